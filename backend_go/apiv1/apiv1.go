@@ -0,0 +1,194 @@
+// Package apiv1 holds the response bodies of the /api/v1 HTTP API and maps the provider's charges and
+// the stored transactions to them. Handlers respond with these instead of *omise.Charge or GORM
+// models, so a new Omise field or model column does not reach clients until it is added here, and the
+// field names below are the v1 contract: rename or remove one only in a new API version.
+package apiv1
+
+import (
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/i18n"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	omise "github.com/omise/omise-go"
+)
+
+// Charge is a charge as created (POST /payments/charge, payment links and intents). The names are
+// Omise's, so clients written against its charge object keep working; customer, refunds, metadata,
+// the payer's IP and other provider internals are left out.
+type Charge struct {
+	ID             string     `json:"id"`
+	Status         string     `json:"status"`       // pending, successful, failed, expired, reversed
+	StatusLabel    string     `json:"status_label"` // Status for display, in the response language
+	Amount         int64      `json:"amount"`       // minor units (satang for THB)
+	Currency       string     `json:"currency"`
+	Description    *string    `json:"description,omitempty"`
+	Paid           bool       `json:"paid"`
+	Authorized     bool       `json:"authorized"`
+	AuthorizeURI   string     `json:"authorize_uri,omitempty"` // where the payer completes 3-D Secure or the bank's page
+	ReturnURI      string     `json:"return_uri,omitempty"`
+	FailureCode    *string    `json:"failure_code,omitempty"`
+	FailureMessage *string    `json:"failure_message,omitempty"`
+	Card           *Card      `json:"card,omitempty"`
+	Source         *Source    `json:"source,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// Card is the card a charge was made with.
+type Card struct {
+	Brand      string `json:"brand"`
+	LastDigits string `json:"last_digits"`
+	Bank       string `json:"bank,omitempty"`
+}
+
+// Source is the payment source of a PromptPay or internet banking charge.
+type Source struct {
+	ID            string         `json:"id"`
+	Type          string         `json:"type"` // e.g. "promptpay", "internet_banking_bbl"
+	ScannableCode *ScannableCode `json:"scannable_code,omitempty"`
+}
+
+// ScannableCode is the QR code the payer scans; Image.DownloadURI is the image.
+type ScannableCode struct {
+	Type  string `json:"type"`
+	Image *Image `json:"image,omitempty"`
+}
+
+// Image is a downloadable image.
+type Image struct {
+	DownloadURI string `json:"download_uri"`
+}
+
+// FromCharge is the v1 view of ch, labeled in lang (i18n.English or i18n.Thai).
+func FromCharge(ch *omise.Charge, lang string) Charge {
+	out := Charge{
+		ID:             ch.ID,
+		Status:         string(ch.Status),
+		StatusLabel:    i18n.StatusLabel(lang, string(ch.Status)),
+		Amount:         ch.Amount,
+		Currency:       ch.Currency,
+		Description:    ch.Description,
+		Paid:           ch.Paid,
+		Authorized:     ch.Authorized,
+		AuthorizeURI:   ch.AuthorizeURI,
+		ReturnURI:      ch.ReturnURI,
+		FailureCode:    ch.FailureCode,
+		FailureMessage: ch.FailureMessage,
+		CreatedAt:      ch.CreatedAt,
+	}
+	if !ch.ExpiresAt.IsZero() {
+		expires := ch.ExpiresAt
+		out.ExpiresAt = &expires
+	}
+	if ch.Card != nil {
+		out.Card = &Card{Brand: ch.Card.Brand, LastDigits: ch.Card.LastDigits, Bank: ch.Card.Bank}
+	}
+	if src := ch.Source; src != nil {
+		out.Source = &Source{ID: src.ID, Type: src.Type}
+		if code := src.ScannableCode; code != nil {
+			out.Source.ScannableCode = &ScannableCode{Type: code.Type}
+			if code.Image != nil {
+				out.Source.ScannableCode.Image = &Image{DownloadURI: code.Image.DownloadURI}
+			}
+		}
+	}
+	return out
+}
+
+// Transaction is a stored transaction (GET /payments/transactions and the other listings). Amounts
+// are minor units.
+type Transaction struct {
+	ID               uint                   `json:"id"`
+	ChargeID         string                 `json:"charge_id"`
+	Provider         string                 `json:"provider"`
+	MerchantID       uint                   `json:"merchant_id"`
+	UserID           *uint                  `json:"user_id,omitempty"`
+	ActingUserID     *uint                  `json:"acting_user_id,omitempty"`
+	AmountSatang     int64                  `json:"amount_satang"`
+	Currency         string                 `json:"currency"`
+	Channel          string                 `json:"channel"`
+	Status           string                 `json:"status"`
+	StatusLabel      string                 `json:"status_label"` // Status for display, in the response language
+	Description      *string                `json:"description,omitempty"`
+	FailureCode      *string                `json:"failure_code,omitempty"`
+	FailureMessage   *string                `json:"failure_message,omitempty"`
+	ExpiresAt        *time.Time             `json:"expires_at,omitempty"`
+	CardBrand        string                 `json:"card_brand,omitempty"`
+	CardLastDigits   string                 `json:"card_last_digits,omitempty"`
+	Bank             string                 `json:"bank,omitempty"`
+	OrderID          *uint                  `json:"order_id,omitempty"`
+	PaymentLinkID    *uint                  `json:"payment_link_id,omitempty"`
+	CouponID         *uint                  `json:"coupon_id,omitempty"`
+	DiscountSatang   int64                  `json:"discount_satang,omitempty"`
+	VATRateBps       int64                  `json:"vat_rate_bps,omitempty"`
+	VATSatang        int64                  `json:"vat_satang,omitempty"`
+	TaxInvoiceNumber *string                `json:"tax_invoice_number,omitempty"`
+	FeeSatang        int64                  `json:"fee_satang,omitempty"`
+	FeeVATSatang     int64                  `json:"fee_vat_satang,omitempty"`
+	NetSatang        int64                  `json:"net_satang,omitempty"`
+	FeesSyncedAt     *time.Time             `json:"fees_synced_at,omitempty"`
+	TransferableAt   *time.Time             `json:"transferable_at,omitempty"`
+	OmiseTransferID  *uint                  `json:"omise_transfer_id,omitempty"`
+	ClientIP         string                 `json:"client_ip,omitempty"`
+	IPCountry        string                 `json:"ip_country,omitempty"`
+	RiskDecision     string                 `json:"risk_decision,omitempty"`
+	RiskScore        int                    `json:"risk_score,omitempty"`
+	RiskReasons      string                 `json:"risk_reasons,omitempty"`
+	Meta             map[string]interface{} `json:"meta,omitempty"`
+	CreatedAt        time.Time              `json:"created_at"`
+	UpdatedAt        time.Time              `json:"updated_at"`
+}
+
+// FromTransaction is the v1 view of t, labeled in lang.
+func FromTransaction(t models.Transaction, lang string) Transaction {
+	return Transaction{
+		ID:               t.ID,
+		ChargeID:         t.ChargeID,
+		Provider:         t.Provider,
+		MerchantID:       t.MerchantID,
+		UserID:           t.UserID,
+		ActingUserID:     t.ActingUserID,
+		AmountSatang:     t.AmountSatang,
+		Currency:         t.Currency,
+		Channel:          t.Channel,
+		Status:           t.Status,
+		StatusLabel:      i18n.StatusLabel(lang, t.Status),
+		Description:      t.Description,
+		FailureCode:      t.FailureCode,
+		FailureMessage:   t.FailureMessage,
+		ExpiresAt:        t.ExpiresAt,
+		CardBrand:        t.CardBrand,
+		CardLastDigits:   t.CardLastDigits,
+		Bank:             t.Bank,
+		OrderID:          t.OrderID,
+		PaymentLinkID:    t.PaymentLinkID,
+		CouponID:         t.CouponID,
+		DiscountSatang:   t.DiscountSatang,
+		VATRateBps:       t.VATRateBps,
+		VATSatang:        t.VATSatang,
+		TaxInvoiceNumber: t.TaxInvoiceNumber,
+		FeeSatang:        t.FeeSatang,
+		FeeVATSatang:     t.FeeVATSatang,
+		NetSatang:        t.NetSatang,
+		FeesSyncedAt:     t.FeesSyncedAt,
+		TransferableAt:   t.TransferableAt,
+		OmiseTransferID:  t.OmiseTransferID,
+		ClientIP:         t.ClientIP,
+		IPCountry:        t.IPCountry,
+		RiskDecision:     t.RiskDecision,
+		RiskScore:        t.RiskScore,
+		RiskReasons:      t.RiskReasons,
+		Meta:             t.Meta,
+		CreatedAt:        t.CreatedAt,
+		UpdatedAt:        t.UpdatedAt,
+	}
+}
+
+// FromTransactions maps a page of transactions; never nil, so an empty page encodes as [].
+func FromTransactions(ts []models.Transaction, lang string) []Transaction {
+	out := make([]Transaction, len(ts))
+	for i, t := range ts {
+		out[i] = FromTransaction(t, lang)
+	}
+	return out
+}
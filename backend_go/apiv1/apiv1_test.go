@@ -0,0 +1,72 @@
+package apiv1
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/i18n"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	omise "github.com/omise/omise-go"
+)
+
+func TestFromChargeLeavesOutProviderInternals(t *testing.T) {
+	ip := "203.0.113.7"
+	ch := &omise.Charge{
+		Base:       omise.Base{ID: "chrg_test_1", CreatedAt: time.Date(2026, 10, 1, 3, 0, 0, 0, time.UTC)},
+		Status:     omise.ChargePending,
+		Amount:     10000,
+		Currency:   "thb",
+		CustomerID: "cust_test_1",
+		IP:         &ip,
+		Metadata:   map[string]interface{}{"user_id": "7", "risk_score": 40},
+		Source: &omise.Source{Base: omise.Base{ID: "src_test_1"}, Type: "promptpay", ScannableCode: &omise.ScannableCode{
+			Type: "qr", Image: &omise.Document{DownloadURI: "https://example.com/qr.png"},
+		}},
+	}
+	raw, err := json.Marshal(FromCharge(ch, i18n.English))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"customer", "ip", "metadata", "refunds", "transaction", "expires_at", "card"} {
+		if _, ok := got[key]; ok {
+			t.Errorf("%s is in the response: %s", key, raw)
+		}
+	}
+	if got["id"] != "chrg_test_1" || got["status"] != "pending" || got["status_label"] != "Pending" || got["amount"] != float64(10000) {
+		t.Errorf("response = %s", raw)
+	}
+	src, _ := got["source"].(map[string]interface{})
+	code, _ := src["scannable_code"].(map[string]interface{})
+	image, _ := code["image"].(map[string]interface{})
+	if image["download_uri"] != "https://example.com/qr.png" {
+		t.Errorf("source.scannable_code.image.download_uri missing: %s", raw)
+	}
+}
+
+func TestFromTransactionsLeavesOutStorageFields(t *testing.T) {
+	if out := FromTransactions(nil, i18n.English); out == nil || len(out) != 0 {
+		t.Errorf("FromTransactions(nil, i18n.English) = %#v, want an empty slice", out)
+	}
+	tx := models.Transaction{ID: 3, ChargeID: "chrg_test_3", Status: "successful", RawPayload: []byte("{}"), RawPayloadObject: "raw/1.bin"}
+	raw, err := json.Marshal(FromTransactions([]models.Transaction{tx}, i18n.Thai))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []map[string]interface{}
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0]["charge_id"] != "chrg_test_3" || got[0]["status"] != "successful" || got[0]["status_label"] != "ชำระเงินสำเร็จ" {
+		t.Fatalf("response = %s", raw)
+	}
+	for _, key := range []string{"raw_payload", "raw_payload_object", "deleted_at", "user"} {
+		if _, ok := got[0][key]; ok {
+			t.Errorf("%s is in the response: %s", key, raw)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Package apperrors defines typed API errors with stable machine-readable codes and HTTP statuses.
+// Handlers return these errors; the Fiber ErrorHandler renders them as {code, message, fields}.
+package apperrors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Error is an API error. Code is stable and safe for clients to branch on; Message is for humans;
+// Err is the internal cause (logged, never sent to clients).
+type Error struct {
+	Status  int          `json:"-"`
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
+	Err     error        `json:"-"`
+}
+
+// FieldError describes one invalid request field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Base errors. Use WithMessage[f] / WithCode / Wrap / WithFields to derive request-specific errors;
+// errors.Is(err, ErrNotFound) matches any error derived from ErrNotFound.
+var (
+	ErrValidation       = New(http.StatusBadRequest, "validation_failed", "request is invalid")
+	ErrBadRequest       = New(http.StatusBadRequest, "bad_request", "request is malformed")
+	ErrUnauthorized     = New(http.StatusUnauthorized, "unauthorized", "authentication required")
+	ErrForbidden        = New(http.StatusForbidden, "forbidden", "not allowed")
+	ErrNotFound         = New(http.StatusNotFound, "not_found", "resource not found")
+	ErrMethodNotAllowed = New(http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+	ErrConflict         = New(http.StatusConflict, "conflict", "request conflicts with the current state")
+	ErrChargeFailed     = New(http.StatusPaymentRequired, "charge_failed", "charge was declined")
+	ErrLimitExceeded    = New(http.StatusTooManyRequests, "limit_exceeded", "limit exceeded, please retry later")
+	ErrOmiseUnavailable = New(http.StatusBadGateway, "provider_unavailable", "payment provider is unavailable, please retry")
+	ErrUnavailable      = New(http.StatusServiceUnavailable, "unavailable", "service temporarily unavailable")
+	ErrInternal         = New(http.StatusInternalServerError, "internal_error", "internal server error")
+)
+
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+	return e.Code + ": " + e.Message
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Is matches errors with the same code, so derived errors match their base.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// WithMessage returns a copy with a client-facing message.
+func (e *Error) WithMessage(msg string) *Error {
+	cp := *e
+	cp.Message = msg
+	return &cp
+}
+
+// WithMessagef is WithMessage with fmt.Sprintf formatting.
+func (e *Error) WithMessagef(format string, args ...interface{}) *Error {
+	return e.WithMessage(fmt.Sprintf(format, args...))
+}
+
+// WithCode returns a copy with a more specific code (same status), e.g. ErrValidation.WithCode("raw_card_disabled").
+func (e *Error) WithCode(code string) *Error {
+	cp := *e
+	cp.Code = code
+	return &cp
+}
+
+// WithFields returns a copy carrying per-field validation details.
+func (e *Error) WithFields(fields []FieldError) *Error {
+	cp := *e
+	cp.Fields = fields
+	return &cp
+}
+
+// Wrap returns a copy that records the internal cause.
+func (e *Error) Wrap(err error) *Error {
+	cp := *e
+	cp.Err = err
+	return &cp
+}
+
+// From converts any error to an *Error; unknown errors become ErrInternal wrapping the cause.
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return ErrInternal.Wrap(err)
+}
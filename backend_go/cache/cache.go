@@ -0,0 +1,95 @@
+// Package cache is a small key-value cache for read-heavy lookups: a Redis client speaking the few
+// commands the service needs, and an in-memory Store for tests and single-instance development.
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrMiss is returned by Get when the key is absent or expired.
+var ErrMiss = errors.New("cache: miss")
+
+// Store is a key-value cache. Entries are best-effort: callers must treat every error as a miss.
+type Store interface {
+	// Get returns the value of key, or ErrMiss.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set stores value under key for ttl (0 keeps it until deleted).
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes the keys; absent keys are not an error.
+	Delete(ctx context.Context, keys ...string) error
+	// Incr increments the integer at key (absent counts as 0) and returns the new value.
+	Incr(ctx context.Context, key string) (int64, error)
+}
+
+// Memory is an in-process Store; the zero value is ready to use.
+type Memory struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time // zero: never
+}
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (m *Memory) Get(_ context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[key]
+	if !ok || (!e.expires.IsZero() && time.Now().After(e.expires)) {
+		return nil, ErrMiss
+	}
+	return append([]byte(nil), e.value...), nil
+}
+
+func (m *Memory) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.set(key, append([]byte(nil), value...), ttl)
+	return nil
+}
+
+func (m *Memory) Delete(_ context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, k := range keys {
+		delete(m.entries, k)
+	}
+	return nil
+}
+
+func (m *Memory) Incr(_ context.Context, key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	if e, ok := m.entries[key]; ok && (e.expires.IsZero() || time.Now().Before(e.expires)) {
+		var err error
+		if n, err = strconv.ParseInt(string(e.value), 10, 64); err != nil {
+			return 0, fmt.Errorf("cache: %s is not an integer", key)
+		}
+	}
+	n++
+	m.set(key, []byte(strconv.FormatInt(n, 10)), 0)
+	return n, nil
+}
+
+// (helper for Memory) store under m.mu.
+func (m *Memory) set(key string, value []byte, ttl time.Duration) {
+	if m.entries == nil {
+		m.entries = make(map[string]memoryEntry)
+	}
+	e := memoryEntry{value: value}
+	if ttl > 0 {
+		e.expires = time.Now().Add(ttl)
+	}
+	m.entries[key] = e
+}
@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis answers AUTH, SELECT, PING, GET, SET, DEL and INCR from a Memory, recording commands.
+func fakeRedis(t *testing.T, password string) (url string, commands func() []string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	var (
+		mu  sync.Mutex
+		log []string
+	)
+	store := NewMemory()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				rd := bufio.NewReader(conn)
+				authed := password == ""
+				for {
+					args, err := readCommand(rd)
+					if err != nil {
+						return
+					}
+					mu.Lock()
+					log = append(log, strings.Join(args, " "))
+					mu.Unlock()
+					ctx := context.Background()
+					var reply string
+					switch cmd := strings.ToUpper(args[0]); {
+					case cmd == "AUTH":
+						authed = args[1] == password
+						reply = "+OK\r\n"
+						if !authed {
+							reply = "-WRONGPASS invalid password\r\n"
+						}
+					case !authed:
+						reply = "-NOAUTH Authentication required.\r\n"
+					case cmd == "SELECT":
+						reply = "+OK\r\n"
+					case cmd == "SET":
+						_ = store.Set(ctx, args[1], []byte(args[2]), 0)
+						reply = "+OK\r\n"
+					case cmd == "PING":
+						reply = "+PONG\r\n"
+					case cmd == "GET":
+						if v, err := store.Get(ctx, args[1]); err == nil {
+							reply = fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+						} else {
+							reply = "$-1\r\n"
+						}
+					case cmd == "DEL":
+						_ = store.Delete(ctx, args[1:]...)
+						reply = fmt.Sprintf(":%d\r\n", len(args)-1)
+					case cmd == "INCR":
+						n, _ := store.Incr(ctx, args[1])
+						reply = fmt.Sprintf(":%d\r\n", n)
+					default:
+						reply = "-ERR unknown command\r\n"
+					}
+					if _, err := io.WriteString(conn, reply); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return "redis://:" + password + "@" + ln.Addr().String() + "/2", func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), log...)
+	}
+}
+
+func readCommand(rd *bufio.Reader) ([]string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, n)
+	for i := range args {
+		if _, err := rd.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := rd.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+	return args, nil
+}
+
+func TestRedisCommands(t *testing.T) {
+	url, commands := fakeRedis(t, "s3cret")
+	r, err := NewRedis(url, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	ctx := context.Background()
+
+	if _, err := r.Get(ctx, "k"); !errors.Is(err, ErrMiss) {
+		t.Fatalf("Get absent: err %v, want ErrMiss", err)
+	}
+	if err := r.Set(ctx, "k", []byte("v 1"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := r.Get(ctx, "k"); err != nil || string(v) != "v 1" {
+		t.Fatalf("Get = %q, %v; want \"v 1\"", v, err)
+	}
+	if err := r.Delete(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Get(ctx, "k"); !errors.Is(err, ErrMiss) {
+		t.Fatalf("Get deleted: err %v, want ErrMiss", err)
+	}
+	for want := int64(1); want <= 2; want++ {
+		if n, err := r.Incr(ctx, "gen"); err != nil || n != want {
+			t.Fatalf("Incr = %d, %v; want %d", n, err, want)
+		}
+	}
+
+	got := commands()
+	want := []string{"AUTH s3cret", "SELECT 2", "GET k", "SET k v 1 PX 60000", "GET k", "DEL k", "GET k", "INCR gen", "INCR gen"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("commands = %q, want %q (one pooled connection)", got, want)
+	}
+}
+
+func TestRedisErrors(t *testing.T) {
+	url, _ := fakeRedis(t, "s3cret")
+	wrong, err := NewRedis(strings.Replace(url, "s3cret", "nope", 1), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wrong.Ping(context.Background()); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("Ping with a wrong password: err %v, want WRONGPASS", err)
+	}
+	for _, bad := range []string{"localhost:6379", "rediss://localhost", "redis://localhost/x"} {
+		if _, err := NewRedis(bad, 0); err == nil {
+			t.Errorf("NewRedis(%q) accepted", bad)
+		}
+	}
+}
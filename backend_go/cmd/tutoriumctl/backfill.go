@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// backfillWindowDays matches the server's limit on one backfill request (handlers/charge_backfill_handler.go).
+const backfillWindowDays = 31
+
+// backfillResult mirrors the server's backfill result (service.ChargeBackfill).
+type backfillResult struct {
+	DryRun       bool `json:"dry_run"`
+	OmiseCharges int  `json:"omise_charges"`
+	Created      int  `json:"created"`
+	Updated      int  `json:"updated"`
+	Unchanged    int  `json:"unchanged"`
+	Failed       int  `json:"failed"`
+	Credited     int  `json:"credited"`
+	Charges      []struct {
+		ChargeID string `json:"charge_id"`
+		Result   string `json:"result"`
+		Error    string `json:"error"`
+	} `json:"charges"`
+}
+
+// chargesBackfill imports Omise's charges from -from up to -to through POST
+// /api/v1/admin/charges/backfill, one window of backfillWindowDays at a time, printing each window's
+// counts. It stops at the first window that fails; re-running from that window's date continues.
+func chargesBackfill(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("charges backfill", flag.ContinueOnError)
+	baseURL := fs.String("url", envOr("TUTORIUM_URL", "http://localhost:8080"), "payment backend base URL")
+	token := fs.String("token", os.Getenv("TUTORIUM_ADMIN_TOKEN"), "admin token (X-Admin-Token)")
+	fromFlag := fs.String("from", "", "first day to backfill, YYYY-MM-DD (Bangkok; required)")
+	toFlag := fs.String("to", "", "day to stop before, YYYY-MM-DD (Bangkok; default tomorrow)")
+	dryRun := fs.Bool("dry-run", false, "report what would be recorded and credited without writing")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *token == "" {
+		return errors.New("an admin token is required (-token or TUTORIUM_ADMIN_TOKEN)")
+	}
+	from, err := time.Parse("2006-01-02", *fromFlag)
+	if err != nil {
+		return errors.New("-from must be a date (YYYY-MM-DD)")
+	}
+	to := time.Now().AddDate(0, 0, 1)
+	if *toFlag != "" {
+		if to, err = time.Parse("2006-01-02", *toFlag); err != nil {
+			return errors.New("-to must be a date (YYYY-MM-DD)")
+		}
+	}
+	to = time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	if !to.After(from) {
+		return errors.New("-to must be after -from")
+	}
+	url := strings.TrimRight(*baseURL, "/") + "/api/v1/admin/charges/backfill"
+
+	var total backfillResult
+	for _, w := range backfillWindows(from, to) {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		res, err := postBackfill(ctx, url, *token, w[0].Format("2006-01-02"), w[1].Format("2006-01-02"), *dryRun)
+		if err != nil {
+			return fmt.Errorf("window %s..%s: %w", w[0].Format("2006-01-02"), w[1].Format("2006-01-02"), err)
+		}
+		fmt.Printf("%s..%s  charges %d  created %d  updated %d  unchanged %d  failed %d  credited %d\n",
+			w[0].Format("2006-01-02"), w[1].Format("2006-01-02"), res.OmiseCharges, res.Created, res.Updated, res.Unchanged, res.Failed, res.Credited)
+		for _, ch := range res.Charges {
+			if ch.Error != "" {
+				fmt.Fprintf(os.Stderr, "  %s %s: %s\n", ch.Result, ch.ChargeID, ch.Error)
+			}
+		}
+		total.OmiseCharges += res.OmiseCharges
+		total.Created += res.Created
+		total.Updated += res.Updated
+		total.Unchanged += res.Unchanged
+		total.Failed += res.Failed
+		total.Credited += res.Credited
+	}
+	verb := "backfilled"
+	if *dryRun {
+		verb = "dry run, nothing written"
+	}
+	fmt.Printf("total (%s): charges %d  created %d  updated %d  unchanged %d  failed %d  credited %d\n",
+		verb, total.OmiseCharges, total.Created, total.Updated, total.Unchanged, total.Failed, total.Credited)
+	return nil
+}
+
+// backfillWindows splits [from, to) into consecutive [start, end) windows of at most
+// backfillWindowDays days.
+func backfillWindows(from, to time.Time) [][2]time.Time {
+	var out [][2]time.Time
+	for start := from; start.Before(to); start = start.AddDate(0, 0, backfillWindowDays) {
+		end := start.AddDate(0, 0, backfillWindowDays)
+		if end.After(to) {
+			end = to
+		}
+		out = append(out, [2]time.Time{start, end})
+	}
+	return out
+}
+
+// postBackfill runs one backfill request.
+func postBackfill(ctx context.Context, url, token, from, to string, dryRun bool) (*backfillResult, error) {
+	body, err := json.Marshal(map[string]interface{}{"from": from, "to": to, "dry_run": dryRun})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Admin-Token", token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("%w: %s", statusError(resp.StatusCode), strings.TrimSpace(string(raw)))
+	}
+	var res backfillResult
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return nil, fmt.Errorf("unreadable response: %w", err)
+	}
+	return &res, nil
+}
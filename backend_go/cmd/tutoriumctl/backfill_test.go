@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackfillWindows(t *testing.T) {
+	day := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	got := backfillWindows(day("2024-01-01"), day("2024-03-05"))
+	want := [][2]string{{"2024-01-01", "2024-02-01"}, {"2024-02-01", "2024-03-03"}, {"2024-03-03", "2024-03-05"}}
+	if len(got) != len(want) {
+		t.Fatalf("windows = %v, want %d", got, len(want))
+	}
+	for i, w := range want {
+		if got[i][0] != day(w[0]) || got[i][1] != day(w[1]) {
+			t.Errorf("window %d = %s..%s, want %s..%s", i, got[i][0].Format("2006-01-02"), got[i][1].Format("2006-01-02"), w[0], w[1])
+		}
+	}
+	if got := backfillWindows(day("2024-01-02"), day("2024-01-01")); len(got) != 0 {
+		t.Errorf("empty range: windows = %v", got)
+	}
+}
@@ -0,0 +1,52 @@
+// Command tutoriumctl is the developer CLI for the payment backend.
+//
+//	tutoriumctl webhooks tail [-url URL] [-token TOKEN] [-json]
+//	tutoriumctl charges backfill -from YYYY-MM-DD [-to YYYY-MM-DD] [-dry-run] [-url URL] [-token TOKEN]
+//
+// The server URL and admin token default to $TUTORIUM_URL (http://localhost:8080) and
+// $TUTORIUM_ADMIN_TOKEN.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+const usage = `usage: tutoriumctl <command> [flags]
+
+commands:
+  webhooks tail     stream webhook deliveries as the server handles them
+  charges backfill  import Omise charges made before the service existed
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	args := os.Args[1:]
+	var err error
+	switch {
+	case len(args) >= 2 && args[0] == "webhooks" && args[1] == "tail":
+		err = webhooksTail(ctx, args[2:])
+	case len(args) >= 2 && args[0] == "charges" && args[1] == "backfill":
+		err = chargesBackfill(ctx, args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "tutoriumctl:", err)
+		os.Exit(1)
+	}
+}
+
+// envOr returns the environment variable key, or def when it is unset.
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/money"
+)
+
+// tailEvent mirrors the server's webhook tail event (handlers/webhook_tail_handler.go).
+type tailEvent struct {
+	ReceivedAt   time.Time `json:"received_at"`
+	Endpoint     string    `json:"endpoint"`
+	Object       string    `json:"object"`
+	ObjectID     string    `json:"object_id"`
+	EventKey     string    `json:"event_key"`
+	ChargeID     string    `json:"charge_id"`
+	Status       string    `json:"status"`
+	AmountSatang int64     `json:"amount_satang"`
+	Currency     string    `json:"currency"`
+	Result       string    `json:"result"`
+	Error        string    `json:"error"`
+	DurationMs   int64     `json:"duration_ms"`
+}
+
+// errStreamClosed means the server ended the stream on purpose (shutdown); reconnect after a pause.
+var errStreamClosed = errors.New("server closed the stream")
+
+// webhooksTail follows GET /api/v1/admin/webhooks/tail until ctx is done, reconnecting when the
+// stream drops (server restart, proxy timeout).
+func webhooksTail(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("webhooks tail", flag.ContinueOnError)
+	baseURL := fs.String("url", envOr("TUTORIUM_URL", "http://localhost:8080"), "payment backend base URL")
+	token := fs.String("token", os.Getenv("TUTORIUM_ADMIN_TOKEN"), "admin token (X-Admin-Token)")
+	raw := fs.Bool("json", false, "print each event as a JSON line instead of a summary")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *token == "" {
+		return errors.New("an admin token is required (-token or TUTORIUM_ADMIN_TOKEN)")
+	}
+	url := strings.TrimRight(*baseURL, "/") + "/api/v1/admin/webhooks/tail"
+
+	backoff := time.Second
+	for {
+		err := streamWebhooks(ctx, url, *token, func(data []byte) {
+			if *raw {
+				fmt.Println(string(data))
+				return
+			}
+			var ev tailEvent
+			if err := json.Unmarshal(data, &ev); err != nil {
+				fmt.Fprintf(os.Stderr, "unreadable event: %s\n", data)
+				return
+			}
+			fmt.Println(formatTailEvent(ev))
+		})
+		if ctx.Err() != nil {
+			return nil
+		}
+		var status statusError
+		if errors.As(err, &status) && (status == http.StatusUnauthorized || status == http.StatusForbidden) {
+			return err
+		}
+		if errors.Is(err, errStreamClosed) || errors.Is(err, io.ErrUnexpectedEOF) {
+			backoff = time.Second // we were connected; the stream just ended
+		}
+		fmt.Fprintf(os.Stderr, "-- disconnected (%v); reconnecting in %s\n", err, backoff)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// statusError is a non-200 response to the stream request.
+type statusError int
+
+func (s statusError) Error() string {
+	return fmt.Sprintf("server answered %d %s", int(s), http.StatusText(int(s)))
+}
+
+// streamWebhooks reads one SSE connection, calling onEvent with the data of every "webhook" event.
+func streamWebhooks(ctx context.Context, url, token string, onEvent func(data []byte)) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Admin-Token", token)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return statusError(resp.StatusCode)
+	}
+	fmt.Fprintf(os.Stderr, "-- tailing webhooks at %s (Ctrl-C to stop)\n", url)
+	return readSSE(resp.Body, func(event string, data []byte) error {
+		switch event {
+		case "webhook":
+			onEvent(data)
+		case "shutdown":
+			return errStreamClosed
+		}
+		return nil
+	})
+}
+
+// readSSE parses a text/event-stream, calling fn per dispatched event. Comments are skipped.
+func readSSE(r io.Reader, fn func(event string, data []byte) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	event, data := "", []string{}
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "":
+			if len(data) > 0 {
+				if event == "" {
+					event = "message"
+				}
+				if err := fn(event, []byte(strings.Join(data, "\n"))); err != nil {
+					return err
+				}
+			}
+			event, data = "", data[:0]
+		case strings.HasPrefix(line, ":"):
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return io.ErrUnexpectedEOF
+}
+
+// formatTailEvent renders one line, e.g.
+//
+//	14:03:22  processed  charge.complete  chrg_test_5x  successful  100.00 THB  (84ms)
+func formatTailEvent(ev tailEvent) string {
+	parts := []string{ev.ReceivedAt.Local().Format("15:04:05"), fmt.Sprintf("%-9s", ev.Result)}
+	if ev.Endpoint != "" {
+		parts = append(parts, "["+ev.Endpoint+"]")
+	}
+	switch {
+	case ev.EventKey != "":
+		parts = append(parts, ev.EventKey)
+	case ev.Object != "":
+		parts = append(parts, ev.Object+" "+ev.ObjectID)
+	}
+	if ev.ChargeID != "" {
+		parts = append(parts, ev.ChargeID)
+	}
+	if ev.Status != "" {
+		parts = append(parts, ev.Status)
+	}
+	if ev.Currency != "" {
+		parts = append(parts, money.New(ev.AmountSatang, strings.ToUpper(ev.Currency)).String())
+	}
+	line := strings.Join(parts, "  ") + fmt.Sprintf("  (%dms)", ev.DurationMs)
+	if ev.Error != "" {
+		line += "  error: " + ev.Error
+	}
+	return line
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadSSE(t *testing.T) {
+	stream := ": tailing webhooks\n\n" +
+		"event: webhook\ndata: {\"a\":1}\n\n" +
+		": heartbeat\n\n" +
+		"event: webhook\ndata: {\"a\":2}\n\n" +
+		"event: shutdown\ndata: {}\n\n"
+	var got []string
+	err := readSSE(strings.NewReader(stream), func(event string, data []byte) error {
+		if event == "shutdown" {
+			return errStreamClosed
+		}
+		got = append(got, event+" "+string(data))
+		return nil
+	})
+	if !errors.Is(err, errStreamClosed) {
+		t.Errorf("err = %v, want errStreamClosed", err)
+	}
+	if want := []string{`webhook {"a":1}`, `webhook {"a":2}`}; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+
+	if err := readSSE(strings.NewReader("event: webhook\ndata: {}\n"), func(string, []byte) error { return nil }); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("truncated stream: err = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestFormatTailEvent(t *testing.T) {
+	ev := tailEvent{
+		ReceivedAt:   time.Date(2025, 3, 1, 14, 3, 22, 0, time.Local),
+		EventKey:     "charge.complete",
+		ChargeID:     "chrg_test_1",
+		Status:       "successful",
+		AmountSatang: 10000,
+		Currency:     "thb",
+		Result:       "processed",
+		DurationMs:   84,
+	}
+	line := formatTailEvent(ev)
+	for _, want := range []string{"14:03:22", "processed", "charge.complete", "chrg_test_1", "successful", "100.00", "(84ms)"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("line %q missing %q", line, want)
+		}
+	}
+
+	ev = tailEvent{ReceivedAt: ev.ReceivedAt, Object: "event", ObjectID: "evnt_1", Result: "failed", Error: "verify event: timeout"}
+	if line := formatTailEvent(ev); !strings.Contains(line, "event evnt_1") || !strings.HasSuffix(line, "error: verify event: timeout") {
+		t.Errorf("failed event line = %q", line)
+	}
+}
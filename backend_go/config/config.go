@@ -0,0 +1,841 @@
+// Package config loads the service configuration from the environment once at startup.
+// Every setting has a sane default except secrets; Load fails fast listing every problem at once.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/cache"
+	"github.com/a2n2k3p4/tutorium-backend/fx"
+	"github.com/a2n2k3p4/tutorium-backend/jobs"
+	"github.com/a2n2k3p4/tutorium-backend/money"
+	"github.com/a2n2k3p4/tutorium-backend/notify"
+	"github.com/a2n2k3p4/tutorium-backend/risk"
+	"github.com/a2n2k3p4/tutorium-backend/warehouse"
+)
+
+// Config is the full runtime configuration. main builds it with Load and passes the pieces
+// to the database, the Omise client, Fiber and the handlers; nothing else reads os.Getenv.
+type Config struct {
+	Port string // PORT, default 8080
+
+	DB    DBConfig
+	Omise OmiseConfig
+	SMTP  notify.SMTPConfig
+	// PAYMENT_EMAILS, email payers a receipt on success and a notice on a declined charge (needs SMTP_*;
+	// for SES use its SMTP endpoint); default true, set false in sandbox
+	PaymentEmails bool
+	// LINE_CHANNEL_ACCESS_TOKEN of the LINE Official Account (Messaging API) that pushes payment
+	// confirmations and QR-expiry reminders to linked users; empty disables LINE
+	LineChannelToken string
+	// FCM_CREDENTIALS_FILE, the key file (JSON) of a Firebase service account that pushes payment
+	// status to the mobile app's devices; empty disables push notifications
+	FCMCredentialsFile string
+	// FCM_DEEP_LINK_BASE, where a payment status push leads in the app, as <base>/<charge id>; default
+	// "tutorium://payments"
+	FCMDeepLinkBase string
+
+	// CORS_ALLOWED_ORIGINS, comma-separated; default "*"
+	CORSOrigins []string
+
+	// ADMIN_API_TOKEN; empty disables the /admin routes (RequireAdmin rejects everything)
+	AdminToken string
+	// RETURN_URI_ALLOWED_HOSTS, e.g. "app.tutorium.io,*.tutorium.io"; empty disables the check
+	ReturnURIAllowedHosts []string
+	// GEO_COUNTRY_HEADER, the header a trusted proxy sends the client's country in, e.g. "CF-IPCountry";
+	// empty records no country (and CHARGE_COUNTRIES_* limit nothing)
+	CountryHeader string
+	// CHARGE_COUNTRIES_ALLOWED and CHARGE_COUNTRIES_DENIED, ISO country codes ("TH,LA,KH") payers may or
+	// may not charge from; empty allows all
+	AllowedCountries []string
+	DeniedCountries  []string
+	// RAW_PAYLOAD_KEY, base64 32-byte AES key; empty stores raw payloads unencrypted
+	RawPayloadKey string
+	// TAX_PROVIDER selects the e-Tax invoice integration ("stub"); empty disables submission
+	TaxProvider string
+	// RISK_EVALUATOR scores charges before they are created ("rules"): risky ones must pass 3-D Secure
+	// or are refused. Empty disables risk scoring
+	RiskEvaluator string
+	// VAT_RATE_PCT, the VAT included in charge amounts (default 7); successful charges get their VAT and
+	// a tax invoice number at this rate, 0 disables both
+	VATRatePct float64
+	// WALLET_FX_RATES, THB per unit of each other currency whose charges may credit a wallet, e.g.
+	// "usd:36.5,sgd:27.1"; charges for a user in a currency without a rate are refused
+	WalletFXRates map[string]float64
+	// FX_PROVIDER, where /payments/quote gets its indicative rates: "static" (default) quotes
+	// WALLET_FX_RATES, "http" fetches FX_RATES_URL; empty disables quotes
+	FXProvider string
+	// FX_RATES_URL, the JSON rates API of the "http" provider, with {base} for the currency to convert
+	// from; default "https://open.er-api.com/v6/latest/{base}"
+	FXRatesURL string
+	// FX_RATES_TTL, how long a rate is cached (in Redis when REDIS_URL is set); default 1h
+	FXRatesTTL time.Duration
+	// PAYMENT_LINK_BASE_URL, the hosted checkout page payment links open, e.g. "https://app.tutorium.io/pay"
+	// (links are <base>/<token>); empty leaves the URL out and clients build it
+	PaymentLinkBaseURL string
+	// PAGE_SIZE_DEFAULT, the rows a listing returns without ?limit= (default 50), and PAGE_SIZE_MAX, the
+	// most it returns whatever ?limit= asks for (default 200; larger limits are clamped)
+	PageSizeDefault int
+	PageSizeMax     int
+	// DEBUG_LISTEN_ADDR, e.g. "127.0.0.1:6060": also serve /debug/pprof and /debug/vars there without
+	// admin auth (bind to localhost or a private interface only); empty disables it
+	DebugListenAddr string
+	// GRPC_LISTEN_ADDR, e.g. ":9090": serve the gRPC payments API (see grpcapi) there; empty disables it
+	GRPCListenAddr string
+	// GRPC_AUTH_TOKEN, bearer token internal services send to the gRPC API; required with GRPC_LISTEN_ADDR
+	GRPCAuthToken string
+	// QR_LOGO_PATH, PNG/JPEG logo drawn on shareable PromptPay QR images; empty prints the brand name only
+	QRLogoPath string
+
+	// WEBHOOK_ENDPOINTS, comma-separated names served at /api/v1/webhooks/<name> in addition to the
+	// always-present "omise"; each is configured by WEBHOOK_ENDPOINT_<NAME>_* (see WebhookEndpointConfig)
+	WebhookEndpoints []WebhookEndpointConfig
+
+	// MERCHANTS, comma-separated names of the Omise accounts served besides the default one (OMISE_*
+	// keys); each is configured by MERCHANT_<NAME>_* (see MerchantConfig)
+	Merchants []MerchantConfig
+
+	// API_CONSUMERS, comma-separated names of the internal services calling this API; each sends its
+	// API_CONSUMER_<NAME>_KEY as X-API-Key so its usage is attributed to it (see APIConsumerConfig)
+	APIConsumers []APIConsumerConfig
+
+	RefundBudget   RefundBudgetConfig
+	ChargeLimits   ChargeLimitsConfig
+	Payouts        PayoutsConfig
+	WebhookSLA     WebhookSLAConfig
+	Alerts         AlertsConfig
+	Backpressure   BackpressureConfig
+	Jobs           JobsConfig
+	Cache          CacheConfig
+	Warehouse      WarehouseConfig
+	PayloadArchive PayloadArchiveConfig
+
+	Features Features
+	Timeouts Timeouts
+}
+
+// RefundBudgetConfig drives daily refund volume alerts (REFUND_*). Amounts are in THB.
+type RefundBudgetConfig struct {
+	DailyLimitTHB float64 // REFUND_DAILY_BUDGET_THB; 0 disables threshold alerts
+	ThresholdsPct []int   // REFUND_ALERT_THRESHOLDS, percent of the budget; default 80,100
+	AnomalyFactor float64 // REFUND_ANOMALY_FACTOR, today vs 28-day daily average; 0 disables
+	AnomalyMinTHB float64 // REFUND_ANOMALY_MIN_THB, ignore anomalies below this total
+}
+
+// ChargeLimitsConfig caps charges against card testing and stolen cards (CHARGE_LIMIT_*); see
+// service.VelocityLimits. Amounts are in THB; 0 disables a limit.
+type ChargeLimitsConfig struct {
+	MaxChargeTHB  float64 // CHARGE_LIMIT_MAX_AMOUNT_THB, one charge
+	PerHour       int     // CHARGE_LIMIT_PER_HOUR, charges a user may create in an hour; default 10
+	PerDay        int     // CHARGE_LIMIT_PER_DAY, the same in 24 hours; default 30
+	DailyTopUpTHB float64 // CHARGE_LIMIT_DAILY_TOP_UP_THB, a user's pending and successful charges in 24 hours
+	// CHARGE_DUPLICATE_WINDOW, refuse a user's charge repeating the amount of one of theirs this recent
+	// unless sent with force=true; default 2m
+	DuplicateWindow time.Duration
+	// CARD_3DS_MIN_AMOUNT_THB, card charges of this much or more must pass 3-D Secure; 0 disables
+	SecureCardMinTHB float64
+	// CARD_3DS_RISK_FLAGGED, card charges flagged by RISK_EVALUATOR must pass 3-D Secure; default true
+	SecureCardRiskFlagged bool
+}
+
+// PayoutsConfig is the rolling reserve withheld from teacher payouts (PAYOUT_*).
+type PayoutsConfig struct {
+	ReservePct  float64 // PAYOUT_RESERVE_PCT, percent of earnings withheld per statement; 0 disables
+	ReserveDays int     // PAYOUT_RESERVE_DAYS, days after the period ends before a hold is paid out
+}
+
+// WebhookSLAConfig is the webhook latency we promise the booking service (WEBHOOK_*).
+type WebhookSLAConfig struct {
+	Delivery      time.Duration // WEBHOOK_DELIVERY_SLA, provider event created -> received by us
+	Processing    time.Duration // WEBHOOK_PROCESSING_SLA, received -> transaction updated
+	AlertCooldown time.Duration // WEBHOOK_SLA_ALERT_COOLDOWN, minimum gap between breach alerts
+}
+
+// WebhookEndpointConfig is one inbound webhook path. <NAME> is the name upper-cased with "-" as "_",
+// e.g. WEBHOOK_ENDPOINT_SCHOOL_A_SECRET for "school-a".
+type WebhookEndpointConfig struct {
+	Name          string
+	Secret        string   // WEBHOOK_ENDPOINT_<NAME>_SECRET, sent as X-Webhook-Token or ?token=; empty is open
+	Events        []string // WEBHOOK_ENDPOINT_<NAME>_EVENTS, event keys handled ("charge.*" prefix); empty is all
+	InstitutionID int      // WEBHOOK_ENDPOINT_<NAME>_INSTITUTION_ID, only record this institution's charges; 0 is any
+}
+
+// JobsConfig schedules the background jobs (JOB_*_SCHEDULE): five-field cron expressions ("minute hour
+// day month weekday", Bangkok time), or "off" to disable a job.
+type JobsConfig struct {
+	ExpirePending         string        // JOB_EXPIRE_PENDING_SCHEDULE, default every 15 minutes
+	PendingTTL            time.Duration // PENDING_CHARGE_TTL, pending charges without an expires_at older than this are expired
+	Reconcile             string        // JOB_RECONCILE_SCHEDULE, default every 10 minutes
+	ReconcileAfter        time.Duration // RECONCILE_AFTER, age at which a pending charge is re-fetched from Omise
+	FullReconcile         string        // JOB_FULL_RECONCILE_SCHEDULE, default 03:00 daily (previous day against Omise's charge list)
+	FullReconcileHeal     bool          // FULL_RECONCILE_HEAL, record missing and stale transactions from Omise (default false: report only)
+	PayoutStatements      string        // JOB_PAYOUT_STATEMENTS_SCHEDULE, default 02:00 on the 1st (previous month)
+	PruneRawPayloads      string        // JOB_PRUNE_RAW_PAYLOADS_SCHEDULE, default 04:30 daily
+	RawPayloadRetention   time.Duration // RAW_PAYLOAD_RETENTION, raw payloads of settled charges older than this are cleared (or archived, see PayloadArchiveConfig)
+	LeaderLease           time.Duration // JOB_LEADER_LEASE, lease of the one replica that runs the jobs; a dead leader is replaced within it
+	WarehouseExport       string        // JOB_WAREHOUSE_EXPORT_SCHEDULE, default 03:30 daily (previous day's transactions; needs WAREHOUSE_BUCKET)
+	TransactionRollup     string        // JOB_TRANSACTION_ROLLUP_SCHEDULE, default hourly at :10 (daily rollups read by /payments/stats)
+	RollupLookbackDays    int           // TRANSACTION_ROLLUP_LOOKBACK_DAYS, past days re-rolled every run to pick up late status changes
+	LineQRReminders       string        // JOB_LINE_QR_REMINDERS_SCHEDULE, default every 5 minutes (needs LINE_CHANNEL_ACCESS_TOKEN)
+	LineQRReminderLead    time.Duration // LINE_QR_REMINDER_LEAD, how long before a PromptPay QR expires its payer is reminded
+	ChargeFailureRate     string        // JOB_CHARGE_FAILURE_RATE_SCHEDULE, default every 5 minutes (alerts per ALERT_FAILURE_RATE_*)
+	ChargeFees            string        // JOB_CHARGE_FEES_SCHEDULE, default hourly at :20 (Omise fees of successful charges, for /admin/settlements)
+	Transfers             string        // JOB_TRANSFERS_SCHEDULE, default 05:00 daily (Omise transfers matched to their charges, for /admin/settlements/transfers)
+	TransactionPartitions string        // JOB_TRANSACTION_PARTITIONS_SCHEDULE, default 01:00 daily (the coming months' partitions of transactions)
+	ReportSubscriptions   string        // JOB_REPORT_SUBSCRIPTIONS_SCHEDULE, default every minute (sends the report subscriptions that are due)
+	ConsistencyChecks     string        // JOB_CONSISTENCY_CHECKS_SCHEDULE, default 03:00 daily (the ledger consistency checks; at most one run a day)
+}
+
+// WarehouseConfig is the object-storage bucket the warehouse_export job delivers to (WAREHOUSE_*): S3,
+// or GCS with HMAC keys and WAREHOUSE_ENDPOINT=storage.googleapis.com.
+type WarehouseConfig struct {
+	Bucket    string // WAREHOUSE_BUCKET; empty disables the export
+	Endpoint  string // WAREHOUSE_ENDPOINT, default s3.amazonaws.com
+	Region    string // WAREHOUSE_REGION, empty lets the client discover it
+	AccessKey string // WAREHOUSE_ACCESS_KEY, required with WAREHOUSE_BUCKET
+	SecretKey string // WAREHOUSE_SECRET_KEY, required with WAREHOUSE_BUCKET
+	Insecure  bool   // WAREHOUSE_INSECURE, plain HTTP for a local MinIO
+	Format    string // WAREHOUSE_FORMAT, "csv" or "csv.gz" (default)
+	Prefix    string // WAREHOUSE_PREFIX, object key prefix, default "transactions/"
+}
+
+// PayloadArchiveConfig is the bucket raw charge payloads are moved to once RAW_PAYLOAD_RETENTION
+// passes (RAW_PAYLOAD_ARCHIVE_*), so they can still be read for audits.
+type PayloadArchiveConfig struct {
+	Bucket    string // RAW_PAYLOAD_ARCHIVE_BUCKET; empty discards pruned payloads
+	Endpoint  string // RAW_PAYLOAD_ARCHIVE_ENDPOINT, default s3.amazonaws.com
+	Region    string // RAW_PAYLOAD_ARCHIVE_REGION, empty lets the client discover it
+	AccessKey string // RAW_PAYLOAD_ARCHIVE_ACCESS_KEY, required with RAW_PAYLOAD_ARCHIVE_BUCKET
+	SecretKey string // RAW_PAYLOAD_ARCHIVE_SECRET_KEY, required with RAW_PAYLOAD_ARCHIVE_BUCKET
+	Insecure  bool   // RAW_PAYLOAD_ARCHIVE_INSECURE, plain HTTP for a local MinIO
+	Prefix    string // RAW_PAYLOAD_ARCHIVE_PREFIX, object key prefix, default "raw-payloads/"
+}
+
+// MerchantConfig is one more Omise account. Clients pick it with the X-Merchant header, and Omise
+// delivers its webhooks to /api/v1/webhooks/<name>. <NAME> is the name upper-cased with "-" as "_",
+// e.g. MERCHANT_UNI_PARTNER_OMISE_SECRET_KEY for "uni-partner".
+type MerchantConfig struct {
+	Name          string
+	PublicKey     string // MERCHANT_<NAME>_OMISE_PUBLIC_KEY, required unless MOCK_OMISE
+	SecretKey     string // MERCHANT_<NAME>_OMISE_SECRET_KEY, required unless MOCK_OMISE
+	WebhookSecret string // MERCHANT_<NAME>_WEBHOOK_SECRET, sent as X-Webhook-Token or ?token=; required
+}
+
+// APIConsumerConfig is one internal consumer of the API. <NAME> is the name upper-cased with "-" as
+// "_", e.g. API_CONSUMER_LIBRARY_FINES_KEY for "library-fines".
+type APIConsumerConfig struct {
+	Name string
+	Key  string // API_CONSUMER_<NAME>_KEY, required
+}
+
+// AlertsConfig lists where admin alerts are delivered, and when the operational ones fire.
+type AlertsConfig struct {
+	SlackWebhookURL   string   // ALERT_SLACK_WEBHOOK_URL
+	DiscordWebhookURL string   // ALERT_DISCORD_WEBHOOK_URL
+	Emails            []string // ALERT_EMAILS, comma-separated (needs SMTP_*)
+
+	FailureRatePct    float64       // ALERT_FAILURE_RATE_PCT, failed percent of settled charges that alerts; 0 disables
+	FailureWindow     time.Duration // ALERT_FAILURE_RATE_WINDOW, the recent charges the rate is taken over
+	FailureMinCharges int           // ALERT_FAILURE_RATE_MIN_CHARGES, settled charges needed in the window to judge
+	Cooldown          time.Duration // ALERT_COOLDOWN, minimum gap between alerts of one kind (failure rate, webhook failures, Omise outage)
+}
+
+// BackpressureConfig sets the queue depths above which load is shed (BACKPRESSURE_*); 0 disables a limit.
+type BackpressureConfig struct {
+	MaxWebhooks   int           // BACKPRESSURE_MAX_WEBHOOKS, webhook requests processed at once
+	MaxBackground int           // BACKPRESSURE_MAX_BACKGROUND, pending background tasks
+	ShedCharges   bool          // BACKPRESSURE_SHED_CHARGES, also reject charge creation while overloaded
+	RetryAfter    time.Duration // BACKPRESSURE_RETRY_AFTER, Retry-After on shed responses
+}
+
+// CacheConfig configures the Redis cache of polled transaction reads (see repository.TransactionCache).
+type CacheConfig struct {
+	RedisURL string        // REDIS_URL, "redis://[:password@]host[:port][/db]"; empty disables caching
+	TTL      time.Duration // TRANSACTION_CACHE_TTL, how long a lookup or list total is served from the cache
+	Timeout  time.Duration // REDIS_TIMEOUT, per command; a slower Redis is treated as a miss
+}
+
+// DBConfig holds the Postgres connection settings (DB_*).
+type DBConfig struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	Name     string
+	SSLMode  string
+
+	// ReplicaHost (DB_REPLICA_HOST) is a streaming read replica serving transaction lists and reports;
+	// it shares the primary's user, password and database. Empty reads everything from the primary.
+	ReplicaHost string
+	ReplicaPort string // DB_REPLICA_PORT, default DB_PORT
+
+	// Connection pool, per database (primary and replica each get one).
+	MaxOpenConns    int           // DB_MAX_OPEN_CONNS, 0 is unlimited
+	MaxIdleConns    int           // DB_MAX_IDLE_CONNS, at most DB_MAX_OPEN_CONNS
+	ConnMaxLifetime time.Duration // DB_CONN_MAX_LIFETIME, connections are recycled after this
+	ConnMaxIdleTime time.Duration // DB_CONN_MAX_IDLE_TIME, idle connections are closed after this
+}
+
+// DSN renders the connection string for gorm's postgres driver.
+func (c DBConfig) DSN() string {
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
+		c.Host, c.User, c.Password, c.Name, c.Port, c.SSLMode)
+}
+
+// ReplicaDSN is DSN for the read replica, or "" when none is configured.
+func (c DBConfig) ReplicaDSN() string {
+	if c.ReplicaHost == "" {
+		return ""
+	}
+	replica := c
+	replica.Host, replica.Port = c.ReplicaHost, c.ReplicaPort
+	return replica.DSN()
+}
+
+// OmiseConfig holds the Omise API keys (OMISE_PUBLIC_KEY / OMISE_SECRET_KEY), both required.
+type OmiseConfig struct {
+	PublicKey  string
+	SecretKey  string
+	APIVersion string // OMISE_API_VERSION: pinned Omise-Version header; empty uses gateway.DefaultAPIVersion
+	// MockBaseURL (MOCK_OMISE_BASE_URL) is how clients reach this server when MOCK_OMISE=true; the
+	// simulator's QR and payment page links point at it. Defaults to http://localhost:$PORT.
+	MockBaseURL string
+
+	// Retries and circuit breaker for Omise calls (see gateway.Resilience).
+	RetryAttempts    int           // OMISE_RETRY_ATTEMPTS, tries per call including the first (1 disables retries)
+	RetryBaseDelay   time.Duration // OMISE_RETRY_BASE_DELAY, first backoff, doubled per retry
+	RetryMaxDelay    time.Duration // OMISE_RETRY_MAX_DELAY
+	BreakerThreshold int           // OMISE_BREAKER_THRESHOLD, consecutive failures that open the breaker (0 disables it)
+	BreakerCooldown  time.Duration // OMISE_BREAKER_COOLDOWN, how long an open breaker fails fast
+}
+
+// Features are boolean feature flags ("true"/"false", "1"/"0").
+type Features struct {
+	AllowRawCard bool // ALLOW_RAW_CARD: server-side card tokenization, sandbox only
+	MockOmise    bool // MOCK_OMISE: in-process Omise simulator, no keys needed; never in production
+	// MIGRATE_ON_START: apply pending migrations before the schema check instead of refusing to boot;
+	// for development and single-instance deploys, otherwise run `migrate up` as a release step
+	MigrateOnStart bool
+}
+
+// Timeouts groups every duration setting (Go duration syntax, e.g. "15s", "2m").
+type Timeouts struct {
+	HTTPRead     time.Duration // HTTP_READ_TIMEOUT
+	HTTPWrite    time.Duration // HTTP_WRITE_TIMEOUT
+	HTTPIdle     time.Duration // HTTP_IDLE_TIMEOUT
+	OmiseRequest time.Duration // OMISE_TIMEOUT
+	Request      time.Duration // REQUEST_TIMEOUT: context deadline of API requests (their Omise and DB calls)
+	AdminRequest time.Duration // ADMIN_REQUEST_TIMEOUT: the same for /admin requests
+	Background   time.Duration // BACKGROUND_TIMEOUT: deadline of each background task (auto-reload, tax submission)
+	Shutdown     time.Duration // SHUTDOWN_TIMEOUT: total drain budget on SIGTERM
+}
+
+// Load reads and validates the configuration. The returned error lists every invalid or missing setting.
+func Load() (*Config, error) {
+	l := &loader{}
+	mockOmise := l.boolean("MOCK_OMISE", false)
+	omiseKey := l.required
+	if mockOmise {
+		omiseKey = func(key string) string { return l.str(key, "") } // the simulator needs no keys
+	}
+	port := l.str("PORT", "8080")
+	cfg := &Config{
+		Port: port,
+		DB:   l.db(),
+		Omise: OmiseConfig{
+			PublicKey:   omiseKey("OMISE_PUBLIC_KEY"),
+			SecretKey:   omiseKey("OMISE_SECRET_KEY"),
+			APIVersion:  l.str("OMISE_API_VERSION", ""),
+			MockBaseURL: l.str("MOCK_OMISE_BASE_URL", "http://localhost:"+port),
+
+			RetryAttempts:    l.count("OMISE_RETRY_ATTEMPTS", 3),
+			RetryBaseDelay:   l.duration("OMISE_RETRY_BASE_DELAY", 200*time.Millisecond),
+			RetryMaxDelay:    l.duration("OMISE_RETRY_MAX_DELAY", 2*time.Second),
+			BreakerThreshold: l.count("OMISE_BREAKER_THRESHOLD", 5),
+			BreakerCooldown:  l.duration("OMISE_BREAKER_COOLDOWN", 30*time.Second),
+		},
+		SMTP: notify.SMTPConfig{
+			Host:     l.str("SMTP_HOST", ""),
+			Port:     l.str("SMTP_PORT", "587"),
+			User:     l.str("SMTP_USER", ""),
+			Password: l.str("SMTP_PASSWORD", ""),
+			From:     l.str("SMTP_FROM", ""),
+		},
+		PaymentEmails:         l.boolean("PAYMENT_EMAILS", true),
+		LineChannelToken:      l.str("LINE_CHANNEL_ACCESS_TOKEN", ""),
+		FCMCredentialsFile:    l.str("FCM_CREDENTIALS_FILE", ""),
+		FCMDeepLinkBase:       strings.TrimRight(l.str("FCM_DEEP_LINK_BASE", "tutorium://payments"), "/"),
+		CORSOrigins:           l.list("CORS_ALLOWED_ORIGINS", []string{"*"}),
+		AdminToken:            l.str("ADMIN_API_TOKEN", ""),
+		ReturnURIAllowedHosts: l.list("RETURN_URI_ALLOWED_HOSTS", nil),
+		CountryHeader:         l.str("GEO_COUNTRY_HEADER", ""),
+		AllowedCountries:      l.countries("CHARGE_COUNTRIES_ALLOWED"),
+		DeniedCountries:       l.countries("CHARGE_COUNTRIES_DENIED"),
+		RawPayloadKey:         l.str("RAW_PAYLOAD_KEY", ""),
+		TaxProvider:           l.str("TAX_PROVIDER", ""),
+		RiskEvaluator:         l.str("RISK_EVALUATOR", ""),
+		VATRatePct:            l.float("VAT_RATE_PCT", 7),
+		WalletFXRates:         l.fxRates("WALLET_FX_RATES"),
+		FXProvider:            l.str("FX_PROVIDER", "static"),
+		FXRatesURL:            l.str("FX_RATES_URL", "https://open.er-api.com/v6/latest/{base}"),
+		FXRatesTTL:            l.duration("FX_RATES_TTL", time.Hour),
+		PaymentLinkBaseURL:    l.str("PAYMENT_LINK_BASE_URL", ""),
+		PageSizeDefault:       l.count("PAGE_SIZE_DEFAULT", 50),
+		PageSizeMax:           l.count("PAGE_SIZE_MAX", 200),
+		QRLogoPath:            l.str("QR_LOGO_PATH", ""),
+		DebugListenAddr:       l.str("DEBUG_LISTEN_ADDR", ""),
+		GRPCListenAddr:        l.str("GRPC_LISTEN_ADDR", ""),
+		GRPCAuthToken:         l.str("GRPC_AUTH_TOKEN", ""),
+		WebhookEndpoints:      l.webhookEndpoints("WEBHOOK_ENDPOINTS"),
+		Merchants:             l.merchants("MERCHANTS", omiseKey),
+		APIConsumers:          l.apiConsumers("API_CONSUMERS"),
+		RefundBudget: RefundBudgetConfig{
+			DailyLimitTHB: l.float("REFUND_DAILY_BUDGET_THB", 0),
+			ThresholdsPct: l.ints("REFUND_ALERT_THRESHOLDS", []int{80, 100}),
+			AnomalyFactor: l.float("REFUND_ANOMALY_FACTOR", 3),
+			AnomalyMinTHB: l.float("REFUND_ANOMALY_MIN_THB", 1000),
+		},
+		ChargeLimits: ChargeLimitsConfig{
+			MaxChargeTHB:    l.float("CHARGE_LIMIT_MAX_AMOUNT_THB", 0),
+			PerHour:         l.count("CHARGE_LIMIT_PER_HOUR", 10),
+			PerDay:          l.count("CHARGE_LIMIT_PER_DAY", 30),
+			DailyTopUpTHB:   l.float("CHARGE_LIMIT_DAILY_TOP_UP_THB", 0),
+			DuplicateWindow: l.duration("CHARGE_DUPLICATE_WINDOW", 2*time.Minute),
+
+			SecureCardMinTHB:      l.float("CARD_3DS_MIN_AMOUNT_THB", 0),
+			SecureCardRiskFlagged: l.boolean("CARD_3DS_RISK_FLAGGED", true),
+		},
+		Payouts: PayoutsConfig{
+			ReservePct:  l.float("PAYOUT_RESERVE_PCT", 10),
+			ReserveDays: l.count("PAYOUT_RESERVE_DAYS", 90),
+		},
+		WebhookSLA: WebhookSLAConfig{
+			Delivery:      l.duration("WEBHOOK_DELIVERY_SLA", 2*time.Minute),
+			Processing:    l.duration("WEBHOOK_PROCESSING_SLA", 5*time.Second),
+			AlertCooldown: l.duration("WEBHOOK_SLA_ALERT_COOLDOWN", 15*time.Minute),
+		},
+		Alerts: AlertsConfig{
+			SlackWebhookURL:   l.str("ALERT_SLACK_WEBHOOK_URL", ""),
+			DiscordWebhookURL: l.str("ALERT_DISCORD_WEBHOOK_URL", ""),
+			Emails:            l.list("ALERT_EMAILS", nil),
+			FailureRatePct:    l.float("ALERT_FAILURE_RATE_PCT", 30),
+			FailureWindow:     l.duration("ALERT_FAILURE_RATE_WINDOW", 15*time.Minute),
+			FailureMinCharges: l.count("ALERT_FAILURE_RATE_MIN_CHARGES", 20),
+			Cooldown:          l.duration("ALERT_COOLDOWN", 30*time.Minute),
+		},
+		Backpressure: BackpressureConfig{
+			MaxWebhooks:   l.count("BACKPRESSURE_MAX_WEBHOOKS", 200),
+			MaxBackground: l.count("BACKPRESSURE_MAX_BACKGROUND", 500),
+			ShedCharges:   l.boolean("BACKPRESSURE_SHED_CHARGES", false),
+			RetryAfter:    l.duration("BACKPRESSURE_RETRY_AFTER", 5*time.Second),
+		},
+		Cache: CacheConfig{
+			RedisURL: l.str("REDIS_URL", ""),
+			TTL:      l.duration("TRANSACTION_CACHE_TTL", 30*time.Second),
+			Timeout:  l.duration("REDIS_TIMEOUT", 500*time.Millisecond),
+		},
+		Jobs: JobsConfig{
+			ExpirePending:         l.schedule("JOB_EXPIRE_PENDING_SCHEDULE", "*/15 * * * *"),
+			PendingTTL:            l.duration("PENDING_CHARGE_TTL", 24*time.Hour),
+			Reconcile:             l.schedule("JOB_RECONCILE_SCHEDULE", "*/10 * * * *"),
+			ReconcileAfter:        l.duration("RECONCILE_AFTER", 10*time.Minute),
+			FullReconcile:         l.schedule("JOB_FULL_RECONCILE_SCHEDULE", "0 3 * * *"),
+			FullReconcileHeal:     l.boolean("FULL_RECONCILE_HEAL", false),
+			PayoutStatements:      l.schedule("JOB_PAYOUT_STATEMENTS_SCHEDULE", "0 2 1 * *"),
+			PruneRawPayloads:      l.schedule("JOB_PRUNE_RAW_PAYLOADS_SCHEDULE", "30 4 * * *"),
+			RawPayloadRetention:   l.duration("RAW_PAYLOAD_RETENTION", 90*24*time.Hour),
+			LeaderLease:           l.duration("JOB_LEADER_LEASE", 30*time.Second),
+			WarehouseExport:       l.schedule("JOB_WAREHOUSE_EXPORT_SCHEDULE", "30 3 * * *"),
+			TransactionRollup:     l.schedule("JOB_TRANSACTION_ROLLUP_SCHEDULE", "10 * * * *"),
+			RollupLookbackDays:    l.count("TRANSACTION_ROLLUP_LOOKBACK_DAYS", 3),
+			LineQRReminders:       l.schedule("JOB_LINE_QR_REMINDERS_SCHEDULE", "*/5 * * * *"),
+			LineQRReminderLead:    l.duration("LINE_QR_REMINDER_LEAD", 15*time.Minute),
+			ChargeFailureRate:     l.schedule("JOB_CHARGE_FAILURE_RATE_SCHEDULE", "*/5 * * * *"),
+			ChargeFees:            l.schedule("JOB_CHARGE_FEES_SCHEDULE", "20 * * * *"),
+			Transfers:             l.schedule("JOB_TRANSFERS_SCHEDULE", "0 5 * * *"),
+			TransactionPartitions: l.schedule("JOB_TRANSACTION_PARTITIONS_SCHEDULE", "0 1 * * *"),
+			ReportSubscriptions:   l.schedule("JOB_REPORT_SUBSCRIPTIONS_SCHEDULE", "* * * * *"),
+			ConsistencyChecks:     l.schedule("JOB_CONSISTENCY_CHECKS_SCHEDULE", "0 3 * * *"),
+		},
+		Warehouse: WarehouseConfig{
+			Bucket:    l.str("WAREHOUSE_BUCKET", ""),
+			Endpoint:  l.str("WAREHOUSE_ENDPOINT", "s3.amazonaws.com"),
+			Region:    l.str("WAREHOUSE_REGION", ""),
+			AccessKey: l.str("WAREHOUSE_ACCESS_KEY", ""),
+			SecretKey: l.str("WAREHOUSE_SECRET_KEY", ""),
+			Insecure:  l.boolean("WAREHOUSE_INSECURE", false),
+			Format:    l.str("WAREHOUSE_FORMAT", warehouse.FormatCSVGzip),
+			Prefix:    l.str("WAREHOUSE_PREFIX", "transactions/"),
+		},
+		PayloadArchive: PayloadArchiveConfig{
+			Bucket:    l.str("RAW_PAYLOAD_ARCHIVE_BUCKET", ""),
+			Endpoint:  l.str("RAW_PAYLOAD_ARCHIVE_ENDPOINT", "s3.amazonaws.com"),
+			Region:    l.str("RAW_PAYLOAD_ARCHIVE_REGION", ""),
+			AccessKey: l.str("RAW_PAYLOAD_ARCHIVE_ACCESS_KEY", ""),
+			SecretKey: l.str("RAW_PAYLOAD_ARCHIVE_SECRET_KEY", ""),
+			Insecure:  l.boolean("RAW_PAYLOAD_ARCHIVE_INSECURE", false),
+			Prefix:    l.str("RAW_PAYLOAD_ARCHIVE_PREFIX", "raw-payloads/"),
+		},
+		Features: Features{
+			AllowRawCard:   l.boolean("ALLOW_RAW_CARD", false),
+			MockOmise:      mockOmise,
+			MigrateOnStart: l.boolean("MIGRATE_ON_START", false),
+		},
+		Timeouts: Timeouts{
+			HTTPRead:     l.duration("HTTP_READ_TIMEOUT", 15*time.Second),
+			HTTPWrite:    l.duration("HTTP_WRITE_TIMEOUT", 15*time.Second),
+			HTTPIdle:     l.duration("HTTP_IDLE_TIMEOUT", 60*time.Second),
+			OmiseRequest: l.duration("OMISE_TIMEOUT", 30*time.Second),
+			Request:      l.duration("REQUEST_TIMEOUT", 20*time.Second),
+			AdminRequest: l.duration("ADMIN_REQUEST_TIMEOUT", 5*time.Minute),
+			Background:   l.duration("BACKGROUND_TIMEOUT", time.Minute),
+			Shutdown:     l.duration("SHUTDOWN_TIMEOUT", 25*time.Second),
+		},
+	}
+
+	if n, err := strconv.Atoi(cfg.Port); err != nil || n <= 0 || n > 65535 {
+		l.fail("PORT: %q is not a valid port", cfg.Port)
+	}
+	if cfg.Cache.RedisURL != "" {
+		if _, err := cache.NewRedis(cfg.Cache.RedisURL, cfg.Cache.Timeout); err != nil {
+			l.fail("REDIS_URL: %v", err)
+		}
+	}
+	if _, err := fx.New(cfg.FXProvider, cfg.WalletFXRates, cfg.FXRatesURL, time.Second); err != nil {
+		l.fail("FX_PROVIDER: %v", err)
+	}
+	if _, err := risk.New(cfg.RiskEvaluator); err != nil {
+		l.fail("RISK_EVALUATOR: %v", err)
+	}
+	if cfg.VATRatePct > 100 {
+		l.fail("VAT_RATE_PCT: %v is over 100", cfg.VATRatePct)
+	}
+	if u, err := url.Parse(cfg.PaymentLinkBaseURL); cfg.PaymentLinkBaseURL != "" && (err != nil || u.Scheme == "" || u.Host == "") {
+		l.fail("PAYMENT_LINK_BASE_URL: %q is not an absolute URL", cfg.PaymentLinkBaseURL)
+	}
+	if cfg.PageSizeDefault == 0 || cfg.PageSizeMax == 0 {
+		l.fail("PAGE_SIZE_DEFAULT and PAGE_SIZE_MAX must be positive")
+	} else if cfg.PageSizeDefault > cfg.PageSizeMax {
+		l.fail("PAGE_SIZE_DEFAULT: %d is over PAGE_SIZE_MAX (%d)", cfg.PageSizeDefault, cfg.PageSizeMax)
+	}
+	if cfg.Alerts.FailureRatePct > 100 {
+		l.fail("ALERT_FAILURE_RATE_PCT: %v is over 100", cfg.Alerts.FailureRatePct)
+	}
+	if cfg.Payouts.ReservePct > 100 {
+		l.fail("PAYOUT_RESERVE_PCT: %v is over 100", cfg.Payouts.ReservePct)
+	}
+	if cfg.GRPCListenAddr != "" && cfg.GRPCAuthToken == "" {
+		l.fail("GRPC_AUTH_TOKEN: required when GRPC_LISTEN_ADDR is set")
+	}
+	if cfg.Omise.RetryAttempts == 0 {
+		l.fail("OMISE_RETRY_ATTEMPTS: must be at least 1 (1 disables retries)")
+	}
+	if cfg.Jobs.ReconcileAfter >= cfg.Jobs.PendingTTL {
+		l.fail("RECONCILE_AFTER: %s must be shorter than PENDING_CHARGE_TTL (%s)", cfg.Jobs.ReconcileAfter, cfg.Jobs.PendingTTL)
+	}
+	for _, m := range cfg.Merchants {
+		for _, ep := range cfg.WebhookEndpoints {
+			if ep.Name == m.Name {
+				l.fail("WEBHOOK_ENDPOINTS: %q is also a merchant, whose webhooks it already receives", ep.Name)
+			}
+		}
+	}
+	if !warehouse.ValidFormat(cfg.Warehouse.Format) {
+		l.fail("WAREHOUSE_FORMAT: %q is not csv or csv.gz", cfg.Warehouse.Format)
+	}
+	if cfg.Warehouse.Bucket != "" && (cfg.Warehouse.AccessKey == "" || cfg.Warehouse.SecretKey == "") {
+		l.fail("WAREHOUSE_ACCESS_KEY, WAREHOUSE_SECRET_KEY: required when WAREHOUSE_BUCKET is set")
+	}
+	if cfg.PayloadArchive.Bucket != "" && (cfg.PayloadArchive.AccessKey == "" || cfg.PayloadArchive.SecretKey == "") {
+		l.fail("RAW_PAYLOAD_ARCHIVE_ACCESS_KEY, RAW_PAYLOAD_ARCHIVE_SECRET_KEY: required when RAW_PAYLOAD_ARCHIVE_BUCKET is set")
+	}
+	if cfg.SMTP.Host != "" && cfg.SMTP.From == "" {
+		l.fail("SMTP_FROM: required when SMTP_HOST is set")
+	}
+
+	if len(l.errs) > 0 {
+		return nil, fmt.Errorf("invalid configuration: %w", errors.Join(l.errs...))
+	}
+	return cfg, nil
+}
+
+// LoadDB reads only the database settings, for commands (migrate) that need nothing else.
+func LoadDB() (DBConfig, error) {
+	l := &loader{}
+	db := l.db()
+	if len(l.errs) > 0 {
+		return DBConfig{}, fmt.Errorf("invalid configuration: %w", errors.Join(l.errs...))
+	}
+	return db, nil
+}
+
+func (l *loader) db() DBConfig {
+	c := DBConfig{
+		Host:            l.str("DB_HOST", "localhost"),
+		Port:            l.str("DB_PORT", "5432"),
+		User:            l.str("DB_USER", "postgres"),
+		Password:        l.str("DB_PASSWORD", ""),
+		Name:            l.str("DB_NAME", "postgres"),
+		SSLMode:         l.str("DB_SSLMODE", "disable"),
+		ReplicaHost:     l.str("DB_REPLICA_HOST", ""),
+		MaxOpenConns:    l.count("DB_MAX_OPEN_CONNS", 25),
+		MaxIdleConns:    l.count("DB_MAX_IDLE_CONNS", 10),
+		ConnMaxLifetime: l.duration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+		ConnMaxIdleTime: l.duration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+	}
+	c.ReplicaPort = l.str("DB_REPLICA_PORT", c.Port)
+	if c.MaxOpenConns > 0 && c.MaxIdleConns > c.MaxOpenConns {
+		l.fail("DB_MAX_IDLE_CONNS: %d is more than DB_MAX_OPEN_CONNS (%d)", c.MaxIdleConns, c.MaxOpenConns)
+	}
+	return c
+}
+
+// ListenAddr is the address passed to app.Listen.
+func (c *Config) ListenAddr() string { return ":" + c.Port }
+
+// loader reads typed values and collects errors so Load can report them all together.
+type loader struct {
+	errs []error
+}
+
+func (l *loader) fail(format string, args ...any) {
+	l.errs = append(l.errs, fmt.Errorf(format, args...))
+}
+
+func (l *loader) str(key, def string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return def
+}
+
+func (l *loader) required(key string) string {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		l.fail("%s: required", key)
+	}
+	return v
+}
+
+func (l *loader) boolean(key string, def bool) bool {
+	v := l.str(key, "")
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		l.fail("%s: %q is not a boolean", key, v)
+		return def
+	}
+	return b
+}
+
+func (l *loader) float(key string, def float64) float64 {
+	v := l.str(key, "")
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 {
+		l.fail("%s: %q is not a non-negative number", key, v)
+		return def
+	}
+	return f
+}
+
+func (l *loader) count(key string, def int) int {
+	v := l.str(key, "")
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		l.fail("%s: %q is not a non-negative integer", key, v)
+		return def
+	}
+	return n
+}
+
+func (l *loader) ints(key string, def []int) []int {
+	raw := l.list(key, nil)
+	if raw == nil {
+		return def
+	}
+	out := make([]int, 0, len(raw))
+	for _, v := range raw {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			l.fail("%s: %q is not a positive integer", key, v)
+			return def
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+func (l *loader) duration(key string, def time.Duration) time.Duration {
+	v := l.str(key, "")
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		l.fail("%s: %q is not a positive duration", key, v)
+		return def
+	}
+	return d
+}
+
+// schedule is a cron expression (validated here, parsed again by the scheduler); "off" returns "".
+func (l *loader) schedule(key, def string) string {
+	v := l.str(key, def)
+	if strings.EqualFold(v, "off") {
+		return ""
+	}
+	if _, err := jobs.ParseCron(v, time.UTC); err != nil {
+		l.fail("%s: %v", key, err)
+		return ""
+	}
+	return v
+}
+
+// configName is a name used in URL paths and env keys (webhook endpoints, API consumers).
+var configName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// envPrefix is the env key prefix for a named setting, e.g. WEBHOOK_ENDPOINT_SCHOOL_A_ for "school-a".
+func envPrefix(kind, name string) string {
+	return kind + "_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+}
+
+func (l *loader) apiConsumers(key string) []APIConsumerConfig {
+	var out []APIConsumerConfig
+	seen, keys := map[string]bool{}, map[string]string{}
+	for _, name := range l.list(key, nil) {
+		if !configName.MatchString(name) {
+			l.fail("%s: %q is not a valid consumer name (lowercase letters, digits and -)", key, name)
+			continue
+		}
+		if seen[name] {
+			l.fail("%s: %q is listed twice", key, name)
+			continue
+		}
+		seen[name] = true
+		keyVar := envPrefix("API_CONSUMER", name) + "KEY"
+		apiKey := l.required(keyVar)
+		if other, dup := keys[apiKey]; dup && apiKey != "" {
+			l.fail("%s: same key as %s", keyVar, other)
+			continue
+		}
+		keys[apiKey] = name
+		out = append(out, APIConsumerConfig{Name: name, Key: apiKey})
+	}
+	return out
+}
+
+// merchantDefault names the account of the OMISE_* keys; it cannot be listed in MERCHANTS.
+const merchantDefault = "default"
+
+func (l *loader) merchants(key string, omiseKey func(string) string) []MerchantConfig {
+	var out []MerchantConfig
+	seen := map[string]bool{}
+	for _, name := range l.list(key, nil) {
+		if !configName.MatchString(name) || name == merchantDefault || name == "omise" {
+			l.fail("%s: %q is not a valid merchant name (lowercase letters, digits and -; not default or omise)", key, name)
+			continue
+		}
+		if seen[name] {
+			l.fail("%s: %q is listed twice", key, name)
+			continue
+		}
+		seen[name] = true
+		prefix := envPrefix("MERCHANT", name)
+		out = append(out, MerchantConfig{
+			Name:          name,
+			PublicKey:     omiseKey(prefix + "OMISE_PUBLIC_KEY"),
+			SecretKey:     omiseKey(prefix + "OMISE_SECRET_KEY"),
+			WebhookSecret: l.required(prefix + "WEBHOOK_SECRET"),
+		})
+	}
+	return out
+}
+
+func (l *loader) webhookEndpoints(key string) []WebhookEndpointConfig {
+	var out []WebhookEndpointConfig
+	seen := map[string]bool{}
+	for _, name := range l.list(key, nil) {
+		if !configName.MatchString(name) {
+			l.fail("%s: %q is not a valid endpoint name (lowercase letters, digits and -)", key, name)
+			continue
+		}
+		if seen[name] {
+			l.fail("%s: %q is listed twice", key, name)
+			continue
+		}
+		seen[name] = true
+		prefix := envPrefix("WEBHOOK_ENDPOINT", name)
+		out = append(out, WebhookEndpointConfig{
+			Name:          name,
+			Secret:        l.str(prefix+"SECRET", ""),
+			Events:        l.list(prefix+"EVENTS", nil),
+			InstitutionID: l.count(prefix+"INSTITUTION_ID", 0),
+		})
+	}
+	return out
+}
+
+func (l *loader) fxRates(key string) map[string]float64 {
+	out := map[string]float64{}
+	for _, pair := range l.list(key, nil) {
+		cur, rate, _ := strings.Cut(pair, ":")
+		cur = strings.ToLower(strings.TrimSpace(cur))
+		if !money.Supported(cur) || cur == money.THB {
+			l.fail("%s: %q is not a supported currency other than THB", key, cur)
+			continue
+		}
+		if _, dup := out[cur]; dup {
+			l.fail("%s: %q is listed twice", key, cur)
+			continue
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(rate), 64)
+		if err != nil || f <= 0 {
+			l.fail("%s: %q is not a positive rate for %s", key, rate, cur)
+			continue
+		}
+		out[cur] = f
+	}
+	return out
+}
+
+func (l *loader) countries(key string) []string {
+	var out []string
+	for _, c := range l.list(key, nil) {
+		code := strings.ToUpper(c)
+		if len(code) != 2 {
+			l.fail("%s: %q is not a two-letter country code", key, c)
+			continue
+		}
+		out = append(out, code)
+	}
+	return out
+}
+
+func (l *loader) list(key string, def []string) []string {
+	v := l.str(key, "")
+	if v == "" {
+		return def
+	}
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,260 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadDefaults(t *testing.T) {
+	t.Setenv("OMISE_PUBLIC_KEY", "pkey_test_1")
+	t.Setenv("OMISE_SECRET_KEY", "skey_test_1")
+	t.Setenv("PORT", "")
+	t.Setenv("CORS_ALLOWED_ORIGINS", "")
+	t.Setenv("HTTP_READ_TIMEOUT", "")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.ListenAddr() != ":8080" {
+		t.Errorf("ListenAddr = %q, want :8080", cfg.ListenAddr())
+	}
+	if len(cfg.CORSOrigins) != 1 || cfg.CORSOrigins[0] != "*" {
+		t.Errorf("CORSOrigins = %v, want [*]", cfg.CORSOrigins)
+	}
+	if cfg.Timeouts.HTTPRead != 15*time.Second {
+		t.Errorf("HTTPRead = %v, want 15s", cfg.Timeouts.HTTPRead)
+	}
+}
+
+func TestLoadReportsEveryError(t *testing.T) {
+	t.Setenv("OMISE_PUBLIC_KEY", "")
+	t.Setenv("OMISE_SECRET_KEY", "")
+	t.Setenv("PORT", "http")
+	t.Setenv("ALLOW_RAW_CARD", "maybe")
+	t.Setenv("OMISE_TIMEOUT", "-1s")
+	t.Setenv("PAGE_SIZE_DEFAULT", "500")
+
+	_, err := Load()
+	if err == nil {
+		t.Fatal("Load: want error")
+	}
+	for _, key := range []string{"OMISE_PUBLIC_KEY", "OMISE_SECRET_KEY", "PORT", "ALLOW_RAW_CARD", "OMISE_TIMEOUT", "PAGE_SIZE_DEFAULT"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("error %q does not mention %s", err, key)
+		}
+	}
+}
+
+func TestLoadMockOmiseNeedsNoKeys(t *testing.T) {
+	t.Setenv("MOCK_OMISE", "true")
+	t.Setenv("OMISE_PUBLIC_KEY", "")
+	t.Setenv("OMISE_SECRET_KEY", "")
+	t.Setenv("PORT", "9090")
+	t.Setenv("MOCK_OMISE_BASE_URL", "")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !cfg.Features.MockOmise {
+		t.Error("MockOmise = false, want true")
+	}
+	if cfg.Omise.MockBaseURL != "http://localhost:9090" {
+		t.Errorf("MockBaseURL = %q, want http://localhost:9090", cfg.Omise.MockBaseURL)
+	}
+}
+
+func TestLoadWebhookEndpoints(t *testing.T) {
+	t.Setenv("OMISE_PUBLIC_KEY", "pkey_test")
+	t.Setenv("OMISE_SECRET_KEY", "skey_test")
+	t.Setenv("WEBHOOK_ENDPOINTS", "school-a, refunds")
+	t.Setenv("WEBHOOK_ENDPOINT_SCHOOL_A_SECRET", "s3cret")
+	t.Setenv("WEBHOOK_ENDPOINT_SCHOOL_A_INSTITUTION_ID", "12")
+	t.Setenv("WEBHOOK_ENDPOINT_REFUNDS_EVENTS", "refund.*,charge.update")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := []WebhookEndpointConfig{
+		{Name: "school-a", Secret: "s3cret", InstitutionID: 12},
+		{Name: "refunds", Events: []string{"refund.*", "charge.update"}},
+	}
+	if !reflect.DeepEqual(cfg.WebhookEndpoints, want) {
+		t.Errorf("WebhookEndpoints = %+v, want %+v", cfg.WebhookEndpoints, want)
+	}
+
+	t.Setenv("WEBHOOK_ENDPOINTS", "School A")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "WEBHOOK_ENDPOINTS") {
+		t.Errorf("invalid endpoint name: err = %v, want WEBHOOK_ENDPOINTS error", err)
+	}
+}
+
+func TestLoadJobSchedules(t *testing.T) {
+	t.Setenv("OMISE_PUBLIC_KEY", "pkey_test")
+	t.Setenv("OMISE_SECRET_KEY", "skey_test")
+	t.Setenv("JOB_RECONCILE_SCHEDULE", "*/5 * * * *")
+	t.Setenv("JOB_PAYOUT_STATEMENTS_SCHEDULE", "off")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Jobs.Reconcile != "*/5 * * * *" || cfg.Jobs.PayoutStatements != "" || cfg.Jobs.ExpirePending != "*/15 * * * *" {
+		t.Errorf("Jobs = %+v, want reconcile every 5 minutes, statements off, expiry by default", cfg.Jobs)
+	}
+	if cfg.Jobs.LineQRReminders != "*/5 * * * *" || cfg.Jobs.LineQRReminderLead != 15*time.Minute || cfg.LineChannelToken != "" {
+		t.Errorf("LINE reminders = %q with lead %s", cfg.Jobs.LineQRReminders, cfg.Jobs.LineQRReminderLead)
+	}
+	if cfg.Jobs.TransactionRollup != "10 * * * *" || cfg.Jobs.RollupLookbackDays != 3 {
+		t.Errorf("rollup job = %q over %d days, want hourly over 3", cfg.Jobs.TransactionRollup, cfg.Jobs.RollupLookbackDays)
+	}
+
+	t.Setenv("JOB_PRUNE_RAW_PAYLOADS_SCHEDULE", "daily")
+	t.Setenv("RECONCILE_AFTER", "48h")
+	_, err = Load()
+	for _, key := range []string{"JOB_PRUNE_RAW_PAYLOADS_SCHEDULE", "RECONCILE_AFTER"} {
+		if err == nil || !strings.Contains(err.Error(), key) {
+			t.Errorf("err = %v, want it to mention %s", err, key)
+		}
+	}
+}
+
+func TestLoadAPIConsumers(t *testing.T) {
+	t.Setenv("OMISE_PUBLIC_KEY", "pkey_test")
+	t.Setenv("OMISE_SECRET_KEY", "skey_test")
+	t.Setenv("API_CONSUMERS", "booking, central-library")
+	t.Setenv("API_CONSUMER_BOOKING_KEY", "bk-1")
+	t.Setenv("API_CONSUMER_CENTRAL_LIBRARY_KEY", "lib-1")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := []APIConsumerConfig{{Name: "booking", Key: "bk-1"}, {Name: "central-library", Key: "lib-1"}}
+	if !reflect.DeepEqual(cfg.APIConsumers, want) {
+		t.Errorf("APIConsumers = %+v, want %+v", cfg.APIConsumers, want)
+	}
+
+	t.Setenv("API_CONSUMER_CENTRAL_LIBRARY_KEY", "bk-1")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "API_CONSUMER_CENTRAL_LIBRARY_KEY") {
+		t.Errorf("shared key: err = %v, want API_CONSUMER_CENTRAL_LIBRARY_KEY error", err)
+	}
+	t.Setenv("API_CONSUMER_CENTRAL_LIBRARY_KEY", "")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "API_CONSUMER_CENTRAL_LIBRARY_KEY") {
+		t.Errorf("missing key: err = %v, want API_CONSUMER_CENTRAL_LIBRARY_KEY error", err)
+	}
+}
+
+func TestLoadDBPool(t *testing.T) {
+	t.Setenv("OMISE_PUBLIC_KEY", "pkey_test")
+	t.Setenv("OMISE_SECRET_KEY", "skey_test")
+	t.Setenv("DB_PORT", "5432")
+	t.Setenv("DB_REPLICA_HOST", "")
+	t.Setenv("DB_MAX_OPEN_CONNS", "")
+	t.Setenv("DB_MAX_IDLE_CONNS", "")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.DB.MaxOpenConns != 25 || cfg.DB.MaxIdleConns != 10 || cfg.DB.ConnMaxLifetime != 30*time.Minute {
+		t.Errorf("pool = %d open, %d idle, %v lifetime; want 25, 10, 30m", cfg.DB.MaxOpenConns, cfg.DB.MaxIdleConns, cfg.DB.ConnMaxLifetime)
+	}
+	if dsn := cfg.DB.ReplicaDSN(); dsn != "" {
+		t.Errorf("ReplicaDSN = %q without DB_REPLICA_HOST, want empty", dsn)
+	}
+
+	t.Setenv("DB_REPLICA_HOST", "replica.internal")
+	if cfg, err = Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if dsn := cfg.DB.ReplicaDSN(); !strings.Contains(dsn, "host=replica.internal ") || !strings.Contains(dsn, "port=5432 ") {
+		t.Errorf("ReplicaDSN = %q, want the replica host on DB_PORT", dsn)
+	}
+
+	t.Setenv("DB_MAX_OPEN_CONNS", "5")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "DB_MAX_IDLE_CONNS") {
+		t.Errorf("idle above open: err = %v, want DB_MAX_IDLE_CONNS error", err)
+	}
+}
+
+func TestLoadMerchants(t *testing.T) {
+	t.Setenv("OMISE_PUBLIC_KEY", "pkey_test")
+	t.Setenv("OMISE_SECRET_KEY", "skey_test")
+	t.Setenv("MERCHANTS", "uni-partner")
+	t.Setenv("MERCHANT_UNI_PARTNER_OMISE_PUBLIC_KEY", "pkey_test_uni")
+	t.Setenv("MERCHANT_UNI_PARTNER_OMISE_SECRET_KEY", "skey_test_uni")
+	t.Setenv("MERCHANT_UNI_PARTNER_WEBHOOK_SECRET", "whsec")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := []MerchantConfig{{Name: "uni-partner", PublicKey: "pkey_test_uni", SecretKey: "skey_test_uni", WebhookSecret: "whsec"}}
+	if !reflect.DeepEqual(cfg.Merchants, want) {
+		t.Errorf("Merchants = %+v, want %+v", cfg.Merchants, want)
+	}
+
+	t.Setenv("WEBHOOK_ENDPOINTS", "uni-partner")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "WEBHOOK_ENDPOINTS") {
+		t.Errorf("endpoint named like a merchant: err = %v, want WEBHOOK_ENDPOINTS error", err)
+	}
+	t.Setenv("WEBHOOK_ENDPOINTS", "")
+	t.Setenv("MERCHANTS", "default")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "MERCHANTS") {
+		t.Errorf("merchant named default: err = %v, want MERCHANTS error", err)
+	}
+}
+
+func TestLoadWarehouse(t *testing.T) {
+	t.Setenv("OMISE_PUBLIC_KEY", "pkey_test")
+	t.Setenv("OMISE_SECRET_KEY", "skey_test")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Warehouse.Bucket != "" || cfg.Warehouse.Format != "csv.gz" || cfg.Jobs.WarehouseExport != "30 3 * * *" {
+		t.Errorf("defaults: %+v, schedule %q", cfg.Warehouse, cfg.Jobs.WarehouseExport)
+	}
+
+	t.Setenv("WAREHOUSE_BUCKET", "tutorium-dwh")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "WAREHOUSE_ACCESS_KEY") {
+		t.Errorf("bucket without keys: err = %v, want WAREHOUSE_ACCESS_KEY error", err)
+	}
+	t.Setenv("WAREHOUSE_ACCESS_KEY", "GOOG1E")
+	t.Setenv("WAREHOUSE_SECRET_KEY", "secret")
+	t.Setenv("WAREHOUSE_FORMAT", "parquet")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "WAREHOUSE_FORMAT") {
+		t.Errorf("format parquet: err = %v, want WAREHOUSE_FORMAT error", err)
+	}
+	t.Setenv("WAREHOUSE_FORMAT", "csv")
+	if _, err := Load(); err != nil {
+		t.Errorf("complete warehouse config: %v", err)
+	}
+}
+
+func TestLoadWalletFXRates(t *testing.T) {
+	t.Setenv("OMISE_PUBLIC_KEY", "pkey_test")
+	t.Setenv("OMISE_SECRET_KEY", "skey_test")
+	t.Setenv("WALLET_FX_RATES", "USD:36.5, sgd:27.1")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if want := map[string]float64{"usd": 36.5, "sgd": 27.1}; !reflect.DeepEqual(cfg.WalletFXRates, want) {
+		t.Errorf("WalletFXRates = %v, want %v", cfg.WalletFXRates, want)
+	}
+
+	for _, bad := range []string{"thb:1", "eur:38", "usd:0", "usd:36.5,usd:37"} {
+		t.Setenv("WALLET_FX_RATES", bad)
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), "WALLET_FX_RATES") {
+			t.Errorf("%q: err = %v, want WALLET_FX_RATES error", bad, err)
+		}
+	}
+}
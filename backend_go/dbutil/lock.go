@@ -0,0 +1,26 @@
+// lock.go serializes work across replicas with Postgres advisory locks. LockKey must be called inside
+// a DB transaction (Transaction, or a repository's WithTx): the lock lasts until it commits or rolls
+// back, whereas outside one it would be released as soon as taken and serialize nothing.
+
+package dbutil
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotInTransaction is returned by LockKey for a *gorm.DB that is not in a DB transaction.
+var ErrNotInTransaction = errors.New("dbutil: advisory lock needs a DB transaction")
+
+// LockKey takes a Postgres advisory lock on key for the rest of the DB transaction tx, waiting for any
+// other transaction (on any replica) that holds it; commit or rollback releases it. It serializes work
+// on something that may have no row to lock yet, such as a charge the webhook and the reconciliation
+// job both see for the first time. Keys share one namespace, so prefix them ("charge:chrg_...").
+// ErrNotInTransaction, taking no lock, when tx is not in a DB transaction.
+func LockKey(tx *gorm.DB, key string) error {
+	if _, ok := tx.Statement.ConnPool.(gorm.TxCommitter); !ok {
+		return ErrNotInTransaction
+	}
+	return tx.Exec("SELECT pg_advisory_xact_lock(hashtextextended(?, 0))", key).Error
+}
@@ -0,0 +1,32 @@
+package dbutil_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/repository/repotest"
+	"gorm.io/gorm"
+)
+
+func TestLockKeyNeedsTransaction(t *testing.T) {
+	db := repotest.NewDB()
+	if err := dbutil.LockKey(db, "charge:chrg_test_1"); !errors.Is(err, dbutil.ErrNotInTransaction) {
+		t.Fatalf("LockKey outside a transaction = %v, want ErrNotInTransaction", err)
+	}
+	if ran := repotest.Statements(db); len(ran) != 0 {
+		t.Fatalf("LockKey outside a transaction ran %q", ran)
+	}
+
+	err := dbutil.Transaction(db, "lock_test", func(tx *gorm.DB) error {
+		return dbutil.LockKey(tx.Session(&gorm.Session{}), "charge:chrg_test_1")
+	})
+	if err != nil {
+		t.Fatalf("LockKey in a transaction: %v", err)
+	}
+	ran := repotest.Statements(db)
+	if len(ran) != 3 || ran[0] != "BEGIN" || !strings.Contains(ran[1], "pg_advisory_xact_lock") || ran[2] != "COMMIT" {
+		t.Errorf("statements = %q, want the lock between BEGIN and COMMIT", ran)
+	}
+}
@@ -0,0 +1,51 @@
+package dbutil
+
+import (
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// ReplicaResolver names the dbresolver resolver that Replica selects.
+const ReplicaResolver = "replica"
+
+// Pool sizes a database/sql connection pool; zero values keep database/sql's defaults.
+type Pool struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// UsePool applies pool to db's connections. With a replicaDSN it also registers that database (with
+// its own pool of the same size) as the read replica Replica routes to; it is connected right away.
+func UsePool(db *gorm.DB, pool Pool, replicaDSN string) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	sqlDB.SetMaxOpenConns(pool.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(pool.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+	if replicaDSN == "" {
+		return nil
+	}
+	// A named resolver, not a global one: queries stay on the primary unless they ask for Replica.
+	return db.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: []gorm.Dialector{postgres.Open(replicaDSN)},
+	}, ReplicaResolver).
+		SetMaxOpenConns(pool.MaxOpenConns).
+		SetMaxIdleConns(pool.MaxIdleConns).
+		SetConnMaxLifetime(pool.ConnMaxLifetime).
+		SetConnMaxIdleTime(pool.ConnMaxIdleTime))
+}
+
+// Replica sends db's reads to the read replica registered by UsePool; without one, and inside a DB
+// transaction, they stay on the primary. Writes always go to the primary. Use it for lists and reports
+// that tolerate replication lag, never for a read that decides a write.
+func Replica(db *gorm.DB) *gorm.DB {
+	return db.Clauses(dbresolver.Use(ReplicaResolver))
+}
@@ -0,0 +1,124 @@
+// Package dbutil wraps GORM operations with bounded retries on transient Postgres errors.
+package dbutil
+
+import (
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// Retry policy: attempts include the first try; backoff doubles from BaseDelay.
+var (
+	MaxAttempts = 3
+	BaseDelay   = 50 * time.Millisecond
+)
+
+// IsTransient reports whether err is a transient database error worth retrying:
+// dropped/reset connections, serialization failures, deadlocks, and server restarts.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case pgErr.Code == "40001", // serialization_failure
+			pgErr.Code == "40P01",               // deadlock_detected
+			pgErr.Code == "57P01",               // admin_shutdown
+			pgErr.Code == "57P03",               // cannot_connect_now
+			strings.HasPrefix(pgErr.Code, "08"): // connection_exception class
+			return true
+		}
+		return false
+	}
+	if pgconn.SafeToRetry(err) || errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return strings.Contains(err.Error(), "connection reset by peer")
+}
+
+// IsUniqueViolation reports whether err is a unique constraint violation (e.g. a reused idempotency key).
+func IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// isSerializationFailure reports errors that guarantee the transaction was rolled back.
+func isSerializationFailure(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == "40001" || pgErr.Code == "40P01")
+}
+
+// Retry runs an idempotent operation (reads, upserts) up to MaxAttempts times while it fails transiently.
+func Retry(op string, fn func() error) error {
+	var err error
+	delay := BaseDelay
+	for attempt := 1; attempt <= MaxAttempts; attempt++ {
+		if err = fn(); err == nil || !IsTransient(err) {
+			return err
+		}
+		if attempt < MaxAttempts {
+			log.Printf("db: transient error op=%s attempt=%d err=%v (retrying)", op, attempt, err)
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	return err
+}
+
+// Transaction runs fn in a DB transaction and re-runs the whole transaction on transient errors.
+// A failed COMMIT is only retried for serialization failures/deadlocks: any other commit error is
+// ambiguous (the commit may have been applied), so it is returned to the caller as-is.
+func Transaction(db *gorm.DB, op string, fn func(tx *gorm.DB) error) error {
+	var err error
+	delay := BaseDelay
+	for attempt := 1; attempt <= MaxAttempts; attempt++ {
+		var retryable bool
+		err, retryable = runTransaction(db, fn)
+		if err == nil || !retryable {
+			return err
+		}
+		if attempt < MaxAttempts {
+			log.Printf("db: transient error op=%s attempt=%d err=%v (retrying transaction)", op, attempt, err)
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	return err
+}
+
+func runTransaction(db *gorm.DB, fn func(tx *gorm.DB) error) (err error, retryable bool) {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error, IsTransient(tx.Error)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err, IsTransient(err)
+	}
+	if err := tx.Commit().Error; err != nil {
+		return err, isSerializationFailure(err)
+	}
+	return nil, false
+}
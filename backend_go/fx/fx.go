@@ -0,0 +1,161 @@
+// Package fx provides exchange rates for showing payers an approximate price in their own currency.
+// Rates are indicative only: charges are made in their own currency, and wallets credited at
+// WALLET_FX_RATES, never at these.
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/cache"
+)
+
+// ErrUnsupported is returned by Provider.Rate for a currency pair the provider has no rate for.
+var ErrUnsupported = errors.New("fx: no rate for the currency pair")
+
+// Rate is how many units of To one unit of From buys (major units, e.g. USD per THB).
+type Rate struct {
+	From     string    `json:"from"` // lowercase ISO 4217, as every currency in the service
+	To       string    `json:"to"`
+	Rate     float64   `json:"rate"`
+	AsOf     time.Time `json:"as_of"` // when the provider published it; zero if it does not say
+	Provider string    `json:"provider"`
+}
+
+// Provider looks up exchange rates.
+type Provider interface {
+	Name() string
+	Rate(ctx context.Context, from, to string) (Rate, error)
+}
+
+// New returns the Provider for a provider name (FX_PROVIDER): "static" quotes thbPer (THB per unit of
+// each currency, WALLET_FX_RATES) and "http" fetches url, where "{base}" stands for the currency to
+// convert from. An empty name disables quotes.
+func New(provider string, thbPer map[string]float64, url string, timeout time.Duration) (Provider, error) {
+	switch provider {
+	case "":
+		return nil, nil
+	case "static":
+		return Static{THBPer: thbPer}, nil
+	case "http":
+		if !strings.Contains(url, "{base}") {
+			return nil, fmt.Errorf("fx: rates URL %q has no {base}", url)
+		}
+		return &HTTP{URL: url, Client: &http.Client{Timeout: timeout}}, nil
+	default:
+		return nil, fmt.Errorf("unknown fx provider %q", provider)
+	}
+}
+
+// Static quotes fixed rates between THB and the currencies of THBPer, and across them through THB.
+type Static struct {
+	THBPer map[string]float64 // THB per unit of each currency (lowercase); THB itself is implied
+}
+
+func (Static) Name() string { return "static" }
+
+func (s Static) Rate(_ context.Context, from, to string) (Rate, error) {
+	from, to = strings.ToLower(from), strings.ToLower(to)
+	f, okFrom := s.thb(from)
+	t, okTo := s.thb(to)
+	if !okFrom || !okTo {
+		return Rate{}, ErrUnsupported
+	}
+	return Rate{From: from, To: to, Rate: f / t, Provider: s.Name()}, nil
+}
+
+// (helper for Static.Rate) THB per unit of currency.
+func (s Static) thb(currency string) (float64, bool) {
+	if currency == "thb" {
+		return 1, true
+	}
+	rate, ok := s.THBPer[currency]
+	return rate, ok && rate > 0
+}
+
+// HTTP fetches rates from a JSON API answering {"rates": {"USD": 0.0274, ...}, "time_last_update_unix":
+// 1760572800} for the base currency in its URL, e.g. https://open.er-api.com/v6/latest/{base}.
+type HTTP struct {
+	URL    string
+	Client *http.Client
+}
+
+func (*HTTP) Name() string { return "http" }
+
+func (p *HTTP) Rate(ctx context.Context, from, to string) (Rate, error) {
+	from, to = strings.ToLower(from), strings.ToLower(to)
+	url := strings.ReplaceAll(p.URL, "{base}", strings.ToUpper(from))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Rate{}, err
+	}
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return Rate{}, fmt.Errorf("fx: fetch %s rates: %w", strings.ToUpper(from), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return Rate{}, ErrUnsupported
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Rate{}, fmt.Errorf("fx: fetch %s rates: HTTP %d", strings.ToUpper(from), resp.StatusCode)
+	}
+	var body struct {
+		Rates   map[string]float64 `json:"rates"`
+		Updated int64              `json:"time_last_update_unix"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return Rate{}, fmt.Errorf("fx: decode %s rates: %w", strings.ToUpper(from), err)
+	}
+	rate, ok := body.Rates[strings.ToUpper(to)]
+	if !ok || rate <= 0 {
+		return Rate{}, ErrUnsupported
+	}
+	out := Rate{From: from, To: to, Rate: rate, Provider: p.Name()}
+	if body.Updated > 0 {
+		out.AsOf = time.Unix(body.Updated, 0).UTC()
+	}
+	return out, nil
+}
+
+// cachedKey prefixes every key Cached writes.
+const cachedKey = "fx:rate:"
+
+// Cached serves a Provider's rates from Store for TTL. Cache failures are logged and fall through to
+// the provider.
+type Cached struct {
+	Provider Provider
+	Store    cache.Store
+	TTL      time.Duration
+}
+
+func (c *Cached) Name() string { return c.Provider.Name() }
+
+func (c *Cached) Rate(ctx context.Context, from, to string) (Rate, error) {
+	from, to = strings.ToLower(from), strings.ToLower(to)
+	key := cachedKey + from + ":" + to
+	if raw, err := c.Store.Get(ctx, key); err == nil {
+		var r Rate
+		if err := json.Unmarshal(raw, &r); err == nil {
+			return r, nil
+		}
+	} else if !errors.Is(err, cache.ErrMiss) {
+		log.Printf("fx: cache get %s: %v", key, err)
+	}
+	r, err := c.Provider.Rate(ctx, from, to)
+	if err != nil {
+		return Rate{}, err
+	}
+	if raw, err := json.Marshal(r); err == nil {
+		if err := c.Store.Set(ctx, key, raw, c.TTL); err != nil {
+			log.Printf("fx: cache set %s: %v", key, err)
+		}
+	}
+	return r, nil
+}
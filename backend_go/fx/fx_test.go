@@ -0,0 +1,64 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/cache"
+)
+
+func TestStaticRate(t *testing.T) {
+	p := Static{THBPer: map[string]float64{"usd": 36.5, "sgd": 27.1}}
+	r, err := p.Rate(context.Background(), "THB", "usd")
+	if err != nil || r.Rate != 1/36.5 || r.From != "thb" || r.To != "usd" {
+		t.Errorf("THB->USD = %+v, %v; want 1/36.5", r, err)
+	}
+	if r, err := p.Rate(context.Background(), "usd", "sgd"); err != nil || r.Rate != 36.5/27.1 {
+		t.Errorf("USD->SGD = %+v, %v; want through THB", r, err)
+	}
+	if _, err := p.Rate(context.Background(), "thb", "eur"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("THB->EUR err = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestHTTPRateCached(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path != "/latest/THB" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"result":"success","time_last_update_unix":1760572800,"rates":{"THB":1,"USD":0.0274}}`))
+	}))
+	defer srv.Close()
+
+	provider, err := New("http", nil, srv.URL+"/latest/{base}", time.Second)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	p := &Cached{Provider: provider, Store: cache.NewMemory(), TTL: time.Hour}
+	for i := 0; i < 2; i++ {
+		r, err := p.Rate(context.Background(), "thb", "USD")
+		if err != nil {
+			t.Fatalf("Rate: %v", err)
+		}
+		if r.Rate != 0.0274 || r.Provider != "http" || !r.AsOf.Equal(time.Unix(1760572800, 0)) {
+			t.Errorf("rate = %+v, want 0.0274 from http as of 1760572800", r)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("provider called %d times, want once (then cached)", n)
+	}
+	if _, err := p.Rate(context.Background(), "thb", "eur"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("THB->EUR err = %v, want ErrUnsupported", err)
+	}
+	if _, err := p.Rate(context.Background(), "usd", "thb"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("unknown base err = %v, want ErrUnsupported", err)
+	}
+}
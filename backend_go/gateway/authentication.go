@@ -0,0 +1,44 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/omise/omise-go/operations"
+)
+
+// Authentication3DS makes Omise send a card charge through 3-D Secure whatever the account's settings.
+const Authentication3DS = "3DS"
+
+type authenticationKey struct{}
+
+// WithAuthentication returns a ctx whose card charges ask Omise for authentication (Authentication3DS),
+// as omise-go's CreateCharge has no field for it.
+func WithAuthentication(ctx context.Context, authentication string) context.Context {
+	return context.WithValue(ctx, authenticationKey{}, authentication)
+}
+
+// AuthenticationFrom returns the authentication WithAuthentication set on ctx, "" if none.
+func AuthenticationFrom(ctx context.Context) string {
+	a, _ := ctx.Value(authenticationKey{}).(string)
+	return a
+}
+
+// authenticatedCharge is a CreateCharge sent with Omise's authentication parameter.
+type authenticatedCharge struct {
+	*operations.CreateCharge
+	authentication string
+}
+
+func (op authenticatedCharge) MarshalJSON() ([]byte, error) {
+	b, err := op.CreateCharge.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(b, &body); err != nil {
+		return nil, err
+	}
+	body["authentication"] = op.authentication
+	return json.Marshal(body)
+}
@@ -0,0 +1,267 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	omise "github.com/omise/omise-go"
+)
+
+// DefaultAPIVersion is the Omise API version omise-go v1.6.0 was written against. Changing
+// OMISE_API_VERSION away from it should be preceded by a compatibility report (see CompareSamples).
+const DefaultAPIVersion = "2019-05-29"
+
+// WithAPIVersion pins the Omise-Version header sent with every request, so a dashboard-side
+// account upgrade never changes the payloads we parse.
+func WithAPIVersion(c *omise.Client, version string) {
+	if version == "" {
+		version = DefaultAPIVersion
+	}
+	c.WithCustomHeaders(map[string]string{"Omise-Version": version})
+}
+
+// SampleDiff is how one sample payload maps onto our omise-go types.
+type SampleDiff struct {
+	File        string   `json:"file"`
+	Object      string   `json:"object"`
+	DecodeError string   `json:"decode_error,omitempty"` // the payload does not parse at all
+	Unknown     []string `json:"unknown,omitempty"`      // present in the payload, dropped by our types
+	Missing     []string `json:"missing,omitempty"`      // read by our types in the baseline sample, absent here
+	TypeChanged []string `json:"type_changed,omitempty"` // present in both with different JSON types
+}
+
+// Breaking reports differences that change what we read: decode failures, missing or retyped fields.
+func (d SampleDiff) Breaking() bool {
+	return d.DecodeError != "" || len(d.Missing) > 0 || len(d.TypeChanged) > 0
+}
+
+// CompatReport summarizes a directory of sample payloads for one API version.
+type CompatReport struct {
+	Version string       `json:"version"`
+	Samples []SampleDiff `json:"samples"`
+}
+
+// Breaking is true when any sample has a breaking difference.
+func (r CompatReport) Breaking() bool {
+	for _, s := range r.Samples {
+		if s.Breaking() {
+			return true
+		}
+	}
+	return false
+}
+
+// String renders the report for logs.
+func (r CompatReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Omise API %s compatibility (%d samples)\n", r.Version, len(r.Samples))
+	for _, s := range r.Samples {
+		status := "ok"
+		if s.Breaking() {
+			status = "BREAKING"
+		} else if len(s.Unknown) > 0 {
+			status = "new fields"
+		}
+		fmt.Fprintf(&b, "  %s (%s): %s\n", s.File, s.Object, status)
+		if s.DecodeError != "" {
+			fmt.Fprintf(&b, "    decode error: %s\n", s.DecodeError)
+		}
+		for _, f := range s.Missing {
+			fmt.Fprintf(&b, "    missing: %s\n", f)
+		}
+		for _, f := range s.TypeChanged {
+			fmt.Fprintf(&b, "    type changed: %s\n", f)
+		}
+		for _, f := range s.Unknown {
+			fmt.Fprintf(&b, "    unknown (ignored): %s\n", f)
+		}
+	}
+	return b.String()
+}
+
+// CompareSamples decodes every *.json payload in dir (one Omise object per file, as returned by the
+// API version under test) into the omise-go type for its "object" and reports what our parsing
+// drops or reads with the wrong type. When baselineDir holds same-named samples from the pinned
+// version, fields we used to read that disappeared are reported as missing.
+func CompareSamples(version, dir, baselineDir string) (CompatReport, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return CompatReport{}, err
+	}
+	if len(files) == 0 {
+		return CompatReport{}, fmt.Errorf("no *.json samples in %s", dir)
+	}
+	sort.Strings(files)
+
+	report := CompatReport{Version: version}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return report, err
+		}
+		diff, _ := compareSample(filepath.Base(f), data)
+		if baselineDir != "" {
+			if base, err := os.ReadFile(filepath.Join(baselineDir, filepath.Base(f))); err == nil {
+				if _, known := compareSample(filepath.Base(f), base); known != nil {
+					diff.Missing = missingPaths(known, data)
+				}
+			}
+		}
+		report.Samples = append(report.Samples, diff)
+	}
+	return report, nil
+}
+
+// (helper for CompareSamples) known field paths of the baseline absent from the candidate payload.
+func missingPaths(known map[string]bool, candidate []byte) []string {
+	var raw map[string]interface{}
+	if json.Unmarshal(candidate, &raw) != nil {
+		return nil
+	}
+	have := map[string]bool{}
+	collectPaths("", raw, have)
+	var out []string
+	for p := range known {
+		if !have[p] {
+			out = append(out, p)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func collectPaths(path string, v interface{}, into map[string]bool) {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, e := range x {
+			p := joinPath(path, k)
+			into[p] = true
+			collectPaths(p, e, into)
+		}
+	case []interface{}:
+		if len(x) > 0 {
+			collectPaths(path+"[]", x[0], into)
+		}
+	}
+}
+
+// (helper for CompareSamples) decode into the typed object, re-encode, and diff the two JSON trees.
+// Also returns the field paths present in both, i.e. the ones our types actually read.
+func compareSample(file string, data []byte) (SampleDiff, map[string]bool) {
+	d := SampleDiff{File: file}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		d.DecodeError = err.Error()
+		return d, nil
+	}
+	d.Object, _ = raw["object"].(string)
+
+	typed := typedObject(d.Object)
+	if typed == nil {
+		d.DecodeError = fmt.Sprintf("no omise-go type for object %q", d.Object)
+		return d, nil
+	}
+	if err := json.Unmarshal(data, typed); err != nil {
+		d.DecodeError = err.Error()
+		return d, nil
+	}
+	reencoded, err := json.Marshal(typed)
+	if err != nil {
+		d.DecodeError = err.Error()
+		return d, nil
+	}
+	var ours map[string]interface{}
+	_ = json.Unmarshal(reencoded, &ours)
+
+	known := map[string]bool{}
+	diffJSON("", raw, ours, &d, known)
+	sort.Strings(d.Unknown)
+	sort.Strings(d.TypeChanged)
+	return d, known
+}
+
+func typedObject(object string) interface{} {
+	switch object {
+	case "charge":
+		return &omise.Charge{}
+	case "event":
+		return &omise.Event{}
+	case "refund":
+		return &omise.Refund{}
+	case "customer":
+		return &omise.Customer{}
+	case "source":
+		return &omise.Source{}
+	case "token":
+		return &omise.Token{}
+	case "account":
+		return &omise.Account{}
+	case "card":
+		return &omise.Card{}
+	}
+	return nil
+}
+
+// (helper for compareSample) walk both trees; lists are compared through their first element.
+func diffJSON(path string, theirs, ours map[string]interface{}, d *SampleDiff, known map[string]bool) {
+	for k, tv := range theirs {
+		p := joinPath(path, k)
+		ov, ok := ours[k]
+		if !ok {
+			d.Unknown = append(d.Unknown, p)
+			continue
+		}
+		known[p] = true
+		diffValue(p, tv, ov, d, known)
+	}
+}
+
+func diffValue(path string, tv, ov interface{}, d *SampleDiff, known map[string]bool) {
+	if tv == nil || ov == nil {
+		return // null on either side carries no type information
+	}
+	tm, tIsMap := tv.(map[string]interface{})
+	om, oIsMap := ov.(map[string]interface{})
+	if tIsMap && oIsMap {
+		diffJSON(path, tm, om, d, known)
+		return
+	}
+	ta, tIsArr := tv.([]interface{})
+	oa, oIsArr := ov.([]interface{})
+	if tIsArr && oIsArr {
+		if len(ta) > 0 && len(oa) > 0 {
+			diffValue(path+"[]", ta[0], oa[0], d, known)
+		}
+		return
+	}
+	if jsonKind(tv) != jsonKind(ov) {
+		d.TypeChanged = append(d.TypeChanged, fmt.Sprintf("%s (%s -> %s)", path, jsonKind(tv), jsonKind(ov)))
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func jsonKind(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "bool"
+	}
+	return "null"
+}
@@ -0,0 +1,84 @@
+package gateway
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCompareSamplesPinnedVersion checks our parsing against the samples of the pinned API version.
+func TestCompareSamplesPinnedVersion(t *testing.T) {
+	dir := filepath.Join("testdata", "omise", DefaultAPIVersion)
+	report, err := CompareSamples(DefaultAPIVersion, dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(report)
+	if report.Breaking() {
+		t.Fatalf("pinned version samples no longer parse cleanly:\n%s", report)
+	}
+}
+
+// TestCompareSamplesUpgrade is the upgrade compatibility mode: drop the new version's sample payloads
+// into a directory and run
+//
+//	OMISE_COMPAT_VERSION=2024-01-01 OMISE_COMPAT_DIR=/path/to/samples go test ./gateway -run Upgrade -v
+//
+// The report of differences against the pinned version is logged; breaking ones fail the test.
+func TestCompareSamplesUpgrade(t *testing.T) {
+	version, dir := os.Getenv("OMISE_COMPAT_VERSION"), os.Getenv("OMISE_COMPAT_DIR")
+	if version == "" || dir == "" {
+		t.Skip("set OMISE_COMPAT_VERSION and OMISE_COMPAT_DIR to compare a new API version")
+	}
+	report, err := CompareSamples(version, dir, filepath.Join("testdata", "omise", DefaultAPIVersion))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(report)
+	if report.Breaking() {
+		t.Errorf("API version %s has breaking differences", version)
+	}
+}
+
+func TestCompareSamplesReportsDifferences(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("charge.json", `{"object":"charge","id":"chrg_1","amount":"100","new_field":true}`)
+
+	base := t.TempDir()
+	if err := os.WriteFile(filepath.Join(base, "charge.json"), []byte(`{"object":"charge","id":"chrg_1","amount":100,"currency":"thb"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := CompareSamples("next", dir, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Samples) != 1 {
+		t.Fatalf("samples = %d, want 1", len(report.Samples))
+	}
+	s := report.Samples[0]
+	if s.DecodeError == "" {
+		t.Errorf("string amount should fail to decode into int64, got %+v", s)
+	}
+	if !report.Breaking() {
+		t.Error("report should be breaking")
+	}
+
+	write("charge.json", `{"object":"charge","id":"chrg_1","amount":100,"new_field":true}`)
+	report, err = CompareSamples("next", dir, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = report.Samples[0]
+	if len(s.Unknown) != 1 || s.Unknown[0] != "new_field" {
+		t.Errorf("unknown = %v, want [new_field]", s.Unknown)
+	}
+	if len(s.Missing) != 1 || s.Missing[0] != "currency" {
+		t.Errorf("missing = %v, want [currency]", s.Missing)
+	}
+}
@@ -0,0 +1,204 @@
+// Package gateway puts the Omise API behind the OmiseGateway interface so handlers can be tested
+// without the network (see gatewaytest for a fake and an httptest-based Omise stub).
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	omise "github.com/omise/omise-go"
+	"github.com/omise/omise-go/operations"
+)
+
+// OmiseGateway is every Omise call the service makes. Errors are returned as omise-go returns them
+// (*omise.Error for API errors), so callers can keep inspecting StatusCode and Code. ctx bounds the
+// call, including its retries: a request whose caller gave up is cancelled, not left to finish.
+type OmiseGateway interface {
+	CreateToken(ctx context.Context, op *operations.CreateToken) (*omise.Token, error)
+	RetrieveToken(ctx context.Context, tokenID string) (*omise.Token, error)
+	CreateSource(ctx context.Context, op *operations.CreateSource) (*omise.Source, error)
+	CreateCharge(ctx context.Context, op *operations.CreateCharge) (*omise.Charge, error)
+	RetrieveCharge(ctx context.Context, chargeID string) (*omise.Charge, error)
+	RetrieveChargeFees(ctx context.Context, chargeID string) (*ChargeFees, error)
+	ExpireCharge(ctx context.Context, chargeID string) (*omise.Charge, error)
+	ListCharges(ctx context.Context, op *operations.ListCharges) (*omise.ChargeList, error)
+	RetrieveTransaction(ctx context.Context, transactionID string) (*omise.Transaction, error)
+	ListTransfers(ctx context.Context, op *operations.ListTransfers) (*omise.TransferList, error)
+	RetrieveEvent(ctx context.Context, eventID string) (*omise.Event, error)
+	CreateRefund(ctx context.Context, op *operations.CreateRefund) (*omise.Refund, error)
+	CreateCustomer(ctx context.Context, op *operations.CreateCustomer) (*omise.Customer, error)
+	UpdateCustomer(ctx context.Context, op *operations.UpdateCustomer) (*omise.Customer, error)
+	RetrieveAccount(ctx context.Context) (*omise.Account, error)
+}
+
+// ChargeFees is what Omise keeps of a charge: its fee, the VAT on the fee, and the net amount paid out
+// (amount - fee - fee_vat), all in the charge's minor units. omise-go's Charge drops these fields.
+// Transaction is the balance transaction crediting Net, which says when it can be transferred.
+type ChargeFees struct {
+	Fee         int64  `json:"fee"`
+	FeeVAT      int64  `json:"fee_vat"`
+	Net         int64  `json:"net"`
+	Transaction string `json:"transaction"`
+}
+
+// Client implements OmiseGateway with the omise-go client. Every call goes through the Resilience
+// policy (none by default; see WithResilience).
+type Client struct {
+	c          *omise.Client
+	resilience Resilience
+	breaker    breaker
+}
+
+var _ OmiseGateway = (*Client)(nil)
+
+// New wraps an omise-go client.
+func New(c *omise.Client) *Client {
+	return &Client{c: c}
+}
+
+// WithResilience sets the retry and circuit breaker policy; call it before the client is shared.
+func (g *Client) WithResilience(r Resilience) *Client {
+	g.resilience = r
+	return g
+}
+
+func (g *Client) CreateToken(ctx context.Context, op *operations.CreateToken) (*omise.Token, error) {
+	out := &omise.Token{}
+	return result(out, g.call(ctx, "CreateToken", true, func() error { return g.do(ctx, out, request(g.c.Request(op))) }))
+}
+
+func (g *Client) RetrieveToken(ctx context.Context, tokenID string) (*omise.Token, error) {
+	out, op := &omise.Token{}, &operations.RetrieveToken{ID: tokenID}
+	return result(out, g.call(ctx, "RetrieveToken", true, func() error { return g.do(ctx, out, request(g.c.Request(op))) }))
+}
+
+func (g *Client) CreateSource(ctx context.Context, op *operations.CreateSource) (*omise.Source, error) {
+	out := &omise.Source{}
+	return result(out, g.call(ctx, "CreateSource", true, func() error { return g.do(ctx, out, request(g.c.Request(op))) }))
+}
+
+// CreateCharge creates the charge, with the authentication WithAuthentication set on ctx for a card.
+func (g *Client) CreateCharge(ctx context.Context, op *operations.CreateCharge) (*omise.Charge, error) {
+	out, auth := &omise.Charge{}, AuthenticationFrom(ctx)
+	return result(out, g.call(ctx, "CreateCharge", false, func() error {
+		if auth != "" && op.Card != "" {
+			return g.do(ctx, out, request(g.c.Request(authenticatedCharge{op, auth})))
+		}
+		return g.do(ctx, out, request(g.c.Request(op)))
+	}))
+}
+
+func (g *Client) RetrieveCharge(ctx context.Context, chargeID string) (*omise.Charge, error) {
+	out, op := &omise.Charge{}, &operations.RetrieveCharge{ChargeID: chargeID}
+	return result(out, g.call(ctx, "RetrieveCharge", true, func() error { return g.do(ctx, out, request(g.c.Request(op))) }))
+}
+
+// RetrieveChargeFees reads the charge like RetrieveCharge, keeping only its fees.
+func (g *Client) RetrieveChargeFees(ctx context.Context, chargeID string) (*ChargeFees, error) {
+	out, op := &ChargeFees{}, &operations.RetrieveCharge{ChargeID: chargeID}
+	return result(out, g.call(ctx, "RetrieveChargeFees", true, func() error { return g.do(ctx, out, request(g.c.Request(op))) }))
+}
+
+// ExpireCharge expires a pending charge so it can no longer be paid (Omise supports it for source
+// charges such as PromptPay). omise-go has no operation for POST /charges/:id/expire, so the request is
+// RetrieveCharge's, re-aimed.
+func (g *Client) ExpireCharge(ctx context.Context, chargeID string) (*omise.Charge, error) {
+	out, op := &omise.Charge{}, &operations.RetrieveCharge{ChargeID: chargeID}
+	return result(out, g.call(ctx, "ExpireCharge", false, func() error {
+		req, err := g.c.Request(op)
+		if err == nil {
+			req.Method = http.MethodPost
+			req.URL.Path += "/expire"
+		}
+		return g.do(ctx, out, request(req, err))
+	}))
+}
+
+func (g *Client) ListCharges(ctx context.Context, op *operations.ListCharges) (*omise.ChargeList, error) {
+	out := &omise.ChargeList{}
+	return result(out, g.call(ctx, "ListCharges", true, func() error { return g.do(ctx, out, request(g.c.Request(op))) }))
+}
+
+func (g *Client) RetrieveTransaction(ctx context.Context, transactionID string) (*omise.Transaction, error) {
+	out, op := &omise.Transaction{}, &operations.RetrieveTransaction{TransactionID: transactionID}
+	return result(out, g.call(ctx, "RetrieveTransaction", true, func() error { return g.do(ctx, out, request(g.c.Request(op))) }))
+}
+
+func (g *Client) ListTransfers(ctx context.Context, op *operations.ListTransfers) (*omise.TransferList, error) {
+	out := &omise.TransferList{}
+	return result(out, g.call(ctx, "ListTransfers", true, func() error { return g.do(ctx, out, request(g.c.Request(op))) }))
+}
+
+func (g *Client) RetrieveEvent(ctx context.Context, eventID string) (*omise.Event, error) {
+	out, op := &omise.Event{}, &operations.RetrieveEvent{EventID: eventID}
+	return result(out, g.call(ctx, "RetrieveEvent", true, func() error { return g.do(ctx, out, request(g.c.Request(op))) }))
+}
+
+func (g *Client) CreateRefund(ctx context.Context, op *operations.CreateRefund) (*omise.Refund, error) {
+	out := &omise.Refund{}
+	return result(out, g.call(ctx, "CreateRefund", false, func() error { return g.do(ctx, out, request(g.c.Request(op))) }))
+}
+
+func (g *Client) CreateCustomer(ctx context.Context, op *operations.CreateCustomer) (*omise.Customer, error) {
+	out := &omise.Customer{}
+	return result(out, g.call(ctx, "CreateCustomer", false, func() error { return g.do(ctx, out, request(g.c.Request(op))) }))
+}
+
+func (g *Client) UpdateCustomer(ctx context.Context, op *operations.UpdateCustomer) (*omise.Customer, error) {
+	out := &omise.Customer{}
+	return result(out, g.call(ctx, "UpdateCustomer", false, func() error { return g.do(ctx, out, request(g.c.Request(op))) }))
+}
+
+func (g *Client) RetrieveAccount(ctx context.Context) (*omise.Account, error) {
+	out, op := &omise.Account{}, &operations.RetrieveAccount{}
+	return result(out, g.call(ctx, "RetrieveAccount", true, func() error { return g.do(ctx, out, request(g.c.Request(op))) }))
+}
+
+// built is an omise-go request, or the error building it.
+type built struct {
+	req *http.Request
+	err error
+}
+
+// request adapts omise.Client.Request's two results for do.
+func request(req *http.Request, err error) built {
+	return built{req, err}
+}
+
+// do is omise.Client.Do with a per-call context: omise-go's own WithContext sets one context on the
+// shared client, which concurrent requests would overwrite.
+func (g *Client) do(ctx context.Context, out interface{}, b built) error {
+	if b.err != nil {
+		return b.err
+	}
+	resp, err := g.c.Client.Do(b.req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	buffer, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return &omise.ErrTransport{Err: err, Buffer: buffer}
+	}
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &omise.Error{StatusCode: resp.StatusCode}
+		if err := json.Unmarshal(buffer, apiErr); err != nil {
+			return &omise.ErrTransport{Err: err, Buffer: buffer}
+		}
+		return apiErr
+	}
+	if err := json.Unmarshal(buffer, out); err != nil {
+		return &omise.ErrTransport{Err: err, Buffer: buffer}
+	}
+	return nil
+}
+
+// result drops the half-filled object on error so callers never see partial responses.
+func result[T any](out *T, err error) (*T, error) {
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
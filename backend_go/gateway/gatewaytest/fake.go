@@ -0,0 +1,554 @@
+// Package gatewaytest provides test doubles for gateway.OmiseGateway: Fake, an in-memory Omise, and
+// Server, an httptest server that speaks Omise's REST API (backed by a Fake) for the real client.
+package gatewaytest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/gateway"
+	omise "github.com/omise/omise-go"
+	"github.com/omise/omise-go/operations"
+)
+
+// Fake is an in-memory Omise. Card charges succeed immediately; source charges (PromptPay, internet
+// banking) stay pending until the test calls Complete. A charge that succeeds credits its net amount to
+// the balance, transferable at once; Transfer pays the balance out. Inject failures with FailNext.
+type Fake struct {
+	mu           sync.Mutex
+	seq          int
+	tokens       map[string]*omise.Token
+	charges      map[string]*omise.Charge
+	sources      map[string]*omise.Source
+	customers    map[string]*omise.Customer
+	events       map[string]*omise.Event
+	transactions map[string]*omise.Transaction
+	transfers    []*omise.Transfer
+	failures     map[string][]error
+	calls        []string
+
+	// Now is the clock used for created_at / expires_at / transferable; defaults to time.Now.
+	Now func() time.Time
+	// QRCodeURL and AuthorizeURL build the PromptPay QR download link for a source and the offsite
+	// payment page for a charge; nil uses Omise-looking URLs that are never fetched.
+	QRCodeURL    func(sourceID string) string
+	AuthorizeURL func(chargeID string) string
+}
+
+var _ gateway.OmiseGateway = (*Fake)(nil)
+
+func NewFake() *Fake {
+	return &Fake{
+		tokens:       map[string]*omise.Token{},
+		charges:      map[string]*omise.Charge{},
+		sources:      map[string]*omise.Source{},
+		customers:    map[string]*omise.Customer{},
+		events:       map[string]*omise.Event{},
+		transactions: map[string]*omise.Transaction{},
+		failures:     map[string][]error{},
+		Now:          time.Now,
+	}
+}
+
+// FailNext makes the next call to method (e.g. "CreateCharge") return err. Queue several to fail
+// several calls in a row.
+func (f *Fake) FailNext(method string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures[method] = append(f.failures[method], err)
+}
+
+// Calls returns the methods called so far, in order.
+func (f *Fake) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+// Charge returns a copy of a stored charge (nil if unknown).
+func (f *Fake) Charge(id string) *omise.Charge {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if ch, ok := f.charges[id]; ok {
+		return cloneCharge(ch)
+	}
+	return nil
+}
+
+// Source returns a copy of a stored source (nil if unknown).
+func (f *Fake) Source(id string) *omise.Source {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if src, ok := f.sources[id]; ok {
+		out := *src
+		return &out
+	}
+	return nil
+}
+
+// Complete settles a pending charge as successful or failed and records a "charge.complete" event
+// for it; it returns the event id to post to the webhook handler.
+func (f *Fake) Complete(chargeID string, successful bool) (string, error) {
+	if successful {
+		return f.Settle(chargeID, "charge.complete", omise.ChargeSuccessful, "", "")
+	}
+	return f.Settle(chargeID, "charge.complete", omise.ChargeFailed, "payment_rejected", "payment was rejected by the bank")
+}
+
+// Settle sets a charge's status (with failureCode/failureMessage unless successful) and records an
+// event with key (e.g. "charge.complete", "charge.expire") for it; it returns the event id.
+func (f *Fake) Settle(chargeID, key string, status omise.ChargeStatus, failureCode, failureMessage string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ch, ok := f.charges[chargeID]
+	if !ok {
+		return "", NotFound("charge", chargeID)
+	}
+	ch.Status = status
+	if status == omise.ChargeSuccessful {
+		ch.Paid, ch.Authorized = true, true
+		ch.FailureCode, ch.FailureMessage = nil, nil
+		f.credit(ch)
+	} else {
+		ch.Paid, ch.Authorized = false, false
+		ch.CapturedAmount = 0
+		ch.FailureCode, ch.FailureMessage = &failureCode, &failureMessage
+	}
+	ev := &omise.Event{Base: f.base("event", "evnt"), Key: key, Data: cloneCharge(ch)}
+	f.events[ev.ID] = ev
+	return ev.ID, nil
+}
+
+// Transfer pays amount of the balance out to the bank, with Omise's transfer fee, and records a
+// "transfer.create" event for it; it returns the transfer and the event id. The transfer is sent and
+// paid at once.
+func (f *Fake) Transfer(amount, fee int64, currency string) (*omise.Transfer, string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tr := &omise.Transfer{Base: f.base("transfer", "trsf_test"), Sent: true, Paid: true, Amount: amount, Fee: fee, Currency: currency}
+	f.transfers = append(f.transfers, tr)
+	out := *tr
+	ev := &omise.Event{Base: f.base("event", "evnt"), Key: "transfer.create", Data: &out}
+	f.events[ev.ID] = ev
+	return &out, ev.ID
+}
+
+// NotFound is the error Omise returns for an unknown object.
+func NotFound(object, id string) *omise.Error {
+	return &omise.Error{StatusCode: http.StatusNotFound, Code: "not_found", Message: fmt.Sprintf("%s %s was not found", object, id)}
+}
+
+// Declined is a 4xx Omise error, e.g. FailNext("CreateCharge", gatewaytest.Declined("invalid_card")).
+func Declined(code string) *omise.Error {
+	return &omise.Error{StatusCode: http.StatusBadRequest, Code: code, Message: "charge was declined: " + code}
+}
+
+func (f *Fake) CreateToken(_ context.Context, op *operations.CreateToken) (*omise.Token, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.enter("CreateToken"); err != nil {
+		return nil, err
+	}
+	last := op.Number
+	if len(last) > 4 {
+		last = last[len(last)-4:]
+	}
+	tok := &omise.Token{
+		Base: f.base("token", "tokn_test"),
+		Card: &omise.Card{Base: f.base("card", "card_test"), Name: op.Name, LastDigits: last, Brand: "Visa",
+			ExpirationMonth: op.ExpirationMonth, ExpirationYear: op.ExpirationYear, Fingerprint: Fingerprint(op.Number)},
+	}
+	f.tokens[tok.ID] = tok
+	return tok, nil
+}
+
+// Fingerprint is the card fingerprint the fake gives tokens of the card number: the same for every
+// token of a card, as Omise's is.
+func Fingerprint(number string) string {
+	sum := sha256.Sum256([]byte(number))
+	return base64.StdEncoding.EncodeToString(sum[:24])
+}
+
+func (f *Fake) RetrieveToken(_ context.Context, tokenID string) (*omise.Token, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.enter("RetrieveToken"); err != nil {
+		return nil, err
+	}
+	tok, ok := f.tokens[tokenID]
+	if !ok {
+		return nil, NotFound("token", tokenID)
+	}
+	out, card := *tok, *tok.Card
+	out.Card = &card
+	return &out, nil
+}
+
+func (f *Fake) CreateSource(_ context.Context, op *operations.CreateSource) (*omise.Source, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.enter("CreateSource"); err != nil {
+		return nil, err
+	}
+	src := &omise.Source{Base: f.base("source", "src_test"), Type: op.Type, Amount: op.Amount, Currency: op.Currency, Flow: "redirect"}
+	if op.Type == "promptpay" {
+		src.Flow = "offline"
+		uri := "https://api.omise.co/charges/qrcode.svg"
+		if f.QRCodeURL != nil {
+			uri = f.QRCodeURL(src.ID)
+		}
+		src.ScannableCode = &omise.ScannableCode{Object: "barcode", Type: "qr", Image: &omise.Document{
+			Base:        f.base("document", "docu_test"),
+			Filename:    "qrcode.svg",
+			DownloadURI: uri,
+		}}
+	}
+	f.sources[src.ID] = src
+	return src, nil
+}
+
+// CreateCharge charges a card at once, unless ctx asks for authentication (gateway.WithAuthentication):
+// then the charge is pending 3-D Secure at its AuthorizeURI, as are source charges that redirect.
+func (f *Fake) CreateCharge(ctx context.Context, op *operations.CreateCharge) (*omise.Charge, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.enter("CreateCharge"); err != nil {
+		return nil, err
+	}
+	ch := &omise.Charge{
+		Base:       f.base("charge", "chrg_test"),
+		Amount:     op.Amount,
+		Currency:   op.Currency,
+		ReturnURI:  op.ReturnURI,
+		Metadata:   op.Metadata,
+		CustomerID: op.Customer,
+		Capture:    !op.DontCapture,
+	}
+	if op.Description != "" {
+		d := op.Description
+		ch.Description = &d
+	}
+	if op.Ip != "" {
+		ip := op.Ip
+		ch.IP = &ip
+	}
+	switch {
+	case op.Source != "":
+		src, ok := f.sources[op.Source]
+		if !ok {
+			return nil, NotFound("source", op.Source)
+		}
+		ch.Source = src
+		ch.Status = omise.ChargePending
+		ch.ExpiresAt = ch.CreatedAt.Add(24 * time.Hour)
+		if src.Flow == "redirect" {
+			ch.AuthorizeURI = "https://pay.omise.co/offsites/" + ch.ID + "/pay"
+			if f.AuthorizeURL != nil {
+				ch.AuthorizeURI = f.AuthorizeURL(ch.ID)
+			}
+		}
+	case op.Card != "" && gateway.AuthenticationFrom(ctx) != "":
+		ch.Card = &omise.Card{Base: f.base("card", "card_test"), LastDigits: "4242", Brand: "Visa"}
+		ch.Status = omise.ChargePending
+		ch.AuthorizeURI = "https://pay.omise.co/payments/" + ch.ID + "/authorize"
+		if f.AuthorizeURL != nil {
+			ch.AuthorizeURI = f.AuthorizeURL(ch.ID)
+		}
+	case op.Card != "" || op.Customer != "":
+		ch.Card = &omise.Card{Base: f.base("card", "card_test"), LastDigits: "4242", Brand: "Visa"}
+		ch.Status, ch.Paid, ch.Authorized = omise.ChargeSuccessful, true, true
+		ch.CapturedAmount, ch.AuthorizedAmount = op.Amount, op.Amount
+		f.credit(ch)
+	default:
+		return nil, &omise.Error{StatusCode: http.StatusBadRequest, Code: "invalid_charge", Message: "card, customer or source is required"}
+	}
+	f.charges[ch.ID] = ch
+	return cloneCharge(ch), nil
+}
+
+func (f *Fake) RetrieveCharge(_ context.Context, chargeID string) (*omise.Charge, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.enter("RetrieveCharge"); err != nil {
+		return nil, err
+	}
+	ch, ok := f.charges[chargeID]
+	if !ok {
+		return nil, NotFound("charge", chargeID)
+	}
+	return cloneCharge(ch), nil
+}
+
+// RetrieveChargeFees returns the fees Omise would keep of a stored charge: on a successful one, the
+// standard 3.65% fee plus 7% VAT on it; nothing on any other.
+func (f *Fake) RetrieveChargeFees(_ context.Context, chargeID string) (*gateway.ChargeFees, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.enter("RetrieveChargeFees"); err != nil {
+		return nil, err
+	}
+	ch, ok := f.charges[chargeID]
+	if !ok {
+		return nil, NotFound("charge", chargeID)
+	}
+	fees := chargeFees(ch)
+	return &fees, nil
+}
+
+// (helper for RetrieveChargeFees and Server) the fees of ch.
+func chargeFees(ch *omise.Charge) gateway.ChargeFees {
+	if ch.Status != omise.ChargeSuccessful {
+		return gateway.ChargeFees{}
+	}
+	fee := ch.Amount * 365 / 10000
+	vat := fee * 7 / 100
+	return gateway.ChargeFees{Fee: fee, FeeVAT: vat, Net: ch.Amount - fee - vat, Transaction: ch.Transaction}
+}
+
+func (f *Fake) RetrieveTransaction(_ context.Context, transactionID string) (*omise.Transaction, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.enter("RetrieveTransaction"); err != nil {
+		return nil, err
+	}
+	tr, ok := f.transactions[transactionID]
+	if !ok {
+		return nil, NotFound("transaction", transactionID)
+	}
+	out := *tr
+	return &out, nil
+}
+
+// ListTransfers pages through the transfers like ListCharges, without the date bounds.
+func (f *Fake) ListTransfers(_ context.Context, op *operations.ListTransfers) (*omise.TransferList, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.enter("ListTransfers"); err != nil {
+		return nil, err
+	}
+	all := append([]*omise.Transfer(nil), f.transfers...) // in creation order
+	order := op.Order
+	if order == omise.UnspecifiedOrder {
+		order = omise.Chronological
+	}
+	if order == omise.ReverseChronological {
+		for i, j := 0, len(all)-1; i < j; i, j = i+1, j-1 {
+			all[i], all[j] = all[j], all[i]
+		}
+	}
+	limit := op.Limit
+	if limit <= 0 {
+		limit = 20
+	} else if limit > 100 {
+		limit = 100
+	}
+	out := &omise.TransferList{List: omise.List{Base: omise.Base{Object: "list"}, Offset: op.Offset, Limit: limit, Total: len(all), Order: order}}
+	for i := op.Offset; i < len(all) && i < op.Offset+limit; i++ {
+		tr := *all[i]
+		out.Data = append(out.Data, &tr)
+	}
+	return out, nil
+}
+
+// ExpireCharge expires a pending source charge and records a "charge.expire" event for it, like Omise;
+// other charges cannot be expired.
+func (f *Fake) ExpireCharge(_ context.Context, chargeID string) (*omise.Charge, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.enter("ExpireCharge"); err != nil {
+		return nil, err
+	}
+	ch, ok := f.charges[chargeID]
+	if !ok {
+		return nil, NotFound("charge", chargeID)
+	}
+	if ch.Status != omise.ChargePending || ch.Source == nil {
+		return nil, &omise.Error{StatusCode: http.StatusBadRequest, Code: "failed_expire", Message: "charge cannot be expired"}
+	}
+	ch.Status = omise.ChargeStatus("expired") // omise-go has no constant for it
+	ev := &omise.Event{Base: f.base("event", "evnt"), Key: "charge.expire", Data: cloneCharge(ch)}
+	f.events[ev.ID] = ev
+	return cloneCharge(ch), nil
+}
+
+// ListCharges pages through the stored charges created in [From, To] (either bound optional) like
+// Omise: Limit defaults to 20 and is capped at 100, Order defaults to chronological.
+func (f *Fake) ListCharges(_ context.Context, op *operations.ListCharges) (*omise.ChargeList, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.enter("ListCharges"); err != nil {
+		return nil, err
+	}
+	var all []*omise.Charge
+	for _, ch := range f.charges {
+		if (!op.From.IsZero() && ch.CreatedAt.Before(op.From)) || (!op.To.IsZero() && ch.CreatedAt.After(op.To)) {
+			continue
+		}
+		all = append(all, ch)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if !all[i].CreatedAt.Equal(all[j].CreatedAt) {
+			return all[i].CreatedAt.Before(all[j].CreatedAt)
+		}
+		return all[i].ID < all[j].ID
+	})
+	order := op.Order
+	if order == omise.UnspecifiedOrder {
+		order = omise.Chronological
+	}
+	if order == omise.ReverseChronological {
+		for i, j := 0, len(all)-1; i < j; i, j = i+1, j-1 {
+			all[i], all[j] = all[j], all[i]
+		}
+	}
+	limit := op.Limit
+	if limit <= 0 {
+		limit = 20
+	} else if limit > 100 {
+		limit = 100
+	}
+	out := &omise.ChargeList{List: omise.List{Base: omise.Base{Object: "list"}, Offset: op.Offset, Limit: limit, Total: len(all), Order: order}}
+	for i := op.Offset; i < len(all) && i < op.Offset+limit; i++ {
+		out.Data = append(out.Data, cloneCharge(all[i]))
+	}
+	return out, nil
+}
+
+func (f *Fake) RetrieveEvent(_ context.Context, eventID string) (*omise.Event, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.enter("RetrieveEvent"); err != nil {
+		return nil, err
+	}
+	ev, ok := f.events[eventID]
+	if !ok {
+		return nil, NotFound("event", eventID)
+	}
+	out := *ev
+	return &out, nil
+}
+
+func (f *Fake) CreateRefund(_ context.Context, op *operations.CreateRefund) (*omise.Refund, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.enter("CreateRefund"); err != nil {
+		return nil, err
+	}
+	ch, ok := f.charges[op.ChargeID]
+	if !ok {
+		return nil, NotFound("charge", op.ChargeID)
+	}
+	if !ch.Paid || ch.RefundedAmount+op.Amount > ch.Amount {
+		return nil, &omise.Error{StatusCode: http.StatusBadRequest, Code: "failed_refund", Message: "charge cannot be refunded for this amount"}
+	}
+	ch.RefundedAmount += op.Amount
+	return &omise.Refund{
+		Base:     f.base("refund", "rfnd_test"),
+		Status:   "closed",
+		Amount:   op.Amount,
+		Currency: ch.Currency,
+		Charge:   ch.ID,
+		Metadata: op.Metadata,
+	}, nil
+}
+
+func (f *Fake) CreateCustomer(_ context.Context, op *operations.CreateCustomer) (*omise.Customer, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.enter("CreateCustomer"); err != nil {
+		return nil, err
+	}
+	cust := &omise.Customer{
+		Base:        f.base("customer", "cust_test"),
+		Email:       op.Email,
+		Description: op.Description,
+		Metadata:    op.Metadata,
+		Cards:       &omise.CardList{},
+	}
+	f.attachCard(cust, op.Card)
+	f.customers[cust.ID] = cust
+	return cloneCustomer(cust), nil
+}
+
+func (f *Fake) UpdateCustomer(_ context.Context, op *operations.UpdateCustomer) (*omise.Customer, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.enter("UpdateCustomer"); err != nil {
+		return nil, err
+	}
+	cust, ok := f.customers[op.CustomerID]
+	if !ok {
+		return nil, NotFound("customer", op.CustomerID)
+	}
+	if op.Email != "" {
+		cust.Email = op.Email
+	}
+	f.attachCard(cust, op.Card)
+	return cloneCustomer(cust), nil
+}
+
+func (f *Fake) RetrieveAccount(_ context.Context) (*omise.Account, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.enter("RetrieveAccount"); err != nil {
+		return nil, err
+	}
+	return &omise.Account{Base: omise.Base{Object: "account", ID: "acct_test"}, Email: "test@example.com"}, nil
+}
+
+// (helper, mu held) record the call and pop an injected failure.
+func (f *Fake) enter(method string) error {
+	f.calls = append(f.calls, method)
+	if q := f.failures[method]; len(q) > 0 {
+		f.failures[method] = q[1:]
+		return q[0]
+	}
+	return nil
+}
+
+// (helper, mu held) a new object header with a sequential test id.
+func (f *Fake) base(object, prefix string) omise.Base {
+	f.seq++
+	return omise.Base{Object: object, ID: fmt.Sprintf("%s_%d", prefix, f.seq), CreatedAt: f.Now().UTC()}
+}
+
+// (helper, mu held) credit a charge that just succeeded with its net amount, transferable now.
+func (f *Fake) credit(ch *omise.Charge) {
+	if ch.Transaction != "" {
+		return
+	}
+	tr := &omise.Transaction{Base: f.base("transaction", "trxn_test"), Source: ch.ID, Type: omise.Credit,
+		Amount: chargeFees(ch).Net, Currency: ch.Currency}
+	tr.Transferable = tr.CreatedAt
+	f.transactions[tr.ID] = tr
+	ch.Transaction = tr.ID
+}
+
+// (helper, mu held) add a card for token to the customer and make it the default.
+func (f *Fake) attachCard(cust *omise.Customer, token string) {
+	if token == "" {
+		return
+	}
+	card := &omise.Card{Base: f.base("card", "card_test"), LastDigits: "4242", Brand: "Visa"}
+	cust.Cards.Data = append(cust.Cards.Data, card)
+	cust.DefaultCard = card.ID
+}
+
+func cloneCharge(ch *omise.Charge) *omise.Charge {
+	out := *ch
+	return &out
+}
+
+func cloneCustomer(c *omise.Customer) *omise.Customer {
+	out := *c
+	cards := *c.Cards
+	cards.Data = append([]*omise.Card(nil), c.Cards.Data...)
+	out.Cards = &cards
+	return &out
+}
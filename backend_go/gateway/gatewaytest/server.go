@@ -0,0 +1,189 @@
+package gatewaytest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/gateway"
+	omise "github.com/omise/omise-go"
+	"github.com/omise/omise-go/operations"
+)
+
+// Server is an httptest server that answers the Omise REST endpoints the service uses, backed by a
+// Fake. Use it to exercise the real omise-go client end to end (JSON encoding, auth, error decoding)
+// without the network.
+type Server struct {
+	*httptest.Server
+	Fake *Fake
+
+	mu       sync.Mutex
+	requests []string
+}
+
+// NewServer starts a Server that is closed when the test ends.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+	s := &Server{Fake: NewFake()}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Gateway returns a real gateway.Client whose API and Vault endpoints point at this server.
+func (s *Server) Gateway(t testing.TB) *gateway.Client {
+	t.Helper()
+	c, err := omise.NewClient("pkey_test_stub", "skey_test_stub")
+	if err != nil {
+		t.Fatalf("omise client: %v", err)
+	}
+	c.Endpoints["https://api.omise.co"] = s.URL
+	c.Endpoints["https://vault.omise.co"] = s.URL
+	return gateway.New(c)
+}
+
+// Requests returns "METHOD /path" for every request received, in order.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+	s.mu.Unlock()
+	if user, _, ok := r.BasicAuth(); !ok || !(strings.HasPrefix(user, "skey_") || strings.HasPrefix(user, "pkey_")) {
+		writeError(w, &omise.Error{StatusCode: http.StatusUnauthorized, Code: "authentication_failure", Message: "authentication failed"})
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	var (
+		out interface{}
+		err error
+	)
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/tokens":
+		var body struct {
+			Card operations.CreateToken `json:"card"`
+		}
+		if err = decode(r, &body); err == nil {
+			out, err = s.Fake.CreateToken(r.Context(), &body.Card)
+		}
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[0] == "tokens":
+		out, err = s.Fake.RetrieveToken(r.Context(), parts[1])
+	case r.Method == http.MethodPost && r.URL.Path == "/sources":
+		var op operations.CreateSource
+		if err = decode(r, &op); err == nil {
+			out, err = s.Fake.CreateSource(r.Context(), &op)
+		}
+	case r.Method == http.MethodPost && r.URL.Path == "/charges":
+		var body struct {
+			operations.CreateCharge
+			Capture        *bool  `json:"capture"`
+			Authentication string `json:"authentication"`
+		}
+		if err = decode(r, &body); err == nil {
+			body.DontCapture = body.Capture != nil && !*body.Capture
+			ctx := r.Context()
+			if body.Authentication != "" {
+				ctx = gateway.WithAuthentication(ctx, body.Authentication)
+			}
+			out, err = s.Fake.CreateCharge(ctx, &body.CreateCharge)
+		}
+	case r.Method == http.MethodGet && r.URL.Path == "/charges":
+		// omise-go sends list parameters as a JSON body, even on GET
+		var body struct {
+			operations.List
+			From *time.Time `json:"from"`
+			To   *time.Time `json:"to"`
+		}
+		if err = decode(r, &body); err == nil {
+			if body.From != nil {
+				body.List.From = *body.From
+			}
+			if body.To != nil {
+				body.List.To = *body.To
+			}
+			out, err = s.Fake.ListCharges(r.Context(), &operations.ListCharges{List: body.List})
+		}
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[0] == "charges":
+		var ch *omise.Charge
+		if ch, err = s.Fake.RetrieveCharge(r.Context(), parts[1]); err == nil {
+			// Omise's charge carries its fees too (see gateway.ChargeFees); its transaction is the Charge's.
+			fees := chargeFees(ch)
+			out = struct {
+				*omise.Charge
+				Fee    int64 `json:"fee"`
+				FeeVAT int64 `json:"fee_vat"`
+				Net    int64 `json:"net"`
+			}{ch, fees.Fee, fees.FeeVAT, fees.Net}
+		}
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[0] == "transactions":
+		out, err = s.Fake.RetrieveTransaction(r.Context(), parts[1])
+	case r.Method == http.MethodGet && r.URL.Path == "/transfers":
+		var body operations.List
+		if err = decode(r, &body); err == nil {
+			out, err = s.Fake.ListTransfers(r.Context(), &operations.ListTransfers{List: body})
+		}
+	case r.Method == http.MethodPost && len(parts) == 3 && parts[0] == "charges" && parts[2] == "expire":
+		out, err = s.Fake.ExpireCharge(r.Context(), parts[1])
+	case r.Method == http.MethodPost && len(parts) == 3 && parts[0] == "charges" && parts[2] == "refunds":
+		op := operations.CreateRefund{ChargeID: parts[1]}
+		if err = decode(r, &op); err == nil {
+			out, err = s.Fake.CreateRefund(r.Context(), &op)
+		}
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[0] == "events":
+		out, err = s.Fake.RetrieveEvent(r.Context(), parts[1])
+	case r.Method == http.MethodPost && r.URL.Path == "/customers":
+		var op operations.CreateCustomer
+		if err = decode(r, &op); err == nil {
+			out, err = s.Fake.CreateCustomer(r.Context(), &op)
+		}
+	case r.Method == http.MethodPatch && len(parts) == 2 && parts[0] == "customers":
+		op := operations.UpdateCustomer{CustomerID: parts[1]}
+		if err = decode(r, &op); err == nil {
+			out, err = s.Fake.UpdateCustomer(r.Context(), &op)
+		}
+	case r.Method == http.MethodGet && r.URL.Path == "/account":
+		out, err = s.Fake.RetrieveAccount(r.Context())
+	default:
+		err = &omise.Error{StatusCode: http.StatusNotFound, Code: "not_found", Message: "no stub for " + r.Method + " " + r.URL.Path}
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+func decode(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return &omise.Error{StatusCode: http.StatusBadRequest, Code: "bad_request", Message: err.Error()}
+	}
+	return nil
+}
+
+// writeError renders err like Omise does; non-Omise errors (injected with FailNext) become 500s.
+func writeError(w http.ResponseWriter, err error) {
+	var oerr *omise.Error
+	if !errors.As(err, &oerr) {
+		oerr = &omise.Error{StatusCode: http.StatusInternalServerError, Code: "internal_error", Message: err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(oerr.StatusCode)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"object":   "error",
+		"location": oerr.Location,
+		"code":     oerr.Code,
+		"message":  oerr.Message,
+	})
+}
@@ -0,0 +1,165 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+
+	omise "github.com/omise/omise-go"
+	"github.com/omise/omise-go/operations"
+)
+
+type merchantKey struct{}
+
+// WithMerchant returns a ctx whose Omise calls Merchants sends to merchant id's account.
+func WithMerchant(ctx context.Context, id uint) context.Context {
+	return context.WithValue(ctx, merchantKey{}, id)
+}
+
+// MerchantFrom returns the merchant WithMerchant set on ctx, 0 if none.
+func MerchantFrom(ctx context.Context) uint {
+	id, _ := ctx.Value(merchantKey{}).(uint)
+	return id
+}
+
+// Merchants implements OmiseGateway over several Omise accounts, one per merchant id: each call goes
+// to the account of the merchant on its ctx (WithMerchant), or to Default when there is none. A
+// merchant without an account fails rather than falling back, so a charge is never looked up or
+// refunded on another merchant's account.
+type Merchants struct {
+	Default  OmiseGateway
+	Accounts map[uint]OmiseGateway
+}
+
+var _ OmiseGateway = (*Merchants)(nil)
+
+// (helper for Merchants) the account for ctx's merchant.
+func (m *Merchants) account(ctx context.Context) (OmiseGateway, error) {
+	id := MerchantFrom(ctx)
+	if id == 0 {
+		return m.Default, nil
+	}
+	if gw, ok := m.Accounts[id]; ok {
+		return gw, nil
+	}
+	return nil, fmt.Errorf("gateway: no Omise account configured for merchant %d", id)
+}
+
+func (m *Merchants) CreateToken(ctx context.Context, op *operations.CreateToken) (*omise.Token, error) {
+	gw, err := m.account(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return gw.CreateToken(ctx, op)
+}
+
+func (m *Merchants) RetrieveToken(ctx context.Context, tokenID string) (*omise.Token, error) {
+	gw, err := m.account(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return gw.RetrieveToken(ctx, tokenID)
+}
+
+func (m *Merchants) CreateSource(ctx context.Context, op *operations.CreateSource) (*omise.Source, error) {
+	gw, err := m.account(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return gw.CreateSource(ctx, op)
+}
+
+func (m *Merchants) CreateCharge(ctx context.Context, op *operations.CreateCharge) (*omise.Charge, error) {
+	gw, err := m.account(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return gw.CreateCharge(ctx, op)
+}
+
+func (m *Merchants) RetrieveCharge(ctx context.Context, chargeID string) (*omise.Charge, error) {
+	gw, err := m.account(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return gw.RetrieveCharge(ctx, chargeID)
+}
+
+func (m *Merchants) RetrieveChargeFees(ctx context.Context, chargeID string) (*ChargeFees, error) {
+	gw, err := m.account(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return gw.RetrieveChargeFees(ctx, chargeID)
+}
+
+func (m *Merchants) ExpireCharge(ctx context.Context, chargeID string) (*omise.Charge, error) {
+	gw, err := m.account(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return gw.ExpireCharge(ctx, chargeID)
+}
+
+func (m *Merchants) ListCharges(ctx context.Context, op *operations.ListCharges) (*omise.ChargeList, error) {
+	gw, err := m.account(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return gw.ListCharges(ctx, op)
+}
+
+func (m *Merchants) RetrieveTransaction(ctx context.Context, transactionID string) (*omise.Transaction, error) {
+	gw, err := m.account(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return gw.RetrieveTransaction(ctx, transactionID)
+}
+
+func (m *Merchants) ListTransfers(ctx context.Context, op *operations.ListTransfers) (*omise.TransferList, error) {
+	gw, err := m.account(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return gw.ListTransfers(ctx, op)
+}
+
+func (m *Merchants) RetrieveEvent(ctx context.Context, eventID string) (*omise.Event, error) {
+	gw, err := m.account(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return gw.RetrieveEvent(ctx, eventID)
+}
+
+func (m *Merchants) CreateRefund(ctx context.Context, op *operations.CreateRefund) (*omise.Refund, error) {
+	gw, err := m.account(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return gw.CreateRefund(ctx, op)
+}
+
+func (m *Merchants) CreateCustomer(ctx context.Context, op *operations.CreateCustomer) (*omise.Customer, error) {
+	gw, err := m.account(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return gw.CreateCustomer(ctx, op)
+}
+
+func (m *Merchants) UpdateCustomer(ctx context.Context, op *operations.UpdateCustomer) (*omise.Customer, error) {
+	gw, err := m.account(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return gw.UpdateCustomer(ctx, op)
+}
+
+func (m *Merchants) RetrieveAccount(ctx context.Context) (*omise.Account, error) {
+	gw, err := m.account(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return gw.RetrieveAccount(ctx)
+}
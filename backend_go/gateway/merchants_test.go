@@ -0,0 +1,52 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	omise "github.com/omise/omise-go"
+)
+
+// accountOmise is an Omise account whose charges all carry the given description.
+func accountOmise(t *testing.T, name string) *Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"object":"charge","id":"chrg_test_1","status":"pending","amount":10000,"currency":"thb","description":"` + name + `"}`))
+	}))
+	t.Cleanup(srv.Close)
+	c, err := omise.NewClient("pkey_test_"+name, "skey_test_"+name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Endpoints["https://api.omise.co"] = srv.URL
+	return New(c)
+}
+
+func TestMerchantsRouteByContext(t *testing.T) {
+	def := accountOmise(t, "default")
+	m := &Merchants{Default: def, Accounts: map[uint]OmiseGateway{1: def, 2: accountOmise(t, "partner")}}
+
+	for _, tc := range []struct {
+		merchant uint
+		want     string
+	}{{0, "default"}, {1, "default"}, {2, "partner"}} {
+		ctx := context.Background()
+		if tc.merchant != 0 {
+			ctx = WithMerchant(ctx, tc.merchant)
+		}
+		ch, err := m.RetrieveCharge(ctx, "chrg_test_1")
+		if err != nil {
+			t.Fatalf("merchant %d: %v", tc.merchant, err)
+		}
+		if ch.Description == nil || *ch.Description != tc.want {
+			t.Errorf("merchant %d: charge from %v, want %s", tc.merchant, ch.Description, tc.want)
+		}
+	}
+
+	if _, err := m.RetrieveCharge(WithMerchant(context.Background(), 3), "chrg_test_1"); err == nil {
+		t.Error("merchant without an account: want an error, not the default account")
+	}
+}
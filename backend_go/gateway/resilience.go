@@ -0,0 +1,195 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log"
+	"math/rand"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	omise "github.com/omise/omise-go"
+)
+
+// ErrCircuitOpen is returned without calling Omise while the circuit breaker is open.
+var ErrCircuitOpen = errors.New("omise: circuit breaker open, Omise calls are failing")
+
+// resilienceMetrics is published at /debug/vars as "omise_gateway": retries, failures (calls that
+// failed after their retries), rejected (calls refused by the open breaker), breaker_trips and
+// breaker_open (1 while open).
+var resilienceMetrics = expvar.NewMap("omise_gateway")
+
+// Resilience is the retry and circuit breaker policy for Omise calls.
+//
+// Retries: calls that cannot move money or change a customer twice (reads, tokens, sources) are
+// retried on Omise 5xx responses and network errors; charges, refunds and customer writes only when
+// the connection could not be made, since a write that reached Omise may have taken effect. Delays
+// double from BaseDelay up to MaxDelay, with full jitter.
+//
+// Breaker: after BreakerThreshold consecutive failed calls (5xx or network errors; 4xx answers are
+// successes as far as availability goes) every call fails fast with ErrCircuitOpen for
+// BreakerCooldown, after which one trial call is let through; it closes the breaker on success and
+// reopens it on failure.
+type Resilience struct {
+	MaxAttempts      int // including the first try; 1 disables retries
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	BreakerThreshold int // 0 disables the breaker
+	BreakerCooldown  time.Duration
+
+	// OnOpen, when set, is called in its own goroutine each time the breaker opens (not when a failed
+	// trial call reopens it), with the consecutive failures that opened it; e.g. to page on-call.
+	OnOpen func(failures int, cooldown time.Duration)
+}
+
+// breaker is a consecutive-failure circuit breaker; the zero value is closed.
+type breaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	trial     bool // a half-open trial call is in flight
+}
+
+// allow reports whether a call may go to Omise now.
+func (b *breaker) allow(r Resilience, now time.Time) bool {
+	if r.BreakerThreshold <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < r.BreakerThreshold {
+		return true
+	}
+	if now.Before(b.openUntil) || b.trial {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// release ends a call that allow let through without an outcome (its caller gave up), so a
+// half-open breaker lets the next trial through.
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+// record notes the outcome of a call that allow let through.
+func (b *breaker) record(r Resilience, failed bool, now time.Time) {
+	if r.BreakerThreshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	wasOpen := b.failures >= r.BreakerThreshold
+	b.trial = false
+	if !failed {
+		if wasOpen {
+			log.Printf("omise: circuit breaker closed")
+			resilienceMetrics.Set("breaker_open", new(expvar.Int))
+		}
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= r.BreakerThreshold {
+		b.openUntil = now.Add(r.BreakerCooldown)
+		if !wasOpen {
+			log.Printf("omise: circuit breaker opened after %d consecutive failures, failing fast for %s", b.failures, r.BreakerCooldown)
+			resilienceMetrics.Add("breaker_trips", 1)
+			open := new(expvar.Int)
+			open.Set(1)
+			resilienceMetrics.Set("breaker_open", open)
+			if r.OnOpen != nil {
+				go r.OnOpen(b.failures, r.BreakerCooldown)
+			}
+		}
+	}
+}
+
+// call runs one Omise request under the policy. name is for logs; safe marks calls that may be sent
+// twice (see Resilience). Once ctx is done there are no more attempts, and the failure caused by it
+// is the caller giving up, so it does not count against the breaker.
+func (g *Client) call(ctx context.Context, name string, safe bool, do func() error) error {
+	r := g.resilience
+	attempts := max(r.MaxAttempts, 1)
+	delay := r.BaseDelay
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if !g.breaker.allow(r, time.Now()) {
+			resilienceMetrics.Add("rejected", 1)
+			return ErrCircuitOpen
+		}
+		err = do()
+		if ctx.Err() != nil {
+			g.breaker.release()
+			return err
+		}
+		g.breaker.record(r, isOutage(err), time.Now())
+		if err == nil || attempt == attempts || !retryable(err, safe) {
+			break
+		}
+		resilienceMetrics.Add("retries", 1)
+		wait := time.Duration(rand.Int63n(int64(delay) + 1)) // full jitter
+		log.Printf("omise: %s attempt=%d failed err=%v (retrying in %s)", name, attempt, err, wait.Round(time.Millisecond))
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		if delay *= 2; r.MaxDelay > 0 && delay > r.MaxDelay {
+			delay = r.MaxDelay
+		}
+	}
+	if isOutage(err) {
+		resilienceMetrics.Add("failures", 1)
+	}
+	return err
+}
+
+// isOutage reports errors that say Omise is unavailable: 5xx answers and transport failures. API
+// errors below 500 (declines, validation, not found) mean Omise is up.
+func isOutage(err error) bool {
+	if err == nil {
+		return false
+	}
+	var oerr *omise.Error
+	if errors.As(err, &oerr) {
+		return oerr.StatusCode >= 500
+	}
+	return true
+}
+
+// retryable reports whether a failed call may be sent again.
+func retryable(err error, safe bool) bool {
+	if !isOutage(err) {
+		return false
+	}
+	if safe {
+		return true
+	}
+	return notSent(err)
+}
+
+// notSent reports transport errors raised before the request reached Omise: the connection was
+// refused, or DNS or dialing failed.
+func notSent(err error) bool {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	omise "github.com/omise/omise-go"
+	"github.com/omise/omise-go/operations"
+)
+
+// flakyOmise answers the first `failures` requests with a 503 and the rest with a pending charge.
+func flakyOmise(t *testing.T, failures int32) (*Client, *int32) {
+	t.Helper()
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if atomic.AddInt32(&hits, 1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"object":"error","code":"service_unavailable","message":"try again"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"object":"charge","id":"chrg_test_1","status":"pending","amount":10000,"currency":"thb"}`))
+	}))
+	t.Cleanup(srv.Close)
+	c, err := omise.NewClient("pkey_test_x", "skey_test_x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Endpoints["https://api.omise.co"] = srv.URL
+	return New(c), &hits
+}
+
+func TestRetriesReadsOnServerErrors(t *testing.T) {
+	g, hits := flakyOmise(t, 2)
+	g.WithResilience(Resilience{MaxAttempts: 3, BaseDelay: time.Millisecond})
+	ch, err := g.RetrieveCharge(context.Background(), "chrg_test_1")
+	if err != nil {
+		t.Fatalf("RetrieveCharge: %v", err)
+	}
+	if ch.ID != "chrg_test_1" || atomic.LoadInt32(hits) != 3 {
+		t.Errorf("charge %q after %d requests, want chrg_test_1 after 3", ch.ID, *hits)
+	}
+}
+
+func TestDoesNotRetryChargesThatReachedOmise(t *testing.T) {
+	g, hits := flakyOmise(t, 1)
+	g.WithResilience(Resilience{MaxAttempts: 3, BaseDelay: time.Millisecond})
+	_, err := g.CreateCharge(context.Background(), &operations.CreateCharge{Amount: 10000, Currency: "thb", Card: "tokn_test"})
+	var oerr *omise.Error
+	if !errors.As(err, &oerr) || oerr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("err = %v, want the 503", err)
+	}
+	if n := atomic.LoadInt32(hits); n != 1 {
+		t.Errorf("%d requests, want 1", n)
+	}
+}
+
+func TestBreakerFailsFastThenRecovers(t *testing.T) {
+	g, hits := flakyOmise(t, 2)
+	opened := make(chan int, 2)
+	g.WithResilience(Resilience{MaxAttempts: 1, BreakerThreshold: 2, BreakerCooldown: 50 * time.Millisecond,
+		OnOpen: func(failures int, _ time.Duration) { opened <- failures }})
+	for i := 0; i < 2; i++ {
+		if _, err := g.RetrieveCharge(context.Background(), "chrg_test_1"); err == nil {
+			t.Fatalf("call %d succeeded, want 503", i)
+		}
+	}
+	if _, err := g.RetrieveCharge(context.Background(), "chrg_test_1"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v, want ErrCircuitOpen", err)
+	}
+	if n := atomic.LoadInt32(hits); n != 2 {
+		t.Errorf("open breaker let a request through: %d requests, want 2", n)
+	}
+	select {
+	case n := <-opened:
+		if n != 2 {
+			t.Errorf("OnOpen got %d failures, want 2", n)
+		}
+	case <-time.After(time.Second):
+		t.Error("OnOpen was not called when the breaker opened")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, err := g.RetrieveCharge(context.Background(), "chrg_test_1"); err != nil {
+		t.Fatalf("trial call after cooldown: %v", err)
+	}
+	if _, err := g.RetrieveCharge(context.Background(), "chrg_test_1"); err != nil {
+		t.Fatalf("call after the breaker closed: %v", err)
+	}
+}
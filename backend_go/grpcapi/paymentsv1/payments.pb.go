@@ -0,0 +1,878 @@
+// Payments API for internal tutorium services. It mirrors the REST endpoints under /api/v1/payments
+// and is served by grpcapi.Server on GRPC_LISTEN_ADDR. Amounts are in minor units (satang).
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: paymentsv1/payments.proto
+
+package paymentsv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Transaction struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Id             uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	ChargeId       string                 `protobuf:"bytes,2,opt,name=charge_id,json=chargeId,proto3" json:"charge_id,omitempty"`
+	UserId         *uint64                `protobuf:"varint,3,opt,name=user_id,json=userId,proto3,oneof" json:"user_id,omitempty"`
+	ActingUserId   *uint64                `protobuf:"varint,4,opt,name=acting_user_id,json=actingUserId,proto3,oneof" json:"acting_user_id,omitempty"`
+	AmountSatang   int64                  `protobuf:"varint,5,opt,name=amount_satang,json=amountSatang,proto3" json:"amount_satang,omitempty"`
+	Currency       string                 `protobuf:"bytes,6,opt,name=currency,proto3" json:"currency,omitempty"`
+	Channel        string                 `protobuf:"bytes,7,opt,name=channel,proto3" json:"channel,omitempty"` // "card", "promptpay", "internet_banking_bbl", ...
+	Status         string                 `protobuf:"bytes,8,opt,name=status,proto3" json:"status,omitempty"`   // Omise charge status: "pending", "successful", "failed", ...
+	FailureCode    string                 `protobuf:"bytes,9,opt,name=failure_code,json=failureCode,proto3" json:"failure_code,omitempty"`
+	FailureMessage string                 `protobuf:"bytes,10,opt,name=failure_message,json=failureMessage,proto3" json:"failure_message,omitempty"`
+	CreatedAt      *timestamppb.Timestamp `protobuf:"bytes,11,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt      *timestamppb.Timestamp `protobuf:"bytes,12,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *Transaction) Reset() {
+	*x = Transaction{}
+	mi := &file_paymentsv1_payments_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Transaction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Transaction) ProtoMessage() {}
+
+func (x *Transaction) ProtoReflect() protoreflect.Message {
+	mi := &file_paymentsv1_payments_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Transaction.ProtoReflect.Descriptor instead.
+func (*Transaction) Descriptor() ([]byte, []int) {
+	return file_paymentsv1_payments_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Transaction) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Transaction) GetChargeId() string {
+	if x != nil {
+		return x.ChargeId
+	}
+	return ""
+}
+
+func (x *Transaction) GetUserId() uint64 {
+	if x != nil && x.UserId != nil {
+		return *x.UserId
+	}
+	return 0
+}
+
+func (x *Transaction) GetActingUserId() uint64 {
+	if x != nil && x.ActingUserId != nil {
+		return *x.ActingUserId
+	}
+	return 0
+}
+
+func (x *Transaction) GetAmountSatang() int64 {
+	if x != nil {
+		return x.AmountSatang
+	}
+	return 0
+}
+
+func (x *Transaction) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *Transaction) GetChannel() string {
+	if x != nil {
+		return x.Channel
+	}
+	return ""
+}
+
+func (x *Transaction) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Transaction) GetFailureCode() string {
+	if x != nil {
+		return x.FailureCode
+	}
+	return ""
+}
+
+func (x *Transaction) GetFailureMessage() string {
+	if x != nil {
+		return x.FailureMessage
+	}
+	return ""
+}
+
+func (x *Transaction) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Transaction) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+type CreateChargeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	AmountSatang  int64                  `protobuf:"varint,1,opt,name=amount_satang,json=amountSatang,proto3" json:"amount_satang,omitempty"`
+	Currency      string                 `protobuf:"bytes,2,opt,name=currency,proto3" json:"currency,omitempty"`                          // "thb"
+	PaymentType   string                 `protobuf:"bytes,3,opt,name=payment_type,json=paymentType,proto3" json:"payment_type,omitempty"` // "credit_card" | "promptpay" | "internet_banking"
+	Token         string                 `protobuf:"bytes,4,opt,name=token,proto3" json:"token,omitempty"`                                // card token (tokn_...) for credit_card
+	ReturnUri     string                 `protobuf:"bytes,5,opt,name=return_uri,json=returnUri,proto3" json:"return_uri,omitempty"`       // required for internet_banking and 3-D Secure
+	Description   string                 `protobuf:"bytes,6,opt,name=description,proto3" json:"description,omitempty"`
+	Metadata      map[string]string      `protobuf:"bytes,7,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Bank          string                 `protobuf:"bytes,8,opt,name=bank,proto3" json:"bank,omitempty"` // internet_banking: "bay" | "bbl" | "ktb" | "scb"
+	UserId        *uint64                `protobuf:"varint,9,opt,name=user_id,json=userId,proto3,oneof" json:"user_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateChargeRequest) Reset() {
+	*x = CreateChargeRequest{}
+	mi := &file_paymentsv1_payments_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateChargeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateChargeRequest) ProtoMessage() {}
+
+func (x *CreateChargeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_paymentsv1_payments_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateChargeRequest.ProtoReflect.Descriptor instead.
+func (*CreateChargeRequest) Descriptor() ([]byte, []int) {
+	return file_paymentsv1_payments_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CreateChargeRequest) GetAmountSatang() int64 {
+	if x != nil {
+		return x.AmountSatang
+	}
+	return 0
+}
+
+func (x *CreateChargeRequest) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *CreateChargeRequest) GetPaymentType() string {
+	if x != nil {
+		return x.PaymentType
+	}
+	return ""
+}
+
+func (x *CreateChargeRequest) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *CreateChargeRequest) GetReturnUri() string {
+	if x != nil {
+		return x.ReturnUri
+	}
+	return ""
+}
+
+func (x *CreateChargeRequest) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *CreateChargeRequest) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *CreateChargeRequest) GetBank() string {
+	if x != nil {
+		return x.Bank
+	}
+	return ""
+}
+
+func (x *CreateChargeRequest) GetUserId() uint64 {
+	if x != nil && x.UserId != nil {
+		return *x.UserId
+	}
+	return 0
+}
+
+type CreateChargeResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	ChargeId       string                 `protobuf:"bytes,1,opt,name=charge_id,json=chargeId,proto3" json:"charge_id,omitempty"`
+	Status         string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	AmountSatang   int64                  `protobuf:"varint,3,opt,name=amount_satang,json=amountSatang,proto3" json:"amount_satang,omitempty"`
+	Currency       string                 `protobuf:"bytes,4,opt,name=currency,proto3" json:"currency,omitempty"`
+	AuthorizeUri   string                 `protobuf:"bytes,5,opt,name=authorize_uri,json=authorizeUri,proto3" json:"authorize_uri,omitempty"` // redirect the payer here when set (3-D Secure, internet banking)
+	QrCodeUri      string                 `protobuf:"bytes,6,opt,name=qr_code_uri,json=qrCodeUri,proto3" json:"qr_code_uri,omitempty"`        // PromptPay QR image
+	FailureCode    string                 `protobuf:"bytes,7,opt,name=failure_code,json=failureCode,proto3" json:"failure_code,omitempty"`
+	FailureMessage string                 `protobuf:"bytes,8,opt,name=failure_message,json=failureMessage,proto3" json:"failure_message,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *CreateChargeResponse) Reset() {
+	*x = CreateChargeResponse{}
+	mi := &file_paymentsv1_payments_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateChargeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateChargeResponse) ProtoMessage() {}
+
+func (x *CreateChargeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_paymentsv1_payments_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateChargeResponse.ProtoReflect.Descriptor instead.
+func (*CreateChargeResponse) Descriptor() ([]byte, []int) {
+	return file_paymentsv1_payments_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CreateChargeResponse) GetChargeId() string {
+	if x != nil {
+		return x.ChargeId
+	}
+	return ""
+}
+
+func (x *CreateChargeResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *CreateChargeResponse) GetAmountSatang() int64 {
+	if x != nil {
+		return x.AmountSatang
+	}
+	return 0
+}
+
+func (x *CreateChargeResponse) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *CreateChargeResponse) GetAuthorizeUri() string {
+	if x != nil {
+		return x.AuthorizeUri
+	}
+	return ""
+}
+
+func (x *CreateChargeResponse) GetQrCodeUri() string {
+	if x != nil {
+		return x.QrCodeUri
+	}
+	return ""
+}
+
+func (x *CreateChargeResponse) GetFailureCode() string {
+	if x != nil {
+		return x.FailureCode
+	}
+	return ""
+}
+
+func (x *CreateChargeResponse) GetFailureMessage() string {
+	if x != nil {
+		return x.FailureMessage
+	}
+	return ""
+}
+
+type GetTransactionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"` // internal id or Omise charge id
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTransactionRequest) Reset() {
+	*x = GetTransactionRequest{}
+	mi := &file_paymentsv1_payments_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTransactionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTransactionRequest) ProtoMessage() {}
+
+func (x *GetTransactionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_paymentsv1_payments_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTransactionRequest.ProtoReflect.Descriptor instead.
+func (*GetTransactionRequest) Descriptor() ([]byte, []int) {
+	return file_paymentsv1_payments_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetTransactionRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type ListTransactionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        *uint64                `protobuf:"varint,1,opt,name=user_id,json=userId,proto3,oneof" json:"user_id,omitempty"`
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	Channel       string                 `protobuf:"bytes,3,opt,name=channel,proto3" json:"channel,omitempty"`
+	Limit         int32                  `protobuf:"varint,4,opt,name=limit,proto3" json:"limit,omitempty"` // default 20, max 100
+	Offset        int32                  `protobuf:"varint,5,opt,name=offset,proto3" json:"offset,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListTransactionsRequest) Reset() {
+	*x = ListTransactionsRequest{}
+	mi := &file_paymentsv1_payments_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListTransactionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListTransactionsRequest) ProtoMessage() {}
+
+func (x *ListTransactionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_paymentsv1_payments_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListTransactionsRequest.ProtoReflect.Descriptor instead.
+func (*ListTransactionsRequest) Descriptor() ([]byte, []int) {
+	return file_paymentsv1_payments_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListTransactionsRequest) GetUserId() uint64 {
+	if x != nil && x.UserId != nil {
+		return *x.UserId
+	}
+	return 0
+}
+
+func (x *ListTransactionsRequest) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *ListTransactionsRequest) GetChannel() string {
+	if x != nil {
+		return x.Channel
+	}
+	return ""
+}
+
+func (x *ListTransactionsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ListTransactionsRequest) GetOffset() int32 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+type ListTransactionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Transactions  []*Transaction         `protobuf:"bytes,1,rep,name=transactions,proto3" json:"transactions,omitempty"`
+	Total         int64                  `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListTransactionsResponse) Reset() {
+	*x = ListTransactionsResponse{}
+	mi := &file_paymentsv1_payments_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListTransactionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListTransactionsResponse) ProtoMessage() {}
+
+func (x *ListTransactionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_paymentsv1_payments_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListTransactionsResponse.ProtoReflect.Descriptor instead.
+func (*ListTransactionsResponse) Descriptor() ([]byte, []int) {
+	return file_paymentsv1_payments_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ListTransactionsResponse) GetTransactions() []*Transaction {
+	if x != nil {
+		return x.Transactions
+	}
+	return nil
+}
+
+func (x *ListTransactionsResponse) GetTotal() int64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+type RefundChargeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TransactionId string                 `protobuf:"bytes,1,opt,name=transaction_id,json=transactionId,proto3" json:"transaction_id,omitempty"` // internal id or Omise charge id
+	AmountSatang  int64                  `protobuf:"varint,2,opt,name=amount_satang,json=amountSatang,proto3" json:"amount_satang,omitempty"`   // 0 refunds whatever has not been refunded yet
+	Reason        string                 `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`                                    // recorded in the audit log
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RefundChargeRequest) Reset() {
+	*x = RefundChargeRequest{}
+	mi := &file_paymentsv1_payments_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RefundChargeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RefundChargeRequest) ProtoMessage() {}
+
+func (x *RefundChargeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_paymentsv1_payments_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RefundChargeRequest.ProtoReflect.Descriptor instead.
+func (*RefundChargeRequest) Descriptor() ([]byte, []int) {
+	return file_paymentsv1_payments_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *RefundChargeRequest) GetTransactionId() string {
+	if x != nil {
+		return x.TransactionId
+	}
+	return ""
+}
+
+func (x *RefundChargeRequest) GetAmountSatang() int64 {
+	if x != nil {
+		return x.AmountSatang
+	}
+	return 0
+}
+
+func (x *RefundChargeRequest) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type RefundChargeResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	RefundId       string                 `protobuf:"bytes,1,opt,name=refund_id,json=refundId,proto3" json:"refund_id,omitempty"`
+	AmountSatang   int64                  `protobuf:"varint,2,opt,name=amount_satang,json=amountSatang,proto3" json:"amount_satang,omitempty"`
+	RefundedSatang int64                  `protobuf:"varint,3,opt,name=refunded_satang,json=refundedSatang,proto3" json:"refunded_satang,omitempty"` // total refunded on the charge, this refund included
+	Transaction    *Transaction           `protobuf:"bytes,4,opt,name=transaction,proto3" json:"transaction,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *RefundChargeResponse) Reset() {
+	*x = RefundChargeResponse{}
+	mi := &file_paymentsv1_payments_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RefundChargeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RefundChargeResponse) ProtoMessage() {}
+
+func (x *RefundChargeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_paymentsv1_payments_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RefundChargeResponse.ProtoReflect.Descriptor instead.
+func (*RefundChargeResponse) Descriptor() ([]byte, []int) {
+	return file_paymentsv1_payments_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *RefundChargeResponse) GetRefundId() string {
+	if x != nil {
+		return x.RefundId
+	}
+	return ""
+}
+
+func (x *RefundChargeResponse) GetAmountSatang() int64 {
+	if x != nil {
+		return x.AmountSatang
+	}
+	return 0
+}
+
+func (x *RefundChargeResponse) GetRefundedSatang() int64 {
+	if x != nil {
+		return x.RefundedSatang
+	}
+	return 0
+}
+
+func (x *RefundChargeResponse) GetTransaction() *Transaction {
+	if x != nil {
+		return x.Transaction
+	}
+	return nil
+}
+
+type WatchTransactionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        *uint64                `protobuf:"varint,1,opt,name=user_id,json=userId,proto3,oneof" json:"user_id,omitempty"` // only this user's transactions
+	Id            string                 `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`                              // only this transaction (internal id or charge id)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchTransactionsRequest) Reset() {
+	*x = WatchTransactionsRequest{}
+	mi := &file_paymentsv1_payments_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchTransactionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchTransactionsRequest) ProtoMessage() {}
+
+func (x *WatchTransactionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_paymentsv1_payments_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchTransactionsRequest.ProtoReflect.Descriptor instead.
+func (*WatchTransactionsRequest) Descriptor() ([]byte, []int) {
+	return file_paymentsv1_payments_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *WatchTransactionsRequest) GetUserId() uint64 {
+	if x != nil && x.UserId != nil {
+		return *x.UserId
+	}
+	return 0
+}
+
+func (x *WatchTransactionsRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+var File_paymentsv1_payments_proto protoreflect.FileDescriptor
+
+const file_paymentsv1_payments_proto_rawDesc = "" +
+	"\n" +
+	"\x19paymentsv1/payments.proto\x12\x14tutorium.payments.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xd7\x03\n" +
+	"\vTransaction\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x1b\n" +
+	"\tcharge_id\x18\x02 \x01(\tR\bchargeId\x12\x1c\n" +
+	"\auser_id\x18\x03 \x01(\x04H\x00R\x06userId\x88\x01\x01\x12)\n" +
+	"\x0eacting_user_id\x18\x04 \x01(\x04H\x01R\factingUserId\x88\x01\x01\x12#\n" +
+	"\ramount_satang\x18\x05 \x01(\x03R\famountSatang\x12\x1a\n" +
+	"\bcurrency\x18\x06 \x01(\tR\bcurrency\x12\x18\n" +
+	"\achannel\x18\a \x01(\tR\achannel\x12\x16\n" +
+	"\x06status\x18\b \x01(\tR\x06status\x12!\n" +
+	"\ffailure_code\x18\t \x01(\tR\vfailureCode\x12'\n" +
+	"\x0ffailure_message\x18\n" +
+	" \x01(\tR\x0efailureMessage\x129\n" +
+	"\n" +
+	"created_at\x18\v \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\f \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAtB\n" +
+	"\n" +
+	"\b_user_idB\x11\n" +
+	"\x0f_acting_user_id\"\xa0\x03\n" +
+	"\x13CreateChargeRequest\x12#\n" +
+	"\ramount_satang\x18\x01 \x01(\x03R\famountSatang\x12\x1a\n" +
+	"\bcurrency\x18\x02 \x01(\tR\bcurrency\x12!\n" +
+	"\fpayment_type\x18\x03 \x01(\tR\vpaymentType\x12\x14\n" +
+	"\x05token\x18\x04 \x01(\tR\x05token\x12\x1d\n" +
+	"\n" +
+	"return_uri\x18\x05 \x01(\tR\treturnUri\x12 \n" +
+	"\vdescription\x18\x06 \x01(\tR\vdescription\x12S\n" +
+	"\bmetadata\x18\a \x03(\v27.tutorium.payments.v1.CreateChargeRequest.MetadataEntryR\bmetadata\x12\x12\n" +
+	"\x04bank\x18\b \x01(\tR\x04bank\x12\x1c\n" +
+	"\auser_id\x18\t \x01(\x04H\x00R\x06userId\x88\x01\x01\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\n" +
+	"\n" +
+	"\b_user_id\"\x9d\x02\n" +
+	"\x14CreateChargeResponse\x12\x1b\n" +
+	"\tcharge_id\x18\x01 \x01(\tR\bchargeId\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12#\n" +
+	"\ramount_satang\x18\x03 \x01(\x03R\famountSatang\x12\x1a\n" +
+	"\bcurrency\x18\x04 \x01(\tR\bcurrency\x12#\n" +
+	"\rauthorize_uri\x18\x05 \x01(\tR\fauthorizeUri\x12\x1e\n" +
+	"\vqr_code_uri\x18\x06 \x01(\tR\tqrCodeUri\x12!\n" +
+	"\ffailure_code\x18\a \x01(\tR\vfailureCode\x12'\n" +
+	"\x0ffailure_message\x18\b \x01(\tR\x0efailureMessage\"'\n" +
+	"\x15GetTransactionRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\xa3\x01\n" +
+	"\x17ListTransactionsRequest\x12\x1c\n" +
+	"\auser_id\x18\x01 \x01(\x04H\x00R\x06userId\x88\x01\x01\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12\x18\n" +
+	"\achannel\x18\x03 \x01(\tR\achannel\x12\x14\n" +
+	"\x05limit\x18\x04 \x01(\x05R\x05limit\x12\x16\n" +
+	"\x06offset\x18\x05 \x01(\x05R\x06offsetB\n" +
+	"\n" +
+	"\b_user_id\"w\n" +
+	"\x18ListTransactionsResponse\x12E\n" +
+	"\ftransactions\x18\x01 \x03(\v2!.tutorium.payments.v1.TransactionR\ftransactions\x12\x14\n" +
+	"\x05total\x18\x02 \x01(\x03R\x05total\"y\n" +
+	"\x13RefundChargeRequest\x12%\n" +
+	"\x0etransaction_id\x18\x01 \x01(\tR\rtransactionId\x12#\n" +
+	"\ramount_satang\x18\x02 \x01(\x03R\famountSatang\x12\x16\n" +
+	"\x06reason\x18\x03 \x01(\tR\x06reason\"\xc6\x01\n" +
+	"\x14RefundChargeResponse\x12\x1b\n" +
+	"\trefund_id\x18\x01 \x01(\tR\brefundId\x12#\n" +
+	"\ramount_satang\x18\x02 \x01(\x03R\famountSatang\x12'\n" +
+	"\x0frefunded_satang\x18\x03 \x01(\x03R\x0erefundedSatang\x12C\n" +
+	"\vtransaction\x18\x04 \x01(\v2!.tutorium.payments.v1.TransactionR\vtransaction\"T\n" +
+	"\x18WatchTransactionsRequest\x12\x1c\n" +
+	"\auser_id\x18\x01 \x01(\x04H\x00R\x06userId\x88\x01\x01\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02idB\n" +
+	"\n" +
+	"\b_user_id2\x9d\x04\n" +
+	"\x0ePaymentService\x12e\n" +
+	"\fCreateCharge\x12).tutorium.payments.v1.CreateChargeRequest\x1a*.tutorium.payments.v1.CreateChargeResponse\x12`\n" +
+	"\x0eGetTransaction\x12+.tutorium.payments.v1.GetTransactionRequest\x1a!.tutorium.payments.v1.Transaction\x12q\n" +
+	"\x10ListTransactions\x12-.tutorium.payments.v1.ListTransactionsRequest\x1a..tutorium.payments.v1.ListTransactionsResponse\x12e\n" +
+	"\fRefundCharge\x12).tutorium.payments.v1.RefundChargeRequest\x1a*.tutorium.payments.v1.RefundChargeResponse\x12h\n" +
+	"\x11WatchTransactions\x12..tutorium.payments.v1.WatchTransactionsRequest\x1a!.tutorium.payments.v1.Transaction0\x01BDZBgithub.com/a2n2k3p4/tutorium-backend/grpcapi/paymentsv1;paymentsv1b\x06proto3"
+
+var (
+	file_paymentsv1_payments_proto_rawDescOnce sync.Once
+	file_paymentsv1_payments_proto_rawDescData []byte
+)
+
+func file_paymentsv1_payments_proto_rawDescGZIP() []byte {
+	file_paymentsv1_payments_proto_rawDescOnce.Do(func() {
+		file_paymentsv1_payments_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_paymentsv1_payments_proto_rawDesc), len(file_paymentsv1_payments_proto_rawDesc)))
+	})
+	return file_paymentsv1_payments_proto_rawDescData
+}
+
+var file_paymentsv1_payments_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_paymentsv1_payments_proto_goTypes = []any{
+	(*Transaction)(nil),              // 0: tutorium.payments.v1.Transaction
+	(*CreateChargeRequest)(nil),      // 1: tutorium.payments.v1.CreateChargeRequest
+	(*CreateChargeResponse)(nil),     // 2: tutorium.payments.v1.CreateChargeResponse
+	(*GetTransactionRequest)(nil),    // 3: tutorium.payments.v1.GetTransactionRequest
+	(*ListTransactionsRequest)(nil),  // 4: tutorium.payments.v1.ListTransactionsRequest
+	(*ListTransactionsResponse)(nil), // 5: tutorium.payments.v1.ListTransactionsResponse
+	(*RefundChargeRequest)(nil),      // 6: tutorium.payments.v1.RefundChargeRequest
+	(*RefundChargeResponse)(nil),     // 7: tutorium.payments.v1.RefundChargeResponse
+	(*WatchTransactionsRequest)(nil), // 8: tutorium.payments.v1.WatchTransactionsRequest
+	nil,                              // 9: tutorium.payments.v1.CreateChargeRequest.MetadataEntry
+	(*timestamppb.Timestamp)(nil),    // 10: google.protobuf.Timestamp
+}
+var file_paymentsv1_payments_proto_depIdxs = []int32{
+	10, // 0: tutorium.payments.v1.Transaction.created_at:type_name -> google.protobuf.Timestamp
+	10, // 1: tutorium.payments.v1.Transaction.updated_at:type_name -> google.protobuf.Timestamp
+	9,  // 2: tutorium.payments.v1.CreateChargeRequest.metadata:type_name -> tutorium.payments.v1.CreateChargeRequest.MetadataEntry
+	0,  // 3: tutorium.payments.v1.ListTransactionsResponse.transactions:type_name -> tutorium.payments.v1.Transaction
+	0,  // 4: tutorium.payments.v1.RefundChargeResponse.transaction:type_name -> tutorium.payments.v1.Transaction
+	1,  // 5: tutorium.payments.v1.PaymentService.CreateCharge:input_type -> tutorium.payments.v1.CreateChargeRequest
+	3,  // 6: tutorium.payments.v1.PaymentService.GetTransaction:input_type -> tutorium.payments.v1.GetTransactionRequest
+	4,  // 7: tutorium.payments.v1.PaymentService.ListTransactions:input_type -> tutorium.payments.v1.ListTransactionsRequest
+	6,  // 8: tutorium.payments.v1.PaymentService.RefundCharge:input_type -> tutorium.payments.v1.RefundChargeRequest
+	8,  // 9: tutorium.payments.v1.PaymentService.WatchTransactions:input_type -> tutorium.payments.v1.WatchTransactionsRequest
+	2,  // 10: tutorium.payments.v1.PaymentService.CreateCharge:output_type -> tutorium.payments.v1.CreateChargeResponse
+	0,  // 11: tutorium.payments.v1.PaymentService.GetTransaction:output_type -> tutorium.payments.v1.Transaction
+	5,  // 12: tutorium.payments.v1.PaymentService.ListTransactions:output_type -> tutorium.payments.v1.ListTransactionsResponse
+	7,  // 13: tutorium.payments.v1.PaymentService.RefundCharge:output_type -> tutorium.payments.v1.RefundChargeResponse
+	0,  // 14: tutorium.payments.v1.PaymentService.WatchTransactions:output_type -> tutorium.payments.v1.Transaction
+	10, // [10:15] is the sub-list for method output_type
+	5,  // [5:10] is the sub-list for method input_type
+	5,  // [5:5] is the sub-list for extension type_name
+	5,  // [5:5] is the sub-list for extension extendee
+	0,  // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_paymentsv1_payments_proto_init() }
+func file_paymentsv1_payments_proto_init() {
+	if File_paymentsv1_payments_proto != nil {
+		return
+	}
+	file_paymentsv1_payments_proto_msgTypes[0].OneofWrappers = []any{}
+	file_paymentsv1_payments_proto_msgTypes[1].OneofWrappers = []any{}
+	file_paymentsv1_payments_proto_msgTypes[4].OneofWrappers = []any{}
+	file_paymentsv1_payments_proto_msgTypes[8].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_paymentsv1_payments_proto_rawDesc), len(file_paymentsv1_payments_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_paymentsv1_payments_proto_goTypes,
+		DependencyIndexes: file_paymentsv1_payments_proto_depIdxs,
+		MessageInfos:      file_paymentsv1_payments_proto_msgTypes,
+	}.Build()
+	File_paymentsv1_payments_proto = out.File
+	file_paymentsv1_payments_proto_goTypes = nil
+	file_paymentsv1_payments_proto_depIdxs = nil
+}
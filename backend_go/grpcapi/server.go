@@ -0,0 +1,326 @@
+// Package grpcapi serves the payments API over gRPC (paymentsv1/payments.proto) for internal tutorium
+// services that prefer protobuf contracts and streaming updates. It is another thin adapter over
+// service.PaymentService, like the Fiber handlers, so both surfaces share validation and recording.
+package grpcapi
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative paymentsv1/payments.proto
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/a2n2k3p4/tutorium-backend/grpcapi/paymentsv1"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/money"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"github.com/a2n2k3p4/tutorium-backend/service"
+	"github.com/go-playground/validator/v10"
+	omise "github.com/omise/omise-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server implements paymentsv1.PaymentServiceServer.
+type Server struct {
+	paymentsv1.UnimplementedPaymentServiceServer
+
+	Payments *service.PaymentService
+}
+
+// NewServer returns a gRPC server with the payments API registered. Every call must carry
+// "authorization: Bearer <token>" metadata; callers name themselves with "x-service-name", which is
+// recorded as the audit actor of refunds.
+func NewServer(payments *service.PaymentService, token string, opts ...grpc.ServerOption) *grpc.Server {
+	auth := tokenAuth(token)
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+			if err := auth(ctx); err != nil {
+				return nil, err
+			}
+			return next(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, next grpc.StreamHandler) error {
+			if err := auth(ss.Context()); err != nil {
+				return err
+			}
+			return next(srv, ss)
+		}),
+	)
+	s := grpc.NewServer(opts...)
+	paymentsv1.RegisterPaymentServiceServer(s, &Server{Payments: payments})
+	return s
+}
+
+// (helper for NewServer) check the bearer token in the call metadata; an empty token rejects every call.
+func tokenAuth(token string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		md, _ := metadata.FromIncomingContext(ctx)
+		for _, v := range md.Get("authorization") {
+			got, ok := strings.CutPrefix(v, "Bearer ")
+			if ok && token != "" && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1 {
+				return nil
+			}
+		}
+		return status.Error(codes.Unauthenticated, "missing or invalid bearer token")
+	}
+}
+
+func (s *Server) CreateCharge(ctx context.Context, in *paymentsv1.CreateChargeRequest) (*paymentsv1.CreateChargeResponse, error) {
+	req := models.PaymentRequest{
+		Amount:      in.GetAmountSatang(),
+		Currency:    in.GetCurrency(),
+		PaymentType: in.GetPaymentType(),
+		Token:       in.GetToken(),
+		ReturnURI:   in.GetReturnUri(),
+		Description: in.GetDescription(),
+		Bank:        in.GetBank(),
+		Force:       true, // duplicate detection is for payers double-tapping "Pay", not services
+	}
+	if len(in.GetMetadata()) > 0 {
+		req.Metadata = make(map[string]interface{}, len(in.GetMetadata()))
+		for k, v := range in.GetMetadata() {
+			req.Metadata[k] = v
+		}
+	}
+	if in.UserId != nil {
+		uid := uint(in.GetUserId())
+		req.UserID = &uid
+	}
+	if err := validateRequest(req); err != nil {
+		return nil, err
+	}
+
+	charge, err := s.Payments.CreateCharge(ctx, req, req.UserID)
+	if err != nil {
+		return nil, chargeStatus(err)
+	}
+	resp := &paymentsv1.CreateChargeResponse{
+		ChargeId:       charge.ID,
+		Status:         string(charge.Status),
+		AmountSatang:   charge.Amount,
+		Currency:       charge.Currency,
+		AuthorizeUri:   charge.AuthorizeURI,
+		FailureCode:    deref(charge.FailureCode),
+		FailureMessage: deref(charge.FailureMessage),
+	}
+	if src := charge.Source; src != nil && src.ScannableCode != nil && src.ScannableCode.Image != nil {
+		resp.QrCodeUri = src.ScannableCode.Image.DownloadURI
+	}
+	return resp, nil
+}
+
+func (s *Server) GetTransaction(ctx context.Context, in *paymentsv1.GetTransactionRequest) (*paymentsv1.Transaction, error) {
+	if in.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	txn, err := s.Payments.Transactions.Find(in.GetId())
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, "transaction not found")
+		}
+		return nil, status.Error(codes.Internal, "failed to retrieve transaction")
+	}
+	return transactionProto(*txn), nil
+}
+
+func (s *Server) ListTransactions(ctx context.Context, in *paymentsv1.ListTransactionsRequest) (*paymentsv1.ListTransactionsResponse, error) {
+	f := repository.TransactionFilter{Status: in.GetStatus(), Channel: in.GetChannel()}
+	if in.UserId != nil {
+		f.UserID = fmt.Sprintf("%d", in.GetUserId())
+	}
+	// Same paging as GET /api/v1/payments/transactions.
+	limit, offset := s.Payments.PageSize.Limit(int(in.GetLimit())), 0
+	if in.GetOffset() > 0 {
+		offset = int(in.GetOffset())
+	}
+
+	transactions, total, err := s.Payments.Transactions.List(f, limit, offset)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve transactions")
+	}
+	resp := &paymentsv1.ListTransactionsResponse{Total: total}
+	for _, t := range transactions {
+		resp.Transactions = append(resp.Transactions, transactionProto(t))
+	}
+	return resp, nil
+}
+
+func (s *Server) RefundCharge(ctx context.Context, in *paymentsv1.RefundChargeRequest) (*paymentsv1.RefundChargeResponse, error) {
+	if in.GetTransactionId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "transaction_id is required")
+	}
+	res, err := s.Payments.RefundCharge(ctx, service.RefundChargeInput{
+		TransactionID: in.GetTransactionId(),
+		AmountSatang:  in.GetAmountSatang(),
+		Reason:        in.GetReason(),
+		Actor:         callerActor(ctx),
+	})
+	if err != nil {
+		var inErr *service.InputError
+		var oerr *omise.Error
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			return nil, status.Error(codes.NotFound, "transaction not found")
+		case errors.As(err, &inErr), errors.As(err, &oerr):
+			return nil, chargeStatus(err)
+		}
+		// Not Unavailable: the refund may have gone through on Omise without being recorded.
+		return nil, status.Error(codes.Internal, "failed to refund charge; check the transaction before retrying")
+	}
+	return &paymentsv1.RefundChargeResponse{
+		RefundId:       res.Refund.ID,
+		AmountSatang:   res.AmountSatang,
+		RefundedSatang: res.RefundedSatang,
+		Transaction:    transactionProto(*res.Transaction),
+	}, nil
+}
+
+func (s *Server) WatchTransactions(in *paymentsv1.WatchTransactionsRequest, stream grpc.ServerStreamingServer[paymentsv1.Transaction]) error {
+	updates, cancel, ok := s.Payments.Updates.Subscribe()
+	if !ok {
+		return status.Error(codes.Unavailable, "server is shutting down")
+	}
+	defer cancel()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case t, open := <-updates:
+			if !open {
+				return status.Error(codes.Unavailable, "server is shutting down")
+			}
+			if !watchMatches(in, t) {
+				continue
+			}
+			if err := stream.Send(transactionProto(t)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// (helper for WatchTransactions) whether t passes the request's filters.
+func watchMatches(in *paymentsv1.WatchTransactionsRequest, t models.Transaction) bool {
+	if in.UserId != nil && (t.UserID == nil || uint64(*t.UserID) != in.GetUserId()) {
+		return false
+	}
+	if id := in.GetId(); id != "" && id != t.ChargeID && id != fmt.Sprintf("%d", t.ID) {
+		return false
+	}
+	return true
+}
+
+// chargeStatus maps service errors like the REST chargeError: input problems -> InvalidArgument,
+// Omise rejections -> FailedPrecondition with Omise's message, anything else -> Unavailable.
+func chargeStatus(err error) error {
+	var inErr *service.InputError
+	if errors.As(err, &inErr) {
+		return status.Errorf(codes.InvalidArgument, "%s: %s", inErr.Code, inErr.Message)
+	}
+	var limErr *service.LimitError
+	if errors.As(err, &limErr) {
+		return status.Errorf(codes.ResourceExhausted, "%s: %s", limErr.Code, limErr.Message)
+	}
+	var dupErr *service.DuplicateChargeError
+	if errors.As(err, &dupErr) {
+		return status.Error(codes.AlreadyExists, "duplicate_charge: "+dupErr.Error())
+	}
+	if errors.Is(err, service.ErrPayerBlocked) {
+		return status.Error(codes.PermissionDenied, "payer_blocked: payments from this account, card or email are not accepted")
+	}
+	if errors.Is(err, service.ErrCountryNotAllowed) {
+		return status.Error(codes.PermissionDenied, "country_not_allowed: payments are not accepted from this country")
+	}
+	if errors.Is(err, service.ErrRiskDenied) {
+		return status.Error(codes.PermissionDenied, "risk_denied: this payment cannot be accepted")
+	}
+	var oerr *omise.Error
+	if errors.As(err, &oerr) && oerr.StatusCode >= 400 && oerr.StatusCode < 500 {
+		return status.Error(codes.FailedPrecondition, oerr.Message)
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+	return status.Error(codes.Unavailable, "payment provider unavailable")
+}
+
+// (helper for RefundCharge) the audit actor for the calling service, from its x-service-name metadata.
+func callerActor(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if names := md.Get("x-service-name"); len(names) > 0 && names[0] != "" {
+		return "service:" + names[0]
+	}
+	return "service:grpc"
+}
+
+func transactionProto(t models.Transaction) *paymentsv1.Transaction {
+	pt := &paymentsv1.Transaction{
+		Id:             uint64(t.ID),
+		ChargeId:       t.ChargeID,
+		AmountSatang:   t.AmountSatang,
+		Currency:       t.Currency,
+		Channel:        t.Channel,
+		Status:         t.Status,
+		FailureCode:    deref(t.FailureCode),
+		FailureMessage: deref(t.FailureMessage),
+		CreatedAt:      timestamppb.New(t.CreatedAt),
+		UpdatedAt:      timestamppb.New(t.UpdatedAt),
+	}
+	if t.UserID != nil {
+		uid := uint64(*t.UserID)
+		pt.UserId = &uid
+	}
+	if t.ActingUserID != nil {
+		uid := uint64(*t.ActingUserID)
+		pt.ActingUserId = &uid
+	}
+	return pt
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// ---------------------- validation ----------------------
+
+// validate enforces the models.PaymentRequest validate tags, as parseAndValidate does for REST.
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	_ = v.RegisterValidation("currency", func(fl validator.FieldLevel) bool {
+		return money.Supported(fl.Field().String())
+	})
+	_ = v.RegisterValidation("charge_amount", func(fl validator.FieldLevel) bool {
+		limits, ok := money.ChargeLimits(fl.Parent().FieldByName("Currency").String())
+		return !ok || limits.Contains(fl.Field().Int())
+	})
+	return v
+}
+
+// (helper for CreateCharge) InvalidArgument listing every failing field, nil if req is valid.
+func validateRequest(req models.PaymentRequest) error {
+	err := validate.Struct(req)
+	if err == nil {
+		return nil
+	}
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	fields := make([]string, 0, len(verrs))
+	for _, fe := range verrs {
+		fields = append(fields, fmt.Sprintf("%s (%s)", fe.Field(), fe.Tag()))
+	}
+	return status.Errorf(codes.InvalidArgument, "invalid charge request: %s", strings.Join(fields, ", "))
+}
@@ -0,0 +1,139 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/gateway/gatewaytest"
+	"github.com/a2n2k3p4/tutorium-backend/grpcapi/paymentsv1"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// (test helper) serve the API in memory and return a client for it.
+func newTestClient(t *testing.T, payments *service.PaymentService) paymentsv1.PaymentServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := NewServer(payments, "secret")
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc.NewClient: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return paymentsv1.NewPaymentServiceClient(conn)
+}
+
+func withToken(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+func TestRequiresBearerToken(t *testing.T) {
+	client := newTestClient(t, service.NewPaymentService(nil, gatewaytest.NewFake()))
+	for name, ctx := range map[string]context.Context{
+		"none":  context.Background(),
+		"wrong": withToken(context.Background(), "nope"),
+	} {
+		_, err := client.GetTransaction(ctx, &paymentsv1.GetTransactionRequest{Id: "1"})
+		if status.Code(err) != codes.Unauthenticated {
+			t.Errorf("%s token: err = %v, want Unauthenticated", name, err)
+		}
+	}
+}
+
+func TestCreateChargeValidatesBeforeCallingOmise(t *testing.T) {
+	fake := gatewaytest.NewFake()
+	client := newTestClient(t, service.NewPaymentService(nil, fake))
+	ctx := withToken(context.Background(), "secret")
+
+	cases := map[string]*paymentsv1.CreateChargeRequest{
+		"amount too small": {AmountSatang: 100, Currency: "thb", PaymentType: "promptpay"},
+		"currency":         {AmountSatang: 50000, Currency: "usd", PaymentType: "promptpay"},
+		"missing bank":     {AmountSatang: 50000, Currency: "thb", PaymentType: "internet_banking", ReturnUri: "https://app.tutorium.io/cb"},
+	}
+	for name, req := range cases {
+		if _, err := client.CreateCharge(ctx, req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%s: err = %v, want InvalidArgument", name, err)
+		}
+	}
+	if calls := fake.Calls(); len(calls) != 0 {
+		t.Errorf("Omise was called for invalid requests: %v", calls)
+	}
+}
+
+func TestWatchTransactionsFiltersByUser(t *testing.T) {
+	payments := service.NewPaymentService(nil, gatewaytest.NewFake())
+	client := newTestClient(t, payments)
+	ctx, cancel := context.WithTimeout(withToken(context.Background(), "secret"), 5*time.Second)
+	defer cancel()
+
+	uid := uint64(7)
+	stream, err := client.WatchTransactions(ctx, &paymentsv1.WatchTransactionsRequest{UserId: &uid})
+	if err != nil {
+		t.Fatalf("WatchTransactions: %v", err)
+	}
+
+	// The subscription is registered asynchronously; publish until the stream sees something.
+	other, mine := uint(8), uint(7)
+	go func() {
+		tick := time.NewTicker(10 * time.Millisecond)
+		defer tick.Stop()
+		for {
+			payments.Updates.Publish(models.Transaction{ID: 1, ChargeID: "chrg_other", UserID: &other, Status: "successful"})
+			payments.Updates.Publish(models.Transaction{ID: 2, ChargeID: "chrg_mine", UserID: &mine, Status: "successful"})
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+			}
+		}
+	}()
+
+	got, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+	if got.GetChargeId() != "chrg_mine" || got.GetUserId() != 7 {
+		t.Errorf("got %s for user %d, want chrg_mine for user 7", got.GetChargeId(), got.GetUserId())
+	}
+
+	payments.Updates.Close()
+	for {
+		if _, err := stream.Recv(); err != nil {
+			if status.Code(err) != codes.Unavailable {
+				t.Errorf("after Close: err = %v, want Unavailable", err)
+			}
+			break
+		}
+	}
+}
+
+func TestChargeStatus(t *testing.T) {
+	cases := []struct {
+		err  error
+		want codes.Code
+	}{
+		{&service.InputError{Code: "raw_card_disabled", Message: "no"}, codes.InvalidArgument},
+		{gatewaytest.Declined("insufficient_fund"), codes.FailedPrecondition},
+		{context.DeadlineExceeded, codes.DeadlineExceeded},
+		{errors.New("connection reset"), codes.Unavailable},
+	}
+	for _, tc := range cases {
+		if got := status.Code(chargeStatus(tc.err)); got != tc.want {
+			t.Errorf("chargeStatus(%v) = %s, want %s", tc.err, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+// admin_transaction_handler.go contains /admin handlers for individual transactions.
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/apiv1"
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/gateway"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/money"
+	"github.com/a2n2k3p4/tutorium-backend/objectstore"
+	"github.com/a2n2k3p4/tutorium-backend/rawpayload"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"github.com/a2n2k3p4/tutorium-backend/service"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// GetRawPayload returns the stored (masked) charge payload, decrypting it transparently; a payload
+// pruned to the archive (RAW_PAYLOAD_ARCHIVE_BUCKET) is read back from there, and says so.
+func (h *PaymentHandler) GetRawPayload(c *fiber.Ctx) error {
+	tx, err := h.Transactions.WithContext(c.UserContext()).Find(c.Params("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperrors.ErrNotFound.WithMessage("Transaction not found")
+		}
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve transaction").Wrap(err)
+	}
+	sealed, err := h.Payments.RawPayload(c.UserContext(), tx)
+	if errors.Is(err, objectstore.ErrNotFound) {
+		return apperrors.ErrNotFound.WithMessage("the archived raw payload of this transaction is missing")
+	}
+	if err != nil {
+		return apperrors.ErrUnavailable.WithMessage("Failed to read archived raw payload").Wrap(err)
+	}
+	if len(sealed) == 0 {
+		return apperrors.ErrNotFound.WithMessage("no raw payload stored for this transaction")
+	}
+
+	plain, err := h.Payments.PayloadCipher.Decrypt(sealed)
+	if err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to decrypt raw payload").Wrap(err)
+	}
+	archived := len(tx.RawPayload) == 0
+	h.audit(auditEntry(c, models.AuditRawPayloadView, "transaction", fmt.Sprintf("%d", tx.ID), nil, nil))
+	return c.JSON(fiber.Map{
+		"id":        tx.ID,
+		"charge_id": tx.ChargeID,
+		"encrypted": rawpayload.IsEncrypted(sealed),
+		"archived":  archived,
+		"payload":   json.RawMessage(plain),
+	})
+}
+
+// SyncTransaction re-reads one charge from Omise and records it like a webhook would (see
+// service.RecordCharge), for when a webhook was missed and support needs the record fixed now rather
+// than at the next reconciliation. Admin only; audited with the status before and after. Legacy
+// imported transactions have no Omise charge to read.
+func (h *PaymentHandler) SyncTransaction(c *fiber.Ctx) error {
+	repo := h.Transactions.WithContext(c.UserContext())
+	t, err := repo.Find(c.Params("id"))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperrors.ErrNotFound.WithMessage("Transaction not found")
+		}
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve transaction").Wrap(err)
+	}
+	if strings.HasPrefix(t.ChargeID, legacyChargeID("")) {
+		return apperrors.ErrConflict.WithMessage("imported legacy transactions have no Omise charge to sync")
+	}
+
+	ctx := gateway.WithMerchant(c.UserContext(), t.MerchantID)
+	ch, err := h.Omise.RetrieveCharge(ctx, t.ChargeID)
+	if err != nil {
+		return chargeError(err)
+	}
+	if err := h.Payments.RecordCharge(service.WithEventSource(ctx, models.TransactionEventSync), ch, t.UserID); err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to record charge").Wrap(err)
+	}
+	synced, err := repo.Get(t.ID)
+	if err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve transaction").Wrap(err)
+	}
+	h.audit(auditEntry(c, models.AuditTransactionSync, "transaction", fmt.Sprintf("%d", t.ID),
+		fiber.Map{"status": t.Status}, fiber.Map{"status": synced.Status, "charge_id": synced.ChargeID}))
+	return c.JSON(apiv1.FromTransaction(*synced, responseLang(c)))
+}
+
+type statusOverrideRequest struct {
+	Status string `json:"status" validate:"required,oneof=pending successful failed expired canceled reversed"`
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// OverrideTransactionStatus forces the status of transaction :id (internal or charge id), e.g. to
+// successful after the bank confirmed a transfer offline, and credits the wallet as a webhook would
+// (see service.OverrideStatus). The reason is mandatory and kept in the audit log. Admin only; 409
+// status_unchanged when the transaction already has the status.
+//
+//	PATCH /api/v1/admin/transactions/42/status {"status": "successful", "reason": "KBank slip 0192 confirmed by phone"}
+func (h *PaymentHandler) OverrideTransactionStatus(c *fiber.Ctx) error {
+	var req statusOverrideRequest
+	if err := parseAndValidate(c, &req); err != nil {
+		return err
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		return apperrors.ErrValidation.WithMessage("reason must not be blank")
+	}
+	t, err := h.Transactions.WithContext(c.UserContext()).Find(c.Params("id"))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperrors.ErrNotFound.WithMessage("Transaction not found")
+		}
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve transaction").Wrap(err)
+	}
+	by := auditEntry(c, models.AuditStatusOverride, "transaction", fmt.Sprintf("%d", t.ID), nil, nil)
+	by.Reason = strings.TrimSpace(req.Reason)
+	overridden, err := h.Payments.OverrideStatus(c.UserContext(), t.ChargeID, req.Status, by)
+	if err != nil {
+		var inErr *service.InputError
+		if errors.As(err, &inErr) {
+			return apperrors.ErrConflict.WithCode(inErr.Code).WithMessage(inErr.Message)
+		}
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperrors.ErrNotFound.WithMessage("Transaction not found")
+		}
+		return apperrors.ErrInternal.WithMessage("Failed to override transaction status").Wrap(err)
+	}
+	return c.JSON(apiv1.FromTransaction(*overridden, responseLang(c)))
+}
+
+// statusAt is one entry of a transaction's status history.
+type statusAt struct {
+	At     time.Time `json:"at"`
+	Status string    `json:"status"`
+}
+
+// balanceDelta is how one ledger entry moved a user's balances, in satang.
+type balanceDelta struct {
+	Balance, Held, Frozen int64
+}
+
+// GetTransactionAsOf reconstructs the transaction and its user's wallet at ?ts= (RFC3339): the status
+// from the status history (transaction.status_change audit entries), the amount refunded so far, and
+// the balances obtained by rolling the current balances back through every later ledger entry.
+// Transactions recorded before status history existed report their current status (status_source
+// "current").
+func (h *PaymentHandler) GetTransactionAsOf(c *fiber.Ctx) error {
+	ts, err := time.Parse(time.RFC3339, c.Query("ts"))
+	if err != nil {
+		return apperrors.ErrValidation.WithMessage("ts must be RFC3339 (e.g. 2025-01-31T14:05:00+07:00)")
+	}
+	txn, err := h.Transactions.WithContext(c.UserContext()).Find(c.Params("id"))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperrors.ErrNotFound.WithMessage("Transaction not found")
+		}
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve transaction").Wrap(err)
+	}
+	if txn.CreatedAt.After(ts) {
+		return apperrors.ErrNotFound.WithMessagef("transaction %d did not exist yet at %s (created %s)",
+			txn.ID, ts.Format(time.RFC3339), txn.CreatedAt.Format(time.RFC3339))
+	}
+	txnID := fmt.Sprintf("%d", txn.ID)
+
+	var changes []models.AuditLog
+	if err := h.db(c).Where("action = ? AND entity_type = ? AND entity_id = ?", models.AuditStatusChange, "transaction", txnID).
+		Order("created_at, id").Find(&changes).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve status history").Wrap(err)
+	}
+	history, status, source := statusAsOf(changes, ts, txn.Status)
+
+	var refunded int64
+	if err := h.db(c).Model(&models.AuditLog{}).
+		Select("COALESCE(SUM((after->>'amount_satang')::bigint), 0)").
+		Where("action = ? AND entity_id = ? AND created_at <= ?", models.AuditRefund, txnID, ts).
+		Scan(&refunded).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve refunds").Wrap(err)
+	}
+
+	resp := fiber.Map{
+		"as_of": ts,
+		"transaction": fiber.Map{
+			"id":              txn.ID,
+			"charge_id":       txn.ChargeID,
+			"user_id":         txn.UserID,
+			"amount_satang":   txn.AmountSatang,
+			"currency":        txn.Currency,
+			"channel":         txn.Channel,
+			"status":          status,
+			"status_source":   source,
+			"refunded_satang": refunded,
+			"current_status":  txn.Status,
+		},
+		"status_history": history,
+		"balance":        nil,
+	}
+	if txn.UserID != nil {
+		balance, err := h.balanceAsOf(*txn.UserID, ts)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return apperrors.ErrInternal.WithMessage("Failed to reconstruct balance").Wrap(err)
+		}
+		if err == nil {
+			resp["balance"] = balance
+		}
+	}
+	return c.JSON(resp)
+}
+
+// (helper for GetTransactionAsOf) the status history up to ts and the status in effect at ts. Before
+// the first recorded change the status is that change's "before" (or unknown for a first insert);
+// without any history it falls back to the current status.
+func statusAsOf(changes []models.AuditLog, ts time.Time, current string) (history []statusAt, status, source string) {
+	history = []statusAt{}
+	if len(changes) == 0 {
+		return history, current, "current"
+	}
+	for _, ch := range changes {
+		if ch.CreatedAt.After(ts) {
+			break
+		}
+		var after struct {
+			Status string `json:"status"`
+		}
+		_ = json.Unmarshal(ch.After, &after)
+		history = append(history, statusAt{At: ch.CreatedAt, Status: after.Status})
+	}
+	if len(history) > 0 {
+		return history, history[len(history)-1].Status, "history"
+	}
+	var before struct {
+		Status string `json:"status"`
+	}
+	_ = json.Unmarshal(changes[0].Before, &before)
+	if before.Status == "" {
+		return history, current, "current"
+	}
+	return history, before.Status, "history"
+}
+
+// (helper for GetTransactionAsOf) the user's balances at ts: current balances minus the effect of
+// every balance.* ledger entry written after ts (entries commit with the balance change they record).
+func (h *PaymentHandler) balanceAsOf(userID uint, ts time.Time) (fiber.Map, error) {
+	user, err := h.Users.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+	var later []models.AuditLog
+	if err := h.DB.Where("entity_type = ? AND entity_id = ? AND action LIKE ? AND created_at > ?", "user", fmt.Sprintf("%d", userID), "balance.%", ts).
+		Find(&later).Error; err != nil {
+		return nil, err
+	}
+	balance := money.FromMajor(user.Balance, money.THB).Amount
+	held := money.FromMajor(user.HeldBalance, money.THB).Amount
+	frozen := money.FromMajor(user.FrozenBalance, money.THB).Amount
+	for _, entry := range later {
+		d := ledgerDelta(entry)
+		balance, held, frozen = balance-d.Balance, held-d.Held, frozen-d.Frozen
+	}
+	return fiber.Map{
+		"user_id":                userID,
+		"balance":                money.New(balance, money.THB).Major(),
+		"held_balance":           money.New(held, money.THB).Major(),
+		"frozen_balance":         money.New(frozen, money.THB).Major(),
+		"ledger_entries_unwound": len(later),
+	}, nil
+}
+
+// ledgerDelta is the change a balance.* audit entry recorded, in satang. Charge credits carry
+// credited_thb; wallet operations carry amount_satang; dispute freezes carry frozen_satang.
+func ledgerDelta(entry models.AuditLog) balanceDelta {
+	var before, after struct {
+		ChargeID          string  `json:"charge_id"`
+		CreditedTHB       float64 `json:"credited_thb"`
+		AmountSatang      int64   `json:"amount_satang"`
+		FrozenSatang      int64   `json:"frozen_satang"`
+		ReturnedToBalance bool    `json:"returned_to_balance"`
+	}
+	_ = json.Unmarshal(entry.Before, &before)
+	_ = json.Unmarshal(entry.After, &after)
+
+	switch entry.Action {
+	case models.AuditBalanceCredit:
+		if after.ChargeID != "" {
+			return balanceDelta{Balance: money.FromMajor(after.CreditedTHB, money.THB).Amount}
+		}
+		return balanceDelta{Balance: after.AmountSatang}
+	case models.AuditBalanceDebit:
+		return balanceDelta{Balance: -after.AmountSatang}
+	case models.AuditBalanceHold:
+		return balanceDelta{Balance: -after.AmountSatang, Held: after.AmountSatang}
+	case models.AuditBalanceRelease:
+		return balanceDelta{Balance: after.AmountSatang, Held: -after.AmountSatang}
+	case models.AuditBalanceCapture:
+		return balanceDelta{Held: -after.AmountSatang}
+	case models.AuditBalanceFreeze:
+		return balanceDelta{Balance: -after.FrozenSatang, Frozen: after.FrozenSatang}
+	case models.AuditBalanceUnfreeze:
+		d := balanceDelta{Frozen: -before.FrozenSatang}
+		if after.ReturnedToBalance {
+			d.Balance = before.FrozenSatang
+		}
+		return d
+	}
+	return balanceDelta{}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/datatypes"
+)
+
+func TestLedgerDelta(t *testing.T) {
+	entry := func(action, before, after string) models.AuditLog {
+		e := models.AuditLog{Action: action, After: datatypes.JSON(after)}
+		if before != "" {
+			e.Before = datatypes.JSON(before)
+		}
+		return e
+	}
+	cases := []struct {
+		name  string
+		entry models.AuditLog
+		want  balanceDelta
+	}{
+		{"charge credit", entry(models.AuditBalanceCredit, "", `{"charge_id":"chrg_1","credited_thb":100.5}`), balanceDelta{Balance: 10050}},
+		{"wallet credit", entry(models.AuditBalanceCredit, "", `{"operation_id":"op1","amount_satang":500}`), balanceDelta{Balance: 500}},
+		{"debit", entry(models.AuditBalanceDebit, "", `{"amount_satang":300}`), balanceDelta{Balance: -300}},
+		{"hold", entry(models.AuditBalanceHold, "", `{"amount_satang":200}`), balanceDelta{Balance: -200, Held: 200}},
+		{"release", entry(models.AuditBalanceRelease, `{"hold_status":"active"}`, `{"amount_satang":200}`), balanceDelta{Balance: 200, Held: -200}},
+		{"capture", entry(models.AuditBalanceCapture, `{"hold_status":"active"}`, `{"amount_satang":200}`), balanceDelta{Held: -200}},
+		{"freeze", entry(models.AuditBalanceFreeze, `{"balance":10}`, `{"frozen_satang":700}`), balanceDelta{Balance: -700, Frozen: 700}},
+		{"unfreeze refunded", entry(models.AuditBalanceUnfreeze, `{"frozen_satang":700}`, `{"returned_to_balance":false}`), balanceDelta{Frozen: -700}},
+		{"unfreeze rejected", entry(models.AuditBalanceUnfreeze, `{"frozen_satang":700}`, `{"returned_to_balance":true}`), balanceDelta{Balance: 700, Frozen: -700}},
+	}
+	for _, tc := range cases {
+		if got := ledgerDelta(tc.entry); got != tc.want {
+			t.Errorf("%s: delta = %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestStatusAsOf(t *testing.T) {
+	t0 := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	changes := []models.AuditLog{
+		{CreatedAt: t0, After: datatypes.JSON(`{"status":"pending"}`)},
+		{CreatedAt: t0.Add(time.Minute), Before: datatypes.JSON(`{"status":"pending"}`), After: datatypes.JSON(`{"status":"successful"}`)},
+	}
+	if _, status, _ := statusAsOf(changes, t0.Add(30*time.Second), "successful"); status != "pending" {
+		t.Errorf("mid-way status = %q, want pending", status)
+	}
+	if history, status, _ := statusAsOf(changes, t0.Add(time.Hour), "successful"); status != "successful" || len(history) != 2 {
+		t.Errorf("later status = %q (%d entries), want successful (2)", status, len(history))
+	}
+	// History that starts mid-life (recorded after the transaction existed) uses the first "before".
+	if _, status, source := statusAsOf(changes[1:], t0, "successful"); status != "pending" || source != "history" {
+		t.Errorf("before first change = %q/%q, want pending/history", status, source)
+	}
+	if _, status, source := statusAsOf(nil, t0, "failed"); status != "failed" || source != "current" {
+		t.Errorf("no history = %q/%q, want failed/current", status, source)
+	}
+}
+
+func TestOverrideTransactionStatusValidated(t *testing.T) {
+	h := NewPaymentHandler(nil, nil)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Patch("/admin/transactions/:id/status", h.OverrideTransactionStatus)
+
+	for name, body := range map[string]string{
+		"no reason":      `{"status": "successful"}`,
+		"blank reason":   `{"status": "successful", "reason": "   "}`,
+		"no status":      `{"reason": "bank confirmed by phone"}`,
+		"unknown status": `{"status": "paid", "reason": "bank confirmed by phone"}`,
+	} {
+		req := httptest.NewRequest("PATCH", "/admin/transactions/42/status", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", name, resp.StatusCode)
+		}
+	}
+}
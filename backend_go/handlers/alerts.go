@@ -0,0 +1,47 @@
+// alerts.go delivers operational alerts to admins (Slack, Discord and/or email).
+package handlers
+
+import (
+	"log"
+
+	"github.com/a2n2k3p4/tutorium-backend/notify"
+)
+
+// AlertTargets lists where admin alerts go; empty targets are skipped.
+type AlertTargets struct {
+	SlackWebhookURL   string
+	DiscordWebhookURL string
+	Emails            []string
+}
+
+// sendAdminAlert delivers msg to every configured target; failures are logged, not returned,
+// because alerts are best-effort and never block the operation that raised them.
+func (h *PaymentHandler) sendAdminAlert(msg notify.Message) {
+	delivered := false
+	if h.Alerts.SlackWebhookURL != "" {
+		if err := notify.PostSlack(h.Alerts.SlackWebhookURL, msg); err != nil {
+			log.Printf("alerts: slack delivery failed subject=%q err=%v", msg.Subject, err)
+		} else {
+			delivered = true
+		}
+	}
+	if h.Alerts.DiscordWebhookURL != "" {
+		if err := notify.PostDiscord(h.Alerts.DiscordWebhookURL, msg); err != nil {
+			log.Printf("alerts: discord delivery failed subject=%q err=%v", msg.Subject, err)
+		} else {
+			delivered = true
+		}
+	}
+	if h.Mailer.Enabled() {
+		for _, to := range h.Alerts.Emails {
+			if err := h.Mailer.Send(to, msg); err != nil {
+				log.Printf("alerts: email delivery failed to=%s subject=%q err=%v", to, msg.Subject, err)
+			} else {
+				delivered = true
+			}
+		}
+	}
+	if !delivered {
+		log.Printf("alerts: no target delivered subject=%q body=%q", msg.Subject, msg.Body)
+	}
+}
@@ -0,0 +1,230 @@
+// allroutes.go registers every HTTP route served by the payment backend.
+package handlers
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/gofiber/fiber/v2"
+	expvarmw "github.com/gofiber/fiber/v2/middleware/expvar"
+	"github.com/gofiber/fiber/v2/middleware/pprof"
+)
+
+// apiVersions lists the versioned APIs, each mounted under its own prefix. Response bodies are the
+// version's DTOs (package apiv1 for /api/v1). A new version (e.g. /api/v2 renaming fields) gets its
+// own register function and DTOs, reusing the handlers whose shape is unchanged and swapping in new
+// ones; older versions keep serving their shapes untouched.
+var apiVersions = []struct {
+	prefix   string
+	register func(r fiber.Router, h *PaymentHandler)
+}{
+	{"/api/v1", registerV1Routes},
+}
+
+// legacyPrefixes are the pre-versioning paths, still served as /api/v1 by legacyPathShim.
+var legacyPrefixes = []string{"/payments", "/webhooks", "/users", "/institutions", "/admin"}
+
+// RegisterRoutes wires the payment handler into the Fiber app: probes and diagnostics at the root,
+// the API under /api/v<n>, and the unversioned legacy paths as aliases of /api/v1.
+// Note: app.Get also registers HEAD for the same path, so every read endpoint
+// answers HEAD with the same status/headers and an empty body (used by uptime checkers).
+func RegisterRoutes(app *fiber.App, h *PaymentHandler) {
+	app.Use(legacyPathShim("/api/v1"))
+
+	app.Get("/health", h.Health)
+	app.Get("/health/live", h.Live)
+	app.Get("/health/ready", h.Ready)
+
+	for _, v := range apiVersions {
+		v.register(app.Group(v.prefix, h.IdentifyConsumer, h.Deadline, h.IdentifyMerchant), h)
+	}
+
+	// Runtime diagnostics: expvar at /debug/vars and Go profiles at /debug/pprof/. CPU profiles and
+	// traces are bounded by HTTP_WRITE_TIMEOUT; use ?seconds=10 or DEBUG_LISTEN_ADDR for longer ones.
+	debug := app.Group("/debug", h.RequireAdmin)
+	debug.Get("/vars", expvarmw.New())
+	debug.Use(pprof.New())
+
+	// Must be registered last: catches anything no route above handled.
+	app.Use(MethodNotAllowed(app))
+}
+
+// registerV1Routes registers the v1 API on r (mounted at /api/v1).
+func registerV1Routes(r fiber.Router, h *PaymentHandler) {
+	r.Post("/payments/charge", h.Shed(true), h.CreateCharge)
+	r.Post("/payments/charge/:id/cancel", h.CancelCharge)
+	r.Get("/payments/transactions", h.ListTransactions)
+	r.Post("/payments/transactions/batch", h.BatchTransactionStatus)
+	r.Get("/payments/stats", h.Shed(false), h.GetPaymentStats)
+	r.Get("/payments/quote", h.GetQuote)
+	r.Get("/payments/transactions/:id", h.GetTransaction)
+	r.Get("/payments/transactions/:id/qr.png", h.GetPaymentQRImage)
+	r.Get("/payments/transactions/:id/history", h.GetTransactionHistory)
+	r.Post("/payments/transactions/:id/sync", h.RequireAdmin, h.SyncTransaction)
+	r.Post("/payments/transactions/:id/dispute-intent", h.CreateDisputeIntent)
+	r.Post("/payments/intents", h.CreatePaymentIntent)
+	r.Get("/payments/intents/:id", h.GetPaymentIntent)
+	r.Post("/payments/intents/:id/confirm", h.Shed(true), h.ConfirmPaymentIntent)
+	r.Post("/payments/orders", h.CreateOrder)
+	r.Get("/payments/orders/:id", h.GetOrder)
+	r.Post("/payments/orders/:id/cancel", h.CancelOrder)
+	r.Get("/payments/links", h.ListPaymentLinks)
+	r.Post("/payments/links", h.CreatePaymentLink)
+	r.Get("/payments/links/:token", h.GetPaymentLink)
+	r.Post("/payments/links/:token/pay", h.Shed(true), h.PayPaymentLink)
+	r.Post("/payments/links/:token/cancel", h.CancelPaymentLink)
+	r.Post("/payments/wallet/debit", h.DebitWallet)
+	r.Post("/payments/wallet/credit", h.RequireAdmin, h.CreditWallet)
+	r.Post("/payments/wallet/holds", h.HoldWallet)
+	r.Post("/payments/wallet/holds/:operation_id/release", h.ReleaseWalletHold)
+	r.Post("/payments/wallet/holds/:operation_id/capture", h.CaptureWalletHold)
+	r.Get("/payments/auto-reload", h.GetAutoReload)
+	r.Put("/payments/auto-reload", h.PutAutoReload)
+	r.Delete("/payments/auto-reload", h.DisableAutoReload)
+	r.Post("/webhooks/:endpoint", h.HandleWebhook)
+	r.Post("/users", h.RequireAdmin, h.CreateUser)
+	r.Get("/users", h.RequireAdmin, h.ListUsers)
+	r.Get("/users/:id", h.GetUser)
+	r.Patch("/users/:id", h.UpdateUser)
+	r.Get("/users/:id/summary", h.GetUserSummary)
+	r.Get("/users/:id/export", h.Shed(false), h.ExportUserData)
+	r.Get("/users/:id/data-export", h.Shed(false), h.ExportUserData) // same as export
+	r.Delete("/users/:id/personal-data", h.RequireAdmin, h.ErasePersonalData)
+	r.Put("/users/:id/line", h.LinkLine)
+	r.Delete("/users/:id/line", h.UnlinkLine)
+	r.Put("/users/:id/devices", h.RegisterDevice)
+	r.Delete("/users/:id/devices/:token", h.UnregisterDevice)
+	r.Get("/institutions/:id/members", h.ListInstitutionMembers)
+	r.Put("/institutions/:id/members/:user_id", h.PutInstitutionMember)
+	r.Delete("/institutions/:id/members/:user_id", h.RemoveInstitutionMember)
+	r.Get("/institutions/:id/transactions", h.ListInstitutionTransactions)
+
+	admin := r.Group("/admin", h.RequireAdmin)
+	admin.Get("/audit", h.ListAuditLogs)
+	admin.Get("/transactions/export", h.Shed(false), h.ExportTransactions)
+	admin.Post("/transactions/bulk", h.Shed(false), h.BulkUpsertTransactions)
+	admin.Get("/transactions/:id/raw-payload", h.GetRawPayload)
+	admin.Get("/transactions/:id/raw", h.GetRawPayload) // same as raw-payload
+	admin.Get("/transactions/:id/as-of", h.GetTransactionAsOf)
+	admin.Patch("/transactions/:id/status", h.OverrideTransactionStatus)
+	admin.Get("/dispute-cases", h.ListDisputeCases)
+	admin.Post("/dispute-cases/:id/resolve", h.ResolveDisputeCase)
+	admin.Get("/refund-budget", h.GetRefundBudget)
+	admin.Get("/webhook-latency", h.GetWebhookLatency)
+	admin.Get("/webhooks/tail", h.TailWebhooks)
+	admin.Post("/ledger/import", h.Shed(false), h.ImportLedger)
+	admin.Post("/charges/backfill", h.Shed(false), h.BackfillCharges)
+	admin.Post("/institutions", h.CreateInstitution)
+	admin.Get("/coupons", h.ListCoupons)
+	admin.Post("/coupons", h.CreateCoupon)
+	admin.Post("/coupons/:id/deactivate", h.DeactivateCoupon)
+	admin.Get("/blocklist", h.ListBlocklist)
+	admin.Post("/blocklist", h.CreateBlocklistEntry)
+	admin.Delete("/blocklist/:id", h.DeleteBlocklistEntry)
+	admin.Get("/payouts/statements", h.ListPayoutStatements)
+	admin.Post("/payouts/statements", h.CreatePayoutStatement)
+	admin.Get("/payouts/statements/:id", h.GetPayoutStatement)
+	admin.Post("/payouts/statements/:id/approve", h.ApprovePayoutStatement)
+	admin.Get("/usage", h.GetUsageReport)
+	admin.Get("/payouts/reserve", h.GetPayoutReserve)
+	admin.Get("/settlements", h.Shed(false), h.GetSettlements)
+	admin.Get("/settlements/transfers", h.Shed(false), h.GetTransferSettlements)
+	admin.Get("/consistency-checks", h.ListConsistencyRuns)
+	admin.Post("/consistency-checks/run", h.Shed(false), h.RunConsistencyChecks)
+	admin.Get("/consistency-checks/:id", h.GetConsistencyRun)
+	admin.Get("/reconciliations", h.ListReconciliationRuns)
+	admin.Post("/reconciliations", h.Shed(false), h.RunReconciliation)
+	admin.Get("/reconciliations/:id", h.GetReconciliationRun)
+	admin.Get("/warehouse-exports", h.ListWarehouseExports)
+	admin.Post("/warehouse-exports", h.Shed(false), h.RunWarehouseExport)
+	admin.Get("/report-subscriptions", h.ListReportSubscriptions)
+	admin.Post("/report-subscriptions", h.CreateReportSubscription)
+	admin.Get("/report-subscriptions/:id", h.GetReportSubscription)
+	admin.Patch("/report-subscriptions/:id", h.UpdateReportSubscription)
+	admin.Delete("/report-subscriptions/:id", h.DeleteReportSubscription)
+	admin.Post("/report-subscriptions/:id/send", h.Shed(false), h.SendReportSubscriptionNow)
+}
+
+// legacyPathShim routes pre-versioning paths (e.g. /payments/charge, the webhook URL configured in
+// the Omise dashboard) to the same handlers under prefix, and marks the response deprecated with a
+// Link to the versioned path so clients can migrate.
+func legacyPathShim(prefix string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		path := c.Path()
+		for _, legacy := range legacyPrefixes {
+			if path == legacy || strings.HasPrefix(path, legacy+"/") {
+				c.Set("Deprecation", "true")
+				c.Set(fiber.HeaderLink, "<"+prefix+path+`>; rel="successor-version"`)
+				c.Path(prefix + path)
+				break
+			}
+		}
+		return c.Next()
+	}
+}
+
+// MethodNotAllowed returns a catch-all handler that distinguishes "wrong method" from "unknown path".
+//   - OPTIONS on a known path -> 204 with Allow header
+//   - other methods on a known path -> 405 with Allow header
+//   - unknown path -> 404
+func MethodNotAllowed(app *fiber.App) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		allowed := allowedMethods(app, c.Path())
+		if len(allowed) == 0 {
+			return apperrors.ErrNotFound.WithMessage("route not found")
+		}
+
+		allow := strings.Join(allowed, ", ")
+		c.Set(fiber.HeaderAllow, allow)
+		if c.Method() == fiber.MethodOptions {
+			return c.SendStatus(fiber.StatusNoContent)
+		}
+		return apperrors.ErrMethodNotAllowed.WithMessagef("method %s is not allowed; allowed: %s", c.Method(), allow)
+	}
+}
+
+// (helper for MethodNotAllowed) collect the methods registered for a concrete request path.
+func allowedMethods(app *fiber.App, path string) []string {
+	seen := map[string]bool{}
+	for _, r := range app.GetRoutes(true) {
+		if routePathMatches(r.Path, path) {
+			seen[r.Method] = true
+		}
+	}
+	if len(seen) == 0 {
+		return nil
+	}
+	seen[fiber.MethodOptions] = true
+
+	methods := make([]string, 0, len(seen))
+	for m := range seen {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// (helper for allowedMethods) match a route pattern ("/a/:id", "/files/*") against a request path.
+func routePathMatches(pattern, path string) bool {
+	pp := strings.Split(strings.Trim(pattern, "/"), "/")
+	rp := strings.Split(strings.Trim(path, "/"), "/")
+	for i, seg := range pp {
+		if seg == "*" {
+			return true
+		}
+		if i >= len(rp) {
+			return strings.HasSuffix(seg, "?")
+		}
+		if strings.HasPrefix(seg, ":") {
+			if rp[i] == "" && !strings.HasSuffix(seg, "?") {
+				return false
+			}
+			continue
+		}
+		if seg != rp[i] {
+			return false
+		}
+	}
+	return len(pp) == len(rp)
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestVersionedAndLegacyRoutes(t *testing.T) {
+	h := NewPaymentHandler(nil, nil)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	RegisterRoutes(app, h)
+
+	cases := []struct {
+		method, path string
+		status       int
+		deprecated   bool
+	}{
+		{"GET", "/health", 200, false},
+		// no admin token configured: both paths reach RequireAdmin, which refuses everything
+		{"GET", "/api/v1/admin/audit", 403, false},
+		{"GET", "/admin/audit", 403, true},
+		{"DELETE", "/api/v1/payments/charge", 405, false},
+		{"DELETE", "/payments/charge", 405, true},
+		{"GET", "/api/v1/nope", 404, false},
+		{"GET", "/paymentsx", 404, false},
+	}
+	for _, tc := range cases {
+		resp, err := app.Test(httptest.NewRequest(tc.method, tc.path, nil))
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s %s: status %d, want %d", tc.method, tc.path, resp.StatusCode, tc.status)
+		}
+		if got := resp.Header.Get("Deprecation") == "true"; got != tc.deprecated {
+			t.Errorf("%s %s: deprecated = %v, want %v", tc.method, tc.path, got, tc.deprecated)
+		}
+	}
+}
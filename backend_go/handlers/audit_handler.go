@@ -0,0 +1,127 @@
+// audit_handler.go records audit entries and serves GET /admin/audit
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// auditEntry builds an AuditLog for the current request (actor + IP); before/after are marshalled as JSON.
+func auditEntry(c *fiber.Ctx, action, entityType, entityID string, before, after interface{}) models.AuditLog {
+	entry := models.AuditLog{
+		Actor:      requestActor(c),
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Before:     auditJSON(before),
+		After:      auditJSON(after),
+	}
+	if c != nil {
+		entry.IP = c.IP()
+	}
+	return entry
+}
+
+// systemAuditEntry builds an AuditLog for background work (webhooks, jobs).
+func systemAuditEntry(component, action, entityType, entityID string, before, after interface{}) models.AuditLog {
+	return models.AuditLog{
+		Actor:      "system:" + component,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Before:     auditJSON(before),
+		After:      auditJSON(after),
+	}
+}
+
+// writeAudit persists an entry. Pass the surrounding DB transaction so the entry commits atomically
+// with the change it describes.
+func writeAudit(db *gorm.DB, entry models.AuditLog) error {
+	return db.Create(&entry).Error
+}
+
+// (helper for handlers outside a DB transaction) write the entry and only log on failure.
+func (h *PaymentHandler) audit(entry models.AuditLog) {
+	if err := writeAudit(h.DB, entry); err != nil {
+		log.Printf("audit: write failed action=%s entity=%s/%s err=%v", entry.Action, entry.EntityType, entry.EntityID, err)
+	}
+}
+
+// (helper for auditEntry) "admin:<name>" behind RequireAdmin, else "user:<id>", else "anonymous".
+func requestActor(c *fiber.Ctx) string {
+	if c == nil {
+		return "system"
+	}
+	if a := adminActor(c); a != "" {
+		return "admin:" + a
+	}
+	if id := userIDFromHeaderOrQuery(c); id != nil {
+		return fmt.Sprintf("user:%d", *id)
+	}
+	return "anonymous"
+}
+
+func auditJSON(v interface{}) datatypes.JSON {
+	if v == nil {
+		return nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return datatypes.JSON(raw)
+}
+
+// ListAuditLogs returns audit entries newest first.
+// Filters: actor, action, entity_type, entity_id, from/to (RFC3339), limit/offset.
+func (h *PaymentHandler) ListAuditLogs(c *fiber.Ctx) error {
+	q := h.db(c).Model(&models.AuditLog{})
+	if v := c.Query("actor"); v != "" {
+		q = q.Where("actor = ?", v)
+	}
+	if v := c.Query("action"); v != "" {
+		q = q.Where("action = ?", v)
+	}
+	if v := c.Query("entity_type"); v != "" {
+		q = q.Where("entity_type = ?", v)
+	}
+	if v := c.Query("entity_id"); v != "" {
+		q = q.Where("entity_id = ?", v)
+	}
+	for _, p := range []struct{ param, cond string }{{"from", "created_at >= ?"}, {"to", "created_at < ?"}} {
+		if v := c.Query(p.param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return apperrors.ErrValidation.WithMessagef("%s must be RFC3339 (e.g. 2025-01-31T00:00:00Z)", p.param)
+			}
+			q = q.Where(p.cond, t)
+		}
+	}
+	limit, offset := h.limitOffset(c)
+
+	var total int64
+	if err := q.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to count audit logs").Wrap(err)
+	}
+	var logs []models.AuditLog
+	if err := q.Order("created_at DESC, id DESC").Limit(limit).Offset(offset).Find(&logs).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve audit logs").Wrap(err)
+	}
+
+	return c.JSON(fiber.Map{
+		"audit_logs": logs,
+		"pagination": fiber.Map{
+			"total":  total,
+			"limit":  limit,
+			"offset": offset,
+		},
+	})
+}
@@ -0,0 +1,295 @@
+// auto_reload_handler.go contains /payments/auto-reload handlers and the auto top-up flow.
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/gateway"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/money"
+	"github.com/a2n2k3p4/tutorium-backend/notify"
+	"github.com/a2n2k3p4/tutorium-backend/service"
+	"github.com/gofiber/fiber/v2"
+	omise "github.com/omise/omise-go"
+	"github.com/omise/omise-go/operations"
+	"gorm.io/gorm"
+)
+
+// Auto-reload safeguards.
+const (
+	autoReloadMinAmount      = 2000   // 20 THB (Omise minimum charge)
+	autoReloadMaxAmount      = 500000 // 5,000 THB per reload
+	autoReloadCooldown       = 10 * time.Minute
+	autoReloadMaxFailures    = 3
+	autoReloadDefaultPerDay  = 3
+	autoReloadMaxPerDayLimit = 10
+)
+
+type autoReloadRequest struct {
+	UserID          *uint   `json:"user_id,omitempty"`
+	Token           string  `json:"token,omitempty"` // card token from Omise.js / mobile SDK; saved to an Omise customer
+	ThresholdSatang *int64  `json:"threshold_satang,omitempty"`
+	AmountSatang    *int64  `json:"amount_satang,omitempty"`
+	NotifyEmail     *string `json:"notify_email,omitempty"`
+	MaxPerDay       *int    `json:"max_per_day,omitempty"`
+	Enabled         *bool   `json:"enabled,omitempty"`
+}
+
+func (h *PaymentHandler) GetAutoReload(c *fiber.Ctx) error {
+	userID := userIDFromHeaderOrQuery(c)
+	if userID == nil {
+		return apperrors.ErrValidation.WithMessage("user_id is required")
+	}
+	var setting models.AutoReload
+	if err := h.db(c).Where("user_id = ?", *userID).First(&setting).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperrors.ErrNotFound.WithMessage("auto-reload is not configured")
+		}
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve auto-reload").Wrap(err)
+	}
+	return c.JSON(setting)
+}
+
+// PutAutoReload creates or updates the user's auto-reload settings, optionally saving a new card.
+func (h *PaymentHandler) PutAutoReload(c *fiber.Ctx) error {
+	var req autoReloadRequest
+	if err := parseAndValidate(c, &req); err != nil {
+		return err
+	}
+	userID := req.UserID
+	if userID == nil {
+		userID = userIDFromHeaderOrQuery(c)
+	}
+	if userID == nil {
+		return apperrors.ErrValidation.WithMessage("user_id is required")
+	}
+
+	var setting models.AutoReload
+	err := h.db(c).Where("user_id = ?", *userID).First(&setting).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve auto-reload").Wrap(err)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		setting = models.AutoReload{UserID: *userID, Currency: "thb", MaxPerDay: autoReloadDefaultPerDay}
+	}
+	before := setting
+
+	if req.ThresholdSatang != nil {
+		setting.ThresholdSatang = *req.ThresholdSatang
+	}
+	if req.AmountSatang != nil {
+		setting.AmountSatang = *req.AmountSatang
+	}
+	if req.NotifyEmail != nil {
+		setting.NotifyEmail = *req.NotifyEmail
+	}
+	if req.MaxPerDay != nil {
+		setting.MaxPerDay = *req.MaxPerDay
+	}
+	if setting.ThresholdSatang < 0 {
+		return apperrors.ErrValidation.WithMessage("threshold_satang must be >= 0")
+	}
+	if setting.AmountSatang < autoReloadMinAmount || setting.AmountSatang > autoReloadMaxAmount {
+		return apperrors.ErrValidation.WithMessagef("amount_satang must be between %d and %d", autoReloadMinAmount, autoReloadMaxAmount)
+	}
+	if setting.MaxPerDay < 1 || setting.MaxPerDay > autoReloadMaxPerDayLimit {
+		return apperrors.ErrValidation.WithMessagef("max_per_day must be between 1 and %d", autoReloadMaxPerDayLimit)
+	}
+
+	if req.Token != "" {
+		card, err := h.saveAutoReloadCard(c.UserContext(), &setting, req.Token)
+		if err != nil {
+			return apperrors.ErrOmiseUnavailable.WithMessage("Failed to save card").Wrap(err)
+		}
+		setting.OmiseCardID = card.ID
+		setting.CardBrand = card.Brand
+		setting.CardLastDigits = card.LastDigits
+	}
+
+	if req.Enabled != nil {
+		setting.Enabled = *req.Enabled
+	}
+	if setting.Enabled {
+		if setting.OmiseCardID == "" {
+			return apperrors.ErrValidation.WithMessage("a saved card is required; send token to enable auto-reload")
+		}
+		setting.ConsecutiveFailures = 0
+		setting.DisabledReason = nil
+	}
+
+	if err := h.db(c).Save(&setting).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to save auto-reload").Wrap(err)
+	}
+	h.audit(auditEntry(c, models.AuditAutoReloadUpdate, "user", fmt.Sprintf("%d", *userID), before, setting))
+	return c.JSON(setting)
+}
+
+// DisableAutoReload turns auto-reload off; the saved card is kept so it can be re-enabled.
+func (h *PaymentHandler) DisableAutoReload(c *fiber.Ctx) error {
+	userID := userIDFromHeaderOrQuery(c)
+	if userID == nil {
+		return apperrors.ErrValidation.WithMessage("user_id is required")
+	}
+	reason := "disabled by user"
+	res := h.db(c).Model(&models.AutoReload{}).
+		Where("user_id = ?", *userID).
+		Updates(map[string]interface{}{"enabled": false, "disabled_reason": reason})
+	if res.Error != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to disable auto-reload").Wrap(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return apperrors.ErrNotFound.WithMessage("auto-reload is not configured")
+	}
+	h.audit(auditEntry(c, models.AuditAutoReloadUpdate, "user", fmt.Sprintf("%d", *userID),
+		nil, fiber.Map{"enabled": false, "disabled_reason": reason}))
+	return c.JSON(fiber.Map{"user_id": *userID, "enabled": false})
+}
+
+// (helper for PutAutoReload) attach the tokenized card to the user's Omise customer (created on first use).
+// Saved cards, like the top-ups charged to them, live on the default merchant's account.
+func (h *PaymentHandler) saveAutoReloadCard(ctx context.Context, setting *models.AutoReload, token string) (*omise.Card, error) {
+	ctx = gateway.WithMerchant(ctx, models.DefaultMerchantID)
+	var (
+		customer *omise.Customer
+		err      error
+	)
+	if setting.OmiseCustomerID == "" {
+		customer, err = h.Omise.CreateCustomer(ctx, &operations.CreateCustomer{
+			Email:       setting.NotifyEmail,
+			Description: fmt.Sprintf("tutorium user %d (auto-reload)", setting.UserID),
+			Card:        token,
+			Metadata:    map[string]interface{}{"user_id": fmt.Sprintf("%d", setting.UserID)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		setting.OmiseCustomerID = customer.ID
+	} else {
+		customer, err = h.Omise.UpdateCustomer(ctx, &operations.UpdateCustomer{
+			CustomerID: setting.OmiseCustomerID,
+			Card:       token,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The newly attached card is the last one in the customer's card list.
+	if customer.Cards == nil || len(customer.Cards.Data) == 0 {
+		return nil, fmt.Errorf("customer %s has no cards", customer.ID)
+	}
+	return customer.Cards.Data[len(customer.Cards.Data)-1], nil
+}
+
+// ---------------------- auto top-up ----------------------
+
+// maybeAutoReload charges the user's saved card when balance (THB) is below their threshold.
+// Safeguards: cooldown between attempts, daily cap, the blocklist, and auto-disable after repeated
+// failures.
+func (h *PaymentHandler) maybeAutoReload(ctx context.Context, userID uint, balance float64) {
+	ctx = gateway.WithMerchant(ctx, models.DefaultMerchantID) // where the card was saved
+	var setting models.AutoReload
+	if err := h.DB.WithContext(ctx).Where("user_id = ? AND enabled = ?", userID, true).First(&setting).Error; err != nil {
+		return
+	}
+	if money.FromMajor(balance, money.THB).Amount >= setting.ThresholdSatang {
+		return
+	}
+
+	// Claim the attempt so concurrent debits do not double-charge.
+	now := time.Now()
+	res := h.DB.WithContext(ctx).Model(&models.AutoReload{}).
+		Where("id = ? AND (last_attempt_at IS NULL OR last_attempt_at < ?)", setting.ID, now.Add(-autoReloadCooldown)).
+		Update("last_attempt_at", now)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return
+	}
+
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	today, err := h.Transactions.WithContext(ctx).CountAutoReloadsSince(userID, dayStart)
+	if err != nil {
+		log.Printf("auto-reload: count failed user=%d err=%v", userID, err)
+		return
+	}
+	if today >= int64(setting.MaxPerDay) {
+		h.notifyAutoReload(&setting, "Auto-reload skipped",
+			fmt.Sprintf("Your balance is %.2f THB but the daily auto-reload limit (%d) has been reached.", balance, setting.MaxPerDay))
+		return
+	}
+
+	if err := h.Payments.CheckBlocklist(ctx, service.Payer{UserID: &userID}); err != nil {
+		log.Printf("auto-reload: skipped user=%d err=%v", userID, err)
+		return
+	}
+
+	charge, err := h.Omise.CreateCharge(ctx, &operations.CreateCharge{
+		Customer:             setting.OmiseCustomerID,
+		Card:                 setting.OmiseCardID,
+		Amount:               setting.AmountSatang,
+		Currency:             setting.Currency,
+		Description:          "Wallet auto-reload",
+		Metadata:             map[string]interface{}{"user_id": fmt.Sprintf("%d", userID), "auto_reload": "true"},
+		TransactionIndicator: omise.MIT,
+		RecurringReason:      omise.Unscheduled,
+	})
+	if err == nil {
+		h.audit(systemAuditEntry("auto_reload", models.AuditAutoReloadCharge, "user", fmt.Sprintf("%d", userID),
+			fiber.Map{"balance": balance}, fiber.Map{"charge_id": charge.ID, "status": charge.Status, "amount_satang": charge.Amount}))
+		if upErr := h.Payments.RecordCharge(ctx, charge, &userID); upErr != nil {
+			log.Printf("auto-reload: save transaction failed charge=%s err=%v", charge.ID, upErr)
+		}
+	}
+
+	if err != nil || charge.Status == omise.ChargeFailed {
+		reason := "charge failed"
+		if err != nil {
+			reason = err.Error()
+		} else if charge.FailureMessage != nil {
+			reason = *charge.FailureMessage
+		}
+		h.recordAutoReloadFailure(&setting, reason)
+		return
+	}
+
+	h.DB.Model(&models.AutoReload{}).Where("id = ?", setting.ID).
+		Updates(map[string]interface{}{"consecutive_failures": 0, "last_charge_id": charge.ID})
+	if charge.Status == omise.ChargeSuccessful {
+		h.notifyAutoReload(&setting, "Wallet auto-reloaded",
+			fmt.Sprintf("We charged %s to your %s card ending %s (charge %s).",
+				money.New(setting.AmountSatang, setting.Currency), setting.CardBrand, setting.CardLastDigits, charge.ID))
+	} else {
+		h.notifyAutoReload(&setting, "Auto-reload pending",
+			fmt.Sprintf("Auto-reload charge %s is %s; your balance will update once it completes.", charge.ID, charge.Status))
+	}
+}
+
+// (helper for maybeAutoReload) count a failure and disable auto-reload after too many in a row.
+func (h *PaymentHandler) recordAutoReloadFailure(setting *models.AutoReload, reason string) {
+	failures := setting.ConsecutiveFailures + 1
+	updates := map[string]interface{}{"consecutive_failures": failures}
+	body := fmt.Sprintf("Auto-reload of %s failed: %s", money.New(setting.AmountSatang, setting.Currency), reason)
+	if failures >= autoReloadMaxFailures {
+		disabled := fmt.Sprintf("disabled after %d consecutive failures", failures)
+		updates["enabled"] = false
+		updates["disabled_reason"] = disabled
+		body += "\nAuto-reload has been " + disabled + "; update your card to re-enable it."
+	}
+	h.DB.Model(&models.AutoReload{}).Where("id = ?", setting.ID).Updates(updates)
+	log.Printf("auto-reload: user=%d failure=%d reason=%s", setting.UserID, failures, reason)
+	h.notifyAutoReload(setting, "Auto-reload failed", body)
+}
+
+func (h *PaymentHandler) notifyAutoReload(setting *models.AutoReload, subject, body string) {
+	log.Printf("auto-reload: user=%d %s", setting.UserID, subject)
+	if setting.NotifyEmail == "" || !h.Mailer.Enabled() {
+		return
+	}
+	if err := h.Mailer.Send(setting.NotifyEmail, notify.Message{Subject: subject, Body: body}); err != nil {
+		log.Printf("auto-reload: notify failed user=%d err=%v", setting.UserID, err)
+	}
+}
@@ -0,0 +1,49 @@
+// background.go tracks work started off the request path so shutdown can wait for it.
+package handlers
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// backgroundWork is embedded in PaymentHandler; the zero value is ready to use.
+type backgroundWork struct {
+	wg sync.WaitGroup
+	// pending counts unfinished goBackground tasks (not scheduler loops); see Backpressure.
+	pending atomic.Int64
+}
+
+// goBackground runs fn in a goroutine that Drain waits for (auto-reload charges, tax submission,
+// alerts). Use it instead of a bare `go` for anything that touches Omise or the DB, and pass ctx to
+// those calls: it carries the Deadlines.Background deadline, not the request's.
+func (h *PaymentHandler) goBackground(fn func(ctx context.Context)) {
+	h.background.wg.Add(1)
+	h.background.pending.Add(1)
+	go func() {
+		defer h.background.wg.Done()
+		defer h.background.pending.Add(-1)
+		ctx, cancel := context.Background(), context.CancelFunc(func() {})
+		if h.Deadlines.Background > 0 {
+			ctx, cancel = context.WithTimeout(ctx, h.Deadlines.Background)
+		}
+		defer cancel()
+		fn(ctx)
+	}()
+}
+
+// Drain waits for background work started with goBackground to finish, or for ctx to expire.
+// Call it after the HTTP server has stopped accepting requests and the jobs have been stopped.
+func (h *PaymentHandler) Drain(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		h.background.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
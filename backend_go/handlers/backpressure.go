@@ -0,0 +1,68 @@
+// backpressure.go sheds load when the webhook pipeline or background work backs up, so exports and
+// reports (and optionally new charges) cannot starve webhook processing.
+package handlers
+
+import (
+	"expvar"
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/gofiber/fiber/v2"
+)
+
+// backpressureMetrics is published at /debug/vars as "backpressure": requests shed per route class.
+var backpressureMetrics = expvar.NewMap("backpressure")
+
+// Backpressure configures load shedding; a zero depth disables that queue's limit.
+type Backpressure struct {
+	MaxWebhooks   int           // webhook requests being processed at once
+	MaxBackground int           // pending background tasks (auto-reload, tax submission, alerts)
+	ShedCharges   bool          // also reject POST /payments/charge while over a limit
+	RetryAfter    time.Duration // Retry-After sent with shed responses
+}
+
+// queueLoad counts in-flight webhook requests; the zero value is ready to use.
+type queueLoad struct {
+	webhooks atomic.Int64
+}
+
+// Shed rejects the request with 503 "overloaded" and a Retry-After header while a queue is over its
+// depth. Non-critical routes (exports, reports) are always shed; critical ones (charge creation)
+// only when Backpressure.ShedCharges is set.
+func (h *PaymentHandler) Shed(critical bool) fiber.Handler {
+	class := "noncritical"
+	if critical {
+		class = "charges"
+	}
+	return func(c *fiber.Ctx) error {
+		if critical && !h.Backpressure.ShedCharges {
+			return c.Next()
+		}
+		queue, depth, limit, over := h.overloadedQueue()
+		if !over {
+			return c.Next()
+		}
+		backpressureMetrics.Add(class, 1)
+		retry := h.Backpressure.RetryAfter
+		if retry <= 0 {
+			retry = 5 * time.Second
+		}
+		c.Set(fiber.HeaderRetryAfter, fmt.Sprintf("%d", int(math.Ceil(retry.Seconds()))))
+		return apperrors.ErrUnavailable.WithCode("overloaded").
+			WithMessagef("the %s queue is over capacity (%d/%d); retry later", queue, depth, limit)
+	}
+}
+
+// (helper for Shed) the first queue over its configured depth.
+func (h *PaymentHandler) overloadedQueue() (queue string, depth int64, limit int, over bool) {
+	if n := h.load.webhooks.Load(); h.Backpressure.MaxWebhooks > 0 && n >= int64(h.Backpressure.MaxWebhooks) {
+		return "webhook", n, h.Backpressure.MaxWebhooks, true
+	}
+	if n := h.background.pending.Load(); h.Backpressure.MaxBackground > 0 && n >= int64(h.Backpressure.MaxBackground) {
+		return "background", n, h.Backpressure.MaxBackground, true
+	}
+	return "", 0, 0, false
+}
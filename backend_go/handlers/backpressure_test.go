@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestShedUnderBackpressure(t *testing.T) {
+	h := NewPaymentHandler(nil, nil)
+	h.Backpressure = Backpressure{MaxWebhooks: 2, RetryAfter: 1500 * time.Millisecond}
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) }
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/export", h.Shed(false), ok)
+	app.Post("/charge", h.Shed(true), ok)
+
+	status := func(method, path string) (int, string) {
+		resp, err := app.Test(httptest.NewRequest(method, path, nil))
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		return resp.StatusCode, resp.Header.Get(fiber.HeaderRetryAfter)
+	}
+
+	if code, _ := status("GET", "/export"); code != 200 {
+		t.Fatalf("export under capacity: status %d, want 200", code)
+	}
+
+	h.load.webhooks.Add(2)
+	defer h.load.webhooks.Add(-2)
+	code, retry := status("GET", "/export")
+	if code != 503 || retry != "2" {
+		t.Errorf("export over capacity: status %d Retry-After %q, want 503 and 2", code, retry)
+	}
+	if code, _ := status("POST", "/charge"); code != 200 {
+		t.Errorf("charge over capacity without ShedCharges: status %d, want 200", code)
+	}
+	h.Backpressure.ShedCharges = true
+	if code, _ := status("POST", "/charge"); code != 503 {
+		t.Errorf("charge over capacity with ShedCharges: status %d, want 503", code)
+	}
+}
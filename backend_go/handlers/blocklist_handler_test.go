@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/repository/repotest"
+	"github.com/a2n2k3p4/tutorium-backend/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestCreateBlocklistEntryValidatesValue(t *testing.T) {
+	h := NewPaymentHandler(nil, nil)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/blocklist", h.CreateBlocklistEntry)
+
+	for name, body := range map[string]string{
+		"unknown kind":     `{"kind": "ip", "value": "10.0.0.1", "reason": "abuse"}`,
+		"no reason":        `{"kind": "user_id", "value": "7"}`,
+		"user id not a id": `{"kind": "user_id", "value": "alice", "reason": "abuse"}`,
+		"user id zero":     `{"kind": "user_id", "value": "0", "reason": "abuse"}`,
+		"not an email":     `{"kind": "email", "value": "alice@", "reason": "abuse"}`,
+	} {
+		req := httptest.NewRequest("POST", "/blocklist", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", name, resp.StatusCode)
+		}
+	}
+}
+
+func TestBlocklistLifecycle(t *testing.T) {
+	h := NewPaymentHandler(repotest.NewDB(), nil)
+	store := repotest.NewBlocklist()
+	store.NotifyEmails[8] = "Billing@Example.com"
+	h.Blocklist, h.Payments.Blocklist = store, store
+	t.Cleanup(func() { h.Drain(context.Background()) })
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/blocklist", h.CreateBlocklistEntry)
+	app.Delete("/blocklist/:id", h.DeleteBlocklistEntry)
+
+	call := func(method, path, body string) *http.Response {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	blocked := func(p service.Payer) bool {
+		t.Helper()
+		err := h.Payments.CheckBlocklist(context.Background(), p)
+		if err != nil && !errors.Is(err, service.ErrPayerBlocked) {
+			t.Fatalf("CheckBlocklist(%+v): %v", p, err)
+		}
+		return err != nil
+	}
+	user := func(id uint) *uint { return &id }
+
+	resp := call("POST", "/blocklist", `{"kind": "user_id", "value": " 7 ", "reason": "chargebacks"}`)
+	if resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("create: status %d, want 201", resp.StatusCode)
+	}
+	var entry models.BlocklistEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entry); err != nil || entry.Value != "7" {
+		t.Fatalf("created entry = %+v (%v), want value 7", entry, err)
+	}
+	if resp := call("POST", "/blocklist", `{"kind": "user_id", "value": "7", "reason": "again"}`); resp.StatusCode != fiber.StatusConflict {
+		t.Fatalf("duplicate: status %d, want 409", resp.StatusCode)
+	}
+	if !blocked(service.Payer{UserID: user(7)}) || blocked(service.Payer{UserID: user(9)}) {
+		t.Fatal("want user 7 blocked and user 9 not")
+	}
+
+	if resp := call("POST", "/blocklist", `{"kind": "email", "value": "Billing@EXAMPLE.com", "reason": "fraud"}`); resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("create email: status %d, want 201", resp.StatusCode)
+	}
+	if !blocked(service.Payer{Email: " billing@example.COM"}) {
+		t.Error("want the email blocked whatever its case")
+	}
+	if !blocked(service.Payer{UserID: user(8)}) {
+		t.Error("want user 8 blocked by their auto-reload notify email")
+	}
+
+	path := fmt.Sprintf("/blocklist/%d", entry.ID)
+	if resp := call("DELETE", path, ""); resp.StatusCode != fiber.StatusNoContent {
+		t.Fatalf("delete: status %d, want 204", resp.StatusCode)
+	}
+	if blocked(service.Payer{UserID: user(7)}) {
+		t.Error("user 7 still blocked after delete")
+	}
+	if resp := call("DELETE", path, ""); resp.StatusCode != fiber.StatusNotFound {
+		t.Errorf("delete again: status %d, want 404", resp.StatusCode)
+	}
+}
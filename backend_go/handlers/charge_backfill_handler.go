@@ -0,0 +1,68 @@
+// charge_backfill_handler.go serves /admin/charges/backfill, which imports Omise's charges made before
+// this service existed into the transactions table (tutoriumctl charges backfill drives it a window
+// at a time).
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// backfillMaxDays bounds one backfill request, which pages through Omise synchronously; longer
+// histories are backfilled a window at a time.
+const backfillMaxDays = 31
+
+type backfillChargesRequest struct {
+	From   string `json:"from" validate:"required"` // YYYY-MM-DD (Bangkok), inclusive
+	To     string `json:"to,omitempty"`             // YYYY-MM-DD (Bangkok), exclusive; default from + backfillMaxDays days
+	DryRun bool   `json:"dry_run"`                  // report what would be recorded and credited without writing
+}
+
+// BackfillCharges records Omise's charges created in a period that the transactions table is missing
+// or has with a stale status, crediting wallets for successful charges that carry a user_id. Re-runs
+// are safe: recorded charges come back unchanged. dry_run (body or query) writes nothing.
+//
+//	POST /api/v1/admin/charges/backfill {"from": "2024-01-01", "to": "2024-02-01", "dry_run": true}
+func (h *PaymentHandler) BackfillCharges(c *fiber.Ctx) error {
+	var req backfillChargesRequest
+	if err := parseAndValidate(c, &req); err != nil {
+		return err
+	}
+	if c.QueryBool("dry_run") {
+		req.DryRun = true
+	}
+	from, err := time.ParseInLocation("2006-01-02", req.From, bangkok)
+	if err != nil {
+		return apperrors.ErrValidation.WithMessage("from must be a date (YYYY-MM-DD)")
+	}
+	to := from.AddDate(0, 0, backfillMaxDays)
+	if req.To != "" {
+		if to, err = time.ParseInLocation("2006-01-02", req.To, bangkok); err != nil || !to.After(from) {
+			return apperrors.ErrValidation.WithMessage("to must be a date (YYYY-MM-DD) after from")
+		}
+	}
+	if to.Sub(from) > backfillMaxDays*24*time.Hour {
+		return apperrors.ErrValidation.WithMessagef("a backfill covers at most %d days", backfillMaxDays)
+	}
+
+	result, err := h.Payments.BackfillCharges(c.UserContext(), from, to, req.DryRun)
+	if !req.DryRun && result.OmiseCharges > 0 {
+		h.audit(auditEntry(c, models.AuditChargeBackfill, "charge_backfill", fmt.Sprintf("%s..%s", req.From, to.Format("2006-01-02")), nil,
+			fiber.Map{"created": result.Created, "updated": result.Updated, "failed": result.Failed, "credited": result.Credited}))
+		h.Payments.TransactionCache.InvalidateTotals(c.UserContext())
+	}
+	log.Printf("charge backfill: from=%s to=%s dry_run=%t omise=%d created=%d updated=%d failed=%d credited=%d err=%v",
+		req.From, to.Format("2006-01-02"), req.DryRun, result.OmiseCharges, result.Created, result.Updated, result.Failed, result.Credited, err)
+	if err != nil {
+		return apperrors.ErrOmiseUnavailable.WithMessagef("Backfill stopped after %d charges; re-run it to continue", result.OmiseCharges).Wrap(err)
+	}
+	if req.DryRun {
+		return c.JSON(result)
+	}
+	return c.Status(fiber.StatusCreated).JSON(result)
+}
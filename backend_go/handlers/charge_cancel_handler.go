@@ -0,0 +1,48 @@
+// charge_cancel_handler.go serves POST /payments/charge/:id/cancel: a payer who changed their mind
+// cancels a charge they have not paid yet, so a stale PromptPay QR or bank page cannot be paid later.
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/a2n2k3p4/tutorium-backend/apiv1"
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"github.com/a2n2k3p4/tutorium-backend/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+// CancelCharge cancels the pending charge :id (internal or charge id): Omise expires it where it can,
+// and the transaction becomes canceled (see service.CancelCharge). A charge that is no longer pending
+// gets 409 charge_not_pending. Allowed for the charge's payer (X-User-ID) or an admin.
+//
+//	POST /api/v1/payments/charge/chrg_test_5xy/cancel
+func (h *PaymentHandler) CancelCharge(c *fiber.Ctx) error {
+	t, err := h.Transactions.WithContext(c.UserContext()).Find(c.Params("id"))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperrors.ErrNotFound.WithMessage("Transaction not found")
+		}
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve transaction").Wrap(err)
+	}
+	if !h.adminTokenValid(c) {
+		// Do not reveal whether other users' charges exist.
+		if self := userIDFromHeaderOrQuery(c); self == nil || t.UserID == nil || *self != *t.UserID {
+			return apperrors.ErrNotFound.WithMessage("Transaction not found")
+		}
+	}
+	ctx := service.WithEventSource(c.UserContext(), models.TransactionEventSync)
+	canceled, err := h.Payments.CancelCharge(ctx, t)
+	if err != nil {
+		var inErr *service.InputError
+		if errors.As(err, &inErr) {
+			return apperrors.ErrConflict.WithCode(inErr.Code).WithMessage(inErr.Message)
+		}
+		return chargeError(err)
+	}
+	h.audit(auditEntry(c, models.AuditTransactionCancel, "transaction", fmt.Sprintf("%d", t.ID),
+		fiber.Map{"status": t.Status}, fiber.Map{"status": canceled.Status, "charge_id": canceled.ChargeID}))
+	return c.JSON(apiv1.FromTransaction(*canceled, responseLang(c)))
+}
@@ -0,0 +1,251 @@
+// consistency_handler.go runs the nightly ledger consistency checks, publishes their results as
+// expvar metrics and admin alerts, and serves /admin/consistency-checks.
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/notify"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/datatypes"
+)
+
+// consistencySampleSize caps the violations stored per check; the count is always complete.
+const consistencySampleSize = 50
+
+// consistencyMetrics is published at /debug/vars as "consistency_checks": violations per check from
+// the last run, plus runs, violations_total and last_run_unix.
+var consistencyMetrics = expvar.NewMap("consistency_checks")
+
+// consistencyCheck is one invariant. SQL returns a row (entity_id, detail) per violation.
+type consistencyCheck struct {
+	Name        string
+	Description string
+	EntityType  string
+	SQL         string
+}
+
+var consistencyChecks = []consistencyCheck{
+	{
+		Name:        "charge_credited_once",
+		Description: "every successful charge of a user has exactly one balance credit, to that user",
+		EntityType:  "charge",
+		// charge_credits holds at most one row per charge; a missing row or another user is the violation.
+		SQL: `SELECT t.charge_id AS entity_id, CASE WHEN c.charge_id IS NULL THEN 'successful charge has no balance credit'
+	ELSE 'credited to user ' || c.user_id || ', not its user ' || t.user_id END AS detail
+FROM transactions t
+JOIN users u ON u.id = t.user_id AND u.deleted_at IS NULL
+LEFT JOIN charge_credits c ON c.charge_id = t.charge_id
+WHERE t.status = 'successful' AND t.deleted_at IS NULL AND t.meta->>'legacy_ref' IS NULL
+	AND (c.charge_id IS NULL OR c.user_id <> t.user_id)`,
+	},
+	{
+		Name:        "refunds_within_charge",
+		Description: "refund totals do not exceed the charge amount",
+		EntityType:  "charge",
+		SQL: `SELECT t.charge_id AS entity_id,
+	'refunded ' || SUM((a.after->>'amount_satang')::bigint) || ' of ' || t.amount_satang || ' satang' AS detail
+FROM audit_logs a
+JOIN transactions t ON a.entity_id = t.id::text
+WHERE a.action = 'transaction.refund'
+GROUP BY t.charge_id, t.amount_satang
+HAVING SUM((a.after->>'amount_satang')::bigint) > t.amount_satang`,
+	},
+	{
+		// Ledger: charge credits + wallet credits - wallet debits - captured holds - refunded dispute amounts
+		// equals balance + held + frozen; held and frozen match active holds and open disputes.
+		Name:        "ledger_matches_balance",
+		Description: "ledger sums match the cached user balances",
+		EntityType:  "user",
+		SQL: `WITH ledger AS (
+	SELECT user_id, ROUND(SUM(credited_thb) * 100)::bigint AS satang
+	FROM charge_credits GROUP BY user_id
+	UNION ALL
+	SELECT user_id, SUM(CASE WHEN kind = 'credit' THEN amount_satang
+		WHEN kind = 'debit' THEN -amount_satang
+		WHEN kind = 'hold' AND hold_status = 'captured' THEN -amount_satang
+		ELSE 0 END)
+	FROM wallet_operations GROUP BY user_id
+	UNION ALL
+	SELECT user_id, -SUM(frozen_satang) FROM dispute_cases
+	WHERE status = 'refunded' AND deleted_at IS NULL GROUP BY user_id
+), expected AS (
+	SELECT user_id, SUM(satang) AS satang FROM ledger GROUP BY user_id
+), held AS (
+	SELECT user_id, SUM(amount_satang) AS satang FROM wallet_operations
+	WHERE kind = 'hold' AND hold_status = 'active' GROUP BY user_id
+), frozen AS (
+	SELECT user_id, SUM(frozen_satang) AS satang FROM dispute_cases
+	WHERE status = 'open' AND deleted_at IS NULL GROUP BY user_id
+), cached AS (
+	SELECT id, ROUND((balance + held_balance + frozen_balance) * 100)::bigint AS total,
+		ROUND(held_balance * 100)::bigint AS held, ROUND(frozen_balance * 100)::bigint AS frozen
+	FROM users WHERE deleted_at IS NULL
+)
+SELECT c.id::text AS entity_id,
+	format('balances total %s satang vs ledger %s; held %s vs active holds %s; frozen %s vs open disputes %s',
+		c.total, COALESCE(e.satang, 0), c.held, COALESCE(h.satang, 0), c.frozen, COALESCE(f.satang, 0)) AS detail
+FROM cached c
+LEFT JOIN expected e ON e.user_id = c.id
+LEFT JOIN held h ON h.user_id = c.id
+LEFT JOIN frozen f ON f.user_id = c.id
+WHERE c.total <> COALESCE(e.satang, 0) OR c.held <> COALESCE(h.satang, 0) OR c.frozen <> COALESCE(f.satang, 0)`,
+	},
+	{
+		Name:        "no_orphan_refunds",
+		Description: "every refund belongs to an existing transaction and is recorded in the audit log",
+		EntityType:  "refund",
+		SQL: `SELECT COALESCE(a.after->>'refund_id', a.entity_id) AS entity_id, 'refund references missing transaction ' || a.entity_id AS detail
+FROM audit_logs a
+LEFT JOIN transactions t ON a.entity_id = t.id::text AND t.deleted_at IS NULL
+WHERE a.action = 'transaction.refund' AND t.id IS NULL
+UNION ALL
+SELECT d.refund_id, 'refund of dispute case ' || d.id || ' has no refund audit entry'
+FROM dispute_cases d
+WHERE d.refund_id <> '' AND NOT EXISTS (
+	SELECT 1 FROM audit_logs a WHERE a.action = 'transaction.refund' AND a.after->>'refund_id' = d.refund_id)`,
+	},
+}
+
+// consistencyViolation is one row returned by a check.
+type consistencyViolation struct {
+	EntityID string `json:"entity_id"`
+	Detail   string `json:"detail"`
+}
+
+// consistencyCheckResult is stored per check in ConsistencyRun.Checks.
+type consistencyCheckResult struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	EntityType  string                 `json:"entity_type"`
+	Violations  int                    `json:"violations"`
+	Sample      []consistencyViolation `json:"sample,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+}
+
+// runConsistencyChecks claims runKey, runs every check and stores the result. A run key that was
+// already claimed (another replica ran tonight's checks) returns (nil, nil).
+func (h *PaymentHandler) runConsistencyChecks(ctx context.Context, trigger, runKey string) (*models.ConsistencyRun, error) {
+	db := h.DB.WithContext(ctx)
+	run := models.ConsistencyRun{RunKey: runKey, Trigger: trigger}
+	if err := db.Create(&run).Error; err != nil {
+		if dbutil.IsUniqueViolation(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	results := make([]consistencyCheckResult, 0, len(consistencyChecks))
+	var failed []string
+	for _, check := range consistencyChecks {
+		res := consistencyCheckResult{Name: check.Name, Description: check.Description, EntityType: check.EntityType}
+		var rows []consistencyViolation
+		if err := db.Raw(check.SQL).Scan(&rows).Error; err != nil {
+			log.Printf("consistency: check=%s failed err=%v", check.Name, err)
+			res.Error = err.Error()
+			failed = append(failed, check.Name)
+		}
+		res.Violations = len(rows)
+		if len(rows) > consistencySampleSize {
+			rows = rows[:consistencySampleSize]
+		}
+		res.Sample = rows
+		run.Violations += res.Violations
+		results = append(results, res)
+	}
+
+	finished := time.Now()
+	run.FinishedAt = &finished
+	if raw, err := json.Marshal(results); err == nil {
+		run.Checks = datatypes.JSON(raw)
+	}
+	if len(failed) > 0 {
+		run.Error = "checks failed to run: " + strings.Join(failed, ", ")
+	}
+	if err := db.Save(&run).Error; err != nil {
+		return nil, err
+	}
+
+	publishConsistencyMetrics(results, finished)
+	log.Printf("consistency: run=%s violations=%d failed=%d", run.RunKey, run.Violations, len(failed))
+	if run.Violations > 0 || len(failed) > 0 {
+		h.sendAdminAlert(notify.Message{Subject: "Ledger consistency check found problems", Body: consistencyAlertBody(run, results)})
+	}
+	return &run, nil
+}
+
+// (helper for runConsistencyChecks) expose the latest run as expvar metrics.
+func publishConsistencyMetrics(results []consistencyCheckResult, at time.Time) {
+	total := 0
+	for _, r := range results {
+		v := new(expvar.Int)
+		v.Set(int64(r.Violations))
+		consistencyMetrics.Set(r.Name, v)
+		total += r.Violations
+	}
+	violations, last := new(expvar.Int), new(expvar.Int)
+	violations.Set(int64(total))
+	last.Set(at.Unix())
+	consistencyMetrics.Set("violations_total", violations)
+	consistencyMetrics.Set("last_run_unix", last)
+	consistencyMetrics.Add("runs", 1)
+}
+
+// (helper for runConsistencyChecks) one line per failing check with a few examples.
+func consistencyAlertBody(run models.ConsistencyRun, results []consistencyCheckResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Run %s found %d violation(s).\n", run.RunKey, run.Violations)
+	for _, r := range results {
+		switch {
+		case r.Error != "":
+			fmt.Fprintf(&b, "\n%s: could not run (%s)", r.Name, r.Error)
+		case r.Violations > 0:
+			fmt.Fprintf(&b, "\n%s (%s): %d violation(s)", r.Name, r.Description, r.Violations)
+			for i, v := range r.Sample {
+				if i == 3 {
+					break
+				}
+				fmt.Fprintf(&b, "\n  %s %s: %s", r.EntityType, v.EntityID, v.Detail)
+			}
+		}
+	}
+	b.WriteString("\n\nFull report: GET /api/v1/admin/consistency-checks/" + fmt.Sprint(run.ID))
+	return b.String()
+}
+
+// ListConsistencyRuns returns recent consistency runs, newest first (without samples). Query: limit/offset.
+func (h *PaymentHandler) ListConsistencyRuns(c *fiber.Ctx) error {
+	limit, offset := h.limitOffset(c)
+	runs := []models.ConsistencyRun{}
+	if err := h.db(c).Omit("checks").Order("created_at DESC, id DESC").Limit(limit).Offset(offset).Find(&runs).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve consistency runs").Wrap(err)
+	}
+	return c.JSON(fiber.Map{"consistency_runs": runs})
+}
+
+// GetConsistencyRun returns one run with every check's count and sample violations.
+func (h *PaymentHandler) GetConsistencyRun(c *fiber.Ctx) error {
+	var run models.ConsistencyRun
+	if err := h.db(c).First(&run, "id = ?", c.Params("id")).Error; err != nil {
+		return apperrors.ErrNotFound.WithMessage("Consistency run not found")
+	}
+	return c.JSON(run)
+}
+
+// RunConsistencyChecks runs the checks now, outside the consistency_checks job.
+func (h *PaymentHandler) RunConsistencyChecks(c *fiber.Ctx) error {
+	run, err := h.runConsistencyChecks(c.UserContext(), models.ConsistencyManual, fmt.Sprintf("%s:%d", models.ConsistencyManual, time.Now().UnixNano()))
+	if err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to run consistency checks").Wrap(err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(run)
+}
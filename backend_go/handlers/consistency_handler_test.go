@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+)
+
+func TestConsistencyAlertBody(t *testing.T) {
+	run := models.ConsistencyRun{ID: 7, RunKey: "nightly:2025-03-01", Violations: 5}
+	results := []consistencyCheckResult{
+		{Name: "charge_credited_once", Description: "credited once", EntityType: "charge", Violations: 5, Sample: []consistencyViolation{
+			{EntityID: "chrg_1", Detail: "a"}, {EntityID: "chrg_2", Detail: "b"}, {EntityID: "chrg_3", Detail: "c"}, {EntityID: "chrg_4", Detail: "d"},
+		}},
+		{Name: "refunds_within_charge"},
+		{Name: "no_orphan_refunds", Error: "boom"},
+	}
+	body := consistencyAlertBody(run, results)
+	for _, want := range []string{"5 violation(s)", "charge chrg_3: c", "no_orphan_refunds: could not run (boom)", "/api/v1/admin/consistency-checks/7"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("alert body missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "chrg_4") || strings.Contains(body, "refunds_within_charge") {
+		t.Errorf("alert body should list at most 3 examples and only failing checks:\n%s", body)
+	}
+}
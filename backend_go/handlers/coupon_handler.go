@@ -0,0 +1,103 @@
+// coupon_handler.go serves /admin/coupons: the discount codes payers apply to orders at charge time
+// (see service.CreateCharge).
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"github.com/gofiber/fiber/v2"
+)
+
+type createCouponRequest struct {
+	Code            string     `json:"code" validate:"required,max=40,alphanum"`
+	Kind            string     `json:"kind" validate:"required,oneof=percent fixed"`
+	PercentOff      int        `json:"percent_off" validate:"required_if=Kind percent,omitempty,min=1,max=100"`
+	AmountOffSatang int64      `json:"amount_off_satang" validate:"required_if=Kind fixed,omitempty,min=1"`
+	Currency        string     `json:"currency" validate:"required_if=Kind fixed,omitempty,currency"`
+	MaxRedemptions  int        `json:"max_redemptions" validate:"min=0"`
+	MaxPerUser      int        `json:"max_per_user" validate:"min=0"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`
+}
+
+// CreateCoupon creates an active coupon. Codes are matched case-insensitively and stored upper case.
+//
+//	POST /api/v1/admin/coupons {"code": "TERM1", "kind": "percent", "percent_off": 10, "max_per_user": 1}
+func (h *PaymentHandler) CreateCoupon(c *fiber.Ctx) error {
+	var req createCouponRequest
+	if err := parseAndValidate(c, &req); err != nil {
+		return err
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return apperrors.ErrValidation.WithMessage("expires_at must be in the future")
+	}
+	coupon := models.Coupon{
+		Code:           strings.ToUpper(req.Code),
+		Kind:           req.Kind,
+		MaxRedemptions: req.MaxRedemptions,
+		MaxPerUser:     req.MaxPerUser,
+		ExpiresAt:      req.ExpiresAt,
+		Active:         true,
+		CreatedBy:      adminActor(c),
+	}
+	if req.Kind == models.CouponPercent {
+		coupon.PercentOff = req.PercentOff
+	} else {
+		coupon.AmountOffSatang, coupon.Currency = req.AmountOffSatang, strings.ToUpper(req.Currency)
+	}
+	if err := h.db(c).Create(&coupon).Error; err != nil {
+		if dbutil.IsUniqueViolation(err) {
+			return apperrors.ErrConflict.WithMessagef("coupon %s already exists", coupon.Code)
+		}
+		return apperrors.ErrInternal.WithMessage("Failed to create coupon").Wrap(err)
+	}
+	h.audit(auditEntry(c, models.AuditCouponChange, "coupon", fmt.Sprintf("%d", coupon.ID), nil, coupon))
+	return c.Status(fiber.StatusCreated).JSON(coupon)
+}
+
+// ListCoupons returns a page of coupons, newest first; ?active=true lists only those still usable
+// (active and not expired).
+func (h *PaymentHandler) ListCoupons(c *fiber.Ctx) error {
+	limit, offset := h.limitOffset(c)
+	q := h.db(c).Model(&models.Coupon{})
+	if c.QueryBool("active") {
+		q = q.Where("active AND (expires_at IS NULL OR expires_at > ?)", time.Now())
+	}
+	coupons := []models.Coupon{}
+	if err := q.Order("created_at DESC, id DESC").Limit(limit).Offset(offset).Find(&coupons).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve coupons").Wrap(err)
+	}
+	return c.JSON(fiber.Map{"coupons": coupons, "pagination": fiber.Map{"limit": limit, "offset": offset}})
+}
+
+// DeactivateCoupon stops a coupon from being applied to new charges; charges already created with it
+// keep their discount.
+func (h *PaymentHandler) DeactivateCoupon(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return apperrors.ErrValidation.WithMessage("id must be a coupon id")
+	}
+	var coupon models.Coupon
+	if err := h.db(c).Take(&coupon, id).Error; err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperrors.ErrNotFound.WithMessage("Coupon not found")
+		}
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve coupon").Wrap(err)
+	}
+	if !coupon.Active {
+		return c.JSON(coupon)
+	}
+	if err := h.db(c).Model(&coupon).Update("active", false).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to deactivate coupon").Wrap(err)
+	}
+	h.audit(auditEntry(c, models.AuditCouponChange, "coupon", fmt.Sprintf("%d", coupon.ID),
+		fiber.Map{"active": true}, fiber.Map{"active": false}))
+	return c.JSON(coupon)
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestCreateCouponValidatesKind(t *testing.T) {
+	h := NewPaymentHandler(nil, nil)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/coupons", h.CreateCoupon)
+
+	for name, body := range map[string]string{
+		"unknown kind":       `{"code": "TERM1", "kind": "bogo"}`,
+		"percent without %":  `{"code": "TERM1", "kind": "percent"}`,
+		"percent over 100":   `{"code": "TERM1", "kind": "percent", "percent_off": 120}`,
+		"fixed without cur":  `{"code": "TERM1", "kind": "fixed", "amount_off_satang": 5000}`,
+		"code with spaces":   `{"code": "TERM 1", "kind": "percent", "percent_off": 10}`,
+		"expired on arrival": `{"code": "TERM1", "kind": "percent", "percent_off": 10, "expires_at": "2020-01-01T00:00:00Z"}`,
+	} {
+		req := httptest.NewRequest("POST", "/coupons", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", name, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+// deadline.go bounds how long a request, and the Omise and DB calls made on its behalf, may run, so a
+// slow upstream cannot hold a Fiber worker forever.
+package handlers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Deadlines configures the context deadlines; zero leaves that kind of work unbounded.
+type Deadlines struct {
+	Request    time.Duration // API requests
+	Admin      time.Duration // /admin requests (reconciliations, imports, reports page through more data)
+	Background time.Duration // each goBackground task (auto-reload charge, tax submission, latency record)
+}
+
+// Deadline gives c.UserContext() the request deadline (the admin one under /admin); handlers pass it
+// to Omise and the DB, which give up with context.DeadlineExceeded once it passes. Streaming
+// responses write after the handler returns and are not bounded by it.
+func (h *PaymentHandler) Deadline(c *fiber.Ctx) error {
+	timeout := h.Deadlines.Request
+	if strings.Contains(c.Path()+"/", "/admin/") {
+		timeout = h.Deadlines.Admin
+	}
+	if timeout <= 0 {
+		return c.Next()
+	}
+	ctx, cancel := context.WithTimeout(c.UserContext(), timeout)
+	defer cancel()
+	c.SetUserContext(ctx)
+	return c.Next()
+}
+
+// db is h.DB bound to the request's context; c may be nil for work started by a job.
+func (h *PaymentHandler) db(c *fiber.Ctx) *gorm.DB {
+	if c == nil {
+		return h.DB
+	}
+	return h.DB.WithContext(c.UserContext())
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestDeadlineBoundsRequestContext(t *testing.T) {
+	h := NewPaymentHandler(nil, nil)
+	h.Deadlines = Deadlines{Request: 2 * time.Second, Admin: time.Minute}
+	var left time.Duration
+	var bounded bool
+	remaining := func(c *fiber.Ctx) error {
+		var deadline time.Time
+		deadline, bounded = c.UserContext().Deadline()
+		left = time.Until(deadline)
+		return c.SendStatus(fiber.StatusOK)
+	}
+	app := fiber.New()
+	api := app.Group("/api/v1", h.Deadline)
+	api.Get("/payments/transactions", remaining)
+	api.Get("/admin/usage", remaining)
+
+	get := func(path string) {
+		t.Helper()
+		if _, err := app.Test(httptest.NewRequest("GET", path, nil)); err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+	}
+
+	get("/api/v1/payments/transactions")
+	if !bounded || left <= 0 || left > 2*time.Second {
+		t.Errorf("API request: deadline set %v with %v left, want at most 2s", bounded, left)
+	}
+	get("/api/v1/admin/usage")
+	if !bounded || left <= 2*time.Second || left > time.Minute {
+		t.Errorf("admin request: deadline set %v with %v left, want the admin deadline", bounded, left)
+	}
+
+	h.Deadlines = Deadlines{}
+	get("/api/v1/payments/transactions")
+	if bounded {
+		t.Error("no deadline configured, but the request context has one")
+	}
+}
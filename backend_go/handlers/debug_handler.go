@@ -0,0 +1,25 @@
+// debug_handler.go publishes runtime diagnostics for /debug/vars next to the expvar "memstats" and
+// the Go profiles under /debug/pprof (both admin-only; see RegisterRoutes).
+package handlers
+
+import (
+	"expvar"
+	"runtime"
+	"time"
+)
+
+var processStart = time.Now()
+
+func init() {
+	// Cheap counters only; heap figures are in "memstats" (expvar's runtime.ReadMemStats snapshot).
+	expvar.Publish("runtime", expvar.Func(func() any {
+		return map[string]any{
+			"go_version":     runtime.Version(),
+			"goroutines":     runtime.NumGoroutine(),
+			"gomaxprocs":     runtime.GOMAXPROCS(0),
+			"num_cpu":        runtime.NumCPU(),
+			"cgo_calls":      runtime.NumCgoCall(),
+			"uptime_seconds": int64(time.Since(processStart).Seconds()),
+		}
+	}))
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestDebugRoutesRequireAdmin(t *testing.T) {
+	h := NewPaymentHandler(nil, nil)
+	h.AdminToken = "secret"
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	RegisterRoutes(app, h)
+
+	for _, path := range []string{"/debug/vars", "/debug/pprof/"} {
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		if resp.StatusCode != fiber.StatusUnauthorized {
+			t.Errorf("GET %s without token: status %d, want 401", path, resp.StatusCode)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/debug/vars", nil)
+	req.Header.Set("X-Admin-Token", "secret")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	var vars map[string]json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&vars); err != nil {
+		t.Fatalf("decode /debug/vars: %v", err)
+	}
+	for _, key := range []string{"memstats", "runtime", "consistency_checks"} {
+		if _, ok := vars[key]; !ok {
+			t.Errorf("/debug/vars missing %q", key)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+// dispute_handler.go contains the student dispute-intent flow and admin review of dispute cases.
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/money"
+	"github.com/a2n2k3p4/tutorium-backend/service"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Charges older than this cannot be refunded by us; the student must go through their bank.
+const disputeRefundWindow = 90 * 24 * time.Hour
+
+type disputeIntentRequest struct {
+	Reason      string `json:"reason" validate:"required,oneof=unrecognized duplicate wrong_amount service_not_received other"`
+	Description string `json:"description,omitempty" validate:"max=2000"`
+}
+
+// CreateDisputeIntent lets a student flag a charge as wrong. It freezes the disputed amount from their
+// balance, opens an internal review case, and tells them whether to wait for a refund or contact their bank.
+func (h *PaymentHandler) CreateDisputeIntent(c *fiber.Ctx) error {
+	userID := userIDFromHeaderOrQuery(c)
+	if userID == nil {
+		return apperrors.ErrValidation.WithMessage("user_id is required")
+	}
+	var req disputeIntentRequest
+	if err := parseAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	txn, err := h.Transactions.WithContext(c.UserContext()).Find(c.Params("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperrors.ErrNotFound.WithMessage("Transaction not found")
+		}
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve transaction").Wrap(err)
+	}
+	// Do not reveal other users' transactions. Institution members with "refund_request" act for the payer.
+	if txn.UserID == nil {
+		return apperrors.ErrNotFound.WithMessage("Transaction not found")
+	}
+	var requestedBy *uint
+	if *txn.UserID != *userID {
+		allowed, err := h.canRequestRefundFor(*userID, *txn.UserID)
+		if err != nil {
+			return apperrors.ErrInternal.WithMessage("Failed to check institution membership").Wrap(err)
+		}
+		if !allowed {
+			return apperrors.ErrNotFound.WithMessage("Transaction not found")
+		}
+		requestedBy, userID = userID, txn.UserID
+	}
+	if txn.Status != "successful" {
+		return apperrors.ErrConflict.WithMessagef("only successful charges can be disputed (status: %s)", txn.Status)
+	}
+
+	var existing int64
+	if err := h.db(c).Model(&models.DisputeCase{}).
+		Where("transaction_id = ? AND status = ?", txn.ID, models.DisputeCaseOpen).
+		Count(&existing).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to check dispute cases").Wrap(err)
+	}
+	if existing > 0 {
+		return apperrors.ErrConflict.WithMessage("a dispute for this transaction is already under review")
+	}
+
+	path := recommendDisputePath(req.Reason, txn.CreatedAt)
+	dc := models.DisputeCase{
+		TransactionID:        txn.ID,
+		TransactionCreatedAt: txn.CreatedAt,
+		UserID:               *userID,
+		RequestedBy:          requestedBy,
+		Reason:               req.Reason,
+		Description:          req.Description,
+		AmountSatang:         txn.AmountSatang,
+		Status:               models.DisputeCaseOpen,
+		RecommendedPath:      path,
+	}
+
+	err = dbutil.Transaction(h.db(c), "open_dispute", func(tx *gorm.DB) error {
+		users := h.Users.WithTx(tx)
+		user, err := users.GetForUpdate(*userID)
+		if err != nil {
+			return err
+		}
+		// Freeze what is still in the wallet; the rest may already have been spent.
+		frozen := txn.AmountSatang
+		if avail := money.FromMajor(user.Balance, money.THB).Amount; avail < frozen {
+			frozen = avail
+		}
+		dc.FrozenSatang = frozen
+
+		if frozen > 0 {
+			frozenTHB := money.New(frozen, money.THB).Major()
+			if err := users.Freeze(*userID, frozenTHB); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(&dc).Error; err != nil {
+			return err
+		}
+		entry := auditEntry(c, models.AuditBalanceFreeze, "user", fmt.Sprintf("%d", *userID),
+			fiber.Map{"balance": user.Balance, "frozen_balance": user.FrozenBalance},
+			fiber.Map{"frozen_satang": frozen, "dispute_case_id": dc.ID, "transaction_id": txn.ID})
+		entry.Reason = req.Reason
+		return writeAudit(tx, entry)
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperrors.ErrNotFound.WithMessage("User not found")
+		}
+		return apperrors.ErrInternal.WithMessage("Failed to open dispute case").Wrap(err)
+	}
+	h.audit(auditEntry(c, models.AuditDisputeOpen, "dispute_case", fmt.Sprintf("%d", dc.ID), nil, dc))
+
+	return c.Status(201).JSON(fiber.Map{
+		"case":             dc,
+		"recommended_path": path,
+		"next_steps":       disputeNextSteps(path),
+	})
+}
+
+// (helper for CreateDisputeIntent) prefer a direct refund; suggest the bank when the card may be
+// compromised or the charge is outside our refund window.
+func recommendDisputePath(reason string, chargedAt time.Time) string {
+	if reason == "unrecognized" || time.Since(chargedAt) > disputeRefundWindow {
+		return models.DisputePathBankDispute
+	}
+	return models.DisputePathRefund
+}
+
+func disputeNextSteps(path string) []string {
+	if path == models.DisputePathBankDispute {
+		return []string{
+			"We have frozen the disputed amount in your wallet while we review the charge.",
+			"If you did not make this payment, contact your card issuer or bank now and ask them to block the card.",
+			"Your bank can open a formal dispute (chargeback); this usually takes 30-90 days.",
+			"If we confirm an error first, we will refund you directly and let you know.",
+		}
+	}
+	return []string{
+		"We have frozen the disputed amount in your wallet while we review the charge.",
+		"Our team will review your case within 3 business days; most refunds go back to the original payment method within 7-14 days.",
+		"You do not need to contact your bank. Opening a bank dispute at the same time can delay the refund.",
+	}
+}
+
+// ---------------------- admin review ----------------------
+
+func (h *PaymentHandler) ListDisputeCases(c *fiber.Ctx) error {
+	q := h.db(c).Model(&models.DisputeCase{})
+	if v := c.Query("status"); v != "" {
+		q = q.Where("status = ?", v)
+	}
+	if v := c.Query("user_id"); v != "" {
+		q = q.Where("user_id = ?", v)
+	}
+	limit, offset := h.limitOffset(c)
+
+	var cases []models.DisputeCase
+	if err := q.Order("created_at DESC").Limit(limit).Offset(offset).Find(&cases).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve dispute cases").Wrap(err)
+	}
+	return c.JSON(fiber.Map{"dispute_cases": cases})
+}
+
+type resolveDisputeRequest struct {
+	Resolution string `json:"resolution"` // "refund" | "reject"
+	Note       string `json:"note"`
+}
+
+// ResolveDisputeCase closes an open case: "refund" refunds the charge on Omise and releases the frozen
+// amount; "reject" returns the frozen amount to the user's balance.
+func (h *PaymentHandler) ResolveDisputeCase(c *fiber.Ctx) error {
+	var req resolveDisputeRequest
+	if err := parseAndValidate(c, &req); err != nil {
+		return err
+	}
+	if req.Resolution != "refund" && req.Resolution != "reject" {
+		return apperrors.ErrValidation.WithMessage(`resolution must be "refund" or "reject"`)
+	}
+	if req.Note == "" {
+		return apperrors.ErrValidation.WithMessage("note is required")
+	}
+
+	var dc models.DisputeCase
+	if err := h.db(c).Preload("Transaction").First(&dc, "id = ?", c.Params("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperrors.ErrNotFound.WithMessage("Dispute case not found")
+		}
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve dispute case").Wrap(err)
+	}
+	if dc.Status != models.DisputeCaseOpen {
+		return apperrors.ErrConflict.WithMessagef("dispute case is already %s", dc.Status)
+	}
+	before := dc
+
+	if req.Resolution == "refund" {
+		refund, err := h.Payments.Refund(c.UserContext(), service.RefundInput{
+			MerchantID:   dc.Transaction.MerchantID,
+			ChargeID:     dc.Transaction.ChargeID,
+			AmountSatang: dc.AmountSatang,
+			Metadata:     map[string]interface{}{"dispute_case_id": fmt.Sprintf("%d", dc.ID)},
+		})
+		if err != nil {
+			return apperrors.ErrOmiseUnavailable.WithMessage("Failed to refund charge").Wrap(err)
+		}
+		dc.RefundID = refund.ID
+		dc.Status = models.DisputeCaseRefunded
+	} else {
+		dc.Status = models.DisputeCaseRejected
+	}
+	now := time.Now()
+	dc.Resolution = req.Note
+	dc.ResolvedBy = adminActor(c)
+	dc.ResolvedAt = &now
+
+	err := dbutil.Transaction(h.db(c), "resolve_dispute", func(tx *gorm.DB) error {
+		if dc.FrozenSatang > 0 {
+			frozenTHB := money.New(dc.FrozenSatang, money.THB).Major()
+			if err := h.Users.WithTx(tx).Unfreeze(dc.UserID, frozenTHB, dc.Status == models.DisputeCaseRejected); err != nil {
+				return err
+			}
+		}
+		if err := tx.Save(&dc).Error; err != nil {
+			return err
+		}
+
+		unfreeze := auditEntry(c, models.AuditBalanceUnfreeze, "user", fmt.Sprintf("%d", dc.UserID),
+			fiber.Map{"frozen_satang": dc.FrozenSatang},
+			fiber.Map{"returned_to_balance": dc.Status == models.DisputeCaseRejected, "dispute_case_id": dc.ID})
+		unfreeze.Reason = req.Note
+		if err := writeAudit(tx, unfreeze); err != nil {
+			return err
+		}
+		if dc.RefundID != "" {
+			refund := auditEntry(c, models.AuditRefund, "transaction", fmt.Sprintf("%d", dc.TransactionID),
+				nil, fiber.Map{"refund_id": dc.RefundID, "amount_satang": dc.AmountSatang})
+			refund.Reason = req.Note
+			if err := writeAudit(tx, refund); err != nil {
+				return err
+			}
+		}
+		resolve := auditEntry(c, models.AuditDisputeResolve, "dispute_case", fmt.Sprintf("%d", dc.ID), before, dc)
+		resolve.Reason = req.Note
+		return writeAudit(tx, resolve)
+	})
+	if err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to resolve dispute case").Wrap(err)
+	}
+	if dc.RefundID != "" {
+		h.goBackground(func(context.Context) { h.checkRefundBudget(time.Now()) })
+	}
+	return c.JSON(dc)
+}
@@ -0,0 +1,46 @@
+// errors.go renders apperrors as the standard {code, message, fields} envelope.
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/i18n"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ErrorHandler is the Fiber ErrorHandler: every error returned by a handler is rendered as an
+// apperrors.Error. Internal causes are logged, never returned to the client. The message is in the
+// Accept-Language language when i18n has one for the code; fields stay English.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		err = fromFiberError(fe)
+	}
+
+	apiErr := apperrors.From(err)
+	if apiErr.Status >= 500 {
+		log.Printf("error: %s %s -> %d %v", c.Method(), c.Path(), apiErr.Status, apiErr)
+	}
+	if msg, ok := i18n.Message(responseLang(c), apiErr.Code); ok {
+		apiErr = apiErr.WithMessage(msg)
+	}
+	return c.Status(apiErr.Status).JSON(apiErr)
+}
+
+// (helper for ErrorHandler) map Fiber's own errors (routing, body limits, ...) to stable codes.
+func fromFiberError(fe *fiber.Error) *apperrors.Error {
+	switch fe.Code {
+	case fiber.StatusNotFound:
+		return apperrors.ErrNotFound.WithMessage("route not found")
+	case fiber.StatusMethodNotAllowed:
+		return apperrors.ErrMethodNotAllowed
+	case fiber.StatusRequestEntityTooLarge:
+		return apperrors.New(fe.Code, "payload_too_large", "request body is too large")
+	}
+	if fe.Code >= 500 {
+		return apperrors.ErrInternal.Wrap(fe)
+	}
+	return apperrors.New(fe.Code, "bad_request", fe.Message)
+}
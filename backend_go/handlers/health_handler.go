@@ -0,0 +1,104 @@
+// health_handler.go serves liveness and readiness probes.
+package handlers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	dbPingTimeout = 2 * time.Second
+	// omiseCheckTimeout bounds the Omise check, well below the client's own HTTP timeout.
+	omiseCheckTimeout = 5 * time.Second
+	// omiseCheckTTL caches the Omise check so frequent probes don't spend API quota.
+	omiseCheckTTL = 30 * time.Second
+)
+
+// dependencyStatus is the per-dependency result reported by /health/ready.
+type dependencyStatus struct {
+	Status    string `json:"status"` // "ok" or "fail"
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+	Cached    bool   `json:"cached,omitempty"`
+}
+
+// healthCache holds the last Omise check; the zero value is ready to use.
+type healthCache struct {
+	mu      sync.Mutex
+	omise   dependencyStatus
+	checked time.Time
+}
+
+// Health is kept for existing uptime checks; it is the liveness probe.
+func (h *PaymentHandler) Health(c *fiber.Ctx) error {
+	return h.Live(c)
+}
+
+// Live reports that the process is up and serving HTTP. It checks no dependencies, so a database
+// or Omise outage never gets the pod restarted.
+func (h *PaymentHandler) Live(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"status": "ok"})
+}
+
+// Ready reports whether the service can take traffic: the DB answers a ping and Omise accepts our
+// credentials. Responds 503 with per-dependency status and latency when any check fails.
+func (h *PaymentHandler) Ready(c *fiber.Ctx) error {
+	checks := map[string]dependencyStatus{
+		"database": h.checkDatabase(c.UserContext()),
+		"omise":    h.checkOmise(c.UserContext()),
+	}
+
+	status, code := "ok", fiber.StatusOK
+	for _, d := range checks {
+		if d.Status != "ok" {
+			status, code = "fail", fiber.StatusServiceUnavailable
+		}
+	}
+	return c.Status(code).JSON(fiber.Map{"status": status, "checks": checks})
+}
+
+// (helper for Ready) ping the connection pool.
+func (h *PaymentHandler) checkDatabase(ctx context.Context) dependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, dbPingTimeout)
+	defer cancel()
+
+	start := time.Now()
+	sqlDB, err := h.DB.DB()
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+	return newDependencyStatus(start, err)
+}
+
+// (helper for Ready) retrieve the account with our secret key: proves both reachability and valid
+// credentials.
+func (h *PaymentHandler) checkOmise(ctx context.Context) dependencyStatus {
+	h.health.mu.Lock()
+	defer h.health.mu.Unlock()
+	if !h.health.checked.IsZero() && time.Since(h.health.checked) < omiseCheckTTL {
+		cached := h.health.omise
+		cached.Cached = true
+		return cached
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, omiseCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	_, err := h.Omise.RetrieveAccount(ctx)
+	h.health.omise = newDependencyStatus(start, err)
+	h.health.checked = time.Now()
+	return h.health.omise
+}
+
+func newDependencyStatus(start time.Time, err error) dependencyStatus {
+	d := dependencyStatus{Status: "ok", LatencyMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		d.Status = "fail"
+		d.Error = err.Error()
+	}
+	return d
+}
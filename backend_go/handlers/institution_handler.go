@@ -0,0 +1,267 @@
+// institution_handler.go contains institutional (shared payer) accounts: member management, the
+// institution's transaction list, and the permission checks used by charges and dispute intents.
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/a2n2k3p4/tutorium-backend/apiv1"
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type createInstitutionRequest struct {
+	Name        string `json:"name" validate:"required,max=120"`
+	PayerUserID uint   `json:"payer_user_id" validate:"required"`
+}
+
+type institutionMemberRequest struct {
+	CanView          bool `json:"can_view"`
+	CanPay           bool `json:"can_pay"`
+	CanRequestRefund bool `json:"can_request_refund"`
+}
+
+// institutionAccess is what the caller may do on one institution.
+type institutionAccess struct {
+	Institution models.Institution
+	ActorID     *uint                     // the caller's login (X-User-ID), nil for admins
+	Admin       bool                      // X-Admin-Token
+	Member      *models.InstitutionMember // nil unless the caller is a member
+}
+
+// can reports whether the caller holds perm; admins and the payer user hold every permission.
+func (a institutionAccess) can(perm string) bool {
+	return a.Admin || a.isPayer() || (a.Member != nil && a.Member.Can(perm))
+}
+
+// canManage reports whether the caller may change the member list.
+func (a institutionAccess) canManage() bool {
+	return a.Admin || a.isPayer()
+}
+
+func (a institutionAccess) isPayer() bool {
+	return a.ActorID != nil && *a.ActorID == a.Institution.PayerUserID
+}
+
+// CreateInstitution registers a shared payer account; the payer user's wallet is the institution's. Admin only.
+func (h *PaymentHandler) CreateInstitution(c *fiber.Ctx) error {
+	var req createInstitutionRequest
+	if err := parseAndValidate(c, &req); err != nil {
+		return err
+	}
+	if _, err := h.Users.WithContext(c.UserContext()).Get(req.PayerUserID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperrors.ErrValidation.WithMessagef("user %d does not exist", req.PayerUserID)
+		}
+		return apperrors.ErrInternal.WithMessage("Failed to create institution").Wrap(err)
+	}
+
+	inst := models.Institution{Name: req.Name, PayerUserID: req.PayerUserID, CreatedBy: adminActor(c)}
+	if err := h.db(c).Create(&inst).Error; err != nil {
+		if dbutil.IsUniqueViolation(err) {
+			return apperrors.ErrConflict.WithMessagef("user %d is already the payer of an institution", req.PayerUserID)
+		}
+		return apperrors.ErrInternal.WithMessage("Failed to create institution").Wrap(err)
+	}
+	h.audit(auditEntry(c, models.AuditInstitutionCreate, "institution", fmt.Sprintf("%d", inst.ID), nil, inst))
+	return c.Status(fiber.StatusCreated).JSON(inst)
+}
+
+// ListInstitutionMembers returns the institution's members. Payer user or admin.
+func (h *PaymentHandler) ListInstitutionMembers(c *fiber.Ctx) error {
+	access, err := h.institutionAccessFor(c, c.Params("id"))
+	if err != nil {
+		return err
+	}
+	if !access.canManage() {
+		return apperrors.ErrForbidden.WithMessage("only the institution's payer account can manage members")
+	}
+	members := []models.InstitutionMember{}
+	if err := h.db(c).Where("institution_id = ?", access.Institution.ID).Order("user_id").Find(&members).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve members").Wrap(err)
+	}
+	return c.JSON(fiber.Map{"institution": access.Institution, "members": members})
+}
+
+// PutInstitutionMember adds a member or replaces their permissions. Payer user or admin.
+func (h *PaymentHandler) PutInstitutionMember(c *fiber.Ctx) error {
+	access, err := h.institutionAccessFor(c, c.Params("id"))
+	if err != nil {
+		return err
+	}
+	if !access.canManage() {
+		return apperrors.ErrForbidden.WithMessage("only the institution's payer account can manage members")
+	}
+	memberID, err := strconv.ParseUint(c.Params("user_id"), 10, 32)
+	if err != nil {
+		return apperrors.ErrValidation.WithMessage("user_id must be a user id")
+	}
+	if uint(memberID) == access.Institution.PayerUserID {
+		return apperrors.ErrValidation.WithMessage("the payer account already holds every permission")
+	}
+	var req institutionMemberRequest
+	if err := parseAndValidate(c, &req); err != nil {
+		return err
+	}
+	if _, err := h.Users.WithContext(c.UserContext()).Get(uint(memberID)); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperrors.ErrNotFound.WithMessage("User not found")
+		}
+		return apperrors.ErrInternal.WithMessage("Failed to update member").Wrap(err)
+	}
+
+	var before *models.InstitutionMember
+	member := models.InstitutionMember{InstitutionID: access.Institution.ID, UserID: uint(memberID), AddedBy: requestActor(c)}
+	err = h.db(c).Where("institution_id = ? AND user_id = ?", member.InstitutionID, member.UserID).Take(&member).Error
+	switch {
+	case err == nil:
+		prev := member
+		before = &prev
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return apperrors.ErrInternal.WithMessage("Failed to update member").Wrap(err)
+	}
+	member.CanView, member.CanPay, member.CanRequestRefund = req.CanView, req.CanPay, req.CanRequestRefund
+	if err := h.db(c).Save(&member).Error; err != nil {
+		if dbutil.IsUniqueViolation(err) {
+			return apperrors.ErrConflict.WithMessage("member was added concurrently; retry")
+		}
+		return apperrors.ErrInternal.WithMessage("Failed to update member").Wrap(err)
+	}
+	h.audit(auditEntry(c, models.AuditInstitutionMember, "institution", fmt.Sprintf("%d", member.InstitutionID), before, member))
+	if before == nil {
+		return c.Status(fiber.StatusCreated).JSON(member)
+	}
+	return c.JSON(member)
+}
+
+// RemoveInstitutionMember revokes a member's access. Payer user or admin.
+func (h *PaymentHandler) RemoveInstitutionMember(c *fiber.Ctx) error {
+	access, err := h.institutionAccessFor(c, c.Params("id"))
+	if err != nil {
+		return err
+	}
+	if !access.canManage() {
+		return apperrors.ErrForbidden.WithMessage("only the institution's payer account can manage members")
+	}
+	var member models.InstitutionMember
+	if err := h.db(c).Where("institution_id = ? AND user_id = ?", access.Institution.ID, c.Params("user_id")).Take(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperrors.ErrNotFound.WithMessage("Member not found")
+		}
+		return apperrors.ErrInternal.WithMessage("Failed to remove member").Wrap(err)
+	}
+	if err := h.db(c).Delete(&member).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to remove member").Wrap(err)
+	}
+	h.audit(auditEntry(c, models.AuditInstitutionMember, "institution", fmt.Sprintf("%d", member.InstitutionID), member, nil))
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ListInstitutionTransactions lists charges billed to the institution, newest first; each carries the
+// acting_user_id of the member who made it. Query: acting_user_id, status, channel, limit/offset.
+// Requires the "view" permission.
+func (h *PaymentHandler) ListInstitutionTransactions(c *fiber.Ctx) error {
+	access, err := h.institutionAccessFor(c, c.Params("id"))
+	if err != nil {
+		return err
+	}
+	if !access.can(models.InstitutionPermView) {
+		return apperrors.ErrForbidden.WithMessage(`the "view" permission is required`)
+	}
+	f := repository.TransactionFilter{
+		UserID:       fmt.Sprintf("%d", access.Institution.PayerUserID),
+		ActingUserID: c.Query("acting_user_id"),
+		Status:       c.Query("status"),
+		Channel:      c.Query("channel"),
+	}
+	limit, offset := h.limitOffset(c)
+	transactions, total, err := h.Transactions.WithContext(c.UserContext()).List(f, limit, offset)
+	if err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve transactions").Wrap(err)
+	}
+	return c.JSON(fiber.Map{
+		"transactions": apiv1.FromTransactions(transactions, responseLang(c)),
+		"pagination": fiber.Map{
+			"total":  total,
+			"limit":  limit,
+			"offset": offset,
+		},
+	})
+}
+
+// institutionAccessFor loads the institution and the caller's membership. Callers that are neither an
+// admin, the payer nor a member get 404, so institution ids cannot be probed.
+func (h *PaymentHandler) institutionAccessFor(c *fiber.Ctx, id string) (institutionAccess, error) {
+	access := institutionAccess{Admin: h.adminTokenValid(c), ActorID: userIDFromHeaderOrQuery(c)}
+	if access.Admin {
+		access.ActorID = nil
+	}
+	if err := h.db(c).First(&access.Institution, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return access, apperrors.ErrNotFound.WithMessage("Institution not found")
+		}
+		return access, apperrors.ErrInternal.WithMessage("Failed to retrieve institution").Wrap(err)
+	}
+	if access.Admin || access.isPayer() {
+		return access, nil
+	}
+	if access.ActorID != nil {
+		var m models.InstitutionMember
+		err := h.db(c).Where("institution_id = ? AND user_id = ?", access.Institution.ID, *access.ActorID).Take(&m).Error
+		if err == nil {
+			access.Member = &m
+			return access, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return access, apperrors.ErrInternal.WithMessage("Failed to retrieve institution").Wrap(err)
+		}
+	}
+	return access, apperrors.ErrNotFound.WithMessage("Institution not found")
+}
+
+// (helper for CreateCharge) bill the charge to the institution: the payer user is charged and
+// credited, and the acting member is recorded as req.ActingUserID. The caller needs "pay".
+func (h *PaymentHandler) applyInstitutionCharge(c *fiber.Ctx, req *models.PaymentRequest) (*uint, error) {
+	access, err := h.institutionAccessFor(c, fmt.Sprintf("%d", *req.InstitutionID))
+	if err != nil {
+		return nil, err
+	}
+	if access.ActorID == nil {
+		return nil, apperrors.ErrValidation.WithMessage("X-User-ID of the acting member is required for institution charges")
+	}
+	if !access.can(models.InstitutionPermPay) {
+		return nil, apperrors.ErrForbidden.WithMessage(`the "pay" permission is required to charge this institution`)
+	}
+
+	payer := access.Institution.PayerUserID
+	metadata := make(map[string]interface{}, len(req.Metadata)+1)
+	for k, v := range req.Metadata {
+		metadata[k] = v
+	}
+	metadata["institution_id"] = fmt.Sprintf("%d", access.Institution.ID)
+	req.Metadata = metadata
+	req.UserID, req.ActingUserID = &payer, access.ActorID
+	return &payer, nil
+}
+
+// (helper for CreateDisputeIntent) whether actorID may request a refund of payerID's charges as a
+// member of the institution that payerID pays for.
+func (h *PaymentHandler) canRequestRefundFor(actorID, payerID uint) (bool, error) {
+	var m models.InstitutionMember
+	err := h.DB.Joins("JOIN institutions ON institutions.id = institution_members.institution_id AND institutions.deleted_at IS NULL").
+		Where("institutions.payer_user_id = ? AND institution_members.user_id = ?", payerID, actorID).
+		Take(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return m.Can(models.InstitutionPermRefundRequest), nil
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+)
+
+func TestInstitutionAccess(t *testing.T) {
+	payer, clerk, stranger := uint(10), uint(11), uint(12)
+	inst := models.Institution{ID: 1, PayerUserID: payer}
+	viewer := &models.InstitutionMember{InstitutionID: 1, UserID: clerk, CanView: true}
+
+	cases := []struct {
+		name      string
+		access    institutionAccess
+		perm      string
+		want      bool
+		canManage bool
+	}{
+		{"admin", institutionAccess{Institution: inst, Admin: true}, models.InstitutionPermPay, true, true},
+		{"payer", institutionAccess{Institution: inst, ActorID: &payer}, models.InstitutionPermRefundRequest, true, true},
+		{"member view", institutionAccess{Institution: inst, ActorID: &clerk, Member: viewer}, models.InstitutionPermView, true, false},
+		{"member pay", institutionAccess{Institution: inst, ActorID: &clerk, Member: viewer}, models.InstitutionPermPay, false, false},
+		{"stranger", institutionAccess{Institution: inst, ActorID: &stranger}, models.InstitutionPermView, false, false},
+	}
+	for _, tc := range cases {
+		if got := tc.access.can(tc.perm); got != tc.want {
+			t.Errorf("%s: can(%s) = %v, want %v", tc.name, tc.perm, got, tc.want)
+		}
+		if got := tc.access.canManage(); got != tc.canManage {
+			t.Errorf("%s: canManage = %v, want %v", tc.name, got, tc.canManage)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+// language.go picks the language of the student-facing text in a response from Accept-Language.
+package handlers
+
+import (
+	"github.com/a2n2k3p4/tutorium-backend/i18n"
+	"github.com/gofiber/fiber/v2"
+)
+
+// responseLang is the language to localize c's response in (i18n.English or i18n.Thai). It sets
+// Content-Language, and Vary so caches keep the languages apart.
+func responseLang(c *fiber.Ctx) string {
+	lang := i18n.Negotiate(c.Get(fiber.HeaderAcceptLanguage))
+	c.Set(fiber.HeaderContentLanguage, lang)
+	c.Vary(fiber.HeaderAcceptLanguage)
+	return lang
+}
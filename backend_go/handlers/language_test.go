@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestErrorMessageLocalized(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/", func(c *fiber.Ctx) error {
+		return apperrors.ErrConflict.WithCode("coupon_expired").WithMessage("coupon TERM1 expired on 2026-06-01")
+	})
+
+	for _, tc := range []struct {
+		acceptLanguage, wantLang, wantMessage string
+	}{
+		{"", "en", "coupon TERM1 expired on 2026-06-01"},
+		{"th-TH,th;q=0.9,en;q=0.8", "th", "คูปองหมดอายุแล้ว"},
+		{"en-US,th;q=0.5", "en", "coupon TERM1 expired on 2026-06-01"},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if tc.acceptLanguage != "" {
+			req.Header.Set("Accept-Language", tc.acceptLanguage)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body apperrors.Error
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Code != "coupon_expired" || body.Message != tc.wantMessage {
+			t.Errorf("%q: got %s %q, want coupon_expired %q", tc.acceptLanguage, body.Code, body.Message, tc.wantMessage)
+		}
+		if got := resp.Header.Get("Content-Language"); got != tc.wantLang {
+			t.Errorf("%q: Content-Language %q, want %q", tc.acceptLanguage, got, tc.wantLang)
+		}
+	}
+}
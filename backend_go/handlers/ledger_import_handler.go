@@ -0,0 +1,278 @@
+// ledger_import_handler.go is the admin bulk import used to migrate balances and transaction history
+// from the legacy tutorium backend without direct SQL inserts.
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/money"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ledgerImportMaxRows bounds one batch so it fits in a single DB transaction; split larger exports.
+const ledgerImportMaxRows = 1000
+
+// Ledger import row kinds.
+const (
+	ledgerRowBalance     = "balance"     // opening balance: credited to the user's wallet once
+	ledgerRowTransaction = "transaction" // historical charge: recorded as-is, balances untouched
+)
+
+// Ledger import row results.
+const (
+	ledgerRowOK       = "ok"       // imported (or would be, on a dry run)
+	ledgerRowSkipped  = "skipped"  // already imported by an earlier batch; idempotent re-runs are safe
+	ledgerRowRejected = "rejected" // invalid; see errors
+)
+
+// ledgerImportRow is one line of the legacy export. Ref must be unique and stable across re-runs: it
+// keys the idempotency of the import.
+type ledgerImportRow struct {
+	Ref         string     `json:"ref" validate:"required,max=80"`
+	Kind        string     `json:"kind" validate:"required,oneof=balance transaction"`
+	UserID      uint       `json:"user_id" validate:"required"`
+	Amount      int64      `json:"amount" validate:"gt=0"`                            // satang
+	Currency    string     `json:"currency,omitempty" validate:"omitempty,oneof=thb"` // the legacy ledger is in THB only
+	Status      string     `json:"status,omitempty" validate:"required_if=Kind transaction,omitempty,oneof=successful failed reversed expired"`
+	Channel     string     `json:"channel,omitempty" validate:"max=40"`
+	OccurredAt  *time.Time `json:"occurred_at,omitempty" validate:"required_if=Kind transaction"`
+	Description string     `json:"description,omitempty" validate:"max=255"`
+}
+
+type ledgerImportRequest struct {
+	DryRun bool              `json:"dry_run"`
+	Rows   []ledgerImportRow `json:"rows"`
+}
+
+// ledgerRowResult reports what happened to one row.
+type ledgerRowResult struct {
+	Row    int                    `json:"row"` // 0-based index in the request
+	Ref    string                 `json:"ref"`
+	Kind   string                 `json:"kind"`
+	Result string                 `json:"result"`
+	Errors []apperrors.FieldError `json:"errors,omitempty"`
+}
+
+// ImportLedger validates a batch of legacy ledger rows and, unless dry_run is set, imports it in one DB
+// transaction. Any rejected row rejects the whole batch (422 with per-row errors) so a migration
+// never ends up half applied; rows already imported are skipped, so a corrected batch can be re-sent.
+func (h *PaymentHandler) ImportLedger(c *fiber.Ctx) error {
+	var req ledgerImportRequest
+	if err := c.BodyParser(&req); err != nil {
+		return apperrors.ErrBadRequest.WithCode("invalid_body").WithMessage("request body must be valid JSON matching the documented schema")
+	}
+	if len(req.Rows) == 0 || len(req.Rows) > ledgerImportMaxRows {
+		return apperrors.ErrValidation.WithMessagef("rows must contain between 1 and %d entries", ledgerImportMaxRows)
+	}
+	if c.QueryBool("dry_run") {
+		req.DryRun = true
+	}
+
+	results, err := h.checkLedgerRows(req.Rows)
+	if err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to validate ledger rows").Wrap(err)
+	}
+	summary := summarizeLedgerImport(results)
+	summary["dry_run"] = req.DryRun
+	summary["rows"] = results
+	if summary["rejected"].(int) > 0 {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(summary)
+	}
+	if req.DryRun {
+		return c.JSON(summary)
+	}
+
+	batchID := fmt.Sprintf("ledger-import-%d", time.Now().UnixNano())
+	err = dbutil.Transaction(h.db(c), "ledger_import", func(tx *gorm.DB) error {
+		for i, row := range req.Rows {
+			if results[i].Result != ledgerRowOK {
+				continue
+			}
+			if err := h.importLedgerRow(c, tx, batchID, row); err != nil {
+				return fmt.Errorf("row %d (%s): %w", i, row.Ref, err)
+			}
+		}
+		return writeAudit(tx, auditEntry(c, models.AuditLedgerImport, "ledger_import", batchID, nil,
+			fiber.Map{"imported": summary["ok"], "skipped": summary["skipped"]}))
+	})
+	if err != nil {
+		if dbutil.IsUniqueViolation(err) {
+			return apperrors.ErrConflict.WithMessage("another import of the same rows is in progress; retry to skip them").Wrap(err)
+		}
+		return apperrors.ErrInternal.WithMessage("Failed to import ledger").Wrap(err)
+	}
+	log.Printf("ledger import: batch=%s imported=%d skipped=%d", batchID, summary["ok"], summary["skipped"])
+	h.Payments.TransactionCache.InvalidateTotals(c.UserContext())
+
+	summary["batch_id"] = batchID
+	return c.Status(fiber.StatusCreated).JSON(summary)
+}
+
+// (helper for ImportLedger) validate every row and mark the ones a previous import already applied.
+func (h *PaymentHandler) checkLedgerRows(rows []ledgerImportRow) ([]ledgerRowResult, error) {
+	results := make([]ledgerRowResult, len(rows))
+	seen := map[string]int{}
+	users := map[uint]bool{}
+	for i := range rows {
+		row := &rows[i]
+		row.Ref = strings.TrimSpace(row.Ref)
+		row.Currency = strings.ToLower(row.Currency)
+		res := ledgerRowResult{Row: i, Ref: row.Ref, Kind: row.Kind, Result: ledgerRowOK}
+
+		if err := validate.Struct(row); err != nil {
+			var verrs validator.ValidationErrors
+			if errors.As(err, &verrs) {
+				for _, fe := range verrs {
+					res.Errors = append(res.Errors, apperrors.FieldError{Field: fe.Field(), Rule: fe.Tag(), Message: fieldMessage(fe)})
+				}
+			}
+		}
+		if first, dup := seen[row.Ref]; dup && row.Ref != "" {
+			res.Errors = append(res.Errors, apperrors.FieldError{Field: "ref", Rule: "unique", Message: fmt.Sprintf("ref duplicates row %d", first)})
+		} else {
+			seen[row.Ref] = i
+		}
+		if row.OccurredAt != nil && row.OccurredAt.After(time.Now()) {
+			res.Errors = append(res.Errors, apperrors.FieldError{Field: "occurred_at", Rule: "past", Message: "occurred_at must not be in the future"})
+		}
+
+		if len(res.Errors) == 0 {
+			exists, checked := users[row.UserID]
+			if !checked {
+				_, err := h.Users.Get(row.UserID)
+				if err != nil && !errors.Is(err, repository.ErrNotFound) {
+					return nil, err
+				}
+				exists = err == nil
+				users[row.UserID] = exists
+			}
+			if !exists {
+				res.Errors = append(res.Errors, apperrors.FieldError{Field: "user_id", Rule: "exists", Message: fmt.Sprintf("user %d does not exist", row.UserID)})
+			}
+		}
+		if len(res.Errors) > 0 {
+			res.Result = ledgerRowRejected
+			results[i] = res
+			continue
+		}
+
+		imported, err := h.ledgerRowImported(*row)
+		if err != nil {
+			return nil, err
+		}
+		if imported {
+			res.Result = ledgerRowSkipped
+		}
+		results[i] = res
+	}
+	return results, nil
+}
+
+// (helper for checkLedgerRows) whether a row with this ref was imported before.
+func (h *PaymentHandler) ledgerRowImported(row ledgerImportRow) (bool, error) {
+	if row.Kind == ledgerRowBalance {
+		var n int64
+		err := h.DB.Model(&models.WalletOperation{}).Where("operation_id = ?", legacyOperationID(row.Ref)).Count(&n).Error
+		return n > 0, err
+	}
+	_, err := h.Transactions.Find(legacyChargeID(row.Ref))
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// (helper for ImportLedger) apply one validated row inside the batch transaction.
+func (h *PaymentHandler) importLedgerRow(c *fiber.Ctx, tx *gorm.DB, batchID string, row ledgerImportRow) error {
+	currency := row.Currency
+	if currency == "" {
+		currency = money.THB
+	}
+
+	if row.Kind == ledgerRowBalance {
+		// A wallet credit keyed like any other wallet operation, so re-runs replay instead of double-crediting.
+		op := models.WalletOperation{
+			OperationID:  legacyOperationID(row.Ref),
+			UserID:       row.UserID,
+			Kind:         models.WalletOpCredit,
+			AmountSatang: row.Amount,
+			Description:  row.Description,
+		}
+		if err := tx.Create(&op).Error; err != nil {
+			return err
+		}
+		users := h.Users.WithTx(tx)
+		amountTHB := money.New(row.Amount, currency).Major()
+		if err := users.Credit(row.UserID, amountTHB); err != nil {
+			return err
+		}
+		user, err := users.Get(row.UserID)
+		if err != nil {
+			return err
+		}
+		op.Balance, op.HeldBalance = user.Balance, user.HeldBalance
+		if err := tx.Model(&op).Select("balance", "held_balance").Updates(&op).Error; err != nil {
+			return err
+		}
+		entry := auditEntry(c, models.AuditBalanceCredit, "user", fmt.Sprintf("%d", row.UserID), nil,
+			fiber.Map{"operation_id": op.OperationID, "amount_satang": row.Amount, "balance": user.Balance, "batch_id": batchID})
+		entry.Reason = "legacy ledger import"
+		return writeAudit(tx, entry)
+	}
+
+	channel := row.Channel
+	if channel == "" {
+		channel = "legacy"
+	}
+	userID := row.UserID
+	t := models.Transaction{
+		CreatedAt:    *row.OccurredAt,
+		UpdatedAt:    *row.OccurredAt,
+		UserID:       &userID,
+		ChargeID:     legacyChargeID(row.Ref),
+		AmountSatang: row.Amount,
+		Currency:     currency,
+		Channel:      channel,
+		Status:       row.Status,
+		Meta: datatypes.JSONMap{
+			"legacy_ref":  row.Ref,
+			"import":      batchID,
+			"description": row.Description,
+		},
+	}
+	if err := h.Transactions.WithTx(tx).UpsertByChargeID(&t); err != nil {
+		return err
+	}
+	return tx.Create(&models.TransactionEvent{
+		CreatedAt: *row.OccurredAt, TransactionID: t.ID, ChargeID: t.ChargeID, NewStatus: t.Status, Source: models.TransactionEventImport,
+	}).Error
+}
+
+// legacyOperationID / legacyChargeID namespace legacy refs so they never collide with live ids.
+func legacyOperationID(ref string) string { return "legacy:" + ref }
+func legacyChargeID(ref string) string    { return "legacy_" + ref }
+
+// (helper for ImportLedger) counts per result.
+func summarizeLedgerImport(results []ledgerRowResult) fiber.Map {
+	counts := map[string]int{ledgerRowOK: 0, ledgerRowSkipped: 0, ledgerRowRejected: 0}
+	for _, r := range results {
+		counts[r.Result]++
+	}
+	return fiber.Map{
+		"total":    len(results),
+		"ok":       counts[ledgerRowOK],
+		"skipped":  counts[ledgerRowSkipped],
+		"rejected": counts[ledgerRowRejected],
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestImportLedgerReportsRowErrors(t *testing.T) {
+	h := NewPaymentHandler(nil, nil)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/import", h.ImportLedger)
+
+	body := `{"dry_run":true,"rows":[
+		{"ref":"a1","kind":"balance","amount":0},
+		{"ref":"t1","kind":"transaction","user_id":3,"amount":1000},
+		{"ref":"t1","kind":"refund","user_id":3,"amount":1000,"currency":"usd"}
+	]}`
+	req := httptest.NewRequest("POST", "/import", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	if resp.StatusCode != 422 {
+		t.Fatalf("status %d, want 422", resp.StatusCode)
+	}
+
+	var out struct {
+		Rejected int               `json:"rejected"`
+		Rows     []ledgerRowResult `json:"rows"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if out.Rejected != 3 || len(out.Rows) != 3 {
+		t.Fatalf("rejected=%d rows=%d, want 3/3", out.Rejected, len(out.Rows))
+	}
+	want := [][]string{
+		{"user_id", "amount"},
+		{"status", "occurred_at"},
+		{"kind", "currency", "ref"},
+	}
+	for i, fields := range want {
+		got := map[string]bool{}
+		for _, fe := range out.Rows[i].Errors {
+			got[fe.Field] = true
+		}
+		for _, f := range fields {
+			if !got[f] {
+				t.Errorf("row %d: no error for %s (got %+v)", i, f, out.Rows[i].Errors)
+			}
+		}
+	}
+}
+
+func TestImportLedgerRejectsEmptyBatch(t *testing.T) {
+	h := NewPaymentHandler(nil, nil)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/import", h.ImportLedger)
+
+	req := httptest.NewRequest("POST", "/import", strings.NewReader(`{"rows":[]}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	if resp.StatusCode != 400 {
+		t.Errorf("status %d, want 400", resp.StatusCode)
+	}
+}
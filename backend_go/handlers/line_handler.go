@@ -0,0 +1,174 @@
+// line_handler.go links users' LINE accounts (PUT/DELETE /users/:id/line) and pushes them payment
+// confirmations and PromptPay QR-expiry reminders through the LINE Messaging API.
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/notify"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"github.com/gofiber/fiber/v2"
+)
+
+// lineUserIDPattern is the shape of a LINE user id, as LINE Login and the Messaging API report it.
+var lineUserIDPattern = regexp.MustCompile(`^U[0-9a-f]{32}$`)
+
+type linkLineRequest struct {
+	LineUserID string `json:"line_user_id" validate:"required"`
+}
+
+// LinkLine stores the user's LINE user id (from LINE Login) so payment notifications reach them on
+// LINE; the user must also add the Official Account as a friend. Allowed for the user themself
+// (X-User-ID) or an admin; one LINE account links to one user.
+//
+//	PUT /api/v1/users/:id/line {"line_user_id": "U4af4980629..."}
+func (h *PaymentHandler) LinkLine(c *fiber.Ctx) error {
+	userID, err := h.ownUserID(c)
+	if err != nil {
+		return err
+	}
+	var req linkLineRequest
+	if err := parseAndValidate(c, &req); err != nil {
+		return err
+	}
+	if !lineUserIDPattern.MatchString(req.LineUserID) {
+		return apperrors.ErrValidation.WithMessage(`line_user_id must be a LINE user id ("U" and 32 hex digits)`)
+	}
+	res := h.db(c).Model(&models.User{}).Where("id = ?", userID).Update("line_user_id", req.LineUserID)
+	if res.Error != nil {
+		if dbutil.IsUniqueViolation(res.Error) {
+			return apperrors.ErrConflict.WithMessage("this LINE account is linked to another user")
+		}
+		return apperrors.ErrInternal.WithMessage("Failed to link LINE account").Wrap(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return apperrors.ErrNotFound.WithMessage("User not found")
+	}
+	return c.JSON(fiber.Map{"user_id": userID, "line_user_id": req.LineUserID})
+}
+
+// UnlinkLine removes the user's LINE user id; LINE notifications stop. Same access as LinkLine.
+func (h *PaymentHandler) UnlinkLine(c *fiber.Ctx) error {
+	userID, err := h.ownUserID(c)
+	if err != nil {
+		return err
+	}
+	res := h.db(c).Model(&models.User{}).Where("id = ?", userID).Update("line_user_id", nil)
+	if res.Error != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to unlink LINE account").Wrap(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return apperrors.ErrNotFound.WithMessage("User not found")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// (helper for the /users/:id/line and /users/:id/devices routes) the :id user, when the caller may
+// change it.
+func (h *PaymentHandler) ownUserID(c *fiber.Ctx) (uint, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return 0, apperrors.ErrValidation.WithMessage("id must be a user id")
+	}
+	userID := uint(id)
+	if !h.adminTokenValid(c) {
+		// Do not reveal whether other users exist.
+		if self := userIDFromHeaderOrQuery(c); self == nil || *self != userID {
+			return 0, apperrors.ErrNotFound.WithMessage("User not found")
+		}
+	}
+	return userID, nil
+}
+
+// linePayment is the data of the LINE and push templates (notify.TemplateLine*, notify.TemplatePush*).
+type linePayment struct {
+	Amount, Channel, Time, ChargeID string
+}
+
+// lineBatch bounds the reminders one line_qr_reminders run sends.
+const lineBatch = 100
+
+// pushLinePaymentConfirmation tells the payer of transactionID on LINE that their payment arrived,
+// when LINE is configured and the payer has linked an account.
+func (h *PaymentHandler) pushLinePaymentConfirmation(ctx context.Context, transactionID uint) {
+	t, err := h.Transactions.WithContext(ctx).Get(transactionID)
+	if err != nil {
+		log.Printf("line: transaction=%d lookup failed err=%v", transactionID, err)
+		return
+	}
+	if _, err := h.pushLine(ctx, *t, models.LineNotifyPaymentSucceeded, notify.TemplateLinePaymentSucceeded); err != nil {
+		log.Printf("line: transaction=%d confirmation failed err=%v", t.ID, err)
+	}
+}
+
+// remindExpiringQRs is the line_qr_reminders job: it reminds, once, every user with a linked LINE
+// account whose pending PromptPay QR expires within lead. It returns how many were reminded.
+func (h *PaymentHandler) remindExpiringQRs(ctx context.Context, now time.Time, lead time.Duration) (int, error) {
+	var expiring []models.Transaction
+	if err := h.DB.WithContext(ctx).Scopes(repository.OmitPayload).
+		Where("status = 'pending' AND channel = 'promptpay' AND expires_at > ? AND expires_at <= ?", now, now.Add(lead)).
+		Where("user_id IN (SELECT id FROM users WHERE line_user_id IS NOT NULL)").
+		Where("NOT EXISTS (SELECT 1 FROM line_notifications n WHERE n.transaction_id = transactions.id AND n.kind = ?)", models.LineNotifyQRExpiring).
+		Order("expires_at").Limit(lineBatch).Find(&expiring).Error; err != nil {
+		return 0, err
+	}
+	reminded := 0
+	for _, t := range expiring {
+		if ctx.Err() != nil {
+			return reminded, ctx.Err()
+		}
+		sent, err := h.pushLine(ctx, t, models.LineNotifyQRExpiring, notify.TemplateLineQRExpiring)
+		if err != nil {
+			log.Printf("line: transaction=%d reminder failed err=%v", t.ID, err)
+			continue
+		}
+		if sent {
+			reminded++
+		}
+	}
+	return reminded, nil
+}
+
+// (helper for pushLinePaymentConfirmation and remindExpiringQRs) push template about t to its user's
+// LINE account, once per kind: the push is claimed in line_notifications first, and the claim is
+// dropped if LINE rejects it so a later run may retry. False when LINE is off, the user has no linked
+// account, or another replica or run already sent it.
+func (h *PaymentHandler) pushLine(ctx context.Context, t models.Transaction, kind, template string) (bool, error) {
+	if !h.Line.Enabled() || t.UserID == nil {
+		return false, nil
+	}
+	var user models.User
+	if err := h.DB.WithContext(ctx).Select("id", "line_user_id").Take(&user, *t.UserID).Error; err != nil || user.LineUserID == nil {
+		return false, nil
+	}
+	at := t.UpdatedAt
+	if kind == models.LineNotifyQRExpiring && t.ExpiresAt != nil {
+		at = *t.ExpiresAt
+	}
+	msg, err := notify.Render(template, linePayment{
+		Amount: t.Amount().String(), Channel: t.PaymentMethod(), Time: at.In(bangkok).Format("15:04"), ChargeID: t.ChargeID,
+	})
+	if err != nil {
+		return false, err
+	}
+	claim := models.LineNotification{TransactionID: t.ID, Kind: kind, UserID: user.ID, LineUserID: *user.LineUserID}
+	if err := h.DB.WithContext(ctx).Create(&claim).Error; err != nil {
+		if dbutil.IsUniqueViolation(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if err := h.Line.Push(*user.LineUserID, msg); err != nil {
+		h.DB.WithContext(ctx).Delete(&claim)
+		return false, fmt.Errorf("push: %w", err)
+	}
+	return true, nil
+}
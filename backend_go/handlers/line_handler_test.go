@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestLinkLineValidation(t *testing.T) {
+	h := NewPaymentHandler(nil, nil)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Put("/users/:id/line", h.LinkLine)
+
+	for _, tc := range []struct {
+		self, body string
+		want       int
+	}{
+		{"7", `{"line_user_id": "not-a-line-id"}`, 400},
+		{"7", `{}`, 400},
+		{"8", `{"line_user_id": "U4af4980629a1b2c3d4e5f60718293a4b"}`, 404}, // someone else's account
+		{"", `{"line_user_id": "U4af4980629a1b2c3d4e5f60718293a4b"}`, 404},
+	} {
+		req := httptest.NewRequest("PUT", "/users/7/line", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		if tc.self != "" {
+			req.Header.Set("X-User-ID", tc.self)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tc.want {
+			t.Errorf("self %q body %s: status %d, want %d", tc.self, tc.body, resp.StatusCode, tc.want)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+// merchants.go picks the merchant (Omise account) a request's Omise calls go to: the X-Merchant header
+// on API requests, the endpoint on webhooks (see WebhookEndpoint.MerchantID).
+package handlers
+
+import (
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/gateway"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Merchant is one configured Omise account besides the default one; ID is its models.Merchant row.
+type Merchant struct {
+	Code string
+	ID   uint
+}
+
+// IdentifyMerchant sends the request's Omise calls, and the charges it creates, to the merchant named
+// by X-Merchant; without the header they go to the default account. An unknown merchant is rejected
+// so a charge is never created on the wrong account.
+func (h *PaymentHandler) IdentifyMerchant(c *fiber.Ctx) error {
+	code := c.Get("X-Merchant")
+	if code == "" || code == "default" {
+		return c.Next()
+	}
+	for _, m := range h.Merchants {
+		if m.Code == code {
+			c.SetUserContext(gateway.WithMerchant(c.UserContext(), m.ID))
+			return c.Next()
+		}
+	}
+	return apperrors.ErrValidation.WithCode("unknown_merchant").WithMessagef("unknown merchant %q", code)
+}
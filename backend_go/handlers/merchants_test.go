@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/a2n2k3p4/tutorium-backend/gateway"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestIdentifyMerchant(t *testing.T) {
+	h := NewPaymentHandler(nil, nil)
+	h.Merchants = []Merchant{{Code: "uni-partner", ID: 2}}
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/merchant", h.IdentifyMerchant, func(c *fiber.Ctx) error {
+		return c.SendString(fmt.Sprint(gateway.MerchantFrom(c.UserContext())))
+	})
+
+	for _, tc := range []struct {
+		header string
+		status int
+		body   string
+	}{
+		{"", fiber.StatusOK, "0"},
+		{"default", fiber.StatusOK, "0"},
+		{"uni-partner", fiber.StatusOK, "2"},
+		{"other", fiber.StatusBadRequest, ""},
+	} {
+		req := httptest.NewRequest("GET", "/merchant", nil)
+		if tc.header != "" {
+			req.Header.Set("X-Merchant", tc.header)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != tc.status || (tc.status == fiber.StatusOK && string(body) != tc.body) {
+			t.Errorf("X-Merchant %q: %d %s, want %d %s", tc.header, resp.StatusCode, body, tc.status, tc.body)
+		}
+	}
+}
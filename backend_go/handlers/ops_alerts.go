@@ -0,0 +1,129 @@
+// ops_alerts.go raises the on-call alerts about the payment path itself: a charge failure rate above
+// the threshold (the charge_failure_rate job), webhooks we failed to process, and Omise failing
+// for long enough to open the circuit breaker.
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/notify"
+)
+
+// OpsAlerts sets when the operational alerts fire; a zero FailureRate disables the failure rate check.
+type OpsAlerts struct {
+	FailureRate       float64       // failed share of settled charges (0-1) that raises an alert
+	FailureWindow     time.Duration // the charges looked at: those created this long before the check
+	FailureMinCharges int           // settled charges below which the window is too small to judge
+	Cooldown          time.Duration // minimum gap between alerts of one kind from this process
+}
+
+// Kinds of operational alert, throttled separately.
+const (
+	opsAlertFailureRate   = "charge_failure_rate"
+	opsAlertWebhookFailed = "webhook_failed"
+	opsAlertOmiseOutage   = "omise_outage"
+)
+
+// opsAlertState throttles operational alerts per kind; the zero value is ready to use.
+type opsAlertState struct {
+	mu    sync.Mutex
+	kinds map[string]*slaAlertState
+}
+
+// (helper for alertOps) the throttle of kind.
+func (s *opsAlertState) of(kind string) *slaAlertState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.kinds == nil {
+		s.kinds = map[string]*slaAlertState{}
+	}
+	if s.kinds[kind] == nil {
+		s.kinds[kind] = &slaAlertState{}
+	}
+	return s.kinds[kind]
+}
+
+// alertOps sends msg unless an alert of the same kind went out within OpsAlerts.Cooldown; it reports
+// whether it was sent. Held-back alerts are counted in the next one.
+func (h *PaymentHandler) alertOps(kind string, now time.Time, msg notify.Message) bool {
+	suppressed, ok := h.opsAlerts.of(kind).claim(now, h.OpsAlerts.Cooldown)
+	if !ok {
+		return false
+	}
+	if suppressed > 0 {
+		msg.Body += fmt.Sprintf("\n%d more alert(s) of this kind since the last one were not sent.", suppressed)
+	}
+	h.sendAdminAlert(msg)
+	return true
+}
+
+// chargeFailureRate is the outcome of the charges in one window.
+type chargeFailureRate struct {
+	Settled int64 // successful or failed
+	Failed  int64
+}
+
+// Rate is the failed share of the settled charges.
+func (r chargeFailureRate) Rate() float64 {
+	if r.Settled == 0 {
+		return 0
+	}
+	return float64(r.Failed) / float64(r.Settled)
+}
+
+// checkChargeFailureRate is the charge_failure_rate job: it alerts when, of the charges created in the
+// OpsAlerts.FailureWindow before now, at least FailureMinCharges have settled and the failed share of
+// them reaches FailureRate. It reports whether it alerted.
+func (h *PaymentHandler) checkChargeFailureRate(ctx context.Context, now time.Time) (bool, error) {
+	cfg := h.OpsAlerts
+	if cfg.FailureRate <= 0 {
+		return false, nil
+	}
+	from := now.Add(-cfg.FailureWindow)
+	var r chargeFailureRate
+	if err := dbutil.Replica(h.DB.WithContext(ctx)).Model(&models.Transaction{}).
+		Select(`COUNT(*) FILTER (WHERE status IN ('successful', 'failed')) AS settled,
+			COUNT(*) FILTER (WHERE status = 'failed') AS failed`).
+		Where("created_at >= ? AND created_at < ?", from, now).
+		Scan(&r).Error; err != nil {
+		return false, err
+	}
+	if r.Settled == 0 || r.Settled < int64(cfg.FailureMinCharges) || r.Rate() < cfg.FailureRate {
+		return false, nil
+	}
+	log.Printf("ops alerts: charge failure rate %.1f%% (%d of %d) since %s", r.Rate()*100, r.Failed, r.Settled, from.Format(time.RFC3339))
+	return h.alertOps(opsAlertFailureRate, now, notify.Message{
+		Subject: "Charge failure rate high",
+		Body: fmt.Sprintf("%.1f%% of the charges settled in the last %s failed (%d of %d; alert threshold %.1f%%).\n"+
+			"By channel: GET /api/v1/payments/stats?group_by=channel&from=%s&to=%s",
+			r.Rate()*100, cfg.FailureWindow, r.Failed, r.Settled, cfg.FailureRate*100,
+			from.UTC().Format(time.RFC3339), now.UTC().Format(time.RFC3339)),
+	}), nil
+}
+
+// alertWebhookFailed alerts that a webhook could not be processed and was answered with a 5xx: the
+// transaction stays stale until Omise's retry, or the reconcile job, gets through.
+func (h *PaymentHandler) alertWebhookFailed(ev webhookTailEvent) {
+	h.alertOps(opsAlertWebhookFailed, ev.ReceivedAt, notify.Message{
+		Subject: "Webhook processing failed",
+		Body: fmt.Sprintf("Webhook %s %s (endpoint %s, charge %s) failed: %s\nOmise will retry it; watch /api/v1/admin/webhooks/tail.",
+			ev.Object, ev.ObjectID, ev.Endpoint, ev.ChargeID, ev.Error),
+	})
+}
+
+// AlertOmiseOutage alerts that the Omise circuit breaker for account opened; main hands it to
+// gateway.Resilience.OnOpen.
+func (h *PaymentHandler) AlertOmiseOutage(account string, failures int, cooldown time.Duration) {
+	h.alertOps(opsAlertOmiseOutage, time.Now(), notify.Message{
+		Subject: "Omise is failing",
+		Body: fmt.Sprintf("%d consecutive Omise calls for %s failed with 5xx or network errors; calls fail fast for %s at a time until one succeeds.\n"+
+			"Charges cannot be created or verified meanwhile. Breaker state: /debug/vars (omise_gateway).",
+			failures, account, cooldown),
+	})
+}
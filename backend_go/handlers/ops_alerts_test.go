@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/notify"
+)
+
+func TestOpsAlertsThrottledPerKind(t *testing.T) {
+	var posted []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Content string `json:"content"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		posted = append(posted, body.Content)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	h := &PaymentHandler{Alerts: AlertTargets{DiscordWebhookURL: srv.URL}, OpsAlerts: OpsAlerts{Cooldown: time.Minute}}
+
+	now := time.Now()
+	msg := notify.Message{Subject: "Omise is failing", Body: "5 consecutive calls failed"}
+	if !h.alertOps(opsAlertOmiseOutage, now, msg) {
+		t.Fatal("first alert was held back")
+	}
+	if h.alertOps(opsAlertOmiseOutage, now.Add(10*time.Second), msg) {
+		t.Fatal("second alert of the kind inside the cooldown was sent")
+	}
+	if !h.alertOps(opsAlertWebhookFailed, now.Add(10*time.Second), notify.Message{Subject: "Webhook processing failed"}) {
+		t.Fatal("an alert of another kind was held back by the first kind's cooldown")
+	}
+	if !h.alertOps(opsAlertOmiseOutage, now.Add(2*time.Minute), msg) {
+		t.Fatal("alert after the cooldown was held back")
+	}
+
+	if len(posted) != 3 {
+		t.Fatalf("posted %d alerts, want 3: %q", len(posted), posted)
+	}
+	if !strings.HasPrefix(posted[0], "**Omise is failing**\n") {
+		t.Errorf("Discord content = %q, want the bold subject first", posted[0])
+	}
+	if !strings.Contains(posted[2], "1 more alert(s)") {
+		t.Errorf("alert after the cooldown = %q, want the held-back count", posted[2])
+	}
+}
+
+func TestChargeFailureRate(t *testing.T) {
+	if r := (chargeFailureRate{}).Rate(); r != 0 {
+		t.Errorf("rate with no charges = %v, want 0", r)
+	}
+	if r := (chargeFailureRate{Settled: 40, Failed: 10}).Rate(); r != 0.25 {
+		t.Errorf("rate = %v, want 0.25", r)
+	}
+}
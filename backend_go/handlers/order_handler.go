@@ -0,0 +1,135 @@
+// order_handler.go serves /payments/orders: the orders (course and class items with their total) that
+// charges pay for. A charge references one with order_id; the order is marked paid when it succeeds.
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/a2n2k3p4/tutorium-backend/apiv1"
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/money"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"github.com/gofiber/fiber/v2"
+)
+
+type createOrderRequest struct {
+	UserID   *uint              `json:"user_id,omitempty"` // defaults to X-User-ID
+	Currency string             `json:"currency" validate:"required,currency"`
+	Items    []orderItemRequest `json:"items" validate:"required,min=1,max=50,dive"`
+}
+
+type orderItemRequest struct {
+	CourseID    uint   `json:"course_id" validate:"required"`
+	ClassID     *uint  `json:"class_id,omitempty"`
+	Description string `json:"description,omitempty" validate:"max=255"`
+	Quantity    int    `json:"quantity" validate:"omitempty,min=1,max=100"` // default 1
+	UnitSatang  int64  `json:"unit_satang" validate:"required,min=1"`
+}
+
+// CreateOrder creates a pending order from its items; the total is their sum, and must be an amount
+// one charge can pay.
+//
+//	POST /api/v1/payments/orders {"currency": "THB", "items": [{"course_id": 12, "unit_satang": 150000}]}
+func (h *PaymentHandler) CreateOrder(c *fiber.Ctx) error {
+	var req createOrderRequest
+	if err := parseAndValidate(c, &req); err != nil {
+		return err
+	}
+	order := models.Order{
+		UserID:    req.UserID,
+		Status:    models.OrderPending,
+		Currency:  strings.ToUpper(req.Currency),
+		CreatedBy: requestActor(c),
+	}
+	if order.UserID == nil {
+		order.UserID = userIDFromHeaderOrQuery(c)
+	}
+	for _, it := range req.Items {
+		qty := max(it.Quantity, 1)
+		item := models.OrderItem{CourseID: it.CourseID, ClassID: it.ClassID, Description: it.Description,
+			Quantity: qty, UnitSatang: it.UnitSatang, AmountSatang: int64(qty) * it.UnitSatang}
+		order.Items = append(order.Items, item)
+		order.TotalSatang += item.AmountSatang
+	}
+	// One charge must pay the order, so its total is bounded as a charge in its currency is.
+	if limits, _ := money.ChargeLimits(order.Currency); !limits.Contains(order.TotalSatang) {
+		return apperrors.ErrValidation.WithMessagef("order total %s must be between %s and %s", money.New(order.TotalSatang, order.Currency),
+			money.New(limits.Min, order.Currency), money.New(limits.Max, order.Currency))
+	}
+	if order.UserID != nil {
+		if _, err := h.Users.WithContext(c.UserContext()).Get(*order.UserID); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return apperrors.ErrValidation.WithMessagef("user %d does not exist", *order.UserID)
+			}
+			return apperrors.ErrInternal.WithMessage("Failed to create order").Wrap(err)
+		}
+	}
+
+	if err := h.Orders.WithContext(c.UserContext()).Create(&order); err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to create order").Wrap(err)
+	}
+	h.audit(auditEntry(c, models.AuditOrderCreate, "order", fmt.Sprintf("%d", order.ID), nil, order))
+	return c.Status(fiber.StatusCreated).JSON(order)
+}
+
+// GetOrder returns the order with its items and the transactions charged for it, newest first. Allowed
+// for the order's user (X-User-ID) or an admin.
+func (h *PaymentHandler) GetOrder(c *fiber.Ctx) error {
+	order, err := h.orderFor(c)
+	if err != nil {
+		return err
+	}
+	transactions := []models.Transaction{}
+	if err := h.db(c).Scopes(repository.OmitPayload).Where("order_id = ?", order.ID).Order("created_at DESC, id DESC").Find(&transactions).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve order").Wrap(err)
+	}
+	return c.JSON(fiber.Map{"order": order, "transactions": apiv1.FromTransactions(transactions, responseLang(c))})
+}
+
+// CancelOrder cancels a pending order; charges for it are refused from then on. A charge already
+// created for it that still succeeds leaves the order cancelled (see service.RecordCharge). Same access
+// as GetOrder.
+func (h *PaymentHandler) CancelOrder(c *fiber.Ctx) error {
+	order, err := h.orderFor(c)
+	if err != nil {
+		return err
+	}
+	cancelled, err := h.Orders.WithContext(c.UserContext()).Cancel(order.ID)
+	if err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to cancel order").Wrap(err)
+	}
+	if !cancelled {
+		return apperrors.ErrConflict.WithMessagef("order %d is %s", order.ID, order.Status)
+	}
+	before := order.Status
+	order.Status = models.OrderCancelled
+	h.audit(auditEntry(c, models.AuditOrderCancel, "order", fmt.Sprintf("%d", order.ID),
+		fiber.Map{"status": before}, fiber.Map{"status": order.Status}))
+	return c.JSON(order)
+}
+
+// (helper for GetOrder and CancelOrder) the :id order with its items, when the caller may see it.
+func (h *PaymentHandler) orderFor(c *fiber.Ctx) (*models.Order, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return nil, apperrors.ErrValidation.WithMessage("id must be an order id")
+	}
+	order, err := h.Orders.WithContext(c.UserContext()).Get(uint(id))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, apperrors.ErrNotFound.WithMessage("Order not found")
+		}
+		return nil, apperrors.ErrInternal.WithMessage("Failed to retrieve order").Wrap(err)
+	}
+	if !h.adminTokenValid(c) {
+		// Do not reveal whether other users' orders exist.
+		if self := userIDFromHeaderOrQuery(c); self == nil || order.UserID == nil || *self != *order.UserID {
+			return nil, apperrors.ErrNotFound.WithMessage("Order not found")
+		}
+	}
+	return order, nil
+}
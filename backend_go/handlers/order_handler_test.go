@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/a2n2k3p4/tutorium-backend/gateway/gatewaytest"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/repository/repotest"
+	"github.com/a2n2k3p4/tutorium-backend/service"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func TestCreateOrderValidatesItemsAndTotal(t *testing.T) {
+	h := NewPaymentHandler(nil, nil)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/orders", h.CreateOrder)
+
+	for name, body := range map[string]string{
+		"no items":       `{"currency": "THB", "items": []}`,
+		"no course":      `{"currency": "THB", "items": [{"unit_satang": 150000}]}`,
+		"bad currency":   `{"currency": "baht", "items": [{"course_id": 1, "unit_satang": 150000}]}`,
+		"below a charge": `{"currency": "THB", "items": [{"course_id": 1, "unit_satang": 500, "quantity": 3}]}`,
+		"above a charge": `{"currency": "THB", "items": [{"course_id": 1, "unit_satang": 10000000, "quantity": 2}]}`,
+	} {
+		req := httptest.NewRequest("POST", "/orders", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", name, resp.StatusCode)
+		}
+	}
+}
+
+func TestOrderLifecycle(t *testing.T) {
+	fake := gatewaytest.NewFake()
+	h := NewPaymentHandler(repotest.NewDB(), fake)
+	users, orders := repotest.NewUsers(models.User{Model: gorm.Model{ID: 7}}), repotest.NewOrders()
+	h.Users, h.Orders = users, orders
+	h.Payments.Users, h.Payments.Orders = users, orders
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/orders", h.CreateOrder)
+	app.Get("/orders/:id", h.GetOrder)
+	app.Post("/orders/:id/cancel", h.CancelOrder)
+	call := func(method, path, userID, body string) int {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-User-ID", userID)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+
+	if status := call("POST", "/orders", "7", `{"currency": "thb", "items": [{"course_id": 12, "unit_satang": 150000}, {"course_id": 12, "class_id": 3, "unit_satang": 75000, "quantity": 2}]}`); status != fiber.StatusCreated {
+		t.Fatalf("create: status %d, want 201", status)
+	}
+	order, err := orders.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != models.OrderPending || order.UserID == nil || *order.UserID != 7 || order.Currency != "THB" ||
+		order.TotalSatang != 300000 || len(order.Items) != 2 || order.Items[1].AmountSatang != 150000 {
+		t.Fatalf("created order = %+v, want user 7's pending THB order of 300000 satang with its 2 items", order)
+	}
+	if status := call("GET", "/orders/1", "8", ""); status != fiber.StatusNotFound {
+		t.Errorf("another user's get: status %d, want 404", status)
+	}
+	if status := call("POST", "/orders/1/cancel", "8", ""); status != fiber.StatusNotFound {
+		t.Errorf("another user's cancel: status %d, want 404", status)
+	}
+
+	if status := call("POST", "/orders/1/cancel", "7", ""); status != fiber.StatusOK {
+		t.Fatalf("cancel: status %d, want 200", status)
+	}
+	if status := call("POST", "/orders/1/cancel", "7", ""); status != fiber.StatusConflict {
+		t.Errorf("second cancel: status %d, want 409", status)
+	}
+	if order, _ := orders.Get(1); order.Status != models.OrderCancelled {
+		t.Errorf("order status = %s, want cancelled", order.Status)
+	}
+
+	uid, oid := uint(7), uint(1)
+	_, err = h.Payments.CreateCharge(context.Background(), models.PaymentRequest{PaymentType: "promptpay", OrderID: &oid}, &uid)
+	var inErr *service.InputError
+	if !errors.As(err, &inErr) || inErr.Code != "order_not_payable" {
+		t.Errorf("charging the cancelled order: err = %v, want InputError order_not_payable", err)
+	}
+	if calls := fake.Calls(); len(calls) != 0 {
+		t.Errorf("Omise was called %v for a cancelled order", calls)
+	}
+}
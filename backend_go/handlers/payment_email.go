@@ -0,0 +1,103 @@
+// payment_email.go emails payers a receipt when a top-up succeeds and what to do next when it is
+// declined, from the notify templates.
+package handlers
+
+import (
+	"context"
+	"log"
+	"strconv"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/money"
+	"github.com/a2n2k3p4/tutorium-backend/notify"
+)
+
+// failureAdvice is what the payer can do about a declined charge, by Omise failure code.
+var failureAdvice = map[string]string{
+	"insufficient_fund":      "Your card or account did not have enough funds. Try another card, or pay by PromptPay.",
+	"stolen_or_lost_card":    "Your bank reported this card as lost or stolen. Please pay with another card and contact your bank.",
+	"failed_fraud_check":     "Your bank held the payment as suspicious. Ask your bank to approve it, then try again.",
+	"invalid_security_code":  "The security code (CVV) was not accepted. Check the 3 digits on the back of your card and try again.",
+	"invalid_account_number": "The card number was not accepted. Check your card details and try again.",
+	"payment_rejected":       "Your bank rejected the payment. Contact your bank, or use another payment method.",
+	"failed_processing":      "The payment could not be processed. Please try again in a few minutes.",
+}
+
+const defaultFailureAdvice = "Please try again, or use another card or payment method. If it keeps failing, reply to this email."
+
+// paymentEmail is the data of the payment templates (notify.TemplatePaymentSucceeded and
+// notify.TemplatePaymentFailed).
+type paymentEmail struct {
+	Name, Amount, Channel, Time, ChargeID string
+	FailureMessage, Advice                string // declined charges only
+	TaxInvoiceNumber, Net, VAT, VATRate   string // successful charges with a tax invoice only
+}
+
+// sendPaymentEmail emails the payer of transactionID the template name, when payment emails are on
+// (PAYMENT_EMAILS) and SMTP is configured. The payer's address is the charge's metadata.email, else
+// the notify_email of the user's auto-reload setting; without one nothing is sent. Failures are logged.
+func (h *PaymentHandler) sendPaymentEmail(ctx context.Context, transactionID uint, name string) {
+	if !h.PaymentEmails || !h.Mailer.Enabled() {
+		return
+	}
+	t, err := h.Transactions.WithContext(ctx).Get(transactionID)
+	if err != nil {
+		log.Printf("payment email: transaction=%d lookup failed err=%v", transactionID, err)
+		return
+	}
+	to := h.paymentEmailRecipient(ctx, t)
+	if to == "" {
+		return
+	}
+
+	data := paymentEmail{
+		Name:     "there",
+		Amount:   t.Amount().String(),
+		Channel:  t.PaymentMethod(),
+		Time:     t.UpdatedAt.In(bangkok).Format("2 Jan 2006 15:04"),
+		ChargeID: t.ChargeID,
+		Advice:   defaultFailureAdvice,
+	}
+	if t.UserID != nil {
+		if u, err := h.Users.Get(*t.UserID); err == nil && u.FirstName != "" {
+			data.Name = u.FirstName
+		}
+	}
+	if t.TaxInvoiceNumber != nil {
+		data.TaxInvoiceNumber = *t.TaxInvoiceNumber
+		data.VAT = money.New(t.VATSatang, t.Currency).String()
+		data.Net = money.New(t.AmountSatang-t.VATSatang, t.Currency).String()
+		data.VATRate = strconv.FormatFloat(float64(t.VATRateBps)/100, 'f', -1, 64) + "%"
+	}
+	if t.FailureMessage != nil {
+		data.FailureMessage = *t.FailureMessage
+	}
+	if t.FailureCode != nil && failureAdvice[*t.FailureCode] != "" {
+		data.Advice = failureAdvice[*t.FailureCode]
+	}
+	msg, err := notify.Render(name, data)
+	if err != nil {
+		log.Printf("payment email: transaction=%d template=%s err=%v", t.ID, name, err)
+		return
+	}
+	if err := h.Mailer.Send(to, msg); err != nil {
+		log.Printf("payment email: transaction=%d send failed err=%v", t.ID, err)
+		return
+	}
+	log.Printf("payment email: transaction=%d template=%s sent", t.ID, name)
+}
+
+// (helper for sendPaymentEmail) where t's emails go; "" when there is no address.
+func (h *PaymentHandler) paymentEmailRecipient(ctx context.Context, t *models.Transaction) string {
+	if email, ok := t.Meta["email"].(string); ok && email != "" {
+		return email
+	}
+	if t.UserID == nil || h.DB == nil {
+		return ""
+	}
+	var setting models.AutoReload
+	if err := h.DB.WithContext(ctx).Select("notify_email").Where("user_id = ?", *t.UserID).Take(&setting).Error; err != nil {
+		return ""
+	}
+	return setting.NotifyEmail
+}
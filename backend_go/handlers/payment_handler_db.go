@@ -3,123 +3,318 @@ package handlers
 
 import (
 	"errors"
-	"log"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/a2n2k3p4/tutorium-backend/apiv1"
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
 	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"github.com/a2n2k3p4/tutorium-backend/service"
 	"github.com/gofiber/fiber/v2"
 	omise "github.com/omise/omise-go"
-	"github.com/omise/omise-go/operations"
-	"gorm.io/gorm"
 )
 
+// CreateCharge validates the request and hands it to the payment service (see service.CreateCharge).
 func (h *PaymentHandler) CreateCharge(c *fiber.Ctx) error {
 	var req models.PaymentRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid request: " + err.Error()})
-	}
-	if req.Amount <= 0 || req.Currency == "" {
-		return c.Status(400).JSON(fiber.Map{"error": "amount and currency are required"})
+	if err := parseAndValidate(c, &req); err != nil {
+		return err
 	}
 
+	req.ClientIP, req.ClientCountry = h.payerOrigin(c)
+
 	// Try to resolve user id from body/header/query
 	userID := h.getUserIDFromRequest(c, &req)
-
-	var (
-		charge *omise.Charge
-		err    error
-	)
-	switch req.PaymentType {
-	case "credit_card":
-		charge, err = h.processCreditCard(req)
-	case "promptpay":
-		charge, err = h.processPromptPay(req)
-	case "internet_banking":
-		charge, err = h.processInternetBanking(req)
-	default:
-		return c.Status(400).JSON(fiber.Map{"error": "unsupported paymentType: " + req.PaymentType})
+	if req.InstitutionID != nil {
+		payer, err := h.applyInstitutionCharge(c, &req)
+		if err != nil {
+			return err
+		}
+		userID = payer
 	}
+
+	charge, err := h.Payments.CreateCharge(c.UserContext(), req, userID)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return chargeError(err)
 	}
+	h.countUsage(c, models.UsageChargesCreated, 1)
+	h.countUsage(c, models.UsageChargeAmountSatang, charge.Amount)
+	return c.JSON(apiv1.FromCharge(charge, responseLang(c)))
+}
 
-	// Persist/Upsert a local transaction row (idempotent on charge_id)
-	if err := h.upsertTransactionFromCharge(charge, userID); err != nil {
-		log.Printf("Failed to save transaction: %v", err) // do not fail outward
+// payerOrigin is the client's address and, when a trusted proxy tells us (CountryHeader), its country.
+func (h *PaymentHandler) payerOrigin(c *fiber.Ctx) (ip, country string) {
+	if h.CountryHeader != "" {
+		country = c.Get(h.CountryHeader)
 	}
-
-	return c.JSON(charge)
+	return c.IP(), country
 }
 
-func (h *PaymentHandler) createCharge(op *operations.CreateCharge) (*omise.Charge, error) {
-	ch := &omise.Charge{}
-	if err := h.Client.Do(ch, op); err != nil {
-		return nil, err
+// chargeError maps service errors: input problems -> validation (with the service's code), velocity
+// limits -> 429 (with the service's code), repeated charges -> 409 duplicate_charge, blocked payers ->
+// 403 payer_blocked, payers from refused countries -> 403 country_not_allowed, charges denied by risk scoring -> 403 risk_denied, Omise rejections -> charge_failed with Omise's message, Omise 5xx and transport
+// failures -> provider_unavailable.
+func chargeError(err error) error {
+	var inErr *service.InputError
+	if errors.As(err, &inErr) {
+		return apperrors.ErrValidation.WithCode(inErr.Code).WithMessage(inErr.Message)
+	}
+	var limErr *service.LimitError
+	if errors.As(err, &limErr) {
+		return apperrors.ErrLimitExceeded.WithCode(limErr.Code).WithMessage(limErr.Message)
+	}
+	var dupErr *service.DuplicateChargeError
+	if errors.As(err, &dupErr) {
+		return apperrors.ErrConflict.WithCode("duplicate_charge").WithMessage(dupErr.Error())
 	}
-	return ch, nil
+	if errors.Is(err, service.ErrPayerBlocked) {
+		return apperrors.ErrForbidden.WithCode("payer_blocked").WithMessage("payments from this account, card or email are not accepted")
+	}
+	if errors.Is(err, service.ErrCountryNotAllowed) {
+		return apperrors.ErrForbidden.WithCode("country_not_allowed").WithMessage("payments are not accepted from your country")
+	}
+	if errors.Is(err, service.ErrRiskDenied) {
+		return apperrors.ErrForbidden.WithCode("risk_denied").WithMessage("this payment cannot be accepted; try another payment method or contact support")
+	}
+	var oerr *omise.Error
+	if errors.As(err, &oerr) {
+		if oerr.StatusCode >= 400 && oerr.StatusCode < 500 {
+			return apperrors.ErrChargeFailed.WithMessage(oerr.Message).Wrap(err)
+		}
+	}
+	// The service only talks to Omise, so anything else is a transport failure.
+	return apperrors.ErrOmiseUnavailable.Wrap(err)
 }
 
+// ListTransactions returns a page of transactions, newest first. The total comes from the transaction
+// cache when one is configured; ?no_cache=true reads it from the database. Filters and sort are those
+// of transactionFilterFromQuery.
+// Pages by limit/offset, or by ?cursor= (keyset, same cost at any depth; not combined with offset).
+// Every full page in the default order carries pagination.next_cursor, so such a listing can
+// continue by cursor; other orders page by offset only.
 func (h *PaymentHandler) ListTransactions(c *fiber.Ctx) error {
-	f := txFilters{
-		UserID:  c.Query("user_id"),
-		Status:  c.Query("status"),
-		Channel: c.Query("channel"),
+	f, err := transactionFilterFromQuery(c)
+	if err != nil {
+		return err
 	}
-	limit, offset := helpersParseLimitOffset(c.Query("limit"), c.Query("offset"))
+	limit, offset := h.limitOffset(c)
 
-	// count
+	var transactions []models.Transaction
 	var totalCount int64
-	if err := h.DB.Model(&models.Transaction{}).
-		Scopes(helpersApplyTxFilters(f)).
-		Count(&totalCount).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to count transactions: " + err.Error()})
+	pagination := fiber.Map{"limit": limit}
+	if raw := c.Query("cursor"); raw != "" {
+		if c.Query("offset") != "" {
+			return apperrors.ErrValidation.WithMessage("cursor and offset cannot be combined")
+		}
+		if !f.Order.IsDefault() {
+			return apperrors.ErrValidation.WithMessage("cursor only pages the default order (newest first)")
+		}
+		after, err := repository.ParseTransactionCursor(raw)
+		if err != nil {
+			return apperrors.ErrValidation.WithMessage("cursor is not a next_cursor from this endpoint")
+		}
+		transactions, totalCount, err = h.transactionCache(c).ListAfter(c.UserContext(), h.Transactions, f, &after, limit)
+		if err != nil {
+			return apperrors.ErrInternal.WithMessage("Failed to retrieve transactions").Wrap(err)
+		}
+	} else {
+		transactions, totalCount, err = h.transactionCache(c).List(c.UserContext(), h.Transactions, f, limit, offset)
+		if err != nil {
+			return apperrors.ErrInternal.WithMessage("Failed to retrieve transactions").Wrap(err)
+		}
+		pagination["offset"] = offset
 	}
-
-	// data (fresh query) — GORM scope keeps this concise. :contentReference[oaicite:3]{index=3}
-	var transactions []models.Transaction
-	if err := h.DB.Model(&models.Transaction{}).
-		Scopes(helpersApplyTxFilters(f)).
-		Preload("User").
-		Order("created_at DESC").
-		Limit(limit).Offset(offset).
-		Find(&transactions).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to retrieve transactions: " + err.Error()})
+	pagination["total"] = totalCount
+	if len(transactions) == limit && f.Order.IsDefault() {
+		pagination["next_cursor"] = repository.CursorAfter(transactions[len(transactions)-1]).String()
 	}
 
 	return c.JSON(fiber.Map{
-		"transactions": transactions,
-		"pagination": fiber.Map{
-			"total":  totalCount,
-			"limit":  limit,
-			"offset": offset,
-		},
+		"transactions": apiv1.FromTransactions(transactions, responseLang(c)),
+		"pagination":   pagination,
 	})
 }
 
+// transactionFilterFromQuery reads the transaction listing filters (ListTransactions,
+// ExportTransactions) from the query:
+// merchant_id, user_id, status and channel (comma-separated lists; status!= and channel!= exclude),
+// has_user (true/false), risk_decision (allow, flag or deny), q (full-text search of description, failure message and metadata),
+// min_amount/max_amount (satang, inclusive), from/to (created_at, see parseDateBound), and the sort:
+// ?sort= (created_at, amount_satang or status) and ?order= (asc or desc, the default).
+func transactionFilterFromQuery(c *fiber.Ctx) (repository.TransactionFilter, error) {
+	f := repository.TransactionFilter{
+		MerchantID:   c.Query("merchant_id"),
+		UserID:       c.Query("user_id"),
+		Status:       c.Query("status"),
+		Channel:      c.Query("channel"),
+		NotStatus:    c.Query("status!"),
+		NotChannel:   c.Query("channel!"),
+		Search:       strings.TrimSpace(c.Query("q")),
+		RiskDecision: c.Query("risk_decision"),
+	}
+	if v := c.Query("has_user"); v != "" {
+		hasUser, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, apperrors.ErrValidation.WithMessage("has_user must be true or false")
+		}
+		f.HasUser = &hasUser
+	}
+	var err error
+	if f.MinAmount, err = parseAmountBound("min_amount", c.Query("min_amount")); err != nil {
+		return f, err
+	}
+	if f.MaxAmount, err = parseAmountBound("max_amount", c.Query("max_amount")); err != nil {
+		return f, err
+	}
+	if f.MinAmount != nil && f.MaxAmount != nil && *f.MinAmount > *f.MaxAmount {
+		return f, apperrors.ErrValidation.WithMessage("min_amount must not exceed max_amount")
+	}
+	if f.From, err = parseDateBound("from", c.Query("from"), false); err != nil {
+		return f, err
+	}
+	if f.To, err = parseDateBound("to", c.Query("to"), true); err != nil {
+		return f, err
+	}
+	if !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To) {
+		return f, apperrors.ErrValidation.WithMessage("from must be before to")
+	}
+	if f.Order, err = repository.ParseTransactionOrder(c.Query("sort"), c.Query("order")); err != nil {
+		return f, apperrors.ErrValidation.WithMessage(err.Error())
+	}
+	return f, nil
+}
+
+// (helper for transactionFilterFromQuery) parses a min_amount/max_amount bound in satang; nil when v is empty.
+func parseAmountBound(param, v string) (*int64, error) {
+	if v == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return nil, apperrors.ErrValidation.WithMessagef("%s must be a non-negative amount in satang", param)
+	}
+	return &n, nil
+}
+
+// (helper for transactionFilterFromQuery) parses a from/to bound: RFC3339, or a YYYY-MM-DD day in Bangkok time.
+// A day is inclusive: as the upper bound it means the start of the next day, since To is exclusive.
+// The zero time when v is empty.
+func parseDateBound(param, v string, upper bool) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	day, err := time.ParseInLocation("2006-01-02", v, bangkok)
+	if err != nil {
+		return time.Time{}, apperrors.ErrValidation.WithMessagef("%s must be RFC3339 (e.g. 2025-10-01T00:00:00+07:00) or YYYY-MM-DD", param)
+	}
+	if upper {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day, nil
+}
+
+// GetTransaction returns one transaction by internal or charge id, through the transaction cache
+// unless ?no_cache=true.
 func (h *PaymentHandler) GetTransaction(c *fiber.Ctx) error {
 	id := c.Params("id")
 	if id == "" {
-		return c.Status(400).JSON(fiber.Map{"error": "id is required"})
+		return apperrors.ErrValidation.WithMessage("id is required")
 	}
 
-	var tx models.Transaction
-	// If numeric, treat as internal PK; else treat as ChargeID
-	if n, err := strconv.ParseUint(id, 10, 64); err == nil {
-		err = h.DB.Preload("User").First(&tx, uint(n)).Error
-		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to retrieve transaction: " + err.Error()})
+	tx, err := h.transactionCache(c).Find(c.UserContext(), h.Transactions, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperrors.ErrNotFound.WithMessage("Transaction not found")
 		}
-		if err == nil {
-			return c.JSON(tx)
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve transaction").Wrap(err)
+	}
+	return c.JSON(apiv1.FromTransaction(*tx, responseLang(c)))
+}
+
+type batchTransactionStatusRequest struct {
+	IDs []string `json:"ids" validate:"required,min=1,max=100,dive,required,max=64"` // internal or charge ids
+}
+
+// transactionStatus is one answer of BatchTransactionStatus.
+type transactionStatus struct {
+	ID            string     `json:"id"` // as requested
+	Found         bool       `json:"found"`
+	TransactionID uint       `json:"transaction_id,omitempty"`
+	ChargeID      string     `json:"charge_id,omitempty"`
+	Status        string     `json:"status,omitempty"`
+	AmountSatang  int64      `json:"amount_satang,omitempty"`
+	Currency      string     `json:"currency,omitempty"`
+	UpdatedAt     *time.Time `json:"updated_at,omitempty"`
+}
+
+// BatchTransactionStatus returns the current status of up to 100 transactions, by
+// internal or charge id like GetTransaction, in one round trip (the booking service verifies many
+// payments at once). Results follow the order of the request; unknown ids come back with found false.
+//
+//	POST /api/v1/payments/transactions/batch {"ids": ["chrg_test_1", "42"]}
+func (h *PaymentHandler) BatchTransactionStatus(c *fiber.Ctx) error {
+	var req batchTransactionStatusRequest
+	if err := parseAndValidate(c, &req); err != nil {
+		return err
+	}
+	rows, err := h.Transactions.WithContext(c.UserContext()).FindMany(req.IDs)
+	if err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve transactions").Wrap(err)
+	}
+	byID, byCharge := map[string]*models.Transaction{}, map[string]*models.Transaction{}
+	for i := range rows {
+		byID[strconv.FormatUint(uint64(rows[i].ID), 10)] = &rows[i]
+		byCharge[rows[i].ChargeID] = &rows[i]
+	}
+	results := make([]transactionStatus, len(req.IDs))
+	for i, id := range req.IDs {
+		results[i] = transactionStatus{ID: id}
+		t := byID[id] // an internal id wins over a charge id, as in Find
+		if t == nil {
+			t = byCharge[id]
+		}
+		if t != nil {
+			updated := t.UpdatedAt
+			results[i] = transactionStatus{ID: id, Found: true, TransactionID: t.ID, ChargeID: t.ChargeID, Status: t.Status,
+				AmountSatang: t.AmountSatang, Currency: t.Currency, UpdatedAt: &updated}
 		}
 	}
+	return c.JSON(fiber.Map{"results": results})
+}
 
-	// Fallback to ChargeID lookup
-	if err := h.DB.Preload("User").Where("charge_id = ?", id).First(&tx).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return c.Status(404).JSON(fiber.Map{"error": "Transaction not found"})
+// GetTransactionHistory returns a transaction's status history, oldest first: every status it moved
+// into, when, and what reported it (webhook, sync, job, import), so a dispute can show exactly when a
+// charge flipped to failed.
+func (h *PaymentHandler) GetTransactionHistory(c *fiber.Ctx) error {
+	tx, err := h.Transactions.WithContext(c.UserContext()).Find(c.Params("id"))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperrors.ErrNotFound.WithMessage("Transaction not found")
 		}
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to retrieve transaction: " + err.Error()})
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve transaction").Wrap(err)
+	}
+	events := []models.TransactionEvent{}
+	if err := h.db(c).Where("transaction_id = ?", tx.ID).Order("created_at, id").Find(&events).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve transaction history").Wrap(err)
+	}
+	return c.JSON(fiber.Map{
+		"transaction_id": tx.ID,
+		"charge_id":      tx.ChargeID,
+		"status":         tx.Status,
+		"events":         events,
+	})
+}
+
+// (helper for ListTransactions and GetTransaction) the transaction cache, or nil (read through to the
+// database) when the request asks to bypass it with ?no_cache=true.
+func (h *PaymentHandler) transactionCache(c *fiber.Ctx) *repository.TransactionCache {
+	if c.QueryBool("no_cache") {
+		return nil
 	}
-	return c.JSON(tx)
+	return h.Payments.TransactionCache
 }
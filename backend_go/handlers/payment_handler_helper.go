@@ -4,167 +4,75 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
-	"log"
 	"strconv"
 
 	"github.com/a2n2k3p4/tutorium-backend/models"
 	"github.com/gofiber/fiber/v2"
 	omise "github.com/omise/omise-go"
-	"gorm.io/datatypes"
-	"gorm.io/gorm"
-	"gorm.io/gorm/clause"
 )
 
-type txFilters struct {
-	UserID  string
-	Status  string
-	Channel string
-}
-
 // ---------------------- payment helpers ----------------------
-// (helper for ListTransactions) GORM scope for queries with optional filters: user, status, and channel.
-func helpersApplyTxFilters(f txFilters) func(*gorm.DB) *gorm.DB {
-	return func(db *gorm.DB) *gorm.DB {
-		if f.UserID != "" {
-			db = db.Where("user_id = ?", f.UserID)
-		}
-		if f.Status != "" {
-			db = db.Where("status = ?", f.Status)
-		}
-		if f.Channel != "" {
-			db = db.Where("channel = ?", f.Channel)
-		}
-		return db
-	}
-}
-
-// (helper for ListTransactions) safe pagination defaults.
-func helpersParseLimitOffset(limitStr, offsetStr string) (int, int) {
-	limit, offset := 50, 0
-	if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+// (helper for the listings) ?limit= and ?offset=: the limit defaults and is clamped to
+// Payments.PageSize, and an unparsable or negative value is ignored rather than refused.
+func (h *PaymentHandler) limitOffset(c *fiber.Ctx) (int, int) {
+	limit, offset := 0, 0
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil {
 		limit = l
 	}
-	if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
+	if o, err := strconv.Atoi(c.Query("offset")); err == nil && o >= 0 {
 		offset = o
 	}
-	return limit, offset
+	return h.Payments.PageSize.Limit(limit), offset
 }
 
 // ---------------------- webhook helpers ----------------------
-// (HandleWebhook helper) update-insert a local transaction row from Omise Charge
-// upsertTransactionFromCharge updates/creates the local transaction and adjusts user balance
-// only on status transitions across the "successful" boundary.
-func (h *PaymentHandler) upsertTransactionFromCharge(charge *omise.Charge, userID *uint) error {
-	if charge == nil {
-		return fmt.Errorf("nil charge")
-	}
-	userID = extractUserIDFromCharge(charge, userID)
-	channel := determineChannel(charge)
-	rawPayload, _ := json.Marshal(charge)
-
-	var meta datatypes.JSONMap
-	if charge.Metadata != nil {
-		meta = datatypes.JSONMap(charge.Metadata)
-	}
-
-	tx := h.DB.Begin()
-	if err := tx.Error; err != nil {
-		return err
-	}
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-			panic(r)
-		}
-	}()
 
-	var prev models.Transaction
-	if err := tx.
-		Clauses(clause.Locking{Strength: "UPDATE"}).
-		Where("charge_id = ?", charge.ID).
-		Take(&prev).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-		tx.Rollback()
-		return err
-	}
-	prevWasSuccessful := prev.Status == "successful"
+// webhookEnvelope is the minimal part of a webhook body needed to route it.
+type webhookEnvelope struct {
+	Object string `json:"object"`
+	ID     string `json:"id"`
+}
 
-	newTx := models.Transaction{
-		UserID:         userID,
-		ChargeID:       charge.ID,
-		AmountSatang:   charge.Amount,
-		Currency:       charge.Currency,
-		Channel:        channel,
-		Status:         string(charge.Status),
-		FailureCode:    charge.FailureCode,
-		FailureMessage: charge.FailureMessage,
-		RawPayload:     rawPayload,
-		Meta:           meta,
+// (HandleWebhook helper) decode only object/id from the request body.
+func parseWebhookEnvelope(body []byte) (webhookEnvelope, error) {
+	var env webhookEnvelope
+	if err := json.Unmarshal(body, &env); err != nil {
+		return env, err
 	}
-	if err := tx.Clauses(clause.OnConflict{
-		Columns: []clause.Column{{Name: "charge_id"}},
-		DoUpdates: clause.AssignmentColumns([]string{
-			"status", "failure_code", "failure_message",
-			"amount_satang", "currency", "channel",
-			"raw_payload", "meta", "updated_at", "user_id",
-		}),
-	}).Create(&newTx).Error; err != nil {
-		tx.Rollback()
-		return err
+	if env.ID == "" {
+		return env, errors.New("missing id")
 	}
-
-	if userID != nil {
-		if err := h.adjustUserBalanceOnStatusTransition(tx, charge, userID, prevWasSuccessful); err != nil {
-			tx.Rollback()
-			return err
-		}
-	}
-
-	return tx.Commit().Error
+	return env, nil
 }
 
-// adjustUserBalanceOnStatusTransition handles user balance adjustment logic for status transitions.
-func (h *PaymentHandler) adjustUserBalanceOnStatusTransition(tx *gorm.DB, charge *omise.Charge, userID *uint, prevWasSuccessful bool) error {
-	nowSuccessful := string(charge.Status) == "successful"
-	switch {
-	case !prevWasSuccessful && nowSuccessful:
-		amountTHB := float64(charge.Amount) / 100.0 // convert satang to THB
-		if err := tx.Model(&models.User{}).
-			Where("id = ?", *userID).
-			Update("balance", gorm.Expr("balance + ?", amountTHB)).Error; err != nil {
-			log.Printf("Failed to credit user balance: %v", err)
-			return err
-		}
-	case prevWasSuccessful && !nowSuccessful:
-		// optional: debit if a previously successful charge became non-successful (reversal/refund)
-		// uncomment if your product requires it; consider partial refunds.
-		/*
-			amountTHB := float64(charge.Amount) / 100.0
-			if err := tx.Model(&models.User{}).
-				Where("id = ?", *userID).
-				Update("balance", gorm.Expr("balance - ?", amountTHB)).Error; err != nil {
-				log.Printf("Failed to debit user balance: %v", err)
-				return err
-			}
-		*/
+// (HandleWebhook helper) omise.Event already decodes Data into a typed object, so read the charge
+// id directly instead of re-marshalling ev.Data.
+func chargeIDFromEvent(ev *omise.Event) (string, bool) {
+	if ch, ok := ev.Data.(*omise.Charge); ok && ch.ID != "" {
+		return ch.ID, true
 	}
-	return nil
+	return "", false
 }
 
-func determineChannel(charge *omise.Charge) string {
-	if charge == nil {
-		return "card"
+// (HandleWebhook helper) the transfer a verified transfer.* event (e.g. "transfer.pay") is about; ev
+// may be nil (bare charge payloads).
+func eventTransfer(ev *omise.Event) (*omise.Transfer, bool) {
+	if ev == nil {
+		return nil, false
 	}
-	if charge.Source != nil && charge.Source.Type != "" {
-		return charge.Source.Type
-	}
-	return "card"
+	tr, ok := ev.Data.(*omise.Transfer)
+	return tr, ok && tr.ID != ""
 }
 
 func (h *PaymentHandler) getUserIDFromRequest(c *fiber.Ctx, req *models.PaymentRequest) *uint {
 	if req.UserID != nil {
 		return req.UserID
 	}
+	return userIDFromHeaderOrQuery(c)
+}
+
+// (helper for getUserIDFromRequest) resolve user id from X-User-ID header, then ?user_id=.
+func userIDFromHeaderOrQuery(c *fiber.Ctx) *uint {
 	if userIDHeader := c.Get("X-User-ID"); userIDHeader != "" {
 		if userID, err := strconv.ParseUint(userIDHeader, 10, 32); err == nil {
 			u := uint(userID)
@@ -179,26 +87,3 @@ func (h *PaymentHandler) getUserIDFromRequest(c *fiber.Ctx, req *models.PaymentR
 	}
 	return nil
 }
-
-// (helper for helpersParseLimitOffset)
-func extractUserIDFromCharge(charge *omise.Charge, userID *uint) *uint {
-	if userID != nil {
-		return userID
-	}
-	if charge == nil || charge.Metadata == nil {
-		return userID
-	}
-	if v, ok := charge.Metadata["user_id"]; ok {
-		switch vv := v.(type) {
-		case string:
-			if n, err := strconv.ParseUint(vv, 10, 32); err == nil {
-				u := uint(n)
-				return &u
-			}
-		case float64:
-			u := uint(vv)
-			return &u
-		}
-	}
-	return userID
-}
@@ -1,92 +1,270 @@
 package handlers
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"image"
 	"log"
+	"time"
 
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/fx"
+	"github.com/a2n2k3p4/tutorium-backend/gateway"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/notify"
+	"github.com/a2n2k3p4/tutorium-backend/provider"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"github.com/a2n2k3p4/tutorium-backend/service"
+	"github.com/a2n2k3p4/tutorium-backend/tax"
 	"github.com/gofiber/fiber/v2"
 	omise "github.com/omise/omise-go"
-	"github.com/omise/omise-go/operations"
 	"gorm.io/gorm"
 )
 
 type PaymentHandler struct {
-	DB     *gorm.DB
-	Client *omise.Client
-}
+	DB *gorm.DB
+
+	// Transactions, Users, Orders, Blocklist and WalletOperations are the data access for payment
+	// transactions, user balances, orders, the payer blocklist and wallet operations;
+	// NewPaymentHandler binds the Postgres implementations to DB, sharing Payments' where it has them.
+	Transactions     repository.TransactionRepository
+	Users            repository.UserRepository
+	Orders           repository.OrderRepository
+	Blocklist        repository.BlocklistRepository
+	WalletOperations repository.WalletOperationRepository
+
+	// Omise is the payment provider API; tests inject gatewaytest.Fake or a gatewaytest.Server client.
+	Omise gateway.OmiseGateway
+
+	// Payments holds the charge, balance-credit and refund logic; handlers adapt HTTP to it. It shares
+	// DB, Omise and the repositories above; payment settings (raw card, return URIs, payload
+	// encryption) are configured on it.
+	Payments *service.PaymentService
+
+	// AdminToken is the shared secret required by /admin routes (see RequireAdmin).
+	AdminToken string
+
+	// Mailer delivers email notifications; nil or unconfigured disables email.
+	Mailer *notify.Mailer
+	// PaymentEmails emails payers a receipt or a decline notice for their charges (see payment_email.go).
+	PaymentEmails bool
+	// Line pushes payment confirmations and QR-expiry reminders to users with a linked LINE account;
+	// nil or unconfigured disables LINE (see line_handler.go).
+	Line *notify.LINE
+	// FCM pushes payment status to the mobile app on users' registered devices; nil or unconfigured
+	// disables it (see push_handler.go). PushDeepLinkBase is where a push leads, as <base>/<charge id>.
+	FCM              *notify.FCM
+	PushDeepLinkBase string
+
+	// CountryHeader is the request header a trusted proxy puts the client's country in (e.g.
+	// Cloudflare's CF-IPCountry); empty leaves the country of charges unknown.
+	CountryHeader string
+
+	// PaymentLinkBaseURL is the hosted checkout page payment links point at, as <base>/<token>; empty
+	// leaves the URL out of payment link responses (see payment_link_handler.go).
+	PaymentLinkBaseURL string
+
+	// Tax submits e-Tax invoices for successful charges; nil disables submission.
+	Tax tax.Service
+
+	// FX converts prices for display in the payer's currency (see quote_handler.go); nil disables quotes.
+	FX fx.Provider
+
+	// QRLogo is drawn on shareable PromptPay QR images; nil prints the brand name only.
+	QRLogo image.Image
+
+	// RefundBudget configures daily refund volume alerts (see refund_budget_handler.go).
+	RefundBudget RefundBudget
+
+	// PayoutReserve is the holdback withheld from teacher payout statements (see payout_handler.go).
+	PayoutReserve PayoutReserve
+
+	// WebhookSLA is the webhook latency promised to the booking service (see webhook_sla_handler.go).
+	WebhookSLA WebhookSLA
+
+	// Alerts lists where operational alerts for admins are delivered.
+	Alerts AlertTargets
 
-func NewPaymentHandler(db *gorm.DB, client *omise.Client) *PaymentHandler {
-	return &PaymentHandler{DB: db, Client: client}
+	// OpsAlerts sets when charge failure rate, webhook failure and Omise outage alerts fire (see ops_alerts.go).
+	OpsAlerts OpsAlerts
+
+	// WebhookEndpoints are the configured inbound webhook paths (see webhook_endpoints.go).
+	WebhookEndpoints []WebhookEndpoint
+
+	// Backpressure sets the queue depths above which load is shed (see backpressure.go).
+	Backpressure Backpressure
+
+	// Deadlines bound requests and background work, including their Omise and DB calls (see deadline.go).
+	Deadlines Deadlines
+
+	// Merchants are the Omise accounts besides the default one, picked by X-Merchant (see merchants.go).
+	Merchants []Merchant
+
+	// Warehouse is the bucket the warehouse_export job delivers to (see warehouse_export_handler.go).
+	Warehouse WarehouseExport
+
+	// APIConsumers are the internal services identified by their X-API-Key for usage reports (see usage.go).
+	APIConsumers []APIConsumer
+
+	// background tracks goroutines started off the request path (see Drain).
+	background backgroundWork
+
+	// health caches the Omise readiness check (see Ready).
+	health healthCache
+
+	// slaAlerts throttles webhook SLA breach alerts.
+	slaAlerts slaAlertState
+
+	// opsAlerts throttles the alerts of OpsAlerts.
+	opsAlerts opsAlertState
+
+	// load counts in-flight webhook processing for Backpressure.
+	load queueLoad
+
+	// webhookTail fans processed webhooks out to /admin/webhooks/tail subscribers.
+	webhookTail webhookFeed
+
+	// usage buffers per-consumer usage counts until FlushUsage.
+	usage usageMeter
 }
 
-func (h *PaymentHandler) Health(c *fiber.Ctx) error {
-	return c.JSON(fiber.Map{"status": "ok"})
+func NewPaymentHandler(db *gorm.DB, gw gateway.OmiseGateway) *PaymentHandler {
+	payments := service.NewPaymentService(db, gw)
+	h := &PaymentHandler{
+		DB:               db,
+		Omise:            gw,
+		Payments:         payments,
+		Transactions:     payments.Transactions,
+		Users:            payments.Users,
+		Orders:           payments.Orders,
+		Blocklist:        payments.Blocklist,
+		WalletOperations: repository.NewWalletOperationRepository(db),
+	}
+	// Issue the e-Tax invoice, email the receipt, confirm on LINE and push to the app after commit, off
+	// the request path (see tax_handler.go, payment_email.go, line_handler.go and push_handler.go).
+	payments.OnChargeSucceeded = func(transactionID uint) {
+		if h.Tax != nil {
+			h.goBackground(func(ctx context.Context) { h.submitTaxInvoice(ctx, transactionID) })
+		}
+		if h.PaymentEmails {
+			h.goBackground(func(ctx context.Context) { h.sendPaymentEmail(ctx, transactionID, notify.TemplatePaymentSucceeded) })
+		}
+		if h.Line.Enabled() {
+			h.goBackground(func(ctx context.Context) { h.pushLinePaymentConfirmation(ctx, transactionID) })
+		}
+		if h.FCM.Enabled() {
+			h.goBackground(func(ctx context.Context) { h.pushPaymentStatus(ctx, transactionID, models.PushPaymentSucceeded) })
+		}
+	}
+	payments.OnChargeFailed = func(transactionID uint) {
+		if h.PaymentEmails {
+			h.goBackground(func(ctx context.Context) { h.sendPaymentEmail(ctx, transactionID, notify.TemplatePaymentFailed) })
+		}
+		if h.FCM.Enabled() {
+			h.goBackground(func(ctx context.Context) { h.pushPaymentStatus(ctx, transactionID, models.PushPaymentFailed) })
+		}
+	}
+	return h
 }
 
-// HandleWebhook accepts either an Event payload (object:"event") or a Charge payload (object:"charge").
-// Flow:
-//   - if event: RetrieveEvent -> extract charge.id -> RetrieveCharge -> upsert
-//   - if charge: RetrieveCharge -> upsert
+// HandleWebhook accepts a webhook of the payment provider (for Omise, an Event payload or a bare Charge
+// payload; see provider.Omise.ParseWebhook). The :endpoint path segment selects a WebhookEndpoint,
+// whose secret and routing rules apply.
+// Flow: ParseWebhook (verifies the event) -> RetrieveCharge (verifies the status) -> record
+// Transfer events (payouts to our bank account) are recorded as the verified event carries them.
+//
 // Return 5xx on transient failure (so Omise retries); 200 when processed or intentionally ignored.
 func (h *PaymentHandler) HandleWebhook(c *fiber.Ctx) error {
-	var envelope struct {
-		Object string `json:"object"`
-		ID     string `json:"id"`
+	receivedAt := time.Now()
+	h.load.webhooks.Add(1)
+	defer h.load.webhooks.Add(-1)
+	tail := webhookTailEvent{ReceivedAt: receivedAt, Result: webhookTailIgnored}
+	defer func() {
+		tail.DurationMs = time.Since(receivedAt).Milliseconds()
+		h.webhookTail.publish(tail)
+		if tail.Result == webhookTailFailed {
+			h.goBackground(func(context.Context) { h.alertWebhookFailed(tail) })
+		}
+	}()
+	ep, ok := h.webhookEndpoint(c.Params("endpoint", defaultWebhookEndpoint))
+	if !ok {
+		tail.Result, tail.Error = webhookTailRejected, "unknown endpoint "+c.Params("endpoint")
+		return apperrors.ErrNotFound.WithMessage("unknown webhook endpoint")
 	}
-	if err := json.Unmarshal(c.Body(), &envelope); err != nil || envelope.ID == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload: missing object or id"})
+	tail.Endpoint = ep.Name
+	if !ep.authorized(c) {
+		tail.Result, tail.Error = webhookTailRejected, "invalid webhook token"
+		return apperrors.ErrUnauthorized.WithMessage("invalid or missing webhook token")
 	}
+	// The event, its charge and the transaction are the endpoint's merchant's, whatever X-Merchant says.
+	c.SetUserContext(gateway.WithMerchant(c.UserContext(), ep.merchantID()))
+	envelope, _ := parseWebhookEnvelope(c.Body()) // for the tail; ParseWebhook validates the body
+	tail.Object, tail.ObjectID = envelope.Object, envelope.ID
 
-	var chargeID string
-
-	switch envelope.Object {
-	case "event":
-		// Verify the event by retrieving it from Omise
-		ev := &omise.Event{}
-		if err := h.Client.Do(ev, &operations.RetrieveEvent{EventID: envelope.ID}); err != nil {
-			log.Printf("webhook: verify event failed id=%s err=%v", envelope.ID, err)
-			// Returning 5xx allows the sender to retry (useful for transient network issues).
-			return c.SendStatus(fiber.StatusInternalServerError)
-		}
-
-		// Extract the embedded object; only handle charge
-		var embedded struct {
-			ID     string `json:"id"`
-			Object string `json:"object"`
-		}
-		raw, err := json.Marshal(ev.Data)
-		if err != nil {
-			log.Printf("webhook: marshal ev.Data failed id=%s err=%v", envelope.ID, err)
+	wh, err := h.Payments.Provider.ParseWebhook(c.UserContext(), c.Body())
+	if errors.Is(err, provider.ErrInvalidPayload) {
+		tail.Result, tail.Error = webhookTailRejected, "invalid payload"
+		return apperrors.ErrBadRequest.WithMessage("invalid payload: missing object or id")
+	}
+	if err != nil {
+		log.Printf("webhook: parse failed id=%s err=%v", envelope.ID, err)
+		tail.Result, tail.Error = webhookTailFailed, err.Error()
+		// Returning 5xx allows the sender to retry (useful for transient network issues).
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	tail.EventKey = wh.Key
+	if wh.Key != "" && !ep.handlesEvent(wh.Key) {
+		// Another endpoint handles this event type.
+		return c.SendStatus(fiber.StatusOK)
+	}
+	event, _ := wh.Native.(*omise.Event)
+	if tr, ok := eventTransfer(event); ok {
+		// A payout to our bank account: record it; the transfers job matches its charges.
+		tail.ObjectID = tr.ID
+		if err := h.Payments.ImportTransfer(c.UserContext(), tr); err != nil {
+			log.Printf("webhook: import transfer failed transfer=%s err=%v", tr.ID, err)
+			tail.Result, tail.Error = webhookTailFailed, "import transfer: "+err.Error()
 			return c.SendStatus(fiber.StatusInternalServerError)
 		}
-		if err := json.Unmarshal(raw, &embedded); err != nil || embedded.Object != "charge" || embedded.ID == "" {
-			// Not a charge-related event → acknowledge and exit.
-			return c.SendStatus(fiber.StatusOK)
-		}
-		chargeID = embedded.ID
-
-	case "charge":
-		// Some dashboard/testing tools show the charge payload directly.
-		chargeID = envelope.ID
-
-	default:
-		// Ignore other payload types.
+		tail.Result = webhookTailProcessed
 		return c.SendStatus(fiber.StatusOK)
 	}
+	if wh.ChargeID == "" {
+		// Not about a charge → acknowledge and exit.
+		return c.SendStatus(fiber.StatusOK)
+	}
+	chargeID := wh.ChargeID
 
 	// Retrieve the charge to independently verify status, then upsert locally.
-	ch := &omise.Charge{}
-	if err := h.Client.Do(ch, &operations.RetrieveCharge{ChargeID: chargeID}); err != nil {
+	charge, err := h.Payments.Provider.RetrieveCharge(c.UserContext(), chargeID)
+	var ch *omise.Charge
+	if err == nil {
+		ch, err = provider.OmiseCharge(charge)
+	}
+	if err != nil {
 		log.Printf("webhook: retrieve charge failed charge=%s err=%v", chargeID, err)
+		tail.ChargeID, tail.Result, tail.Error = chargeID, webhookTailFailed, "retrieve charge: "+err.Error()
 		return c.SendStatus(fiber.StatusInternalServerError)
 	}
 
-	// NOTE: upsertTransactionFromCharge should be defined on PaymentHandler elsewhere in your codebase.
-	if err := h.upsertTransactionFromCharge(ch, nil); err != nil {
+	tail.ChargeID, tail.Status, tail.AmountSatang, tail.Currency = ch.ID, string(ch.Status), ch.Amount, ch.Currency
+	if !ep.routesCharge(ch) {
+		log.Printf("webhook: charge=%s is not routed to endpoint %s, ignored", ch.ID, ep.Name)
+		tail.Error = "not routed to this endpoint"
+		return c.SendStatus(fiber.StatusOK)
+	}
+	if err := h.Payments.RecordCharge(service.WithEventSource(c.UserContext(), models.TransactionEventWebhook), ch, nil); err != nil {
 		log.Printf("webhook: upsert failed charge=%s err=%v", ch.ID, err)
+		tail.Result, tail.Error = webhookTailFailed, "record charge: "+err.Error()
 		return c.SendStatus(fiber.StatusInternalServerError)
 	}
 
+	if event != nil {
+		processedAt := time.Now()
+		h.goBackground(func(ctx context.Context) { h.recordWebhookLatency(ctx, event, ch.ID, receivedAt, processedAt) })
+	}
+
 	log.Printf("webhook: processed charge=%s status=%s amount=%d source=%v", ch.ID, ch.Status, ch.Amount, ch.Source)
+	tail.Result = webhookTailProcessed
 	return c.SendStatus(fiber.StatusOK)
 }
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/gateway"
+	"github.com/a2n2k3p4/tutorium-backend/gateway/gatewaytest"
+	"github.com/a2n2k3p4/tutorium-backend/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+const cardChargeBody = `{"amount":100000,"currency":"thb","paymentType":"credit_card","token":"tokn_test_1"}`
+
+// (test helper) POST body to /payments/charge and decode the error envelope.
+func postCharge(t *testing.T, gw gateway.OmiseGateway, body string) (int, map[string]interface{}) {
+	t.Helper()
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/payments/charge", NewPaymentHandler(nil, gw).CreateCharge)
+
+	req := httptest.NewRequest("POST", "/payments/charge", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	raw, _ := io.ReadAll(resp.Body)
+	var out map[string]interface{}
+	_ = json.Unmarshal(raw, &out)
+	return resp.StatusCode, out
+}
+
+func TestCreateChargeMapsOmiseErrors(t *testing.T) {
+	cases := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{"declined", gatewaytest.Declined("invalid_card"), 402, "charge_failed"},
+		{"transport", errors.New("connection reset"), 502, "provider_unavailable"},
+	}
+	for _, tc := range cases {
+		t.Run("fake/"+tc.name, func(t *testing.T) {
+			fake := gatewaytest.NewFake()
+			fake.FailNext("CreateCharge", tc.err)
+			status, body := postCharge(t, fake, cardChargeBody)
+			if status != tc.wantStatus || body["code"] != tc.wantCode {
+				t.Errorf("got %d %v, want %d %s", status, body, tc.wantStatus, tc.wantCode)
+			}
+		})
+		// Through the real omise-go client: non-Omise errors come back from the stub as HTTP 500.
+		t.Run("stub/"+tc.name, func(t *testing.T) {
+			srv := gatewaytest.NewServer(t)
+			srv.Fake.FailNext("CreateCharge", tc.err)
+			status, body := postCharge(t, srv.Gateway(t), cardChargeBody)
+			if status != tc.wantStatus || body["code"] != tc.wantCode {
+				t.Errorf("got %d %v, want %d %s", status, body, tc.wantStatus, tc.wantCode)
+			}
+			if got := srv.Requests(); !reflect.DeepEqual(got, []string{"POST /charges"}) {
+				t.Errorf("requests = %v", got)
+			}
+		})
+	}
+}
+
+func TestCreateChargeRejectsInvalidRequestWithoutCallingOmise(t *testing.T) {
+	fake := gatewaytest.NewFake()
+	status, body := postCharge(t, fake, `{"amount":1,"currency":"usd","paymentType":"credit_card","token":"tokn_test_1"}`)
+	if status != 400 || body["code"] != "validation_failed" {
+		t.Errorf("got %d %v, want 400 validation_failed", status, body)
+	}
+	if calls := fake.Calls(); len(calls) != 0 {
+		t.Errorf("Omise called for invalid request: %v", calls)
+	}
+
+	// Limits are per currency, in its own minor units: 50 yen is below the JPY minimum.
+	status, body = postCharge(t, fake, `{"amount":50,"currency":"jpy","paymentType":"credit_card","token":"tokn_test_1"}`)
+	if status != 400 || body["code"] != "validation_failed" {
+		t.Errorf("50 JPY: got %d %v, want 400 validation_failed", status, body)
+	}
+}
+
+func TestChargeErrorMapsRefusedPayers(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{fmt.Errorf("charge: %w", service.ErrPayerBlocked), fiber.StatusForbidden, "payer_blocked"},
+		{&service.LimitError{Code: "charge_rate_limit", Message: "at most 10 charges an hour"}, fiber.StatusTooManyRequests, "charge_rate_limit"},
+		{&service.DuplicateChargeError{ChargeID: "chrg_test_1", CreatedAt: time.Now()}, fiber.StatusConflict, "duplicate_charge"},
+	}
+	for _, tc := range cases {
+		var apiErr *apperrors.Error
+		if err := chargeError(tc.err); !errors.As(err, &apiErr) || apiErr.Status != tc.status || apiErr.Code != tc.code {
+			t.Errorf("chargeError(%v) = %v, want %d %s", tc.err, err, tc.status, tc.code)
+		}
+	}
+}
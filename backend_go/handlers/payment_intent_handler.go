@@ -0,0 +1,137 @@
+// payment_intent_handler.go serves /payments/intents: charges in two steps. The server fixes the amount,
+// currency and order when the intent is created; the client only picks the payment method when it
+// confirms, and may retry the confirm safely (see service.ConfirmIntent).
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/a2n2k3p4/tutorium-backend/apiv1"
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"github.com/a2n2k3p4/tutorium-backend/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+type createIntentRequest struct {
+	Amount      int64                  `json:"amount" validate:"required_without=OrderID,omitempty,charge_amount"` // minor units; defaults to the order's total
+	Currency    string                 `json:"currency" validate:"required_without=OrderID,omitempty,currency"`
+	OrderID     *uint                  `json:"order_id,omitempty"`
+	CouponCode  string                 `json:"coupon_code,omitempty" validate:"omitempty,max=40"`
+	Description string                 `json:"description,omitempty" validate:"max=255"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	UserID      *uint                  `json:"user_id,omitempty"` // the payer; defaults to X-User-ID
+}
+
+type confirmIntentRequest struct {
+	PaymentType string                 `json:"paymentType" validate:"required,oneof=credit_card promptpay internet_banking"`
+	Token       string                 `json:"token,omitempty" validate:"omitempty,startswith=tokn_"`
+	ReturnURI   string                 `json:"return_uri,omitempty" validate:"required_if=PaymentType internet_banking,omitempty,url"`
+	Bank        string                 `json:"bank,omitempty" validate:"required_if=PaymentType internet_banking,omitempty,oneof=bay bbl ktb scb"`
+	Card        map[string]interface{} `json:"card,omitempty"` // server-side tokenization (TESTING ONLY, requires ALLOW_RAW_CARD=true)
+}
+
+// CreatePaymentIntent creates an intent to charge the payer (user_id or X-User-ID) an amount fixed now:
+// the order's total less its coupon, or the amount sent when there is no order.
+//
+//	POST /api/v1/payments/intents {"order_id": 31, "coupon_code": "TERM1"}
+func (h *PaymentHandler) CreatePaymentIntent(c *fiber.Ctx) error {
+	var req createIntentRequest
+	if err := parseAndValidate(c, &req); err != nil {
+		return err
+	}
+	userID := req.UserID
+	if userID == nil {
+		userID = userIDFromHeaderOrQuery(c)
+	}
+	if userID == nil {
+		return apperrors.ErrValidation.WithMessage("X-User-ID (the payer) is required")
+	}
+	intent, err := h.Payments.CreateIntent(c.UserContext(), models.PaymentRequest{
+		Amount:      req.Amount,
+		Currency:    req.Currency,
+		OrderID:     req.OrderID,
+		CouponCode:  req.CouponCode,
+		Description: req.Description,
+		Metadata:    req.Metadata,
+	}, userID)
+	if err != nil {
+		var inErr *service.InputError
+		if errors.As(err, &inErr) {
+			return apperrors.ErrValidation.WithCode(inErr.Code).WithMessage(inErr.Message)
+		}
+		return apperrors.ErrInternal.WithMessage("Failed to create payment intent").Wrap(err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(intent)
+}
+
+// GetPaymentIntent returns the intent. Allowed for its payer (X-User-ID) or an admin.
+func (h *PaymentHandler) GetPaymentIntent(c *fiber.Ctx) error {
+	intent, err := h.intentFor(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(intent)
+}
+
+// ConfirmPaymentIntent charges the intent with the payment method sent and answers like CreateCharge.
+// Confirming an intent that already has its charge answers with that charge (Idempotent-Replayed:
+// true) instead of charging again; a concurrent confirm gets 409. Same access as GetPaymentIntent.
+//
+//	POST /api/v1/payments/intents/<id>/confirm {"paymentType": "credit_card", "token": "tokn_..."}
+func (h *PaymentHandler) ConfirmPaymentIntent(c *fiber.Ctx) error {
+	var req confirmIntentRequest
+	if err := parseAndValidate(c, &req); err != nil {
+		return err
+	}
+	intent, err := h.intentFor(c)
+	if err != nil {
+		return err
+	}
+	pay := models.PaymentRequest{
+		PaymentType: req.PaymentType,
+		Token:       req.Token,
+		ReturnURI:   req.ReturnURI,
+		Bank:        req.Bank,
+		Card:        req.Card,
+	}
+	pay.ClientIP, pay.ClientCountry = h.payerOrigin(c)
+	charge, replayed, err := h.Payments.ConfirmIntent(c.UserContext(), intent.ID, pay)
+	if err != nil {
+		if errors.Is(err, service.ErrIntentProcessing) {
+			return apperrors.ErrConflict.WithCode("intent_processing").WithMessage("payment intent is being confirmed; retry shortly")
+		}
+		return chargeError(err)
+	}
+	if replayed {
+		c.Set("Idempotent-Replayed", "true")
+		return c.JSON(apiv1.FromCharge(charge, responseLang(c)))
+	}
+	h.countUsage(c, models.UsageChargesCreated, 1)
+	h.countUsage(c, models.UsageChargeAmountSatang, charge.Amount)
+	return c.JSON(apiv1.FromCharge(charge, responseLang(c)))
+}
+
+// (helper for GetPaymentIntent and ConfirmPaymentIntent) the :id intent, when the caller may see it.
+func (h *PaymentHandler) intentFor(c *fiber.Ctx) (*models.PaymentIntent, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return nil, apperrors.ErrValidation.WithMessage("id must be a payment intent id")
+	}
+	var intent models.PaymentIntent
+	if err := h.db(c).Take(&intent, id).Error; err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, apperrors.ErrNotFound.WithMessage("Payment intent not found")
+		}
+		return nil, apperrors.ErrInternal.WithMessage("Failed to retrieve payment intent").Wrap(err)
+	}
+	if !h.adminTokenValid(c) {
+		// Do not reveal whether other users' intents exist.
+		if self := userIDFromHeaderOrQuery(c); self == nil || intent.UserID == nil || *self != *intent.UserID {
+			return nil, apperrors.ErrNotFound.WithMessage("Payment intent not found")
+		}
+	}
+	return &intent, nil
+}
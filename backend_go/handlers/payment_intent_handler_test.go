@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestPaymentIntentRequestsValidated(t *testing.T) {
+	h := NewPaymentHandler(nil, nil)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/intents", h.CreatePaymentIntent)
+	app.Post("/intents/:id/confirm", h.ConfirmPaymentIntent)
+
+	for name, tc := range map[string]struct {
+		path, user, body string
+	}{
+		"no payer":              {"/intents", "", `{"amount": 50000, "currency": "THB"}`},
+		"no amount or order":    {"/intents", "7", `{"currency": "THB"}`},
+		"below a charge":        {"/intents", "7", `{"amount": 1000, "currency": "THB"}`},
+		"coupon without order":  {"/intents", "7", `{"amount": 50000, "currency": "THB", "coupon_code": "TERM1"}`},
+		"confirm without type":  {"/intents/1/confirm", "7", `{"token": "tokn_test_1"}`},
+		"confirm without bank":  {"/intents/1/confirm", "7", `{"paymentType": "internet_banking"}`},
+		"confirm bad intent id": {"/intents/x/confirm", "7", `{"paymentType": "promptpay"}`},
+	} {
+		req := httptest.NewRequest("POST", tc.path, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		if tc.user != "" {
+			req.Header.Set("X-User-ID", tc.user)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", name, resp.StatusCode)
+		}
+	}
+}
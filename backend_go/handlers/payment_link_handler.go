@@ -0,0 +1,264 @@
+// payment_link_handler.go serves /payments/links: one-off payment requests a tutor creates and shares
+// (e.g. for a private lesson over chat). The link opens the hosted checkout page at
+// PAYMENT_LINK_BASE_URL/<token>, which reads the link and pays it through the token routes here.
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/apiv1"
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Lifetime of a payment link: paymentLinkDefaultTTL unless the tutor sets expires_at, which may be at
+// most paymentLinkMaxTTL away.
+const (
+	paymentLinkDefaultTTL = 7 * 24 * time.Hour
+	paymentLinkMaxTTL     = 30 * 24 * time.Hour
+)
+
+type createPaymentLinkRequest struct {
+	TutorID     *uint      `json:"tutor_id,omitempty"` // defaults to X-User-ID
+	PayerUserID *uint      `json:"payer_user_id,omitempty"`
+	Amount      int64      `json:"amount" validate:"required,charge_amount"` // minor units of Currency, within the limits of a charge
+	Currency    string     `json:"currency" validate:"required,currency"`
+	Description string     `json:"description" validate:"required,max=255"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// paymentLinkResponse is a PaymentLink with the URL to share.
+type paymentLinkResponse struct {
+	models.PaymentLink
+	URL string `json:"url,omitempty"`
+}
+
+// publicPaymentLink is what anyone holding the link sees: enough for the checkout page, nothing about
+// the payer or the charge that paid it.
+type publicPaymentLink struct {
+	TutorID      *uint     `json:"tutor_id,omitempty"`
+	AmountSatang int64     `json:"amount_satang"`
+	Currency     string    `json:"currency"`
+	Description  string    `json:"description"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	Status       string    `json:"status"`
+	Payable      bool      `json:"payable"`
+}
+
+type payPaymentLinkRequest struct {
+	PaymentType string `json:"paymentType" validate:"required,oneof=credit_card promptpay internet_banking"`
+	Token       string `json:"token,omitempty" validate:"omitempty,startswith=tokn_"`
+	ReturnURI   string `json:"return_uri,omitempty" validate:"required_if=PaymentType internet_banking,omitempty,url"`
+	Bank        string `json:"bank,omitempty" validate:"required_if=PaymentType internet_banking,omitempty,oneof=bay bbl ktb scb"`
+	UserID      *uint  `json:"user_id,omitempty"` // the payer; defaults to X-User-ID
+	Force       bool   `json:"force,omitempty"`   // pay even if it repeats a recent charge of the payer
+}
+
+// CreatePaymentLink creates an active payment link for the tutor (X-User-ID, or tutor_id from an
+// admin) and returns it with the URL to share.
+//
+//	POST /api/v1/payments/links {"amount": 80000, "currency": "THB", "description": "Private lesson 12 Oct"}
+func (h *PaymentHandler) CreatePaymentLink(c *fiber.Ctx) error {
+	var req createPaymentLinkRequest
+	if err := parseAndValidate(c, &req); err != nil {
+		return err
+	}
+	tutorID := userIDFromHeaderOrQuery(c)
+	if req.TutorID != nil {
+		if !h.adminTokenValid(c) && (tutorID == nil || *tutorID != *req.TutorID) {
+			return apperrors.ErrForbidden.WithMessage("only an admin can create links for another tutor")
+		}
+		tutorID = req.TutorID
+	}
+	if tutorID == nil {
+		return apperrors.ErrValidation.WithMessage("X-User-ID (the tutor) is required")
+	}
+	now := time.Now()
+	expiresAt := now.Add(paymentLinkDefaultTTL)
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(now) || req.ExpiresAt.After(now.Add(paymentLinkMaxTTL)) {
+			return apperrors.ErrValidation.WithMessagef("expires_at must be in the next %d days", int(paymentLinkMaxTTL/(24*time.Hour)))
+		}
+		expiresAt = *req.ExpiresAt
+	}
+	if req.PayerUserID != nil {
+		if _, err := h.Users.WithContext(c.UserContext()).Get(*req.PayerUserID); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return apperrors.ErrValidation.WithMessagef("user %d does not exist", *req.PayerUserID)
+			}
+			return apperrors.ErrInternal.WithMessage("Failed to create payment link").Wrap(err)
+		}
+	}
+	token, err := newPaymentLinkToken()
+	if err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to create payment link").Wrap(err)
+	}
+
+	link := models.PaymentLink{
+		Token:        token,
+		TutorID:      tutorID,
+		PayerUserID:  req.PayerUserID,
+		AmountSatang: req.Amount,
+		Currency:     strings.ToUpper(req.Currency),
+		Description:  req.Description,
+		ExpiresAt:    expiresAt,
+		Status:       models.PaymentLinkActive,
+		CreatedBy:    requestActor(c),
+	}
+	if err := h.db(c).Create(&link).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to create payment link").Wrap(err)
+	}
+	h.audit(auditEntry(c, models.AuditPaymentLinkCreate, "payment_link", fmt.Sprintf("%d", link.ID), nil, link))
+	return c.Status(fiber.StatusCreated).JSON(h.paymentLinkResponse(link))
+}
+
+// ListPaymentLinks returns a page of the caller's payment links (X-User-ID as the tutor), newest
+// first; an admin lists everyone's, or one tutor's with ?tutor_id=. ?status= filters by status.
+func (h *PaymentHandler) ListPaymentLinks(c *fiber.Ctx) error {
+	limit, offset := h.limitOffset(c)
+	q := h.db(c).Model(&models.PaymentLink{})
+	if h.adminTokenValid(c) {
+		if tutorID := c.QueryInt("tutor_id"); tutorID > 0 {
+			q = q.Where("tutor_id = ?", tutorID)
+		}
+	} else {
+		tutorID := userIDFromHeaderOrQuery(c)
+		if tutorID == nil {
+			return apperrors.ErrValidation.WithMessage("X-User-ID (the tutor) is required")
+		}
+		q = q.Where("tutor_id = ?", *tutorID)
+	}
+	if status := c.Query("status"); status != "" {
+		q = q.Where("status = ?", status)
+	}
+	var links []models.PaymentLink
+	if err := q.Order("created_at DESC, id DESC").Limit(limit).Offset(offset).Find(&links).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve payment links").Wrap(err)
+	}
+	out := make([]paymentLinkResponse, 0, len(links))
+	for _, link := range links {
+		out = append(out, h.paymentLinkResponse(link))
+	}
+	return c.JSON(fiber.Map{"payment_links": out, "pagination": fiber.Map{"limit": limit, "offset": offset}})
+}
+
+// GetPaymentLink returns what the checkout page shows for :token. No identity is needed: the token is
+// the secret the tutor shared.
+func (h *PaymentHandler) GetPaymentLink(c *fiber.Ctx) error {
+	link, err := h.paymentLinkFor(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(publicPaymentLink{
+		TutorID:      link.TutorID,
+		AmountSatang: link.AmountSatang,
+		Currency:     link.Currency,
+		Description:  link.Description,
+		ExpiresAt:    link.ExpiresAt,
+		Status:       link.Status,
+		Payable:      link.Payable(time.Now()),
+	})
+}
+
+// PayPaymentLink creates a charge for :token's amount, currency and description, paid by the caller
+// (user_id or X-User-ID, if known). It answers like CreateCharge; the link becomes paid when the
+// charge succeeds.
+//
+//	POST /api/v1/payments/links/<token>/pay {"paymentType": "promptpay"}
+func (h *PaymentHandler) PayPaymentLink(c *fiber.Ctx) error {
+	var req payPaymentLinkRequest
+	if err := parseAndValidate(c, &req); err != nil {
+		return err
+	}
+	link, err := h.paymentLinkFor(c)
+	if err != nil {
+		return err
+	}
+	charge := models.PaymentRequest{
+		PaymentType:   req.PaymentType,
+		Token:         req.Token,
+		ReturnURI:     req.ReturnURI,
+		Bank:          req.Bank,
+		UserID:        req.UserID,
+		PaymentLinkID: &link.ID,
+		Force:         req.Force,
+	}
+	charge.ClientIP, charge.ClientCountry = h.payerOrigin(c)
+	userID := h.getUserIDFromRequest(c, &charge)
+	ch, err := h.Payments.CreateCharge(c.UserContext(), charge, userID)
+	if err != nil {
+		return chargeError(err)
+	}
+	h.countUsage(c, models.UsageChargesCreated, 1)
+	h.countUsage(c, models.UsageChargeAmountSatang, ch.Amount)
+	return c.JSON(apiv1.FromCharge(ch, responseLang(c)))
+}
+
+// CancelPaymentLink cancels an active payment link so it can no longer be paid; a charge already
+// created for it that still succeeds leaves it cancelled (see service.RecordCharge). Allowed for the
+// link's tutor (X-User-ID) or an admin.
+func (h *PaymentHandler) CancelPaymentLink(c *fiber.Ctx) error {
+	link, err := h.paymentLinkFor(c)
+	if err != nil {
+		return err
+	}
+	if !h.adminTokenValid(c) {
+		if self := userIDFromHeaderOrQuery(c); self == nil || link.TutorID == nil || *self != *link.TutorID {
+			return apperrors.ErrForbidden.WithMessage("only the link's tutor or an admin can cancel it")
+		}
+	}
+	res := h.db(c).Model(&models.PaymentLink{}).Where("id = ? AND status = ?", link.ID, models.PaymentLinkActive).
+		Update("status", models.PaymentLinkCancelled)
+	if res.Error != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to cancel payment link").Wrap(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return apperrors.ErrConflict.WithMessagef("payment link is %s", link.Status)
+	}
+	before := link.Status
+	link.Status = models.PaymentLinkCancelled
+	h.audit(auditEntry(c, models.AuditPaymentLinkCancel, "payment_link", fmt.Sprintf("%d", link.ID),
+		fiber.Map{"status": before}, fiber.Map{"status": link.Status}))
+	return c.JSON(h.paymentLinkResponse(*link))
+}
+
+// (helper for the payment link routes) the :token payment link.
+func (h *PaymentHandler) paymentLinkFor(c *fiber.Ctx) (*models.PaymentLink, error) {
+	token := c.Params("token")
+	if len(token) != 32 {
+		return nil, apperrors.ErrNotFound.WithMessage("Payment link not found")
+	}
+	var link models.PaymentLink
+	if err := h.db(c).Where("token = ?", token).Take(&link).Error; err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, apperrors.ErrNotFound.WithMessage("Payment link not found")
+		}
+		return nil, apperrors.ErrInternal.WithMessage("Failed to retrieve payment link").Wrap(err)
+	}
+	return &link, nil
+}
+
+// (helper for CreatePaymentLink and friends) link with its shareable URL, when PaymentLinkBaseURL is set.
+func (h *PaymentHandler) paymentLinkResponse(link models.PaymentLink) paymentLinkResponse {
+	out := paymentLinkResponse{PaymentLink: link}
+	if h.PaymentLinkBaseURL != "" {
+		out.URL = strings.TrimSuffix(h.PaymentLinkBaseURL, "/") + "/" + link.Token
+	}
+	return out
+}
+
+// (helper for CreatePaymentLink) a random 32-character link token.
+func newPaymentLinkToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
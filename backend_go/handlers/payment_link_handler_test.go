@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestCreatePaymentLinkValidates(t *testing.T) {
+	h := NewPaymentHandler(nil, nil)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/links", h.CreatePaymentLink)
+
+	tooLate := time.Now().Add(paymentLinkMaxTTL + time.Hour).UTC().Format(time.RFC3339)
+	for name, tc := range map[string]struct {
+		user, body string
+		want       int
+	}{
+		"no tutor":          {"", `{"amount": 80000, "currency": "THB", "description": "Private lesson"}`, fiber.StatusBadRequest},
+		"no description":    {"5", `{"amount": 80000, "currency": "THB"}`, fiber.StatusBadRequest},
+		"below a charge":    {"5", `{"amount": 1000, "currency": "THB", "description": "Private lesson"}`, fiber.StatusBadRequest},
+		"expires too late":  {"5", `{"amount": 80000, "currency": "THB", "description": "Private lesson", "expires_at": "` + tooLate + `"}`, fiber.StatusBadRequest},
+		"for another tutor": {"5", `{"tutor_id": 6, "amount": 80000, "currency": "THB", "description": "Private lesson"}`, fiber.StatusForbidden},
+	} {
+		req := httptest.NewRequest("POST", "/links", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		if tc.user != "" {
+			req.Header.Set("X-User-ID", tc.user)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s: status %d, want %d", name, resp.StatusCode, tc.want)
+		}
+	}
+}
+
+func TestPaymentLinkURLAndToken(t *testing.T) {
+	token, err := newPaymentLinkToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(token) != 32 {
+		t.Fatalf("token %q is %d characters, want 32", token, len(token))
+	}
+	if other, _ := newPaymentLinkToken(); other == token {
+		t.Fatal("two tokens are the same")
+	}
+
+	h := &PaymentHandler{PaymentLinkBaseURL: "https://app.tutorium.io/pay/"}
+	if got := h.paymentLinkResponse(models.PaymentLink{Token: token}).URL; got != "https://app.tutorium.io/pay/"+token {
+		t.Errorf("URL = %q", got)
+	}
+	if got := (&PaymentHandler{}).paymentLinkResponse(models.PaymentLink{Token: token}).URL; got != "" {
+		t.Errorf("URL without a base = %q, want none", got)
+	}
+}
+
+func TestPaymentLinkPayable(t *testing.T) {
+	now := time.Now()
+	link := models.PaymentLink{Status: models.PaymentLinkActive, ExpiresAt: now.Add(time.Hour)}
+	if !link.Payable(now) {
+		t.Error("active unexpired link is not payable")
+	}
+	if link.Payable(now.Add(2 * time.Hour)) {
+		t.Error("expired link is payable")
+	}
+	link.Status = models.PaymentLinkPaid
+	if link.Payable(now) {
+		t.Error("paid link is payable")
+	}
+}
@@ -0,0 +1,54 @@
+// payment_stats_handler.go serves GET /payments/stats, the aggregates behind the revenue dashboard.
+package handlers
+
+import (
+	"slices"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetPaymentStats returns, for the transactions created in [from, to) that match the other
+// ListTransactions filters (see transactionFilterFromQuery), the totals per currency and the same
+// figures per ?interval= (day, the default, week or month, in Bangkok time), optionally split by
+// ?group_by= (channel or status): count, amount, successful count and amount, and success rate. The
+// aggregation is done by the database (repository.TransactionRepository.Stats), from the daily
+// rollups of the transaction_rollup job for past days and live for today.
+//
+//	GET /api/v1/payments/stats?from=2026-10-01&to=2026-10-31&interval=week&group_by=channel
+func (h *PaymentHandler) GetPaymentStats(c *fiber.Ctx) error {
+	f, err := transactionFilterFromQuery(c)
+	if err != nil {
+		return err
+	}
+	if f.From.IsZero() || f.To.IsZero() {
+		return apperrors.ErrValidation.WithMessage("from and to are required")
+	}
+	interval := c.Query("interval", "day")
+	if !slices.Contains(repository.StatsIntervals, interval) {
+		return apperrors.ErrValidation.WithMessage("interval must be day, week or month")
+	}
+	groupBy := c.Query("group_by")
+	if groupBy != "" && !slices.Contains(repository.StatsGroupings, groupBy) {
+		return apperrors.ErrValidation.WithMessage("group_by must be channel or status")
+	}
+
+	repo := h.Transactions.WithContext(c.UserContext())
+	totals, err := repo.Stats(f, "", "")
+	if err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to compute payment stats").Wrap(err)
+	}
+	buckets, err := repo.Stats(f, interval, groupBy)
+	if err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to compute payment stats").Wrap(err)
+	}
+	return c.JSON(fiber.Map{
+		"from":     f.From,
+		"to":       f.To,
+		"interval": interval,
+		"group_by": groupBy,
+		"totals":   totals,
+		"buckets":  buckets,
+	})
+}
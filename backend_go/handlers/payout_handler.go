@@ -0,0 +1,283 @@
+// payout_handler.go contains teacher payout statements (/admin/payouts) and the rolling reserve that
+// withholds part of each statement's earnings against later refunds and chargebacks.
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/money"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PayoutReserve is the rolling reserve policy applied to payout statements.
+type PayoutReserve struct {
+	Bps      int64 // share of net earnings withheld, in basis points (1000 = 10%); 0 disables the holdback
+	HoldDays int   // days after the statement period ends before its hold is paid out
+}
+
+type createPayoutStatementRequest struct {
+	TeacherID string `json:"teacher_id" validate:"required,max=64"`
+	From      string `json:"from" validate:"required"` // YYYY-MM-DD (Bangkok), inclusive
+	To        string `json:"to" validate:"required"`   // YYYY-MM-DD (Bangkok), exclusive
+	Currency  string `json:"currency,omitempty" validate:"omitempty,len=3"`
+}
+
+// payoutItem is one line of a statement; AmountSatang is signed (what it adds to the payout).
+type payoutItem struct {
+	Kind         string    `json:"kind"` // charge, refund, reserve_hold, reserve_release
+	Ref          string    `json:"ref"`  // charge id, refund id or reserve entry id
+	At           time.Time `json:"at"`
+	AmountSatang int64     `json:"amount_satang"`
+}
+
+// CreatePayoutStatement builds a draft statement for one teacher and period: successful charges
+// tagged with the teacher, less refunds made in the period, less the reserve holdback, plus reserve
+// holds that matured by the end of the period. The reserve ledger moves in the same DB transaction.
+func (h *PaymentHandler) CreatePayoutStatement(c *fiber.Ctx) error {
+	var req createPayoutStatementRequest
+	if err := parseAndValidate(c, &req); err != nil {
+		return err
+	}
+	from, err := time.ParseInLocation("2006-01-02", req.From, bangkok)
+	if err != nil {
+		return apperrors.ErrValidation.WithMessage("from must be a date (YYYY-MM-DD)")
+	}
+	to, err := time.ParseInLocation("2006-01-02", req.To, bangkok)
+	if err != nil || !to.After(from) {
+		return apperrors.ErrValidation.WithMessage("to must be a date (YYYY-MM-DD) after from")
+	}
+	if to.After(time.Now()) {
+		return apperrors.ErrValidation.WithMessage("the period must have ended")
+	}
+	currency := money.THB
+	if req.Currency != "" {
+		currency = money.New(0, req.Currency).Currency
+	}
+
+	st, err := h.createPayoutStatement(c, req.TeacherID, currency, from, to)
+	if err != nil {
+		if dbutil.IsUniqueViolation(err) {
+			return apperrors.ErrConflict.WithMessagef("a %s statement for teacher %s starting %s already exists", currency, req.TeacherID, req.From)
+		}
+		return apperrors.ErrInternal.WithMessage("Failed to create payout statement").Wrap(err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(st)
+}
+
+// createPayoutStatement builds and stores the draft statement for teacherID's [from, to) earnings in
+// currency, moving the reserve ledger in the same DB transaction. c is the admin request, or nil when
+// the monthly statements job creates it; an existing statement for the period is a unique violation.
+func (h *PaymentHandler) createPayoutStatement(c *fiber.Ctx, teacherID, currency string, from, to time.Time) (*models.PayoutStatement, error) {
+	st := models.PayoutStatement{
+		TeacherID:   teacherID,
+		Currency:    currency,
+		PeriodStart: from,
+		PeriodEnd:   to,
+		ReserveBps:  h.PayoutReserve.Bps,
+		Status:      models.PayoutStatementDraft,
+		CreatedBy:   "system:jobs",
+	}
+	if c != nil {
+		st.CreatedBy = adminActor(c)
+	}
+	err := dbutil.Transaction(h.db(c), "create_payout_statement", func(tx *gorm.DB) error {
+		st.ID = 0
+		items, err := payoutEarnings(tx, teacherID, currency, from, to)
+		if err != nil {
+			return err
+		}
+		for _, it := range items {
+			if it.Kind == "charge" {
+				st.GrossSatang += it.AmountSatang
+			} else {
+				st.RefundsSatang -= it.AmountSatang
+			}
+		}
+		if earned := st.GrossSatang - st.RefundsSatang; earned > 0 {
+			st.ReserveHeldSatang = money.New(earned, currency).Percent(h.PayoutReserve.Bps, money.RoundHalfUp).Amount
+		}
+
+		var matured []models.PayoutReserveEntry
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("teacher_id = ? AND currency = ? AND kind = ? AND released_at IS NULL AND release_after <= ?",
+				teacherID, currency, models.PayoutReserveHold, to).
+			Order("id").Find(&matured).Error; err != nil {
+			return err
+		}
+		for _, hold := range matured {
+			st.ReserveReleasedSatang += hold.AmountSatang
+		}
+		st.NetSatang = st.GrossSatang - st.RefundsSatang - st.ReserveHeldSatang + st.ReserveReleasedSatang
+
+		if err := tx.Create(&st).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		if st.ReserveHeldSatang > 0 {
+			releaseAfter := to.AddDate(0, 0, h.PayoutReserve.HoldDays)
+			hold := models.PayoutReserveEntry{TeacherID: st.TeacherID, Currency: currency, Kind: models.PayoutReserveHold,
+				AmountSatang: st.ReserveHeldSatang, StatementID: st.ID, ReleaseAfter: &releaseAfter}
+			if err := tx.Create(&hold).Error; err != nil {
+				return err
+			}
+			items = append(items, payoutItem{Kind: "reserve_hold", Ref: fmt.Sprintf("%d", hold.ID), At: now, AmountSatang: -hold.AmountSatang})
+		}
+		for _, hold := range matured {
+			holdID := hold.ID
+			release := models.PayoutReserveEntry{TeacherID: st.TeacherID, Currency: currency, Kind: models.PayoutReserveRelease,
+				AmountSatang: hold.AmountSatang, StatementID: st.ID, HoldID: &holdID}
+			if err := tx.Create(&release).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&hold).Update("released_at", now).Error; err != nil {
+				return err
+			}
+			items = append(items, payoutItem{Kind: "reserve_release", Ref: fmt.Sprintf("%d", hold.ID), At: now, AmountSatang: hold.AmountSatang})
+		}
+
+		if err := tx.Model(&st).Update("items", auditJSON(items)).Error; err != nil {
+			return err
+		}
+		st.Items = auditJSON(items)
+		after := fiber.Map{"teacher_id": st.TeacherID, "period_start": st.PeriodStart, "net_satang": st.NetSatang,
+			"reserve_held_satang": st.ReserveHeldSatang, "reserve_released_satang": st.ReserveReleasedSatang}
+		if c == nil {
+			return writeAudit(tx, systemAuditEntry("jobs", models.AuditPayoutStatement, "payout_statement", fmt.Sprintf("%d", st.ID), nil, after))
+		}
+		return writeAudit(tx, auditEntry(c, models.AuditPayoutStatement, "payout_statement", fmt.Sprintf("%d", st.ID), nil, after))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// (helper for CreatePayoutStatement) the teacher's successful charges created in [from, to) and the
+// refunds of any of their charges made in [from, to), oldest first.
+func payoutEarnings(tx *gorm.DB, teacherID, currency string, from, to time.Time) ([]payoutItem, error) {
+	var charges []models.Transaction
+	if err := tx.Select("id", "charge_id", "created_at", "amount_satang").
+		Where("status = ? AND meta->>'teacher_id' = ? AND LOWER(currency) = ? AND created_at >= ? AND created_at < ?",
+			"successful", teacherID, currency, from, to).
+		Order("created_at, id").Find(&charges).Error; err != nil {
+		return nil, err
+	}
+	var refunds []struct {
+		RefundID     string
+		CreatedAt    time.Time
+		AmountSatang int64
+	}
+	if err := tx.Table("audit_logs AS a").
+		Select("COALESCE(a.after->>'refund_id', a.id::text) AS refund_id, a.created_at, (a.after->>'amount_satang')::bigint AS amount_satang").
+		Joins("JOIN transactions t ON a.entity_id = t.id::text").
+		Where("a.action = ? AND t.meta->>'teacher_id' = ? AND LOWER(t.currency) = ? AND a.created_at >= ? AND a.created_at < ?",
+			models.AuditRefund, teacherID, currency, from, to).
+		Scan(&refunds).Error; err != nil {
+		return nil, err
+	}
+
+	items := make([]payoutItem, 0, len(charges)+len(refunds))
+	for _, t := range charges {
+		items = append(items, payoutItem{Kind: "charge", Ref: t.ChargeID, At: t.CreatedAt, AmountSatang: t.AmountSatang})
+	}
+	for _, r := range refunds {
+		items = append(items, payoutItem{Kind: "refund", Ref: r.RefundID, At: r.CreatedAt, AmountSatang: -r.AmountSatang})
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].At.Before(items[j].At) })
+	return items, nil
+}
+
+// ListPayoutStatements returns statements newest period first (without items). Query: teacher_id, status, limit/offset.
+func (h *PaymentHandler) ListPayoutStatements(c *fiber.Ctx) error {
+	q := h.db(c).Model(&models.PayoutStatement{}).Omit("items")
+	if v := c.Query("teacher_id"); v != "" {
+		q = q.Where("teacher_id = ?", v)
+	}
+	if v := c.Query("status"); v != "" {
+		q = q.Where("status = ?", v)
+	}
+	limit, offset := h.limitOffset(c)
+	statements := []models.PayoutStatement{}
+	if err := q.Order("period_start DESC, id DESC").Limit(limit).Offset(offset).Find(&statements).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve payout statements").Wrap(err)
+	}
+	return c.JSON(fiber.Map{"payout_statements": statements})
+}
+
+// GetPayoutStatement returns one statement with its itemized lines.
+func (h *PaymentHandler) GetPayoutStatement(c *fiber.Ctx) error {
+	var st models.PayoutStatement
+	if err := h.db(c).First(&st, "id = ?", c.Params("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperrors.ErrNotFound.WithMessage("Payout statement not found")
+		}
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve payout statement").Wrap(err)
+	}
+	return c.JSON(st)
+}
+
+// ApprovePayoutStatement marks a draft statement approved for payment.
+func (h *PaymentHandler) ApprovePayoutStatement(c *fiber.Ctx) error {
+	var st models.PayoutStatement
+	err := dbutil.Transaction(h.db(c), "approve_payout_statement", func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&st, "id = ?", c.Params("id")).Error; err != nil {
+			return err
+		}
+		if st.Status != models.PayoutStatementDraft {
+			return apperrors.ErrConflict.WithCode("statement_not_draft").WithMessagef("statement is already %s", st.Status)
+		}
+		now := time.Now()
+		st.Status, st.ApprovedBy, st.ApprovedAt = models.PayoutStatementApproved, adminActor(c), &now
+		if err := tx.Model(&st).Select("status", "approved_by", "approved_at").Updates(&st).Error; err != nil {
+			return err
+		}
+		return writeAudit(tx, auditEntry(c, models.AuditPayoutApprove, "payout_statement", fmt.Sprintf("%d", st.ID),
+			fiber.Map{"status": models.PayoutStatementDraft}, fiber.Map{"status": st.Status, "net_satang": st.NetSatang}))
+	})
+	if err != nil {
+		var appErr *apperrors.Error
+		switch {
+		case errors.As(err, &appErr):
+			return appErr
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return apperrors.ErrNotFound.WithMessage("Payout statement not found")
+		}
+		return apperrors.ErrInternal.WithMessage("Failed to approve payout statement").Wrap(err)
+	}
+	return c.JSON(st)
+}
+
+// GetPayoutReserve returns a teacher's reserve balance and the holds not yet released. Query:
+// teacher_id (required), currency (default thb).
+func (h *PaymentHandler) GetPayoutReserve(c *fiber.Ctx) error {
+	teacherID := c.Query("teacher_id")
+	if teacherID == "" {
+		return apperrors.ErrValidation.WithMessage("teacher_id is required")
+	}
+	currency := money.New(0, c.Query("currency", money.THB)).Currency
+	outstanding := []models.PayoutReserveEntry{}
+	if err := h.db(c).Where("teacher_id = ? AND currency = ? AND kind = ? AND released_at IS NULL", teacherID, currency, models.PayoutReserveHold).
+		Order("release_after, id").Find(&outstanding).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve payout reserve").Wrap(err)
+	}
+	var held int64
+	for _, e := range outstanding {
+		held += e.AmountSatang
+	}
+	return c.JSON(fiber.Map{
+		"teacher_id":  teacherID,
+		"currency":    currency,
+		"held_satang": held,
+		"outstanding": outstanding,
+		"reserve_bps": h.PayoutReserve.Bps,
+		"hold_days":   h.PayoutReserve.HoldDays,
+	})
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestCreatePayoutStatementValidation(t *testing.T) {
+	h := NewPaymentHandler(nil, nil)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/statements", h.CreatePayoutStatement)
+	app.Get("/reserve", h.GetPayoutReserve)
+
+	tomorrow := time.Now().In(bangkok).AddDate(0, 0, 1).Format("2006-01-02")
+	for name, body := range map[string]string{
+		"missing teacher": `{"from":"2025-03-01","to":"2025-04-01"}`,
+		"bad date":        `{"teacher_id":"t1","from":"March","to":"2025-04-01"}`,
+		"empty period":    `{"teacher_id":"t1","from":"2025-04-01","to":"2025-04-01"}`,
+		"open period":     `{"teacher_id":"t1","from":"2025-03-01","to":"` + tomorrow + `"}`,
+		"bad currency":    `{"teacher_id":"t1","from":"2025-03-01","to":"2025-04-01","currency":"baht"}`,
+	} {
+		req := httptest.NewRequest("POST", "/statements", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		if resp.StatusCode != 400 {
+			t.Errorf("%s: status %d, want 400", name, resp.StatusCode)
+		}
+	}
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/reserve", nil))
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	if resp.StatusCode != 400 {
+		t.Errorf("reserve without teacher_id: status %d, want 400", resp.StatusCode)
+	}
+}
@@ -0,0 +1,161 @@
+// push_handler.go registers the mobile app's devices (PUT/DELETE /users/:id/devices) and pushes them,
+// through Firebase Cloud Messaging, the outcome of a PromptPay or mobile banking charge, so a payer who
+// switched to their banking app is brought back to the payment.
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/notify"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm/clause"
+)
+
+// maxDevicesPerUser bounds the devices a user's pushes go to; registering another forgets the one
+// registered least recently.
+const maxDevicesPerUser = 10
+
+type registerDeviceRequest struct {
+	Token    string `json:"token" validate:"required,max=4096"`
+	Platform string `json:"platform" validate:"required,oneof=android ios"`
+}
+
+// RegisterDevice stores the FCM registration token of a device the user signed in on, so payment
+// status pushes reach it; a token registered to another user moves to this one. The app calls it at
+// sign-in and whenever FCM rotates the token. Allowed for the user themself (X-User-ID) or an admin.
+//
+//	PUT /api/v1/users/:id/devices {"token": "fMEP0vJqS0:APA91b...", "platform": "android"}
+func (h *PaymentHandler) RegisterDevice(c *fiber.Ctx) error {
+	userID, err := h.ownUserID(c)
+	if err != nil {
+		return err
+	}
+	var req registerDeviceRequest
+	if err := parseAndValidate(c, &req); err != nil {
+		return err
+	}
+	if err := h.db(c).Select("id").Take(&models.User{}, userID).Error; err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperrors.ErrNotFound.WithMessage("User not found")
+		}
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve user").Wrap(err)
+	}
+	device := models.DeviceToken{UserID: userID, Token: strings.TrimSpace(req.Token), Platform: req.Platform}
+	if err := h.db(c).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "token"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "platform", "updated_at"}),
+	}).Create(&device).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to register device").Wrap(err)
+	}
+	if err := h.db(c).Where("user_id = ? AND id NOT IN (?)", userID,
+		h.db(c).Model(&models.DeviceToken{}).Select("id").Where("user_id = ?", userID).
+			Order("updated_at DESC").Limit(maxDevicesPerUser)).
+		Delete(&models.DeviceToken{}).Error; err != nil {
+		log.Printf("push: pruning devices of user=%d failed err=%v", userID, err)
+	}
+	return c.JSON(fiber.Map{"user_id": userID, "token": device.Token, "platform": device.Platform})
+}
+
+// UnregisterDevice forgets a device of the user, e.g. at sign-out; pushes stop reaching it. Same
+// access as RegisterDevice; a token the user does not have is not an error.
+//
+//	DELETE /api/v1/users/:id/devices/fMEP0vJqS0:APA91b...
+func (h *PaymentHandler) UnregisterDevice(c *fiber.Ctx) error {
+	userID, err := h.ownUserID(c)
+	if err != nil {
+		return err
+	}
+	token, err := url.PathUnescape(c.Params("token"))
+	if err != nil || token == "" {
+		return apperrors.ErrValidation.WithMessage("token must be an FCM registration token")
+	}
+	if err := h.db(c).Where("user_id = ? AND token = ?", userID, token).Delete(&models.DeviceToken{}).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to unregister device").Wrap(err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// pushTemplates are the templates of the push notification kinds.
+var pushTemplates = map[string]string{
+	models.PushPaymentSucceeded: notify.TemplatePushPaymentSucceeded,
+	models.PushPaymentFailed:    notify.TemplatePushPaymentFailed,
+}
+
+// pushPaymentStatus pushes the outcome kind of transactionID to every registered device of its payer,
+// once, when FCM is configured and the charge was paid outside the app (PromptPay or mobile banking).
+// The push deep-links to the charge (PushDeepLinkBase). Tokens FCM no longer knows are forgotten; if
+// no device could be reached for another reason the claim is dropped. Failures are logged.
+func (h *PaymentHandler) pushPaymentStatus(ctx context.Context, transactionID uint, kind string) {
+	if !h.FCM.Enabled() {
+		return
+	}
+	t, err := h.Transactions.WithContext(ctx).Get(transactionID)
+	if err != nil {
+		log.Printf("push: transaction=%d lookup failed err=%v", transactionID, err)
+		return
+	}
+	if t.UserID == nil || !pushedChannel(t.Channel) {
+		return
+	}
+	var devices []models.DeviceToken
+	if err := h.DB.WithContext(ctx).Where("user_id = ?", *t.UserID).Find(&devices).Error; err != nil {
+		log.Printf("push: transaction=%d devices lookup failed err=%v", t.ID, err)
+		return
+	}
+	if len(devices) == 0 {
+		return
+	}
+	msg, err := notify.Render(pushTemplates[kind], linePayment{
+		Amount: t.Amount().String(), Channel: t.PaymentMethod(), Time: t.UpdatedAt.In(bangkok).Format("15:04"), ChargeID: t.ChargeID,
+	})
+	if err != nil {
+		log.Printf("push: transaction=%d template=%s err=%v", t.ID, kind, err)
+		return
+	}
+	claim := models.PushNotification{TransactionID: t.ID, Kind: kind, UserID: *t.UserID}
+	if err := h.DB.WithContext(ctx).Create(&claim).Error; err != nil {
+		if !dbutil.IsUniqueViolation(err) {
+			log.Printf("push: transaction=%d claim failed err=%v", t.ID, err)
+		}
+		return
+	}
+
+	push := notify.Push{Title: msg.Subject, Body: msg.Body, Data: map[string]string{
+		"type":      "payment_status",
+		"charge_id": t.ChargeID,
+		"status":    t.Status,
+		"link":      h.PushDeepLinkBase + "/" + url.PathEscape(t.ChargeID),
+	}}
+	reached, failed := 0, 0
+	for _, d := range devices {
+		err := h.FCM.Push(d.Token, push)
+		switch {
+		case err == nil:
+			reached++
+		case errors.Is(err, notify.ErrUnregistered):
+			h.DB.WithContext(ctx).Delete(&d)
+		default:
+			failed++
+			log.Printf("push: transaction=%d device=%d failed err=%v", t.ID, d.ID, err)
+		}
+	}
+	if reached == 0 && failed > 0 {
+		h.DB.WithContext(ctx).Delete(&claim)
+		return
+	}
+	h.DB.WithContext(ctx).Model(&claim).Update("devices", reached)
+}
+
+// pushedChannel reports whether charges of channel are paid outside the app, so their payer is pushed
+// the outcome: PromptPay and mobile banking (e.g. mobile_banking_kbank).
+func pushedChannel(channel string) bool {
+	return channel == "promptpay" || strings.HasPrefix(channel, "mobile_banking_")
+}
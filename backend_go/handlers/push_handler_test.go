@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRegisterDeviceValidation(t *testing.T) {
+	h := NewPaymentHandler(nil, nil)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Put("/users/:id/devices", h.RegisterDevice)
+	app.Delete("/users/:id/devices/:token", h.UnregisterDevice)
+
+	for _, tc := range []struct {
+		method, self, body string
+		want               int
+	}{
+		{"PUT", "7", `{"token": "fMEP0vJqS0:APA91b", "platform": "windows"}`, 400},
+		{"PUT", "7", `{"platform": "ios"}`, 400},
+		{"PUT", "8", `{"token": "fMEP0vJqS0:APA91b", "platform": "ios"}`, 404}, // someone else's device list
+		{"PUT", "", `{"token": "fMEP0vJqS0:APA91b", "platform": "ios"}`, 404},
+		{"DELETE", "8", ``, 404},
+	} {
+		path := "/users/7/devices"
+		if tc.method == "DELETE" {
+			path += "/fMEP0vJqS0:APA91b"
+		}
+		req := httptest.NewRequest(tc.method, path, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		if tc.self != "" {
+			req.Header.Set("X-User-ID", tc.self)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s self %q body %s: status %d, want %d", tc.method, tc.self, tc.body, resp.StatusCode, tc.want)
+		}
+	}
+}
+
+func TestPushedChannel(t *testing.T) {
+	for channel, want := range map[string]bool{
+		"promptpay":            true,
+		"mobile_banking_kbank": true,
+		"mobile_banking_scb":   true,
+		"card":                 false,
+		"internet_banking_bbl": false,
+		"truemoney":            false,
+	} {
+		if got := pushedChannel(channel); got != want {
+			t.Errorf("pushedChannel(%q) = %v, want %v", channel, got, want)
+		}
+	}
+}
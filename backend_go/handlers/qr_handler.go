@@ -0,0 +1,99 @@
+// qr_handler.go serves a branded, shareable PNG of a PromptPay charge's QR code.
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/gateway"
+	"github.com/a2n2k3p4/tutorium-backend/money"
+	"github.com/a2n2k3p4/tutorium-backend/qrimage"
+	"github.com/gofiber/fiber/v2"
+	omise "github.com/omise/omise-go"
+	"gorm.io/gorm"
+)
+
+const (
+	qrBrandName     = "Tutorium"
+	qrMaxImageBytes = 2 << 20
+)
+
+// bangkok is used for the printed expiry time (fixed offset: no tzdata needed in the container).
+var bangkok = time.FixedZone("ICT", 7*60*60)
+
+var qrHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// GetPaymentQRImage renders the PromptPay QR of a pending charge with our logo, the amount and the
+// expiry into one PNG. The charge is re-read from Omise so an expired or paid QR is never shared.
+func (h *PaymentHandler) GetPaymentQRImage(c *fiber.Ctx) error {
+	t, err := h.Transactions.WithContext(c.UserContext()).Find(c.Params("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperrors.ErrNotFound.WithMessage("Transaction not found")
+		}
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve transaction").Wrap(err)
+	}
+	if t.Channel != "promptpay" {
+		return apperrors.ErrValidation.WithCode("not_promptpay").WithMessage("QR images are only available for PromptPay charges")
+	}
+
+	ch, err := h.Omise.RetrieveCharge(gateway.WithMerchant(c.UserContext(), t.MerchantID), t.ChargeID)
+	if err != nil {
+		return apperrors.ErrOmiseUnavailable.Wrap(err)
+	}
+	if ch.Status != omise.ChargePending || (!ch.ExpiresAt.IsZero() && time.Now().After(ch.ExpiresAt)) {
+		return apperrors.ErrConflict.WithCode("charge_not_payable").WithMessagef("charge is %s and can no longer be paid", ch.Status)
+	}
+	if ch.Source == nil || ch.Source.ScannableCode == nil || ch.Source.ScannableCode.Image == nil {
+		return apperrors.ErrNotFound.WithMessage("charge has no QR code")
+	}
+
+	raw, err := fetchQRImage(ch.Source.ScannableCode.Image.DownloadURI)
+	if err != nil {
+		return apperrors.ErrOmiseUnavailable.WithMessage("failed to download QR code").Wrap(err)
+	}
+	qr, err := qrimage.Decode(raw)
+	if err != nil {
+		return apperrors.ErrInternal.WithMessage("failed to decode QR code").Wrap(err)
+	}
+
+	var buf bytes.Buffer
+	if err := qrimage.Render(&buf, qr, qrimage.Card{
+		Brand:     qrBrandName,
+		Logo:      h.QRLogo,
+		Amount:    money.New(ch.Amount, ch.Currency).String(),
+		Reference: ch.ID,
+		ExpiresAt: ch.ExpiresAt,
+		Now:       time.Now(),
+		Location:  bangkok,
+	}); err != nil {
+		return apperrors.ErrInternal.WithMessage("failed to render QR image").Wrap(err)
+	}
+
+	// The countdown is baked in at render time, so never cache.
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`inline; filename="%s.png"`, ch.ID))
+	c.Type("png")
+	return c.Send(buf.Bytes())
+}
+
+// (helper for GetPaymentQRImage) download the QR image Omise hosts for the charge.
+func fetchQRImage(uri string) ([]byte, error) {
+	if uri == "" {
+		return nil, errors.New("empty download uri")
+	}
+	resp, err := qrHTTPClient.Get(uri)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download qr: status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, qrMaxImageBytes))
+}
@@ -0,0 +1,76 @@
+// quote_handler.go serves /payments/quote: an approximate price in the payer's own currency, so
+// international students see what a THB charge is worth at home before they confirm it. The charge
+// itself is always made in its own currency.
+package handlers
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/fx"
+	"github.com/a2n2k3p4/tutorium-backend/money"
+	"github.com/gofiber/fiber/v2"
+)
+
+// currencyCode is an ISO 4217 code in any case; quotes may be in currencies the service cannot charge in.
+var currencyCode = regexp.MustCompile(`^[A-Za-z]{3}$`)
+
+// Quote is an amount converted for display.
+type Quote struct {
+	Amount   money.Money `json:"amount"`
+	Quote    money.Money `json:"quote"`
+	Display  string      `json:"display"` // e.g. "≈ 27.40 USD"
+	Rate     float64     `json:"rate"`
+	AsOf     *time.Time  `json:"as_of,omitempty"`
+	Provider string      `json:"provider"`
+}
+
+// GetQuote converts ?amount= (minor units of ?from=, a currency charges are made in; default THB)
+// to ?to= at the FX provider's rate, rounded to the minor unit of to.
+//
+//	GET /api/v1/payments/quote?amount=150000&from=THB&to=USD
+func (h *PaymentHandler) GetQuote(c *fiber.Ctx) error {
+	amount, err := strconv.ParseInt(c.Query("amount"), 10, 64)
+	if err != nil || amount <= 0 {
+		return apperrors.ErrValidation.WithMessage("amount must be a positive number of minor units (e.g. satang)")
+	}
+	from := strings.ToLower(c.Query("from", money.THB))
+	if !money.Supported(from) {
+		return apperrors.ErrValidation.WithMessagef("from is not a supported currency (%s)", strings.Join(money.SupportedCurrencies(), ", "))
+	}
+	to := c.Query("to")
+	if !currencyCode.MatchString(to) {
+		return apperrors.ErrValidation.WithMessage("to must be an ISO 4217 currency code, e.g. USD")
+	}
+	if h.FX == nil {
+		return apperrors.ErrUnavailable.WithCode("fx_unavailable").WithMessage("currency quotes are not configured")
+	}
+	rate, err := h.FX.Rate(c.UserContext(), from, to)
+	if err != nil {
+		if errors.Is(err, fx.ErrUnsupported) {
+			return apperrors.ErrValidation.WithCode("currency_not_supported").WithMessagef("no exchange rate from %s to %s", strings.ToUpper(from), strings.ToUpper(to))
+		}
+		return apperrors.ErrUnavailable.WithCode("fx_unavailable").WithMessage("exchange rates are unavailable; try again later").Wrap(err)
+	}
+	return c.JSON(quote(money.New(amount, from), rate))
+}
+
+// (helper for GetQuote) amount converted at rate.
+func quote(amount money.Money, rate fx.Rate) Quote {
+	converted := money.FromMajor(amount.Major()*rate.Rate, rate.To)
+	q := Quote{
+		Amount:   amount,
+		Quote:    converted,
+		Display:  "≈ " + converted.String(),
+		Rate:     rate.Rate,
+		Provider: rate.Provider,
+	}
+	if !rate.AsOf.IsZero() {
+		q.AsOf = &rate.AsOf
+	}
+	return q
+}
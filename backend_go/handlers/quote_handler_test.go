@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/a2n2k3p4/tutorium-backend/fx"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestGetQuote(t *testing.T) {
+	h := NewPaymentHandler(nil, nil)
+	h.FX = fx.Static{THBPer: map[string]float64{"usd": 36.5, "jpy": 0.245}}
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/quote", h.GetQuote)
+
+	cases := []struct {
+		query   string
+		status  int
+		display string
+	}{
+		{"amount=150000&from=THB&to=USD", 200, "≈ 41.10 USD"},
+		{"amount=150000&to=jpy", 200, "≈ 6,122 JPY"}, // 1,500 THB in whole yen
+		{"amount=150000&to=EUR", 400, ""},            // no rate
+		{"amount=150000&from=EUR&to=THB", 400, ""},   // not a charge currency
+		{"amount=-5&to=USD", 400, ""},
+		{"amount=150000&to=dollars", 400, ""},
+	}
+	for _, tc := range cases {
+		resp, err := app.Test(httptest.NewRequest("GET", "/quote?"+tc.query, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s: status %d, want %d", tc.query, resp.StatusCode, tc.status)
+			continue
+		}
+		if tc.status != 200 {
+			continue
+		}
+		var q Quote
+		if err := json.NewDecoder(resp.Body).Decode(&q); err != nil {
+			t.Fatalf("%s: decode: %v", tc.query, err)
+		}
+		if q.Display != tc.display || q.Amount.Amount != 150000 || q.Provider != "static" {
+			t.Errorf("%s: quote = %+v, want %s", tc.query, q, tc.display)
+		}
+	}
+
+	h.FX = nil
+	resp, err := app.Test(httptest.NewRequest("GET", "/quote?amount=150000&to=USD", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusServiceUnavailable {
+		t.Errorf("without a provider: status %d, want 503", resp.StatusCode)
+	}
+}
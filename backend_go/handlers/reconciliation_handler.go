@@ -0,0 +1,178 @@
+// reconciliation_handler.go runs full reconciliations of Omise's charge list against the transactions
+// table (nightly as the full_reconcile job, or on demand), stores their reports and serves
+// /admin/reconciliations.
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/notify"
+	"github.com/a2n2k3p4/tutorium-backend/service"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/datatypes"
+)
+
+// reconciliationItemsCap caps the discrepancies stored per run; the counts are always complete.
+const reconciliationItemsCap = 500
+
+// reconciliationMaxDays bounds a manual run, which pages through Omise synchronously.
+const reconciliationMaxDays = 31
+
+// reconciliationMetrics is published at /debug/vars as "charge_reconciliation": runs, plus
+// discrepancies, healed and last_run_unix from the last finished run.
+var reconciliationMetrics = expvar.NewMap("charge_reconciliation")
+
+type runReconciliationRequest struct {
+	From string `json:"from" validate:"required"` // YYYY-MM-DD (Bangkok), inclusive
+	To   string `json:"to" validate:"required"`   // YYYY-MM-DD (Bangkok), exclusive
+	Heal bool   `json:"heal"`                     // record missing and stale transactions from Omise
+}
+
+// RunReconciliation compares Omise's charges created in a period with the transactions table now, and
+// optionally heals what it can.
+//
+//	POST /api/v1/admin/reconciliations {"from": "2026-10-01", "to": "2026-10-08", "heal": true}
+func (h *PaymentHandler) RunReconciliation(c *fiber.Ctx) error {
+	var req runReconciliationRequest
+	if err := parseAndValidate(c, &req); err != nil {
+		return err
+	}
+	from, err := time.ParseInLocation("2006-01-02", req.From, bangkok)
+	if err != nil {
+		return apperrors.ErrValidation.WithMessage("from must be a date (YYYY-MM-DD)")
+	}
+	to, err := time.ParseInLocation("2006-01-02", req.To, bangkok)
+	if err != nil || !to.After(from) {
+		return apperrors.ErrValidation.WithMessage("to must be a date (YYYY-MM-DD) after from")
+	}
+	if to.Sub(from) > reconciliationMaxDays*24*time.Hour {
+		return apperrors.ErrValidation.WithMessagef("a reconciliation covers at most %d days", reconciliationMaxDays)
+	}
+
+	runKey := fmt.Sprintf("%s:%d", models.ReconciliationManual, time.Now().UnixNano())
+	run, err := h.runReconciliation(c.UserContext(), adminActor(c), models.ReconciliationManual, runKey, from, to, req.Heal)
+	if run == nil {
+		return apperrors.ErrInternal.WithMessage("Failed to run reconciliation").Wrap(err)
+	}
+	if err != nil {
+		return apperrors.ErrOmiseUnavailable.WithMessagef("Reconciliation %d failed; its error is stored with the run", run.ID).Wrap(err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(run)
+}
+
+// ListReconciliationRuns returns recent reconciliations, newest first (without items). Query: limit/offset.
+func (h *PaymentHandler) ListReconciliationRuns(c *fiber.Ctx) error {
+	limit, offset := h.limitOffset(c)
+	runs := []models.ReconciliationRun{}
+	if err := h.db(c).Omit("items").Order("created_at DESC, id DESC").Limit(limit).Offset(offset).Find(&runs).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve reconciliations").Wrap(err)
+	}
+	return c.JSON(fiber.Map{"reconciliations": runs})
+}
+
+// GetReconciliationRun returns one reconciliation with its discrepancies.
+func (h *PaymentHandler) GetReconciliationRun(c *fiber.Ctx) error {
+	var run models.ReconciliationRun
+	if err := h.db(c).First(&run, "id = ?", c.Params("id")).Error; err != nil {
+		return apperrors.ErrNotFound.WithMessage("Reconciliation not found")
+	}
+	return c.JSON(run)
+}
+
+// reconcilePreviousDay is the full_reconcile job: it reconciles yesterday (Bangkok), once across replicas.
+func (h *PaymentHandler) reconcilePreviousDay(ctx context.Context, now time.Time, heal bool) error {
+	local := now.In(bangkok)
+	to := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, bangkok)
+	from := to.AddDate(0, 0, -1)
+	runKey := models.ReconciliationScheduled + ":" + from.Format("2006-01-02")
+	run, err := h.runReconciliation(ctx, "system:jobs", models.ReconciliationScheduled, runKey, from, to, heal)
+	if run == nil && err == nil {
+		return nil // another replica has it
+	}
+	return err
+}
+
+// runReconciliation claims runKey, reconciles [from, to) and stores the report. A run key that was
+// already claimed returns (nil, nil). When the reconciliation itself fails, the run is stored with
+// its error and returned along with it.
+func (h *PaymentHandler) runReconciliation(ctx context.Context, actor, trigger, runKey string, from, to time.Time, heal bool) (*models.ReconciliationRun, error) {
+	run := models.ReconciliationRun{RunKey: runKey, Trigger: trigger, PeriodStart: from, PeriodEnd: to, Heal: heal, CreatedBy: actor}
+	if err := h.DB.Create(&run).Error; err != nil {
+		if dbutil.IsUniqueViolation(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	result, runErr := h.Payments.ReconcileCharges(ctx, from, to, heal)
+	finished := time.Now()
+	run.FinishedAt = &finished
+	if runErr != nil {
+		run.Error = runErr.Error()
+	} else {
+		run.OmiseCharges, run.LocalTransactions = result.OmiseCharges, result.LocalTransactions
+		run.Discrepancies, run.Healed = len(result.Discrepancies), result.Healed()
+		items := result.Discrepancies
+		if len(items) > reconciliationItemsCap {
+			items = items[:reconciliationItemsCap]
+		}
+		if raw, err := json.Marshal(items); err == nil {
+			run.Items = datatypes.JSON(raw)
+		}
+	}
+	if err := h.DB.Save(&run).Error; err != nil {
+		return nil, err
+	}
+
+	reconciliationMetrics.Add("runs", 1)
+	if runErr == nil {
+		for name, n := range map[string]int{"discrepancies": run.Discrepancies, "healed": run.Healed} {
+			v := new(expvar.Int)
+			v.Set(int64(n))
+			reconciliationMetrics.Set(name, v)
+		}
+		last := new(expvar.Int)
+		last.Set(finished.Unix())
+		reconciliationMetrics.Set("last_run_unix", last)
+	}
+	log.Printf("reconcile: run=%s omise=%d local=%d discrepancies=%d healed=%d err=%v",
+		run.RunKey, run.OmiseCharges, run.LocalTransactions, run.Discrepancies, run.Healed, runErr)
+	if runErr != nil || run.Discrepancies > run.Healed {
+		h.sendAdminAlert(notify.Message{Subject: "Omise reconciliation found problems", Body: reconciliationAlertBody(run, result)})
+	}
+	return &run, runErr
+}
+
+// (helper for runReconciliation) the totals and the first few unhealed discrepancies.
+func reconciliationAlertBody(run models.ReconciliationRun, result *service.ChargeReconciliation) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Run %s for %s - %s", run.RunKey, run.PeriodStart.In(bangkok).Format("2006-01-02"),
+		run.PeriodEnd.In(bangkok).Add(-time.Second).Format("2006-01-02"))
+	if run.Error != "" {
+		fmt.Fprintf(&b, " failed: %s", run.Error)
+	} else {
+		fmt.Fprintf(&b, ": %d discrepancies, %d healed.\n", run.Discrepancies, run.Healed)
+		shown := 0
+		for _, d := range result.Discrepancies {
+			if d.Healed || shown == 5 {
+				continue
+			}
+			shown++
+			fmt.Fprintf(&b, "\n  %s %s: local %q, omise %q", d.Kind, d.ChargeID, d.Local, d.Omise)
+			if d.Error != "" {
+				fmt.Fprintf(&b, " (heal failed: %s)", d.Error)
+			}
+		}
+	}
+	b.WriteString("\n\nFull report: GET /api/v1/admin/reconciliations/" + fmt.Sprint(run.ID))
+	return b.String()
+}
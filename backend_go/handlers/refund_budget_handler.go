@@ -0,0 +1,148 @@
+// refund_budget_handler.go tracks daily refund volume against a soft budget and alerts admins when
+// it crosses thresholds or jumps far above the recent norm (fraud, or an accidental bulk refund).
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/money"
+	"github.com/a2n2k3p4/tutorium-backend/notify"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// refundBaselineDays is the trailing window used as the "normal" daily refund volume.
+const refundBaselineDays = 28
+
+// RefundBudget configures refund volume alerts. Refunds are never blocked; this only alerts.
+type RefundBudget struct {
+	DailyLimitSatang int64   // 0 disables threshold alerts
+	ThresholdsPct    []int   // percent of DailyLimitSatang that trigger an alert, e.g. [80, 100]
+	AnomalyFactor    float64 // alert when today > factor x baseline daily average; 0 disables
+	AnomalyMinSatang int64   // ignore anomalies below this total (avoids noise on quiet days)
+}
+
+// refundBudgetStatus is the current day's refund volume compared to the budget and baseline.
+type refundBudgetStatus struct {
+	Day            string  `json:"day"`
+	TotalSatang    int64   `json:"total_satang"`
+	Count          int64   `json:"count"`
+	BudgetSatang   int64   `json:"budget_satang"`
+	UsedPct        float64 `json:"used_pct,omitempty"`
+	BaselineSatang int64   `json:"baseline_satang"` // average per day over the trailing window
+	Total          string  `json:"total"`
+}
+
+// GetRefundBudget reports today's refund total against the budget and the trailing baseline.
+func (h *PaymentHandler) GetRefundBudget(c *fiber.Ctx) error {
+	st, err := h.refundBudgetStatus(time.Now())
+	if err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to compute refund totals").Wrap(err)
+	}
+	alerts := []models.RefundAlert{}
+	if err := h.db(c).Where("day = ?", st.Day).Order("created_at").Find(&alerts).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve refund alerts").Wrap(err)
+	}
+	return c.JSON(fiber.Map{"status": st, "config": h.RefundBudget, "alerts": alerts})
+}
+
+// checkRefundBudget evaluates today's refunds and sends each alert at most once per day. Called in the
+// background after every refund we issue.
+func (h *PaymentHandler) checkRefundBudget(now time.Time) {
+	b := h.RefundBudget
+	if b.DailyLimitSatang <= 0 && b.AnomalyFactor <= 0 {
+		return
+	}
+	st, err := h.refundBudgetStatus(now)
+	if err != nil {
+		log.Printf("refund budget: compute totals failed err=%v", err)
+		return
+	}
+
+	if b.DailyLimitSatang > 0 {
+		// Only the highest crossed threshold alerts, so one big refund doesn't send 80% and 100% together.
+		crossed := 0
+		for _, pct := range b.ThresholdsPct {
+			if pct > crossed && st.TotalSatang*100 >= b.DailyLimitSatang*int64(pct) {
+				crossed = pct
+			}
+		}
+		if crossed > 0 && h.claimRefundAlert(st, fmt.Sprintf("%s_%d", models.RefundAlertBudget, crossed)) {
+			h.sendAdminAlert(notify.Message{
+				Subject: fmt.Sprintf("Refunds at %d%% of daily budget (%s)", crossed, st.Day),
+				Body: fmt.Sprintf("Refunds today: %s in %d refund(s).\nDaily budget: %s (%.0f%% used).\nBaseline: %s per day over the last %d days.",
+					st.Total, st.Count, money.New(st.BudgetSatang, money.THB), st.UsedPct,
+					money.New(st.BaselineSatang, money.THB), refundBaselineDays),
+			})
+		}
+	}
+
+	if b.AnomalyFactor > 0 && st.TotalSatang >= b.AnomalyMinSatang &&
+		float64(st.TotalSatang) > b.AnomalyFactor*float64(st.BaselineSatang) &&
+		h.claimRefundAlert(st, models.RefundAlertAnomaly) {
+		h.sendAdminAlert(notify.Message{
+			Subject: fmt.Sprintf("Unusual refund volume (%s)", st.Day),
+			Body: fmt.Sprintf("Refunds today: %s in %d refund(s), more than %.1fx the %d-day average of %s per day.\nCheck for fraud or an accidental bulk refund.",
+				st.Total, st.Count, b.AnomalyFactor, refundBaselineDays, money.New(st.BaselineSatang, money.THB)),
+		})
+	}
+}
+
+// (helper for checkRefundBudget) insert the (day, kind) alert row; false when another check already sent it.
+func (h *PaymentHandler) claimRefundAlert(st refundBudgetStatus, kind string) bool {
+	res := h.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RefundAlert{
+		Day:            st.Day,
+		Kind:           kind,
+		TotalSatang:    st.TotalSatang,
+		BudgetSatang:   st.BudgetSatang,
+		BaselineSatang: st.BaselineSatang,
+	})
+	if res.Error != nil {
+		log.Printf("refund budget: record alert failed day=%s kind=%s err=%v", st.Day, kind, res.Error)
+		return false
+	}
+	return res.RowsAffected == 1
+}
+
+// (helper for refund budget) sum refunds from the audit log for the Bangkok calendar day of now,
+// plus the average daily total over the trailing baseline window.
+func (h *PaymentHandler) refundBudgetStatus(now time.Time) (refundBudgetStatus, error) {
+	local := now.In(bangkok)
+	dayStart := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, bangkok)
+	st := refundBudgetStatus{Day: dayStart.Format("2006-01-02"), BudgetSatang: h.RefundBudget.DailyLimitSatang}
+
+	var today struct {
+		Total int64
+		Count int64
+	}
+	if err := h.refundSums(dayStart, dayStart.AddDate(0, 0, 1)).Scan(&today).Error; err != nil {
+		return st, err
+	}
+	var baseline struct {
+		Total int64
+		Count int64
+	}
+	if err := h.refundSums(dayStart.AddDate(0, 0, -refundBaselineDays), dayStart).Scan(&baseline).Error; err != nil {
+		return st, err
+	}
+
+	st.TotalSatang, st.Count = today.Total, today.Count
+	st.BaselineSatang = baseline.Total / refundBaselineDays
+	st.Total = money.New(st.TotalSatang, money.THB).String()
+	if st.BudgetSatang > 0 {
+		st.UsedPct = float64(st.TotalSatang) * 100 / float64(st.BudgetSatang)
+	}
+	return st, nil
+}
+
+// (helper for refundBudgetStatus) every refund writes an AuditRefund entry with after.amount_satang.
+func (h *PaymentHandler) refundSums(from, to time.Time) *gorm.DB {
+	return h.DB.Model(&models.AuditLog{}).
+		Select("COALESCE(SUM((after->>'amount_satang')::bigint), 0) AS total, COUNT(*) AS count").
+		Where("action = ? AND created_at >= ? AND created_at < ?", models.AuditRefund, from, to)
+}
@@ -0,0 +1,146 @@
+// report_queries.go contains the reporting queries behind scheduled report subscriptions.
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/money"
+	"github.com/a2n2k3p4/tutorium-backend/notify"
+)
+
+// ReportRow is one aggregated line of a report.
+type ReportRow struct {
+	Key            string `json:"key"`
+	Currency       string `json:"currency"`
+	Count          int64  `json:"count"`
+	AmountSatang   int64  `json:"amount_satang"`
+	DiscountSatang int64  `json:"discount_satang,omitempty"` // coupon discounts, not part of AmountSatang (daily_revenue)
+}
+
+// Report is the result of a reporting query over [From, To).
+type Report struct {
+	Type string      `json:"type"`
+	From time.Time   `json:"from"`
+	To   time.Time   `json:"to"`
+	Rows []ReportRow `json:"rows"`
+}
+
+func isValidReportType(t string) bool {
+	switch t {
+	case models.ReportDailyRevenue, models.ReportWeeklyRefunds, models.ReportMonthlyTeacherEarnings, models.ReportMonthlyServiceUsage:
+		return true
+	}
+	return false
+}
+
+// buildReport runs the query for a report type over [from, to).
+//   - daily_revenue: successful charges grouped by channel, with the coupon discounts given on them
+//   - weekly_refunds: reversed charges grouped by channel
+//   - monthly_teacher_earnings: successful charges grouped by metadata teacher_id
+//   - monthly_service_usage: usage per API consumer and metric (see usage.go); Count is the metric's total
+func (h *PaymentHandler) buildReport(reportType string, from, to time.Time) (*Report, error) {
+	if reportType == models.ReportMonthlyServiceUsage {
+		return h.buildUsageReport(from, to)
+	}
+	q := dbutil.Replica(h.DB).Model(&models.Transaction{})
+	switch reportType {
+	case models.ReportDailyRevenue:
+		q = q.Select("channel AS key, currency, COUNT(*) AS count, COALESCE(SUM(amount_satang), 0) AS amount_satang, "+
+			"COALESCE(SUM(discount_satang), 0) AS discount_satang").
+			Where("status = ? AND created_at >= ? AND created_at < ?", "successful", from, to).
+			Group("channel, currency")
+	case models.ReportWeeklyRefunds:
+		q = q.Select("channel AS key, currency, COUNT(*) AS count, COALESCE(SUM(amount_satang), 0) AS amount_satang").
+			Where("status = ? AND updated_at >= ? AND updated_at < ?", "reversed", from, to).
+			Group("channel, currency")
+	case models.ReportMonthlyTeacherEarnings:
+		q = q.Select("COALESCE(meta->>'teacher_id', 'unassigned') AS key, currency, COUNT(*) AS count, COALESCE(SUM(amount_satang), 0) AS amount_satang").
+			Where("status = ? AND created_at >= ? AND created_at < ?", "successful", from, to).
+			Group("key, currency")
+	default:
+		return nil, fmt.Errorf("unknown report type: %s", reportType)
+	}
+
+	report := &Report{Type: reportType, From: from, To: to}
+	if err := q.Order("key").Scan(&report.Rows).Error; err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// (helper for buildReport) one row per consumer and metric, keyed "<consumer> <metric>"; charge
+// amounts are in the charges_created row.
+func (h *PaymentHandler) buildUsageReport(from, to time.Time) (*Report, error) {
+	consumers, err := h.usageTotals(from, to)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{Type: models.ReportMonthlyServiceUsage, From: from, To: to, Rows: []ReportRow{}}
+	for _, cu := range consumers {
+		for _, metric := range []string{models.UsageAPICalls, models.UsageChargesCreated, models.UsageExports, models.UsageExportRows, models.UsageExportBytes} {
+			row := ReportRow{Key: cu.Consumer + " " + metric, Count: cu.Metrics[metric]}
+			if metric == models.UsageChargesCreated {
+				row.Currency, row.AmountSatang = "thb", cu.Metrics[models.UsageChargeAmountSatang]
+			}
+			report.Rows = append(report.Rows, row)
+		}
+	}
+	return report, nil
+}
+
+// Message renders the report as a plain-text notification.
+func (r *Report) Message() notify.Message {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Period: %s - %s\n", r.From.Format("2006-01-02"), r.To.Add(-time.Second).Format("2006-01-02"))
+	if len(r.Rows) == 0 {
+		b.WriteString("No activity in this period.\n")
+	}
+	for _, row := range r.Rows {
+		if row.Currency == "" { // a count, no amount
+			fmt.Fprintf(&b, "%-24s %6d\n", row.Key, row.Count)
+			continue
+		}
+		if row.DiscountSatang != 0 {
+			fmt.Fprintf(&b, "%-24s %6d  %16s  (discounts %s)\n", row.Key, row.Count, money.New(row.AmountSatang, row.Currency),
+				money.New(row.DiscountSatang, row.Currency))
+			continue
+		}
+		fmt.Fprintf(&b, "%-24s %6d  %16s\n", row.Key, row.Count, money.New(row.AmountSatang, row.Currency))
+	}
+	return notify.Message{
+		Subject: "Tutorium report: " + strings.ReplaceAll(r.Type, "_", " "),
+		Body:    b.String(),
+	}
+}
+
+// reportPeriod returns the most recent complete period for a report type.
+func reportPeriod(reportType string, now time.Time) (time.Time, time.Time) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch reportType {
+	case models.ReportWeeklyRefunds:
+		to := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7)) // Monday 00:00
+		return to.AddDate(0, 0, -7), to
+	case models.ReportMonthlyTeacherEarnings, models.ReportMonthlyServiceUsage:
+		to := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		return to.AddDate(0, -1, 0), to
+	default:
+		return today.AddDate(0, 0, -1), today
+	}
+}
+
+// nextReportRun returns when the next complete period for a report type ends.
+func nextReportRun(reportType string, now time.Time) time.Time {
+	_, to := reportPeriod(reportType, now)
+	switch reportType {
+	case models.ReportWeeklyRefunds:
+		return to.AddDate(0, 0, 7)
+	case models.ReportMonthlyTeacherEarnings, models.ReportMonthlyServiceUsage:
+		return to.AddDate(0, 1, 0)
+	default:
+		return to.AddDate(0, 0, 1)
+	}
+}
@@ -0,0 +1,218 @@
+// report_subscription_handler.go contains /admin/report-subscriptions handlers and the delivery run by the report_subscriptions job.
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/notify"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type reportSubscriptionRequest struct {
+	ReportType string `json:"report_type"`
+	Channel    string `json:"channel"`
+	Target     string `json:"target"`
+	Active     *bool  `json:"active,omitempty"`
+}
+
+// (helper for report subscription handlers) validate channel/target pairs.
+func validateReportTarget(channel, target string) string {
+	switch channel {
+	case models.DeliveryEmail:
+		if !strings.Contains(target, "@") {
+			return "target must be an email address for channel email"
+		}
+	case models.DeliverySlack:
+		if !strings.HasPrefix(target, "https://") {
+			return "target must be a Slack incoming-webhook URL (https://...) for channel slack"
+		}
+	default:
+		return `channel must be "email" or "slack"`
+	}
+	return ""
+}
+
+func (h *PaymentHandler) ListReportSubscriptions(c *fiber.Ctx) error {
+	var subs []models.ReportSubscription
+	if err := h.db(c).Order("id").Find(&subs).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve report subscriptions").Wrap(err)
+	}
+	return c.JSON(fiber.Map{"report_subscriptions": subs})
+}
+
+func (h *PaymentHandler) CreateReportSubscription(c *fiber.Ctx) error {
+	var req reportSubscriptionRequest
+	if err := parseAndValidate(c, &req); err != nil {
+		return err
+	}
+	if !isValidReportType(req.ReportType) {
+		return apperrors.ErrValidation.WithMessagef("unsupported report_type: %s", req.ReportType)
+	}
+	if msg := validateReportTarget(req.Channel, req.Target); msg != "" {
+		return apperrors.ErrValidation.WithMessage(msg)
+	}
+
+	sub := models.ReportSubscription{
+		ReportType: req.ReportType,
+		Channel:    req.Channel,
+		Target:     req.Target,
+		Active:     req.Active == nil || *req.Active,
+		NextRunAt:  nextReportRun(req.ReportType, time.Now()),
+		CreatedBy:  adminActor(c),
+	}
+	if err := h.db(c).Create(&sub).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to create report subscription").Wrap(err)
+	}
+	h.audit(auditEntry(c, models.AuditReportSubscription, "report_subscription", fmt.Sprintf("%d", sub.ID), nil, sub))
+	return c.Status(201).JSON(sub)
+}
+
+func (h *PaymentHandler) GetReportSubscription(c *fiber.Ctx) error {
+	sub, err := h.findReportSubscription(c.Params("id"))
+	if err != nil {
+		return reportSubscriptionError(c, err)
+	}
+	return c.JSON(sub)
+}
+
+func (h *PaymentHandler) UpdateReportSubscription(c *fiber.Ctx) error {
+	sub, err := h.findReportSubscription(c.Params("id"))
+	if err != nil {
+		return reportSubscriptionError(c, err)
+	}
+	before := *sub
+	var req reportSubscriptionRequest
+	if err := parseAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	if req.ReportType != "" && req.ReportType != sub.ReportType {
+		if !isValidReportType(req.ReportType) {
+			return apperrors.ErrValidation.WithMessagef("unsupported report_type: %s", req.ReportType)
+		}
+		sub.ReportType = req.ReportType
+		sub.NextRunAt = nextReportRun(req.ReportType, time.Now())
+	}
+	if req.Channel != "" {
+		sub.Channel = req.Channel
+	}
+	if req.Target != "" {
+		sub.Target = req.Target
+	}
+	if msg := validateReportTarget(sub.Channel, sub.Target); msg != "" {
+		return apperrors.ErrValidation.WithMessage(msg)
+	}
+	if req.Active != nil {
+		sub.Active = *req.Active
+	}
+
+	if err := h.db(c).Save(sub).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to update report subscription").Wrap(err)
+	}
+	h.audit(auditEntry(c, models.AuditReportSubscription, "report_subscription", fmt.Sprintf("%d", sub.ID), before, sub))
+	return c.JSON(sub)
+}
+
+func (h *PaymentHandler) DeleteReportSubscription(c *fiber.Ctx) error {
+	sub, err := h.findReportSubscription(c.Params("id"))
+	if err != nil {
+		return reportSubscriptionError(c, err)
+	}
+	if err := h.db(c).Delete(sub).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to delete report subscription").Wrap(err)
+	}
+	h.audit(auditEntry(c, models.AuditReportSubscription, "report_subscription", fmt.Sprintf("%d", sub.ID), sub, nil))
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// SendReportSubscriptionNow delivers the most recent period immediately (does not move next_run_at).
+func (h *PaymentHandler) SendReportSubscriptionNow(c *fiber.Ctx) error {
+	sub, err := h.findReportSubscription(c.Params("id"))
+	if err != nil {
+		return reportSubscriptionError(c, err)
+	}
+	from, to := reportPeriod(sub.ReportType, time.Now())
+	report, err := h.buildReport(sub.ReportType, from, to)
+	if err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to build report").Wrap(err)
+	}
+	if err := h.deliverReport(sub, report); err != nil {
+		return apperrors.ErrOmiseUnavailable.WithMessage("Failed to deliver report").Wrap(err)
+	}
+	return c.JSON(report)
+}
+
+func (h *PaymentHandler) findReportSubscription(id string) (*models.ReportSubscription, error) {
+	var sub models.ReportSubscription
+	if err := h.DB.First(&sub, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+func reportSubscriptionError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperrors.ErrNotFound.WithMessage("Report subscription not found")
+	}
+	return apperrors.ErrInternal.WithMessage("Failed to retrieve report subscription").Wrap(err)
+}
+
+// ---------------------- delivery ----------------------
+
+// runDueReportSubscriptions sends every active subscription due at now (the report_subscriptions job).
+func (h *PaymentHandler) runDueReportSubscriptions(ctx context.Context, now time.Time) error {
+	db := h.DB.WithContext(ctx)
+	var due []models.ReportSubscription
+	if err := db.Where("active = ? AND next_run_at <= ?", true, now).Find(&due).Error; err != nil {
+		return fmt.Errorf("load due subscriptions: %w", err)
+	}
+
+	for i := range due {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		sub := &due[i]
+		// Claim the run by moving next_run_at; another replica that loses the race skips it.
+		res := db.Model(&models.ReportSubscription{}).
+			Where("id = ? AND next_run_at = ?", sub.ID, sub.NextRunAt).
+			Update("next_run_at", nextReportRun(sub.ReportType, now))
+		if res.Error != nil || res.RowsAffected == 0 {
+			continue
+		}
+
+		from, to := reportPeriod(sub.ReportType, now)
+		report, err := h.buildReport(sub.ReportType, from, to)
+		if err == nil {
+			err = h.deliverReport(sub, report)
+		}
+
+		updates := map[string]interface{}{"last_error": nil}
+		if err != nil {
+			log.Printf("reports: subscription=%d type=%s failed err=%v", sub.ID, sub.ReportType, err)
+			updates["last_error"] = err.Error()
+		} else {
+			updates["last_sent_at"] = now
+		}
+		db.Model(&models.ReportSubscription{}).Where("id = ?", sub.ID).Updates(updates)
+	}
+	return nil
+}
+
+func (h *PaymentHandler) deliverReport(sub *models.ReportSubscription, report *Report) error {
+	msg := report.Message()
+	switch sub.Channel {
+	case models.DeliveryEmail:
+		return h.Mailer.Send(sub.Target, msg)
+	case models.DeliverySlack:
+		return notify.PostSlack(sub.Target, msg)
+	}
+	return errors.New("unsupported channel: " + sub.Channel)
+}
@@ -0,0 +1,201 @@
+// scheduled_jobs.go defines the periodic background jobs main hands to the jobs scheduler: expiring
+// stale pending charges, reconciling pending charges with Omise, the nightly full reconciliation,
+// monthly payout statements, pruning old raw payloads, the transaction rollups, LINE QR-expiry reminders,
+// the charge failure rate alert, the warehouse export, syncing Omise fees, importing Omise transfers,
+// creating the coming months' transaction partitions, sending due report subscriptions, the nightly
+// ledger consistency checks and saving API usage counts.
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/jobs"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/service"
+)
+
+// transfersLookback is how far back the transfers job re-reads Omise's transfers, so a transfer whose
+// status changed after its webhook (sent, paid, failed) is updated.
+const transfersLookback = 7 * 24 * time.Hour
+
+// partitionMonthsAhead is how many months past the current one the transaction_partitions job keeps
+// partitions for, so a few failed runs do not send charges to transactions_default.
+const partitionMonthsAhead = 3
+
+// JobSchedules configures ScheduledJobs. Schedules are cron expressions evaluated in Bangkok time; an
+// empty schedule leaves that job out.
+type JobSchedules struct {
+	ExpirePending         string
+	PendingTTL            time.Duration // pending charges without an expiry older than this are expired; others at their expires_at
+	Reconcile             string
+	ReconcileAfter        time.Duration // pending charges younger than this are left to their webhook
+	FullReconcile         string        // the previous Bangkok day's charges against Omise's list
+	FullReconcileHeal     bool
+	PayoutStatements      string // drafts for the previous calendar month
+	PruneRawPayloads      string
+	RawPayloadRetention   time.Duration
+	WarehouseExport       string // the previous Bangkok day's transactions; only when PaymentHandler.Warehouse has a Store
+	TransactionRollup     string
+	RollupLookbackDays    int           // days before today re-rolled by every transaction_rollup run
+	LineQRReminders       string        // only when PaymentHandler.Line is configured
+	LineQRReminderLead    time.Duration // how long before a PromptPay QR expires its payer is reminded
+	ChargeFailureRate     string        // only when PaymentHandler.OpsAlerts has a FailureRate
+	ChargeFees            string        // Omise's fees of successful charges not synced yet
+	Transfers             string        // Omise's transfers of the last transfersLookback, and their charges
+	TransactionPartitions string        // monthly partitions of transactions up to partitionMonthsAhead
+	ReportSubscriptions   string        // report subscriptions whose next run is due
+	ConsistencyChecks     string        // the ledger consistency checks, once per Bangkok day
+}
+
+// ScheduledJobs returns the background jobs enabled in s, plus flush_usage, which always runs.
+func (h *PaymentHandler) ScheduledJobs(s JobSchedules) ([]jobs.Job, error) {
+	defs := []struct {
+		name, expr string
+		run        func(ctx context.Context) error
+	}{
+		{"expire_pending", s.ExpirePending, func(ctx context.Context) error {
+			n, err := h.Payments.ExpireStalePending(ctx, time.Now(), s.PendingTTL)
+			logJobCount("expire_pending", "expired", int64(n))
+			return err
+		}},
+		{"reconcile", s.Reconcile, func(ctx context.Context) error {
+			n, err := h.Payments.ReconcilePending(ctx, time.Now().Add(-s.ReconcileAfter))
+			logJobCount("reconcile", "settled", int64(n))
+			return err
+		}},
+		{"full_reconcile", s.FullReconcile, func(ctx context.Context) error {
+			return h.reconcilePreviousDay(ctx, time.Now(), s.FullReconcileHeal)
+		}},
+		{"payout_statements", s.PayoutStatements, func(ctx context.Context) error {
+			return h.createMonthlyPayoutStatements(ctx, time.Now())
+		}},
+		{"prune_raw_payloads", s.PruneRawPayloads, func(ctx context.Context) error {
+			n, err := h.Payments.PruneRawPayloads(ctx, time.Now().Add(-s.RawPayloadRetention))
+			logJobCount("prune_raw_payloads", "pruned", n)
+			return err
+		}},
+		{"transaction_rollup", s.TransactionRollup, func(ctx context.Context) error {
+			n, err := h.refreshTransactionRollups(ctx, time.Now(), s.RollupLookbackDays)
+			logJobCount("transaction_rollup", "days", int64(n))
+			return err
+		}},
+		{"charge_fees", s.ChargeFees, func(ctx context.Context) error {
+			n, err := h.Payments.SyncChargeFees(ctx)
+			logJobCount("charge_fees", "synced", int64(n))
+			return err
+		}},
+		{"transfers", s.Transfers, func(ctx context.Context) error {
+			imported, matched, err := h.Payments.SyncTransfers(ctx, time.Now().Add(-transfersLookback))
+			logJobCount("transfers", "imported", int64(imported))
+			logJobCount("transfers", "matched", int64(matched))
+			return err
+		}},
+		{"transaction_partitions", s.TransactionPartitions, func(ctx context.Context) error {
+			n, err := h.Payments.EnsureTransactionPartitions(ctx, partitionMonthsAhead)
+			logJobCount("transaction_partitions", "created", int64(n))
+			return err
+		}},
+		{"report_subscriptions", s.ReportSubscriptions, func(ctx context.Context) error {
+			return h.runDueReportSubscriptions(ctx, time.Now())
+		}},
+		{"consistency_checks", s.ConsistencyChecks, func(ctx context.Context) error {
+			// One run per Bangkok day: the key is claimed by the first replica, or run, to get there.
+			key := models.ConsistencyNightly + ":" + time.Now().In(bangkok).Format("2006-01-02")
+			_, err := h.runConsistencyChecks(ctx, models.ConsistencyNightly, key)
+			return err
+		}},
+	}
+	if h.Line.Enabled() {
+		defs = append(defs, struct {
+			name, expr string
+			run        func(ctx context.Context) error
+		}{"line_qr_reminders", s.LineQRReminders, func(ctx context.Context) error {
+			n, err := h.remindExpiringQRs(ctx, time.Now(), s.LineQRReminderLead)
+			logJobCount("line_qr_reminders", "reminded", int64(n))
+			return err
+		}})
+	}
+	if h.OpsAlerts.FailureRate > 0 {
+		defs = append(defs, struct {
+			name, expr string
+			run        func(ctx context.Context) error
+		}{"charge_failure_rate", s.ChargeFailureRate, func(ctx context.Context) error {
+			_, err := h.checkChargeFailureRate(ctx, time.Now())
+			return err
+		}})
+	}
+	if h.Warehouse.Store != nil {
+		defs = append(defs, struct {
+			name, expr string
+			run        func(ctx context.Context) error
+		}{"warehouse_export", s.WarehouseExport, func(ctx context.Context) error {
+			return h.exportPreviousDay(ctx, time.Now())
+		}})
+	}
+	var out []jobs.Job
+	for _, d := range defs {
+		if d.expr == "" {
+			continue
+		}
+		sched, err := jobs.ParseCron(d.expr, bangkok)
+		if err != nil {
+			return nil, fmt.Errorf("job %s: %w", d.name, err)
+		}
+		run := d.run
+		out = append(out, jobs.Job{Name: d.name, Schedule: sched, Timeout: 30 * time.Minute, Run: func(ctx context.Context) error {
+			return run(service.WithEventSource(ctx, models.TransactionEventJob))
+		}})
+	}
+	out = append(out, jobs.Job{Name: "flush_usage", Schedule: jobs.Every(time.Minute), Run: h.FlushUsage, Timeout: time.Minute})
+	return out, nil
+}
+
+// (helper for ScheduledJobs) log what a run did, when it did anything.
+func logJobCount(job, what string, n int64) {
+	if n > 0 {
+		log.Printf("jobs: job=%s %s=%d", job, what, n)
+	}
+}
+
+// createMonthlyPayoutStatements drafts the previous calendar month's (Bangkok) payout statement for
+// every teacher with earnings or matured reserve holds in it. Statements that already exist (created
+// by an admin or by another replica) are skipped.
+func (h *PaymentHandler) createMonthlyPayoutStatements(ctx context.Context, now time.Time) error {
+	local := now.In(bangkok)
+	to := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, bangkok)
+	from := to.AddDate(0, -1, 0)
+
+	var payees []struct {
+		TeacherID string
+		Currency  string
+	}
+	if err := h.DB.WithContext(ctx).Raw(`
+		SELECT meta->>'teacher_id' AS teacher_id, LOWER(currency) AS currency FROM transactions
+		WHERE status = 'successful' AND meta->>'teacher_id' <> '' AND created_at >= ? AND created_at < ? AND deleted_at IS NULL
+		UNION
+		SELECT teacher_id, currency FROM payout_reserve_entries
+		WHERE kind = ? AND released_at IS NULL AND release_after <= ?
+		ORDER BY 1, 2`, from, to, models.PayoutReserveHold, to).Scan(&payees).Error; err != nil {
+		return err
+	}
+
+	created := 0
+	for _, p := range payees {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if _, err := h.createPayoutStatement(nil, p.TeacherID, p.Currency, from, to); err != nil {
+			if dbutil.IsUniqueViolation(err) {
+				continue
+			}
+			return fmt.Errorf("statement for teacher %s (%s): %w", p.TeacherID, p.Currency, err)
+		}
+		created++
+	}
+	logJobCount("payout_statements", "created", int64(created))
+	return nil
+}
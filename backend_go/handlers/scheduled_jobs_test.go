@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduledJobsLeaveOutEmptySchedules(t *testing.T) {
+	h := NewPaymentHandler(nil, nil)
+	list, err := h.ScheduledJobs(JobSchedules{ReportSubscriptions: "* * * * *", ConsistencyChecks: "0 3 * * *"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, j := range list {
+		names = append(names, j.Name)
+	}
+	want := []string{"report_subscriptions", "consistency_checks", "flush_usage"}
+	if len(names) != len(want) {
+		t.Fatalf("jobs = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("jobs = %v, want %v", names, want)
+		}
+	}
+
+	// The consistency checks run at 03:00 Bangkok time.
+	from := time.Date(2025, 3, 1, 22, 0, 0, 0, time.UTC) // 05:00 on Mar 2 in Bangkok
+	if got, want := list[1].Schedule.Next(from), time.Date(2025, 3, 3, 3, 0, 0, 0, bangkok); !got.Equal(want) {
+		t.Errorf("consistency_checks after %s = %s, want %s", from, got, want)
+	}
+
+	if _, err := h.ScheduledJobs(JobSchedules{ConsistencyChecks: "0 25 * * *"}); err == nil {
+		t.Error("ScheduledJobs accepted hour 25")
+	}
+}
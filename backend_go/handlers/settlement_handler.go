@@ -0,0 +1,195 @@
+// settlement_handler.go serves /admin/settlements: gross charge volume against what Omise pays out
+// after its fees, per Bangkok day, so finance can match it to the bank deposits; and
+// /admin/settlements/transfers: Omise's transfers to the bank against the charges matched to them.
+package handlers
+
+import (
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// settlementMaxDays bounds the range of one settlement report.
+const settlementMaxDays = 93
+
+// SettlementRow is one day's successful charges in one currency. Charges whose fees are not synced
+// from Omise yet (Unsynced) count in GrossSatang but not in the fee and net columns.
+type SettlementRow struct {
+	Day          string `json:"day,omitempty"` // YYYY-MM-DD, Bangkok; empty in totals
+	Currency     string `json:"currency"`
+	Charges      int64  `json:"charges"`
+	GrossSatang  int64  `json:"gross_satang"`
+	FeeSatang    int64  `json:"fee_satang"`
+	FeeVATSatang int64  `json:"fee_vat_satang"`
+	NetSatang    int64  `json:"net_satang"`
+	Unsynced     int64  `json:"unsynced"`
+}
+
+// GetSettlements reports gross, fees and net of successful charges per Bangkok day and currency over
+// ?from=&to= (YYYY-MM-DD, inclusive; default the last 7 days), with totals per currency. Fees come
+// from the charge_fees job, so today's charges may still be unsynced.
+//
+//	GET /api/v1/admin/settlements?from=2026-10-01&to=2026-10-31
+func (h *PaymentHandler) GetSettlements(c *fiber.Ctx) error {
+	from, to, err := settlementRange(c.Query("from"), c.Query("to"), time.Now())
+	if err != nil {
+		return err
+	}
+	rows := []SettlementRow{}
+	if err := dbutil.Replica(h.db(c)).Model(&models.Transaction{}).
+		Select(`to_char(created_at AT TIME ZONE 'Asia/Bangkok', 'YYYY-MM-DD') AS day, UPPER(currency) AS currency,
+			COUNT(*) AS charges, SUM(amount_satang) AS gross_satang, SUM(fee_satang) AS fee_satang,
+			SUM(fee_vat_satang) AS fee_vat_satang, SUM(net_satang) AS net_satang,
+			COUNT(*) FILTER (WHERE fees_synced_at IS NULL) AS unsynced`).
+		Where("status = ? AND created_at >= ? AND created_at < ?", "successful", from, to).
+		Group("1, 2").Order("1, 2").Scan(&rows).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to build settlement report").Wrap(err)
+	}
+	return c.JSON(fiber.Map{
+		"from":   from.Format("2006-01-02"),
+		"to":     to.AddDate(0, 0, -1).Format("2006-01-02"),
+		"days":   rows,
+		"totals": settlementTotals(rows),
+	})
+}
+
+// (helper for GetSettlements) the [from, to) of the inclusive days from and to, by default the 7 days
+// up to and including now's.
+func settlementRange(fromParam, toParam string, now time.Time) (time.Time, time.Time, error) {
+	local := now.In(bangkok)
+	to := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, bangkok).AddDate(0, 0, 1)
+	if toParam != "" {
+		day, err := time.ParseInLocation("2006-01-02", toParam, bangkok)
+		if err != nil {
+			return time.Time{}, time.Time{}, apperrors.ErrValidation.WithMessage("to must be YYYY-MM-DD")
+		}
+		to = day.AddDate(0, 0, 1)
+	}
+	from := to.AddDate(0, 0, -7)
+	if fromParam != "" {
+		day, err := time.ParseInLocation("2006-01-02", fromParam, bangkok)
+		if err != nil {
+			return time.Time{}, time.Time{}, apperrors.ErrValidation.WithMessage("from must be YYYY-MM-DD")
+		}
+		from = day
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, apperrors.ErrValidation.WithMessage("from must not be after to")
+	}
+	if from.AddDate(0, 0, settlementMaxDays).Before(to) {
+		return time.Time{}, time.Time{}, apperrors.ErrValidation.WithMessagef("the range must be at most %d days", settlementMaxDays)
+	}
+	return from, to, nil
+}
+
+// (helper for GetSettlements) rows summed per currency, in the order the currencies first appear.
+func settlementTotals(rows []SettlementRow) []SettlementRow {
+	totals := []SettlementRow{}
+	index := map[string]int{}
+	for _, r := range rows {
+		i, ok := index[r.Currency]
+		if !ok {
+			i = len(totals)
+			index[r.Currency] = i
+			totals = append(totals, SettlementRow{Currency: r.Currency})
+		}
+		t := &totals[i]
+		t.Charges += r.Charges
+		t.GrossSatang += r.GrossSatang
+		t.FeeSatang += r.FeeSatang
+		t.FeeVATSatang += r.FeeVATSatang
+		t.NetSatang += r.NetSatang
+		t.Unsynced += r.Unsynced
+	}
+	return totals
+}
+
+// TransferSettlement is an Omise transfer with how far its matched charges are from it;
+// DiscrepancySatang is nil until the transfer is matched (see service.MatchTransfers).
+type TransferSettlement struct {
+	models.OmiseTransfer
+	DiscrepancySatang *int64 `json:"discrepancy_satang,omitempty"`
+}
+
+// UnsettledCharge is a successful charge no transfer paid out yet. Overdue charges became transferable
+// before a transfer of their account that is already matched, so they should have been in it.
+type UnsettledCharge struct {
+	ID             uint       `json:"id"`
+	MerchantID     uint       `json:"merchant_id"`
+	ChargeID       string     `json:"charge_id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	AmountSatang   int64      `json:"amount_satang"`
+	NetSatang      int64      `json:"net_satang"`
+	Currency       string     `json:"currency"`
+	TransferableAt *time.Time `json:"transferable_at,omitempty"` // nil until the fees are synced
+	Overdue        bool       `json:"overdue"`
+}
+
+// GetTransferSettlements reports the Omise transfers made over ?from=&to= (as GetSettlements) with
+// their discrepancies, and a page (?limit=&offset=) of the successful charges created over the range
+// that no transfer paid out yet, oldest first. Transfers and their charges come from the transfers job
+// and transfer webhooks.
+//
+//	GET /api/v1/admin/settlements/transfers?from=2026-10-01&to=2026-10-31
+func (h *PaymentHandler) GetTransferSettlements(c *fiber.Ctx) error {
+	from, to, err := settlementRange(c.Query("from"), c.Query("to"), time.Now())
+	if err != nil {
+		return err
+	}
+	limit, offset := h.limitOffset(c)
+	db := dbutil.Replica(h.db(c))
+
+	var transfers []models.OmiseTransfer
+	if err := db.Where("transferred_at >= ? AND transferred_at < ?", from, to).
+		Order("transferred_at, id").Find(&transfers).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to build transfer report").Wrap(err)
+	}
+	out, discrepancies := transferSettlements(transfers)
+
+	unsettled := func() *gorm.DB {
+		return db.Model(&models.Transaction{}).
+			Where("status = ? AND omise_transfer_id IS NULL AND created_at >= ? AND created_at < ?", "successful", from, to)
+	}
+	var total int64
+	if err := unsettled().Count(&total).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to build transfer report").Wrap(err)
+	}
+	charges := []UnsettledCharge{}
+	if err := unsettled().Select(`id, merchant_id, charge_id, created_at, amount_satang, net_satang, UPPER(currency) AS currency,
+			transferable_at, transferable_at <= (SELECT MAX(o.transferred_at) FROM omise_transfers o
+				WHERE o.merchant_id = transactions.merchant_id AND o.matched_at IS NOT NULL) IS TRUE AS overdue`).
+		Order("created_at, id").Limit(limit).Offset(offset).Scan(&charges).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to build transfer report").Wrap(err)
+	}
+	return c.JSON(fiber.Map{
+		"from":          from.Format("2006-01-02"),
+		"to":            to.AddDate(0, 0, -1).Format("2006-01-02"),
+		"transfers":     out,
+		"discrepancies": discrepancies,
+		"unsettled":     charges,
+		"pagination":    fiber.Map{"limit": limit, "offset": offset, "total": total},
+	})
+}
+
+// (helper for GetTransferSettlements) transfers with their discrepancies, and how many matched
+// transfers have one.
+func transferSettlements(transfers []models.OmiseTransfer) ([]TransferSettlement, int) {
+	out := make([]TransferSettlement, 0, len(transfers))
+	discrepancies := 0
+	for _, t := range transfers {
+		row := TransferSettlement{OmiseTransfer: t}
+		if t.MatchedAt != nil {
+			d := t.DiscrepancySatang()
+			row.DiscrepancySatang = &d
+			if d != 0 {
+				discrepancies++
+			}
+		}
+		out = append(out, row)
+	}
+	return out, discrepancies
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	omise "github.com/omise/omise-go"
+)
+
+func TestSettlementRange(t *testing.T) {
+	now := time.Date(2026, 10, 16, 23, 30, 0, 0, time.UTC) // 06:30 on the 17th in Bangkok
+	from, to, err := settlementRange("", "", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 10, 18, 0, 0, 0, 0, bangkok); !to.Equal(want) || !from.Equal(want.AddDate(0, 0, -7)) {
+		t.Errorf("default range = [%s, %s), want the 7 Bangkok days up to the 17th", from, to)
+	}
+
+	from, to, err = settlementRange("2026-10-01", "2026-10-31", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !from.Equal(time.Date(2026, 10, 1, 0, 0, 0, 0, bangkok)) || !to.Equal(time.Date(2026, 11, 1, 0, 0, 0, 0, bangkok)) {
+		t.Errorf("range = [%s, %s), want October inclusive", from, to)
+	}
+
+	for name, r := range map[string][2]string{
+		"bad day":    {"2026-10", ""},
+		"reversed":   {"2026-10-10", "2026-10-01"},
+		"too long":   {"2026-01-01", "2026-10-01"},
+		"bad to day": {"", "yesterday"},
+	} {
+		if _, _, err := settlementRange(r[0], r[1], now); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestSettlementTotals(t *testing.T) {
+	totals := settlementTotals([]SettlementRow{
+		{Day: "2026-10-01", Currency: "THB", Charges: 2, GrossSatang: 200000, FeeSatang: 7300, FeeVATSatang: 511, NetSatang: 192189},
+		{Day: "2026-10-01", Currency: "USD", Charges: 1, GrossSatang: 5000, FeeSatang: 183, FeeVATSatang: 13, NetSatang: 4804},
+		{Day: "2026-10-02", Currency: "THB", Charges: 1, GrossSatang: 50000, Unsynced: 1},
+	})
+	if len(totals) != 2 || totals[0].Currency != "THB" || totals[1].Currency != "USD" {
+		t.Fatalf("totals = %+v, want THB then USD", totals)
+	}
+	if thb := totals[0]; thb.Charges != 3 || thb.GrossSatang != 250000 || thb.NetSatang != 192189 || thb.Unsynced != 1 || thb.Day != "" {
+		t.Errorf("THB total = %+v", thb)
+	}
+}
+
+func TestTransferSettlements(t *testing.T) {
+	matched := time.Date(2026, 10, 16, 5, 0, 0, 0, bangkok)
+	out, discrepancies := transferSettlements([]models.OmiseTransfer{
+		{TransferID: "trsf_1", AmountSatang: 96095, FeeSatang: 3000, MatchedAt: &matched, MatchedNetSatang: 99095},
+		{TransferID: "trsf_2", AmountSatang: 50000, FeeSatang: 3000, MatchedAt: &matched, MatchedNetSatang: 48047}, // a refund in between
+		{TransferID: "trsf_3", AmountSatang: 10000, FeeSatang: 3000},
+	})
+	if discrepancies != 1 || len(out) != 3 {
+		t.Fatalf("got %d rows with %d discrepancies, want 3 with 1", len(out), discrepancies)
+	}
+	if d := out[0].DiscrepancySatang; d == nil || *d != 0 {
+		t.Errorf("trsf_1 discrepancy = %v, want 0", d)
+	}
+	if d := out[1].DiscrepancySatang; d == nil || *d != -4953 {
+		t.Errorf("trsf_2 discrepancy = %v, want -4953", d)
+	}
+	if out[2].DiscrepancySatang != nil {
+		t.Errorf("unmatched trsf_3 has a discrepancy: %d", *out[2].DiscrepancySatang)
+	}
+}
+
+func TestEventTransfer(t *testing.T) {
+	if tr, ok := eventTransfer(&omise.Event{Key: "transfer.pay", Data: &omise.Transfer{Base: omise.Base{ID: "trsf_1"}}}); !ok || tr.ID != "trsf_1" {
+		t.Errorf("transfer.pay event: got %v, %v", tr, ok)
+	}
+	if _, ok := eventTransfer(&omise.Event{Key: "charge.complete", Data: &omise.Charge{}}); ok {
+		t.Error("charge event read as a transfer")
+	}
+	if _, ok := eventTransfer(nil); ok {
+		t.Error("nil event read as a transfer")
+	}
+}
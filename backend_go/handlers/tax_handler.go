@@ -0,0 +1,87 @@
+// tax_handler.go submits e-Tax invoice data for successful charges and stores the returned document references.
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/tax"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// taxSubmitTimeout bounds one call to the external tax provider.
+const taxSubmitTimeout = 30 * time.Second
+
+// submitTaxInvoice issues the tax document for a successful transaction. It is idempotent: a transaction
+// with a submitted document is skipped, and a failed attempt is recorded with its error for a later retry.
+func (h *PaymentHandler) submitTaxInvoice(ctx context.Context, transactionID uint) {
+	if h.Tax == nil {
+		return
+	}
+
+	t, err := h.Transactions.WithContext(ctx).Get(transactionID)
+	if err != nil {
+		log.Printf("tax: load transaction %d: %v", transactionID, err)
+		return
+	}
+	if t.Status != "successful" {
+		return
+	}
+
+	var existing models.TaxDocument
+	err = h.DB.WithContext(ctx).Where("transaction_id = ?", t.ID).Take(&existing).Error
+	if err == nil && existing.Status == models.TaxDocumentSubmitted {
+		return
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("tax: load document for transaction %d: %v", t.ID, err)
+		return
+	}
+
+	// Transactions recorded before VAT was persisted (or with VAT_RATE_PCT=0) use the standard rate.
+	rate := t.VATRateBps
+	if rate == 0 {
+		rate = tax.VATRateBps
+	}
+	inv := tax.NewInvoice(t.ChargeID, t.ID, t.UserID, t.Amount(), rate, "Tutorium wallet top-up", t.UpdatedAt)
+	inv.Number = deref(t.TaxInvoiceNumber)
+	submitCtx, cancel := context.WithTimeout(ctx, taxSubmitTimeout)
+	defer cancel()
+	doc, submitErr := h.Tax.Submit(submitCtx, inv)
+
+	rec := models.TaxDocument{
+		TransactionID: t.ID,
+		ChargeID:      t.ChargeID,
+		Provider:      h.Tax.Name(),
+		NetSatang:     inv.Net.Amount,
+		VATSatang:     inv.VAT.Amount,
+		Attempts:      existing.Attempts + 1,
+	}
+	if submitErr != nil {
+		log.Printf("tax: submit invoice for charge %s: %v", t.ChargeID, submitErr)
+		rec.Status = models.TaxDocumentFailed
+		rec.LastError = submitErr.Error()
+	} else {
+		rec.Status = models.TaxDocumentSubmitted
+		rec.DocumentID = doc.DocumentID
+		rec.Number = doc.Number
+		rec.URL = doc.URL
+	}
+
+	if err := dbutil.Retry("save_tax_document", func() error {
+		return h.DB.WithContext(ctx).Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "transaction_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{
+				"provider", "status", "document_id", "number", "url",
+				"net_satang", "vat_satang", "attempts", "last_error", "updated_at",
+			}),
+		}).Create(&rec).Error
+	}); err != nil {
+		log.Printf("tax: save document for transaction %d: %v", t.ID, err)
+	}
+}
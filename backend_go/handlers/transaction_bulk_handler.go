@@ -0,0 +1,289 @@
+// transaction_bulk_handler.go is the admin bulk upsert of historical transactions exported from the
+// old Python billing service, keyed on their charge ids so a migration can be re-run.
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/money"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// bulkTransactionsMaxRecords bounds one batch so it fits in a single DB transaction.
+const bulkTransactionsMaxRecords = 1000
+
+// bulkTransactionSource marks transactions the bulk upsert wrote (meta.legacy_source); only those are
+// updated by a later batch.
+const bulkTransactionSource = "billing"
+
+// Bulk upsert record results.
+const (
+	bulkRecordCreated  = "created"  // new charge id (or would be, on a dry run)
+	bulkRecordUpdated  = "updated"  // replaces the record an earlier batch imported
+	bulkRecordSkipped  = "skipped"  // the charge id belongs to a transaction this service recorded; it wins
+	bulkRecordRejected = "rejected" // invalid; see errors
+)
+
+// bulkTransactionRecord is one historical transaction. Amounts are minor units (satang for THB);
+// CreatedAt is when the charge was made and becomes the transaction's created_at.
+type bulkTransactionRecord struct {
+	ChargeID       string                 `json:"charge_id" validate:"required,max=100"`
+	UserID         *uint                  `json:"user_id,omitempty"`
+	Amount         int64                  `json:"amount" validate:"gt=0"`
+	Currency       string                 `json:"currency,omitempty" validate:"omitempty,len=3,alpha"` // default thb
+	Status         string                 `json:"status" validate:"required,oneof=pending successful failed reversed expired canceled"`
+	Channel        string                 `json:"channel,omitempty" validate:"max=40"`
+	CreatedAt      *time.Time             `json:"created_at" validate:"required"`
+	UpdatedAt      *time.Time             `json:"updated_at,omitempty"` // default created_at
+	Description    string                 `json:"description,omitempty" validate:"max=255"`
+	FailureCode    string                 `json:"failure_code,omitempty" validate:"max=100"`
+	FailureMessage string                 `json:"failure_message,omitempty" validate:"max=255"`
+	Meta           map[string]interface{} `json:"meta,omitempty"`
+}
+
+type bulkTransactionsRequest struct {
+	DryRun       bool                    `json:"dry_run"`
+	Transactions []bulkTransactionRecord `json:"transactions"`
+}
+
+// bulkRecordResult reports what happened to one record.
+type bulkRecordResult struct {
+	Index    int                    `json:"index"` // 0-based position in the request
+	ChargeID string                 `json:"charge_id"`
+	Result   string                 `json:"result"`
+	Errors   []apperrors.FieldError `json:"errors,omitempty"`
+}
+
+// BulkUpsertTransactions validates a batch of historical transactions and, unless dry_run is set,
+// upserts it by charge id in one DB transaction. Any rejected record rejects the whole batch (422 with
+// per-record errors). Re-sending a record updates what the earlier batch imported; a charge id this
+// service recorded itself is skipped. Balances are untouched, and the records are marked like the
+// ledger import's (meta.legacy_ref) so reconciliation and the consistency checks leave them alone.
+//
+//	POST /api/v1/admin/transactions/bulk {"dry_run": true, "transactions": [{"charge_id": "chrg_...", ...}]}
+func (h *PaymentHandler) BulkUpsertTransactions(c *fiber.Ctx) error {
+	var req bulkTransactionsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return apperrors.ErrBadRequest.WithCode("invalid_body").WithMessage("request body must be valid JSON matching the documented schema")
+	}
+	if len(req.Transactions) == 0 || len(req.Transactions) > bulkTransactionsMaxRecords {
+		return apperrors.ErrValidation.WithMessagef("transactions must contain between 1 and %d entries", bulkTransactionsMaxRecords)
+	}
+	if c.QueryBool("dry_run") {
+		req.DryRun = true
+	}
+
+	results, existing, err := h.checkBulkRecords(c, req.Transactions)
+	if err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to validate transactions").Wrap(err)
+	}
+	summary := summarizeBulkRecords(results)
+	summary["dry_run"] = req.DryRun
+	summary["transactions"] = results
+	if summary["rejected"].(int) > 0 {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(summary)
+	}
+	if req.DryRun {
+		return c.JSON(summary)
+	}
+
+	batchID := fmt.Sprintf("transactions-bulk-%d", time.Now().UnixNano())
+	err = dbutil.Transaction(h.db(c), "transactions_bulk", func(tx *gorm.DB) error {
+		for i, rec := range req.Transactions {
+			if results[i].Result == bulkRecordSkipped {
+				continue
+			}
+			if err := h.upsertBulkRecord(tx, batchID, rec, existing[rec.ChargeID]); err != nil {
+				return fmt.Errorf("record %d (%s): %w", i, rec.ChargeID, err)
+			}
+		}
+		return writeAudit(tx, auditEntry(c, models.AuditTransactionBulk, "transactions_bulk", batchID, nil,
+			fiber.Map{"created": summary["created"], "updated": summary["updated"], "skipped": summary["skipped"]}))
+	})
+	if err != nil {
+		if dbutil.IsUniqueViolation(err) {
+			return apperrors.ErrConflict.WithMessage("another batch with the same charge ids is in progress; retry to update them").Wrap(err)
+		}
+		return apperrors.ErrInternal.WithMessage("Failed to import transactions").Wrap(err)
+	}
+	log.Printf("transactions bulk: batch=%s created=%d updated=%d skipped=%d", batchID, summary["created"], summary["updated"], summary["skipped"])
+	h.Payments.TransactionCache.InvalidateTotals(c.UserContext())
+
+	summary["batch_id"] = batchID
+	return c.Status(fiber.StatusCreated).JSON(summary)
+}
+
+// (helper for BulkUpsertTransactions) validate every record and decide, from the transactions already
+// holding their charge ids (returned by charge id), whether each is created, updated or skipped.
+func (h *PaymentHandler) checkBulkRecords(c *fiber.Ctx, records []bulkTransactionRecord) ([]bulkRecordResult, map[string]*models.Transaction, error) {
+	results := make([]bulkRecordResult, len(records))
+	seen := map[string]int{}
+	var ids []string
+	for i := range records {
+		rec := &records[i]
+		rec.ChargeID = strings.TrimSpace(rec.ChargeID)
+		rec.Currency = strings.ToLower(rec.Currency)
+		res := bulkRecordResult{Index: i, ChargeID: rec.ChargeID, Result: bulkRecordCreated}
+
+		if err := validate.Struct(rec); err != nil {
+			var verrs validator.ValidationErrors
+			if errors.As(err, &verrs) {
+				for _, fe := range verrs {
+					res.Errors = append(res.Errors, apperrors.FieldError{Field: fe.Field(), Rule: fe.Tag(), Message: fieldMessage(fe)})
+				}
+			}
+		}
+		if first, dup := seen[rec.ChargeID]; dup && rec.ChargeID != "" {
+			res.Errors = append(res.Errors, apperrors.FieldError{Field: "charge_id", Rule: "unique", Message: fmt.Sprintf("charge_id duplicates record %d", first)})
+		} else {
+			seen[rec.ChargeID] = i
+		}
+		if rec.CreatedAt != nil && rec.CreatedAt.After(time.Now()) {
+			res.Errors = append(res.Errors, apperrors.FieldError{Field: "created_at", Rule: "past", Message: "created_at must not be in the future"})
+		}
+		if rec.CreatedAt != nil && rec.UpdatedAt != nil && rec.UpdatedAt.Before(*rec.CreatedAt) {
+			res.Errors = append(res.Errors, apperrors.FieldError{Field: "updated_at", Rule: "gtefield", Message: "updated_at must not be before created_at"})
+		}
+		if len(res.Errors) > 0 {
+			res.Result = bulkRecordRejected
+		} else {
+			ids = append(ids, rec.ChargeID)
+		}
+		results[i] = res
+	}
+	if len(ids) < len(records) {
+		return results, nil, nil
+	}
+
+	if err := h.checkBulkUsers(records, results); err != nil {
+		return nil, nil, err
+	}
+	found, err := h.Transactions.WithContext(c.UserContext()).FindMany(ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	existing := make(map[string]*models.Transaction, len(found))
+	for i := range found {
+		existing[found[i].ChargeID] = &found[i]
+	}
+	for i := range results {
+		t := existing[results[i].ChargeID]
+		switch {
+		case t == nil:
+		case t.Meta["legacy_source"] == bulkTransactionSource:
+			results[i].Result = bulkRecordUpdated
+		default:
+			results[i].Result = bulkRecordSkipped
+		}
+	}
+	return results, existing, nil
+}
+
+// (helper for checkBulkRecords) reject records whose user does not exist.
+func (h *PaymentHandler) checkBulkUsers(records []bulkTransactionRecord, results []bulkRecordResult) error {
+	users := map[uint]bool{}
+	for i, rec := range records {
+		if rec.UserID == nil {
+			continue
+		}
+		exists, checked := users[*rec.UserID]
+		if !checked {
+			_, err := h.Users.Get(*rec.UserID)
+			if err != nil && !errors.Is(err, repository.ErrNotFound) {
+				return err
+			}
+			exists = err == nil
+			users[*rec.UserID] = exists
+		}
+		if !exists {
+			results[i].Result = bulkRecordRejected
+			results[i].Errors = append(results[i].Errors, apperrors.FieldError{Field: "user_id", Rule: "exists", Message: fmt.Sprintf("user %d does not exist", *rec.UserID)})
+		}
+	}
+	return nil
+}
+
+// (helper for BulkUpsertTransactions) upsert one validated record inside the batch transaction; prev is
+// the transaction an earlier batch imported for its charge id, if any.
+func (h *PaymentHandler) upsertBulkRecord(tx *gorm.DB, batchID string, rec bulkTransactionRecord, prev *models.Transaction) error {
+	currency := rec.Currency
+	if currency == "" {
+		currency = money.THB
+	}
+	channel := rec.Channel
+	if channel == "" {
+		channel = "legacy"
+	}
+	updatedAt := *rec.CreatedAt
+	if rec.UpdatedAt != nil {
+		updatedAt = *rec.UpdatedAt
+	}
+	meta := datatypes.JSONMap{}
+	for k, v := range rec.Meta {
+		meta[k] = v
+	}
+	meta["legacy_ref"], meta["legacy_source"], meta["import"] = rec.ChargeID, bulkTransactionSource, batchID
+
+	t := models.Transaction{
+		CreatedAt:    *rec.CreatedAt,
+		UpdatedAt:    updatedAt,
+		UserID:       rec.UserID,
+		ChargeID:     rec.ChargeID,
+		AmountSatang: rec.Amount,
+		Currency:     currency,
+		Channel:      channel,
+		Status:       rec.Status,
+		Meta:         meta,
+	}
+	if prev != nil {
+		t.CreatedAt = prev.CreatedAt // the partition key of the row to update
+	}
+	t.Description, t.FailureCode, t.FailureMessage = optionalString(rec.Description), optionalString(rec.FailureCode), optionalString(rec.FailureMessage)
+	if err := h.Transactions.WithTx(tx).UpsertByChargeID(&t); err != nil {
+		return err
+	}
+	if prev != nil && prev.Status == t.Status {
+		return nil
+	}
+	event := models.TransactionEvent{
+		CreatedAt: updatedAt, TransactionID: t.ID, ChargeID: t.ChargeID, NewStatus: t.Status, Source: models.TransactionEventImport, FailureCode: t.FailureCode,
+	}
+	if prev != nil {
+		event.OldStatus = prev.Status
+	}
+	return tx.Create(&event).Error
+}
+
+// (helper for upsertBulkRecord) nil for "".
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// (helper for BulkUpsertTransactions) counts per result.
+func summarizeBulkRecords(results []bulkRecordResult) fiber.Map {
+	counts := map[string]int{bulkRecordCreated: 0, bulkRecordUpdated: 0, bulkRecordSkipped: 0, bulkRecordRejected: 0}
+	for _, r := range results {
+		counts[r.Result]++
+	}
+	return fiber.Map{
+		"total":    len(results),
+		"created":  counts[bulkRecordCreated],
+		"updated":  counts[bulkRecordUpdated],
+		"skipped":  counts[bulkRecordSkipped],
+		"rejected": counts[bulkRecordRejected],
+	}
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/repository/repotest"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func TestBulkUpsertTransactionsReportsRecordErrors(t *testing.T) {
+	h := NewPaymentHandler(nil, nil)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/bulk", h.BulkUpsertTransactions)
+
+	body := `{"dry_run":true,"transactions":[
+		{"charge_id":"chrg_1","amount":0,"status":"successful","created_at":"2023-05-01T10:00:00+07:00"},
+		{"charge_id":"chrg_2","amount":1000,"status":"refunded","currency":"baht"},
+		{"charge_id":"chrg_2","amount":1000,"status":"failed","created_at":"2023-05-01T10:00:00+07:00","updated_at":"2023-04-01T10:00:00+07:00"}
+	]}`
+	req := httptest.NewRequest("POST", "/bulk", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	if resp.StatusCode != 422 {
+		t.Fatalf("status %d, want 422", resp.StatusCode)
+	}
+
+	var out struct {
+		Rejected     int                `json:"rejected"`
+		Transactions []bulkRecordResult `json:"transactions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if out.Rejected != 3 || len(out.Transactions) != 3 {
+		t.Fatalf("rejected=%d records=%d, want 3/3", out.Rejected, len(out.Transactions))
+	}
+	want := [][]string{
+		{"amount"},
+		{"status", "currency", "created_at"},
+		{"charge_id", "updated_at"},
+	}
+	for i, fields := range want {
+		got := map[string]bool{}
+		for _, fe := range out.Transactions[i].Errors {
+			got[fe.Field] = true
+		}
+		for _, f := range fields {
+			if !got[f] {
+				t.Errorf("record %d: no error for %s (got %+v)", i, f, out.Transactions[i].Errors)
+			}
+		}
+	}
+}
+
+func TestBulkUpsertTransactionsRejectsEmptyBatch(t *testing.T) {
+	h := NewPaymentHandler(nil, nil)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/bulk", h.BulkUpsertTransactions)
+
+	req := httptest.NewRequest("POST", "/bulk", strings.NewReader(`{"transactions":[]}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	if resp.StatusCode != 400 {
+		t.Errorf("status %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestBulkUpsertTransactionsRerunUpdates(t *testing.T) {
+	h := NewPaymentHandler(repotest.NewDB(), nil)
+	store := repotest.NewTransactions(models.Transaction{ChargeID: "chrg_live_1", Status: "successful", AmountSatang: 9900, Currency: "thb"})
+	h.Users, h.Transactions = repotest.NewUsers(models.User{Model: gorm.Model{ID: 7}}), store
+	t.Cleanup(func() { h.Drain(context.Background()) })
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/bulk", h.BulkUpsertTransactions)
+
+	batch := func(status string) map[string]interface{} {
+		t.Helper()
+		body := `{"transactions":[
+			{"charge_id":"chrg_legacy_1","user_id":7,"amount":5000,"status":"` + status + `","created_at":"2023-05-01T10:00:00+07:00"},
+			{"charge_id":"chrg_live_1","amount":100,"status":"failed","created_at":"2023-05-01T10:00:00+07:00"}
+		]}`
+		req := httptest.NewRequest("POST", "/bulk", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		var out map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if resp.StatusCode != fiber.StatusCreated {
+			t.Fatalf("status %d (%v), want 201", resp.StatusCode, out)
+		}
+		return out
+	}
+
+	if out := batch("pending"); out["created"] != 1.0 || out["skipped"] != 1.0 {
+		t.Fatalf("first batch = %v, want 1 created and 1 skipped", out)
+	}
+	if out := batch("successful"); out["updated"] != 1.0 || out["skipped"] != 1.0 || out["created"] != 0.0 {
+		t.Fatalf("second batch = %v, want 1 updated and 1 skipped", out)
+	}
+
+	rows := store.All()
+	if len(rows) != 2 {
+		t.Fatalf("%d rows, want the live charge and one imported row", len(rows))
+	}
+	if live := rows[0]; live.Status != "successful" || live.AmountSatang != 9900 {
+		t.Errorf("live charge = %s %d, want it untouched", live.Status, live.AmountSatang)
+	}
+	if imported := rows[1]; imported.ChargeID != "chrg_legacy_1" || imported.Status != "successful" || imported.Meta["legacy_source"] != bulkTransactionSource {
+		t.Errorf("imported row = %s %s %v, want chrg_legacy_1 updated to successful", imported.ChargeID, imported.Status, imported.Meta)
+	}
+}
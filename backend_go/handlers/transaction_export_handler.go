@@ -0,0 +1,184 @@
+// transaction_export_handler.go serves GET /admin/transactions/export, the Excel workbook of the
+// transactions matching the listing filters, for accounting.
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/money"
+	"github.com/gofiber/fiber/v2"
+	"github.com/xuri/excelize/v2"
+)
+
+// transactionExportMaxRows bounds one workbook, which is built in memory; larger exports must be
+// narrowed (e.g. by from/to).
+const transactionExportMaxRows = 100000
+
+// Sheet names of the transaction workbook.
+const (
+	transactionsSheet = "Transactions"
+	byChannelSheet    = "By channel"
+)
+
+// channelSummary is one row of the "By channel" sheet.
+type channelSummary struct {
+	Channel, Currency string
+	Count             int64
+	Successful        int64
+	SuccessfulSatang  int64
+}
+
+// ExportTransactions returns the transactions matching the ListTransactions filters (see
+// transactionFilterFromQuery) as an xlsx workbook: a "Transactions" sheet with one row each and a
+// "By channel" sheet totalling them per channel and currency. VAT and the tax invoice number are
+// those of successful charges (see service.RecordCharge). Times are Bangkok time, amounts major
+// units (THB). The export is audited and counted as usage like ExportUserData.
+func (h *PaymentHandler) ExportTransactions(c *fiber.Ctx) error {
+	f, err := transactionFilterFromQuery(c)
+	if err != nil {
+		return err
+	}
+	repo := h.Transactions.WithContext(c.UserContext())
+	total, err := repo.Count(f)
+	if err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to count transactions").Wrap(err)
+	}
+	if total > transactionExportMaxRows {
+		return apperrors.ErrValidation.WithMessagef("%d transactions match; narrow the filters to at most %d (e.g. with from/to)",
+			total, transactionExportMaxRows)
+	}
+	var rows []models.Transaction
+	for offset := 0; ; offset += exportPageSize {
+		page, err := repo.Page(f, exportPageSize, offset)
+		if err != nil {
+			return apperrors.ErrInternal.WithMessage("Failed to retrieve transactions").Wrap(err)
+		}
+		rows = append(rows, page...)
+		if len(page) < exportPageSize || len(rows) >= transactionExportMaxRows {
+			break
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := writeTransactionWorkbook(&buf, rows); err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to build export workbook").Wrap(err)
+	}
+	h.audit(auditEntry(c, models.AuditTransactionExport, "transaction", "", nil,
+		fiber.Map{"format": "xlsx", "query": string(c.Request().URI().QueryString()), "transactions": len(rows)}))
+	h.countUsage(c, models.UsageExports, 1)
+	h.countUsage(c, models.UsageExportRows, int64(len(rows)))
+	h.countUsage(c, models.UsageExportBytes, int64(buf.Len()))
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="tutorium-transactions-%s.xlsx"`,
+		time.Now().In(bangkok).Format("20060102-1504")))
+	c.Type("xlsx")
+	return c.Send(buf.Bytes())
+}
+
+// writeTransactionWorkbook writes rows as the transaction workbook (see ExportTransactions).
+func writeTransactionWorkbook(w io.Writer, rows []models.Transaction) error {
+	wb := excelize.NewFile()
+	defer wb.Close()
+	if err := wb.SetSheetName("Sheet1", transactionsSheet); err != nil {
+		return err
+	}
+	if _, err := wb.NewSheet(byChannelSheet); err != nil {
+		return err
+	}
+	timeFmt, amountFmt := "yyyy-mm-dd hh:mm:ss", "#,##0.00"
+	timeStyle, err := wb.NewStyle(&excelize.Style{CustomNumFmt: &timeFmt})
+	if err != nil {
+		return err
+	}
+	amountStyle, err := wb.NewStyle(&excelize.Style{CustomNumFmt: &amountFmt})
+	if err != nil {
+		return err
+	}
+
+	sheet := [][]interface{}{{"id", "created_at", "charge_id", "merchant_id", "user_id", "status", "channel", "amount", "currency",
+		"failure_code", "description", "vat", "tax_invoice_number"}}
+	summaries := map[[2]string]*channelSummary{}
+	for _, t := range rows {
+		var userID interface{}
+		if t.UserID != nil {
+			userID = *t.UserID
+		}
+		sheet = append(sheet, []interface{}{
+			t.ID,
+			excelize.Cell{StyleID: timeStyle, Value: t.CreatedAt.In(bangkok)},
+			t.ChargeID, t.MerchantID, userID, t.Status, t.Channel,
+			excelize.Cell{StyleID: amountStyle, Value: t.Amount().Major()},
+			t.Currency, deref(t.FailureCode), deref(t.Description),
+			excelize.Cell{StyleID: amountStyle, Value: money.New(t.VATSatang, t.Currency).Major()}, deref(t.TaxInvoiceNumber),
+		})
+
+		key := [2]string{t.Channel, t.Currency}
+		s := summaries[key]
+		if s == nil {
+			s = &channelSummary{Channel: t.Channel, Currency: t.Currency}
+			summaries[key] = s
+		}
+		s.Count++
+		if t.Status == "successful" {
+			s.Successful++
+			s.SuccessfulSatang += t.AmountSatang
+		}
+	}
+	if err := writeSheet(wb, transactionsSheet, sheet); err != nil {
+		return err
+	}
+
+	keys := make([][2]string, 0, len(summaries))
+	for k := range summaries {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	summary := [][]interface{}{{"channel", "currency", "transactions", "successful", "successful_amount"}}
+	for _, k := range keys {
+		s := summaries[k]
+		summary = append(summary, []interface{}{s.Channel, s.Currency, s.Count, s.Successful,
+			excelize.Cell{StyleID: amountStyle, Value: money.New(s.SuccessfulSatang, s.Currency).Major()}})
+	}
+	if err := writeSheet(wb, byChannelSheet, summary); err != nil {
+		return err
+	}
+	return wb.Write(w)
+}
+
+// (helper for writeTransactionWorkbook) streams rows, the first being the header, into sheet.
+func writeSheet(wb *excelize.File, sheet string, rows [][]interface{}) error {
+	sw, err := wb.NewStreamWriter(sheet)
+	if err != nil {
+		return err
+	}
+	for i, row := range rows {
+		cell, err := excelize.CoordinatesToCellName(1, i+1)
+		if err != nil {
+			return err
+		}
+		if err := sw.SetRow(cell, row); err != nil {
+			return err
+		}
+	}
+	return sw.Flush()
+}
+
+// (helper for writeTransactionWorkbook) the string behind s, or "" for nil.
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
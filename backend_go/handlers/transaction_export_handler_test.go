@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestWriteTransactionWorkbook(t *testing.T) {
+	uid := uint(4)
+	at := time.Date(2025, 10, 1, 3, 0, 0, 0, time.UTC)
+	rows := []models.Transaction{
+		{ID: 3, UserID: &uid, ChargeID: "chrg_test_3", CreatedAt: at, AmountSatang: 150000, Currency: "thb", Channel: "promptpay", Status: "successful"},
+		{ID: 2, ChargeID: "chrg_test_2", CreatedAt: at, AmountSatang: 50000, Currency: "thb", Channel: "card", Status: "failed"},
+		{ID: 1, UserID: &uid, ChargeID: "chrg_test_1", CreatedAt: at, AmountSatang: 25050, Currency: "thb", Channel: "promptpay", Status: "successful"},
+	}
+
+	var buf bytes.Buffer
+	if err := writeTransactionWorkbook(&buf, rows); err != nil {
+		t.Fatalf("writeTransactionWorkbook: %v", err)
+	}
+	wb, err := excelize.OpenReader(&buf)
+	if err != nil {
+		t.Fatalf("open workbook: %v", err)
+	}
+	defer wb.Close()
+	if got := wb.GetSheetList(); len(got) != 2 || got[0] != transactionsSheet || got[1] != byChannelSheet {
+		t.Fatalf("sheets = %v, want %s and %s", got, transactionsSheet, byChannelSheet)
+	}
+
+	txns, err := wb.GetRows(transactionsSheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txns) != 4 || txns[1][2] != "chrg_test_3" || txns[1][7] != "1,500.00" || txns[1][1] != "2025-10-01 10:00:00" {
+		t.Errorf("transactions sheet = %v, want a header and 3 rows in Bangkok time", txns)
+	}
+
+	summary, err := wb.GetRows(byChannelSheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"channel", "currency", "transactions", "successful", "successful_amount"},
+		{"card", "thb", "1", "0", "0.00"},
+		{"promptpay", "thb", "2", "2", "1,750.50"},
+	}
+	if len(summary) != len(want) {
+		t.Fatalf("summary sheet = %v, want %v", summary, want)
+	}
+	for i := range want {
+		for j := range want[i] {
+			if summary[i][j] != want[i][j] {
+				t.Errorf("summary[%d][%d] = %q, want %q", i, j, summary[i][j], want[i][j])
+			}
+		}
+	}
+}
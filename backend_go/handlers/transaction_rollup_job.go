@@ -0,0 +1,66 @@
+// transaction_rollup_job.go keeps the daily transaction rollups that GET /payments/stats reads for
+// historical ranges (see repository.TransactionRepository.Stats).
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+)
+
+// rollupBackfillDays bounds how many never-rolled-up days one transaction_rollup run fills in, so a
+// first run over a long history is spread over several runs.
+const rollupBackfillDays = 31
+
+// refreshTransactionRollups is the transaction_rollup job: it re-rolls the lookback days before today
+// (Bangkok), whose transactions may still change status, then rolls up to rollupBackfillDays older
+// days that have no rollup yet, newest first. Today is left to Stats to aggregate live. It returns
+// the number of days rolled up.
+func (h *PaymentHandler) refreshTransactionRollups(ctx context.Context, now time.Time, lookback int) (int, error) {
+	local := now.In(bangkok)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, bangkok)
+	windowStart := today.AddDate(0, 0, -lookback)
+	repo := h.Transactions.WithContext(ctx)
+
+	done := 0
+	var err error
+	for day := windowStart; day.Before(today); day = day.AddDate(0, 0, 1) {
+		if _, rerr := repo.RefreshDailyRollup(day); rerr != nil {
+			err = errors.Join(err, rerr)
+			continue
+		}
+		done++
+	}
+
+	var earliest sql.NullTime
+	if qerr := h.DB.WithContext(ctx).Model(&models.Transaction{}).Select("MIN(created_at)").Row().Scan(&earliest); qerr != nil || !earliest.Valid {
+		return done, errors.Join(err, qerr)
+	}
+	var missing []string
+	if qerr := h.DB.WithContext(ctx).Raw(`
+		SELECT to_char(d, 'YYYY-MM-DD') FROM generate_series(?::date, ?::date - 1, interval '1 day') AS d
+		WHERE to_char(d, 'YYYY-MM-DD') NOT IN (SELECT day FROM transaction_rollup_days)
+		ORDER BY d DESC LIMIT ?`,
+		earliest.Time.In(bangkok).Format("2006-01-02"), windowStart.Format("2006-01-02"), rollupBackfillDays).
+		Scan(&missing).Error; qerr != nil {
+		return done, errors.Join(err, qerr)
+	}
+	for _, d := range missing {
+		if ctx.Err() != nil {
+			break
+		}
+		day, perr := time.ParseInLocation("2006-01-02", d, bangkok)
+		if perr != nil {
+			continue
+		}
+		if _, rerr := repo.RefreshDailyRollup(day); rerr != nil {
+			err = errors.Join(err, rerr)
+			continue
+		}
+		done++
+	}
+	return done, err
+}
@@ -0,0 +1,523 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/cache"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// memTransactions is an in-memory repository.TransactionRepository for handler tests.
+type memTransactions struct {
+	rows       []models.Transaction
+	lastFilter repository.TransactionFilter
+	counts     int // Count calls
+}
+
+func (m *memTransactions) List(f repository.TransactionFilter, limit, offset int) ([]models.Transaction, int64, error) {
+	m.lastFilter = f
+	var out []models.Transaction
+	for _, t := range m.rows {
+		if f.Status == "" || t.Status == f.Status {
+			out = append(out, t)
+		}
+	}
+	total := int64(len(out))
+	if offset > len(out) {
+		offset = len(out)
+	}
+	out = out[offset:]
+	if limit < len(out) {
+		out = out[:limit]
+	}
+	return out, total, nil
+}
+
+func (m *memTransactions) Page(f repository.TransactionFilter, limit, offset int) ([]models.Transaction, error) {
+	out, _, err := m.List(f, limit, offset)
+	return out, err
+}
+
+func (m *memTransactions) PageAfter(f repository.TransactionFilter, after *repository.TransactionCursor, limit int) ([]models.Transaction, error) {
+	m.lastFilter = f
+	var out []models.Transaction
+	for _, t := range m.rows {
+		if after != nil && !t.CreatedAt.Before(after.CreatedAt) && (!t.CreatedAt.Equal(after.CreatedAt) || t.ID >= after.ID) {
+			continue
+		}
+		if (f.Status == "" || t.Status == f.Status) && len(out) < limit {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+func (m *memTransactions) Count(f repository.TransactionFilter) (int64, error) {
+	m.counts++
+	_, total, err := m.List(f, 0, 0)
+	return total, err
+}
+
+func (m *memTransactions) Find(id string) (*models.Transaction, error) {
+	for i := range m.rows {
+		if m.rows[i].ChargeID == id {
+			return &m.rows[i], nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (m *memTransactions) FindMany(ids []string) ([]models.Transaction, error) {
+	var out []models.Transaction
+	for _, t := range m.rows {
+		if slices.Contains(ids, t.ChargeID) || slices.Contains(ids, strconv.FormatUint(uint64(t.ID), 10)) {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+func (m *memTransactions) Get(id uint) (*models.Transaction, error) {
+	for i := range m.rows {
+		if m.rows[i].ID == id {
+			return &m.rows[i], nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (m *memTransactions) LockByChargeID(chargeID string) (*models.Transaction, error) {
+	return m.Find(chargeID)
+}
+
+func (m *memTransactions) UpsertByChargeID(t *models.Transaction) error {
+	m.rows = append(m.rows, *t)
+	return nil
+}
+
+func (m *memTransactions) UpdateStatus(t *models.Transaction) error {
+	for i := range m.rows {
+		if m.rows[i].ID == t.ID {
+			m.rows[i].Status, m.rows[i].FailureCode, m.rows[i].FailureMessage = t.Status, t.FailureCode, t.FailureMessage
+		}
+	}
+	return nil
+}
+
+func (m *memTransactions) RecordEvent(*models.TransactionEvent) error { return nil }
+
+func (m *memTransactions) LatestEventSource(uint) (string, error) { return "", repository.ErrNotFound }
+
+func (m *memTransactions) Stats(f repository.TransactionFilter, interval, groupBy string) ([]repository.TransactionStats, error) {
+	m.lastFilter = f
+	return []repository.TransactionStats{}, nil
+}
+
+func (m *memTransactions) RefreshDailyRollup(time.Time) (int, error) { return 0, nil }
+
+func (m *memTransactions) CountAutoReloadsSince(uint, time.Time) (int64, error) { return 0, nil }
+
+func (m *memTransactions) LatestChargeLike(uint, int64, string, time.Time) (*models.Transaction, error) {
+	return nil, repository.ErrNotFound
+}
+
+func (m *memTransactions) ChargeVelocity(uint, time.Time, time.Time) ([]repository.ChargeVolume, error) {
+	return nil, nil
+}
+
+func (m *memTransactions) WithTx(*gorm.DB) repository.TransactionRepository             { return m }
+func (m *memTransactions) WithContext(context.Context) repository.TransactionRepository { return m }
+
+func TestTransactionHandlersUseRepository(t *testing.T) {
+	repo := &memTransactions{rows: []models.Transaction{
+		{ID: 1, ChargeID: "chrg_1", Status: "successful", AmountSatang: 10000, Currency: "thb"},
+		{ID: 2, ChargeID: "chrg_2", Status: "pending", AmountSatang: 5000, Currency: "thb"},
+	}}
+	h := NewPaymentHandler(nil, nil)
+	h.Transactions = repo
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/transactions", h.ListTransactions)
+	app.Get("/transactions/:id", h.GetTransaction)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/transactions?status=successful&channel=card", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list struct {
+		Transactions []models.Transaction  `json:"transactions"`
+		Pagination   struct{ Total int64 } `json:"pagination"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&list)
+	if len(list.Transactions) != 1 || list.Pagination.Total != 1 || list.Transactions[0].ChargeID != "chrg_1" {
+		t.Errorf("list = %+v", list)
+	}
+	if repo.lastFilter.Channel != "card" {
+		t.Errorf("filter = %+v, want channel card", repo.lastFilter)
+	}
+
+	resp, _ = app.Test(httptest.NewRequest("GET", "/transactions/chrg_2", nil))
+	if resp.StatusCode != 200 {
+		t.Errorf("GET chrg_2: status %d, want 200", resp.StatusCode)
+	}
+	resp, _ = app.Test(httptest.NewRequest("GET", "/transactions/chrg_missing", nil))
+	if resp.StatusCode != 404 {
+		t.Errorf("GET chrg_missing: status %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestListTransactionsDateRange(t *testing.T) {
+	repo := &memTransactions{}
+	h := NewPaymentHandler(nil, nil)
+	h.Transactions = repo
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/transactions", h.ListTransactions)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/transactions?status=successful&from=2025-10-01&to=2025-10-31", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("status %d, want 200", resp.StatusCode)
+	}
+	wantFrom := time.Date(2025, 10, 1, 0, 0, 0, 0, bangkok)
+	wantTo := time.Date(2025, 11, 1, 0, 0, 0, 0, bangkok)
+	if f := repo.lastFilter; !f.From.Equal(wantFrom) || !f.To.Equal(wantTo) || f.Status != "successful" {
+		t.Errorf("filter = %+v, want October in Bangkok time with status successful", f)
+	}
+
+	resp, _ = app.Test(httptest.NewRequest("GET", "/transactions?from=2025-10-01T12:00:00Z", nil))
+	if f := repo.lastFilter; resp.StatusCode != 200 || !f.From.Equal(time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)) || !f.To.IsZero() {
+		t.Errorf("RFC3339 from: status %d, filter %+v", resp.StatusCode, f)
+	}
+
+	for _, q := range []string{"from=yesterday", "to=2025-13-01", "from=2025-10-02&to=2025-10-01"} {
+		resp, _ := app.Test(httptest.NewRequest("GET", "/transactions?"+q, nil))
+		if resp.StatusCode != 400 {
+			t.Errorf("%s: status %d, want 400", q, resp.StatusCode)
+		}
+	}
+}
+
+func TestListTransactionsCursor(t *testing.T) {
+	// rows are newest first, like the repository's order; 3 and 2 share a created_at.
+	base := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	repo := &memTransactions{rows: []models.Transaction{
+		{ID: 4, ChargeID: "chrg_4", CreatedAt: base.Add(2 * time.Minute)},
+		{ID: 3, ChargeID: "chrg_3", CreatedAt: base.Add(time.Minute)},
+		{ID: 2, ChargeID: "chrg_2", CreatedAt: base.Add(time.Minute)},
+		{ID: 1, ChargeID: "chrg_1", CreatedAt: base},
+	}}
+	h := NewPaymentHandler(nil, nil)
+	h.Transactions = repo
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/transactions", h.ListTransactions)
+
+	type page struct {
+		Transactions []models.Transaction `json:"transactions"`
+		Pagination   struct {
+			Total      int64  `json:"total"`
+			NextCursor string `json:"next_cursor"`
+		} `json:"pagination"`
+	}
+	get := func(path string) page {
+		t.Helper()
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != 200 {
+			t.Fatalf("GET %s: status %d", path, resp.StatusCode)
+		}
+		var p page
+		_ = json.NewDecoder(resp.Body).Decode(&p)
+		return p
+	}
+
+	var got []string
+	p := get("/transactions?limit=2")
+	for {
+		for _, tx := range p.Transactions {
+			got = append(got, tx.ChargeID)
+		}
+		if p.Pagination.Total != 4 {
+			t.Errorf("total = %d, want 4", p.Pagination.Total)
+		}
+		if p.Pagination.NextCursor == "" {
+			break
+		}
+		p = get("/transactions?limit=2&cursor=" + p.Pagination.NextCursor)
+	}
+	if want := "chrg_4,chrg_3,chrg_2,chrg_1"; strings.Join(got, ",") != want {
+		t.Errorf("pages = %v, want %s", got, want)
+	}
+
+	for _, q := range []string{"cursor=not-a-cursor", "cursor=" + repository.CursorAfter(repo.rows[0]).String() + "&offset=2"} {
+		resp, _ := app.Test(httptest.NewRequest("GET", "/transactions?"+q, nil))
+		if resp.StatusCode != 400 {
+			t.Errorf("%s: status %d, want 400", q, resp.StatusCode)
+		}
+	}
+}
+
+func TestListTransactionsClampsLimit(t *testing.T) {
+	repo := &memTransactions{}
+	for i := 1; i <= 5; i++ {
+		repo.rows = append(repo.rows, models.Transaction{ID: uint(i), ChargeID: "chrg_" + strconv.Itoa(i)})
+	}
+	h := NewPaymentHandler(nil, nil)
+	h.Transactions = repo
+	h.Payments.PageSize = repository.PageSize{Default: 2, Max: 3}
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/transactions", h.ListTransactions)
+
+	for query, want := range map[string]int{"": 2, "?limit=1": 1, "?limit=1000000": 3, "?limit=-5": 2, "?limit=abc": 2} {
+		resp, err := app.Test(httptest.NewRequest("GET", "/transactions"+query, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != 200 {
+			t.Fatalf("%q: status %d", query, resp.StatusCode)
+		}
+		var page struct {
+			Transactions []models.Transaction `json:"transactions"`
+			Pagination   struct {
+				Limit int `json:"limit"`
+			} `json:"pagination"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&page)
+		if page.Pagination.Limit != want || len(page.Transactions) != want {
+			t.Errorf("%q: limit %d with %d rows, want %d", query, page.Pagination.Limit, len(page.Transactions), want)
+		}
+	}
+}
+
+func TestListTransactionsMultiValueFilters(t *testing.T) {
+	repo := &memTransactions{}
+	h := NewPaymentHandler(nil, nil)
+	h.Transactions = repo
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/transactions", h.ListTransactions)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/transactions?status=successful,failed&channel!=card&has_user=false", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := repo.lastFilter
+	if resp.StatusCode != 200 || f.Status != "successful,failed" || f.NotChannel != "card" || f.HasUser == nil || *f.HasUser {
+		t.Errorf("status %d, filter %+v; want successful,failed without card and without a user", resp.StatusCode, f)
+	}
+	resp, _ = app.Test(httptest.NewRequest("GET", "/transactions?q=+student%40example.com+", nil))
+	if resp.StatusCode != 200 || repo.lastFilter.Search != "student@example.com" {
+		t.Errorf("q: status %d, search %q; want student@example.com", resp.StatusCode, repo.lastFilter.Search)
+	}
+	resp, _ = app.Test(httptest.NewRequest("GET", "/transactions?has_user=maybe", nil))
+	if resp.StatusCode != 400 {
+		t.Errorf("has_user=maybe: status %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestListTransactionsAmountRange(t *testing.T) {
+	repo := &memTransactions{}
+	h := NewPaymentHandler(nil, nil)
+	h.Transactions = repo
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/transactions", h.ListTransactions)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/transactions?min_amount=0&max_amount=100000", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := repo.lastFilter; resp.StatusCode != 200 || f.MinAmount == nil || *f.MinAmount != 0 || f.MaxAmount == nil || *f.MaxAmount != 100000 {
+		t.Errorf("status %d, filter %+v; want 0 to 100000 satang", resp.StatusCode, f)
+	}
+	for _, q := range []string{"min_amount=-1", "max_amount=10.5", "min_amount=500&max_amount=100"} {
+		resp, _ := app.Test(httptest.NewRequest("GET", "/transactions?"+q, nil))
+		if resp.StatusCode != 400 {
+			t.Errorf("%s: status %d, want 400", q, resp.StatusCode)
+		}
+	}
+}
+
+func TestListTransactionsSort(t *testing.T) {
+	repo := &memTransactions{}
+	h := NewPaymentHandler(nil, nil)
+	h.Transactions = repo
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/transactions", h.ListTransactions)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/transactions?sort=amount_satang&order=desc", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (repository.TransactionOrder{Column: "amount_satang"}); resp.StatusCode != 200 || repo.lastFilter.Order != want {
+		t.Errorf("status %d, order %+v; want 200 with %+v", resp.StatusCode, repo.lastFilter.Order, want)
+	}
+	resp, _ = app.Test(httptest.NewRequest("GET", "/transactions?order=asc", nil))
+	if want := (repository.TransactionOrder{Asc: true}); resp.StatusCode != 200 || repo.lastFilter.Order != want {
+		t.Errorf("order=asc: status %d, order %+v", resp.StatusCode, repo.lastFilter.Order)
+	}
+
+	cursor := repository.CursorAfter(models.Transaction{ID: 1, CreatedAt: time.Now()}).String()
+	for _, q := range []string{"sort=user_id", "sort=amount_satang;drop", "order=up", "sort=status&cursor=" + cursor} {
+		resp, _ := app.Test(httptest.NewRequest("GET", "/transactions?"+q, nil))
+		if resp.StatusCode != 400 {
+			t.Errorf("%s: status %d, want 400", q, resp.StatusCode)
+		}
+	}
+}
+
+func TestTransactionReadsUseCache(t *testing.T) {
+	repo := &memTransactions{rows: []models.Transaction{
+		{ID: 1, ChargeID: "chrg_1", Status: "pending", AmountSatang: 10000, Currency: "thb"},
+	}}
+	h := NewPaymentHandler(nil, nil)
+	h.Transactions = repo
+	h.Payments.TransactionCache = &repository.TransactionCache{Store: cache.NewMemory(), TTL: time.Minute}
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/transactions", h.ListTransactions)
+	app.Get("/transactions/:id", h.GetTransaction)
+
+	status := func(path string) string {
+		t.Helper()
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var tx models.Transaction
+		_ = json.NewDecoder(resp.Body).Decode(&tx)
+		return tx.Status
+	}
+	if got := status("/transactions/chrg_1"); got != "pending" {
+		t.Fatalf("first read: status %q, want pending", got)
+	}
+	repo.rows[0].Status = "successful"
+	if got := status("/transactions/chrg_1"); got != "pending" {
+		t.Errorf("cached read: status %q, want the cached pending", got)
+	}
+	if got := status("/transactions/chrg_1?no_cache=true"); got != "successful" {
+		t.Errorf("no_cache read: status %q, want successful", got)
+	}
+	h.Payments.TransactionCache.Invalidate(context.Background(), repo.rows[0])
+	if got := status("/transactions/chrg_1"); got != "successful" {
+		t.Errorf("read after Invalidate: status %q, want successful", got)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := app.Test(httptest.NewRequest("GET", "/transactions?status=successful", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if repo.counts != 1 {
+		t.Errorf("two list reads counted %d times, want 1 (total cached)", repo.counts)
+	}
+	h.Payments.TransactionCache.InvalidateTotals(context.Background())
+	_, _ = app.Test(httptest.NewRequest("GET", "/transactions?status=successful", nil))
+	if repo.counts != 2 {
+		t.Errorf("after InvalidateTotals: counted %d times, want 2", repo.counts)
+	}
+}
+
+func TestGetPaymentStats(t *testing.T) {
+	repo := &memTransactions{}
+	h := NewPaymentHandler(nil, nil)
+	h.Transactions = repo
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/stats", h.GetPaymentStats)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/stats?from=2026-10-01&to=2026-10-31&interval=month&group_by=channel&merchant_id=3", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := repo.lastFilter; resp.StatusCode != 200 || f.MerchantID != "3" || f.From.IsZero() || f.To.IsZero() {
+		t.Errorf("status %d, filter %+v; want 200 for merchant 3 in October", resp.StatusCode, f)
+	}
+	for _, q := range []string{"", "from=2026-10-01", "from=2026-10-01&to=2026-10-31&interval=year", "from=2026-10-01&to=2026-10-31&group_by=user_id"} {
+		resp, _ := app.Test(httptest.NewRequest("GET", "/stats?"+q, nil))
+		if resp.StatusCode != 400 {
+			t.Errorf("%q: status %d, want 400", q, resp.StatusCode)
+		}
+	}
+}
+
+func TestGetTransactionHistoryUnknown(t *testing.T) {
+	h := NewPaymentHandler(nil, nil)
+	h.Transactions = &memTransactions{}
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/transactions/:id/history", h.GetTransactionHistory)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/transactions/chrg_missing/history", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 404 {
+		t.Errorf("status %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestSyncTransactionRejectsLegacyImports(t *testing.T) {
+	h := NewPaymentHandler(nil, nil)
+	h.Transactions = &memTransactions{rows: []models.Transaction{{ID: 1, ChargeID: legacyChargeID("A-1"), Status: "successful"}}}
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/transactions/:id/sync", h.SyncTransaction)
+
+	for id, want := range map[string]int{legacyChargeID("A-1"): 409, "chrg_missing": 404} {
+		resp, err := app.Test(httptest.NewRequest("POST", "/transactions/"+id+"/sync", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != want {
+			t.Errorf("%s: status %d, want %d", id, resp.StatusCode, want)
+		}
+	}
+}
+
+func TestBatchTransactionStatus(t *testing.T) {
+	h := NewPaymentHandler(nil, nil)
+	h.Transactions = &memTransactions{rows: []models.Transaction{
+		{ID: 7, ChargeID: "chrg_a", Status: "successful"},
+		{ID: 8, ChargeID: "chrg_b", Status: "failed"},
+	}}
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/batch", h.BatchTransactionStatus)
+
+	req := httptest.NewRequest("POST", "/batch", strings.NewReader(`{"ids": ["chrg_b", "7", "chrg_missing"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body struct {
+		Results []transactionStatus `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, len(body.Results))
+	for i, r := range body.Results {
+		got[i] = r.ID + ":" + r.Status + ":" + strconv.FormatBool(r.Found)
+	}
+	if want := []string{"chrg_b:failed:true", "7:successful:true", "chrg_missing::false"}; !slices.Equal(got, want) {
+		t.Errorf("results %v, want %v", got, want)
+	}
+
+	ids := make([]string, 101)
+	for i := range ids {
+		ids[i] = strconv.Itoa(i)
+	}
+	raw, _ := json.Marshal(map[string][]string{"ids": ids})
+	req = httptest.NewRequest("POST", "/batch", strings.NewReader(string(raw)))
+	req.Header.Set("Content-Type", "application/json")
+	if resp, _ := app.Test(req); resp.StatusCode != 400 {
+		t.Errorf("101 ids: status %d, want 400", resp.StatusCode)
+	}
+}
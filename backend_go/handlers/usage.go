@@ -0,0 +1,180 @@
+// usage.go attributes API requests to the internal service that made them (its X-API-Key) and counts
+// per-consumer usage (API calls, charges created, export volume) for the monthly cost allocation
+// report at /admin/usage and the monthly_service_usage report subscription.
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm/clause"
+)
+
+// APIConsumer is an internal service (a university department's system) calling this API with its key.
+type APIConsumer struct {
+	Name string
+	Key  string
+}
+
+// usageKey identifies one counter: a metric of a consumer on a Bangkok day.
+type usageKey struct {
+	day, consumer, metric string
+}
+
+// usageMeter buffers usage counts between flushes; the zero value is ready to use.
+type usageMeter struct {
+	mu     sync.Mutex
+	counts map[usageKey]int64
+}
+
+func (m *usageMeter) add(k usageKey, n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.counts == nil {
+		m.counts = map[usageKey]int64{}
+	}
+	m.counts[k] += n
+}
+
+// take returns the buffered counts and starts a new buffer.
+func (m *usageMeter) take() map[usageKey]int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := m.counts
+	m.counts = nil
+	return out
+}
+
+// IdentifyConsumer tags the request with the API consumer whose key it carries in X-API-Key and
+// counts it as an API call. A request without a key is counted as "unattributed"; an unknown key is
+// rejected with 401 so a misconfigured service notices instead of being billed to nobody.
+func (h *PaymentHandler) IdentifyConsumer(c *fiber.Ctx) error {
+	consumer := models.UsageConsumerUnattributed
+	if key := c.Get("X-API-Key"); key != "" {
+		name, ok := h.apiConsumer(key)
+		if !ok {
+			return apperrors.ErrUnauthorized.WithMessage("unknown API key")
+		}
+		consumer = name
+	}
+	c.Locals("api_consumer", consumer)
+	err := c.Next()
+	h.countUsage(c, models.UsageAPICalls, 1)
+	return err
+}
+
+// (helper for IdentifyConsumer) the consumer owning key, comparing every key in constant time.
+func (h *PaymentHandler) apiConsumer(key string) (string, bool) {
+	found := ""
+	for _, ac := range h.APIConsumers {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(ac.Key)) == 1 {
+			found = ac.Name
+		}
+	}
+	return found, found != ""
+}
+
+// countUsage adds n to the request consumer's metric for today.
+func (h *PaymentHandler) countUsage(c *fiber.Ctx, metric string, n int64) {
+	consumer, ok := c.Locals("api_consumer").(string)
+	if !ok {
+		consumer = models.UsageConsumerUnattributed
+	}
+	h.usage.add(usageKey{day: time.Now().In(bangkok).Format("2006-01-02"), consumer: consumer, metric: metric}, n)
+}
+
+// FlushUsage adds the buffered counts to the usage_counters table. Counts that fail to save are kept
+// for the next flush. It runs every minute as the flush_usage job and once more on shutdown.
+func (h *PaymentHandler) FlushUsage(ctx context.Context) error {
+	if h.DB == nil {
+		return nil
+	}
+	counts := h.usage.take()
+	if len(counts) == 0 {
+		return nil
+	}
+	rows := make([]models.UsageCounter, 0, len(counts))
+	for k, n := range counts {
+		rows = append(rows, models.UsageCounter{Day: k.day, Consumer: k.consumer, Metric: k.metric, Count: n})
+	}
+	err := h.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "day"}, {Name: "consumer"}, {Name: "metric"}},
+		DoUpdates: clause.Set{
+			{Column: clause.Column{Name: "count"}, Value: clause.Expr{SQL: "usage_counters.count + excluded.count"}},
+			{Column: clause.Column{Name: "updated_at"}, Value: clause.Expr{SQL: "excluded.updated_at"}},
+		},
+	}).Create(&rows).Error
+	if err != nil {
+		for k, n := range counts {
+			h.usage.add(k, n)
+		}
+		return err
+	}
+	return nil
+}
+
+// consumerUsage is one consumer's totals in a usage report.
+type consumerUsage struct {
+	Consumer string           `json:"consumer"`
+	Metrics  map[string]int64 `json:"metrics"`
+}
+
+// (helper for GetUsageReport and buildReport) per-consumer totals over the Bangkok days [from, to).
+func (h *PaymentHandler) usageTotals(from, to time.Time) ([]consumerUsage, error) {
+	var rows []struct {
+		Consumer string
+		Metric   string
+		Total    int64
+	}
+	if err := dbutil.Replica(h.DB).Model(&models.UsageCounter{}).
+		Select("consumer, metric, SUM(count) AS total").
+		Where("day >= ? AND day < ?", from.In(bangkok).Format("2006-01-02"), to.In(bangkok).Format("2006-01-02")).
+		Group("consumer, metric").Order("consumer, metric").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := []consumerUsage{}
+	for _, r := range rows {
+		if len(out) == 0 || out[len(out)-1].Consumer != r.Consumer {
+			out = append(out, consumerUsage{Consumer: r.Consumer, Metrics: map[string]int64{}})
+		}
+		out[len(out)-1].Metrics[r.Metric] = r.Total
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Metrics[models.UsageAPICalls] > out[j].Metrics[models.UsageAPICalls]
+	})
+	return out, nil
+}
+
+// GetUsageReport returns each API consumer's usage for a calendar month (Bangkok), for cost allocation.
+//
+//	GET /api/v1/admin/usage?month=2026-09   (default: the current month so far)
+func (h *PaymentHandler) GetUsageReport(c *fiber.Ctx) error {
+	now := time.Now().In(bangkok)
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, bangkok)
+	if m := c.Query("month"); m != "" {
+		parsed, err := time.ParseInLocation("2006-01", m, bangkok)
+		if err != nil {
+			return apperrors.ErrValidation.WithMessage("month must be YYYY-MM")
+		}
+		from = parsed
+	}
+	to := from.AddDate(0, 1, 0)
+
+	// Include this replica's unflushed counts; other replicas' arrive within a minute.
+	if err := h.FlushUsage(c.UserContext()); err != nil {
+		log.Printf("usage: flush failed err=%v", err)
+	}
+	consumers, err := h.usageTotals(from, to)
+	if err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to build usage report").Wrap(err)
+	}
+	return c.JSON(fiber.Map{"month": from.Format("2006-01"), "from": from, "to": to, "consumers": consumers})
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestIdentifyConsumerCountsCalls(t *testing.T) {
+	h := NewPaymentHandler(nil, nil)
+	h.APIConsumers = []APIConsumer{{Name: "booking", Key: "bk-key"}, {Name: "library", Key: "lib-key"}}
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	api := app.Group("/api/v1", h.IdentifyConsumer)
+	api.Get("/ping", func(c *fiber.Ctx) error { return c.SendString("pong") })
+	api.Get("/fail", func(c *fiber.Ctx) error { return fiber.ErrTeapot })
+
+	for _, tc := range []struct {
+		path, key string
+		want      int
+	}{
+		{"/api/v1/ping", "bk-key", 200},
+		{"/api/v1/ping", "bk-key", 200},
+		{"/api/v1/fail", "bk-key", 418}, // failed calls are still calls
+		{"/api/v1/ping", "lib-key", 200},
+		{"/api/v1/ping", "", 200},
+		{"/api/v1/ping", "stolen", 401},
+	} {
+		req := httptest.NewRequest("GET", tc.path, nil)
+		if tc.key != "" {
+			req.Header.Set("X-API-Key", tc.key)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		if resp.StatusCode != tc.want {
+			t.Errorf("GET %s with key %q: status %d, want %d", tc.path, tc.key, resp.StatusCode, tc.want)
+		}
+	}
+
+	calls := map[string]int64{}
+	for k, n := range h.usage.take() {
+		if k.metric == models.UsageAPICalls {
+			calls[k.consumer] += n
+		}
+	}
+	want := map[string]int64{"booking": 3, "library": 1, models.UsageConsumerUnattributed: 1}
+	for consumer, n := range want {
+		if calls[consumer] != n {
+			t.Errorf("api_calls[%s] = %d, want %d", consumer, calls[consumer], n)
+		}
+	}
+	if len(calls) != len(want) {
+		t.Errorf("api_calls = %v, want only %v", calls, want)
+	}
+	if len(h.usage.take()) != 0 {
+		t.Errorf("take did not reset the buffer")
+	}
+}
+
+func TestUsageReportMessage(t *testing.T) {
+	r := &Report{Type: models.ReportMonthlyServiceUsage, Rows: []ReportRow{
+		{Key: "booking api_calls", Count: 1200},
+		{Key: "booking charges_created", Count: 3, Currency: "thb", AmountSatang: 150000},
+	}}
+	body := r.Message().Body
+	if !strings.Contains(body, "booking api_calls") || !strings.Contains(body, "1,500.00 THB") {
+		t.Errorf("message body = %q, want the call count and the charge amount", body)
+	}
+}
@@ -0,0 +1,124 @@
+// user_erasure_handler.go serves DELETE /users/:id/personal-data, the PDPA right to erasure: the
+// user's personal data is anonymized in place, while the financial records the law requires us to keep
+// (balances, transactions, receipts, the ledger in the audit log) stay attached to the user id.
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// erasedName replaces the names of an erased user.
+const erasedName = "Erased"
+
+// errAlreadyErased aborts an erasure of a user erased before.
+var errAlreadyErased = errors.New("personal data already erased")
+
+type erasePersonalDataRequest struct {
+	Reason string `json:"reason" validate:"required,max=255"` // e.g. the data subject request reference
+}
+
+// ErasePersonalData anonymizes the user: names, student id, phone, gender, picture and LINE account
+// on the user; the LINE ids of past notifications; the app's registered devices; the payer IP addresses
+// of their transactions; the saved card and notify email of auto-reload, which is disabled; and the
+// profiles in user.create and user.update audit entries. The erasure itself is audited with the reason.
+// Admin only; a user already erased gets 409.
+//
+//	DELETE /api/v1/users/<id>/personal-data {"reason": "PDPA request #2026-0142"}
+func (h *PaymentHandler) ErasePersonalData(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return apperrors.ErrValidation.WithMessage("id must be a user id")
+	}
+	var req erasePersonalDataRequest
+	if err := parseAndValidate(c, &req); err != nil {
+		return err
+	}
+	userID := uint(id)
+	entityID := fmt.Sprintf("%d", userID)
+	now := time.Now()
+	erased := fiber.Map{}
+	err = dbutil.Transaction(h.db(c), "erase_personal_data", func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&user, userID).Error; err != nil {
+			return err
+		}
+		if user.PersonalDataErasedAt != nil {
+			return errAlreadyErased
+		}
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"student_id":              erasedStudentID(userID),
+			"first_name":              erasedName,
+			"last_name":               erasedName,
+			"gender":                  "",
+			"phone_number":            "",
+			"profile_picture":         nil,
+			"line_user_id":            nil,
+			"personal_data_erased_at": now,
+		}).Error; err != nil {
+			return err
+		}
+
+		res := tx.Model(&models.LineNotification{}).Where("user_id = ?", userID).Update("line_user_id", "")
+		if res.Error != nil {
+			return res.Error
+		}
+		erased["line_notifications"] = res.RowsAffected
+
+		res = tx.Where("user_id = ?", userID).Delete(&models.DeviceToken{})
+		if res.Error != nil {
+			return res.Error
+		}
+		erased["device_tokens"] = res.RowsAffected
+
+		res = tx.Model(&models.Transaction{}).Where("user_id = ? AND client_ip <> ''", userID).Update("client_ip", "")
+		if res.Error != nil {
+			return res.Error
+		}
+		erased["transaction_ips"] = res.RowsAffected
+
+		res = tx.Model(&models.AutoReload{}).Where("user_id = ?", userID).Updates(map[string]interface{}{
+			"enabled": false, "disabled_reason": "personal data erased", "notify_email": "",
+			"omise_customer_id": "", "omise_card_id": "", "card_brand": "", "card_last_digits": "",
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		erased["auto_reload"] = res.RowsAffected
+
+		res = tx.Model(&models.AuditLog{}).
+			Where("entity_type = ? AND entity_id = ? AND action IN ?", "user", entityID, []string{models.AuditUserCreate, models.AuditUserUpdate}).
+			Updates(map[string]interface{}{"before": nil, "after": auditJSON(fiber.Map{"erased": true})})
+		if res.Error != nil {
+			return res.Error
+		}
+		erased["audit_entries"] = res.RowsAffected
+
+		entry := auditEntry(c, models.AuditUserErase, "user", entityID, nil, erased)
+		entry.Reason = req.Reason
+		return writeAudit(tx, entry)
+	})
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return apperrors.ErrNotFound.WithMessage("User not found")
+	case errors.Is(err, errAlreadyErased):
+		return apperrors.ErrConflict.WithCode("personal_data_erased").WithMessage("the user's personal data has already been erased")
+	case err != nil:
+		return apperrors.ErrInternal.WithMessage("Failed to erase personal data").Wrap(err)
+	}
+	return c.JSON(fiber.Map{"user_id": userID, "personal_data_erased_at": now, "erased": erased})
+}
+
+// (helper for ErasePersonalData) a student id no real one can take: those are all digits.
+func erasedStudentID(userID uint) string {
+	return fmt.Sprintf("X%09d", userID)
+}
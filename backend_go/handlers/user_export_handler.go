@@ -0,0 +1,243 @@
+// user_export_handler.go serves GET /users/:id/export, the PDPA data-portability archive of everything
+// the payment backend holds about one user.
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/datatypes"
+)
+
+// exportPageSize is how many transactions are read per query while building an export.
+const exportPageSize = 500
+
+// userExport is the archive's export.json; the CSV files flatten the same data.
+type userExport struct {
+	ExportedAt   time.Time            `json:"exported_at"`
+	User         models.UserDoc       `json:"user"`
+	Transactions []models.Transaction `json:"transactions"`
+	Ledger       []exportLedgerEntry  `json:"ledger"`
+	Receipts     []models.TaxDocument `json:"receipts"`
+	SavedCards   []exportSavedCard    `json:"saved_cards"`
+}
+
+// exportLedgerEntry is one balance movement from the audit log. Actor is reduced to its kind
+// ("admin", "user", "system") so staff names and IPs are not disclosed.
+type exportLedgerEntry struct {
+	At     time.Time      `json:"at"`
+	Action string         `json:"action"`
+	Actor  string         `json:"actor"`
+	Reason string         `json:"reason,omitempty"`
+	Before datatypes.JSON `json:"before,omitempty"`
+	After  datatypes.JSON `json:"after,omitempty"`
+}
+
+// exportSavedCard is the card metadata kept for auto-reload; Omise ids are internal and left out.
+type exportSavedCard struct {
+	Brand      string    `json:"brand"`
+	LastDigits string    `json:"last_digits"`
+	SavedAt    time.Time `json:"saved_at"`
+	Enabled    bool      `json:"auto_reload_enabled"`
+}
+
+// ExportUserData returns the user's profile, transactions, ledger entries, receipts and saved-card
+// metadata as a zip archive (export.json plus one CSV per section), or as plain JSON with ?format=json.
+// Transactions are masked as the API serves them: no raw payloads, cards by brand and last digits only.
+// Also served at GET /users/:id/data-export.
+// Allowed for the user themselves (X-User-ID) or an admin (X-Admin-Token); every export is audited.
+func (h *PaymentHandler) ExportUserData(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return apperrors.ErrValidation.WithMessage("id must be a user id")
+	}
+	userID := uint(id)
+	if !h.adminTokenValid(c) {
+		// Do not reveal whether other users exist.
+		if self := userIDFromHeaderOrQuery(c); self == nil || *self != userID {
+			return apperrors.ErrNotFound.WithMessage("User not found")
+		}
+	}
+	format := c.Query("format", "zip")
+	if format != "zip" && format != "json" {
+		return apperrors.ErrValidation.WithMessage(`format must be "zip" or "json"`)
+	}
+
+	exp, err := h.collectUserExport(userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperrors.ErrNotFound.WithMessage("User not found")
+		}
+		return apperrors.ErrInternal.WithMessage("Failed to export user data").Wrap(err)
+	}
+	h.audit(auditEntry(c, models.AuditUserDataExport, "user", fmt.Sprintf("%d", userID), nil,
+		fiber.Map{"format": format, "transactions": len(exp.Transactions), "ledger": len(exp.Ledger), "receipts": len(exp.Receipts)}))
+
+	h.countUsage(c, models.UsageExports, 1)
+	h.countUsage(c, models.UsageExportRows, int64(len(exp.Transactions)+len(exp.Ledger)+len(exp.Receipts)))
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	if format == "json" {
+		if err := c.JSON(exp); err != nil {
+			return err
+		}
+		h.countUsage(c, models.UsageExportBytes, int64(len(c.Response().Body())))
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := writeUserExportArchive(&buf, exp); err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to build export archive").Wrap(err)
+	}
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="tutorium-user-%d-%s.zip"`,
+		userID, exp.ExportedAt.In(bangkok).Format("20060102")))
+	c.Type("zip")
+	h.countUsage(c, models.UsageExportBytes, int64(buf.Len()))
+	return c.Send(buf.Bytes())
+}
+
+// (helper for ExportUserData) load every section of the export.
+func (h *PaymentHandler) collectUserExport(userID uint) (userExport, error) {
+	var user models.User // every column: Users.Get reads the balances only
+	if err := h.DB.Take(&user, userID).Error; err != nil {
+		return userExport{}, err
+	}
+	exp := userExport{
+		ExportedAt:   time.Now().UTC(),
+		User:         userProfile(&user),
+		Transactions: []models.Transaction{},
+		Ledger:       []exportLedgerEntry{},
+		Receipts:     []models.TaxDocument{},
+		SavedCards:   []exportSavedCard{},
+	}
+
+	f := repository.TransactionFilter{UserID: fmt.Sprintf("%d", userID)}
+	for offset := 0; ; offset += exportPageSize {
+		page, total, err := h.Transactions.List(f, exportPageSize, offset)
+		if err != nil {
+			return userExport{}, err
+		}
+		exp.Transactions = append(exp.Transactions, page...)
+		if len(page) < exportPageSize || int64(len(exp.Transactions)) >= total {
+			break
+		}
+	}
+
+	var logs []models.AuditLog
+	if err := h.DB.Where("entity_type = ? AND entity_id = ? AND action LIKE ?", "user", fmt.Sprintf("%d", userID), "balance.%").
+		Order("created_at, id").Find(&logs).Error; err != nil {
+		return userExport{}, err
+	}
+	for _, l := range logs {
+		actor, _, _ := strings.Cut(l.Actor, ":")
+		exp.Ledger = append(exp.Ledger, exportLedgerEntry{At: l.CreatedAt, Action: l.Action, Actor: actor, Reason: l.Reason, Before: l.Before, After: l.After})
+	}
+
+	if err := h.DB.Where("transaction_id IN (?)", h.DB.Model(&models.Transaction{}).Select("id").Where("user_id = ?", userID)).
+		Order("created_at").Find(&exp.Receipts).Error; err != nil {
+		return userExport{}, err
+	}
+
+	var cards []models.AutoReload
+	if err := h.DB.Where("user_id = ? AND card_last_digits <> ''", userID).Find(&cards).Error; err != nil {
+		return userExport{}, err
+	}
+	for _, ar := range cards {
+		exp.SavedCards = append(exp.SavedCards, exportSavedCard{Brand: ar.CardBrand, LastDigits: ar.CardLastDigits, SavedAt: ar.CreatedAt, Enabled: ar.Enabled})
+	}
+	return exp, nil
+}
+
+// userProfile is the user in its documented JSON shape (models.UserDoc), for exports and the /users routes.
+func userProfile(u *models.User) models.UserDoc {
+	doc := models.UserDoc{
+		ID:            u.ID,
+		StudentID:     u.StudentID,
+		FirstName:     u.FirstName,
+		LastName:      u.LastName,
+		Gender:        u.Gender,
+		PhoneNumber:   u.PhoneNumber,
+		Balance:       u.Balance,
+		FrozenBalance: u.FrozenBalance,
+		HeldBalance:   u.HeldBalance,
+		LineUserID:    u.LineUserID,
+
+		PersonalDataErasedAt: u.PersonalDataErasedAt,
+	}
+	if len(u.ProfilePicture) > 0 {
+		doc.ProfilePicture = base64.StdEncoding.EncodeToString(u.ProfilePicture)
+	}
+	return doc
+}
+
+// writeUserExportArchive writes exp as a zip: export.json (complete) and a CSV per section.
+func writeUserExportArchive(w io.Writer, exp userExport) error {
+	zw := zip.NewWriter(w)
+
+	jw, err := zw.Create("export.json")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(jw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(exp); err != nil {
+		return err
+	}
+
+	ts := func(t time.Time) string { return t.UTC().Format(time.RFC3339) }
+	sections := []struct {
+		name   string
+		header []string
+		rows   [][]string
+	}{
+		{name: "transactions.csv", header: []string{"id", "created_at", "charge_id", "status", "channel", "amount_satang", "currency", "failure_code"}},
+		{name: "ledger.csv", header: []string{"at", "action", "actor", "reason", "before", "after"}},
+		{name: "receipts.csv", header: []string{"transaction_id", "charge_id", "status", "number", "url", "net_satang", "vat_satang", "issued_at"}},
+		{name: "saved_cards.csv", header: []string{"brand", "last_digits", "saved_at", "auto_reload_enabled"}},
+	}
+	for _, t := range exp.Transactions {
+		failure := ""
+		if t.FailureCode != nil {
+			failure = *t.FailureCode
+		}
+		sections[0].rows = append(sections[0].rows, []string{strconv.FormatUint(uint64(t.ID), 10), ts(t.CreatedAt), t.ChargeID, t.Status, t.Channel,
+			strconv.FormatInt(t.AmountSatang, 10), t.Currency, failure})
+	}
+	for _, l := range exp.Ledger {
+		sections[1].rows = append(sections[1].rows, []string{ts(l.At), l.Action, l.Actor, l.Reason, string(l.Before), string(l.After)})
+	}
+	for _, r := range exp.Receipts {
+		sections[2].rows = append(sections[2].rows, []string{strconv.FormatUint(uint64(r.TransactionID), 10), r.ChargeID, r.Status, r.Number, r.URL,
+			strconv.FormatInt(r.NetSatang, 10), strconv.FormatInt(r.VATSatang, 10), ts(r.UpdatedAt)})
+	}
+	for _, sc := range exp.SavedCards {
+		sections[3].rows = append(sections[3].rows, []string{sc.Brand, sc.LastDigits, ts(sc.SavedAt), strconv.FormatBool(sc.Enabled)})
+	}
+
+	for _, s := range sections {
+		fw, err := zw.Create(s.name)
+		if err != nil {
+			return err
+		}
+		cw := csv.NewWriter(fw)
+		if err := cw.Write(s.header); err != nil {
+			return err
+		}
+		if err := cw.WriteAll(s.rows); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestWriteUserExportArchive(t *testing.T) {
+	uid := uint(4)
+	exp := userExport{
+		ExportedAt: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		User:       models.UserDoc{ID: uid, FirstName: "Somchai"},
+		Transactions: []models.Transaction{
+			{ID: 1, UserID: &uid, ChargeID: "chrg_test_1", AmountSatang: 150000, Currency: "thb", Channel: "promptpay", Status: "successful"},
+		},
+		Ledger:     []exportLedgerEntry{{Action: models.AuditBalanceCredit, Actor: "system"}},
+		SavedCards: []exportSavedCard{{Brand: "Visa", LastDigits: "4242", Enabled: true}},
+	}
+
+	var buf bytes.Buffer
+	if err := writeUserExportArchive(&buf, exp); err != nil {
+		t.Fatalf("writeUserExportArchive: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+	files := map[string][][]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		raw, _ := io.ReadAll(rc)
+		rc.Close()
+		if f.Name == "export.json" {
+			if !bytes.Contains(raw, []byte(`"charge_id": "chrg_test_1"`)) {
+				t.Errorf("export.json misses the transaction: %s", raw)
+			}
+			continue
+		}
+		rows, err := csv.NewReader(bytes.NewReader(raw)).ReadAll()
+		if err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+		files[f.Name] = rows
+	}
+
+	for name, wantRows := range map[string]int{"transactions.csv": 2, "ledger.csv": 2, "receipts.csv": 1, "saved_cards.csv": 2} {
+		if got := len(files[name]); got != wantRows {
+			t.Errorf("%s: %d rows (with header), want %d", name, got, wantRows)
+		}
+	}
+	if row := files["transactions.csv"][1]; row[2] != "chrg_test_1" || row[5] != "150000" || row[6] != "thb" {
+		t.Errorf("transactions.csv row = %v", row)
+	}
+}
+
+func TestExportUserDataRequiresOwnerOrAdmin(t *testing.T) {
+	h := NewPaymentHandler(nil, nil)
+	h.AdminToken = "secret"
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/users/:id/export", h.ExportUserData)
+
+	for name, header := range map[string][2]string{
+		"anonymous":   {},
+		"other user":  {"X-User-ID", "5"},
+		"wrong token": {"X-Admin-Token", "guess"},
+	} {
+		req := httptest.NewRequest("GET", "/users/4/export", nil)
+		if header[0] != "" {
+			req.Header.Set(header[0], header[1])
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		if resp.StatusCode != 404 {
+			t.Errorf("%s: status %d, want 404", name, resp.StatusCode)
+		}
+	}
+}
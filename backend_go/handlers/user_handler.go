@@ -0,0 +1,232 @@
+// user_handler.go serves /users: creating, listing, reading and editing user profiles, and a summary of
+// a user's wallet and payments. Balances only move through payments and the wallet routes, never here.
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/a2n2k3p4/tutorium-backend/apiv1"
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type createUserRequest struct {
+	StudentID      string `json:"student_id" validate:"required,len=10,numeric"`
+	FirstName      string `json:"first_name" validate:"required,max=30"`
+	LastName       string `json:"last_name" validate:"required,max=30"`
+	Gender         string `json:"gender,omitempty" validate:"omitempty,oneof=Male Female Other"`
+	PhoneNumber    string `json:"phone_number,omitempty" validate:"omitempty,th_phone"`
+	ProfilePicture []byte `json:"profile_picture,omitempty" validate:"max=1048576"` // base64 in JSON; at most 1 MiB
+}
+
+// updateUserRequest changes the fields sent; an empty profile_picture removes it.
+type updateUserRequest struct {
+	StudentID      *string `json:"student_id,omitempty" validate:"omitempty,len=10,numeric"` // admin only
+	FirstName      *string `json:"first_name,omitempty" validate:"omitempty,min=1,max=30"`
+	LastName       *string `json:"last_name,omitempty" validate:"omitempty,min=1,max=30"`
+	Gender         *string `json:"gender,omitempty" validate:"omitempty,oneof=Male Female Other"`
+	PhoneNumber    *string `json:"phone_number,omitempty" validate:"omitempty,th_phone"`
+	ProfilePicture []byte  `json:"profile_picture,omitempty" validate:"max=1048576"`
+}
+
+// UserSummary is a user's wallet, what they have paid in so far and their latest transaction.
+type UserSummary struct {
+	UserID          uint               `json:"user_id"`
+	Balance         float64            `json:"balance"` // THB
+	HeldBalance     float64            `json:"held_balance"`
+	FrozenBalance   float64            `json:"frozen_balance"`
+	LifetimeTopUps  []UserTopUps       `json:"lifetime_top_ups"`
+	LastTransaction *apiv1.Transaction `json:"last_transaction,omitempty"`
+}
+
+// UserTopUps sums a user's successful charges in one currency.
+type UserTopUps struct {
+	Currency     string `json:"currency"`
+	Charges      int64  `json:"charges"`
+	AmountSatang int64  `json:"amount_satang"`
+}
+
+// CreateUser creates a user with a zero balance. Admin only.
+//
+//	POST /api/v1/users {"student_id": "6610505511", "first_name": "Alice", "last_name": "Smith"}
+func (h *PaymentHandler) CreateUser(c *fiber.Ctx) error {
+	var req createUserRequest
+	if err := parseAndValidate(c, &req); err != nil {
+		return err
+	}
+	user := models.User{
+		StudentID:      req.StudentID,
+		FirstName:      req.FirstName,
+		LastName:       req.LastName,
+		Gender:         req.Gender,
+		PhoneNumber:    req.PhoneNumber,
+		ProfilePicture: req.ProfilePicture,
+	}
+	if err := h.db(c).Create(&user).Error; err != nil {
+		if dbutil.IsUniqueViolation(err) {
+			return apperrors.ErrConflict.WithCode("student_id_taken").WithMessagef("student %s already has a user", req.StudentID)
+		}
+		return apperrors.ErrInternal.WithMessage("Failed to create user").Wrap(err)
+	}
+	profile := userProfile(&user)
+	h.audit(auditEntry(c, models.AuditUserCreate, "user", fmt.Sprintf("%d", user.ID), nil, auditProfile(profile)))
+	return c.Status(fiber.StatusCreated).JSON(profile)
+}
+
+// ListUsers returns a page of users by id, optionally those whose student id or name contains ?q=.
+// Admin only.
+func (h *PaymentHandler) ListUsers(c *fiber.Ctx) error {
+	limit, offset := h.limitOffset(c)
+	db := dbutil.Replica(h.db(c))
+	matching := func() *gorm.DB {
+		q := db.Model(&models.User{})
+		if term := c.Query("q"); term != "" {
+			like := "%" + term + "%"
+			q = q.Where("student_id ILIKE ? OR first_name ILIKE ? OR last_name ILIKE ?", like, like, like)
+		}
+		return q
+	}
+	var total int64
+	if err := matching().Count(&total).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve users").Wrap(err)
+	}
+	var users []models.User
+	if err := matching().Omit("profile_picture").Order("id").Limit(limit).Offset(offset).Find(&users).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve users").Wrap(err)
+	}
+	out := make([]models.UserDoc, 0, len(users))
+	for i := range users {
+		out = append(out, userProfile(&users[i]))
+	}
+	return c.JSON(fiber.Map{"users": out, "pagination": fiber.Map{"limit": limit, "offset": offset, "total": total}})
+}
+
+// GetUser returns the user's profile and balances. Allowed for the user (X-User-ID) or an admin.
+func (h *PaymentHandler) GetUser(c *fiber.Ctx) error {
+	user, err := h.userFor(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(userProfile(user))
+}
+
+// UpdateUser changes the fields sent of the user's profile; only an admin may change student_id. Same
+// access as GetUser.
+//
+//	PATCH /api/v1/users/<id> {"phone_number": "0812345678"}
+func (h *PaymentHandler) UpdateUser(c *fiber.Ctx) error {
+	var req updateUserRequest
+	if err := parseAndValidate(c, &req); err != nil {
+		return err
+	}
+	if req.StudentID != nil && !h.adminTokenValid(c) {
+		return apperrors.ErrForbidden.WithMessage("only an admin can change student_id")
+	}
+	user, err := h.userFor(c)
+	if err != nil {
+		return err
+	}
+	if user.PersonalDataErasedAt != nil {
+		return apperrors.ErrConflict.WithCode("personal_data_erased").WithMessage("the user's personal data has been erased")
+	}
+	before := auditProfile(userProfile(user))
+	updates := map[string]interface{}{}
+	for column, value := range map[string]*string{
+		"student_id":   req.StudentID,
+		"first_name":   req.FirstName,
+		"last_name":    req.LastName,
+		"gender":       req.Gender,
+		"phone_number": req.PhoneNumber,
+	} {
+		if value != nil {
+			updates[column] = *value
+		}
+	}
+	if req.ProfilePicture != nil {
+		updates["profile_picture"] = req.ProfilePicture
+	}
+	if len(updates) == 0 {
+		return apperrors.ErrValidation.WithMessage("no fields to update")
+	}
+	if err := h.db(c).Model(user).Updates(updates).Error; err != nil {
+		if dbutil.IsUniqueViolation(err) {
+			return apperrors.ErrConflict.WithCode("student_id_taken").WithMessagef("student %s already has a user", *req.StudentID)
+		}
+		return apperrors.ErrInternal.WithMessage("Failed to update user").Wrap(err)
+	}
+	if user, err = h.userFor(c); err != nil {
+		return err
+	}
+	profile := userProfile(user)
+	h.audit(auditEntry(c, models.AuditUserUpdate, "user", fmt.Sprintf("%d", user.ID), before, auditProfile(profile)))
+	return c.JSON(profile)
+}
+
+// GetUserSummary returns the user's balances, their successful charges summed per currency and their
+// latest transaction. Same access as GetUser.
+func (h *PaymentHandler) GetUserSummary(c *fiber.Ctx) error {
+	user, err := h.userFor(c)
+	if err != nil {
+		return err
+	}
+	db := dbutil.Replica(h.db(c))
+	summary := UserSummary{
+		UserID:         user.ID,
+		Balance:        user.Balance,
+		HeldBalance:    user.HeldBalance,
+		FrozenBalance:  user.FrozenBalance,
+		LifetimeTopUps: []UserTopUps{},
+	}
+	if err := db.Model(&models.Transaction{}).
+		Select("UPPER(currency) AS currency, COUNT(*) AS charges, SUM(amount_satang) AS amount_satang").
+		Where("user_id = ? AND status = ?", user.ID, "successful").
+		Group("1").Order("1").Scan(&summary.LifetimeTopUps).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to build user summary").Wrap(err)
+	}
+	var last models.Transaction
+	err = db.Scopes(repository.OmitPayload).Where("user_id = ?", user.ID).Order("created_at DESC, id DESC").Take(&last).Error
+	switch {
+	case err == nil:
+		view := apiv1.FromTransaction(last, responseLang(c))
+		summary.LastTransaction = &view
+	case !errors.Is(err, repository.ErrNotFound):
+		return apperrors.ErrInternal.WithMessage("Failed to build user summary").Wrap(err)
+	}
+	return c.JSON(summary)
+}
+
+// (helper for the /users/:id routes) the :id user with every column, when the caller may see it.
+func (h *PaymentHandler) userFor(c *fiber.Ctx) (*models.User, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return nil, apperrors.ErrValidation.WithMessage("id must be a user id")
+	}
+	if !h.adminTokenValid(c) {
+		// Do not reveal whether other users exist.
+		if self := userIDFromHeaderOrQuery(c); self == nil || *self != uint(id) {
+			return nil, apperrors.ErrNotFound.WithMessage("User not found")
+		}
+	}
+	var user models.User
+	if err := h.db(c).Take(&user, id).Error; err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, apperrors.ErrNotFound.WithMessage("User not found")
+		}
+		return nil, apperrors.ErrInternal.WithMessage("Failed to retrieve user").Wrap(err)
+	}
+	return &user, nil
+}
+
+// (helper for CreateUser and UpdateUser) profile as audited: the picture is noted, not copied.
+func auditProfile(profile models.UserDoc) models.UserDoc {
+	if profile.ProfilePicture != "" {
+		profile.ProfilePicture = fmt.Sprintf("<%d base64 characters>", len(profile.ProfilePicture))
+	}
+	return profile
+}
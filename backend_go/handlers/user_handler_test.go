@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestUserRoutesValidateBeforeTheDatabase(t *testing.T) {
+	h := NewPaymentHandler(nil, nil)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/users", h.CreateUser)
+	app.Get("/users/:id", h.GetUser)
+	app.Patch("/users/:id", h.UpdateUser)
+	app.Get("/users/:id/summary", h.GetUserSummary)
+
+	cases := []struct {
+		name, method, path, body string
+		status                   int
+	}{
+		{"short student id", "POST", "/users", `{"student_id": "66105", "first_name": "Alice", "last_name": "Smith"}`, 400},
+		{"no last name", "POST", "/users", `{"student_id": "6610505511", "first_name": "Alice"}`, 400},
+		{"bad phone", "POST", "/users", `{"student_id": "6610505511", "first_name": "Alice", "last_name": "Smith", "phone_number": "12345"}`, 400},
+		{"another user", "GET", "/users/8", "", 404},
+		{"another user's summary", "GET", "/users/8/summary", "", 404},
+		{"empty first name", "PATCH", "/users/7", `{"first_name": ""}`, 400},
+		{"student id without admin", "PATCH", "/users/7", `{"student_id": "6610505511"}`, 403},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-User-ID", "7")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s: status %d, want %d", tc.name, resp.StatusCode, tc.status)
+		}
+	}
+}
+
+func TestErasePersonalDataNeedsAReason(t *testing.T) {
+	h := NewPaymentHandler(nil, nil)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Delete("/users/:id/personal-data", h.ErasePersonalData)
+
+	for name, body := range map[string]string{"no reason": `{}`, "empty reason": `{"reason": ""}`} {
+		req := httptest.NewRequest("DELETE", "/users/7/personal-data", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", name, resp.StatusCode)
+		}
+	}
+	if id := erasedStudentID(42); len(id) != 10 || id != "X000000042" {
+		t.Errorf("erasedStudentID(42) = %q, want X000000042 (fits student_id)", id)
+	}
+}
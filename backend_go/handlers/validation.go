@@ -0,0 +1,101 @@
+// validation.go contains request validation (go-playground/validator).
+package handlers
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/money"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Thai phone numbers: 0XXXXXXXXX or +66XXXXXXXXX (mobile and landline).
+var thPhonePattern = regexp.MustCompile(`^(\+66|0)[0-9]{8,9}$`)
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	// Report JSON field names (e.g. "return_uri") instead of Go field names.
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	_ = v.RegisterValidation("currency", func(fl validator.FieldLevel) bool {
+		return money.Supported(fl.Field().String())
+	})
+	_ = v.RegisterValidation("charge_amount", chargeAmountValid)
+	_ = v.RegisterValidation("th_phone", func(fl validator.FieldLevel) bool {
+		return thPhonePattern.MatchString(strings.ReplaceAll(fl.Field().String(), "-", ""))
+	})
+	return v
+}
+
+// chargeAmountValid is the "charge_amount" rule: an amount in minor units within the charge limits of
+// the struct's Currency field. An unknown currency passes here and fails the "currency" rule instead.
+func chargeAmountValid(fl validator.FieldLevel) bool {
+	cur := fl.Parent().FieldByName("Currency")
+	if !cur.IsValid() || cur.Kind() != reflect.String {
+		return false
+	}
+	limits, ok := money.ChargeLimits(cur.String())
+	return !ok || limits.Contains(fl.Field().Int())
+}
+
+// parseAndValidate decodes the body into req and runs its validate tags.
+func parseAndValidate(c *fiber.Ctx, req interface{}) error {
+	if err := c.BodyParser(req); err != nil {
+		return apperrors.ErrBadRequest.WithCode("invalid_body").WithMessage("request body must be valid JSON matching the documented schema")
+	}
+	if err := validate.Struct(req); err != nil {
+		return validationError(err)
+	}
+	return nil
+}
+
+// validationError converts validator errors into ErrValidation with per-field details.
+func validationError(err error) error {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return apperrors.ErrValidation
+	}
+	fields := make([]apperrors.FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		fields = append(fields, apperrors.FieldError{Field: fe.Field(), Rule: fe.Tag(), Message: fieldMessage(fe)})
+	}
+	return apperrors.ErrValidation.WithMessage("one or more fields are invalid").WithFields(fields)
+}
+
+// (helper for validationError) human-readable message per rule.
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required", "required_if":
+		return fe.Field() + " is required"
+	case "min", "gte":
+		return fe.Field() + " must be at least " + fe.Param()
+	case "max", "lte":
+		return fe.Field() + " must be at most " + fe.Param()
+	case "oneof":
+		return fe.Field() + " must be one of: " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "currency":
+		return fe.Field() + " is not a supported currency (" + strings.Join(money.SupportedCurrencies(), ", ") + ")"
+	case "charge_amount":
+		return fe.Field() + " is outside the charge limits of the currency"
+	case "th_phone":
+		return fe.Field() + " must be a Thai phone number (0XXXXXXXXX or +66XXXXXXXXX)"
+	case "url", "http_url":
+		return fe.Field() + " must be a valid URL"
+	case "email":
+		return fe.Field() + " must be a valid email address"
+	case "startswith":
+		return fe.Field() + " must start with " + fe.Param()
+	}
+	return fe.Field() + " is invalid (" + fe.Tag() + ")"
+}
@@ -0,0 +1,268 @@
+// wallet_handler.go contains wallet balance handlers (debit, credit, holds) for /payments/wallet.
+// Every call carries a client-supplied operation_id; a retry with the same id replays the stored
+// result instead of moving money again (see models.WalletOperation).
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/money"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var errOperationReplayed = errors.New("wallet operation already applied")
+
+type walletOperationRequest struct {
+	OperationID string `json:"operation_id" validate:"required,max=100"`
+	UserID      *uint  `json:"user_id,omitempty"`
+	Amount      int64  `json:"amount" validate:"required,gt=0"` // satang
+	Description string `json:"description,omitempty" validate:"max=255"`
+}
+
+// DebitWallet deducts amount (satang) from the user's balance, then triggers auto-reload if the
+// remaining balance dropped below the user's threshold.
+func (h *PaymentHandler) DebitWallet(c *fiber.Ctx) error {
+	op, entry, err := h.walletOperationFromRequest(c, models.WalletOpDebit, models.AuditBalanceDebit)
+	if err != nil {
+		return err
+	}
+	amountTHB := money.New(op.AmountSatang, money.THB).Major()
+	replayed, err := h.applyWalletOperation(c, &op, entry, func(users repository.UserRepository) error {
+		return users.Debit(op.UserID, amountTHB)
+	})
+	if err != nil {
+		return walletOperationError(err, "Failed to debit wallet")
+	}
+	if !replayed {
+		log.Printf("wallet: debit op=%s user=%d amount=%d desc=%q balance=%.2f", op.OperationID, op.UserID, op.AmountSatang, op.Description, op.Balance)
+		// Top up in the background; the debit itself has already succeeded.
+		uid, balance := op.UserID, op.Balance
+		h.goBackground(func(ctx context.Context) { h.maybeAutoReload(ctx, uid, balance) })
+	}
+	return walletOperationResponse(c, op, replayed)
+}
+
+// CreditWallet adds amount (satang) to the user's balance (e.g. a cancelled booking). Admin only.
+func (h *PaymentHandler) CreditWallet(c *fiber.Ctx) error {
+	op, entry, err := h.walletOperationFromRequest(c, models.WalletOpCredit, models.AuditBalanceCredit)
+	if err != nil {
+		return err
+	}
+	amountTHB := money.New(op.AmountSatang, money.THB).Major()
+	replayed, err := h.applyWalletOperation(c, &op, entry, func(users repository.UserRepository) error {
+		return users.Credit(op.UserID, amountTHB)
+	})
+	if err != nil {
+		return walletOperationError(err, "Failed to credit wallet")
+	}
+	if !replayed {
+		log.Printf("wallet: credit op=%s user=%d amount=%d desc=%q balance=%.2f", op.OperationID, op.UserID, op.AmountSatang, op.Description, op.Balance)
+	}
+	return walletOperationResponse(c, op, replayed)
+}
+
+// HoldWallet reserves amount (satang) of the user's balance, e.g. while a booking awaits confirmation.
+// The operation_id identifies the hold for a later release or capture.
+func (h *PaymentHandler) HoldWallet(c *fiber.Ctx) error {
+	op, entry, err := h.walletOperationFromRequest(c, models.WalletOpHold, models.AuditBalanceHold)
+	if err != nil {
+		return err
+	}
+	op.HoldStatus = models.WalletHoldActive
+	amountTHB := money.New(op.AmountSatang, money.THB).Major()
+	replayed, err := h.applyWalletOperation(c, &op, entry, func(users repository.UserRepository) error {
+		return users.Hold(op.UserID, amountTHB)
+	})
+	if err != nil {
+		return walletOperationError(err, "Failed to hold wallet balance")
+	}
+	if !replayed {
+		log.Printf("wallet: hold op=%s user=%d amount=%d desc=%q balance=%.2f", op.OperationID, op.UserID, op.AmountSatang, op.Description, op.Balance)
+	}
+	return walletOperationResponse(c, op, replayed)
+}
+
+// ReleaseWalletHold returns an active hold to the user's balance. Releasing an already released hold
+// replays the result, so it is safe to retry.
+func (h *PaymentHandler) ReleaseWalletHold(c *fiber.Ctx) error {
+	return h.settleWalletHold(c, models.WalletHoldReleased)
+}
+
+// CaptureWalletHold spends an active hold (the booking was confirmed). Safe to retry like release.
+func (h *PaymentHandler) CaptureWalletHold(c *fiber.Ctx) error {
+	return h.settleWalletHold(c, models.WalletHoldCaptured)
+}
+
+// (helper for ReleaseWalletHold / CaptureWalletHold) move the hold to status in one DB transaction.
+func (h *PaymentHandler) settleWalletHold(c *fiber.Ctx, status string) error {
+	operationID := c.Params("operation_id")
+	action := models.AuditBalanceRelease
+	if status == models.WalletHoldCaptured {
+		action = models.AuditBalanceCapture
+	}
+
+	var op models.WalletOperation
+	replayed := false
+	err := dbutil.Transaction(h.db(c), "settle_wallet_hold", func(tx *gorm.DB) error {
+		replayed = false
+		ops := h.WalletOperations.WithTx(tx)
+		hold, err := ops.LockHold(operationID)
+		if err != nil {
+			return err
+		}
+		op = *hold
+		if op.HoldStatus == status {
+			replayed = true
+			return nil
+		}
+		if op.HoldStatus != models.WalletHoldActive {
+			return apperrors.ErrConflict.WithCode("hold_not_active").WithMessagef("hold is already %s", op.HoldStatus)
+		}
+
+		users := h.Users.WithTx(tx)
+		amountTHB := money.New(op.AmountSatang, money.THB).Major()
+		settle := users.CaptureHold
+		if status == models.WalletHoldReleased {
+			settle = users.ReleaseHold
+		}
+		if err := settle(op.UserID, amountTHB); err != nil {
+			return err
+		}
+		user, err := users.Get(op.UserID)
+		if err != nil {
+			return err
+		}
+		op.HoldStatus, op.Balance, op.HeldBalance = status, user.Balance, user.HeldBalance
+		if err := ops.Save(&op); err != nil {
+			return err
+		}
+		entry := auditEntry(c, action, "user", fmt.Sprintf("%d", op.UserID),
+			fiber.Map{"hold_status": models.WalletHoldActive},
+			fiber.Map{"hold_status": status, "operation_id": op.OperationID, "amount_satang": op.AmountSatang,
+				"balance": user.Balance, "held_balance": user.HeldBalance})
+		return writeAudit(tx, entry)
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperrors.ErrNotFound.WithMessage("Hold not found")
+		}
+		return walletOperationError(err, "Failed to settle wallet hold")
+	}
+	if !replayed {
+		log.Printf("wallet: hold %s op=%s user=%d amount=%d", status, op.OperationID, op.UserID, op.AmountSatang)
+	}
+	return walletOperationResponse(c, op, replayed)
+}
+
+// (helper for wallet handlers) parse and validate the request into an unsaved operation and its audit entry.
+func (h *PaymentHandler) walletOperationFromRequest(c *fiber.Ctx, kind, action string) (models.WalletOperation, models.AuditLog, error) {
+	var req walletOperationRequest
+	if err := parseAndValidate(c, &req); err != nil {
+		return models.WalletOperation{}, models.AuditLog{}, err
+	}
+	userID := req.UserID
+	if userID == nil {
+		userID = userIDFromHeaderOrQuery(c)
+	}
+	if userID == nil {
+		return models.WalletOperation{}, models.AuditLog{}, apperrors.ErrValidation.WithMessage("user_id is required")
+	}
+	op := models.WalletOperation{
+		OperationID:  req.OperationID,
+		UserID:       *userID,
+		Kind:         kind,
+		AmountSatang: req.Amount,
+		Description:  req.Description,
+	}
+	entry := auditEntry(c, action, "user", fmt.Sprintf("%d", *userID), nil, nil)
+	entry.Reason = req.Description
+	return op, entry, nil
+}
+
+// applyWalletOperation claims op.OperationID, runs mutate and records the resulting balances and the
+// audit entry, all in one DB transaction under c's deadline. When the id was already applied, op is
+// replaced by the stored operation and replayed is true; a reused id with different parameters is a
+// conflict.
+func (h *PaymentHandler) applyWalletOperation(c *fiber.Ctx, op *models.WalletOperation, entry models.AuditLog, mutate func(users repository.UserRepository) error) (replayed bool, err error) {
+	requested := *op
+	err = dbutil.Transaction(h.db(c), "wallet_"+op.Kind, func(tx *gorm.DB) error {
+		*op = requested
+		// Claim the id first: a concurrent retry blocks here until this transaction ends.
+		ops := h.WalletOperations.WithTx(tx)
+		claimed, err := ops.Claim(op)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			return errOperationReplayed
+		}
+		users := h.Users.WithTx(tx)
+		if err := mutate(users); err != nil {
+			return err
+		}
+		user, err := users.Get(op.UserID)
+		if err != nil {
+			return err
+		}
+		op.Balance, op.HeldBalance = user.Balance, user.HeldBalance
+		if err := ops.Save(op); err != nil {
+			return err
+		}
+		entry.After = auditJSON(fiber.Map{"operation_id": op.OperationID, "amount_satang": op.AmountSatang,
+			"balance": user.Balance, "held_balance": user.HeldBalance})
+		return writeAudit(tx, entry)
+	})
+	if !errors.Is(err, errOperationReplayed) {
+		return false, err
+	}
+
+	stored, err := h.WalletOperations.WithContext(c.UserContext()).Get(requested.OperationID)
+	if err != nil {
+		return false, err
+	}
+	if stored.UserID != requested.UserID || stored.Kind != requested.Kind || stored.AmountSatang != requested.AmountSatang {
+		return false, apperrors.ErrConflict.WithCode("operation_id_reused").
+			WithMessagef("operation_id %q was already used for a different %s of %d satang", stored.OperationID, stored.Kind, stored.AmountSatang)
+	}
+	*op = *stored
+	return true, nil
+}
+
+// (helper for wallet handlers) map operation errors to API errors.
+func walletOperationError(err error, message string) error {
+	var appErr *apperrors.Error
+	switch {
+	case errors.As(err, &appErr):
+		return appErr
+	case errors.Is(err, repository.ErrInsufficientBalance):
+		return apperrors.ErrConflict.WithMessage("insufficient balance")
+	case errors.Is(err, repository.ErrNotFound):
+		return apperrors.ErrNotFound.WithMessage("User not found")
+	}
+	return apperrors.ErrInternal.WithMessage(message).Wrap(err)
+}
+
+// (helper for wallet handlers) the operation as JSON; replays are flagged with Idempotent-Replayed.
+func walletOperationResponse(c *fiber.Ctx, op models.WalletOperation, replayed bool) error {
+	if replayed {
+		c.Set("Idempotent-Replayed", "true")
+	}
+	return c.JSON(fiber.Map{
+		"operation_id": op.OperationID,
+		"user_id":      op.UserID,
+		"kind":         op.Kind,
+		"amount":       op.AmountSatang,
+		"hold_status":  op.HoldStatus,
+		"balance":      op.Balance,
+		"held_balance": op.HeldBalance,
+		"replayed":     replayed,
+	})
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/repository/repotest"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func TestWalletOperationsRequireOperationID(t *testing.T) {
+	h := NewPaymentHandler(nil, nil)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/debit", h.DebitWallet)
+	app.Post("/holds", h.HoldWallet)
+
+	for _, path := range []string{"/debit", "/holds"} {
+		req := httptest.NewRequest("POST", path, strings.NewReader(`{"user_id":1,"amount":5000}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		if resp.StatusCode != 400 {
+			t.Errorf("%s without operation_id: status %d, want 400", path, resp.StatusCode)
+		}
+	}
+}
+
+// walletTestApp serves the wallet handlers over in-memory users and operations.
+func walletTestApp(t *testing.T, users ...models.User) (*fiber.App, *repotest.Users) {
+	t.Helper()
+	h := NewPaymentHandler(repotest.NewDB(), nil)
+	store := repotest.NewUsers(users...)
+	h.Users, h.WalletOperations = store, repotest.NewWalletOperations()
+	t.Cleanup(func() { h.Drain(context.Background()) })
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/debit", h.DebitWallet)
+	app.Post("/holds", h.HoldWallet)
+	app.Post("/holds/:operation_id/release", h.ReleaseWalletHold)
+	return app, store
+}
+
+type walletTestResponse struct {
+	Status     int
+	Replayed   bool    `json:"replayed"`
+	Balance    float64 `json:"balance"`
+	Held       float64 `json:"held_balance"`
+	Code       string  `json:"code"`
+	ReplayHead string
+}
+
+func walletCall(t *testing.T, app *fiber.App, path, body string) walletTestResponse {
+	t.Helper()
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	out := walletTestResponse{Status: resp.StatusCode, ReplayHead: resp.Header.Get("Idempotent-Replayed")}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("%s: decode: %v", path, err)
+	}
+	return out
+}
+
+func TestDebitWalletReplaysOperationID(t *testing.T) {
+	app, users := walletTestApp(t, models.User{Model: gorm.Model{ID: 7}, Balance: 100})
+	debit := `{"operation_id": "booking-41", "user_id": 7, "amount": 5000}`
+
+	first := walletCall(t, app, "/debit", debit)
+	if first.Status != 200 || first.Replayed || first.Balance != 50 {
+		t.Fatalf("first debit = %+v, want 200 with balance 50", first)
+	}
+	again := walletCall(t, app, "/debit", debit)
+	if again.Status != 200 || !again.Replayed || again.ReplayHead != "true" || again.Balance != 50 {
+		t.Fatalf("retried debit = %+v, want the first result replayed", again)
+	}
+	reused := walletCall(t, app, "/debit", `{"operation_id": "booking-41", "user_id": 7, "amount": 3000}`)
+	if reused.Status != 409 || reused.Code != "operation_id_reused" {
+		t.Fatalf("debit reusing the id for another amount = %+v, want 409 operation_id_reused", reused)
+	}
+
+	if u, _ := users.Get(7); u.Balance != 50 {
+		t.Errorf("balance = %.2f, want 50.00: debited once", u.Balance)
+	}
+}
+
+func TestReleaseWalletHoldReplays(t *testing.T) {
+	app, users := walletTestApp(t, models.User{Model: gorm.Model{ID: 7}, Balance: 100})
+
+	if held := walletCall(t, app, "/holds", `{"operation_id": "booking-42", "user_id": 7, "amount": 2000}`); held.Status != 200 || held.Held != 20 {
+		t.Fatalf("hold = %+v, want 200 holding 20", held)
+	}
+	first := walletCall(t, app, "/holds/booking-42/release", "")
+	if first.Status != 200 || first.Replayed || first.Balance != 100 || first.Held != 0 {
+		t.Fatalf("release = %+v, want 200 with the hold back in the balance", first)
+	}
+	again := walletCall(t, app, "/holds/booking-42/release", "")
+	if again.Status != 200 || !again.Replayed || again.Balance != 100 {
+		t.Fatalf("retried release = %+v, want the first result replayed", again)
+	}
+	if reused := walletCall(t, app, "/holds", `{"operation_id": "booking-42", "user_id": 7, "amount": 2000}`); reused.Status != 200 || !reused.Replayed {
+		t.Errorf("hold retried after its release = %+v, want the stored hold replayed", reused)
+	}
+
+	if u, _ := users.Get(7); u.Balance != 100 || u.HeldBalance != 0 {
+		t.Errorf("balance = %.2f held = %.2f, want 100.00 and 0.00: held and released once", u.Balance, u.HeldBalance)
+	}
+}
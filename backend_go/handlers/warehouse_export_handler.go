@@ -0,0 +1,237 @@
+// warehouse_export_handler.go delivers each Bangkok day's transactions to the data warehouse bucket
+// (nightly as the warehouse_export job, or on demand) and serves /admin/warehouse-exports.
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/notify"
+	"github.com/a2n2k3p4/tutorium-backend/objectstore"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"github.com/a2n2k3p4/tutorium-backend/warehouse"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// warehouseExportMetrics is published at /debug/vars as "warehouse_export": runs, failures, and
+// last_success_unix and last_rows from the last successful export.
+var warehouseExportMetrics = expvar.NewMap("warehouse_export")
+
+// warehouseExportStale is how long a run may stay "running" before it is taken to have died with its
+// replica and is claimed again; well beyond the job timeout.
+const warehouseExportStale = 2 * time.Hour
+
+// warehouseExportRetryDays is how far back the nightly job retries failed exports.
+const warehouseExportRetryDays = 7
+
+// WarehouseExport is where the warehouse_export job delivers; a nil Store disables it.
+type WarehouseExport struct {
+	Store  objectstore.Store
+	Format string // warehouse.FormatCSV or warehouse.FormatCSVGzip
+	Prefix string // object key prefix, e.g. "transactions/"
+}
+
+// (helper for runWarehouseExport) the object key of day's file, partitioned by date for the warehouse
+// loader: <prefix>date=YYYY-MM-DD/transactions.<format>.
+func (w WarehouseExport) key(day string) string {
+	return w.Prefix + "date=" + day + "/transactions." + w.Format
+}
+
+type runWarehouseExportRequest struct {
+	Day string `json:"day" validate:"required"` // YYYY-MM-DD (Bangkok)
+}
+
+// ListWarehouseExports returns whether the export is configured, the last export and the last
+// successful one, and a page of exports, newest first.
+func (h *PaymentHandler) ListWarehouseExports(c *fiber.Ctx) error {
+	limit, offset := h.limitOffset(c)
+	exports := []models.WarehouseExport{}
+	if err := h.db(c).Order("created_at DESC, id DESC").Limit(limit).Offset(offset).Find(&exports).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to retrieve warehouse exports").Wrap(err)
+	}
+	resp := fiber.Map{"enabled": h.Warehouse.Store != nil, "format": h.Warehouse.Format, "exports": exports}
+	for name, q := range map[string]func() (models.WarehouseExport, error){
+		"last": func() (e models.WarehouseExport, err error) {
+			err = h.db(c).Order("started_at DESC, id DESC").First(&e).Error
+			return e, err
+		},
+		"last_success": func() (e models.WarehouseExport, err error) {
+			err = h.db(c).Where("status = ?", models.WarehouseExportSucceeded).Order("day DESC").First(&e).Error
+			return e, err
+		},
+	} {
+		e, err := q()
+		if err == nil {
+			resp[name] = e
+		} else if !errors.Is(err, repository.ErrNotFound) {
+			return apperrors.ErrInternal.WithMessage("Failed to retrieve warehouse exports").Wrap(err)
+		}
+	}
+	return c.JSON(resp)
+}
+
+// RunWarehouseExport exports one day now, e.g. to retry a failed night or backfill. A day already
+// exported (or being exported) is a conflict.
+//
+//	POST /api/v1/admin/warehouse-exports {"day": "2026-10-01"}
+func (h *PaymentHandler) RunWarehouseExport(c *fiber.Ctx) error {
+	if h.Warehouse.Store == nil {
+		return apperrors.ErrConflict.WithMessage("warehouse export is not configured (WAREHOUSE_BUCKET)")
+	}
+	var req runWarehouseExportRequest
+	if err := parseAndValidate(c, &req); err != nil {
+		return err
+	}
+	day, err := time.ParseInLocation("2006-01-02", req.Day, bangkok)
+	if err != nil {
+		return apperrors.ErrValidation.WithMessage("day must be YYYY-MM-DD")
+	}
+	if !day.AddDate(0, 0, 1).Before(time.Now()) {
+		return apperrors.ErrValidation.WithMessage("day must be over (Bangkok time)")
+	}
+	run, err := h.runWarehouseExport(c.UserContext(), adminActor(c), day)
+	if run == nil && err == nil {
+		return apperrors.ErrConflict.WithMessagef("%s is already exported or being exported", req.Day)
+	}
+	if run == nil {
+		return apperrors.ErrInternal.WithMessage("Failed to run the warehouse export").Wrap(err)
+	}
+	return c.JSON(run)
+}
+
+// exportPreviousDay is the warehouse_export job: it exports yesterday (Bangkok), once across replicas,
+// then retries the exports of the week before that failed.
+func (h *PaymentHandler) exportPreviousDay(ctx context.Context, now time.Time) error {
+	local := now.In(bangkok)
+	yesterday := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, bangkok).AddDate(0, 0, -1)
+	_, err := h.runWarehouseExport(ctx, "system:jobs", yesterday)
+
+	var failed []string
+	if qerr := h.DB.WithContext(ctx).Model(&models.WarehouseExport{}).
+		Where("status = ? AND day >= ? AND day < ?", models.WarehouseExportFailed,
+			yesterday.AddDate(0, 0, -warehouseExportRetryDays).Format("2006-01-02"), yesterday.Format("2006-01-02")).
+		Order("day").Pluck("day", &failed).Error; qerr != nil {
+		return errors.Join(err, qerr)
+	}
+	for _, d := range failed {
+		if ctx.Err() != nil {
+			break
+		}
+		day, perr := time.ParseInLocation("2006-01-02", d, bangkok)
+		if perr != nil {
+			continue
+		}
+		if _, rerr := h.runWarehouseExport(ctx, "system:jobs", day); rerr != nil {
+			err = errors.Join(err, rerr)
+		}
+	}
+	return err
+}
+
+// runWarehouseExport claims day, writes its transactions to the bucket and records the outcome. A day
+// that is exported or being exported returns (nil, nil); a failed one is claimed again, as is one
+// "running" for longer than warehouseExportStale. When the
+// export itself fails, the run is stored with its error and returned along with it.
+func (h *PaymentHandler) runWarehouseExport(ctx context.Context, actor string, day time.Time) (*models.WarehouseExport, error) {
+	run, err := h.claimWarehouseExport(actor, day.Format("2006-01-02"))
+	if run == nil || err != nil {
+		return nil, err
+	}
+
+	key := h.Warehouse.key(run.Day)
+	n, size, runErr := h.writeWarehouseDay(ctx, key, day, day.AddDate(0, 0, 1))
+	finished := time.Now()
+	run.FinishedAt = &finished
+	run.Object, run.Rows, run.Bytes = h.Warehouse.Store.URL(key), n, size
+	run.Status = models.WarehouseExportSucceeded
+	if runErr != nil {
+		run.Status, run.Error = models.WarehouseExportFailed, runErr.Error()
+	}
+	if err := h.DB.Save(run).Error; err != nil {
+		return nil, err
+	}
+
+	warehouseExportMetrics.Add("runs", 1)
+	if runErr != nil {
+		warehouseExportMetrics.Add("failures", 1)
+		h.sendAdminAlert(notify.Message{
+			Subject: "Warehouse export failed",
+			Body:    fmt.Sprintf("Export of %s (attempt %d) failed: %s\n\nRetry: POST /api/v1/admin/warehouse-exports {\"day\": %q}", run.Day, run.Attempts, run.Error, run.Day),
+		})
+	} else {
+		for name, v := range map[string]int64{"last_success_unix": finished.Unix(), "last_rows": int64(n)} {
+			iv := new(expvar.Int)
+			iv.Set(v)
+			warehouseExportMetrics.Set(name, iv)
+		}
+	}
+	log.Printf("warehouse_export: day=%s object=%s rows=%d bytes=%d attempt=%d err=%v", run.Day, run.Object, n, size, run.Attempts, runErr)
+	return run, runErr
+}
+
+// (helper for runWarehouseExport) insert day's run, or take over a failed or stale one; nil when
+// another run has the day.
+func (h *PaymentHandler) claimWarehouseExport(actor, day string) (*models.WarehouseExport, error) {
+	now := time.Now()
+	run := models.WarehouseExport{Day: day, Status: models.WarehouseExportRunning, Format: h.Warehouse.Format, Attempts: 1, StartedAt: now, CreatedBy: actor}
+	err := h.DB.Create(&run).Error
+	if err == nil {
+		return &run, nil
+	}
+	if !dbutil.IsUniqueViolation(err) {
+		return nil, err
+	}
+	res := h.DB.Model(&models.WarehouseExport{}).
+		Where("day = ? AND (status = ? OR (status = ? AND started_at < ?))", day,
+			models.WarehouseExportFailed, models.WarehouseExportRunning, now.Add(-warehouseExportStale)).
+		Updates(map[string]interface{}{
+			"status": models.WarehouseExportRunning, "format": h.Warehouse.Format, "attempts": gorm.Expr("attempts + 1"),
+			"started_at": now, "finished_at": nil, "error": "", "created_by": actor,
+		})
+	if res.Error != nil || res.RowsAffected == 0 {
+		return nil, res.Error
+	}
+	if err := h.DB.First(&run, "day = ?", day).Error; err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// (helper for runWarehouseExport) encode the transactions created in [from, to) and upload them to
+// key; the row count and file size.
+func (h *PaymentHandler) writeWarehouseDay(ctx context.Context, key string, from, to time.Time) (int, int64, error) {
+	repo := h.Transactions.WithContext(ctx)
+	f := repository.TransactionFilter{From: from, To: to}
+	var rows []models.Transaction
+	var after *repository.TransactionCursor
+	for {
+		page, err := repo.PageAfter(f, after, exportPageSize)
+		if err != nil {
+			return 0, 0, err
+		}
+		rows = append(rows, page...)
+		if len(page) < exportPageSize {
+			break
+		}
+		cur := repository.CursorAfter(page[len(page)-1])
+		after = &cur
+	}
+
+	var buf bytes.Buffer
+	if err := warehouse.Encode(&buf, h.Warehouse.Format, rows); err != nil {
+		return 0, 0, err
+	}
+	if err := h.Warehouse.Store.Put(ctx, key, buf.Bytes(), warehouse.ContentType(h.Warehouse.Format)); err != nil {
+		return 0, 0, err
+	}
+	return len(rows), int64(buf.Len()), nil
+}
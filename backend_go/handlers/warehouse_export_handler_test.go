@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/objectstore"
+	"github.com/a2n2k3p4/tutorium-backend/warehouse"
+)
+
+func TestWriteWarehouseDay(t *testing.T) {
+	at := time.Date(2025, 10, 1, 9, 0, 0, 0, bangkok)
+	repo := &memTransactions{}
+	for i := exportPageSize + 2; i > 0; i-- { // more than a page, newest first
+		repo.rows = append(repo.rows, models.Transaction{ID: uint(i), ChargeID: "chrg_test", CreatedAt: at.Add(time.Duration(i) * time.Second)})
+	}
+	store := objectstore.NewMemory()
+	h := NewPaymentHandler(nil, nil)
+	h.Transactions = repo
+	h.Warehouse = WarehouseExport{Store: store, Format: warehouse.FormatCSVGzip, Prefix: "transactions/"}
+
+	day := time.Date(2025, 10, 1, 0, 0, 0, 0, bangkok)
+	key := h.Warehouse.key("2025-10-01")
+	if key != "transactions/date=2025-10-01/transactions.csv.gz" {
+		t.Errorf("key = %s", key)
+	}
+	n, size, err := h.writeWarehouseDay(context.Background(), key, day, day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("writeWarehouseDay: %v", err)
+	}
+	if f := repo.lastFilter; !f.From.Equal(day) || !f.To.Equal(day.AddDate(0, 0, 1)) {
+		t.Errorf("filter = %+v, want the Bangkok day", f)
+	}
+	body, ok := store.Get(key)
+	if !ok || int64(len(body)) != size {
+		t.Fatalf("object %s: present %v, %d bytes, want %d", key, ok, len(body), size)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := io.ReadAll(zr)
+	if lines := strings.Count(string(raw), "\n"); n != exportPageSize+2 || lines != n+1 {
+		t.Errorf("%d rows, %d lines; want every transaction across pages plus the header", n, lines)
+	}
+}
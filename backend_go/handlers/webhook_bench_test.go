@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/service"
+	omise "github.com/omise/omise-go"
+)
+
+var (
+	benchWebhookBody = []byte(`{"object":"event","id":"evnt_test_5xyz","livemode":false,"key":"charge.complete",` +
+		`"data":{"object":"charge","id":"chrg_test_5xyz","amount":150000,"currency":"thb","status":"successful"}}`)
+
+	benchCharge = func() *omise.Charge {
+		ip := "203.0.113.10"
+		return &omise.Charge{
+			Base:     omise.Base{Object: "charge", ID: "chrg_test_5xyz", CreatedAt: time.Unix(1700000000, 0)},
+			Status:   omise.ChargeSuccessful,
+			Amount:   150000,
+			Currency: "thb",
+			IP:       &ip,
+			Card: &omise.Card{
+				Base:       omise.Base{Object: "card", ID: "card_test_5xyz"},
+				Name:       "Somchai Jaidee",
+				City:       "Bangkok",
+				PostalCode: "10330",
+				LastDigits: "4242",
+				Brand:      "Visa",
+			},
+			Metadata: map[string]interface{}{"user_id": "1", "course": map[string]interface{}{"name": "Calculus"}},
+		}
+	}()
+
+	benchEvent = &omise.Event{Base: omise.Base{Object: "event", ID: "evnt_test_5xyz"}, Key: "charge.complete", Data: benchCharge}
+)
+
+// Allocation ceilings for the webhook hot path; a failure here means a change made it more expensive.
+func TestWebhookHotPathAllocs(t *testing.T) {
+	s := &service.PaymentService{}
+	gates := []struct {
+		name string
+		max  float64
+		fn   func()
+	}{
+		{"parseWebhookEnvelope", 4, func() { _, _ = parseWebhookEnvelope(benchWebhookBody) }},
+		{"chargeIDFromEvent", 0, func() { _, _ = chargeIDFromEvent(benchEvent) }},
+		{"SealRawPayload", 40, func() { _, _ = s.SealRawPayload(benchCharge) }},
+	}
+	for _, g := range gates {
+		if got := testing.AllocsPerRun(100, g.fn); got > g.max {
+			t.Errorf("%s: %.0f allocs/op, want <= %.0f", g.name, got, g.max)
+		}
+	}
+}
+
+func BenchmarkParseWebhookEnvelope(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := parseWebhookEnvelope(benchWebhookBody); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkChargeIDFromEvent(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, ok := chargeIDFromEvent(benchEvent); !ok {
+			b.Fatal("no charge id")
+		}
+	}
+}
+
+// BenchmarkChargeIDFromEventMarshal is the previous approach (marshal ev.Data, unmarshal id/object), kept for comparison.
+func BenchmarkChargeIDFromEventMarshal(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var embedded struct {
+			ID     string `json:"id"`
+			Object string `json:"object"`
+		}
+		raw, _ := json.Marshal(benchEvent.Data)
+		if err := json.Unmarshal(raw, &embedded); err != nil || embedded.ID == "" {
+			b.Fatal("no charge id")
+		}
+	}
+}
+
+func BenchmarkSealRawPayload(b *testing.B) {
+	s := &service.PaymentService{}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.SealRawPayload(benchCharge); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkListTransactionsSerialization(b *testing.B) {
+	uid := uint(1)
+	txs := make([]models.Transaction, 50)
+	for i := range txs {
+		txs[i] = models.Transaction{
+			ID: uint(i + 1), UserID: &uid, ChargeID: "chrg_test_5xyz", AmountSatang: 150000,
+			Currency: "thb", Channel: "card", Status: "successful",
+			RawPayload: make([]byte, 2048), // never serialized (json:"-")
+		}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(map[string]interface{}{"transactions": txs}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	omise "github.com/omise/omise-go"
+)
+
+func TestWebhookEndpointResolution(t *testing.T) {
+	h := NewPaymentHandler(nil, nil)
+	h.WebhookEndpoints = []WebhookEndpoint{{Name: "school-a", Secret: "s3cret"}}
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/webhooks/:endpoint", h.HandleWebhook)
+
+	cases := []struct {
+		path, token string
+		want        int
+	}{
+		{"/webhooks/unknown", "", 404},
+		{"/webhooks/school-a", "", 401},
+		{"/webhooks/school-a", "wrong", 401},
+		{"/webhooks/school-a?token=s3cret", "", 400}, // authorized; the empty body is then rejected
+		{"/webhooks/school-a", "s3cret", 400},
+		{"/webhooks/omise", "", 400}, // default endpoint, open
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest("POST", tc.path, strings.NewReader("{}"))
+		req.Header.Set("Content-Type", "application/json")
+		if tc.token != "" {
+			req.Header.Set("X-Webhook-Token", tc.token)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		if resp.StatusCode != tc.want {
+			t.Errorf("POST %s (token %q): status %d, want %d", tc.path, tc.token, resp.StatusCode, tc.want)
+		}
+	}
+}
+
+func TestWebhookEndpointRules(t *testing.T) {
+	ep := WebhookEndpoint{Events: []string{"charge.*", "refund.create"}, InstitutionID: 12}
+	for key, want := range map[string]bool{"charge.complete": true, "refund.create": true, "refund.update": false, "customer.create": false} {
+		if got := ep.handlesEvent(key); got != want {
+			t.Errorf("handlesEvent(%q) = %v, want %v", key, got, want)
+		}
+	}
+	if !(WebhookEndpoint{}).handlesEvent("anything") {
+		t.Error("an endpoint without Events should handle every event")
+	}
+
+	mine := &omise.Charge{Metadata: map[string]interface{}{"institution_id": "12"}}
+	other := &omise.Charge{Metadata: map[string]interface{}{"institution_id": "13"}}
+	if !ep.routesCharge(mine) || ep.routesCharge(other) || ep.routesCharge(&omise.Charge{}) {
+		t.Error("routesCharge should only accept charges billed to institution 12")
+	}
+}
@@ -1,31 +1,31 @@
 package handlers
 
 import (
-	"encoding/json"
 	"log"
 
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/gateway"
 	"github.com/gofiber/fiber/v2"
 	omise "github.com/omise/omise-go"
-	"github.com/omise/omise-go/operations"
 )
 
 type WebhookHandler struct {
-	Client   *omise.Client
+	Omise    gateway.OmiseGateway
 	Upserter interface {
 		UpsertTransactionFromCharge(*omise.Charge) error
 	}
 }
 
-func NewWebhookHandler(client *omise.Client, upserter interface {
+func NewWebhookHandler(gw gateway.OmiseGateway, upserter interface {
 	UpsertTransactionFromCharge(*omise.Charge) error
 }) *WebhookHandler {
-	return &WebhookHandler{Client: client, Upserter: upserter}
+	return &WebhookHandler{Omise: gw, Upserter: upserter}
 }
 
 func (h *WebhookHandler) HandleWebhook(c *fiber.Ctx) error {
 	// Only POST
 	if c.Method() != fiber.MethodPost {
-		return c.Status(fiber.StatusMethodNotAllowed).JSON(fiber.Map{"error": "method not allowed"})
+		return apperrors.ErrMethodNotAllowed.WithMessage("method not allowed")
 	}
 
 	// Minimal envelope
@@ -33,36 +33,28 @@ func (h *WebhookHandler) HandleWebhook(c *fiber.Ctx) error {
 		ID string `json:"id"`
 	}
 	if err := c.BodyParser(&envelope); err != nil || envelope.ID == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload: missing event id"})
+		return apperrors.ErrValidation.WithMessage("invalid payload: missing event id")
 	}
 
 	// Verify event by fetching from Omise (recommended)
-	ev := &omise.Event{}
-	if err := h.Client.Do(ev, &operations.RetrieveEvent{EventID: envelope.ID}); err != nil {
+	ev, err := h.Omise.RetrieveEvent(c.UserContext(), envelope.ID)
+	if err != nil {
 		log.Printf("webhook verify failed id=%s err=%v", envelope.ID, err)
 		// Bad request will not be retried by Omise; if you want retries, return 5xx here.
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "event verification failed"})
+		return apperrors.ErrValidation.WithMessage("event verification failed")
 	}
 
 	// Only handle events whose data.object == "charge"
-	var data struct {
-		ID     string `json:"id"`
-		Object string `json:"object"`
-	}
-	raw, err := json.Marshal(ev.Data)
-	if err != nil {
-		log.Printf("webhook marshal ev.Data failed id=%s err=%v", envelope.ID, err)
-		return c.SendStatus(fiber.StatusInternalServerError) // trigger retry
-	}
-	if err := json.Unmarshal(raw, &data); err != nil || data.Object != "charge" || data.ID == "" {
+	chargeID, ok := chargeIDFromEvent(ev)
+	if !ok {
 		// ignore non-charge events
 		return c.SendStatus(fiber.StatusOK)
 	}
 
 	// Retrieve charge (verify status independently)
-	ch := &omise.Charge{}
-	if err := h.Client.Do(ch, &operations.RetrieveCharge{ChargeID: data.ID}); err != nil {
-		log.Printf("webhook retrieve charge failed charge=%s err=%v", data.ID, err)
+	ch, err := h.Omise.RetrieveCharge(c.UserContext(), chargeID)
+	if err != nil {
+		log.Printf("webhook retrieve charge failed charge=%s err=%v", chargeID, err)
 		return c.SendStatus(fiber.StatusInternalServerError) // trigger retry
 	}
 
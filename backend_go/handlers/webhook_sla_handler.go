@@ -0,0 +1,177 @@
+// webhook_sla_handler.go measures webhook latency per provider event (event created -> received ->
+// transaction updated) against the SLA promised to the booking service, and alerts admins on breaches.
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/notify"
+	"github.com/gofiber/fiber/v2"
+	omise "github.com/omise/omise-go"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WebhookSLA is the promised webhook latency; a zero leg is not checked.
+type WebhookSLA struct {
+	Delivery      time.Duration // provider event created -> request received
+	Processing    time.Duration // request received -> transaction updated
+	AlertCooldown time.Duration // minimum gap between breach alerts from this process
+}
+
+// slaAlertState remembers when the last breach alert went out; the zero value is ready to use.
+type slaAlertState struct {
+	mu         sync.Mutex
+	lastSent   time.Time
+	suppressed int
+}
+
+// webhookLatencyStats is the latency distribution of one group of events, in milliseconds.
+type webhookLatencyStats struct {
+	EventKey      string  `json:"event_key,omitempty"`
+	Count         int64   `json:"count"`
+	Breached      int64   `json:"breached"`
+	DeliveryP50   float64 `json:"delivery_p50_ms"`
+	DeliveryP95   float64 `json:"delivery_p95_ms"`
+	DeliveryP99   float64 `json:"delivery_p99_ms"`
+	DeliveryMax   int64   `json:"delivery_max_ms"`
+	ProcessingP50 float64 `json:"processing_p50_ms"`
+	ProcessingP95 float64 `json:"processing_p95_ms"`
+	ProcessingP99 float64 `json:"processing_p99_ms"`
+	ProcessingMax int64   `json:"processing_max_ms"`
+	TotalP95      float64 `json:"total_p95_ms"`
+}
+
+const webhookLatencyColumns = `COUNT(*) AS count,
+	COALESCE(SUM(CASE WHEN breached THEN 1 ELSE 0 END), 0) AS breached,
+	COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY delivery_ms), 0) AS delivery_p50,
+	COALESCE(percentile_cont(0.95) WITHIN GROUP (ORDER BY delivery_ms), 0) AS delivery_p95,
+	COALESCE(percentile_cont(0.99) WITHIN GROUP (ORDER BY delivery_ms), 0) AS delivery_p99,
+	COALESCE(MAX(delivery_ms), 0) AS delivery_max,
+	COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY processing_ms), 0) AS processing_p50,
+	COALESCE(percentile_cont(0.95) WITHIN GROUP (ORDER BY processing_ms), 0) AS processing_p95,
+	COALESCE(percentile_cont(0.99) WITHIN GROUP (ORDER BY processing_ms), 0) AS processing_p99,
+	COALESCE(MAX(processing_ms), 0) AS processing_max,
+	COALESCE(percentile_cont(0.95) WITHIN GROUP (ORDER BY total_ms), 0) AS total_p95`
+
+// GetWebhookLatency reports the webhook latency distribution, overall and per event key.
+// Query: from / to (RFC3339, on processed_at); default is the last 24 hours.
+func (h *PaymentHandler) GetWebhookLatency(c *fiber.Ctx) error {
+	to := time.Now()
+	from := to.Add(-24 * time.Hour)
+	for _, p := range []struct {
+		param string
+		dst   *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		if v := c.Query(p.param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return apperrors.ErrValidation.WithMessagef("%s must be RFC3339 (e.g. 2025-01-31T00:00:00Z)", p.param)
+			}
+			*p.dst = t
+		}
+	}
+
+	window := dbutil.Replica(h.db(c)).Model(&models.WebhookDelivery{}).Where("processed_at >= ? AND processed_at < ?", from, to)
+	var overall webhookLatencyStats
+	if err := window.Session(&gorm.Session{}).Select(webhookLatencyColumns).Scan(&overall).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to compute webhook latency").Wrap(err)
+	}
+	byKey := []webhookLatencyStats{}
+	if err := window.Session(&gorm.Session{}).Select("event_key, " + webhookLatencyColumns).
+		Group("event_key").Order("event_key").Scan(&byKey).Error; err != nil {
+		return apperrors.ErrInternal.WithMessage("Failed to compute webhook latency").Wrap(err)
+	}
+
+	return c.JSON(fiber.Map{
+		"from":    from,
+		"to":      to,
+		"sla":     fiber.Map{"delivery_ms": h.WebhookSLA.Delivery.Milliseconds(), "processing_ms": h.WebhookSLA.Processing.Milliseconds()},
+		"overall": overall,
+		"events":  byKey,
+	})
+}
+
+// recordWebhookLatency stores the latency of a processed event and alerts on an SLA breach. It runs
+// in the background after the webhook has been answered.
+func (h *PaymentHandler) recordWebhookLatency(ctx context.Context, ev *omise.Event, chargeID string, receivedAt, processedAt time.Time) {
+	d := newWebhookDelivery(ev, chargeID, receivedAt, processedAt)
+	breaches := h.WebhookSLA.breaches(d)
+	d.Breached = len(breaches) > 0
+
+	// Retries of an already processed event keep the first measurement.
+	res := h.DB.WithContext(ctx).Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "event_id"}}, DoNothing: true}).Create(&d)
+	if res.Error != nil {
+		log.Printf("webhook sla: record failed event=%s err=%v", d.EventID, res.Error)
+		return
+	}
+	if res.RowsAffected == 0 || !d.Breached {
+		return
+	}
+	log.Printf("webhook sla: breach event=%s key=%s charge=%s %s", d.EventID, d.EventKey, d.ChargeID, strings.Join(breaches, "; "))
+
+	suppressed, ok := h.slaAlerts.claim(processedAt, h.WebhookSLA.AlertCooldown)
+	if !ok {
+		return
+	}
+	body := fmt.Sprintf("Event %s (%s) for charge %s: %s.\nEvent created %s, received %s, processed %s.",
+		d.EventID, d.EventKey, d.ChargeID, strings.Join(breaches, "; "),
+		d.EventCreatedAt.In(bangkok).Format(time.RFC3339), d.ReceivedAt.In(bangkok).Format(time.RFC3339),
+		d.ProcessedAt.In(bangkok).Format(time.RFC3339))
+	if suppressed > 0 {
+		body += fmt.Sprintf("\n%d more breach(es) since the last alert were not alerted individually.", suppressed)
+	}
+	h.sendAdminAlert(notify.Message{Subject: "Webhook latency SLA breached", Body: body})
+}
+
+// (helper for recordWebhookLatency) the three latency legs; clock skew never yields negative values.
+func newWebhookDelivery(ev *omise.Event, chargeID string, receivedAt, processedAt time.Time) models.WebhookDelivery {
+	created := ev.CreatedAt
+	if created.IsZero() || created.After(receivedAt) {
+		created = receivedAt
+	}
+	return models.WebhookDelivery{
+		EventID:        ev.ID,
+		EventKey:       ev.Key,
+		ChargeID:       chargeID,
+		EventCreatedAt: created,
+		ReceivedAt:     receivedAt,
+		ProcessedAt:    processedAt,
+		DeliveryMs:     receivedAt.Sub(created).Milliseconds(),
+		ProcessingMs:   processedAt.Sub(receivedAt).Milliseconds(),
+		TotalMs:        processedAt.Sub(created).Milliseconds(),
+	}
+}
+
+// breaches describes each leg of d that exceeded its SLA.
+func (s WebhookSLA) breaches(d models.WebhookDelivery) []string {
+	var out []string
+	if s.Delivery > 0 && d.DeliveryMs > s.Delivery.Milliseconds() {
+		out = append(out, fmt.Sprintf("delivery took %s (SLA %s)", time.Duration(d.DeliveryMs)*time.Millisecond, s.Delivery))
+	}
+	if s.Processing > 0 && d.ProcessingMs > s.Processing.Milliseconds() {
+		out = append(out, fmt.Sprintf("processing took %s (SLA %s)", time.Duration(d.ProcessingMs)*time.Millisecond, s.Processing))
+	}
+	return out
+}
+
+// claim reports whether an alert may be sent now, and how many breaches were held back since the last one.
+func (s *slaAlertState) claim(now time.Time, cooldown time.Duration) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.lastSent.IsZero() && now.Sub(s.lastSent) < cooldown {
+		s.suppressed++
+		return 0, false
+	}
+	n := s.suppressed
+	s.lastSent, s.suppressed = now, 0
+	return n, true
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	omise "github.com/omise/omise-go"
+)
+
+func TestWebhookSLABreaches(t *testing.T) {
+	created := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	ev := &omise.Event{Base: omise.Base{ID: "evnt_1", CreatedAt: created}, Key: "charge.complete"}
+	sla := WebhookSLA{Delivery: time.Minute, Processing: 5 * time.Second}
+
+	d := newWebhookDelivery(ev, "chrg_1", created.Add(30*time.Second), created.Add(32*time.Second))
+	if d.DeliveryMs != 30000 || d.ProcessingMs != 2000 || d.TotalMs != 32000 {
+		t.Fatalf("latency = %d/%d/%d ms, want 30000/2000/32000", d.DeliveryMs, d.ProcessingMs, d.TotalMs)
+	}
+	if got := sla.breaches(d); len(got) != 0 {
+		t.Errorf("breaches = %v, want none", got)
+	}
+
+	d = newWebhookDelivery(ev, "chrg_1", created.Add(2*time.Minute), created.Add(2*time.Minute+6*time.Second))
+	if got := sla.breaches(d); len(got) != 2 {
+		t.Errorf("breaches = %v, want delivery and processing", got)
+	}
+
+	// A provider clock ahead of ours counts as zero delivery lag, never negative.
+	d = newWebhookDelivery(ev, "chrg_1", created.Add(-time.Second), created)
+	if d.DeliveryMs != 0 {
+		t.Errorf("DeliveryMs = %d, want 0", d.DeliveryMs)
+	}
+}
+
+func TestSLAAlertCooldown(t *testing.T) {
+	var s slaAlertState
+	now := time.Now()
+	if _, ok := s.claim(now, time.Minute); !ok {
+		t.Fatal("first alert should be sent")
+	}
+	if _, ok := s.claim(now.Add(10*time.Second), time.Minute); ok {
+		t.Fatal("alert inside cooldown should be held back")
+	}
+	n, ok := s.claim(now.Add(2*time.Minute), time.Minute)
+	if !ok || n != 1 {
+		t.Errorf("claim after cooldown = (%d, %v), want (1, true)", n, ok)
+	}
+}
@@ -0,0 +1,149 @@
+// webhook_tail_handler.go streams processed webhooks to developers over Server-Sent Events
+// (GET /admin/webhooks/tail, used by `tutoriumctl webhooks tail`).
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Webhook tail results.
+const (
+	webhookTailProcessed = "processed" // charge recorded
+	webhookTailIgnored   = "ignored"   // acknowledged without action (not a charge)
+	webhookTailFailed    = "failed"    // 5xx, Omise will retry
+	webhookTailRejected  = "rejected"  // malformed payload
+)
+
+const (
+	// webhookTailBuffer is how many events a slow subscriber may lag before events are dropped for it.
+	webhookTailBuffer = 64
+	// webhookTailHeartbeat keeps idle streams (and proxies in between) from timing out.
+	webhookTailHeartbeat = 15 * time.Second
+)
+
+// webhookTailEvent summarizes one webhook delivery as handled by HandleWebhook.
+type webhookTailEvent struct {
+	ReceivedAt   time.Time `json:"received_at"`
+	Endpoint     string    `json:"endpoint,omitempty"` // WebhookEndpoint name
+	Object       string    `json:"object,omitempty"`   // "event" or "charge"
+	ObjectID     string    `json:"object_id,omitempty"`
+	EventKey     string    `json:"event_key,omitempty"`
+	ChargeID     string    `json:"charge_id,omitempty"`
+	Status       string    `json:"status,omitempty"`
+	AmountSatang int64     `json:"amount_satang,omitempty"`
+	Currency     string    `json:"currency,omitempty"`
+	Result       string    `json:"result"`
+	Error        string    `json:"error,omitempty"`
+	DurationMs   int64     `json:"duration_ms"`
+}
+
+// webhookFeed fans webhook events out to tail subscribers; the zero value is ready to use.
+type webhookFeed struct {
+	mu     sync.Mutex
+	subs   map[chan webhookTailEvent]struct{}
+	closed bool
+}
+
+// publish hands ev to every subscriber without blocking; a full subscriber misses it.
+func (f *webhookFeed) publish(ev webhookTailEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for ch := range f.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// subscribe registers a subscriber. The channel is closed by cancel or by close; ok is false once the
+// feed has been closed for shutdown.
+func (f *webhookFeed) subscribe() (events <-chan webhookTailEvent, cancel func(), ok bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil, nil, false
+	}
+	if f.subs == nil {
+		f.subs = make(map[chan webhookTailEvent]struct{})
+	}
+	ch := make(chan webhookTailEvent, webhookTailBuffer)
+	f.subs[ch] = struct{}{}
+	return ch, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if _, ok := f.subs[ch]; ok {
+			delete(f.subs, ch)
+			close(ch)
+		}
+	}, true
+}
+
+// close ends every stream and refuses new subscribers.
+func (f *webhookFeed) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	for ch := range f.subs {
+		delete(f.subs, ch)
+		close(ch)
+	}
+}
+
+// CloseStreams ends open webhook tail streams and gRPC WatchTransactions streams. Call it before
+// shutting the servers down, which otherwise wait for the streams until their deadline.
+func (h *PaymentHandler) CloseStreams() {
+	h.webhookTail.close()
+	h.Payments.Updates.Close()
+}
+
+// TailWebhooks streams every webhook handled from now on as SSE "webhook" events (JSON
+// webhookTailEvent), with a comment heartbeat while idle. Nothing is replayed; events are not stored.
+func (h *PaymentHandler) TailWebhooks(c *fiber.Ctx) error {
+	events, cancel, ok := h.webhookTail.subscribe()
+	if !ok {
+		return c.SendStatus(fiber.StatusServiceUnavailable)
+	}
+	conn := c.Context().Conn()
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no") // nginx: do not buffer the stream
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+		heartbeat := time.NewTicker(webhookTailHeartbeat)
+		defer heartbeat.Stop()
+
+		fmt.Fprint(w, ": tailing webhooks\n\n")
+		for {
+			// The server's write timeout covers the whole response; keep extending it while streaming.
+			_ = conn.SetWriteDeadline(time.Now().Add(2 * webhookTailHeartbeat))
+			if err := w.Flush(); err != nil {
+				return // client went away
+			}
+			select {
+			case ev, open := <-events:
+				if !open {
+					fmt.Fprint(w, "event: shutdown\ndata: {}\n\n")
+					_ = w.Flush()
+					return
+				}
+				raw, err := json.Marshal(ev)
+				if err != nil {
+					continue
+				}
+				fmt.Fprintf(w, "event: webhook\ndata: %s\n\n", raw)
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+			}
+		}
+	})
+	return nil
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import "testing"
+
+func TestWebhookFeed(t *testing.T) {
+	var f webhookFeed
+	f.publish(webhookTailEvent{Result: webhookTailIgnored}) // no subscribers: dropped
+
+	a, cancelA, ok := f.subscribe()
+	if !ok {
+		t.Fatal("subscribe on an open feed failed")
+	}
+	b, _, _ := f.subscribe()
+	f.publish(webhookTailEvent{ChargeID: "chrg_1", Result: webhookTailProcessed})
+	if ev := <-a; ev.ChargeID != "chrg_1" {
+		t.Errorf("subscriber a got %+v", ev)
+	}
+	if ev := <-b; ev.ChargeID != "chrg_1" {
+		t.Errorf("subscriber b got %+v", ev)
+	}
+
+	// A subscriber that stops reading loses events instead of blocking webhooks.
+	for i := 0; i < webhookTailBuffer+10; i++ {
+		f.publish(webhookTailEvent{})
+	}
+	if len(a) != webhookTailBuffer {
+		t.Errorf("buffered = %d, want %d", len(a), webhookTailBuffer)
+	}
+
+	cancelA()
+	cancelA() // idempotent
+	f.close()
+	for range b { // drains the buffer, then ends: close closed the channel
+	}
+	if _, _, ok := f.subscribe(); ok {
+		t.Error("subscribe after close should fail")
+	}
+}
@@ -0,0 +1,147 @@
+// Package i18n translates what the student-facing app shows as is: error messages, by their stable
+// apperrors code, and payment status labels. English is the default and the language the messages are
+// written in; Thai is the other supported language, picked from the request's Accept-Language.
+package i18n
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Supported languages (BCP 47 primary subtags).
+const (
+	English = "en"
+	Thai    = "th"
+)
+
+// Negotiate picks the response language from an Accept-Language header ("th-TH,th;q=0.9,en;q=0.8"):
+// the supported language with the highest q, English when none is.
+func Negotiate(acceptLanguage string) string {
+	type choice struct {
+		lang string
+		q    float64
+	}
+	var choices []choice
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		primary, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if (primary == English || primary == Thai) && q > 0 {
+			choices = append(choices, choice{primary, q})
+		}
+	}
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].q > choices[j].q })
+	if len(choices) == 0 {
+		return English
+	}
+	return choices[0].lang
+}
+
+// Message is the lang message for an error code, if there is one. English has none: the handlers'
+// own messages are English and more specific.
+func Message(lang, code string) (string, bool) {
+	if lang != Thai {
+		return "", false
+	}
+	msg, ok := thaiMessages[code]
+	return msg, ok
+}
+
+// StatusLabel is the label of a transaction or charge status in lang; the status itself when it has
+// none.
+func StatusLabel(lang, status string) string {
+	labels := englishStatusLabels
+	if lang == Thai {
+		labels = thaiStatusLabels
+	}
+	if label, ok := labels[status]; ok {
+		return label
+	}
+	return status
+}
+
+var englishStatusLabels = map[string]string{
+	"pending":    "Pending",
+	"successful": "Successful",
+	"failed":     "Failed",
+	"expired":    "Expired",
+	"canceled":   "Canceled",
+	"reversed":   "Reversed",
+	"refunded":   "Refunded",
+}
+
+var thaiStatusLabels = map[string]string{
+	"pending":    "รอชำระเงิน",
+	"successful": "ชำระเงินสำเร็จ",
+	"failed":     "ชำระเงินไม่สำเร็จ",
+	"expired":    "หมดเวลาชำระเงิน",
+	"canceled":   "ยกเลิกแล้ว",
+	"reversed":   "ยกเลิกรายการแล้ว",
+	"refunded":   "คืนเงินแล้ว",
+}
+
+// thaiMessages covers the codes of the payment flows students go through; codes only admins see keep
+// their English message.
+var thaiMessages = map[string]string{
+	// apperrors base errors
+	"validation_failed":    "ข้อมูลไม่ถูกต้อง กรุณาตรวจสอบอีกครั้ง",
+	"bad_request":          "คำขอไม่ถูกต้อง",
+	"invalid_body":         "คำขอไม่ถูกต้อง",
+	"unauthorized":         "กรุณาเข้าสู่ระบบ",
+	"forbidden":            "คุณไม่มีสิทธิ์ทำรายการนี้",
+	"not_found":            "ไม่พบข้อมูลที่ต้องการ",
+	"conflict":             "ไม่สามารถทำรายการได้ในขณะนี้",
+	"charge_failed":        "การชำระเงินถูกปฏิเสธ กรุณาลองใช้วิธีชำระเงินอื่น",
+	"limit_exceeded":       "ทำรายการเกินกำหนด กรุณาลองใหม่ภายหลัง",
+	"provider_unavailable": "ระบบชำระเงินขัดข้องชั่วคราว กรุณาลองใหม่อีกครั้ง",
+	"unavailable":          "ระบบไม่พร้อมให้บริการชั่วคราว กรุณาลองใหม่อีกครั้ง",
+	"overloaded":           "ระบบมีผู้ใช้งานจำนวนมาก กรุณาลองใหม่อีกครั้ง",
+	"internal_error":       "เกิดข้อผิดพลาดในระบบ กรุณาลองใหม่อีกครั้ง",
+
+	// charges
+	"payer_blocked":            "ไม่สามารถรับชำระเงินจากบัญชี บัตร หรืออีเมลนี้ได้",
+	"country_not_allowed":      "ไม่สามารถรับชำระเงินจากประเทศของคุณได้",
+	"risk_denied":              "ไม่สามารถรับชำระเงินรายการนี้ได้ กรุณาลองใช้วิธีชำระเงินอื่นหรือติดต่อฝ่ายบริการลูกค้า",
+	"duplicate_charge":         "คุณเพิ่งทำรายการยอดเดียวกันนี้ไป กรุณาตรวจสอบประวัติการชำระเงินก่อนทำรายการซ้ำ",
+	"authentication_required":  "บัตรนี้ต้องยืนยันตัวตนด้วย 3-D Secure",
+	"currency_not_supported":   "ไม่รองรับสกุลเงินนี้",
+	"unsupported_payment_type": "ไม่รองรับวิธีชำระเงินนี้",
+	"invalid_charge_request":   "ข้อมูลการชำระเงินไม่ถูกต้อง",
+	"raw_card_disabled":        "ไม่รองรับการส่งข้อมูลบัตรโดยตรง กรุณาชำระผ่านหน้าชำระเงิน",
+	"return_uri_not_allowed":   "ไม่อนุญาตให้ใช้ที่อยู่สำหรับกลับหลังชำระเงินนี้",
+	"insufficient_balance":     "ยอดเงินคงเหลือไม่เพียงพอ",
+	"charge_not_pending":       "รายการนี้ไม่อยู่ในสถานะรอชำระเงินแล้ว",
+	"charge_not_payable":       "ไม่สามารถชำระรายการนี้ได้แล้ว",
+	"fx_unavailable":           "ไม่สามารถแปลงสกุลเงินได้ในขณะนี้ กรุณาลองใหม่อีกครั้ง",
+	"charge_amount_limit":      "ยอดชำระต่อครั้งเกินวงเงินที่กำหนด",
+	"charge_rate_limit":        "ทำรายการชำระเงินบ่อยเกินไป กรุณาลองใหม่ภายหลัง",
+	"daily_top_up_limit":       "ยอดเติมเงินวันนี้ครบวงเงินแล้ว",
+
+	// orders, coupons, payment links and intents
+	"order_not_found":            "ไม่พบคำสั่งซื้อ",
+	"order_not_payable":          "ไม่สามารถชำระคำสั่งซื้อนี้ได้แล้ว",
+	"order_amount_mismatch":      "ยอดชำระไม่ตรงกับคำสั่งซื้อ",
+	"order_user_mismatch":        "คำสั่งซื้อนี้เป็นของผู้ใช้อื่น",
+	"coupon_not_found":           "ไม่พบคูปองนี้",
+	"coupon_expired":             "คูปองหมดอายุแล้ว",
+	"coupon_inactive":            "คูปองนี้ใช้ไม่ได้แล้ว",
+	"coupon_limit_reached":       "คูปองนี้ถูกใช้ครบจำนวนแล้ว",
+	"coupon_requires_order":      "คูปองนี้ใช้ได้กับคำสั่งซื้อเท่านั้น",
+	"coupon_currency_mismatch":   "คูปองนี้ใช้กับสกุลเงินนี้ไม่ได้",
+	"coupon_discount_too_large":  "ส่วนลดของคูปองเกินยอดชำระ",
+	"payment_link_not_found":     "ไม่พบลิงก์ชำระเงิน",
+	"payment_link_not_payable":   "ลิงก์ชำระเงินนี้ใช้ไม่ได้แล้ว",
+	"payment_link_user_mismatch": "ลิงก์ชำระเงินนี้เป็นของผู้ใช้อื่น",
+	"intent_not_found":           "ไม่พบรายการชำระเงิน",
+	"intent_expired":             "รายการชำระเงินหมดอายุแล้ว",
+	"intent_processing":          "กำลังดำเนินการชำระเงิน กรุณารอสักครู่",
+	"personal_data_erased":       "ข้อมูลส่วนบุคคลของบัญชีนี้ถูกลบแล้ว",
+}
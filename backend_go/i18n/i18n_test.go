@@ -0,0 +1,47 @@
+package i18n
+
+import "testing"
+
+func TestNegotiate(t *testing.T) {
+	for header, want := range map[string]string{
+		"":                        English,
+		"th":                      Thai,
+		"th-TH,th;q=0.9,en;q=0.8": Thai,
+		"en-US,en;q=0.9,th;q=0.8": English,
+		"ja,th;q=0.5":             Thai,
+		"fr-FR,de;q=0.7":          English,
+		"th;q=0,en;q=0.1":         English,
+		"en;q=0.3, TH-th;q=0.7":   Thai,
+		"th;q=bogus,en;q=0.2":     English,
+	} {
+		if got := Negotiate(header); got != want {
+			t.Errorf("Negotiate(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+	if msg, ok := Message(Thai, "insufficient_balance"); !ok || msg != "ยอดเงินคงเหลือไม่เพียงพอ" {
+		t.Errorf("Thai insufficient_balance = %q, %v", msg, ok)
+	}
+	if _, ok := Message(English, "insufficient_balance"); ok {
+		t.Error("English should keep the handler's message")
+	}
+	if _, ok := Message(Thai, "ledger_import_invalid"); ok {
+		t.Error("an untranslated code should keep the handler's message")
+	}
+}
+
+func TestStatusLabel(t *testing.T) {
+	for _, tc := range []struct{ lang, status, want string }{
+		{English, "successful", "Successful"},
+		{Thai, "successful", "ชำระเงินสำเร็จ"},
+		{Thai, "pending", "รอชำระเงิน"},
+		{"", "expired", "Expired"},
+		{Thai, "on_hold", "on_hold"},
+	} {
+		if got := StatusLabel(tc.lang, tc.status); got != tc.want {
+			t.Errorf("StatusLabel(%q, %q) = %q, want %q", tc.lang, tc.status, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package jobs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule yields the next run time strictly after a given time.
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+// Every runs a job at a fixed interval after the previous run finished.
+type Every time.Duration
+
+func (d Every) Next(after time.Time) time.Time { return after.Add(time.Duration(d)) }
+
+func (d Every) String() string { return "every " + time.Duration(d).String() }
+
+// CronSchedule is a standard five-field cron expression, "minute hour day-of-month month
+// day-of-week", evaluated in Location. Fields take *, numbers, ranges (1-5), lists (1,15) and steps
+// (*/15, 8-18/2); day-of-week is 0-6 from Sunday (7 is also Sunday). As in cron, when both day fields
+// are restricted a day matches either.
+type CronSchedule struct {
+	Expr     string
+	Location *time.Location
+
+	minute, hour, dom, month, dow uint64 // bit i set = value i allowed
+	domStar, dowStar              bool
+}
+
+// ParseCron parses expr for loc (nil is UTC).
+func ParseCron(expr string, loc *time.Location) (*CronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron %q: want 5 fields (minute hour day month weekday), got %d", expr, len(fields))
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	s := &CronSchedule{Expr: expr, Location: loc, domStar: fields[2] == "*", dowStar: fields[4] == "*"}
+	var err error
+	for _, f := range []struct {
+		dst      *uint64
+		src      string
+		min, max int
+	}{
+		{&s.minute, fields[0], 0, 59},
+		{&s.hour, fields[1], 0, 23},
+		{&s.dom, fields[2], 1, 31},
+		{&s.month, fields[3], 1, 12},
+		{&s.dow, fields[4], 0, 7},
+	} {
+		if *f.dst, err = parseCronField(f.src, f.min, f.max); err != nil {
+			return nil, fmt.Errorf("cron %q: %w", expr, err)
+		}
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1 // 7 is Sunday too
+	}
+	return s, nil
+}
+
+// (helper for ParseCron) the bit set of values one field allows.
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("bad step in %q", part)
+			}
+			step = n
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			loStr, hiStr, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(loStr); err != nil {
+				return 0, fmt.Errorf("bad value in %q", part)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(hiStr); err != nil {
+					return 0, fmt.Errorf("bad range in %q", part)
+				}
+			} else if hasStep {
+				hi = max // "5/15" means from 5 every 15
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is outside %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Next returns the first matching minute after after, or the zero time if none within five years
+// (e.g. "0 0 31 2 *").
+func (s *CronSchedule) Next(after time.Time) time.Time {
+	t := after.In(s.Location).Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.Location)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.Location)
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.Location)
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// (helper for Next) cron's day rule: both day fields restricted means either may match.
+func (s *CronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func (s *CronSchedule) String() string { return s.Expr }
@@ -0,0 +1,51 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	bkk := time.FixedZone("ICT", 7*60*60)
+	at := func(s string) time.Time {
+		v, err := time.ParseInLocation("2006-01-02 15:04", s, bkk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	cases := []struct {
+		expr, after, want string
+	}{
+		{"*/15 * * * *", "2026-03-10 10:07", "2026-03-10 10:15"},
+		{"*/15 * * * *", "2026-03-10 10:15", "2026-03-10 10:30"},
+		{"0 2 1 * *", "2026-03-10 10:07", "2026-04-01 02:00"},
+		{"30 4 * * *", "2026-12-31 05:00", "2027-01-01 04:30"},
+		{"0 9 * * 1-5", "2026-03-13 09:00", "2026-03-16 09:00"}, // Friday -> Monday
+		{"0 0 13 * 5", "2026-03-01 00:00", "2026-03-06 00:00"},  // either Friday or the 13th
+		{"0 8-18/4 * * *", "2026-03-10 12:30", "2026-03-10 16:00"},
+		{"5,35 * * 2 7", "2026-01-01 00:00", "2026-02-01 00:05"}, // 7 is Sunday
+	}
+	for _, tc := range cases {
+		s, err := ParseCron(tc.expr, bkk)
+		if err != nil {
+			t.Fatalf("ParseCron(%q): %v", tc.expr, err)
+		}
+		if got := s.Next(at(tc.after)); !got.Equal(at(tc.want)) {
+			t.Errorf("%q after %s = %s, want %s", tc.expr, tc.after, got.Format("2006-01-02 15:04 Mon"), tc.want)
+		}
+	}
+
+	never, _ := ParseCron("0 0 31 2 *", bkk)
+	if got := never.Next(at("2026-01-01 00:00")); !got.IsZero() {
+		t.Errorf("Feb 31 = %s, want never", got)
+	}
+}
+
+func TestParseCronRejects(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := ParseCron(expr, nil); err == nil {
+			t.Errorf("ParseCron(%q) accepted", expr)
+		}
+	}
+}
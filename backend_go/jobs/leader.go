@@ -0,0 +1,131 @@
+package jobs
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// leaderMetrics is published at /debug/vars as "jobs_leader": this replica's holder id, whether it
+// leads, and the lease's current holder and expiry as last seen.
+var leaderMetrics = expvar.NewMap("jobs_leader")
+
+// LeaseStore grants a named lease to one holder at a time (repository.NewJobLeaseRepository).
+type LeaseStore interface {
+	// AcquireLease takes or renews name for holder until ttl from now, unless another holder's lease
+	// is still valid. It returns the lease's holder and expiry after the attempt, whoever won.
+	AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (current string, expiresAt time.Time, err error)
+	// ReleaseLease ends holder's lease on name, if it still holds it.
+	ReleaseLease(ctx context.Context, name, holder string) error
+}
+
+// Elector elects one replica to run the scheduled jobs through a lease in a LeaseStore: every replica
+// campaigns every TTL/3, the holder renews, and the others take over once the lease lapses (at most
+// TTL after the leader dies, at once when it stops cleanly). A job already running when its replica
+// loses the lease finishes, so jobs must still tolerate an occasional overlap.
+type Elector struct {
+	Store  LeaseStore
+	Name   string        // lease name, e.g. "jobs"
+	Holder string        // this replica, unique among replicas (see HolderID)
+	TTL    time.Duration // lease length
+
+	mu    sync.Mutex
+	until time.Time // this replica leads until then; zero when it does not
+}
+
+// Leader reports whether this replica holds the lease. The lease is counted from before the attempt
+// that won it, so a replica stops leading no later than the store expires its lease.
+func (e *Elector) Leader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return time.Now().Before(e.until)
+}
+
+// HolderID identifies this process among replicas: host name and pid.
+func HolderID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// (helper for Scheduler.Start) campaign until ctx ends; Scheduler.Stop releases the lease once the
+// running jobs have returned.
+func (e *Elector) campaign(ctx context.Context) {
+	leaderMetrics.Set("self", expvarString(e.Holder))
+	for {
+		e.attempt(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(e.TTL / 3):
+		}
+	}
+}
+
+// (helper for campaign) one acquire or renewal; a store error drops leadership when the lease runs out.
+func (e *Elector) attempt(ctx context.Context) {
+	start := time.Now()
+	actx, cancel := context.WithTimeout(ctx, e.TTL/3)
+	defer cancel()
+	holder, expiresAt, err := e.Store.AcquireLease(actx, e.Name, e.Holder, e.TTL)
+	if err != nil {
+		if ctx.Err() == nil {
+			leaderMetrics.Add("errors", 1)
+			log.Printf("jobs: lease %s: acquire failed: %v", e.Name, err)
+		}
+		return
+	}
+
+	e.mu.Lock()
+	was := time.Now().Before(e.until)
+	if holder == e.Holder {
+		e.until = start.Add(e.TTL)
+	} else {
+		e.until = time.Time{}
+	}
+	is := !e.until.IsZero()
+	e.mu.Unlock()
+
+	switch {
+	case is && !was:
+		log.Printf("jobs: lease %s: %s is now the leader", e.Name, e.Holder)
+	case !is && was:
+		log.Printf("jobs: lease %s: leadership lost to %s", e.Name, holder)
+	}
+	leaderMetrics.Set("holder", expvarString(holder))
+	setTime(leaderMetrics, "lease_expires_unix", expiresAt)
+	leading := new(expvar.Int)
+	if is {
+		leading.Set(1)
+	}
+	leaderMetrics.Set("leader", leading)
+}
+
+// (helper for Scheduler.Stop) hand the lease over now instead of letting it lapse.
+func (e *Elector) release() {
+	e.mu.Lock()
+	was := time.Now().Before(e.until)
+	e.until = time.Time{}
+	e.mu.Unlock()
+	leaderMetrics.Set("leader", new(expvar.Int))
+	if !was {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := e.Store.ReleaseLease(ctx, e.Name, e.Holder); err != nil {
+		log.Printf("jobs: lease %s: release failed: %v", e.Name, err)
+	}
+}
+
+func expvarString(s string) *expvar.String {
+	v := new(expvar.String)
+	v.Set(s)
+	return v
+}
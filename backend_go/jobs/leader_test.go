@@ -0,0 +1,84 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// memLeases is a LeaseStore in memory.
+type memLeases struct {
+	mu      sync.Mutex
+	holder  string
+	expires time.Time
+}
+
+func (m *memLeases) AcquireLease(_ context.Context, _, holder string, ttl time.Duration) (string, time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.holder == holder || time.Now().After(m.expires) {
+		m.holder, m.expires = holder, time.Now().Add(ttl)
+	}
+	return m.holder, m.expires, nil
+}
+
+func (m *memLeases) ReleaseLease(_ context.Context, _, holder string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.holder == holder {
+		m.expires = time.Now()
+	}
+	return nil
+}
+
+func TestElectorRunsJobsOnOneReplica(t *testing.T) {
+	store := &memLeases{}
+	var runs [2]atomic.Int64
+	schedulers := make([]*Scheduler, 2)
+	for i := range schedulers {
+		s := New()
+		s.Elector = &Elector{Store: store, Name: "jobs", Holder: []string{"a", "b"}[i], TTL: time.Hour}
+		s.Add(Job{Name: "test_elected", Schedule: Every(5 * time.Millisecond), Run: func(ctx context.Context) error {
+			runs[i].Add(1)
+			return nil
+		}})
+		s.Start()
+		schedulers[i] = s
+		if i == 0 {
+			waitFor(t, "a to lead", s.Elector.Leader)
+		}
+	}
+
+	waitFor(t, "a to run the job", func() bool { return runs[0].Load() >= 3 })
+	if runs[1].Load() != 0 || schedulers[1].Elector.Leader() {
+		t.Fatalf("b ran %d times (leader %v) while a held the lease", runs[1].Load(), schedulers[1].Elector.Leader())
+	}
+
+	// a stops cleanly and hands over; b takes the lease on its next attempt.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := schedulers[0].Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	schedulers[1].Elector.attempt(ctx)
+	waitFor(t, "b to run the job", func() bool { return runs[1].Load() >= 1 })
+	if err := schedulers[1].Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if got := leaderMetrics.Get("holder").String(); got != `"b"` {
+		t.Errorf("jobs_leader holder = %s, want \"b\"", got)
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,182 @@
+// Package jobs runs the periodic background jobs (expiring stale charges, reconciling with Omise,
+// payout statements, pruning raw payloads) on cron-style schedules. Each job runs in its own loop and
+// never overlaps itself; runs are logged and counted in the "jobs" expvar map at /debug/vars.
+//
+// With an Elector only the replica holding its lease runs jobs; the others skip their runs. Jobs must
+// still be safe to run on two replicas at once (a run outlives a lost lease, and schedulers without an
+// Elector run everywhere): claim work in the database (unique keys, row locks).
+package jobs
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// metrics is published at /debug/vars as "jobs": one map per job (see jobMetrics).
+var metrics = expvar.NewMap("jobs")
+
+// Job is one periodic task.
+type Job struct {
+	Name     string
+	Schedule Schedule
+	// Run does one pass. ctx is cancelled when the scheduler stops (and after Timeout); return early
+	// when it is, leaving the rest for the next run.
+	Run func(ctx context.Context) error
+	// Timeout bounds a single run; 0 is unbounded.
+	Timeout time.Duration
+}
+
+// Scheduler runs Jobs until Stop. Add every job, and set Elector, before Start.
+type Scheduler struct {
+	// Elector, when set, limits runs to the replica that holds its lease; nil runs jobs here always.
+	Elector *Elector
+
+	jobs     []Job
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	election sync.WaitGroup
+}
+
+func New() *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{ctx: ctx, cancel: cancel}
+}
+
+// Add registers j; it panics on a job without a name, schedule or Run, which is a programming error.
+func (s *Scheduler) Add(j Job) {
+	if j.Name == "" || j.Schedule == nil || j.Run == nil {
+		panic(fmt.Sprintf("jobs: incomplete job %q", j.Name))
+	}
+	s.jobs = append(s.jobs, j)
+}
+
+// Start launches one loop per job, and the Elector's campaign.
+func (s *Scheduler) Start() {
+	if s.Elector != nil {
+		s.election.Add(1)
+		go func() {
+			defer s.election.Done()
+			s.Elector.campaign(s.ctx)
+		}()
+	}
+	for _, j := range s.jobs {
+		m := jobMetrics(j.Name)
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.loop(j, m)
+		}()
+		log.Printf("jobs: job=%s scheduled (%v)", j.Name, j.Schedule)
+	}
+}
+
+// Stop cancels running jobs and waits for them to return, or for ctx to expire. The Elector's lease
+// is released once they have, so another replica takes over without waiting for it to lapse.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.cancel()
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		s.election.Wait()
+		if s.Elector != nil {
+			s.Elector.release()
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// (helper for Start) sleep until each scheduled time and run j; runs missed while j was still running
+// are skipped, not queued.
+func (s *Scheduler) loop(j Job, m *expvar.Map) {
+	for {
+		now := time.Now()
+		next := j.Schedule.Next(now)
+		if next.IsZero() {
+			log.Printf("jobs: job=%s has no future run, stopping", j.Name)
+			return
+		}
+		setTime(m, "next_run_unix", next)
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			s.run(j, m)
+		}
+	}
+}
+
+// (helper for loop) one run with its timeout, logs and metrics; a panic counts as a failure. Runs on a
+// replica that is not the leader are skipped and counted.
+func (s *Scheduler) run(j Job, m *expvar.Map) {
+	if s.Elector != nil && !s.Elector.Leader() {
+		m.Add("skipped_not_leader", 1)
+		return
+	}
+	ctx, cancel := s.ctx, context.CancelFunc(func() {})
+	if j.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, j.Timeout)
+	}
+	defer cancel()
+
+	start := time.Now()
+	m.Add("running", 1)
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return j.Run(ctx)
+	}()
+	m.Add("running", -1)
+	took := time.Since(start)
+
+	m.Add("runs", 1)
+	setTime(m, "last_run_unix", start)
+	duration := new(expvar.Int)
+	duration.Set(took.Milliseconds())
+	m.Set("last_duration_ms", duration)
+	lastErr := new(expvar.String)
+	switch {
+	case err == nil:
+		setTime(m, "last_success_unix", start)
+		log.Printf("jobs: job=%s ok took=%s", j.Name, took.Round(time.Millisecond))
+	case errors.Is(err, context.Canceled) && s.ctx.Err() != nil:
+		log.Printf("jobs: job=%s interrupted by shutdown took=%s", j.Name, took.Round(time.Millisecond))
+	default:
+		m.Add("failures", 1)
+		lastErr.Set(err.Error())
+		log.Printf("jobs: job=%s failed took=%s err=%v", j.Name, took.Round(time.Millisecond), err)
+	}
+	m.Set("last_error", lastErr)
+}
+
+// (helper for Start) the job's expvar map, created on first use (tests start several schedulers).
+func jobMetrics(name string) *expvar.Map {
+	if m, ok := metrics.Get(name).(*expvar.Map); ok {
+		return m
+	}
+	m := new(expvar.Map).Init()
+	metrics.Set(name, m)
+	return m
+}
+
+func setTime(m *expvar.Map, key string, t time.Time) {
+	v := new(expvar.Int)
+	v.Set(t.Unix())
+	m.Set(key, v)
+}
@@ -0,0 +1,81 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSchedulerRunsAndStops(t *testing.T) {
+	var runs, failures atomic.Int64
+	blocked := make(chan struct{}, 1)
+	s := New()
+	s.Add(Job{Name: "test_ok", Schedule: Every(10 * time.Millisecond), Run: func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}})
+	s.Add(Job{Name: "test_fail", Schedule: Every(10 * time.Millisecond), Run: func(ctx context.Context) error {
+		failures.Add(1)
+		panic("boom")
+	}})
+	s.Add(Job{Name: "test_block", Schedule: Every(time.Millisecond), Run: func(ctx context.Context) error {
+		select {
+		case blocked <- struct{}{}:
+		default:
+		}
+		<-ctx.Done() // runs until Stop cancels it
+		return ctx.Err()
+	}})
+	s.Start()
+
+	deadline := time.After(5 * time.Second)
+	for runs.Load() < 2 || failures.Load() < 2 {
+		select {
+		case <-deadline:
+			t.Fatalf("runs = %d, failures = %d after 5s", runs.Load(), failures.Load())
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	<-blocked
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	after := runs.Load()
+	time.Sleep(30 * time.Millisecond)
+	if runs.Load() != after {
+		t.Errorf("job ran after Stop")
+	}
+
+	m := jobMetrics("test_fail")
+	if got := m.Get("failures").String(); got == "0" {
+		t.Errorf("failures metric = %s, want > 0", got)
+	}
+	if got := m.Get("last_error").String(); got != `"panic: boom"` {
+		t.Errorf("last_error = %s, want the panic", got)
+	}
+}
+
+func TestStopGivesUpAtDeadline(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{}, 1)
+	s := New()
+	s.Add(Job{Name: "test_stuck", Schedule: Every(time.Millisecond), Run: func(context.Context) error {
+		started <- struct{}{}
+		<-release // ignores cancellation
+		return nil
+	}})
+	s.Start()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Stop = %v, want DeadlineExceeded", err)
+	}
+}
@@ -1,78 +1,533 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
 	"log"
+	"math"
+	"net"
+	"net/http"
+	_ "net/http/pprof"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/joho/godotenv"
 	omise "github.com/omise/omise-go"
+	"google.golang.org/grpc"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 
+	"github.com/a2n2k3p4/tutorium-backend/cache"
+	"github.com/a2n2k3p4/tutorium-backend/config"
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/fx"
+	"github.com/a2n2k3p4/tutorium-backend/gateway"
+	"github.com/a2n2k3p4/tutorium-backend/grpcapi"
 	"github.com/a2n2k3p4/tutorium-backend/handlers"
+	"github.com/a2n2k3p4/tutorium-backend/jobs"
+	"github.com/a2n2k3p4/tutorium-backend/migrations"
 	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/money"
+	"github.com/a2n2k3p4/tutorium-backend/notify"
+	"github.com/a2n2k3p4/tutorium-backend/objectstore"
+	"github.com/a2n2k3p4/tutorium-backend/rawpayload"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"github.com/a2n2k3p4/tutorium-backend/risk"
+	"github.com/a2n2k3p4/tutorium-backend/service"
+	"github.com/a2n2k3p4/tutorium-backend/simulator"
+	"github.com/a2n2k3p4/tutorium-backend/tax"
 )
 
 func main() {
 	_ = godotenv.Load()
 
-	// Database connection
-	dsn := fmt.Sprintf(
-		"host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
-		os.Getenv("DB_HOST"),
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_PASSWORD"),
-		os.Getenv("DB_NAME"),
-		os.Getenv("DB_PORT"),
-	)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	// PrepareStmt caches prepared statements (webhook bursts reuse the same upsert/lookup queries)
+	db, err := gorm.Open(postgres.Open(cfg.DB.DSN()), &gorm.Config{PrepareStmt: true})
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
+	// Transaction lists and reports read from DB_REPLICA_HOST when set (see dbutil.Replica), so they do
+	// not contend with webhook writes on the primary.
+	if err := dbutil.UsePool(db, dbutil.Pool{
+		MaxOpenConns:    cfg.DB.MaxOpenConns,
+		MaxIdleConns:    cfg.DB.MaxIdleConns,
+		ConnMaxLifetime: cfg.DB.ConnMaxLifetime,
+		ConnMaxIdleTime: cfg.DB.ConnMaxIdleTime,
+	}, cfg.DB.ReplicaDSN()); err != nil {
+		log.Fatal("Failed to connect to the read replica:", err)
+	}
 
-	// Auto migrate models
-	if err := db.AutoMigrate(&models.User{}, &models.Transaction{}); err != nil {
-		log.Fatal("Failed to migrate database:", err)
+	// The schema is owned by the versioned migrations (migrations/, applied with `migrate up`); refuse to
+	// serve against a database that is behind, ahead, dirty or drifted from what this build expects.
+	if cfg.Features.MigrateOnStart {
+		if err := migrations.Up(cfg.DB.DSN()); err != nil {
+			log.Fatal("Failed to migrate database:", err)
+		}
+	}
+	if err := migrations.Check(db, models.All()...); err != nil {
+		log.Fatal("Refusing to start: ", err)
 	}
 
-	// Omise client setup
-	publicKey := os.Getenv("OMISE_PUBLIC_KEY")
-	secretKey := os.Getenv("OMISE_SECRET_KEY")
-	if publicKey == "" || secretKey == "" {
-		log.Fatal("OMISE_PUBLIC_KEY and OMISE_SECRET_KEY must be set")
+	// Omise client setup; MOCK_OMISE=true swaps in the in-process simulator (see simulator/), which
+	// then stands in for every merchant's account
+	// Breakers opening page on-call (see handlers.OpsAlerts); no Omise call is made before the handler exists.
+	var paymentHandler *handlers.PaymentHandler
+	breakerOpened := func(account string) func(int, time.Duration) {
+		return func(failures int, cooldown time.Duration) {
+			if paymentHandler != nil {
+				paymentHandler.AlertOmiseOutage(account, failures, cooldown)
+			}
+		}
+	}
+	var defaultGateway gateway.OmiseGateway
+	var sandbox *simulator.Simulator
+	if cfg.Features.MockOmise {
+		sandbox = simulator.New(cfg.Omise.MockBaseURL)
+		defaultGateway = sandbox
+		log.Printf("WARNING: MOCK_OMISE=true, payments go to the in-process simulator (sandbox at %s/sandbox)", cfg.Omise.MockBaseURL)
+	} else {
+		if v := cfg.Omise.APIVersion; v != "" && v != gateway.DefaultAPIVersion {
+			log.Printf("WARNING: OMISE_API_VERSION=%s differs from the version our types were checked against (%s)", v, gateway.DefaultAPIVersion)
+		}
+		defaultGateway = newOmiseGateway(cfg, cfg.Omise.PublicKey, cfg.Omise.SecretKey, breakerOpened("the default account"))
 	}
 
-	client, err := omise.NewClient(publicKey, secretKey)
-	if err != nil {
-		log.Fatal("Failed to create Omise client:", err)
+	// Every merchant (MERCHANTS) has its own Omise account; requests pick one with X-Merchant and
+	// stored transactions remember theirs (see gateway.Merchants)
+	omiseGateway := &gateway.Merchants{
+		Default:  defaultGateway,
+		Accounts: map[uint]gateway.OmiseGateway{models.DefaultMerchantID: defaultGateway},
+	}
+	var (
+		merchants         []handlers.Merchant
+		merchantEndpoints []handlers.WebhookEndpoint // each account delivers to /api/v1/webhooks/<name>
+	)
+	for _, mc := range cfg.Merchants {
+		m := models.Merchant{Code: mc.Name}
+		if err := db.Where(models.Merchant{Code: mc.Name}).FirstOrCreate(&m).Error; err != nil {
+			log.Fatal("Failed to register merchant ", mc.Name, ": ", err)
+		}
+		omiseGateway.Accounts[m.ID] = defaultGateway
+		if sandbox == nil {
+			omiseGateway.Accounts[m.ID] = newOmiseGateway(cfg, mc.PublicKey, mc.SecretKey, breakerOpened("merchant "+mc.Name))
+		}
+		merchants = append(merchants, handlers.Merchant{Code: mc.Name, ID: m.ID})
+		merchantEndpoints = append(merchantEndpoints, handlers.WebhookEndpoint{Name: mc.Name, Secret: mc.WebhookSecret, MerchantID: m.ID})
 	}
 
 	// Initialize handlers
-	paymentHandler := handlers.NewPaymentHandler(db, client)
+	paymentHandler = handlers.NewPaymentHandler(db, omiseGateway)
+	// Raw card tokenization is off unless explicitly enabled (sandbox only).
+	paymentHandler.Payments.AllowRawCard = cfg.Features.AllowRawCard
+	if paymentHandler.Payments.AllowRawCard {
+		log.Println("WARNING: ALLOW_RAW_CARD=true, server-side card tokenization is enabled (sandbox only)")
+	}
+	paymentHandler.Merchants = merchants
+	paymentHandler.WebhookEndpoints = merchantEndpoints
+	paymentHandler.Payments.Merchants = []uint{models.DefaultMerchantID}
+	for _, m := range merchants {
+		paymentHandler.Payments.Merchants = append(paymentHandler.Payments.Merchants, m.ID)
+	}
+	paymentHandler.AdminToken = cfg.AdminToken
+	paymentHandler.Mailer = notify.NewMailer(cfg.SMTP)
+	paymentHandler.PaymentEmails = cfg.PaymentEmails
+	paymentHandler.Line = notify.NewLINE(cfg.LineChannelToken)
+	var fcmCredentials []byte
+	if cfg.FCMCredentialsFile != "" {
+		if fcmCredentials, err = os.ReadFile(cfg.FCMCredentialsFile); err != nil {
+			log.Fatal("Invalid FCM_CREDENTIALS_FILE:", err)
+		}
+	}
+	if paymentHandler.FCM, err = notify.NewFCM(fcmCredentials); err != nil {
+		log.Fatal("Invalid FCM_CREDENTIALS_FILE:", err)
+	}
+	paymentHandler.PushDeepLinkBase = cfg.FCMDeepLinkBase
+
+	// Allowed return_uri hosts for redirect-based charges
+	paymentHandler.Payments.ReturnURIAllowlist = cfg.ReturnURIAllowedHosts
+	if len(paymentHandler.Payments.ReturnURIAllowlist) == 0 {
+		log.Println("WARNING: RETURN_URI_ALLOWED_HOSTS is not set, return_uri is not validated")
+	}
+
+	// Where charges may come from (GEO_COUNTRY_HEADER, CHARGE_COUNTRIES_*)
+	paymentHandler.CountryHeader = cfg.CountryHeader
+	paymentHandler.Payments.Countries = service.CountryPolicy{Allowed: cfg.AllowedCountries, Denied: cfg.DeniedCountries}
+	if cfg.CountryHeader == "" && len(cfg.AllowedCountries)+len(cfg.DeniedCountries) > 0 {
+		log.Println("WARNING: CHARGE_COUNTRIES_* are set without GEO_COUNTRY_HEADER, charges are not limited by country")
+	}
+
+	// Optional AES-GCM encryption of stored raw charge payloads (base64 32-byte key, e.g. injected from KMS)
+	if cfg.RawPayloadKey != "" {
+		payloadCipher, err := rawpayload.NewCipherFromBase64(cfg.RawPayloadKey)
+		if err != nil {
+			log.Fatal("Invalid RAW_PAYLOAD_KEY:", err)
+		}
+		paymentHandler.Payments.PayloadCipher = payloadCipher
+	}
+
+	// External e-Tax invoice provider (TAX_PROVIDER); unset disables submission
+	taxService, err := tax.New(cfg.TaxProvider)
+	if err != nil {
+		log.Fatal("Invalid TAX_PROVIDER:", err)
+	}
+	paymentHandler.Tax = taxService
+	paymentHandler.Payments.VATRateBps = int64(math.Round(cfg.VATRatePct * 100))
+	paymentHandler.Payments.WalletFXRates = cfg.WalletFXRates
+	paymentHandler.Payments.Velocity = service.VelocityLimits{
+		MaxChargeSatang:     money.FromMajor(cfg.ChargeLimits.MaxChargeTHB, money.THB).Amount,
+		MaxPerHour:          cfg.ChargeLimits.PerHour,
+		MaxPerDay:           cfg.ChargeLimits.PerDay,
+		MaxDailyTopUpSatang: money.FromMajor(cfg.ChargeLimits.DailyTopUpTHB, money.THB).Amount,
+	}
+	paymentHandler.Payments.DuplicateWindow = cfg.ChargeLimits.DuplicateWindow
+	paymentHandler.Payments.SecureCards = service.SecureCardPolicy{
+		MinAmountSatang: money.FromMajor(cfg.ChargeLimits.SecureCardMinTHB, money.THB).Amount,
+		RiskFlagged:     cfg.ChargeLimits.SecureCardRiskFlagged,
+	}
+	riskEvaluator, err := risk.New(cfg.RiskEvaluator)
+	if err != nil {
+		log.Fatal("Invalid RISK_EVALUATOR:", err)
+	}
+	paymentHandler.Payments.Risk = riskEvaluator
+	paymentHandler.PaymentLinkBaseURL = cfg.PaymentLinkBaseURL
+	paymentHandler.Payments.PageSize = repository.PageSize{Default: cfg.PageSizeDefault, Max: cfg.PageSizeMax}
+
+	// Refund volume alerts and where admin alerts go
+	paymentHandler.RefundBudget = handlers.RefundBudget{
+		DailyLimitSatang: money.FromMajor(cfg.RefundBudget.DailyLimitTHB, money.THB).Amount,
+		ThresholdsPct:    cfg.RefundBudget.ThresholdsPct,
+		AnomalyFactor:    cfg.RefundBudget.AnomalyFactor,
+		AnomalyMinSatang: money.FromMajor(cfg.RefundBudget.AnomalyMinTHB, money.THB).Amount,
+	}
+	paymentHandler.WebhookSLA = handlers.WebhookSLA{
+		Delivery:      cfg.WebhookSLA.Delivery,
+		Processing:    cfg.WebhookSLA.Processing,
+		AlertCooldown: cfg.WebhookSLA.AlertCooldown,
+	}
+	paymentHandler.Alerts = handlers.AlertTargets{
+		SlackWebhookURL:   cfg.Alerts.SlackWebhookURL,
+		DiscordWebhookURL: cfg.Alerts.DiscordWebhookURL,
+		Emails:            cfg.Alerts.Emails,
+	}
+	paymentHandler.OpsAlerts = handlers.OpsAlerts{
+		FailureRate:       cfg.Alerts.FailureRatePct / 100,
+		FailureWindow:     cfg.Alerts.FailureWindow,
+		FailureMinCharges: cfg.Alerts.FailureMinCharges,
+		Cooldown:          cfg.Alerts.Cooldown,
+	}
+
+	// Rolling reserve withheld from teacher payout statements
+	paymentHandler.PayoutReserve = handlers.PayoutReserve{
+		Bps:      int64(math.Round(cfg.Payouts.ReservePct * 100)),
+		HoldDays: cfg.Payouts.ReserveDays,
+	}
+
+	// Inbound webhook endpoints besides the default /api/v1/webhooks/omise
+	for _, ep := range cfg.WebhookEndpoints {
+		paymentHandler.WebhookEndpoints = append(paymentHandler.WebhookEndpoints, handlers.WebhookEndpoint{
+			Name:          ep.Name,
+			Secret:        ep.Secret,
+			Events:        ep.Events,
+			InstitutionID: uint(ep.InstitutionID),
+		})
+	}
+
+	// Internal services identified by X-API-Key for per-consumer usage reports
+	for _, ac := range cfg.APIConsumers {
+		paymentHandler.APIConsumers = append(paymentHandler.APIConsumers, handlers.APIConsumer{Name: ac.Name, Key: ac.Key})
+	}
+
+	// Nightly delivery of the previous day's transactions to the data warehouse bucket
+	paymentHandler.Warehouse = handlers.WarehouseExport{Format: cfg.Warehouse.Format, Prefix: cfg.Warehouse.Prefix}
+	if cfg.Warehouse.Bucket != "" {
+		store, err := objectstore.NewS3(objectstore.S3Config{
+			Endpoint:  cfg.Warehouse.Endpoint,
+			Region:    cfg.Warehouse.Region,
+			Bucket:    cfg.Warehouse.Bucket,
+			AccessKey: cfg.Warehouse.AccessKey,
+			SecretKey: cfg.Warehouse.SecretKey,
+			Insecure:  cfg.Warehouse.Insecure,
+		})
+		if err != nil {
+			log.Fatal("Invalid WAREHOUSE_ENDPOINT:", err)
+		}
+		paymentHandler.Warehouse.Store = store
+	}
+
+	// Raw payloads past RAW_PAYLOAD_RETENTION go to cold storage instead of being discarded
+	if cfg.PayloadArchive.Bucket != "" {
+		store, err := objectstore.NewS3(objectstore.S3Config{
+			Endpoint:  cfg.PayloadArchive.Endpoint,
+			Region:    cfg.PayloadArchive.Region,
+			Bucket:    cfg.PayloadArchive.Bucket,
+			AccessKey: cfg.PayloadArchive.AccessKey,
+			SecretKey: cfg.PayloadArchive.SecretKey,
+			Insecure:  cfg.PayloadArchive.Insecure,
+		})
+		if err != nil {
+			log.Fatal("Invalid RAW_PAYLOAD_ARCHIVE_ENDPOINT:", err)
+		}
+		paymentHandler.Payments.PayloadArchive = store
+		paymentHandler.Payments.PayloadArchivePrefix = cfg.PayloadArchive.Prefix
+	}
+
+	// Transaction lookups and list totals polled by the dashboard are cached in Redis when configured.
+	var redis *cache.Redis
+	if cfg.Cache.RedisURL != "" {
+		if redis, err = cache.NewRedis(cfg.Cache.RedisURL, cfg.Cache.Timeout); err != nil {
+			log.Fatal("Invalid REDIS_URL:", err)
+		}
+		if err := redis.Ping(context.Background()); err != nil {
+			log.Printf("WARNING: Redis is unreachable, transaction reads go to the database until it answers: %v", err)
+		}
+		paymentHandler.Payments.TransactionCache = &repository.TransactionCache{Store: redis, TTL: cfg.Cache.TTL}
+	}
+
+	// Indicative exchange rates for /payments/quote (FX_PROVIDER), cached in Redis or in memory
+	fxProvider, err := fx.New(cfg.FXProvider, cfg.WalletFXRates, cfg.FXRatesURL, cfg.Timeouts.Request)
+	if err != nil {
+		log.Fatal("Invalid FX_PROVIDER:", err)
+	}
+	if fxProvider != nil {
+		var fxStore cache.Store = cache.NewMemory()
+		if redis != nil {
+			fxStore = redis
+		}
+		paymentHandler.FX = &fx.Cached{Provider: fxProvider, Store: fxStore, TTL: cfg.FXRatesTTL}
+	}
+
+	// Context deadlines of API requests (their Omise and DB calls) and background work
+	paymentHandler.Deadlines = handlers.Deadlines{
+		Request:    cfg.Timeouts.Request,
+		Admin:      cfg.Timeouts.AdminRequest,
+		Background: cfg.Timeouts.Background,
+	}
+
+	// Load shedding when the webhook pipeline or background work backs up
+	paymentHandler.Backpressure = handlers.Backpressure{
+		MaxWebhooks:   cfg.Backpressure.MaxWebhooks,
+		MaxBackground: cfg.Backpressure.MaxBackground,
+		ShedCharges:   cfg.Backpressure.ShedCharges,
+		RetryAfter:    cfg.Backpressure.RetryAfter,
+	}
+
+	// Logo for shareable PromptPay QR images
+	if cfg.QRLogoPath != "" {
+		logo, err := loadImage(cfg.QRLogoPath)
+		if err != nil {
+			log.Fatal("Invalid QR_LOGO_PATH:", err)
+		}
+		paymentHandler.QRLogo = logo
+	}
+
+	// Periodic jobs (stale charges, Omise reconciliation, full nightly reconciliation, payout statements,
+	// raw payload retention, warehouse export, report subscriptions, consistency checks)
+	scheduledJobs, err := paymentHandler.ScheduledJobs(handlers.JobSchedules{
+		ExpirePending:         cfg.Jobs.ExpirePending,
+		PendingTTL:            cfg.Jobs.PendingTTL,
+		Reconcile:             cfg.Jobs.Reconcile,
+		ReconcileAfter:        cfg.Jobs.ReconcileAfter,
+		FullReconcile:         cfg.Jobs.FullReconcile,
+		FullReconcileHeal:     cfg.Jobs.FullReconcileHeal,
+		PayoutStatements:      cfg.Jobs.PayoutStatements,
+		PruneRawPayloads:      cfg.Jobs.PruneRawPayloads,
+		RawPayloadRetention:   cfg.Jobs.RawPayloadRetention,
+		WarehouseExport:       cfg.Jobs.WarehouseExport,
+		TransactionRollup:     cfg.Jobs.TransactionRollup,
+		RollupLookbackDays:    cfg.Jobs.RollupLookbackDays,
+		LineQRReminders:       cfg.Jobs.LineQRReminders,
+		LineQRReminderLead:    cfg.Jobs.LineQRReminderLead,
+		ChargeFailureRate:     cfg.Jobs.ChargeFailureRate,
+		ChargeFees:            cfg.Jobs.ChargeFees,
+		Transfers:             cfg.Jobs.Transfers,
+		TransactionPartitions: cfg.Jobs.TransactionPartitions,
+		ReportSubscriptions:   cfg.Jobs.ReportSubscriptions,
+		ConsistencyChecks:     cfg.Jobs.ConsistencyChecks,
+	})
+	if err != nil {
+		log.Fatal("Invalid job schedule:", err)
+	}
+	// Only the replica holding the "jobs" lease runs them (see jobs.Elector)
+	scheduler := jobs.New()
+	scheduler.Elector = &jobs.Elector{
+		Store:  repository.NewJobLeaseRepository(db),
+		Name:   "jobs",
+		Holder: jobs.HolderID(),
+		TTL:    cfg.Jobs.LeaderLease,
+	}
+	for _, j := range scheduledJobs {
+		scheduler.Add(j)
+	}
+	scheduler.Start()
 
 	// Create Fiber app
-	app := fiber.New()
+	app := fiber.New(fiber.Config{
+		// Render every returned error as {code, message, fields} (see handlers/errors.go)
+		ErrorHandler: handlers.ErrorHandler,
+		ReadTimeout:  cfg.Timeouts.HTTPRead,
+		WriteTimeout: cfg.Timeouts.HTTPWrite,
+		IdleTimeout:  cfg.Timeouts.HTTPIdle,
+	})
 
 	// Middleware (Cors) TODO: integrate middleware into transaction handlers, or use CORS idc
 	app.Use(logger.New())
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowMethods: "GET, POST, PUT, DELETE, OPTIONS",
-		AllowHeaders: "Content-Type, Authorization, X-User-ID",
+		AllowOrigins: strings.Join(cfg.CORSOrigins, ", "),
+		AllowMethods: "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS",
+		AllowHeaders: "Content-Type, Authorization, X-User-ID, X-Admin-Token, X-Admin-User, X-API-Key, X-Merchant",
 	}))
 
-	// Routes
-	app.Get("/health", paymentHandler.Health)
-	app.Post("/payments/charge", paymentHandler.CreateCharge)
-	app.Get("/payments/transactions", paymentHandler.ListTransactions)
-	app.Get("/payments/transactions/:id", paymentHandler.GetTransaction)
-	app.Post("/webhooks/omise", paymentHandler.HandleWebhook)
+	// Routes (see handlers/allroutes.go); sandbox routes go first, before the catch-all
+	if sandbox != nil {
+		sandbox.Register(app)
+	}
+	handlers.RegisterRoutes(app, paymentHandler)
+
+	// Serve until SIGINT/SIGTERM, then shut down gracefully
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		fmt.Printf("Server running on http://localhost:%s\n", cfg.Port)
+		serveErr <- app.Listen(cfg.ListenAddr())
+	}()
+
+	// Optional diagnostics listener: net/http/pprof and expvar register on http.DefaultServeMux; no auth
+	var debugServer *http.Server
+	if cfg.DebugListenAddr != "" {
+		debugServer = &http.Server{Addr: cfg.DebugListenAddr, Handler: http.DefaultServeMux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			log.Printf("debug: pprof and expvar on http://%s/debug/", cfg.DebugListenAddr)
+			if err := debugServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("debug: listener stopped: %v", err)
+			}
+		}()
+	}
+
+	// Optional gRPC payments API for internal services, backed by the same PaymentService
+	var grpcServer *grpc.Server
+	if cfg.GRPCListenAddr != "" {
+		lis, err := net.Listen("tcp", cfg.GRPCListenAddr)
+		if err != nil {
+			log.Fatal("Failed to listen on GRPC_LISTEN_ADDR:", err)
+		}
+		grpcServer = grpcapi.NewServer(paymentHandler.Payments, cfg.GRPCAuthToken)
+		go func() {
+			log.Printf("grpc: payments API on %s", cfg.GRPCListenAddr)
+			if err := grpcServer.Serve(lis); err != nil {
+				serveErr <- fmt.Errorf("grpc: %w", err)
+			}
+		}()
+	}
+
+	select {
+	case err := <-serveErr:
+		log.Fatal(err)
+	case <-ctx.Done():
+	}
+	stop() // a second signal kills the process immediately
+	log.Printf("shutdown: signal received, draining for up to %s", cfg.Timeouts.Shutdown)
+
+	// One deadline for the whole sequence: in-flight requests, then background work, then the DB pool.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Shutdown)
+	defer cancel()
+
+	// 1. Stop accepting connections and wait for in-flight charge/webhook requests (HTTP and gRPC); end
+	//    the long-lived webhook tail and WatchTransactions streams first so they do not hold the servers open.
+	paymentHandler.CloseStreams()
+	if err := app.ShutdownWithContext(shutdownCtx); err != nil {
+		log.Printf("shutdown: http server: %v", err)
+	}
+	if debugServer != nil {
+		_ = debugServer.Close()
+	}
+	if grpcServer != nil {
+		stopGRPC(shutdownCtx, grpcServer)
+	}
+	// 2. Stop the jobs and wait for background work (auto-reload charges, tax submission).
+	if err := scheduler.Stop(shutdownCtx); err != nil {
+		log.Printf("shutdown: jobs still running: %v", err)
+	}
+	if err := paymentHandler.Drain(shutdownCtx); err != nil {
+		log.Printf("shutdown: background work still running: %v", err)
+	}
+	if err := paymentHandler.FlushUsage(shutdownCtx); err != nil {
+		log.Printf("shutdown: usage counts lost: %v", err)
+	}
+	// 3. Close the DB pool and the Redis connections.
+	if sqlDB, err := db.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			log.Printf("shutdown: close database: %v", err)
+		}
+	}
+	if redis != nil {
+		_ = redis.Close()
+	}
+	log.Println("shutdown: complete")
+}
 
-	fmt.Println("Server running on http://localhost:8080")
-	log.Fatal(app.Listen(":8080"))
+// stopGRPC waits for in-flight gRPC calls like app.ShutdownWithContext, cutting them off at ctx's deadline.
+func stopGRPC(ctx context.Context, s *grpc.Server) {
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Printf("shutdown: grpc server: %v", ctx.Err())
+		s.Stop()
+	}
+}
+
+// newOmiseGateway is an Omise client for one account, with the configured timeout, API version and
+// retry policy; onOpen is told when its circuit breaker opens.
+func newOmiseGateway(cfg *config.Config, publicKey, secretKey string, onOpen func(failures int, cooldown time.Duration)) gateway.OmiseGateway {
+	client, err := omise.NewClient(publicKey, secretKey)
+	if err != nil {
+		log.Fatal("Failed to create Omise client:", err)
+	}
+	client.Client.Timeout = cfg.Timeouts.OmiseRequest
+	gateway.WithAPIVersion(client, cfg.Omise.APIVersion)
+	return gateway.New(client).WithResilience(gateway.Resilience{
+		MaxAttempts:      cfg.Omise.RetryAttempts,
+		BaseDelay:        cfg.Omise.RetryBaseDelay,
+		MaxDelay:         cfg.Omise.RetryMaxDelay,
+		BreakerThreshold: cfg.Omise.BreakerThreshold,
+		BreakerCooldown:  cfg.Omise.BreakerCooldown,
+		OnOpen:           onOpen,
+	})
+}
+
+// loadImage reads a PNG/JPEG file from disk.
+func loadImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	return img, err
 }
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/golang-migrate/migrate/v4"
+
+	"github.com/a2n2k3p4/tutorium-backend/config"
+	"github.com/a2n2k3p4/tutorium-backend/migrations"
+)
+
+const migrateUsage = `usage: tutorium-backend migrate <command>
+
+commands:
+  up          apply every pending migration
+  down N      roll back the last N migrations
+  goto V      migrate up or down to version V
+  force V     record version V as applied and clean, after fixing a failed migration by hand
+  version     print the applied version and whether it is dirty
+
+Only the DB_* settings are read.
+`
+
+// runMigrate is the `migrate` subcommand: it manages the schema with the embedded migrations and exits.
+func runMigrate(args []string) {
+	if err := migrateCommand(args); err != nil {
+		fmt.Fprintln(os.Stderr, "migrate:", err)
+		os.Exit(1)
+	}
+}
+
+func migrateCommand(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+	// (helper) the numeric argument of down/goto/force
+	number := func() (int, error) {
+		if len(args) != 2 {
+			return 0, fmt.Errorf("%s needs exactly one number", args[0])
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%s: %q is not a non-negative number", args[0], args[1])
+		}
+		return n, nil
+	}
+
+	dbCfg, err := config.LoadDB()
+	if err != nil {
+		return err
+	}
+	m, err := migrations.New(dbCfg.DSN())
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	switch args[0] {
+	case "up":
+		err = m.Up()
+	case "down":
+		n, nerr := number()
+		if nerr != nil {
+			return nerr
+		}
+		if n == 0 {
+			return errors.New("down 0 does nothing; give the number of migrations to roll back")
+		}
+		err = m.Steps(-n)
+	case "goto":
+		v, nerr := number()
+		if nerr != nil {
+			return nerr
+		}
+		err = m.Migrate(uint(v))
+	case "force":
+		v, nerr := number()
+		if nerr != nil {
+			return nerr
+		}
+		err = m.Force(v)
+	case "version", "status":
+	default:
+		fmt.Fprint(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+
+	latest, err := migrations.Latest()
+	if err != nil {
+		return err
+	}
+	version, dirty, err := m.Version()
+	switch {
+	case errors.Is(err, migrate.ErrNilVersion):
+		fmt.Printf("no migrations applied (this build expects version %d)\n", latest)
+	case err != nil:
+		return err
+	default:
+		fmt.Printf("version %d, dirty %t (this build expects version %d)\n", version, dirty, latest)
+	}
+	return nil
+}
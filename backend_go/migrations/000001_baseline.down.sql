@@ -0,0 +1,15 @@
+DROP TABLE IF EXISTS "payout_reserve_entries";
+DROP TABLE IF EXISTS "payout_statements";
+DROP TABLE IF EXISTS "consistency_runs";
+DROP TABLE IF EXISTS "institution_members";
+DROP TABLE IF EXISTS "institutions";
+DROP TABLE IF EXISTS "wallet_operations";
+DROP TABLE IF EXISTS "webhook_deliveries";
+DROP TABLE IF EXISTS "refund_alerts";
+DROP TABLE IF EXISTS "tax_documents";
+DROP TABLE IF EXISTS "dispute_cases";
+DROP TABLE IF EXISTS "audit_logs";
+DROP TABLE IF EXISTS "auto_reloads";
+DROP TABLE IF EXISTS "report_subscriptions";
+DROP TABLE IF EXISTS "transactions";
+DROP TABLE IF EXISTS "users";
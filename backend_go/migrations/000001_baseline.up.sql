@@ -0,0 +1,71 @@
+-- Baseline: the schema gorm AutoMigrate created before versioned migrations. Every statement is
+-- IF NOT EXISTS so databases created by AutoMigrate adopt it unchanged.
+
+CREATE TABLE IF NOT EXISTS "users" ("id" bigserial,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,"student_id" varchar(10) NOT NULL,"profile_picture" bytea,"first_name" varchar(30) NOT NULL,"last_name" varchar(30) NOT NULL,"gender" varchar(6),"phone_number" varchar(20),"balance" numeric(12,2) DEFAULT 0,"frozen_balance" numeric(12,2) DEFAULT 0,"held_balance" numeric(12,2) DEFAULT 0,PRIMARY KEY ("id"),CONSTRAINT "chk_users_balance" CHECK (balance >= 0),CONSTRAINT "chk_users_frozen_balance" CHECK (frozen_balance >= 0),CONSTRAINT "chk_users_held_balance" CHECK (held_balance >= 0));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_student_id" ON "users" ("student_id");
+CREATE INDEX IF NOT EXISTS "idx_users_deleted_at" ON "users" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "transactions" ("id" bigserial,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,"user_id" bigint,"acting_user_id" bigint,"charge_id" text,"amount_satang" bigint,"currency" text,"channel" text,"status" text,"failure_code" text,"failure_message" text,"raw_payload" bytea,"meta" JSONB,PRIMARY KEY ("id"),CONSTRAINT "fk_transactions_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE SET NULL ON UPDATE CASCADE);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_transactions_charge_id" ON "transactions" ("charge_id");
+CREATE INDEX IF NOT EXISTS "idx_transactions_acting_user_id" ON "transactions" ("acting_user_id");
+CREATE INDEX IF NOT EXISTS "idx_transactions_user_id" ON "transactions" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_transactions_deleted_at" ON "transactions" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "report_subscriptions" ("id" bigserial,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,"report_type" varchar(40) NOT NULL,"channel" varchar(10) NOT NULL,"target" text NOT NULL,"active" boolean DEFAULT true,"next_run_at" timestamptz,"last_sent_at" timestamptz,"last_error" text,"created_by" varchar(100),PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_report_subscriptions_next_run_at" ON "report_subscriptions" ("next_run_at");
+CREATE INDEX IF NOT EXISTS "idx_report_subscriptions_deleted_at" ON "report_subscriptions" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "auto_reloads" ("id" bigserial,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,"user_id" bigint NOT NULL,"enabled" boolean DEFAULT false,"threshold_satang" bigint NOT NULL,"amount_satang" bigint NOT NULL,"currency" varchar(3) DEFAULT 'thb',"notify_email" text,"omise_customer_id" text,"omise_card_id" text,"card_brand" text,"card_last_digits" text,"max_per_day" bigint DEFAULT 3,"last_attempt_at" timestamptz,"last_charge_id" text,"consecutive_failures" bigint DEFAULT 0,"disabled_reason" text,PRIMARY KEY ("id"),CONSTRAINT "fk_auto_reloads_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_auto_reloads_user_id" ON "auto_reloads" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_auto_reloads_deleted_at" ON "auto_reloads" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "audit_logs" ("id" bigserial,"created_at" timestamptz,"actor" varchar(100) NOT NULL,"action" varchar(60) NOT NULL,"entity_type" varchar(40),"entity_id" varchar(64),"before" JSONB,"after" JSONB,"ip" varchar(45),"reason" text,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_audit_entity" ON "audit_logs" ("entity_type","entity_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_action" ON "audit_logs" ("action");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_actor" ON "audit_logs" ("actor");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_created_at" ON "audit_logs" ("created_at");
+
+CREATE TABLE IF NOT EXISTS "dispute_cases" ("id" bigserial,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,"transaction_id" bigint NOT NULL,"user_id" bigint NOT NULL,"requested_by" bigint,"reason" varchar(40) NOT NULL,"description" text,"amount_satang" bigint,"frozen_satang" bigint,"status" varchar(20) NOT NULL,"recommended_path" varchar(20),"resolution" text,"resolved_by" varchar(100),"resolved_at" timestamptz,"refund_id" text,PRIMARY KEY ("id"),CONSTRAINT "fk_dispute_cases_transaction" FOREIGN KEY ("transaction_id") REFERENCES "transactions"("id"));
+CREATE INDEX IF NOT EXISTS "idx_dispute_cases_status" ON "dispute_cases" ("status");
+CREATE INDEX IF NOT EXISTS "idx_dispute_cases_user_id" ON "dispute_cases" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_dispute_cases_transaction_id" ON "dispute_cases" ("transaction_id");
+CREATE INDEX IF NOT EXISTS "idx_dispute_cases_deleted_at" ON "dispute_cases" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "tax_documents" ("id" bigserial,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,"transaction_id" bigint NOT NULL,"charge_id" varchar(64),"provider" varchar(40),"status" varchar(20) NOT NULL,"document_id" varchar(128),"number" varchar(64),"url" text,"net_satang" bigint,"vat_satang" bigint,"attempts" bigint NOT NULL DEFAULT 0,"last_error" text,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_tax_documents_status" ON "tax_documents" ("status");
+CREATE INDEX IF NOT EXISTS "idx_tax_documents_charge_id" ON "tax_documents" ("charge_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_tax_documents_transaction_id" ON "tax_documents" ("transaction_id");
+CREATE INDEX IF NOT EXISTS "idx_tax_documents_deleted_at" ON "tax_documents" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "refund_alerts" ("id" bigserial,"created_at" timestamptz,"day" varchar(10) NOT NULL,"kind" varchar(20) NOT NULL,"total_satang" bigint,"budget_satang" bigint,"baseline_satang" bigint,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_refund_alert_day_kind" ON "refund_alerts" ("day","kind");
+
+CREATE TABLE IF NOT EXISTS "webhook_deliveries" ("id" bigserial,"event_id" varchar(64) NOT NULL,"event_key" varchar(64) NOT NULL,"charge_id" varchar(64),"event_created_at" timestamptz,"received_at" timestamptz,"processed_at" timestamptz,"delivery_ms" bigint,"processing_ms" bigint,"total_ms" bigint,"breached" boolean NOT NULL DEFAULT false,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_processed_at" ON "webhook_deliveries" ("processed_at");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_charge_id" ON "webhook_deliveries" ("charge_id");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_event_key" ON "webhook_deliveries" ("event_key");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_webhook_deliveries_event_id" ON "webhook_deliveries" ("event_id");
+
+CREATE TABLE IF NOT EXISTS "wallet_operations" ("id" bigserial,"created_at" timestamptz,"updated_at" timestamptz,"operation_id" varchar(100) NOT NULL,"user_id" bigint NOT NULL,"kind" varchar(10) NOT NULL,"amount_satang" bigint NOT NULL,"description" varchar(255),"hold_status" varchar(10),"balance" numeric(12,2),"held_balance" numeric(12,2),PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_wallet_operations_user_id" ON "wallet_operations" ("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_wallet_operations_operation_id" ON "wallet_operations" ("operation_id");
+
+CREATE TABLE IF NOT EXISTS "institutions" ("id" bigserial,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,"name" varchar(120) NOT NULL,"payer_user_id" bigint NOT NULL,"created_by" varchar(100),PRIMARY KEY ("id"),CONSTRAINT "fk_institutions_payer" FOREIGN KEY ("payer_user_id") REFERENCES "users"("id") ON DELETE RESTRICT ON UPDATE CASCADE);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_institutions_payer_user_id" ON "institutions" ("payer_user_id");
+CREATE INDEX IF NOT EXISTS "idx_institutions_deleted_at" ON "institutions" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "institution_members" ("id" bigserial,"created_at" timestamptz,"updated_at" timestamptz,"institution_id" bigint NOT NULL,"user_id" bigint NOT NULL,"can_view" boolean NOT NULL DEFAULT false,"can_pay" boolean NOT NULL DEFAULT false,"can_request_refund" boolean NOT NULL DEFAULT false,"added_by" varchar(100),PRIMARY KEY ("id"),CONSTRAINT "fk_institution_members_institution" FOREIGN KEY ("institution_id") REFERENCES "institutions"("id") ON DELETE CASCADE ON UPDATE CASCADE,CONSTRAINT "fk_institution_members_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE);
+CREATE INDEX IF NOT EXISTS "idx_institution_members_user_id" ON "institution_members" ("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_institution_member" ON "institution_members" ("institution_id","user_id");
+
+CREATE TABLE IF NOT EXISTS "consistency_runs" ("id" bigserial,"created_at" timestamptz,"run_key" varchar(40) NOT NULL,"trigger" varchar(10) NOT NULL,"finished_at" timestamptz,"violations" bigint,"checks" JSONB,"error" text,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_consistency_runs_run_key" ON "consistency_runs" ("run_key");
+CREATE INDEX IF NOT EXISTS "idx_consistency_runs_created_at" ON "consistency_runs" ("created_at");
+
+CREATE TABLE IF NOT EXISTS "payout_statements" ("id" bigserial,"created_at" timestamptz,"updated_at" timestamptz,"teacher_id" varchar(64) NOT NULL,"currency" varchar(3) NOT NULL,"period_start" timestamptz NOT NULL,"period_end" timestamptz NOT NULL,"gross_satang" bigint,"refunds_satang" bigint,"reserve_held_satang" bigint,"reserve_released_satang" bigint,"net_satang" bigint,"reserve_bps" bigint,"status" varchar(10) NOT NULL,"items" JSONB,"created_by" varchar(100),"approved_by" varchar(100),"approved_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_payout_statement_period" ON "payout_statements" ("teacher_id","currency","period_start");
+
+CREATE TABLE IF NOT EXISTS "payout_reserve_entries" ("id" bigserial,"created_at" timestamptz,"teacher_id" varchar(64) NOT NULL,"currency" varchar(3) NOT NULL,"kind" varchar(10) NOT NULL,"amount_satang" bigint NOT NULL,"statement_id" bigint NOT NULL,"release_after" timestamptz,"hold_id" bigint,"released_at" timestamptz,PRIMARY KEY ("id"),CONSTRAINT "fk_payout_reserve_entries_statement" FOREIGN KEY ("statement_id") REFERENCES "payout_statements"("id") ON DELETE CASCADE ON UPDATE CASCADE);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_payout_reserve_entries_hold_id" ON "payout_reserve_entries" ("hold_id");
+CREATE INDEX IF NOT EXISTS "idx_payout_reserve_entries_statement_id" ON "payout_reserve_entries" ("statement_id");
+CREATE INDEX IF NOT EXISTS "idx_payout_reserve_entries_teacher_id" ON "payout_reserve_entries" ("teacher_id");
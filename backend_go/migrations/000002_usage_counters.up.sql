@@ -0,0 +1,3 @@
+-- Daily usage per internal API consumer (models.UsageCounter), for cost allocation.
+CREATE TABLE "usage_counters" ("id" bigserial,"updated_at" timestamptz,"day" varchar(10) NOT NULL,"consumer" varchar(64) NOT NULL,"metric" varchar(40) NOT NULL,"count" bigint NOT NULL DEFAULT 0,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX "idx_usage_counter" ON "usage_counters" ("day","consumer","metric");
@@ -0,0 +1,4 @@
+-- Full reconciliations of Omise's charge list against the transactions table (models.ReconciliationRun).
+CREATE TABLE "reconciliation_runs" ("id" bigserial,"created_at" timestamptz,"run_key" varchar(40) NOT NULL,"trigger" varchar(10) NOT NULL,"period_start" timestamptz NOT NULL,"period_end" timestamptz NOT NULL,"heal" boolean,"finished_at" timestamptz,"omise_charges" bigint,"local_transactions" bigint,"discrepancies" bigint,"healed" bigint,"items" JSONB,"error" text,"created_by" varchar(100),PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX "idx_reconciliation_runs_run_key" ON "reconciliation_runs" ("run_key");
+CREATE INDEX "idx_reconciliation_runs_created_at" ON "reconciliation_runs" ("created_at");
@@ -0,0 +1,2 @@
+-- Leases electing the replica that runs the scheduled jobs (models.JobLease, jobs.Elector).
+CREATE TABLE "job_leases" ("name" varchar(50),"holder" varchar(100) NOT NULL,"expires_at" timestamptz NOT NULL,"updated_at" timestamptz,PRIMARY KEY ("name"));
@@ -0,0 +1,7 @@
+-- Omise accounts (models.Merchant). The OMISE_* account is "default", id 1, and owns every existing transaction.
+CREATE TABLE "merchants" ("id" bigserial,"created_at" timestamptz,"code" varchar(50) NOT NULL,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX "idx_merchants_code" ON "merchants" ("code");
+INSERT INTO "merchants" ("id","created_at","code") VALUES (1, now(), 'default');
+SELECT setval(pg_get_serial_sequence('merchants', 'id'), 1);
+ALTER TABLE "transactions" ADD COLUMN "merchant_id" bigint NOT NULL DEFAULT 1 CONSTRAINT "fk_transactions_merchant" REFERENCES "merchants"("id");
+CREATE INDEX "idx_transactions_merchant_id" ON "transactions" ("merchant_id");
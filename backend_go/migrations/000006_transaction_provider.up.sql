@@ -0,0 +1,2 @@
+-- The payment provider of each transaction (provider.PaymentProvider); every existing charge is Omise's.
+ALTER TABLE "transactions" ADD COLUMN "provider" varchar(20) NOT NULL DEFAULT 'omise';
@@ -0,0 +1,2 @@
+-- The (created_at, id) keyset that transaction listing pages by (repository.TransactionCursor).
+CREATE INDEX IF NOT EXISTS "idx_transactions_created_id" ON "transactions" ("created_at","id");
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS "idx_transactions_search";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "search";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "description";
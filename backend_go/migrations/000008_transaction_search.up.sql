@@ -0,0 +1,9 @@
+-- Full-text search of transactions (ListTransactions ?q=): the charge description, the failure message
+-- and every string and number in meta. The 'simple' configuration keeps emails and references whole
+-- rather than stemming them as English.
+ALTER TABLE "transactions" ADD COLUMN "description" text;
+ALTER TABLE "transactions" ADD COLUMN "search" tsvector GENERATED ALWAYS AS (
+    to_tsvector('simple', coalesce("description", '') || ' ' || coalesce("failure_message", ''))
+    || jsonb_to_tsvector('simple', coalesce("meta", '{}'::jsonb), '["string", "numeric"]')
+) STORED;
+CREATE INDEX IF NOT EXISTS "idx_transactions_search" ON "transactions" USING gin ("search");
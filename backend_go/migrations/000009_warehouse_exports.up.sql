@@ -0,0 +1,4 @@
+-- Daily deliveries of transactions to the data warehouse bucket (models.WarehouseExport).
+CREATE TABLE "warehouse_exports" ("id" bigserial,"created_at" timestamptz,"day" varchar(10) NOT NULL,"status" varchar(10) NOT NULL,"format" varchar(10) NOT NULL,"object" text,"rows" bigint,"bytes" bigint,"attempts" bigint NOT NULL DEFAULT 1,"started_at" timestamptz NOT NULL,"finished_at" timestamptz,"error" text,"created_by" varchar(100),PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX "idx_warehouse_exports_day" ON "warehouse_exports" ("day");
+CREATE INDEX "idx_warehouse_exports_created_at" ON "warehouse_exports" ("created_at");
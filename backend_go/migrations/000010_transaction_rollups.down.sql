@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS "transaction_rollup_days";
+DROP TABLE IF EXISTS "transaction_daily_rollups";
@@ -0,0 +1,4 @@
+-- Daily per-merchant/channel/status/currency transaction rollups and the days they cover
+-- (models.TransactionDailyRollup, models.TransactionRollupDay).
+CREATE TABLE "transaction_daily_rollups" ("day" varchar(10),"merchant_id" bigint,"channel" text,"status" text,"currency" text,"count" bigint NOT NULL,"amount_satang" bigint NOT NULL,PRIMARY KEY ("day","merchant_id","channel","status","currency"));
+CREATE TABLE "transaction_rollup_days" ("day" varchar(10),"refreshed_at" timestamptz NOT NULL,"groups" bigint,PRIMARY KEY ("day"));
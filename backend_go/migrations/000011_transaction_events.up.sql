@@ -0,0 +1,9 @@
+-- Append-only transaction status history (models.TransactionEvent), seeded from the
+-- transaction.status_change audit entries written before it existed.
+CREATE TABLE "transaction_events" ("id" bigserial,"created_at" timestamptz,"transaction_id" bigint NOT NULL,"charge_id" text,"old_status" varchar(20),"new_status" varchar(20) NOT NULL,"source" varchar(10) NOT NULL,"failure_code" text,PRIMARY KEY ("id"));
+CREATE INDEX "idx_transaction_events_transaction" ON "transaction_events" ("transaction_id","created_at");
+INSERT INTO "transaction_events" ("created_at","transaction_id","charge_id","old_status","new_status","source","failure_code")
+SELECT a.created_at, a.entity_id::bigint, a.after->>'charge_id', COALESCE(a.before->>'status', ''), a.after->>'status', 'backfill', a.after->>'failure_code'
+FROM "audit_logs" a
+WHERE a.action = 'transaction.status_change' AND a.entity_type = 'transaction' AND a.entity_id ~ '^[0-9]+$' AND a.after->>'status' IS NOT NULL
+ORDER BY a.created_at, a.id;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS "line_notifications";
+DROP INDEX IF EXISTS "idx_users_line_user_id";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "line_user_id";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "expires_at";
@@ -0,0 +1,7 @@
+-- Linked LINE accounts (users.line_user_id), the LINE messages pushed about transactions
+-- (models.LineNotification), and when a charge expires (transactions.expires_at) for QR reminders.
+ALTER TABLE "transactions" ADD COLUMN "expires_at" timestamptz;
+ALTER TABLE "users" ADD COLUMN "line_user_id" varchar(33);
+CREATE UNIQUE INDEX "idx_users_line_user_id" ON "users" ("line_user_id");
+CREATE TABLE "line_notifications" ("id" bigserial,"created_at" timestamptz,"transaction_id" bigint NOT NULL,"kind" varchar(20) NOT NULL,"user_id" bigint NOT NULL,"line_user_id" varchar(33) NOT NULL,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX "idx_line_notifications_transaction_kind" ON "line_notifications" ("transaction_id","kind");
@@ -0,0 +1,7 @@
+-- FCM registration tokens of users' devices (models.DeviceToken) and the payment status pushes sent to
+-- them (models.PushNotification).
+CREATE TABLE "device_tokens" ("id" bigserial,"created_at" timestamptz,"updated_at" timestamptz,"user_id" bigint NOT NULL,"token" varchar(4096) NOT NULL,"platform" varchar(10) NOT NULL,PRIMARY KEY ("id"));
+CREATE INDEX "idx_device_tokens_user_id" ON "device_tokens" ("user_id");
+CREATE UNIQUE INDEX "idx_device_tokens_token" ON "device_tokens" ("token");
+CREATE TABLE "push_notifications" ("id" bigserial,"created_at" timestamptz,"transaction_id" bigint NOT NULL,"kind" varchar(20) NOT NULL,"user_id" bigint NOT NULL,"devices" bigint NOT NULL,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX "idx_push_notifications_transaction_kind" ON "push_notifications" ("transaction_id","kind");
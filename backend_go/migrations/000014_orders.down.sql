@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS "idx_transactions_order_id";
+ALTER TABLE "transactions" DROP CONSTRAINT IF EXISTS "fk_transactions_order";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "order_id";
+DROP TABLE IF EXISTS "order_items";
+DROP TABLE IF EXISTS "orders";
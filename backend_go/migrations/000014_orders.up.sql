@@ -0,0 +1,11 @@
+-- Orders (models.Order, models.OrderItem) created before charging, and the order a transaction pays
+-- for (transactions.order_id).
+CREATE TABLE "orders" ("id" bigserial,"created_at" timestamptz,"updated_at" timestamptz,"user_id" bigint,"status" varchar(20) NOT NULL,"currency" varchar(3) NOT NULL,"total_satang" bigint NOT NULL,"paid_at" timestamptz,"paid_transaction_id" bigint,"created_by" varchar(100),PRIMARY KEY ("id"));
+CREATE INDEX "idx_orders_user_id" ON "orders" ("user_id");
+CREATE INDEX "idx_orders_status" ON "orders" ("status");
+CREATE TABLE "order_items" ("id" bigserial,"order_id" bigint NOT NULL,"course_id" bigint NOT NULL,"class_id" bigint,"description" varchar(255),"quantity" bigint NOT NULL,"unit_satang" bigint NOT NULL,"amount_satang" bigint NOT NULL,PRIMARY KEY ("id"),CONSTRAINT "fk_orders_items" FOREIGN KEY ("order_id") REFERENCES "orders"("id"));
+CREATE INDEX "idx_order_items_order_id" ON "order_items" ("order_id");
+CREATE INDEX "idx_order_items_course_id" ON "order_items" ("course_id");
+ALTER TABLE "transactions" ADD COLUMN "order_id" bigint;
+ALTER TABLE "transactions" ADD CONSTRAINT "fk_transactions_order" FOREIGN KEY ("order_id") REFERENCES "orders"("id");
+CREATE INDEX "idx_transactions_order_id" ON "transactions" ("order_id");
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS "idx_transactions_coupon_id";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "discount_satang";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "coupon_id";
+DROP TABLE IF EXISTS "coupon_redemptions";
+DROP TABLE IF EXISTS "coupons";
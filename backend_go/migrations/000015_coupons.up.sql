@@ -0,0 +1,11 @@
+-- Coupons applied to order totals at charge time (models.Coupon), their redemptions by successful
+-- charges (models.CouponRedemption), and the discount recorded on each transaction.
+CREATE TABLE "coupons" ("id" bigserial,"created_at" timestamptz,"updated_at" timestamptz,"code" varchar(40) NOT NULL,"kind" varchar(10) NOT NULL,"percent_off" bigint,"amount_off_satang" bigint,"currency" varchar(3),"max_redemptions" bigint NOT NULL DEFAULT 0,"max_per_user" bigint NOT NULL DEFAULT 0,"redemptions" bigint NOT NULL DEFAULT 0,"expires_at" timestamptz,"active" boolean NOT NULL DEFAULT true,"created_by" varchar(100),PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX "idx_coupons_code" ON "coupons" ("code");
+CREATE TABLE "coupon_redemptions" ("id" bigserial,"created_at" timestamptz,"coupon_id" bigint NOT NULL,"user_id" bigint,"order_id" bigint,"transaction_id" bigint NOT NULL,"discount_satang" bigint,PRIMARY KEY ("id"));
+CREATE INDEX "idx_coupon_redemptions_coupon_id" ON "coupon_redemptions" ("coupon_id");
+CREATE INDEX "idx_coupon_redemptions_user_id" ON "coupon_redemptions" ("user_id");
+CREATE UNIQUE INDEX "idx_coupon_redemptions_transaction_id" ON "coupon_redemptions" ("transaction_id");
+ALTER TABLE "transactions" ADD COLUMN "coupon_id" bigint;
+ALTER TABLE "transactions" ADD COLUMN "discount_satang" bigint NOT NULL DEFAULT 0;
+CREATE INDEX "idx_transactions_coupon_id" ON "transactions" ("coupon_id");
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS "tax_invoice_sequences";
+DROP INDEX IF EXISTS "idx_transactions_tax_invoice_number";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "tax_invoice_number";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "vat_satang";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "vat_rate_bps";
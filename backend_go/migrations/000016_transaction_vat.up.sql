@@ -0,0 +1,7 @@
+-- The VAT and tax invoice number of each successful transaction, and the monthly tax invoice
+-- number series (models.TaxInvoiceSequence).
+ALTER TABLE "transactions" ADD COLUMN "vat_rate_bps" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "transactions" ADD COLUMN "vat_satang" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "transactions" ADD COLUMN "tax_invoice_number" varchar(32);
+CREATE UNIQUE INDEX "idx_transactions_tax_invoice_number" ON "transactions" ("tax_invoice_number");
+CREATE TABLE "tax_invoice_sequences" ("period" varchar(6),"last_number" bigint NOT NULL,"updated_at" timestamptz,PRIMARY KEY ("period"));
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS "idx_transactions_payment_link_id";
+ALTER TABLE "transactions" DROP CONSTRAINT IF EXISTS "fk_transactions_payment_link";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "payment_link_id";
+DROP TABLE IF EXISTS "payment_links";
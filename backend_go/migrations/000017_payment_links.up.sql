@@ -0,0 +1,9 @@
+-- Payment links tutors share for one-off payments (models.PaymentLink), and the link a transaction
+-- pays (transactions.payment_link_id).
+CREATE TABLE "payment_links" ("id" bigserial,"created_at" timestamptz,"updated_at" timestamptz,"token" varchar(32) NOT NULL,"tutor_id" bigint,"payer_user_id" bigint,"amount_satang" bigint NOT NULL,"currency" varchar(3) NOT NULL,"description" varchar(255) NOT NULL,"expires_at" timestamptz NOT NULL,"status" varchar(20) NOT NULL,"paid_at" timestamptz,"paid_transaction_id" bigint,"created_by" varchar(100),PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX "idx_payment_links_token" ON "payment_links" ("token");
+CREATE INDEX "idx_payment_links_tutor_id" ON "payment_links" ("tutor_id");
+CREATE INDEX "idx_payment_links_status" ON "payment_links" ("status");
+ALTER TABLE "transactions" ADD COLUMN "payment_link_id" bigint;
+ALTER TABLE "transactions" ADD CONSTRAINT "fk_transactions_payment_link" FOREIGN KEY ("payment_link_id") REFERENCES "payment_links"("id");
+CREATE INDEX "idx_transactions_payment_link_id" ON "transactions" ("payment_link_id");
@@ -0,0 +1,6 @@
+-- Payment intents (models.PaymentIntent): server-fixed charges confirmed by the client.
+CREATE TABLE "payment_intents" ("id" bigserial,"created_at" timestamptz,"updated_at" timestamptz,"merchant_id" bigint NOT NULL DEFAULT 1,"user_id" bigint,"order_id" bigint,"amount_satang" bigint NOT NULL,"currency" varchar(3) NOT NULL,"description" varchar(255),"coupon_code" varchar(40),"metadata" jsonb,"status" varchar(30) NOT NULL,"charge_id" varchar(64),"last_error" varchar(255),"expires_at" timestamptz NOT NULL,PRIMARY KEY ("id"));
+CREATE INDEX "idx_payment_intents_user_id" ON "payment_intents" ("user_id");
+CREATE INDEX "idx_payment_intents_order_id" ON "payment_intents" ("order_id");
+CREATE INDEX "idx_payment_intents_status" ON "payment_intents" ("status");
+CREATE INDEX "idx_payment_intents_charge_id" ON "payment_intents" ("charge_id");
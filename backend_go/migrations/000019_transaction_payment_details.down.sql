@@ -0,0 +1,3 @@
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "bank";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "card_last_digits";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "card_brand";
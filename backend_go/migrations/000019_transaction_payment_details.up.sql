@@ -0,0 +1,4 @@
+-- Card brand, last digits and bank of each transaction's payment method, taken from the charge.
+ALTER TABLE "transactions" ADD COLUMN "card_brand" varchar(20);
+ALTER TABLE "transactions" ADD COLUMN "card_last_digits" varchar(4);
+ALTER TABLE "transactions" ADD COLUMN "bank" varchar(100);
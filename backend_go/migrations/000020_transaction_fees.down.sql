@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS "idx_transactions_fees_unsynced";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "fees_synced_at";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "net_satang";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "fee_vat_satang";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "fee_satang";
@@ -0,0 +1,7 @@
+-- Omise's fee, the VAT on it and the net amount of each successful charge, synced by the charge_fees
+-- job; the partial index finds the ones still to sync.
+ALTER TABLE "transactions" ADD COLUMN "fee_satang" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "transactions" ADD COLUMN "fee_vat_satang" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "transactions" ADD COLUMN "net_satang" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "transactions" ADD COLUMN "fees_synced_at" timestamptz;
+CREATE INDEX "idx_transactions_fees_unsynced" ON "transactions" ("created_at", "id") WHERE status = 'successful' AND fees_synced_at IS NULL AND deleted_at IS NULL;
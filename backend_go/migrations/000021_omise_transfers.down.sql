@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS "idx_transactions_omise_transfer_id";
+ALTER TABLE "transactions" DROP CONSTRAINT IF EXISTS "fk_transactions_transfer";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "omise_transfer_id";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "transferable_at";
+DROP TABLE IF EXISTS "omise_transfers";
@@ -0,0 +1,12 @@
+-- Omise transfers (payouts to our bank account, models.OmiseTransfer) and the transfer each charge was
+-- paid out in. Charges get their transferable time with their fees, so successful charges are synced
+-- again to fill it in.
+CREATE TABLE "omise_transfers" ("id" bigserial,"created_at" timestamptz,"updated_at" timestamptz,"merchant_id" bigint NOT NULL DEFAULT 1,"transfer_id" varchar(64) NOT NULL,"amount_satang" bigint NOT NULL,"fee_satang" bigint NOT NULL DEFAULT 0,"currency" varchar(3) NOT NULL,"sent" boolean NOT NULL DEFAULT false,"paid" boolean NOT NULL DEFAULT false,"failure_code" varchar(100),"transferred_at" timestamptz NOT NULL,"matched_at" timestamptz,"matched_charges" bigint NOT NULL DEFAULT 0,"matched_net_satang" bigint NOT NULL DEFAULT 0,PRIMARY KEY ("id"));
+CREATE INDEX "idx_omise_transfers_merchant_id" ON "omise_transfers" ("merchant_id");
+CREATE UNIQUE INDEX "idx_omise_transfers_transfer_id" ON "omise_transfers" ("transfer_id");
+CREATE INDEX "idx_omise_transfers_transferred_at" ON "omise_transfers" ("transferred_at");
+ALTER TABLE "transactions" ADD COLUMN "transferable_at" timestamptz;
+ALTER TABLE "transactions" ADD COLUMN "omise_transfer_id" bigint;
+ALTER TABLE "transactions" ADD CONSTRAINT "fk_transactions_transfer" FOREIGN KEY ("omise_transfer_id") REFERENCES "omise_transfers"("id");
+CREATE INDEX "idx_transactions_omise_transfer_id" ON "transactions" ("omise_transfer_id");
+UPDATE "transactions" SET "fees_synced_at" = NULL WHERE "status" = 'successful' AND "deleted_at" IS NULL;
@@ -0,0 +1,2 @@
+-- Pending charges by deadline, for the expire_pending job (service.ExpireStalePending).
+CREATE INDEX "idx_transactions_pending_expires_at" ON "transactions" ("expires_at") WHERE status = 'pending' AND deleted_at IS NULL;
@@ -0,0 +1 @@
+ALTER TABLE "users" DROP COLUMN IF EXISTS "personal_data_erased_at";
@@ -0,0 +1,3 @@
+-- When a user's personal data was erased under PDPA (see handlers.ErasePersonalData); financial
+-- records stay.
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "personal_data_erased_at" timestamptz;
@@ -0,0 +1,3 @@
+-- Users, cards (by Omise fingerprint) and email addresses refused at charge time (models.BlocklistEntry).
+CREATE TABLE "blocklist_entries" ("id" bigserial,"created_at" timestamptz,"updated_at" timestamptz,"kind" varchar(20) NOT NULL,"value" varchar(255) NOT NULL,"reason" varchar(255) NOT NULL,"created_by" varchar(100),PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX "idx_blocklist_kind_value" ON "blocklist_entries" ("kind","value");
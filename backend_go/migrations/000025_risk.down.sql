@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS "idx_transactions_risk_review";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "risk_reasons";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "risk_score";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "risk_decision";
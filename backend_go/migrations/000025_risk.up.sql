@@ -0,0 +1,5 @@
+-- Pre-charge risk assessments (service.assessRisk), and flagged charges for review.
+ALTER TABLE "transactions" ADD COLUMN "risk_decision" varchar(10);
+ALTER TABLE "transactions" ADD COLUMN "risk_score" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "transactions" ADD COLUMN "risk_reasons" varchar(255);
+CREATE INDEX "idx_transactions_risk_review" ON "transactions" ("created_at") WHERE risk_decision = 'flag' AND deleted_at IS NULL;
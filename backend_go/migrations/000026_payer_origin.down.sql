@@ -0,0 +1,2 @@
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "ip_country";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "client_ip";
@@ -0,0 +1,3 @@
+-- Where each charge was created from: the payer's IP address and its country (service.checkCountry).
+ALTER TABLE "transactions" ADD COLUMN "client_ip" varchar(45);
+ALTER TABLE "transactions" ADD COLUMN "ip_country" varchar(2);
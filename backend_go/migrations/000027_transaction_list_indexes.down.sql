@@ -0,0 +1,4 @@
+CREATE INDEX IF NOT EXISTS "idx_transactions_user_id" ON "transactions" ("user_id");
+DROP INDEX IF EXISTS "idx_transactions_channel_created";
+DROP INDEX IF EXISTS "idx_transactions_status_created";
+DROP INDEX IF EXISTS "idx_transactions_user_created";
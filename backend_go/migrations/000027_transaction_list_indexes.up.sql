@@ -0,0 +1,6 @@
+-- Transaction listings by user, status and channel, newest first (repository.TransactionFilter); the
+-- user index replaces the one on user_id alone.
+CREATE INDEX "idx_transactions_user_created" ON "transactions" ("user_id","created_at" DESC);
+CREATE INDEX "idx_transactions_status_created" ON "transactions" ("status","created_at");
+CREATE INDEX "idx_transactions_channel_created" ON "transactions" ("channel","created_at");
+DROP INDEX IF EXISTS "idx_transactions_user_id";
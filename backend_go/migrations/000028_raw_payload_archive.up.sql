@@ -0,0 +1,2 @@
+-- Where a pruned raw payload was archived (service.PruneRawPayloads with RAW_PAYLOAD_ARCHIVE_BUCKET).
+ALTER TABLE "transactions" ADD COLUMN "raw_payload_object" varchar(255);
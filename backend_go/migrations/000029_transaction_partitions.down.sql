@@ -0,0 +1,35 @@
+-- Back to one table. Fails if two partitions hold the same charge id or id, which the partitioned
+-- table could not prevent.
+ALTER TABLE "transactions" RENAME TO "transactions_partitioned";
+CREATE TABLE "transactions" (LIKE "transactions_partitioned" INCLUDING DEFAULTS INCLUDING GENERATED);
+ALTER TABLE "transactions" ALTER COLUMN "created_at" DROP NOT NULL;
+INSERT INTO "transactions" ("id","created_at","updated_at","deleted_at","user_id","acting_user_id","charge_id","amount_satang","currency","channel","status","failure_code","failure_message","raw_payload","meta","merchant_id","provider","description","expires_at","order_id","coupon_id","discount_satang","vat_rate_bps","vat_satang","tax_invoice_number","payment_link_id","card_brand","card_last_digits","bank","fee_satang","fee_vat_satang","net_satang","fees_synced_at","transferable_at","omise_transfer_id","risk_decision","risk_score","risk_reasons","client_ip","ip_country","raw_payload_object")
+    SELECT "id","created_at","updated_at","deleted_at","user_id","acting_user_id","charge_id","amount_satang","currency","channel","status","failure_code","failure_message","raw_payload","meta","merchant_id","provider","description","expires_at","order_id","coupon_id","discount_satang","vat_rate_bps","vat_satang","tax_invoice_number","payment_link_id","card_brand","card_last_digits","bank","fee_satang","fee_vat_satang","net_satang","fees_synced_at","transferable_at","omise_transfer_id","risk_decision","risk_score","risk_reasons","client_ip","ip_country","raw_payload_object" FROM "transactions_partitioned";
+ALTER SEQUENCE "transactions_id_seq" OWNED BY "transactions"."id";
+DROP TABLE "transactions_partitioned";
+DROP FUNCTION IF EXISTS create_transaction_partitions(timestamptz, timestamptz);
+
+ALTER TABLE "transactions" ADD PRIMARY KEY ("id");
+ALTER TABLE "transactions" ADD CONSTRAINT "fk_transactions_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE SET NULL ON UPDATE CASCADE;
+ALTER TABLE "transactions" ADD CONSTRAINT "fk_transactions_merchant" FOREIGN KEY ("merchant_id") REFERENCES "merchants"("id");
+ALTER TABLE "transactions" ADD CONSTRAINT "fk_transactions_order" FOREIGN KEY ("order_id") REFERENCES "orders"("id");
+ALTER TABLE "transactions" ADD CONSTRAINT "fk_transactions_payment_link" FOREIGN KEY ("payment_link_id") REFERENCES "payment_links"("id");
+ALTER TABLE "transactions" ADD CONSTRAINT "fk_transactions_transfer" FOREIGN KEY ("omise_transfer_id") REFERENCES "omise_transfers"("id");
+ALTER TABLE "dispute_cases" ADD CONSTRAINT "fk_dispute_cases_transaction" FOREIGN KEY ("transaction_id") REFERENCES "transactions"("id");
+CREATE UNIQUE INDEX "idx_transactions_charge_id" ON "transactions" ("charge_id");
+CREATE UNIQUE INDEX "idx_transactions_tax_invoice_number" ON "transactions" ("tax_invoice_number");
+CREATE INDEX "idx_transactions_acting_user_id" ON "transactions" ("acting_user_id");
+CREATE INDEX "idx_transactions_deleted_at" ON "transactions" ("deleted_at");
+CREATE INDEX "idx_transactions_merchant_id" ON "transactions" ("merchant_id");
+CREATE INDEX "idx_transactions_created_id" ON "transactions" ("created_at","id");
+CREATE INDEX "idx_transactions_search" ON "transactions" USING gin ("search");
+CREATE INDEX "idx_transactions_order_id" ON "transactions" ("order_id");
+CREATE INDEX "idx_transactions_coupon_id" ON "transactions" ("coupon_id");
+CREATE INDEX "idx_transactions_payment_link_id" ON "transactions" ("payment_link_id");
+CREATE INDEX "idx_transactions_omise_transfer_id" ON "transactions" ("omise_transfer_id");
+CREATE INDEX "idx_transactions_user_created" ON "transactions" ("user_id","created_at" DESC);
+CREATE INDEX "idx_transactions_status_created" ON "transactions" ("status","created_at");
+CREATE INDEX "idx_transactions_channel_created" ON "transactions" ("channel","created_at");
+CREATE INDEX "idx_transactions_fees_unsynced" ON "transactions" ("created_at", "id") WHERE status = 'successful' AND fees_synced_at IS NULL AND deleted_at IS NULL;
+CREATE INDEX "idx_transactions_pending_expires_at" ON "transactions" ("expires_at") WHERE status = 'pending' AND deleted_at IS NULL;
+CREATE INDEX "idx_transactions_risk_review" ON "transactions" ("created_at") WHERE risk_decision = 'flag' AND deleted_at IS NULL;
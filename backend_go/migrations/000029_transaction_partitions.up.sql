@@ -0,0 +1,67 @@
+-- Transactions become range partitions by month of created_at (Bangkok months, like the reports), so
+-- month-scoped queries scan their months only and old months can be detached and archived whole.
+-- Rows are copied under an exclusive lock: run this migration in a maintenance window.
+--
+-- A partitioned table's unique indexes must include created_at: the charge id and tax invoice number
+-- are unique per created_at, which is the charge's own creation time and so the same for every copy
+-- of a charge (service.RecordCharge), and the primary key is (id, created_at). Nothing can reference
+-- transactions(id) alone, so dispute_cases loses its foreign key.
+
+-- create_transaction_partitions creates the monthly partitions covering [since, upto] that do not
+-- exist yet, named transactions_YYYY_MM, and returns how many it created. The transaction_partitions
+-- job keeps months ahead of now; transactions_default catches rows outside every partition.
+CREATE FUNCTION create_transaction_partitions(since timestamptz, upto timestamptz) RETURNS integer
+LANGUAGE plpgsql AS $$
+DECLARE
+    bkk_month timestamp := date_trunc('month', since AT TIME ZONE 'Asia/Bangkok');
+    part text;
+    created integer := 0;
+BEGIN
+    WHILE bkk_month <= upto AT TIME ZONE 'Asia/Bangkok' LOOP
+        part := 'transactions_' || to_char(bkk_month, 'YYYY_MM');
+        IF to_regclass(part) IS NULL THEN
+            EXECUTE format('CREATE TABLE %I PARTITION OF "transactions" FOR VALUES FROM (%L) TO (%L)',
+                part, bkk_month AT TIME ZONE 'Asia/Bangkok', (bkk_month + interval '1 month') AT TIME ZONE 'Asia/Bangkok');
+            created := created + 1;
+        END IF;
+        bkk_month := bkk_month + interval '1 month';
+    END LOOP;
+    RETURN created;
+END
+$$;
+
+ALTER TABLE "transactions" RENAME TO "transactions_unpartitioned";
+UPDATE "transactions_unpartitioned" SET "created_at" = COALESCE("updated_at", now()) WHERE "created_at" IS NULL;
+CREATE TABLE "transactions" (LIKE "transactions_unpartitioned" INCLUDING DEFAULTS INCLUDING GENERATED) PARTITION BY RANGE ("created_at");
+ALTER TABLE "transactions" ALTER COLUMN "created_at" SET NOT NULL;
+CREATE TABLE "transactions_default" PARTITION OF "transactions" DEFAULT;
+SELECT create_transaction_partitions(COALESCE((SELECT MIN("created_at") FROM "transactions_unpartitioned"), now()), now() + interval '3 months');
+INSERT INTO "transactions" ("id","created_at","updated_at","deleted_at","user_id","acting_user_id","charge_id","amount_satang","currency","channel","status","failure_code","failure_message","raw_payload","meta","merchant_id","provider","description","expires_at","order_id","coupon_id","discount_satang","vat_rate_bps","vat_satang","tax_invoice_number","payment_link_id","card_brand","card_last_digits","bank","fee_satang","fee_vat_satang","net_satang","fees_synced_at","transferable_at","omise_transfer_id","risk_decision","risk_score","risk_reasons","client_ip","ip_country","raw_payload_object")
+    SELECT "id","created_at","updated_at","deleted_at","user_id","acting_user_id","charge_id","amount_satang","currency","channel","status","failure_code","failure_message","raw_payload","meta","merchant_id","provider","description","expires_at","order_id","coupon_id","discount_satang","vat_rate_bps","vat_satang","tax_invoice_number","payment_link_id","card_brand","card_last_digits","bank","fee_satang","fee_vat_satang","net_satang","fees_synced_at","transferable_at","omise_transfer_id","risk_decision","risk_score","risk_reasons","client_ip","ip_country","raw_payload_object" FROM "transactions_unpartitioned";
+ALTER SEQUENCE "transactions_id_seq" OWNED BY "transactions"."id";
+ALTER TABLE "dispute_cases" DROP CONSTRAINT IF EXISTS "fk_dispute_cases_transaction";
+DROP TABLE "transactions_unpartitioned";
+
+ALTER TABLE "transactions" ADD PRIMARY KEY ("id","created_at");
+ALTER TABLE "transactions" ADD CONSTRAINT "fk_transactions_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE SET NULL ON UPDATE CASCADE;
+ALTER TABLE "transactions" ADD CONSTRAINT "fk_transactions_merchant" FOREIGN KEY ("merchant_id") REFERENCES "merchants"("id");
+ALTER TABLE "transactions" ADD CONSTRAINT "fk_transactions_order" FOREIGN KEY ("order_id") REFERENCES "orders"("id");
+ALTER TABLE "transactions" ADD CONSTRAINT "fk_transactions_payment_link" FOREIGN KEY ("payment_link_id") REFERENCES "payment_links"("id");
+ALTER TABLE "transactions" ADD CONSTRAINT "fk_transactions_transfer" FOREIGN KEY ("omise_transfer_id") REFERENCES "omise_transfers"("id");
+CREATE UNIQUE INDEX "idx_transactions_charge_id" ON "transactions" ("charge_id","created_at");
+CREATE UNIQUE INDEX "idx_transactions_tax_invoice_number" ON "transactions" ("tax_invoice_number","created_at");
+CREATE INDEX "idx_transactions_acting_user_id" ON "transactions" ("acting_user_id");
+CREATE INDEX "idx_transactions_deleted_at" ON "transactions" ("deleted_at");
+CREATE INDEX "idx_transactions_merchant_id" ON "transactions" ("merchant_id");
+CREATE INDEX "idx_transactions_created_id" ON "transactions" ("created_at","id");
+CREATE INDEX "idx_transactions_search" ON "transactions" USING gin ("search");
+CREATE INDEX "idx_transactions_order_id" ON "transactions" ("order_id");
+CREATE INDEX "idx_transactions_coupon_id" ON "transactions" ("coupon_id");
+CREATE INDEX "idx_transactions_payment_link_id" ON "transactions" ("payment_link_id");
+CREATE INDEX "idx_transactions_omise_transfer_id" ON "transactions" ("omise_transfer_id");
+CREATE INDEX "idx_transactions_user_created" ON "transactions" ("user_id","created_at" DESC);
+CREATE INDEX "idx_transactions_status_created" ON "transactions" ("status","created_at");
+CREATE INDEX "idx_transactions_channel_created" ON "transactions" ("channel","created_at");
+CREATE INDEX "idx_transactions_fees_unsynced" ON "transactions" ("created_at", "id") WHERE status = 'successful' AND fees_synced_at IS NULL AND deleted_at IS NULL;
+CREATE INDEX "idx_transactions_pending_expires_at" ON "transactions" ("expires_at") WHERE status = 'pending' AND deleted_at IS NULL;
+CREATE INDEX "idx_transactions_risk_review" ON "transactions" ("created_at") WHERE risk_decision = 'flag' AND deleted_at IS NULL;
@@ -0,0 +1,4 @@
+ALTER TABLE "dispute_cases" DROP CONSTRAINT IF EXISTS "fk_dispute_cases_transaction";
+ALTER TABLE "dispute_cases" DROP COLUMN IF EXISTS "transaction_created_at";
+ALTER TABLE "transactions" DROP CONSTRAINT IF EXISTS "fk_transactions_charge_id";
+DROP TABLE IF EXISTS "transaction_charge_ids";
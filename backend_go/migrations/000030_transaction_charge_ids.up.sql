@@ -0,0 +1,16 @@
+-- The partitioned transactions table (000029) can only keep charge ids unique per created_at, and
+-- nothing could reference transactions(id) alone. transaction_charge_ids, not partitioned, holds
+-- each charge id once with the created_at its row is recorded at; every transaction's (charge_id,
+-- created_at) must be in it, so a charge id has one row across all partitions. repository's
+-- UpsertByChargeID registers the charge id in the DB transaction that writes its row. Fails if two
+-- partitions already hold the same charge id.
+CREATE TABLE "transaction_charge_ids" ("charge_id" text NOT NULL,"created_at" timestamptz NOT NULL,PRIMARY KEY ("charge_id"));
+CREATE UNIQUE INDEX "idx_transaction_charge_ids_charge_created" ON "transaction_charge_ids" ("charge_id","created_at");
+INSERT INTO "transaction_charge_ids" ("charge_id","created_at") SELECT "charge_id","created_at" FROM "transactions" WHERE "charge_id" IS NOT NULL;
+ALTER TABLE "transactions" ADD CONSTRAINT "fk_transactions_charge_id" FOREIGN KEY ("charge_id","created_at") REFERENCES "transaction_charge_ids"("charge_id","created_at");
+
+-- Dispute cases reference their transaction by the whole primary key, (id, created_at).
+ALTER TABLE "dispute_cases" ADD COLUMN "transaction_created_at" timestamptz;
+UPDATE "dispute_cases" SET "transaction_created_at" = t."created_at" FROM "transactions" t WHERE t."id" = "dispute_cases"."transaction_id";
+ALTER TABLE "dispute_cases" ALTER COLUMN "transaction_created_at" SET NOT NULL;
+ALTER TABLE "dispute_cases" ADD CONSTRAINT "fk_dispute_cases_transaction" FOREIGN KEY ("transaction_id","transaction_created_at") REFERENCES "transactions"("id","created_at");
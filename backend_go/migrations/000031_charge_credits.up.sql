@@ -0,0 +1,11 @@
+-- charge_credits records the wallet credit of each successful charge, once per charge id: crediting a
+-- charge inserts its row in the DB transaction that credits the wallet, and credits nothing when the
+-- row exists (repository's CreditCharge), so a charge succeeding again, by Omise or by a status
+-- override, is not credited twice. Backfilled from the balance.credit audit entries that recorded
+-- charge credits until now.
+CREATE TABLE "charge_credits" ("charge_id" text NOT NULL,"user_id" bigint NOT NULL,"credited_thb" numeric(12,2) NOT NULL,"created_at" timestamptz,PRIMARY KEY ("charge_id"));
+INSERT INTO "charge_credits" ("charge_id","user_id","credited_thb","created_at")
+    SELECT DISTINCT ON ("after"->>'charge_id') "after"->>'charge_id', "entity_id"::bigint, COALESCE(("after"->>'credited_thb')::numeric, 0), "created_at"
+    FROM "audit_logs"
+    WHERE "action" = 'balance.credit' AND "entity_type" = 'user' AND "after"->>'charge_id' IS NOT NULL
+    ORDER BY "after"->>'charge_id', "created_at";
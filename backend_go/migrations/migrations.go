@@ -0,0 +1,142 @@
+// Package migrations holds the versioned SQL schema, embedded in the binary as golang-migrate files
+// (NNNNNN_name.up.sql / NNNNNN_name.down.sql), and the startup check that refuses to run against a
+// database whose schema differs from the one this build expects.
+//
+// Schema changes (new tables, column renames, index changes) are new numbered files; never edit a
+// migration that has been applied anywhere. Apply them with `tutorium-backend migrate up`.
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+	pgxmigrate "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	_ "github.com/jackc/pgx/v5/stdlib" // registers the "pgx" database/sql driver
+	"gorm.io/gorm"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// versionTable is where golang-migrate records the applied version.
+const versionTable = "schema_migrations"
+
+var fileName = regexp.MustCompile(`^(\d+)_[a-z0-9_]+\.(up|down)\.sql$`)
+
+// New returns a migrator over the embedded files for the Postgres database at dsn. It opens its own
+// connection, which Close releases.
+func New(dsn string) (*migrate.Migrate, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, err
+	}
+	driver, err := pgxmigrate.WithInstance(db, &pgxmigrate.Config{MigrationsTable: versionTable})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("migrations: connect: %w", err)
+	}
+	src, err := iofs.New(files, ".")
+	if err != nil {
+		_ = driver.Close()
+		return nil, err
+	}
+	return migrate.NewWithInstance("iofs", src, "pgx5", driver)
+}
+
+// Up applies every pending migration; an up-to-date database is not an error.
+func Up(dsn string) error {
+	m, err := New(dsn)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+	return nil
+}
+
+// Latest is the newest embedded migration version: the schema version this build expects.
+func Latest() (uint, error) {
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return 0, err
+	}
+	var latest uint
+	for _, e := range entries {
+		m := fileName.FindStringSubmatch(e.Name())
+		if m == nil {
+			return 0, fmt.Errorf("migrations: unexpected file %s", e.Name())
+		}
+		v, err := strconv.ParseUint(m[1], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		latest = max(latest, uint(v))
+	}
+	return latest, nil
+}
+
+// Check refuses a schema that differs from this build's: the applied version must be Latest and not
+// dirty (a migration failed halfway), and every table, column and index of models must exist, which
+// catches changes made by hand or by AutoMigrate outside the migrations.
+func Check(db *gorm.DB, models ...interface{}) error {
+	latest, err := Latest()
+	if err != nil {
+		return err
+	}
+	mig := db.Migrator()
+	if !mig.HasTable(versionTable) {
+		return fmt.Errorf("schema is not versioned (no %s table); run `migrate up`", versionTable)
+	}
+	var applied struct {
+		Version uint
+		Dirty   bool
+	}
+	if err := db.Raw("SELECT version, dirty FROM " + versionTable + " LIMIT 1").Scan(&applied).Error; err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+	switch {
+	case applied.Dirty:
+		return fmt.Errorf("schema version %d is dirty (a migration failed halfway); fix the database, then `migrate force %d`", applied.Version, applied.Version)
+	case applied.Version < latest:
+		return fmt.Errorf("schema version %d is behind this build (%d); run `migrate up`", applied.Version, latest)
+	case applied.Version > latest:
+		return fmt.Errorf("schema version %d is ahead of this build (%d); deploy a newer build or `migrate goto %d`", applied.Version, latest, latest)
+	}
+
+	var drift []string
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return err
+		}
+		table := stmt.Schema.Table
+		if !mig.HasTable(table) {
+			drift = append(drift, "missing table "+table)
+			continue
+		}
+		for _, name := range stmt.Schema.DBNames {
+			if !mig.HasColumn(model, name) {
+				drift = append(drift, fmt.Sprintf("missing column %s.%s", table, name))
+			}
+		}
+		for _, idx := range stmt.Schema.ParseIndexes() {
+			if !mig.HasIndex(model, idx.Name) {
+				drift = append(drift, fmt.Sprintf("missing index %s on %s", idx.Name, table))
+			}
+		}
+	}
+	if len(drift) > 0 {
+		return fmt.Errorf("schema drift at version %d: %s", latest, strings.Join(drift, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package migrations
+
+import (
+	"io/fs"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/gorm/schema"
+)
+
+func TestFilesArePairedAndContiguous(t *testing.T) {
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dirs := map[string]map[string]bool{} // version -> up/down present
+	for _, e := range entries {
+		m := fileName.FindStringSubmatch(e.Name())
+		if m == nil {
+			t.Fatalf("unexpected file %s", e.Name())
+		}
+		if dirs[m[1]] == nil {
+			dirs[m[1]] = map[string]bool{}
+		}
+		dirs[m[1]][m[2]] = true
+	}
+	latest, err := Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int(latest) != len(dirs) {
+		t.Errorf("Latest() = %d with %d versions; versions must run 1..N without gaps", latest, len(dirs))
+	}
+	for v, d := range dirs {
+		if !d["up"] || !d["down"] {
+			t.Errorf("version %s needs both an up and a down file, has %v", v, d)
+		}
+	}
+}
+
+func TestMigrationsCreateEveryModelTable(t *testing.T) {
+	ups, err := fs.Glob(files, "*.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var up []byte
+	for _, name := range ups {
+		b, err := fs.ReadFile(files, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		up = append(up, b...)
+	}
+	for _, model := range models.All() {
+		s, err := schema.Parse(model, &sync.Map{}, schema.NamingStrategy{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !regexp.MustCompile(`CREATE TABLE (IF NOT EXISTS )?"` + s.Table + `"`).Match(up) {
+			t.Errorf("no migration creates %s", s.Table)
+		}
+		for _, idx := range s.ParseIndexes() {
+			if !strings.Contains(string(up), `"`+idx.Name+`"`) {
+				t.Errorf("no migration creates index %s on %s", idx.Name, s.Table)
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Audit actions recorded for admin and money-moving operations.
+const (
+	AuditBalanceCredit      = "balance.credit"
+	AuditBalanceDebit       = "balance.debit"
+	AuditBalanceAdjust      = "balance.adjust"
+	AuditBalanceFreeze      = "balance.freeze"
+	AuditBalanceUnfreeze    = "balance.unfreeze"
+	AuditBalanceHold        = "balance.hold"
+	AuditBalanceRelease     = "balance.hold_release"
+	AuditBalanceCapture     = "balance.hold_capture"
+	AuditDisputeOpen        = "dispute.open"
+	AuditDisputeResolve     = "dispute.resolve"
+	AuditRefund             = "transaction.refund"
+	AuditStatusChange       = "transaction.status_change"
+	AuditRawPayloadView     = "transaction.raw_payload_view"
+	AuditTransactionSync    = "transaction.sync"
+	AuditTransactionCancel  = "transaction.cancel"
+	AuditStatusOverride     = "transaction.status_override"
+	AuditPayoutApprove      = "payout.approve"
+	AuditPayoutStatement    = "payout.statement"
+	AuditAutoReloadUpdate   = "auto_reload.update"
+	AuditAutoReloadCharge   = "auto_reload.charge"
+	AuditReportSubscription = "report_subscription.change"
+	AuditLedgerImport       = "ledger.import"
+	AuditChargeBackfill     = "charge.backfill"
+	AuditTransactionBulk    = "transaction.bulk_upsert"
+	AuditUserDataExport     = "user.data_export"
+	AuditTransactionExport  = "transaction.export"
+	AuditInstitutionCreate  = "institution.create"
+	AuditInstitutionMember  = "institution.member_change"
+	AuditOrderCreate        = "order.create"
+	AuditOrderCancel        = "order.cancel"
+	AuditOrderPaid          = "order.paid"
+	AuditCouponChange       = "coupon.change"
+	AuditPaymentLinkCreate  = "payment_link.create"
+	AuditPaymentLinkCancel  = "payment_link.cancel"
+	AuditPaymentLinkPaid    = "payment_link.paid"
+	AuditUserCreate         = "user.create"
+	AuditUserUpdate         = "user.update"
+	AuditUserErase          = "user.personal_data_erase"
+	AuditBlocklistChange    = "blocklist.change"
+	AuditRiskDeny           = "risk.deny"
+)
+
+// AuditLog is an append-only record of who did what to which entity.
+type AuditLog struct {
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time      `gorm:"index" json:"created_at"`
+	Actor      string         `gorm:"size:100;index;not null" json:"actor"` // "admin:<name>", "user:<id>", "system:<component>"
+	Action     string         `gorm:"size:60;index;not null" json:"action"`
+	EntityType string         `gorm:"size:40;index:idx_audit_entity" json:"entity_type"`
+	EntityID   string         `gorm:"size:64;index:idx_audit_entity" json:"entity_id"`
+	Before     datatypes.JSON `gorm:"type:jsonb" json:"before,omitempty"`
+	After      datatypes.JSON `gorm:"type:jsonb" json:"after,omitempty"`
+	IP         string         `gorm:"size:45" json:"ip,omitempty"`
+	Reason     string         `json:"reason,omitempty"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AutoReload is a student's opt-in to automatically top up their wallet from a saved card
+// whenever a debit leaves the balance below ThresholdSatang.
+type AutoReload struct {
+	ID              uint           `gorm:"primaryKey" json:"id"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+	UserID          uint           `gorm:"uniqueIndex;not null" json:"user_id"`
+	Enabled         bool           `gorm:"default:false" json:"enabled"`
+	ThresholdSatang int64          `gorm:"not null" json:"threshold_satang"`
+	AmountSatang    int64          `gorm:"not null" json:"amount_satang"`
+	Currency        string         `gorm:"size:3;default:thb" json:"currency"`
+	NotifyEmail     string         `json:"notify_email,omitempty"`
+
+	// Saved card on Omise (customer + card created from a client-side token)
+	OmiseCustomerID string `json:"-"`
+	OmiseCardID     string `json:"-"`
+	CardBrand       string `json:"card_brand,omitempty"`
+	CardLastDigits  string `json:"card_last_digits,omitempty"`
+
+	// Safeguards
+	MaxPerDay           int        `gorm:"default:3" json:"max_per_day"`
+	LastAttemptAt       *time.Time `json:"last_attempt_at,omitempty"`
+	LastChargeID        string     `json:"last_charge_id,omitempty"`
+	ConsecutiveFailures int        `gorm:"default:0" json:"consecutive_failures"`
+	DisabledReason      *string    `json:"disabled_reason,omitempty"`
+
+	User *User `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
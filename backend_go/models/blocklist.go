@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Blocklist kinds: what BlocklistEntry.Value holds.
+const (
+	BlockUserID          = "user_id"          // a users.id, in decimal
+	BlockCardFingerprint = "card_fingerprint" // Omise's card fingerprint, the same for every token of a card
+	BlockEmail           = "email"            // lower case
+)
+
+// BlocklistEntry refuses charges from a user, a card or an email address (see
+// service.CheckBlocklist); charges already made are not affected.
+type BlocklistEntry struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Kind      string    `gorm:"size:20;not null;uniqueIndex:idx_blocklist_kind_value" json:"kind"`
+	Value     string    `gorm:"size:255;not null;uniqueIndex:idx_blocklist_kind_value" json:"value"`
+	Reason    string    `gorm:"size:255;not null" json:"reason"`
+	CreatedBy string    `gorm:"size:100" json:"created_by,omitempty"`
+}
@@ -1,15 +1,25 @@
 package models
 
 // PaymentRequest is the payload from your frontend to initiate a charge.
+// Validation rules (validate tags) are enforced by handlers before any Omise call.
 type PaymentRequest struct {
-	Amount      int64                  `json:"amount"`               // (satang unit : 100 satang = 1 THB)
-	Currency    string                 `json:"currency"`             // "THB"
-	PaymentType string                 `json:"paymentType"`          // "credit_card" | "promptpay" | "internet_banking"
-	Token       string                 `json:"token,omitempty"`      // for card charges (preferred)
-	ReturnURI   string                 `json:"return_uri,omitempty"` // required for some redirects (3DS/internet banking)
-	Description string                 `json:"description,omitempty"`
-	Metadata    map[string]interface{} `json:"metadata,omitempty"` // free-form, attached to the Omise charge
-	Card        map[string]interface{} `json:"card,omitempty"`     // server-side tokenization (TESTING ONLY)
-	Bank        string                 `json:"bank,omitempty"`     // e.g. "bbl", "bay", "scb"
-	UserID      *uint                  `json:"user_id,omitempty"`  // FK to users.id
+	Amount          int64                  `json:"amount" validate:"required_without=OrderID,omitempty,charge_amount"`                     // minor units of Currency (satang for THB, yen for JPY) within money.ChargeLimits; defaults to the order's total
+	Currency        string                 `json:"currency" validate:"required_without=OrderID,omitempty,currency"`                        // "THB", or another of money.SupportedCurrencies; defaults to the order's currency
+	PaymentType     string                 `json:"paymentType" validate:"required,oneof=credit_card promptpay internet_banking"`           // "credit_card" | "promptpay" | "internet_banking"
+	Token           string                 `json:"token,omitempty" validate:"omitempty,startswith=tokn_"`                                  // for card charges (preferred)
+	ReturnURI       string                 `json:"return_uri,omitempty" validate:"required_if=PaymentType internet_banking,omitempty,url"` // required for some redirects (3DS/internet banking)
+	Description     string                 `json:"description,omitempty" validate:"max=255"`
+	Metadata        map[string]interface{} `json:"metadata,omitempty"`                                                                                 // free-form, attached to the Omise charge
+	Card            map[string]interface{} `json:"card,omitempty"`                                                                                     // server-side tokenization (TESTING ONLY, requires ALLOW_RAW_CARD=true)
+	Bank            string                 `json:"bank,omitempty" validate:"required_if=PaymentType internet_banking,omitempty,oneof=bay bbl ktb scb"` // e.g. "bbl", "bay", "scb"
+	UserID          *uint                  `json:"user_id,omitempty"`                                                                                  // FK to users.id
+	InstitutionID   *uint                  `json:"institution_id,omitempty"`                                                                           // bill an institution; the caller must be a member with "pay"
+	OrderID         *uint                  `json:"order_id,omitempty"`                                                                                 // the pending Order this charge pays for
+	ClientIP        string                 `json:"-"`                                                                                                  // the payer's address, set by handlers, never by clients
+	ClientCountry   string                 `json:"-"`                                                                                                  // the country of ClientIP (GEO_COUNTRY_HEADER), set by handlers
+	PaymentLinkID   *uint                  `json:"-"`                                                                                                  // set by the payment link checkout (PayPaymentLink), never by clients
+	PaymentIntentID *uint                  `json:"-"`                                                                                                  // set by ConfirmIntent, never by clients
+	ActingUserID    *uint                  `json:"-"`                                                                                                  // the institution member making an institution charge, set by handlers, never by clients
+	CouponCode      string                 `json:"coupon_code,omitempty" validate:"omitempty,max=40"`                                                  // discount on the order total; needs OrderID
+	Force           bool                   `json:"force,omitempty"`                                                                                    // charge even if it repeats a recent charge of the user (see service.DuplicateChargeError)
 }
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// ChargeCredit is the wallet credit of a successful charge. A charge is credited once, however often
+// it becomes successful (see repository.UserRepository.CreditCharge).
+type ChargeCredit struct {
+	ChargeID    string    `gorm:"primaryKey" json:"charge_id"`
+	UserID      uint      `gorm:"not null" json:"user_id"`
+	CreditedTHB float64   `gorm:"type:numeric(12,2);not null" json:"credited_thb"`
+	CreatedAt   time.Time `json:"created_at"`
+}
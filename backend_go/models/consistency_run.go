@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Consistency run triggers.
+const (
+	ConsistencyNightly = "nightly"
+	ConsistencyManual  = "manual"
+)
+
+// ConsistencyRun is one execution of the ledger consistency checks. RunKey is unique
+// ("nightly:YYYY-MM-DD" in Bangkok time, "manual:<unix nanos>") so each nightly run happens once
+// even with several replicas scheduling it.
+type ConsistencyRun struct {
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time      `gorm:"index" json:"created_at"`
+	RunKey     string         `gorm:"size:40;not null;uniqueIndex" json:"run_key"`
+	Trigger    string         `gorm:"size:10;not null" json:"trigger"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty"`
+	Violations int            `json:"violations"`
+	Checks     datatypes.JSON `gorm:"type:jsonb" json:"checks,omitempty"` // per-check counts and sample violations
+	Error      string         `json:"error,omitempty"`
+}
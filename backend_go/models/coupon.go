@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// Coupon kinds.
+const (
+	CouponPercent = "percent" // PercentOff percent of the order total
+	CouponFixed   = "fixed"   // AmountOffSatang, in Currency
+)
+
+// Coupon is a discount code applied to an order's total when it is charged (PaymentRequest.CouponCode).
+// The discount is recorded on the transaction (Transaction.DiscountSatang); a redemption is counted when
+// the charge succeeds.
+type Coupon struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	Code            string     `gorm:"size:40;not null;uniqueIndex" json:"code"` // upper case
+	Kind            string     `gorm:"size:10;not null" json:"kind"`
+	PercentOff      int        `json:"percent_off,omitempty"`       // percent: 1-100
+	AmountOffSatang int64      `json:"amount_off_satang,omitempty"` // fixed
+	Currency        string     `gorm:"size:3" json:"currency,omitempty"`
+	MaxRedemptions  int        `gorm:"not null;default:0" json:"max_redemptions"` // 0 is unlimited
+	MaxPerUser      int        `gorm:"not null;default:0" json:"max_per_user"`    // 0 is unlimited
+	Redemptions     int        `gorm:"not null;default:0" json:"redemptions"`     // successful charges that used it
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`
+	Active          bool       `gorm:"not null;default:true" json:"active"`
+	CreatedBy       string     `gorm:"size:100" json:"created_by,omitempty"`
+}
+
+// Discount is what c takes off an order total of totalSatang, never more than the total.
+func (c Coupon) Discount(totalSatang int64) int64 {
+	var off int64
+	switch c.Kind {
+	case CouponPercent:
+		off = totalSatang * int64(c.PercentOff) / 100
+	case CouponFixed:
+		off = c.AmountOffSatang
+	}
+	return min(max(off, 0), totalSatang)
+}
+
+// CouponRedemption is one successful charge that used a coupon; TransactionID is unique, so a charge
+// is counted once however often it is recorded.
+type CouponRedemption struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	CouponID       uint      `gorm:"not null;index" json:"coupon_id"`
+	UserID         *uint     `gorm:"index" json:"user_id,omitempty"`
+	OrderID        *uint     `json:"order_id,omitempty"`
+	TransactionID  uint      `gorm:"not null;uniqueIndex" json:"transaction_id"`
+	DiscountSatang int64     `json:"discount_satang"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Device platforms of a DeviceToken.
+const (
+	DevicePlatformAndroid = "android"
+	DevicePlatformIOS     = "ios"
+)
+
+// DeviceToken is the FCM registration token of one of a user's devices running the mobile app; payment
+// status pushes go to every device of the payer. A token belongs to one user at a time (the one last
+// signed in on the device).
+type DeviceToken struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	Token     string    `gorm:"size:4096;not null;uniqueIndex" json:"token"`
+	Platform  string    `gorm:"size:10;not null" json:"platform"`
+}
+
+// Push notification kinds.
+const (
+	PushPaymentSucceeded = "payment_succeeded"
+	PushPaymentFailed    = "payment_failed"
+)
+
+// PushNotification records a push sent about a transaction, like LineNotification: (TransactionID,
+// Kind) is unique, so each kind is pushed at most once per transaction.
+type PushNotification struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	TransactionID uint      `gorm:"not null;uniqueIndex:idx_push_notifications_transaction_kind,priority:1" json:"transaction_id"`
+	Kind          string    `gorm:"size:20;not null;uniqueIndex:idx_push_notifications_transaction_kind,priority:2" json:"kind"`
+	UserID        uint      `gorm:"not null" json:"user_id"`
+	Devices       int       `gorm:"not null" json:"devices"` // devices it reached
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Dispute case statuses.
+const (
+	DisputeCaseOpen     = "open"
+	DisputeCaseRefunded = "refunded"
+	DisputeCaseRejected = "rejected"
+)
+
+// Recommended resolution paths shown to the student.
+const (
+	DisputePathRefund      = "refund"       // we review and refund directly (fast, no bank involvement)
+	DisputePathBankDispute = "bank_dispute" // student should contact their card issuer / bank
+)
+
+// DisputeCase is an internal review case opened when a student claims a charge was wrong.
+// While open, the disputed amount is moved from the user's balance into FrozenBalance.
+type DisputeCase struct {
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+	TransactionID uint           `gorm:"index;not null" json:"transaction_id"`
+	// TransactionCreatedAt is the transaction's created_at: with TransactionID, the primary key of the
+	// partitioned transactions table that the foreign key references.
+	TransactionCreatedAt time.Time  `gorm:"not null" json:"-"`
+	UserID               uint       `gorm:"index;not null" json:"user_id"`
+	RequestedBy          *uint      `json:"requested_by,omitempty"` // institution member who opened it for the payer user
+	Reason               string     `gorm:"size:40;not null" json:"reason"`
+	Description          string     `json:"description,omitempty"`
+	AmountSatang         int64      `json:"amount_satang"`
+	FrozenSatang         int64      `json:"frozen_satang"`
+	Status               string     `gorm:"size:20;index;not null" json:"status"`
+	RecommendedPath      string     `gorm:"size:20" json:"recommended_path"`
+	Resolution           string     `json:"resolution,omitempty"`
+	ResolvedBy           string     `gorm:"size:100" json:"resolved_by,omitempty"`
+	ResolvedAt           *time.Time `json:"resolved_at,omitempty"`
+	RefundID             string     `json:"refund_id,omitempty"`
+
+	Transaction *Transaction `gorm:"foreignKey:TransactionID" json:"-"`
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Institution member permissions.
+const (
+	InstitutionPermView          = "view"           // see the institution's transactions
+	InstitutionPermPay           = "pay"            // create charges billed to the institution
+	InstitutionPermRefundRequest = "refund_request" // dispute / request a refund of the institution's charges
+)
+
+// Institution is a shared payer entity (a school, a company) whose wallet is the balance of PayerUserID.
+// Several staff logins act for it as InstitutionMembers; each charge records the member who made it.
+type Institution struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	Name        string         `gorm:"size:120;not null" json:"name"`
+	PayerUserID uint           `gorm:"uniqueIndex;not null" json:"payer_user_id"`
+	CreatedBy   string         `gorm:"size:100" json:"created_by,omitempty"`
+
+	Payer *User `gorm:"foreignKey:PayerUserID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
+}
+
+// InstitutionMember grants a staff login (UserID) permissions on an institution. The payer user
+// implicitly has every permission and manages the members.
+type InstitutionMember struct {
+	ID               uint      `gorm:"primaryKey" json:"-"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+	InstitutionID    uint      `gorm:"uniqueIndex:idx_institution_member;not null" json:"institution_id"`
+	UserID           uint      `gorm:"uniqueIndex:idx_institution_member;index;not null" json:"user_id"`
+	CanView          bool      `gorm:"not null;default:false" json:"can_view"`
+	CanPay           bool      `gorm:"not null;default:false" json:"can_pay"`
+	CanRequestRefund bool      `gorm:"not null;default:false" json:"can_request_refund"`
+	AddedBy          string    `gorm:"size:100" json:"added_by,omitempty"`
+
+	Institution *Institution `gorm:"foreignKey:InstitutionID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	User        *User        `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+// Can reports whether the member holds permission perm (one of the InstitutionPerm constants).
+func (m InstitutionMember) Can(perm string) bool {
+	switch perm {
+	case InstitutionPermView:
+		return m.CanView
+	case InstitutionPermPay:
+		return m.CanPay
+	case InstitutionPermRefundRequest:
+		return m.CanRequestRefund
+	}
+	return false
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// JobLease is a named lease held by one replica until ExpiresAt (see jobs.Elector); the "jobs" lease
+// picks the replica that runs the scheduled jobs.
+type JobLease struct {
+	Name      string    `gorm:"primaryKey;size:50" json:"name"`
+	Holder    string    `gorm:"size:100;not null" json:"holder"`
+	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// LINE notification kinds.
+const (
+	LineNotifyPaymentSucceeded = "payment_succeeded"
+	LineNotifyQRExpiring       = "qr_expiring"
+)
+
+// LineNotification records a LINE message pushed about a transaction. (TransactionID, Kind) is unique,
+// so each kind is pushed at most once per transaction even with several replicas or job runs.
+type LineNotification struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	TransactionID uint      `gorm:"not null;uniqueIndex:idx_line_notifications_transaction_kind,priority:1" json:"transaction_id"`
+	Kind          string    `gorm:"size:20;not null;uniqueIndex:idx_line_notifications_transaction_kind,priority:2" json:"kind"`
+	UserID        uint      `gorm:"not null" json:"user_id"`
+	LineUserID    string    `gorm:"size:33;not null" json:"line_user_id"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// DefaultMerchantID is the merchant of the OMISE_* account, which owns every transaction recorded
+// before merchants existed.
+const DefaultMerchantID uint = 1
+
+// Merchant is one Omise account served by this deployment (e.g. the sandbox and a university
+// partner). Only its identity is stored: its Omise keys and webhook secret come from the configuration
+// (MERCHANT_<CODE>_*), and main upserts a row per configured merchant at startup.
+type Merchant struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Code      string    `gorm:"size:50;not null;uniqueIndex" json:"code"` // "default", or the MERCHANTS name
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// OmiseTransfer is a payout of an Omise account's balance to our bank account, imported from Omise by
+// its transfer webhooks and the transfers job. Omise does not say which charges a transfer pays out, so
+// they are matched here (Transaction.OmiseTransferID): every successful charge of the account that
+// became transferable before the transfer was made and is not in an earlier one.
+type OmiseTransfer struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	MerchantID       uint       `gorm:"not null;default:1;index" json:"merchant_id"` // the Omise account paid out
+	TransferID       string     `gorm:"size:64;not null;uniqueIndex" json:"transfer_id"`
+	AmountSatang     int64      `gorm:"not null" json:"amount_satang"` // sent to the bank
+	FeeSatang        int64      `gorm:"not null;default:0" json:"fee_satang"`
+	Currency         string     `gorm:"size:3;not null" json:"currency"`
+	Sent             bool       `gorm:"not null;default:false" json:"sent"`
+	Paid             bool       `gorm:"not null;default:false" json:"paid"`
+	FailureCode      *string    `gorm:"size:100" json:"failure_code,omitempty"`
+	TransferredAt    time.Time  `gorm:"not null;index" json:"transferred_at"` // created at Omise
+	MatchedAt        *time.Time `json:"matched_at,omitempty"`                 // when its charges were matched; nil until then
+	MatchedCharges   int64      `gorm:"not null;default:0" json:"matched_charges"`
+	MatchedNetSatang int64      `gorm:"not null;default:0" json:"matched_net_satang"` // net of the matched charges
+}
+
+// DiscrepancySatang is how much the matched charges' net differs from what the transfer took from
+// the balance (amount and fee): non-zero when refunds, disputes or unmatched charges moved the balance.
+func (t OmiseTransfer) DiscrepancySatang() int64 {
+	return t.MatchedNetSatang - t.AmountSatang - t.FeeSatang
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Order statuses.
+const (
+	OrderPending   = "pending"
+	OrderPaid      = "paid"
+	OrderCancelled = "cancelled"
+)
+
+// Order is what a payer is about to buy (course enrolments, class sessions), created before it is
+// charged for. The charge references it (PaymentRequest.OrderID), the transaction keeps the reference
+// (Transaction.OrderID), and the order becomes paid when such a charge succeeds.
+type Order struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+	UserID            *uint      `gorm:"index" json:"user_id,omitempty"`
+	Status            string     `gorm:"size:20;not null;index" json:"status"`
+	Currency          string     `gorm:"size:3;not null" json:"currency"`
+	TotalSatang       int64      `gorm:"not null" json:"total_satang"` // sum of the items' AmountSatang
+	PaidAt            *time.Time `json:"paid_at,omitempty"`
+	PaidTransactionID *uint      `json:"paid_transaction_id,omitempty"` // the successful charge that paid it
+	CreatedBy         string     `gorm:"size:100" json:"created_by,omitempty"`
+
+	Items []OrderItem `gorm:"foreignKey:OrderID" json:"items"`
+}
+
+// OrderItem is one line of an Order: a course, or one class of it.
+type OrderItem struct {
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	OrderID      uint   `gorm:"index;not null" json:"order_id"`
+	CourseID     uint   `gorm:"index;not null" json:"course_id"`
+	ClassID      *uint  `json:"class_id,omitempty"` // a single class session of the course, if the item is one
+	Description  string `gorm:"size:255" json:"description,omitempty"`
+	Quantity     int    `gorm:"not null" json:"quantity"`
+	UnitSatang   int64  `gorm:"not null" json:"unit_satang"`
+	AmountSatang int64  `gorm:"not null" json:"amount_satang"` // Quantity * UnitSatang
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Payment intent statuses.
+const (
+	IntentRequiresConfirmation = "requires_confirmation" // created, or its last charge attempt was declined
+	IntentProcessing           = "processing"            // a confirm is creating its charge
+	IntentConfirmed            = "confirmed"             // its charge exists (ChargeID); confirming again replays it
+)
+
+// PaymentIntent is a charge whose amount, currency and order the server fixed before the client picks
+// how to pay. Confirming it (with a card token or a source type) creates the charge, at most once:
+// retried confirms return the same charge.
+type PaymentIntent struct {
+	ID           uint              `gorm:"primaryKey" json:"id"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+	MerchantID   uint              `gorm:"not null;default:1" json:"merchant_id"` // the Omise account it is charged on
+	UserID       *uint             `gorm:"index" json:"user_id,omitempty"`        // the payer
+	OrderID      *uint             `gorm:"index" json:"order_id,omitempty"`
+	AmountSatang int64             `gorm:"not null" json:"amount_satang"`
+	Currency     string            `gorm:"size:3;not null" json:"currency"`
+	Description  string            `gorm:"size:255" json:"description,omitempty"`
+	CouponCode   string            `gorm:"size:40" json:"coupon_code,omitempty"`
+	Metadata     datatypes.JSONMap `gorm:"type:jsonb" json:"metadata,omitempty"` // attached to the charge
+	Status       string            `gorm:"size:30;not null;index" json:"status"`
+	ChargeID     *string           `gorm:"size:64;index" json:"charge_id,omitempty"` // the latest charge created for it
+	LastError    string            `gorm:"size:255" json:"last_error,omitempty"`     // why the last confirm did not go through
+	ExpiresAt    time.Time         `gorm:"not null" json:"expires_at"`               // confirmable until then
+}
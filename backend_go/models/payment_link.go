@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Payment link statuses.
+const (
+	PaymentLinkActive    = "active"
+	PaymentLinkPaid      = "paid"
+	PaymentLinkCancelled = "cancelled"
+)
+
+// PaymentLink is a one-off payment request a tutor shares (e.g. over chat) for a private lesson: a
+// fixed amount and description, payable once before ExpiresAt on the hosted checkout page at
+// PAYMENT_LINK_BASE_URL/<Token>. The charge keeps the reference (Transaction.PaymentLinkID), and the
+// link becomes paid when such a charge succeeds.
+type PaymentLink struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+	Token             string     `gorm:"size:32;not null;uniqueIndex" json:"token"` // the unguessable part of the link
+	TutorID           *uint      `gorm:"index" json:"tutor_id,omitempty"`           // the user who is asking to be paid
+	PayerUserID       *uint      `json:"payer_user_id,omitempty"`                   // only this user may pay, if set
+	AmountSatang      int64      `gorm:"not null" json:"amount_satang"`
+	Currency          string     `gorm:"size:3;not null" json:"currency"`
+	Description       string     `gorm:"size:255;not null" json:"description"`
+	ExpiresAt         time.Time  `gorm:"not null" json:"expires_at"`
+	Status            string     `gorm:"size:20;not null;index" json:"status"`
+	PaidAt            *time.Time `json:"paid_at,omitempty"`
+	PaidTransactionID *uint      `json:"paid_transaction_id,omitempty"` // the successful charge that paid it
+	CreatedBy         string     `gorm:"size:100" json:"created_by,omitempty"`
+}
+
+// Payable reports whether l can still be charged at now.
+func (l PaymentLink) Payable(now time.Time) bool {
+	return l.Status == PaymentLinkActive && now.Before(l.ExpiresAt)
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Payout statement states.
+const (
+	PayoutStatementDraft    = "draft"
+	PayoutStatementApproved = "approved"
+)
+
+// Payout reserve ledger entry kinds.
+const (
+	PayoutReserveHold    = "hold"    // part of a statement's earnings withheld
+	PayoutReserveRelease = "release" // a matured hold paid out on a later statement
+)
+
+// PayoutStatement is a teacher's earnings for [PeriodStart, PeriodEnd): successful charges tagged
+// with the teacher (metadata teacher_id), less refunds and the rolling reserve holdback, plus
+// reserve holds that matured by PeriodEnd. Items itemizes every line.
+type PayoutStatement struct {
+	ID                    uint           `gorm:"primaryKey" json:"id"`
+	CreatedAt             time.Time      `json:"created_at"`
+	UpdatedAt             time.Time      `json:"updated_at"`
+	TeacherID             string         `gorm:"size:64;not null;uniqueIndex:idx_payout_statement_period" json:"teacher_id"`
+	Currency              string         `gorm:"size:3;not null;uniqueIndex:idx_payout_statement_period" json:"currency"`
+	PeriodStart           time.Time      `gorm:"not null;uniqueIndex:idx_payout_statement_period" json:"period_start"`
+	PeriodEnd             time.Time      `gorm:"not null" json:"period_end"`
+	GrossSatang           int64          `json:"gross_satang"`
+	RefundsSatang         int64          `json:"refunds_satang"`
+	ReserveHeldSatang     int64          `json:"reserve_held_satang"`
+	ReserveReleasedSatang int64          `json:"reserve_released_satang"`
+	NetSatang             int64          `json:"net_satang"`
+	ReserveBps            int64          `json:"reserve_bps"` // holdback rate applied, basis points of gross
+	Status                string         `gorm:"size:10;not null" json:"status"`
+	Items                 datatypes.JSON `gorm:"type:jsonb" json:"items,omitempty"`
+	CreatedBy             string         `gorm:"size:100" json:"created_by,omitempty"`
+	ApprovedBy            string         `gorm:"size:100" json:"approved_by,omitempty"`
+	ApprovedAt            *time.Time     `json:"approved_at,omitempty"`
+}
+
+// PayoutReserveEntry is the rolling reserve ledger: a hold when a statement withholds earnings and
+// a release (pointing at its hold) when a later statement pays the matured hold out.
+type PayoutReserveEntry struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	TeacherID    string     `gorm:"size:64;not null;index" json:"teacher_id"`
+	Currency     string     `gorm:"size:3;not null" json:"currency"`
+	Kind         string     `gorm:"size:10;not null" json:"kind"`
+	AmountSatang int64      `gorm:"not null" json:"amount_satang"`
+	StatementID  uint       `gorm:"not null;index" json:"statement_id"`
+	ReleaseAfter *time.Time `json:"release_after,omitempty"`              // holds only
+	HoldID       *uint      `gorm:"uniqueIndex" json:"hold_id,omitempty"` // releases only: each hold is released once
+	ReleasedAt   *time.Time `json:"released_at,omitempty"`                // holds only, set when released
+
+	Statement *PayoutStatement `gorm:"foreignKey:StatementID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Reconciliation run triggers.
+const (
+	ReconciliationScheduled = "scheduled"
+	ReconciliationManual    = "manual"
+)
+
+// ReconciliationRun is one comparison of Omise's charge list for [PeriodStart, PeriodEnd) against the
+// transactions table. RunKey is unique ("scheduled:YYYY-MM-DD" for the Bangkok day reconciled,
+// "manual:<unix nanos>") so a scheduled run happens once even with several replicas.
+type ReconciliationRun struct {
+	ID                uint           `gorm:"primaryKey" json:"id"`
+	CreatedAt         time.Time      `gorm:"index" json:"created_at"`
+	RunKey            string         `gorm:"size:40;not null;uniqueIndex" json:"run_key"`
+	Trigger           string         `gorm:"size:10;not null" json:"trigger"`
+	PeriodStart       time.Time      `gorm:"not null" json:"period_start"`
+	PeriodEnd         time.Time      `gorm:"not null" json:"period_end"`
+	Heal              bool           `json:"heal"`
+	FinishedAt        *time.Time     `json:"finished_at,omitempty"`
+	OmiseCharges      int            `json:"omise_charges"`
+	LocalTransactions int            `json:"local_transactions"`
+	Discrepancies     int            `json:"discrepancies"`
+	Healed            int            `json:"healed"`
+	Items             datatypes.JSON `gorm:"type:jsonb" json:"items,omitempty"` // the discrepancies, capped
+	Error             string         `json:"error,omitempty"`
+	CreatedBy         string         `gorm:"size:100" json:"created_by,omitempty"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Refund alert kinds; budget kinds are suffixed with the threshold percent ("budget_80").
+const (
+	RefundAlertBudget  = "budget"
+	RefundAlertAnomaly = "anomaly"
+)
+
+// RefundAlert records an alert sent for a day's refund volume. The unique (day, kind) key makes
+// each alert fire once per day even with several replicas checking.
+type RefundAlert struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	Day            string    `gorm:"size:10;not null;uniqueIndex:idx_refund_alert_day_kind" json:"day"` // YYYY-MM-DD, Bangkok time
+	Kind           string    `gorm:"size:20;not null;uniqueIndex:idx_refund_alert_day_kind" json:"kind"`
+	TotalSatang    int64     `json:"total_satang"`
+	BudgetSatang   int64     `json:"budget_satang"`
+	BaselineSatang int64     `json:"baseline_satang"`
+}
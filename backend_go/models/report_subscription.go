@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Report types an admin can subscribe to.
+const (
+	ReportDailyRevenue           = "daily_revenue"
+	ReportWeeklyRefunds          = "weekly_refunds"
+	ReportMonthlyTeacherEarnings = "monthly_teacher_earnings"
+	ReportMonthlyServiceUsage    = "monthly_service_usage"
+)
+
+// Delivery channels for report subscriptions.
+const (
+	DeliveryEmail = "email"
+	DeliverySlack = "slack"
+)
+
+// ReportSubscription is a recurring report delivered by email or to a Slack channel.
+type ReportSubscription struct {
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+	ReportType string         `gorm:"size:40;not null" json:"report_type"`
+	Channel    string         `gorm:"size:10;not null" json:"channel"` // "email" | "slack"
+	Target     string         `gorm:"not null" json:"target"`          // email address or Slack webhook URL
+	Active     bool           `gorm:"default:true" json:"active"`
+	NextRunAt  time.Time      `gorm:"index" json:"next_run_at"`
+	LastSentAt *time.Time     `json:"last_sent_at,omitempty"`
+	LastError  *string        `json:"last_error,omitempty"`
+	CreatedBy  string         `gorm:"size:100" json:"created_by,omitempty"`
+}
@@ -0,0 +1,17 @@
+package models
+
+// All lists every persisted model, in the order the migrations create their tables. The
+// startup schema check (migrations.Check) compares the database against these.
+func All() []interface{} {
+	return []interface{}{
+		&User{}, &Merchant{}, &Transaction{}, &ReportSubscription{}, &AutoReload{}, &AuditLog{}, &DisputeCase{},
+		&TaxDocument{}, &RefundAlert{}, &WebhookDelivery{}, &WalletOperation{}, &Institution{},
+		&InstitutionMember{}, &ConsistencyRun{}, &PayoutStatement{}, &PayoutReserveEntry{},
+		&UsageCounter{}, &ReconciliationRun{}, &JobLease{}, &WarehouseExport{},
+		&TransactionDailyRollup{}, &TransactionRollupDay{}, &TransactionEvent{}, &LineNotification{},
+		&DeviceToken{}, &PushNotification{},
+		&Order{}, &OrderItem{}, &Coupon{}, &CouponRedemption{},
+		&TaxInvoiceSequence{}, &PaymentLink{}, &PaymentIntent{}, &OmiseTransfer{},
+		&BlocklistEntry{}, &TransactionChargeID{}, &ChargeCredit{},
+	}
+}
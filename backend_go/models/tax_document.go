@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Tax document submission statuses.
+const (
+	TaxDocumentSubmitted = "submitted"
+	TaxDocumentFailed    = "failed"
+)
+
+// TaxDocument stores the external tax provider's reference for a successful transaction
+// (one per transaction). Failed submissions are kept with the error so they can be retried.
+type TaxDocument struct {
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+	TransactionID uint           `gorm:"uniqueIndex;not null" json:"transaction_id"`
+	ChargeID      string         `gorm:"size:64;index" json:"charge_id"`
+	Provider      string         `gorm:"size:40" json:"provider"`
+	Status        string         `gorm:"size:20;index;not null" json:"status"`
+	DocumentID    string         `gorm:"size:128" json:"document_id,omitempty"`
+	Number        string         `gorm:"size:64" json:"number,omitempty"`
+	URL           string         `json:"url,omitempty"`
+	NetSatang     int64          `json:"net_satang"`
+	VATSatang     int64          `json:"vat_satang"`
+	Attempts      int            `gorm:"not null;default:0" json:"attempts"`
+	LastError     string         `json:"last_error,omitempty"`
+}
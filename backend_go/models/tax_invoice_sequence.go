@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// TaxInvoiceSequence numbers the tax invoices of one Bangkok calendar month (Period "YYYYMM"):
+// LastNumber is the last number issued. Numbers are taken in the transaction that records the
+// successful charge, so a rolled-back charge leaves no gap in the series, as Thai tax invoices require.
+type TaxInvoiceSequence struct {
+	Period     string    `gorm:"primaryKey;size:6" json:"period"`
+	LastNumber int64     `gorm:"not null" json:"last_number"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
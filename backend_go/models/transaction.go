@@ -1,27 +1,79 @@
 package models
 
 import (
+	"strings"
 	"time"
 
+	"github.com/a2n2k3p4/tutorium-backend/money"
+
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
 type Transaction struct {
-	ID             uint              `gorm:"primaryKey" json:"id"`
-	CreatedAt      time.Time         `json:"created_at"`
-	UpdatedAt      time.Time         `json:"updated_at"`
-	DeletedAt      gorm.DeletedAt    `gorm:"index" json:"-"`
-	UserID         *uint             `gorm:"index" json:"user_id,omitempty"`
-	ChargeID       string            `gorm:"uniqueIndex" json:"charge_id"`
-	AmountSatang   int64             `json:"amount_satang"`
-	Currency       string            `json:"currency"`
-	Channel        string            `json:"channel"`
-	Status         string            `json:"status"`
-	FailureCode    *string           `json:"failure_code,omitempty"`
-	FailureMessage *string           `json:"failure_message,omitempty"`
-	RawPayload     []byte            `json:"-"`
-	Meta           datatypes.JSONMap `gorm:"type:jsonb" json:"meta,omitempty"`
+	ID               uint              `gorm:"primaryKey;index:idx_transactions_created_id,priority:2" json:"id"`
+	CreatedAt        time.Time         `gorm:"index:idx_transactions_created_id,priority:1;index:idx_transactions_user_created,priority:2,sort:desc;index:idx_transactions_status_created,priority:2;index:idx_transactions_channel_created,priority:2;uniqueIndex:idx_transactions_charge_id,priority:2;uniqueIndex:idx_transactions_tax_invoice_number,priority:2" json:"created_at"` // keyset of List (repository.TransactionCursor); the monthly partition key, so fixed once recorded
+	UpdatedAt        time.Time         `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt    `gorm:"index" json:"-"`
+	UserID           *uint             `gorm:"index:idx_transactions_user_created,priority:1" json:"user_id,omitempty"` // also a user's listing, newest first
+	ActingUserID     *uint             `gorm:"index" json:"acting_user_id,omitempty"`                                   // institution member who made the charge (see Institution)
+	MerchantID       uint              `gorm:"not null;default:1;index" json:"merchant_id"`                             // the Omise account the charge is on
+	Provider         string            `gorm:"size:20;not null;default:omise" json:"provider"`                          // payment provider of the charge (provider.PaymentProvider.Name)
+	ChargeID         string            `gorm:"uniqueIndex:idx_transactions_charge_id,priority:1" json:"charge_id"`
+	AmountSatang     int64             `json:"amount_satang"`
+	Currency         string            `json:"currency"`
+	Channel          string            `gorm:"index:idx_transactions_channel_created,priority:1" json:"channel"`
+	Status           string            `gorm:"index:idx_transactions_status_created,priority:1" json:"status"`
+	Description      *string           `json:"description,omitempty"`
+	FailureCode      *string           `json:"failure_code,omitempty"`
+	FailureMessage   *string           `json:"failure_message,omitempty"`
+	ExpiresAt        *time.Time        `json:"expires_at,omitempty"`                // when the charge can no longer be paid (e.g. a PromptPay QR), if it expires
+	CardBrand        string            `gorm:"size:20" json:"card_brand,omitempty"` // e.g. "Visa", for card charges
+	CardLastDigits   string            `gorm:"size:4" json:"card_last_digits,omitempty"`
+	Bank             string            `gorm:"size:100" json:"bank,omitempty"`                                                                         // the card's issuer, or the bank code of a banking source (e.g. "bbl")
+	OrderID          *uint             `gorm:"index" json:"order_id,omitempty"`                                                                        // the Order the charge pays for (PaymentRequest.OrderID)
+	PaymentLinkID    *uint             `gorm:"index" json:"payment_link_id,omitempty"`                                                                 // the PaymentLink the charge pays
+	CouponID         *uint             `gorm:"index" json:"coupon_id,omitempty"`                                                                       // the Coupon applied to the order total
+	DiscountSatang   int64             `gorm:"not null;default:0" json:"discount_satang,omitempty"`                                                    // taken off the order total by the coupon; AmountSatang is net of it
+	VATRateBps       int64             `gorm:"not null;default:0" json:"vat_rate_bps,omitempty"`                                                       // VAT rate of the tax invoice, in basis points
+	VATSatang        int64             `gorm:"not null;default:0" json:"vat_satang,omitempty"`                                                         // VAT included in AmountSatang
+	TaxInvoiceNumber *string           `gorm:"size:32;uniqueIndex:idx_transactions_tax_invoice_number,priority:1" json:"tax_invoice_number,omitempty"` // issued when the charge succeeds (see TaxInvoiceSequence)
+	FeeSatang        int64             `gorm:"not null;default:0" json:"fee_satang,omitempty"`                                                         // Omise's fee on the charge
+	FeeVATSatang     int64             `gorm:"not null;default:0" json:"fee_vat_satang,omitempty"`                                                     // VAT on FeeSatang
+	NetSatang        int64             `gorm:"not null;default:0" json:"net_satang,omitempty"`                                                         // paid out by Omise: AmountSatang - FeeSatang - FeeVATSatang
+	FeesSyncedAt     *time.Time        `json:"fees_synced_at,omitempty"`                                                                               // when the fees were read from Omise; nil until then (see service.SyncChargeFees)
+	TransferableAt   *time.Time        `json:"transferable_at,omitempty"`                                                                              // when Omise lets NetSatang be transferred; synced with the fees
+	OmiseTransferID  *uint             `gorm:"index" json:"omise_transfer_id,omitempty"`                                                               // the OmiseTransfer that paid NetSatang out, once matched
+	ClientIP         string            `gorm:"size:45" json:"client_ip,omitempty"`                                                                     // the payer's address when the charge was created over HTTP
+	IPCountry        string            `gorm:"size:2" json:"ip_country,omitempty"`                                                                     // ISO 3166-1 alpha-2 country of ClientIP, when known
+	RiskDecision     string            `gorm:"size:10" json:"risk_decision,omitempty"`                                                                 // risk.Allow, Flag or Deny when the charge was assessed (service.Risk)
+	RiskScore        int               `gorm:"not null;default:0" json:"risk_score,omitempty"`                                                         // 0-100
+	RiskReasons      string            `gorm:"size:255" json:"risk_reasons,omitempty"`                                                                 // comma-separated, e.g. "recent_failures,charge_burst"
+	RawPayload       []byte            `json:"-"`
+	RawPayloadObject string            `gorm:"size:255" json:"-"` // object key RawPayload was archived to once it was pruned (service.PruneRawPayloads)
+	Meta             datatypes.JSONMap `gorm:"type:jsonb" json:"meta,omitempty"`
+
+	User        *User          `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"-"`
+	Merchant    *Merchant      `gorm:"foreignKey:MerchantID" json:"-"`
+	Order       *Order         `gorm:"foreignKey:OrderID" json:"-"`
+	PaymentLink *PaymentLink   `gorm:"foreignKey:PaymentLinkID" json:"-"`
+	Transfer    *OmiseTransfer `gorm:"foreignKey:OmiseTransferID" json:"-"`
+}
+
+// PaymentMethod describes how t was paid for payers, e.g. "VISA •••• 4242"; the channel when the card
+// is unknown.
+func (t Transaction) PaymentMethod() string {
+	if t.CardLastDigits == "" {
+		return t.Channel
+	}
+	brand := strings.ToUpper(t.CardBrand)
+	if brand == "" {
+		brand = "CARD"
+	}
+	return brand + " •••• " + t.CardLastDigits
+}
 
-	User *User `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"-"`
+// Amount returns the charge amount as money (minor units + currency).
+func (t Transaction) Amount() money.Money {
+	return money.New(t.AmountSatang, t.Currency)
 }
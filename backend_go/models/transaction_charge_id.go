@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// TransactionChargeID registers a charge id with the created_at of its Transaction. Transactions are
+// partitioned by created_at, so their own unique index only covers a charge id per created_at; this
+// table, which is not partitioned, keeps one created_at per charge id and so one row across every
+// partition (see repository.TransactionRepository.UpsertByChargeID).
+type TransactionChargeID struct {
+	ChargeID  string    `gorm:"primaryKey;uniqueIndex:idx_transaction_charge_ids_charge_created,priority:1" json:"charge_id"`
+	CreatedAt time.Time `gorm:"not null;uniqueIndex:idx_transaction_charge_ids_charge_created,priority:2" json:"created_at"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Sources of a TransactionEvent: what reported the status.
+const (
+	TransactionEventWebhook  = "webhook"  // an Omise webhook
+	TransactionEventSync     = "sync"     // a charge read from Omise in a request (creation, admin actions)
+	TransactionEventJob      = "job"      // a background job (reconciliation, expiry)
+	TransactionEventImport   = "import"   // the legacy ledger import and the Omise charge backfill
+	TransactionEventBackfill = "backfill" // copied from the status-change audit log when events were introduced
+	TransactionEventManual   = "manual"   // an admin's status override; Omise's reports no longer change it
+)
+
+// TransactionEvent is one status a transaction moved into, appended whenever its status changes
+// (OldStatus is empty for the first). Rows are never updated, so they give the exact time a charge
+// flipped, e.g. to failed, for disputes.
+type TransactionEvent struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt     time.Time `gorm:"index:idx_transaction_events_transaction,priority:2" json:"created_at"`
+	TransactionID uint      `gorm:"not null;index:idx_transaction_events_transaction,priority:1" json:"transaction_id"`
+	ChargeID      string    `json:"charge_id"`
+	OldStatus     string    `gorm:"size:20" json:"old_status,omitempty"`
+	NewStatus     string    `gorm:"size:20;not null" json:"new_status"`
+	Source        string    `gorm:"size:10;not null" json:"source"`
+	FailureCode   *string   `json:"failure_code,omitempty"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// TransactionDailyRollup is the count and sum of one Bangkok day's transactions (by created_at) per
+// merchant, channel, status and currency, kept by the transaction_rollup job so stats over historical
+// ranges need not scan the transactions table.
+type TransactionDailyRollup struct {
+	Day          string `gorm:"size:10;primaryKey" json:"day"` // YYYY-MM-DD
+	MerchantID   uint   `gorm:"primaryKey;autoIncrement:false" json:"merchant_id"`
+	Channel      string `gorm:"primaryKey" json:"channel"`
+	Status       string `gorm:"primaryKey" json:"status"`
+	Currency     string `gorm:"primaryKey" json:"currency"` // lower case
+	Count        int64  `gorm:"not null" json:"count"`
+	AmountSatang int64  `gorm:"not null" json:"amount_satang"`
+}
+
+// TransactionRollupDay marks a Bangkok day whose TransactionDailyRollup rows are complete (a day
+// without transactions has a marker but no rows). Days without a marker are aggregated live.
+type TransactionRollupDay struct {
+	Day         string    `gorm:"size:10;primaryKey" json:"day"` // YYYY-MM-DD
+	RefreshedAt time.Time `gorm:"not null" json:"refreshed_at"`
+	Groups      int       `json:"groups"` // TransactionDailyRollup rows
+}
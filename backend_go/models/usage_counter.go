@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Usage metrics counted per API consumer (see handlers/usage.go).
+const (
+	UsageAPICalls           = "api_calls"
+	UsageChargesCreated     = "charges_created"
+	UsageChargeAmountSatang = "charge_amount_satang"
+	UsageExports            = "exports"
+	UsageExportRows         = "export_rows"
+	UsageExportBytes        = "export_bytes"
+)
+
+// UsageConsumerUnattributed is the consumer of requests sent without an API key.
+const UsageConsumerUnattributed = "unattributed"
+
+// UsageCounter is one day's total of a usage metric for an internal API consumer, for cost
+// allocation. Replicas add to the same row (unique day, consumer, metric).
+type UsageCounter struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Day       string    `gorm:"size:10;not null;uniqueIndex:idx_usage_counter" json:"day"` // YYYY-MM-DD, Bangkok time
+	Consumer  string    `gorm:"size:64;not null;uniqueIndex:idx_usage_counter" json:"consumer"`
+	Metric    string    `gorm:"size:40;not null;uniqueIndex:idx_usage_counter" json:"metric"`
+	Count     int64     `gorm:"not null;default:0" json:"count"`
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
@@ -14,6 +16,11 @@ type User struct {
 	Gender         string  `gorm:"size:6"`
 	PhoneNumber    string  `gorm:"size:20"`
 	Balance        float64 `gorm:"type:numeric(12,2);default:0;check:balance >= 0"`
+	FrozenBalance  float64 `gorm:"type:numeric(12,2);default:0;check:frozen_balance >= 0"` // held while a dispute case is open
+	HeldBalance    float64 `gorm:"type:numeric(12,2);default:0;check:held_balance >= 0"`   // reserved by active wallet holds
+	LineUserID     *string `gorm:"size:33;uniqueIndex"`                                    // linked LINE account ("U" + 32 hex), for LINE notifications
+
+	PersonalDataErasedAt *time.Time // set when the profile was anonymized under PDPA; balances and transactions stay
 
 	//TODO : uncomment below
 	//Learner *Learner
@@ -32,4 +39,9 @@ type UserDoc struct {
 	Gender         string  `json:"gender" example:"Female"`
 	PhoneNumber    string  `json:"phone_number" example:"+66912345678"`
 	Balance        float64 `json:"balance" example:"250.75"`
+	FrozenBalance  float64 `json:"frozen_balance" example:"0"`
+	HeldBalance    float64 `json:"held_balance" example:"0"`
+	LineUserID     *string `json:"line_user_id,omitempty" example:"U4af4980629a1b2c3d4e5f60718293a4b"`
+
+	PersonalDataErasedAt *time.Time `json:"personal_data_erased_at,omitempty"`
 }
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Wallet operation kinds.
+const (
+	WalletOpDebit  = "debit"
+	WalletOpCredit = "credit"
+	WalletOpHold   = "hold"
+)
+
+// Wallet hold statuses: an active hold keeps its amount in User.HeldBalance until it is released back
+// to the balance or captured (spent).
+const (
+	WalletHoldActive   = "active"
+	WalletHoldReleased = "released"
+	WalletHoldCaptured = "captured"
+)
+
+// WalletOperation is one applied wallet debit, credit or hold, keyed by the client-supplied
+// OperationID. The unique key makes retries of the same call (e.g. from the booking service over a
+// flaky network) replay the stored result instead of moving money twice. Rejected calls (insufficient
+// balance, unknown user) are not stored, so they can be retried.
+type WalletOperation struct {
+	ID           uint      `gorm:"primaryKey" json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	OperationID  string    `gorm:"size:100;not null;uniqueIndex" json:"operation_id"`
+	UserID       uint      `gorm:"not null;index" json:"user_id"`
+	Kind         string    `gorm:"size:10;not null" json:"kind"`
+	AmountSatang int64     `gorm:"not null" json:"amount"`
+	Description  string    `gorm:"size:255" json:"description,omitempty"`
+	HoldStatus   string    `gorm:"size:10" json:"hold_status,omitempty"` // holds only
+	Balance      float64   `gorm:"type:numeric(12,2)" json:"balance"`    // user balance right after the operation
+	HeldBalance  float64   `gorm:"type:numeric(12,2)" json:"held_balance"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Warehouse export statuses.
+const (
+	WarehouseExportRunning   = "running"
+	WarehouseExportSucceeded = "succeeded"
+	WarehouseExportFailed    = "failed"
+)
+
+// WarehouseExport is one delivery of a Bangkok day's transactions (by created_at) to the warehouse
+// bucket. Day is unique so a day is exported once across replicas; a failed export is claimed again
+// by the next attempt, which counts in Attempts.
+type WarehouseExport struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time  `gorm:"index" json:"created_at"`
+	Day        string     `gorm:"size:10;not null;uniqueIndex" json:"day"` // YYYY-MM-DD
+	Status     string     `gorm:"size:10;not null" json:"status"`
+	Format     string     `gorm:"size:10;not null" json:"format"`
+	Object     string     `json:"object,omitempty"` // where the file was written, e.g. s3://bucket/transactions/...
+	Rows       int        `json:"rows"`
+	Bytes      int64      `json:"bytes"`
+	Attempts   int        `gorm:"not null;default:1" json:"attempts"`
+	StartedAt  time.Time  `gorm:"not null" json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	CreatedBy  string     `gorm:"size:100" json:"created_by,omitempty"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// WebhookDelivery records how long one provider event took to reach us and to be processed, for the
+// webhook latency SLA. One row per event: Omise retries reuse the event id and only the first
+// successful processing is kept, so retry time counts against the delivery lag.
+type WebhookDelivery struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	EventID        string    `gorm:"size:64;not null;uniqueIndex" json:"event_id"`
+	EventKey       string    `gorm:"size:64;not null;index" json:"event_key"` // e.g. "charge.complete"
+	ChargeID       string    `gorm:"size:64;index" json:"charge_id,omitempty"`
+	EventCreatedAt time.Time `json:"event_created_at"`                       // provider-side "created"
+	ReceivedAt     time.Time `json:"received_at"`                            // request arrived at our endpoint
+	ProcessedAt    time.Time `gorm:"index" json:"processed_at"`              // transaction upserted
+	DeliveryMs     int64     `json:"delivery_ms"`                            // ReceivedAt - EventCreatedAt
+	ProcessingMs   int64     `json:"processing_ms"`                          // ProcessedAt - ReceivedAt
+	TotalMs        int64     `json:"total_ms"`                               // ProcessedAt - EventCreatedAt
+	Breached       bool      `gorm:"not null;default:false" json:"breached"` // either leg over its SLA
+}
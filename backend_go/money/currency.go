@@ -0,0 +1,48 @@
+package money
+
+import (
+	"sort"
+	"strings"
+)
+
+// Limits are the smallest and largest charge Omise accepts in a currency, in its minor units.
+type Limits struct {
+	Min int64
+	Max int64
+}
+
+// chargeLimits lists the currencies the service charges in (lowercase ISO 4217) with their limits.
+// JPY has no minor unit, so its limits are in yen.
+var chargeLimits = map[string]Limits{
+	THB:   {Min: 2000, Max: 15000000},
+	"usd": {Min: 100, Max: 400000},
+	"sgd": {Min: 100, Max: 550000},
+	"jpy": {Min: 100, Max: 600000},
+}
+
+// Supported reports whether charges may be made in currency (any case).
+func Supported(currency string) bool {
+	_, ok := chargeLimits[strings.ToLower(currency)]
+	return ok
+}
+
+// SupportedCurrencies returns the supported currencies, lowercase and sorted.
+func SupportedCurrencies() []string {
+	out := make([]string, 0, len(chargeLimits))
+	for c := range chargeLimits {
+		out = append(out, c)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ChargeLimits returns the charge limits of currency; false if it is not supported.
+func ChargeLimits(currency string) (Limits, bool) {
+	l, ok := chargeLimits[strings.ToLower(currency)]
+	return l, ok
+}
+
+// Contains reports whether minor units of the limits' currency are a chargeable amount.
+func (l Limits) Contains(minor int64) bool {
+	return minor >= l.Min && minor <= l.Max
+}
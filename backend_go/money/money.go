@@ -0,0 +1,202 @@
+// Package money represents amounts as int64 minor units (satang for THB) with a currency,
+// and centralizes conversion, percentage and proration rounding rules.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// THB is the default currency of the service (Omise uses lowercase ISO 4217 codes).
+const THB = "thb"
+
+// ErrCurrencyMismatch is returned when combining amounts of different currencies.
+var ErrCurrencyMismatch = errors.New("money: currency mismatch")
+
+// RoundingMode decides what happens to fractional minor units.
+type RoundingMode int
+
+const (
+	// RoundHalfUp rounds .5 away from zero (Thai Revenue Department convention for VAT).
+	RoundHalfUp RoundingMode = iota
+	// RoundHalfEven rounds .5 to the nearest even unit (banker's rounding, unbiased for aggregates).
+	RoundHalfEven
+	// RoundDown truncates toward zero (never over-pays, e.g. commissions).
+	RoundDown
+)
+
+// Money is an amount in minor units of Currency.
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// New returns an amount of minor units (e.g. satang).
+func New(minor int64, currency string) Money {
+	return Money{Amount: minor, Currency: strings.ToLower(currency)}
+}
+
+// FromMajor converts a major-unit amount (e.g. 12.34 THB) to minor units, rounding half away from zero.
+// Use only at boundaries that still store floats (e.g. users.balance).
+func FromMajor(major float64, currency string) Money {
+	scale := math.Pow10(MinorUnits(currency))
+	return New(int64(math.Round(major*scale)), currency)
+}
+
+// Major returns the amount in major units (e.g. THB) for display or float-backed columns.
+func (m Money) Major() float64 {
+	return float64(m.Amount) / math.Pow10(MinorUnits(m.Currency))
+}
+
+func (m Money) IsZero() bool     { return m.Amount == 0 }
+func (m Money) IsNegative() bool { return m.Amount < 0 }
+func (m Money) Neg() Money       { return Money{Amount: -m.Amount, Currency: m.Currency} }
+
+func (m Money) Add(o Money) (Money, error) {
+	if !sameCurrency(m, o) {
+		return Money{}, ErrCurrencyMismatch
+	}
+	return Money{Amount: m.Amount + o.Amount, Currency: m.Currency}, nil
+}
+
+func (m Money) Sub(o Money) (Money, error) {
+	if !sameCurrency(m, o) {
+		return Money{}, ErrCurrencyMismatch
+	}
+	return Money{Amount: m.Amount - o.Amount, Currency: m.Currency}, nil
+}
+
+// Min returns the smaller of two amounts of the same currency.
+func Min(a, b Money) (Money, error) {
+	if !sameCurrency(a, b) {
+		return Money{}, ErrCurrencyMismatch
+	}
+	if a.Amount <= b.Amount {
+		return a, nil
+	}
+	return b, nil
+}
+
+// Percent returns m * bps / 10000 (basis points: 700 = 7%), rounded with mode.
+func (m Money) Percent(bps int64, mode RoundingMode) Money {
+	return m.MulRat(bps, 10000, mode)
+}
+
+// MulRat returns m * num / den rounded with mode. Used for refund proration (refund * part / whole).
+func (m Money) MulRat(num, den int64, mode RoundingMode) Money {
+	if den == 0 {
+		panic("money: division by zero")
+	}
+	n := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(num))
+	return Money{Amount: divRound(n, big.NewInt(den), mode), Currency: m.Currency}
+}
+
+// ExtractInclusive splits a tax-inclusive amount into (net, tax) for a rate in basis points,
+// e.g. 107.00 THB at 700 bps -> 100.00 + 7.00. net + tax always equals m.
+func (m Money) ExtractInclusive(bps int64, mode RoundingMode) (net, tax Money) {
+	tax = m.MulRat(bps, 10000+bps, mode)
+	return Money{Amount: m.Amount - tax.Amount, Currency: m.Currency}, tax
+}
+
+// Allocate splits m across ratios without losing minor units; leftover units go to the
+// earliest shares first. Allocate(100, 1, 1, 1) -> 34, 33, 33.
+func (m Money) Allocate(ratios ...int64) []Money {
+	var total int64
+	for _, r := range ratios {
+		if r < 0 {
+			panic("money: negative ratio")
+		}
+		total += r
+	}
+	out := make([]Money, len(ratios))
+	if total == 0 {
+		for i := range out {
+			out[i] = Money{Currency: m.Currency}
+		}
+		return out
+	}
+
+	remainder := m.Amount
+	for i, r := range ratios {
+		out[i] = m.MulRat(r, total, RoundDown)
+		remainder -= out[i].Amount
+	}
+	step := int64(1)
+	if remainder < 0 {
+		step = -1
+	}
+	for i := 0; remainder != 0; i = (i + 1) % len(out) {
+		if ratios[i] == 0 {
+			continue
+		}
+		out[i].Amount += step
+		remainder -= step
+	}
+	return out
+}
+
+// String formats as "1,234.50 THB".
+func (m Money) String() string {
+	digits := MinorUnits(m.Currency)
+	sign := ""
+	amt := m.Amount
+	if amt < 0 {
+		sign, amt = "-", -amt
+	}
+	scale := int64(math.Pow10(digits))
+	whole := groupThousands(amt / scale)
+	if digits == 0 {
+		return fmt.Sprintf("%s%s %s", sign, whole, strings.ToUpper(m.Currency))
+	}
+	return fmt.Sprintf("%s%s.%0*d %s", sign, whole, digits, amt%scale, strings.ToUpper(m.Currency))
+}
+
+// MinorUnits returns the ISO 4217 exponent of a currency (2 unless listed).
+func MinorUnits(currency string) int {
+	switch strings.ToLower(currency) {
+	case "jpy", "krw", "vnd", "clp", "isk", "ugx":
+		return 0
+	case "bhd", "jod", "kwd", "omr", "tnd":
+		return 3
+	}
+	return 2
+}
+
+func sameCurrency(a, b Money) bool {
+	return strings.EqualFold(a.Currency, b.Currency)
+}
+
+// (helper for MulRat) integer division of n/d with the given rounding mode.
+func divRound(n, d *big.Int, mode RoundingMode) int64 {
+	if d.Sign() < 0 {
+		n, d = new(big.Int).Neg(n), new(big.Int).Neg(d)
+	}
+	q, r := new(big.Int).QuoRem(n, d, new(big.Int)) // truncates toward zero
+	if r.Sign() == 0 || mode == RoundDown {
+		return q.Int64()
+	}
+
+	twice := new(big.Int).Abs(r)
+	twice.Lsh(twice, 1)
+	cmp := twice.Cmp(d)
+	away := cmp > 0 || (cmp == 0 && (mode == RoundHalfUp || q.Bit(0) == 1))
+	if away {
+		if n.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q.Int64()
+}
+
+func groupThousands(n int64) string {
+	s := fmt.Sprintf("%d", n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
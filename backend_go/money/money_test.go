@@ -0,0 +1,129 @@
+package money
+
+import "testing"
+
+func TestPercentRounding(t *testing.T) {
+	cases := []struct {
+		amount, bps int64
+		mode        RoundingMode
+		want        int64
+	}{
+		{150, 1000, RoundHalfUp, 15},   // exact
+		{5, 1000, RoundHalfUp, 1},      // 0.5 -> 1
+		{5, 1000, RoundHalfEven, 0},    // 0.5 -> 0 (even)
+		{15, 1000, RoundHalfEven, 2},   // 1.5 -> 2 (even)
+		{19, 1000, RoundDown, 1},       // 1.9 -> 1
+		{-5, 1000, RoundHalfUp, -1},    // -0.5 -> -1 (away from zero)
+		{-19, 1000, RoundDown, -1},     // -1.9 -> -1 (toward zero)
+		{10000, 700, RoundHalfUp, 700}, // 7% VAT
+	}
+	for _, tc := range cases {
+		got := New(tc.amount, THB).Percent(tc.bps, tc.mode).Amount
+		if got != tc.want {
+			t.Errorf("Percent(%d, %d bps, mode %d) = %d, want %d", tc.amount, tc.bps, tc.mode, got, tc.want)
+		}
+	}
+}
+
+func TestExtractInclusive(t *testing.T) {
+	for _, gross := range []int64{10700, 9999, 1, 0, 123457} {
+		net, tax := New(gross, THB).ExtractInclusive(700, RoundHalfUp)
+		if net.Amount+tax.Amount != gross {
+			t.Errorf("gross %d: net %d + tax %d != gross", gross, net.Amount, tax.Amount)
+		}
+	}
+	net, tax := New(10700, THB).ExtractInclusive(700, RoundHalfUp)
+	if net.Amount != 10000 || tax.Amount != 700 {
+		t.Errorf("ExtractInclusive(107.00) = %d, %d; want 10000, 700", net.Amount, tax.Amount)
+	}
+}
+
+func TestAllocateKeepsEveryUnit(t *testing.T) {
+	cases := []struct {
+		amount int64
+		ratios []int64
+		want   []int64
+	}{
+		{100, []int64{1, 1, 1}, []int64{34, 33, 33}},
+		{-100, []int64{1, 1, 1}, []int64{-34, -33, -33}},
+		{5, []int64{0, 1, 1}, []int64{0, 3, 2}},
+		{1000, []int64{70, 30}, []int64{700, 300}},
+	}
+	for _, tc := range cases {
+		got := New(tc.amount, THB).Allocate(tc.ratios...)
+		var sum int64
+		for i, m := range got {
+			sum += m.Amount
+			if m.Amount != tc.want[i] {
+				t.Errorf("Allocate(%d, %v)[%d] = %d, want %d", tc.amount, tc.ratios, i, m.Amount, tc.want[i])
+			}
+		}
+		if sum != tc.amount {
+			t.Errorf("Allocate(%d, %v) sums to %d", tc.amount, tc.ratios, sum)
+		}
+	}
+}
+
+func TestMajorConversion(t *testing.T) {
+	if got := FromMajor(19.99, THB).Amount; got != 1999 {
+		t.Errorf("FromMajor(19.99) = %d, want 1999", got)
+	}
+	if got := FromMajor(0.105, THB).Amount; got != 11 {
+		t.Errorf("FromMajor(0.105) = %d, want 11", got)
+	}
+	if got := FromMajor(500, "jpy").Amount; got != 500 {
+		t.Errorf("FromMajor(500 JPY) = %d, want 500", got)
+	}
+	if got := New(1999, THB).Major(); got != 19.99 {
+		t.Errorf("Major() = %v, want 19.99", got)
+	}
+}
+
+func TestArithmeticCurrencyMismatch(t *testing.T) {
+	if _, err := New(1, THB).Add(New(1, "usd")); err != ErrCurrencyMismatch {
+		t.Errorf("Add across currencies err = %v, want ErrCurrencyMismatch", err)
+	}
+	sum, err := New(150, "THB").Add(New(50, THB))
+	if err != nil || sum.Amount != 200 {
+		t.Errorf("Add = %v, %v; want 200", sum, err)
+	}
+}
+
+func TestString(t *testing.T) {
+	cases := map[Money]string{
+		New(123450, THB):    "1,234.50 THB",
+		New(-5, THB):        "-0.05 THB",
+		New(1500, "jpy"):    "1,500 JPY",
+		New(1234, "kwd"):    "1.234 KWD",
+		New(100000000, THB): "1,000,000.00 THB",
+	}
+	for m, want := range cases {
+		if got := m.String(); got != want {
+			t.Errorf("String(%d %s) = %q, want %q", m.Amount, m.Currency, got, want)
+		}
+	}
+}
+
+func TestChargeLimits(t *testing.T) {
+	cases := []struct {
+		currency string
+		amount   int64
+		want     bool
+	}{
+		{"THB", 2000, true},    // 20.00 THB
+		{"thb", 1999, false},   // below the minimum
+		{"jpy", 100, true},     // 100 JPY: no minor unit
+		{"jpy", 600001, false}, // above the maximum
+		{"usd", 100, true},     // 1.00 USD
+		{"eur", 100000, false}, // not supported
+	}
+	for _, tc := range cases {
+		l, ok := ChargeLimits(tc.currency)
+		if got := ok && l.Contains(tc.amount); got != tc.want {
+			t.Errorf("%d %s chargeable = %v, want %v", tc.amount, tc.currency, got, tc.want)
+		}
+	}
+	if !Supported("SGD") || Supported("eur") {
+		t.Errorf("Supported(SGD) = %v, Supported(eur) = %v; want true, false", Supported("SGD"), Supported("eur"))
+	}
+}
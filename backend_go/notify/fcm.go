@@ -0,0 +1,199 @@
+package notify
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fcmAPI is Firebase Cloud Messaging; a variable so tests can point it at a fake.
+var fcmAPI = "https://fcm.googleapis.com"
+
+// fcmScope is the OAuth scope of the FCM HTTP v1 API.
+const fcmScope = "https://www.googleapis.com/auth/firebase.messaging"
+
+// ErrUnregistered is returned by FCM.Push for a device token FCM no longer knows (the app was
+// uninstalled or the token rotated); the token should be forgotten.
+var ErrUnregistered = errors.New("fcm: device token is not registered")
+
+// Push is a push notification: Title and Body are shown, Data reaches the app (e.g. a deep link).
+type Push struct {
+	Title string
+	Body  string
+	Data  map[string]string
+}
+
+// FCM pushes notifications to the mobile app's devices through Firebase Cloud Messaging (HTTP v1),
+// authenticated as a Google service account.
+type FCM struct {
+	projectID   string
+	clientEmail string
+	tokenURI    string
+	key         *rsa.PrivateKey
+
+	mu          sync.Mutex
+	accessToken string
+	expires     time.Time
+}
+
+// NewFCM returns a client for the Firebase project of serviceAccountJSON, the key file of a service
+// account allowed to send messages; empty disables it.
+func NewFCM(serviceAccountJSON []byte) (*FCM, error) {
+	if len(bytes.TrimSpace(serviceAccountJSON)) == 0 {
+		return &FCM{}, nil
+	}
+	var sa struct {
+		ProjectID   string `json:"project_id"`
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(serviceAccountJSON, &sa); err != nil {
+		return nil, fmt.Errorf("fcm: service account: %w", err)
+	}
+	if sa.ProjectID == "" || sa.ClientEmail == "" {
+		return nil, fmt.Errorf("fcm: service account has no project_id or client_email")
+	}
+	block, _ := pem.Decode([]byte(sa.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("fcm: service account private_key is not PEM")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("fcm: service account private_key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("fcm: service account private_key is not an RSA key")
+	}
+	if sa.TokenURI == "" {
+		sa.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	return &FCM{projectID: sa.ProjectID, clientEmail: sa.ClientEmail, tokenURI: sa.TokenURI, key: key}, nil
+}
+
+// Enabled reports whether a service account is configured.
+func (f *FCM) Enabled() bool {
+	return f != nil && f.key != nil
+}
+
+// Push sends p to the device with registration token to; ErrUnregistered if FCM no longer knows it.
+func (f *FCM) Push(to string, p Push) error {
+	if !f.Enabled() {
+		return fmt.Errorf("FCM is not configured (FCM_CREDENTIALS_FILE is empty)")
+	}
+	token, err := f.token()
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(map[string]interface{}{"message": map[string]interface{}{
+		"token":        to,
+		"notification": map[string]string{"title": p.Title, "body": p.Body},
+		"data":         p.Data,
+		"android":      map[string]string{"priority": "high"},
+		"apns":         map[string]interface{}{"headers": map[string]string{"apns-priority": "10"}},
+	}})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, fcmAPI+"/v1/projects/"+url.PathEscape(f.projectID)+"/messages:send", bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	var body struct {
+		Error struct {
+			Status  string `json:"status"`
+			Details []struct {
+				ErrorCode string `json:"errorCode"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	for _, d := range body.Error.Details {
+		if d.ErrorCode == "UNREGISTERED" {
+			return ErrUnregistered
+		}
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrUnregistered
+	}
+	return fmt.Errorf("fcm returned %s %s", resp.Status, body.Error.Status)
+}
+
+// (helper for Push) an OAuth access token for the service account, reused until a minute before it
+// expires (JWT bearer grant, RFC 7523).
+func (f *FCM) token() (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	if f.accessToken != "" && now.Before(f.expires.Add(-time.Minute)) {
+		return f.accessToken, nil
+	}
+	assertion, err := f.assertion(now)
+	if err != nil {
+		return "", err
+	}
+	resp, err := httpClient.PostForm(f.tokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("fcm: token endpoint returned %s", resp.Status)
+	}
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil || tok.AccessToken == "" {
+		return "", fmt.Errorf("fcm: token endpoint returned no access token (%v)", err)
+	}
+	f.accessToken, f.expires = tok.AccessToken, now.Add(time.Duration(tok.ExpiresIn)*time.Second)
+	return f.accessToken, nil
+}
+
+// (helper for token) the signed JWT the service account exchanges for an access token.
+func (f *FCM) assertion(now time.Time) (string, error) {
+	enc := base64.RawURLEncoding
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   f.clientEmail,
+		"scope": fcmScope,
+		"aud":   f.tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	signed := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, f.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return strings.Join([]string{signed, enc.EncodeToString(sig)}, "."), nil
+}
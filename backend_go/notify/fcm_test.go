@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFCMPush(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tokenRequests int
+	var got struct {
+		auth string
+		body map[string]map[string]interface{}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			tokenRequests++
+			if r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || strings.Count(r.FormValue("assertion"), ".") != 2 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(`{"access_token": "ya29.test", "expires_in": 3600}`))
+		case "/v1/projects/tutorium-app/messages:send":
+			got.auth = r.Header.Get("Authorization")
+			got.body = nil
+			_ = json.NewDecoder(r.Body).Decode(&got.body)
+			if got.body["message"]["token"] == "gone" {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"error": {"status": "NOT_FOUND", "details": [{"errorCode": "UNREGISTERED"}]}}`))
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	defer func(api string) { fcmAPI = api }(fcmAPI)
+	fcmAPI = srv.URL
+
+	creds, _ := json.Marshal(map[string]string{
+		"project_id":   "tutorium-app",
+		"client_email": "push@tutorium-app.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":    srv.URL + "/token",
+	})
+	fcm, err := NewFCM(creds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	push := Push{Title: "Payment received", Body: "500.00 THB", Data: map[string]string{"link": "tutorium://payments/chrg_test_1"}}
+	if err := fcm.Push("device-1", push); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := got.body["message"]["data"].(map[string]interface{})
+	if got.auth != "Bearer ya29.test" || got.body["message"]["token"] != "device-1" || data["link"] != "tutorium://payments/chrg_test_1" {
+		t.Errorf("auth %q, body %v", got.auth, got.body)
+	}
+	if err := fcm.Push("gone", push); !errors.Is(err, ErrUnregistered) {
+		t.Errorf("unregistered token: err = %v, want ErrUnregistered", err)
+	}
+	if tokenRequests != 1 {
+		t.Errorf("%d token requests, want the access token reused", tokenRequests)
+	}
+
+	if disabled, err := NewFCM(nil); err != nil || disabled.Enabled() || disabled.Push("device-1", push) == nil {
+		t.Errorf("no credentials: err %v, enabled %v; want disabled", err, disabled.Enabled())
+	}
+	if _, err := NewFCM([]byte(`{"project_id": "tutorium-app"}`)); err == nil {
+		t.Error("credentials without a key accepted")
+	}
+}
@@ -0,0 +1,148 @@
+// Package notify delivers operational messages (reports, alerts) over email and chat webhooks, pushes
+// LINE messages and mobile notifications (FCM) to users, and renders the messages sent to payers from
+// templates/ (see Render).
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Message is a channel-agnostic notification.
+type Message struct {
+	Subject string
+	Body    string
+}
+
+// SMTPConfig holds outgoing mail settings (SMTP_HOST, SMTP_PORT, SMTP_USER, SMTP_PASSWORD, SMTP_FROM).
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	From     string
+}
+
+// Mailer sends plain-text email through an SMTP relay.
+type Mailer struct {
+	cfg SMTPConfig
+}
+
+func NewMailer(cfg SMTPConfig) *Mailer {
+	if cfg.Port == "" {
+		cfg.Port = "587"
+	}
+	return &Mailer{cfg: cfg}
+}
+
+// Enabled reports whether an SMTP host is configured.
+func (m *Mailer) Enabled() bool {
+	return m != nil && m.cfg.Host != ""
+}
+
+func (m *Mailer) Send(to string, msg Message) error {
+	if !m.Enabled() {
+		return fmt.Errorf("email is not configured (SMTP_HOST is empty)")
+	}
+	var auth smtp.Auth
+	if m.cfg.User != "" {
+		auth = smtp.PlainAuth("", m.cfg.User, m.cfg.Password, m.cfg.Host)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.cfg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(msg.Body)
+
+	return smtp.SendMail(m.cfg.Host+":"+m.cfg.Port, auth, m.cfg.From, []string{to}, []byte(b.String()))
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// PostSlack posts a message to a Slack incoming-webhook URL.
+func PostSlack(webhookURL string, msg Message) error {
+	text := msg.Body
+	if msg.Subject != "" {
+		text = "*" + msg.Subject + "*\n" + msg.Body
+	}
+	return postJSON(webhookURL, map[string]string{"text": text})
+}
+
+// PostDiscord posts a message to a Discord channel webhook URL.
+func PostDiscord(webhookURL string, msg Message) error {
+	text := msg.Body
+	if msg.Subject != "" {
+		text = "**" + msg.Subject + "**\n" + msg.Body
+	}
+	return postJSON(webhookURL, map[string]string{"content": text})
+}
+
+// lineAPI is the LINE Messaging API; a variable so tests can point it at a fake.
+var lineAPI = "https://api.line.me"
+
+// LINE pushes messages to users through a LINE Official Account (the Messaging API).
+type LINE struct {
+	token string // channel access token
+}
+
+// NewLINE returns a client for the channel with access token token; empty disables it.
+func NewLINE(token string) *LINE {
+	return &LINE{token: token}
+}
+
+// Enabled reports whether a channel access token is configured.
+func (l *LINE) Enabled() bool {
+	return l != nil && l.token != ""
+}
+
+// Push sends msg as one text message to the LINE user id to (who must have added the account as a friend).
+func (l *LINE) Push(to string, msg Message) error {
+	if !l.Enabled() {
+		return fmt.Errorf("LINE is not configured (LINE_CHANNEL_ACCESS_TOKEN is empty)")
+	}
+	text := msg.Body
+	if msg.Subject != "" {
+		text = msg.Subject + "\n\n" + msg.Body
+	}
+	return postJSONAuth(lineAPI+"/v2/bot/message/push", "Bearer "+l.token, map[string]interface{}{
+		"to":       to,
+		"messages": []map[string]string{{"type": "text", "text": text}},
+	})
+}
+
+// (helper for chat webhooks) POST a JSON body and treat any non-2xx as an error.
+func postJSON(url string, body interface{}) error {
+	return postJSONAuth(url, "", body)
+}
+
+// (helper for postJSON and LINE.Push) postJSON with an Authorization header, when auth is set.
+func postJSONAuth(url, auth string, body interface{}) error {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
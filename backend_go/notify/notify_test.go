@@ -0,0 +1,62 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRenderPaymentTemplates(t *testing.T) {
+	data := map[string]string{"Name": "Alice", "Amount": "500.00 THB", "Channel": "PromptPay", "Time": "1 Oct 2026 10:00",
+		"ChargeID": "chrg_test_1", "FailureMessage": "insufficient funds", "Advice": "Try another card."}
+	for _, name := range []string{TemplatePaymentSucceeded, TemplatePaymentFailed, TemplateLinePaymentSucceeded, TemplateLineQRExpiring} {
+		msg, err := Render(name, data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if msg.Subject == "" || strings.Contains(msg.Subject, "\n") {
+			t.Errorf("%s: subject %q", name, msg.Subject)
+		}
+		if !strings.Contains(msg.Body, "500.00 THB") || !strings.Contains(msg.Body, "chrg_test_1") {
+			t.Errorf("%s: body %q", name, msg.Body)
+		}
+	}
+	data["TaxInvoiceNumber"], data["Net"], data["VAT"], data["VATRate"] = "TIV-202610-000001", "467.29 THB", "32.71 THB", "7%"
+	msg, err := Render(TemplatePaymentSucceeded, data)
+	if err != nil || !strings.Contains(msg.Body, "TIV-202610-000001") || !strings.Contains(msg.Body, "VAT 7%: 32.71 THB") {
+		t.Errorf("receipt with a tax invoice = %q, %v", msg.Body, err)
+	}
+	if _, err := Render("nope", data); err == nil {
+		t.Error("unknown template rendered")
+	}
+}
+
+func TestLINEPush(t *testing.T) {
+	var got struct {
+		auth string
+		body map[string]interface{}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.auth = r.Header.Get("Authorization")
+		_ = json.NewDecoder(r.Body).Decode(&got.body)
+		if r.URL.Path != "/v2/bot/message/push" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	defer func(api string) { lineAPI = api }(lineAPI)
+	lineAPI = srv.URL
+
+	if err := NewLINE("tok").Push("U123", Message{Subject: "Paid", Body: "500.00 THB"}); err != nil {
+		t.Fatal(err)
+	}
+	msgs, _ := got.body["messages"].([]interface{})
+	if got.auth != "Bearer tok" || got.body["to"] != "U123" || len(msgs) != 1 {
+		t.Errorf("auth %q, body %v", got.auth, got.body)
+	}
+	if err := NewLINE("").Push("U123", Message{}); err == nil {
+		t.Error("pushed without a token")
+	}
+}
@@ -0,0 +1,35 @@
+package notify
+
+import (
+	"embed"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/*.tmpl
+var templateFiles embed.FS
+
+// templates are the message templates; each file defines "<name>.subject" and "<name>.body".
+var templates = template.Must(template.New("").ParseFS(templateFiles, "templates/*.tmpl"))
+
+// Templates usable with Render.
+const (
+	TemplatePaymentSucceeded     = "payment_succeeded"
+	TemplatePaymentFailed        = "payment_failed"
+	TemplateLinePaymentSucceeded = "line_payment_succeeded"
+	TemplateLineQRExpiring       = "line_qr_expiring"
+	TemplatePushPaymentSucceeded = "push_payment_succeeded"
+	TemplatePushPaymentFailed    = "push_payment_failed"
+)
+
+// Render builds the Message of template name (see templates/) for data.
+func Render(name string, data interface{}) (Message, error) {
+	var subject, body strings.Builder
+	if err := templates.ExecuteTemplate(&subject, name+".subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := templates.ExecuteTemplate(&body, name+".body", data); err != nil {
+		return Message{}, err
+	}
+	return Message{Subject: strings.TrimSpace(subject.String()), Body: strings.TrimLeft(body.String(), "\n")}, nil
+}
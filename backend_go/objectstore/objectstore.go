@@ -0,0 +1,133 @@
+// Package objectstore uploads files to an object-storage bucket: Amazon S3, or Google Cloud Storage
+// through its S3-compatible XML API (HMAC keys, endpoint storage.googleapis.com). Memory is an
+// in-process Store for tests and local runs.
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// ErrNotFound is returned by Store.Read for a key without an object.
+var ErrNotFound = errors.New("objectstore: no such object")
+
+// Store is one bucket.
+type Store interface {
+	// Put writes body to key, replacing any object already there.
+	Put(ctx context.Context, key string, body []byte, contentType string) error
+	// Read returns the object at key; ErrNotFound when there is none.
+	Read(ctx context.Context, key string) ([]byte, error)
+	// URL names the object at key, e.g. s3://bucket/key, for logs and run reports.
+	URL(key string) string
+}
+
+// S3Config is a bucket reached over the S3 API.
+type S3Config struct {
+	Endpoint  string // host[:port]: s3.amazonaws.com, storage.googleapis.com, or a MinIO server
+	Region    string // empty lets the client discover it
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	Insecure  bool // plain HTTP, for a local MinIO
+}
+
+// S3 is a Store over the S3 API.
+type S3 struct {
+	client *minio.Client
+	bucket string
+	scheme string
+}
+
+var _ Store = (*S3)(nil)
+
+// NewS3 returns the bucket of cfg; it does not contact the endpoint.
+func NewS3(cfg S3Config) (*S3, error) {
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: !cfg.Insecure,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("objectstore: %w", err)
+	}
+	scheme := "s3"
+	if cfg.Endpoint == "storage.googleapis.com" {
+		scheme = "gs"
+	}
+	return &S3{client: client, bucket: cfg.Bucket, scheme: scheme}, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	_, err := s.client.PutObject(ctx, s.bucket, key, bytes.NewReader(body), int64(len(body)),
+		minio.PutObjectOptions{ContentType: contentType})
+	if err != nil {
+		return fmt.Errorf("objectstore: put %s: %w", s.URL(key), err)
+	}
+	return nil
+}
+
+func (s *S3) Read(ctx context.Context, key string) ([]byte, error) {
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("objectstore: get %s: %w", s.URL(key), err)
+	}
+	defer obj.Close()
+	body, err := io.ReadAll(obj) // GetObject is lazy: a missing key fails here
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == minio.NoSuchKey {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, s.URL(key))
+		}
+		return nil, fmt.Errorf("objectstore: get %s: %w", s.URL(key), err)
+	}
+	return body, nil
+}
+
+func (s *S3) URL(key string) string {
+	return s.scheme + "://" + s.bucket + "/" + key
+}
+
+// Memory is a Store that keeps objects in memory.
+type Memory struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+var _ Store = (*Memory)(nil)
+
+func NewMemory() *Memory {
+	return &Memory{objects: map[string][]byte{}}
+}
+
+func (m *Memory) Put(_ context.Context, key string, body []byte, _ string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = bytes.Clone(body)
+	return nil
+}
+
+func (m *Memory) Read(_ context.Context, key string) ([]byte, error) {
+	b, ok := m.Get(key)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, m.URL(key))
+	}
+	return bytes.Clone(b), nil
+}
+
+func (m *Memory) URL(key string) string {
+	return "mem://" + key
+}
+
+// Get returns the object at key, and whether there is one.
+func (m *Memory) Get(key string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.objects[key]
+	return b, ok
+}
@@ -0,0 +1,139 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/a2n2k3p4/tutorium-backend/gateway"
+	omise "github.com/omise/omise-go"
+	"github.com/omise/omise-go/operations"
+)
+
+// NameOmise is the Name of the Omise provider.
+const NameOmise = "omise"
+
+// Omise is the PaymentProvider for Omise, over an OmiseGateway (a gateway.Merchants routes each call
+// to the account of the merchant on its ctx). Errors are the gateway's, so *omise.Error still reaches
+// callers that inspect it.
+type Omise struct {
+	Gateway gateway.OmiseGateway
+}
+
+var _ PaymentProvider = (*Omise)(nil)
+
+func NewOmise(gw gateway.OmiseGateway) *Omise {
+	return &Omise{Gateway: gw}
+}
+
+func (p *Omise) Name() string { return NameOmise }
+
+// CreateCharge charges a card token directly; PromptPay and internet banking first create the source
+// the charge is made from.
+func (p *Omise) CreateCharge(ctx context.Context, req ChargeRequest) (*Charge, error) {
+	op := &operations.CreateCharge{
+		Amount:      req.AmountSatang,
+		Currency:    req.Currency,
+		ReturnURI:   req.ReturnURI,
+		Description: req.Description,
+		Metadata:    req.Metadata,
+		Ip:          req.IP,
+	}
+	switch req.Method {
+	case MethodCard:
+		op.Card = req.CardToken
+		if req.Require3DS {
+			ctx = gateway.WithAuthentication(ctx, gateway.Authentication3DS)
+		}
+	case MethodPromptPay, MethodInternetBanking:
+		sourceType := req.Method
+		if req.Method == MethodInternetBanking {
+			sourceType += "_" + req.Bank
+		}
+		src, err := p.Gateway.CreateSource(ctx, &operations.CreateSource{
+			Type:     sourceType,
+			Amount:   req.AmountSatang,
+			Currency: req.Currency,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s source: %w", sourceType, err)
+		}
+		op.Source = src.ID
+	default:
+		return nil, fmt.Errorf("omise: unsupported payment method %q", req.Method)
+	}
+	ch, err := p.Gateway.CreateCharge(ctx, op)
+	if err != nil {
+		return nil, err
+	}
+	return omiseCharge(ch), nil
+}
+
+func (p *Omise) RetrieveCharge(ctx context.Context, chargeID string) (*Charge, error) {
+	ch, err := p.Gateway.RetrieveCharge(ctx, chargeID)
+	if err != nil {
+		return nil, err
+	}
+	return omiseCharge(ch), nil
+}
+
+func (p *Omise) Refund(ctx context.Context, req RefundRequest) (*Refund, error) {
+	r, err := p.Gateway.CreateRefund(ctx, &operations.CreateRefund{
+		ChargeID: req.ChargeID,
+		Amount:   req.AmountSatang,
+		Metadata: req.Metadata,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Refund{ID: r.ID, ChargeID: r.Charge, AmountSatang: r.Amount, Native: r}, nil
+}
+
+// ParseWebhook accepts an event payload (object "event"), verified by retrieving the event from
+// Omise, or a bare charge payload (object "charge", sent by some dashboard and testing tools). Other
+// objects are returned without a ChargeID.
+func (p *Omise) ParseWebhook(ctx context.Context, body []byte) (*WebhookEvent, error) {
+	var envelope struct {
+		Object string `json:"object"`
+		ID     string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil || envelope.ID == "" {
+		return nil, ErrInvalidPayload
+	}
+	switch envelope.Object {
+	case "event":
+		ev, err := p.Gateway.RetrieveEvent(ctx, envelope.ID)
+		if err != nil {
+			return nil, fmt.Errorf("verify event: %w", err)
+		}
+		out := &WebhookEvent{ID: ev.ID, Key: ev.Key, Native: ev}
+		if ch, ok := ev.Data.(*omise.Charge); ok {
+			out.ChargeID = ch.ID
+		}
+		return out, nil
+	case "charge":
+		return &WebhookEvent{ID: envelope.ID, ChargeID: envelope.ID}, nil
+	default:
+		return &WebhookEvent{ID: envelope.ID}, nil
+	}
+}
+
+// OmiseCharge is the *omise.Charge behind c, or an error when c comes from another provider.
+func OmiseCharge(c *Charge) (*omise.Charge, error) {
+	if ch, ok := c.Native.(*omise.Charge); ok {
+		return ch, nil
+	}
+	return nil, fmt.Errorf("provider: charge %s from %s is not an Omise charge", c.ID, c.Provider)
+}
+
+// (helper for Omise) the provider-neutral view of ch.
+func omiseCharge(ch *omise.Charge) *Charge {
+	return &Charge{
+		Provider:     NameOmise,
+		ID:           ch.ID,
+		Status:       string(ch.Status),
+		AmountSatang: ch.Amount,
+		Currency:     ch.Currency,
+		Native:       ch,
+	}
+}
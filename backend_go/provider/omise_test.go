@@ -0,0 +1,91 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/a2n2k3p4/tutorium-backend/gateway/gatewaytest"
+	omise "github.com/omise/omise-go"
+)
+
+func TestOmiseChargeAndRefund(t *testing.T) {
+	fake := gatewaytest.NewFake()
+	p := NewOmise(fake)
+	ctx := context.Background()
+
+	qr, err := p.CreateCharge(ctx, ChargeRequest{Method: MethodPromptPay, AmountSatang: 10000, Currency: "thb"})
+	if err != nil {
+		t.Fatalf("CreateCharge promptpay: %v", err)
+	}
+	if qr.Provider != NameOmise || qr.Status != "pending" || qr.AmountSatang != 10000 {
+		t.Errorf("promptpay charge = %+v, want a pending 10000 omise charge", qr)
+	}
+	if ch, err := OmiseCharge(qr); err != nil || ch.Source == nil || ch.Source.Type != "promptpay" {
+		t.Errorf("promptpay charge is not made from a promptpay source: %v", err)
+	}
+	if _, err := p.CreateCharge(ctx, ChargeRequest{Method: MethodInternetBanking, Bank: "scb", AmountSatang: 10000, Currency: "thb"}); err != nil {
+		t.Fatalf("CreateCharge internet banking: %v", err)
+	}
+	if _, err := p.CreateCharge(ctx, ChargeRequest{Method: "cash"}); err == nil {
+		t.Error("CreateCharge accepted an unsupported method")
+	}
+
+	card, err := p.CreateCharge(ctx, ChargeRequest{Method: MethodCard, CardToken: "tokn_test_1", AmountSatang: 5000, Currency: "thb"})
+	if err != nil {
+		t.Fatalf("CreateCharge card: %v", err)
+	}
+	refund, err := p.Refund(ctx, RefundRequest{ChargeID: card.ID, AmountSatang: 2000})
+	if err != nil {
+		t.Fatalf("Refund: %v", err)
+	}
+	if refund.ChargeID != card.ID || refund.AmountSatang != 2000 || refund.ID == "" {
+		t.Errorf("refund = %+v, want 2000 of %s", refund, card.ID)
+	}
+
+	want := []string{"CreateSource", "CreateCharge", "CreateSource", "CreateCharge", "CreateCharge", "CreateRefund"}
+	if got := fake.Calls(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("calls = %v, want %v", got, want)
+	}
+}
+
+func TestOmiseParseWebhook(t *testing.T) {
+	fake := gatewaytest.NewFake()
+	p := NewOmise(fake)
+	ctx := context.Background()
+	ch, err := p.CreateCharge(ctx, ChargeRequest{Method: MethodPromptPay, AmountSatang: 10000, Currency: "thb"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventID, err := fake.Complete(ch.ID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ev, err := p.ParseWebhook(ctx, []byte(`{"object":"event","id":"`+eventID+`"}`))
+	if err != nil {
+		t.Fatalf("ParseWebhook event: %v", err)
+	}
+	if ev.Key != "charge.complete" || ev.ChargeID != ch.ID {
+		t.Errorf("event = %+v, want charge.complete for %s", ev, ch.ID)
+	}
+	if _, ok := ev.Native.(*omise.Event); !ok {
+		t.Errorf("event Native = %T, want *omise.Event", ev.Native)
+	}
+
+	if ev, err := p.ParseWebhook(ctx, []byte(`{"object":"charge","id":"`+ch.ID+`"}`)); err != nil || ev.ChargeID != ch.ID || ev.Key != "" {
+		t.Errorf("ParseWebhook charge = %+v, %v; want ChargeID %s without a key", ev, err, ch.ID)
+	}
+	if ev, err := p.ParseWebhook(ctx, []byte(`{"object":"customer","id":"cust_test_1"}`)); err != nil || ev.ChargeID != "" {
+		t.Errorf("ParseWebhook customer = %+v, %v; want no charge", ev, err)
+	}
+	for _, body := range []string{`{}`, `not json`} {
+		if _, err := p.ParseWebhook(ctx, []byte(body)); !errors.Is(err, ErrInvalidPayload) {
+			t.Errorf("ParseWebhook(%s): err %v, want ErrInvalidPayload", body, err)
+		}
+	}
+	if _, err := p.ParseWebhook(ctx, []byte(`{"object":"event","id":"evnt_test_unknown"}`)); err == nil || errors.Is(err, ErrInvalidPayload) {
+		t.Errorf("ParseWebhook unknown event: err %v, want a verification error", err)
+	}
+}
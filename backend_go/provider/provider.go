@@ -0,0 +1,84 @@
+// Package provider puts the payment provider behind PaymentProvider, so the service creates charges,
+// refunds them and reads webhooks without naming the provider. Omise is the only implementation; a
+// Stripe or 2C2P adapter implements the same interface and registers its Name. Types are the
+// provider-neutral subset the service needs, and each carries the provider's own object as Native
+// for the code paths (recording, reconciliation) that are still Omise-specific.
+package provider
+
+import (
+	"context"
+	"errors"
+)
+
+// Payment methods of a ChargeRequest.
+const (
+	MethodCard            = "card"
+	MethodPromptPay       = "promptpay"
+	MethodInternetBanking = "internet_banking"
+)
+
+// ErrInvalidPayload is returned by ParseWebhook for a body that is not a webhook of the provider;
+// surfaces answer it with 400, other ParseWebhook errors with 5xx so the provider retries.
+var ErrInvalidPayload = errors.New("provider: invalid webhook payload")
+
+// PaymentProvider is one payment provider account.
+type PaymentProvider interface {
+	// Name identifies the provider; it is stored on every transaction ("omise").
+	Name() string
+	// CreateCharge charges req.AmountSatang with req.Method.
+	CreateCharge(ctx context.Context, req ChargeRequest) (*Charge, error)
+	// RetrieveCharge reads the provider's current copy of a charge.
+	RetrieveCharge(ctx context.Context, chargeID string) (*Charge, error)
+	// Refund refunds (part of) a charge.
+	Refund(ctx context.Context, req RefundRequest) (*Refund, error)
+	// ParseWebhook decodes and verifies an inbound webhook body; ErrInvalidPayload when it is not one.
+	ParseWebhook(ctx context.Context, body []byte) (*WebhookEvent, error)
+}
+
+// ChargeRequest is a charge to create. Amounts are minor units (satang for THB).
+type ChargeRequest struct {
+	Method       string // MethodCard, MethodPromptPay or MethodInternetBanking
+	AmountSatang int64
+	Currency     string
+	CardToken    string // MethodCard: the card tokenized on the client
+	Bank         string // MethodInternetBanking: e.g. "bbl", "scb"
+	ReturnURI    string // where redirect-based methods (3DS, internet banking) send the payer back
+	IP           string // the payer's address, if known
+	Require3DS   bool   // MethodCard: authenticate the payer with 3-D Secure whatever the account's settings
+	Description  string
+	Metadata     map[string]interface{}
+}
+
+// Charge is a charge as the provider reports it.
+type Charge struct {
+	Provider     string
+	ID           string
+	Status       string // "pending", "successful", "failed", ...
+	AmountSatang int64
+	Currency     string
+	Native       interface{} // the provider's charge, e.g. *omise.Charge
+}
+
+// RefundRequest refunds AmountSatang of ChargeID; Metadata is attached to the refund.
+type RefundRequest struct {
+	ChargeID     string
+	AmountSatang int64
+	Metadata     map[string]interface{}
+}
+
+// Refund is a refund the provider created.
+type Refund struct {
+	ID           string
+	ChargeID     string
+	AmountSatang int64
+	Native       interface{} // the provider's refund, e.g. *omise.Refund
+}
+
+// WebhookEvent is a verified webhook. ChargeID is empty for webhooks that are not about a charge,
+// which are acknowledged and ignored.
+type WebhookEvent struct {
+	ID       string      // the event id, or the charge id for bare charge payloads
+	Key      string      // event type, e.g. "charge.complete"; empty for bare charge payloads
+	ChargeID string      // the charge the event concerns; re-read it with RetrieveCharge
+	Native   interface{} // the provider's event, e.g. *omise.Event; nil for bare charge payloads
+}
@@ -0,0 +1,176 @@
+// Package qrimage composes a PromptPay QR code with our branding, the amount and the expiry
+// into a single PNG that can be forwarded in chat apps.
+package qrimage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"  // decode QR images served as GIF
+	_ "image/jpeg" // decode QR images served as JPEG
+	"image/png"
+	"io"
+	"time"
+
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// Layout (pixels). The QR is scaled with nearest-neighbour so modules stay crisp for scanners.
+const (
+	width       = 480
+	padding     = 24
+	headerH     = 88
+	qrSize      = width - 2*padding
+	footerH     = 132
+	height      = headerH + qrSize + footerH
+	logoMaxSize = 56
+)
+
+var (
+	brandColor = color.RGBA{0x4b, 0x2c, 0x91, 0xff} // matches the app bar
+	textColor  = color.RGBA{0x22, 0x22, 0x22, 0xff}
+	mutedColor = color.RGBA{0x77, 0x77, 0x77, 0xff}
+	alertColor = color.RGBA{0xc6, 0x28, 0x28, 0xff}
+)
+
+// ErrUnsupportedFormat is returned when the QR image is neither a raster image nor SVG.
+var ErrUnsupportedFormat = errors.New("qrimage: unsupported image format")
+
+// Card is what gets printed around the QR code.
+type Card struct {
+	Brand     string      // header text, e.g. "Tutorium"
+	Logo      image.Image // optional; drawn left of Brand
+	Amount    string      // formatted amount, e.g. "1,000.00 THB"
+	Reference string      // charge id, printed small for support
+	ExpiresAt time.Time   // zero hides the expiry line
+	Now       time.Time   // render time, used for the "expires in" countdown
+	Location  *time.Location
+}
+
+// Decode reads a QR image as served by Omise: PNG/JPEG/GIF, or SVG (rasterized at qrSize).
+func Decode(data []byte) (image.Image, error) {
+	if looksLikeSVG(data) {
+		icon, err := oksvg.ReadIconStream(bytes.NewReader(data), oksvg.IgnoreErrorMode)
+		if err != nil {
+			return nil, fmt.Errorf("qrimage: parse svg: %w", err)
+		}
+		img := image.NewRGBA(image.Rect(0, 0, qrSize, qrSize))
+		draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+		icon.SetTarget(0, 0, qrSize, qrSize)
+		icon.Draw(rasterx.NewDasher(qrSize, qrSize, rasterx.NewScannerGV(qrSize, qrSize, img, img.Bounds())), 1)
+		return img, nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if errors.Is(err, image.ErrFormat) {
+		return nil, ErrUnsupportedFormat
+	}
+	return img, err
+}
+
+// (helper for Decode) sniff the first bytes for an XML/SVG document.
+func looksLikeSVG(data []byte) bool {
+	head := bytes.TrimSpace(data[:min(len(data), 512)])
+	return bytes.HasPrefix(head, []byte("<?xml")) || bytes.Contains(head, []byte("<svg"))
+}
+
+// Render writes the branded card as PNG.
+func Render(w io.Writer, qr image.Image, card Card) error {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	// Header band: logo + brand name.
+	draw.Draw(img, image.Rect(0, 0, width, headerH), image.NewUniform(brandColor), image.Point{}, draw.Src)
+	textX := padding
+	if card.Logo != nil {
+		lb := fitWithin(card.Logo.Bounds(), logoMaxSize)
+		dst := lb.Add(image.Pt(padding, (headerH-lb.Dy())/2))
+		xdraw.CatmullRom.Scale(img, dst, card.Logo, card.Logo.Bounds(), draw.Over, nil)
+		textX = dst.Max.X + 12
+	}
+	drawText(img, card.Brand, textX, (headerH-basicfont.Face7x13.Height*3)/2, 3, color.White)
+
+	// QR code.
+	qrRect := image.Rect(padding, headerH, padding+qrSize, headerH+qrSize)
+	xdraw.NearestNeighbor.Scale(img, qrRect, qr, qr.Bounds(), draw.Over, nil)
+
+	// Footer: amount, expiry, reference.
+	y := headerH + qrSize + 12
+	drawTextCentered(img, card.Amount, y, 3, textColor)
+	y += 48
+	if line, expired := expiryLine(card); line != "" {
+		c := color.Color(textColor)
+		if expired {
+			c = alertColor
+		}
+		drawTextCentered(img, line, y, 2, c)
+	}
+	y += 36
+	if card.Reference != "" {
+		drawTextCentered(img, "Ref "+card.Reference, y, 1, mutedColor)
+	}
+
+	return png.Encode(w, img)
+}
+
+// expiryLine renders "Expires 14:05 (in 12 min)" at render time; a PNG cannot tick, so the
+// countdown is as of generation and the absolute time is printed next to it.
+func expiryLine(card Card) (string, bool) {
+	if card.ExpiresAt.IsZero() {
+		return "", false
+	}
+	loc := card.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	at := card.ExpiresAt.In(loc).Format("02 Jan 15:04")
+	left := card.ExpiresAt.Sub(card.Now)
+	switch {
+	case left <= 0:
+		return "Expired " + at, true
+	case left < time.Hour:
+		return fmt.Sprintf("Expires %s (in %d min)", at, int(left.Minutes())+1), false
+	default:
+		return fmt.Sprintf("Expires %s (in %dh %02dm)", at, int(left.Hours()), int(left.Minutes())%60), false
+	}
+}
+
+// (helper for Render) scale a rectangle to fit in a size x size box, keeping the aspect ratio.
+func fitWithin(r image.Rectangle, size int) image.Rectangle {
+	w, h := r.Dx(), r.Dy()
+	if w <= 0 || h <= 0 {
+		return image.Rectangle{}
+	}
+	if w >= h {
+		return image.Rect(0, 0, size, h*size/w)
+	}
+	return image.Rect(0, 0, w*size/h, size)
+}
+
+// (helper for Render) draw ASCII text with the built-in bitmap face, scaled up by an integer factor.
+// The bitmap face keeps the service free of font files; it only covers ASCII, so callers pass ASCII.
+func drawText(dst draw.Image, s string, x, y, scale int, c color.Color) {
+	face := basicfont.Face7x13
+	w := font.MeasureString(face, s).Ceil()
+	if w == 0 {
+		return
+	}
+	small := image.NewRGBA(image.Rect(0, 0, w, face.Height))
+	d := font.Drawer{Dst: small, Src: image.NewUniform(c), Face: face, Dot: fixed.P(0, face.Ascent)}
+	d.DrawString(s)
+	r := image.Rect(x, y, x+w*scale, y+face.Height*scale)
+	xdraw.NearestNeighbor.Scale(dst, r, small, small.Bounds(), draw.Over, nil)
+}
+
+// (helper for Render) draw text horizontally centered on the card.
+func drawTextCentered(dst draw.Image, s string, y, scale int, c color.Color) {
+	w := font.MeasureString(basicfont.Face7x13, s).Ceil() * scale
+	drawText(dst, s, (width-w)/2, y, scale, c)
+}
@@ -0,0 +1,81 @@
+package qrimage
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+	"time"
+)
+
+const svgQR = `<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" width="21" height="21" viewBox="0 0 21 21">
+<rect width="21" height="21" fill="#fff"/><rect x="0" y="0" width="7" height="7" fill="#000"/>
+</svg>`
+
+func TestDecodeSVGAndPNG(t *testing.T) {
+	img, err := Decode([]byte(svgQR))
+	if err != nil {
+		t.Fatalf("Decode(svg): %v", err)
+	}
+	if got := img.Bounds().Dx(); got != qrSize {
+		t.Errorf("svg width = %d, want %d", got, qrSize)
+	}
+	if r, _, _, _ := img.At(5, 5).RGBA(); r > 0x1000 {
+		t.Errorf("top-left module not dark after rasterizing")
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 10, 10))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decode(buf.Bytes()); err != nil {
+		t.Errorf("Decode(png): %v", err)
+	}
+
+	if _, err := Decode([]byte("not an image")); err != ErrUnsupportedFormat {
+		t.Errorf("Decode(garbage) err = %v, want ErrUnsupportedFormat", err)
+	}
+}
+
+func TestRender(t *testing.T) {
+	now := time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	err := Render(&buf, image.NewGray(image.Rect(0, 0, 21, 21)), Card{
+		Brand:     "Tutorium",
+		Amount:    "1,000.00 THB",
+		Reference: "chrg_test_1",
+		ExpiresAt: now.Add(15 * time.Minute),
+		Now:       now,
+	})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	out, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("output is not PNG: %v", err)
+	}
+	if b := out.Bounds(); b.Dx() != width || b.Dy() != height {
+		t.Errorf("size = %v, want %dx%d", b, width, height)
+	}
+}
+
+func TestExpiryLine(t *testing.T) {
+	now := time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+	cases := []struct {
+		expires time.Time
+		want    string
+		expired bool
+	}{
+		{time.Time{}, "", false},
+		{now.Add(14*time.Minute + 30*time.Second), "Expires 02 Jan 10:14 (in 15 min)", false},
+		{now.Add(90 * time.Minute), "Expires 02 Jan 11:30 (in 1h 30m)", false},
+		{now.Add(-time.Minute), "Expired 02 Jan 09:59", true},
+	}
+	for _, tc := range cases {
+		got, expired := expiryLine(Card{ExpiresAt: tc.expires, Now: now, Location: time.UTC})
+		if got != tc.want || expired != tc.expired {
+			t.Errorf("expiryLine(%v) = %q,%v want %q,%v", tc.expires, got, expired, tc.want, tc.expired)
+		}
+	}
+}
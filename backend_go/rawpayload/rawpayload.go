@@ -0,0 +1,191 @@
+// Package rawpayload masks PII in stored provider payloads and optionally encrypts them at rest.
+package rawpayload
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	omise "github.com/omise/omise-go"
+)
+
+// keys whose string values are masked wherever they appear in the payload.
+var maskedKeys = map[string]bool{
+	"name":         true,
+	"email":        true,
+	"phone_number": true,
+	"city":         true,
+	"postal_code":  true,
+	"street1":      true,
+	"street2":      true,
+	"ip":           true,
+}
+
+// keys removed entirely (should never be present, but never persist them if they are).
+var droppedKeys = map[string]bool{
+	"number":        true,
+	"security_code": true,
+}
+
+// Mask returns the JSON payload with cardholder PII masked and sensitive card fields removed.
+// Non-object payloads are returned unchanged.
+func Mask(payload []byte) ([]byte, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(payload, &doc); err != nil {
+		return nil, err
+	}
+	maskValue(doc)
+	return json.Marshal(doc)
+}
+
+func maskValue(v interface{}) {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		for k, child := range vv {
+			switch {
+			case droppedKeys[k]:
+				delete(vv, k)
+			case maskedKeys[k]:
+				if s, ok := child.(string); ok && s != "" {
+					vv[k] = maskString(s)
+				}
+			default:
+				maskValue(child)
+			}
+		}
+	case []interface{}:
+		for _, child := range vv {
+			maskValue(child)
+		}
+	}
+}
+
+// MaskCharge returns a copy of the charge with the same fields masked as Mask would mask in its JSON.
+// It avoids a decode/encode round trip on the webhook hot path; the input is not modified.
+func MaskCharge(ch *omise.Charge) *omise.Charge {
+	if ch == nil {
+		return nil
+	}
+	cp := *ch
+	if ch.Card != nil {
+		card := *ch.Card
+		card.Name = maskString(card.Name)
+		card.City = maskString(card.City)
+		card.PostalCode = maskString(card.PostalCode)
+		cp.Card = &card
+	}
+	if ch.IP != nil {
+		ip := maskString(*ch.IP)
+		cp.IP = &ip
+	}
+	if ch.Source != nil {
+		src := *ch.Source
+		src.Ip = maskString(src.Ip)
+		cp.Source = &src
+	}
+	if ch.Metadata != nil {
+		md := copyValue(ch.Metadata).(map[string]interface{})
+		maskValue(md)
+		cp.Metadata = md
+	}
+	return &cp
+}
+
+// (helper for MaskCharge) deep-copy decoded JSON values so masking never mutates the caller's data.
+func copyValue(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(vv))
+		for k, child := range vv {
+			out[k] = copyValue(child)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(vv))
+		for i, child := range vv {
+			out[i] = copyValue(child)
+		}
+		return out
+	}
+	return v
+}
+
+// maskString keeps the first rune of each word: "John Doe" -> "J*** D**".
+func maskString(s string) string {
+	words := strings.Fields(s)
+	for i, w := range words {
+		r := []rune(w)
+		words[i] = string(r[0]) + strings.Repeat("*", len(r)-1)
+	}
+	return strings.Join(words, " ")
+}
+
+// ---------------------- encryption at rest ----------------------
+
+// header marks an encrypted payload; rows without it are legacy plaintext.
+var header = []byte("ENC1")
+
+// Cipher seals payloads with AES-256-GCM.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipherFromBase64 builds a Cipher from a base64-encoded 32-byte key (RAW_PAYLOAD_KEY).
+func NewCipherFromBase64(key string) (*Cipher, error) {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("decode key: %w", err)
+	}
+	if len(raw) != 32 {
+		return nil, fmt.Errorf("key must be 32 bytes, got %d", len(raw))
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// Encrypt returns header || nonce || ciphertext. A nil Cipher returns the plaintext unchanged.
+func (c *Cipher) Encrypt(plaintext []byte) ([]byte, error) {
+	if c == nil {
+		return plaintext, nil
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append([]byte{}, header...)
+	out = append(out, nonce...)
+	return c.aead.Seal(out, nonce, plaintext, header), nil
+}
+
+// Decrypt reverses Encrypt. Payloads without the header are returned as-is (legacy plaintext).
+func (c *Cipher) Decrypt(data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+	if c == nil {
+		return nil, errors.New("payload is encrypted but no key is configured")
+	}
+	body := data[len(header):]
+	ns := c.aead.NonceSize()
+	if len(body) < ns {
+		return nil, errors.New("encrypted payload is truncated")
+	}
+	return c.aead.Open(nil, body[:ns], body[ns:], header)
+}
+
+// IsEncrypted reports whether data was produced by Encrypt.
+func IsEncrypted(data []byte) bool {
+	return len(data) >= len(header) && string(data[:len(header)]) == string(header)
+}
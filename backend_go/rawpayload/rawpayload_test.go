@@ -0,0 +1,57 @@
+package rawpayload
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	omise "github.com/omise/omise-go"
+)
+
+func TestMaskChargeMatchesMask(t *testing.T) {
+	ip := "203.0.113.10"
+	ch := &omise.Charge{
+		Base:     omise.Base{Object: "charge", ID: "chrg_test_1"},
+		IP:       &ip,
+		Card:     &omise.Card{Name: "Somchai Jaidee", City: "Bangkok", PostalCode: "10330", LastDigits: "4242"},
+		Source:   &omise.Source{Type: "promptpay", Ip: "203.0.113.10"},
+		Metadata: map[string]interface{}{"user_id": "1", "payer": map[string]interface{}{"email": "a@example.com"}},
+	}
+	raw, _ := json.Marshal(ch)
+	want, err := Mask(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := json.Marshal(MaskCharge(ch))
+
+	var wantDoc, gotDoc interface{}
+	_ = json.Unmarshal(want, &wantDoc)
+	_ = json.Unmarshal(got, &gotDoc)
+	w, _ := json.Marshal(wantDoc)
+	g, _ := json.Marshal(gotDoc)
+	if string(w) != string(g) {
+		t.Errorf("MaskCharge differs from Mask:\n got %s\nwant %s", g, w)
+	}
+	if ch.Card.Name != "Somchai Jaidee" || ch.Metadata["payer"].(map[string]interface{})["email"] != "a@example.com" {
+		t.Error("MaskCharge modified its input")
+	}
+}
+
+func TestCipherRoundTrip(t *testing.T) {
+	c, err := NewCipherFromBase64(base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := c.Encrypt([]byte(`{"id":"chrg_test_1"}`))
+	if err != nil || !IsEncrypted(sealed) {
+		t.Fatalf("Encrypt = %v, encrypted=%v", err, IsEncrypted(sealed))
+	}
+	plain, err := c.Decrypt(sealed)
+	if err != nil || string(plain) != `{"id":"chrg_test_1"}` {
+		t.Errorf("Decrypt = %q, %v", plain, err)
+	}
+	legacy, err := c.Decrypt([]byte(`{"id":"legacy"}`))
+	if err != nil || string(legacy) != `{"id":"legacy"}` {
+		t.Errorf("Decrypt(plaintext) = %q, %v", legacy, err)
+	}
+}
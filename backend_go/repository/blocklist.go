@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/gorm"
+)
+
+// BlocklistRepository stores the blocklist: the users, cards and email addresses whose charges are
+// refused (see models.BlocklistEntry).
+type BlocklistRepository interface {
+	// Create adds entry; false, without adding it, when its kind and value are already blocked.
+	Create(entry *models.BlocklistEntry) (bool, error)
+	// Get returns the entry of id.
+	Get(id uint) (*models.BlocklistEntry, error)
+	// Delete removes the entry of id.
+	Delete(id uint) error
+	// List returns a page of the entries of kind ("" for every kind), newest first. It reads from the
+	// replica when one is configured.
+	List(kind string, limit, offset int) ([]models.BlocklistEntry, error)
+	// BlocksPayer reports, in one query, whether userID (nil for none) is blocked as a user or by the
+	// notify email of their auto-reload setting, or email ("" for none; lower case) is blocked.
+	BlocksPayer(userID *uint, email string) (bool, error)
+	// BlocksCard reports whether the card of fingerprint is blocked; for "", whether any card is.
+	BlocksCard(fingerprint string) (bool, error)
+
+	// WithTx returns a repository bound to the DB transaction tx.
+	WithTx(tx *gorm.DB) BlocklistRepository
+	// WithContext returns a repository whose queries are cancelled with ctx.
+	WithContext(ctx context.Context) BlocklistRepository
+}
+
+// NewBlocklistRepository returns the Postgres BlocklistRepository.
+func NewBlocklistRepository(db *gorm.DB) BlocklistRepository {
+	return &pgBlocklist{db: db}
+}
+
+type pgBlocklist struct {
+	db *gorm.DB
+}
+
+func (r *pgBlocklist) WithTx(tx *gorm.DB) BlocklistRepository {
+	return &pgBlocklist{db: tx}
+}
+
+func (r *pgBlocklist) WithContext(ctx context.Context) BlocklistRepository {
+	return &pgBlocklist{db: r.db.WithContext(ctx)}
+}
+
+func (r *pgBlocklist) Create(entry *models.BlocklistEntry) (bool, error) {
+	if err := r.db.Create(entry).Error; err != nil {
+		if dbutil.IsUniqueViolation(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *pgBlocklist) Get(id uint) (*models.BlocklistEntry, error) {
+	var entry models.BlocklistEntry
+	if err := r.db.Take(&entry, id).Error; err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (r *pgBlocklist) Delete(id uint) error {
+	return r.db.Delete(&models.BlocklistEntry{}, id).Error
+}
+
+func (r *pgBlocklist) List(kind string, limit, offset int) ([]models.BlocklistEntry, error) {
+	q := dbutil.Replica(r.db).Model(&models.BlocklistEntry{})
+	if kind != "" {
+		q = q.Where("kind = ?", kind)
+	}
+	entries := []models.BlocklistEntry{}
+	if err := q.Order("created_at DESC, id DESC").Limit(limit).Offset(offset).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (r *pgBlocklist) BlocksPayer(userID *uint, email string) (bool, error) {
+	var (
+		conds []string
+		args  []interface{}
+	)
+	if userID != nil {
+		conds = append(conds, "(kind = ? AND value = ?)",
+			"(kind = ? AND value IN (SELECT LOWER(notify_email) FROM auto_reloads WHERE user_id = ? AND deleted_at IS NULL))")
+		args = append(args, models.BlockUserID, strconv.FormatUint(uint64(*userID), 10), models.BlockEmail, *userID)
+	}
+	if email != "" {
+		conds = append(conds, "(kind = ? AND value = ?)")
+		args = append(args, models.BlockEmail, email)
+	}
+	if len(conds) == 0 {
+		return false, nil
+	}
+	return r.has(r.db.Where(strings.Join(conds, " OR "), args...))
+}
+
+func (r *pgBlocklist) BlocksCard(fingerprint string) (bool, error) {
+	q := r.db.Where("kind = ?", models.BlockCardFingerprint)
+	if fingerprint != "" {
+		q = q.Where("value = ?", fingerprint)
+	}
+	return r.has(q)
+}
+
+// (helper) whether q matches any entry.
+func (r *pgBlocklist) has(q *gorm.DB) (bool, error) {
+	var n int64
+	if err := q.Model(&models.BlocklistEntry{}).Count(&n).Error; err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/gorm"
+)
+
+// JobLeases stores the job_leases rows behind jobs.Elector (it implements jobs.LeaseStore). Expiry is
+// computed on the database clock, so replicas with skewed clocks still agree on who holds a lease.
+type JobLeases struct {
+	db *gorm.DB
+}
+
+// NewJobLeaseRepository returns the Postgres lease store.
+func NewJobLeaseRepository(db *gorm.DB) *JobLeases {
+	return &JobLeases{db: db}
+}
+
+// AcquireLease takes name for holder when it is free, expired or already holder's, in one upsert,
+// and returns whoever holds it afterwards.
+func (r *JobLeases) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (string, time.Time, error) {
+	var lease models.JobLease
+	err := dbutil.Retry("acquire_job_lease", func() error {
+		db := r.db.WithContext(ctx)
+		if err := db.Exec(`INSERT INTO job_leases (name, holder, expires_at, updated_at)
+			VALUES (?, ?, now() + ? * interval '1 millisecond', now())
+			ON CONFLICT (name) DO UPDATE SET holder = EXCLUDED.holder, expires_at = EXCLUDED.expires_at, updated_at = EXCLUDED.updated_at
+			WHERE job_leases.holder = EXCLUDED.holder OR job_leases.expires_at < now()`,
+			name, holder, ttl.Milliseconds()).Error; err != nil {
+			return err
+		}
+		return db.Where("name = ?", name).Take(&lease).Error
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return lease.Holder, lease.ExpiresAt, nil
+}
+
+// ReleaseLease expires name now if holder still holds it.
+func (r *JobLeases) ReleaseLease(ctx context.Context, name, holder string) error {
+	return r.db.WithContext(ctx).Model(&models.JobLease{}).
+		Where("name = ? AND holder = ?", name, holder).
+		Update("expires_at", gorm.Expr("now()")).Error
+}
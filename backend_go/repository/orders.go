@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/gorm"
+)
+
+// OrderRepository stores the orders charges pay for, with their items.
+type OrderRepository interface {
+	// Create inserts order and its items; their ids are set.
+	Create(order *models.Order) error
+	// Get returns the order with its items, in the order they were added.
+	Get(id uint) (*models.Order, error)
+	// Cancel moves a pending order to cancelled; false when it is not pending.
+	Cancel(id uint) (bool, error)
+	// MarkPaid moves a pending order to paid by transactionID at at; false when it is not pending
+	// (already paid, or cancelled).
+	MarkPaid(id, transactionID uint, at time.Time) (bool, error)
+
+	// WithTx returns a repository bound to the DB transaction tx.
+	WithTx(tx *gorm.DB) OrderRepository
+	// WithContext returns a repository whose queries are cancelled with ctx.
+	WithContext(ctx context.Context) OrderRepository
+}
+
+// NewOrderRepository returns the Postgres OrderRepository.
+func NewOrderRepository(db *gorm.DB) OrderRepository {
+	return &pgOrders{db: db}
+}
+
+type pgOrders struct {
+	db *gorm.DB
+}
+
+func (r *pgOrders) WithTx(tx *gorm.DB) OrderRepository {
+	return &pgOrders{db: tx}
+}
+
+func (r *pgOrders) WithContext(ctx context.Context) OrderRepository {
+	return &pgOrders{db: r.db.WithContext(ctx)}
+}
+
+func (r *pgOrders) Create(order *models.Order) error {
+	return r.db.Create(order).Error
+}
+
+func (r *pgOrders) Get(id uint) (*models.Order, error) {
+	var order models.Order
+	if err := r.db.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).Take(&order, id).Error; err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (r *pgOrders) Cancel(id uint) (bool, error) {
+	res := r.db.Model(&models.Order{}).Where("id = ? AND status = ?", id, models.OrderPending).
+		Update("status", models.OrderCancelled)
+	return res.RowsAffected > 0, res.Error
+}
+
+func (r *pgOrders) MarkPaid(id, transactionID uint, at time.Time) (bool, error) {
+	res := r.db.Model(&models.Order{}).Where("id = ? AND status = ?", id, models.OrderPending).
+		Updates(map[string]interface{}{"status": models.OrderPaid, "paid_at": at, "paid_transaction_id": transactionID, "updated_at": at})
+	return res.RowsAffected > 0, res.Error
+}
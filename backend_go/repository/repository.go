@@ -0,0 +1,43 @@
+// Package repository holds the data access for transactions and users behind interfaces, so handlers
+// only orchestrate and tests can swap in another store. The Postgres implementations wrap GORM; bind
+// them to a DB transaction with WithTx to combine several calls atomically (see dbutil.Transaction).
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNotFound is returned when the requested row does not exist. It is gorm.ErrRecordNotFound, so
+	// errors.Is works with either name.
+	ErrNotFound = gorm.ErrRecordNotFound
+
+	// ErrInsufficientBalance is returned when a guarded balance change would make the balance negative.
+	ErrInsufficientBalance = errors.New("insufficient balance")
+)
+
+// PageSize bounds the pages of the listings: Default rows when the caller asks for no limit, at most
+// Max whatever it asks for. Zero fields take DefaultPageSize's.
+type PageSize struct {
+	Default int
+	Max     int
+}
+
+// DefaultPageSize is the page size of listings unless configured otherwise.
+var DefaultPageSize = PageSize{Default: 50, Max: 200}
+
+// Limit is the effective page size for a requested limit: Default for none (<= 0), clamped to Max.
+func (p PageSize) Limit(requested int) int {
+	if p.Max <= 0 {
+		p.Max = DefaultPageSize.Max
+	}
+	if p.Default <= 0 {
+		p.Default = min(DefaultPageSize.Default, p.Max)
+	}
+	if requested <= 0 {
+		requested = p.Default
+	}
+	return min(requested, p.Max)
+}
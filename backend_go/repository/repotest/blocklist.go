@@ -0,0 +1,120 @@
+package repotest
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"gorm.io/gorm"
+)
+
+// Blocklist is an in-memory repository.BlocklistRepository. Like Users, WithTx returns the same
+// store. NotifyEmails stands in for the auto_reloads table: the notify email of each user's
+// auto-reload setting.
+type Blocklist struct {
+	NotifyEmails map[uint]string
+
+	mu      sync.Mutex
+	seq     uint
+	entries map[uint]*models.BlocklistEntry
+}
+
+var _ repository.BlocklistRepository = (*Blocklist)(nil)
+
+func NewBlocklist() *Blocklist {
+	return &Blocklist{NotifyEmails: map[uint]string{}, entries: map[uint]*models.BlocklistEntry{}}
+}
+
+func (s *Blocklist) Create(entry *models.BlocklistEntry) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.match(entry.Kind, entry.Value) {
+		return false, nil
+	}
+	s.seq++
+	entry.ID, entry.CreatedAt = s.seq, time.Now()
+	copied := *entry
+	s.entries[entry.ID] = &copied
+	return true, nil
+}
+
+func (s *Blocklist) Get(id uint) (*models.BlocklistEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	copied := *entry
+	return &copied, nil
+}
+
+func (s *Blocklist) Delete(id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, id)
+	return nil
+}
+
+func (s *Blocklist) List(kind string, limit, offset int) ([]models.BlocklistEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := []models.BlocklistEntry{}
+	for _, e := range s.entries {
+		if kind == "" || e.Kind == kind {
+			entries = append(entries, *e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID > entries[j].ID })
+	if offset > len(entries) {
+		offset = len(entries)
+	}
+	entries = entries[offset:]
+	if limit < len(entries) {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+func (s *Blocklist) BlocksPayer(userID *uint, email string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if userID != nil {
+		if s.match(models.BlockUserID, strconv.FormatUint(uint64(*userID), 10)) {
+			return true, nil
+		}
+		if notify, ok := s.NotifyEmails[*userID]; ok && s.match(models.BlockEmail, strings.ToLower(notify)) {
+			return true, nil
+		}
+	}
+	return email != "" && s.match(models.BlockEmail, email), nil
+}
+
+func (s *Blocklist) BlocksCard(fingerprint string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		if e.Kind == models.BlockCardFingerprint && (fingerprint == "" || e.Value == fingerprint) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Blocklist) WithTx(*gorm.DB) repository.BlocklistRepository             { return s }
+func (s *Blocklist) WithContext(context.Context) repository.BlocklistRepository { return s }
+
+// (helper) whether an entry blocks kind and value; s.mu is held.
+func (s *Blocklist) match(kind, value string) bool {
+	for _, e := range s.entries {
+		if e.Kind == kind && e.Value == value {
+			return true
+		}
+	}
+	return false
+}
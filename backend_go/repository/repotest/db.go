@@ -0,0 +1,116 @@
+// Package repotest provides test doubles for the repository interfaces: in-memory stores (Users,
+// WalletOperations, ...) and NewDB, a *gorm.DB on no database, for the code that binds them to a DB
+// transaction (dbutil.Transaction and WithTx). Writes the code makes on the transaction itself, such
+// as audit entries, succeed without effect; the state the test checks is in the stores.
+package repotest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"sync"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// NewDB returns a Postgres-dialect *gorm.DB that runs no SQL: transactions begin, commit and roll
+// back, statements succeed affecting no rows and queries return none (so First and Take report
+// gorm.ErrRecordNotFound). Statements lists what it was given.
+func NewDB() *gorm.DB {
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(&stubConnector{})}),
+		&gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		panic(err) // nothing to connect to, so nothing fails
+	}
+	return db
+}
+
+// Statements returns the SQL db (from NewDB) ran so far, in order, with BEGIN, COMMIT and ROLLBACK
+// for its transactions; nil for any other *gorm.DB.
+func Statements(db *gorm.DB) []string {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil
+	}
+	stub, ok := sqlDB.Driver().(*stubConnector)
+	if !ok {
+		return nil
+	}
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	return append([]string(nil), stub.statements...)
+}
+
+// stubConnector is the database/sql driver of NewDB; it logs the statements of all its connections.
+type stubConnector struct {
+	mu         sync.Mutex
+	statements []string
+}
+
+func (d *stubConnector) Connect(context.Context) (driver.Conn, error) { return stubConn{d}, nil }
+func (d *stubConnector) Driver() driver.Driver                        { return d }
+func (d *stubConnector) Open(string) (driver.Conn, error)             { return stubConn{d}, nil }
+
+func (d *stubConnector) log(query string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.statements = append(d.statements, query)
+}
+
+type stubConn struct{ d *stubConnector }
+
+func (c stubConn) Prepare(query string) (driver.Stmt, error) { return stubStmt{c.d, query}, nil }
+func (c stubConn) Close() error                              { return nil }
+func (c stubConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c stubConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	c.d.log("BEGIN")
+	return stubTx(c), nil
+}
+
+func (c stubConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.d.log(query)
+	return driver.RowsAffected(0), nil
+}
+
+func (c stubConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	c.d.log(query)
+	return noRows{}, nil
+}
+
+// CheckNamedValue takes any argument as is: nothing reads it.
+func (c stubConn) CheckNamedValue(*driver.NamedValue) error { return nil }
+
+type stubTx struct{ d *stubConnector }
+
+func (t stubTx) Commit() error   { t.d.log("COMMIT"); return nil }
+func (t stubTx) Rollback() error { t.d.log("ROLLBACK"); return nil }
+
+type stubStmt struct {
+	d     *stubConnector
+	query string
+}
+
+func (s stubStmt) Close() error  { return nil }
+func (s stubStmt) NumInput() int { return -1 }
+
+func (s stubStmt) Exec([]driver.Value) (driver.Result, error) {
+	s.d.log(s.query)
+	return driver.RowsAffected(0), nil
+}
+
+func (s stubStmt) Query([]driver.Value) (driver.Rows, error) {
+	s.d.log(s.query)
+	return noRows{}, nil
+}
+
+type noRows struct{}
+
+func (noRows) Columns() []string              { return nil }
+func (noRows) Close() error                   { return nil }
+func (noRows) Next(dest []driver.Value) error { return io.EOF }
@@ -0,0 +1,80 @@
+package repotest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"gorm.io/gorm"
+)
+
+// Orders is an in-memory repository.OrderRepository. Like Users, WithTx returns the same store.
+type Orders struct {
+	mu       sync.Mutex
+	seq      uint
+	orders   map[uint]*models.Order
+	itemsSeq uint
+}
+
+var _ repository.OrderRepository = (*Orders)(nil)
+
+func NewOrders() *Orders {
+	return &Orders{orders: map[uint]*models.Order{}}
+}
+
+func (s *Orders) Create(order *models.Order) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	order.ID = s.seq
+	order.CreatedAt, order.UpdatedAt = time.Now(), time.Now()
+	for i := range order.Items {
+		s.itemsSeq++
+		order.Items[i].ID, order.Items[i].OrderID = s.itemsSeq, order.ID
+	}
+	s.orders[order.ID] = copyOrder(order)
+	return nil
+}
+
+func (s *Orders) Get(id uint) (*models.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	order, ok := s.orders[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return copyOrder(order), nil
+}
+
+func (s *Orders) Cancel(id uint) (bool, error) {
+	return s.move(id, func(o *models.Order) { o.Status = models.OrderCancelled })
+}
+
+func (s *Orders) MarkPaid(id, transactionID uint, at time.Time) (bool, error) {
+	return s.move(id, func(o *models.Order) {
+		o.Status, o.PaidAt, o.PaidTransactionID, o.UpdatedAt = models.OrderPaid, &at, &transactionID, at
+	})
+}
+
+func (s *Orders) WithTx(*gorm.DB) repository.OrderRepository             { return s }
+func (s *Orders) WithContext(context.Context) repository.OrderRepository { return s }
+
+// (helper for Cancel and MarkPaid) apply change to order id while it is pending.
+func (s *Orders) move(id uint, change func(o *models.Order)) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	order, ok := s.orders[id]
+	if !ok || order.Status != models.OrderPending {
+		return false, nil
+	}
+	change(order)
+	return true, nil
+}
+
+func copyOrder(o *models.Order) *models.Order {
+	copied := *o
+	copied.Items = append([]models.OrderItem(nil), o.Items...)
+	return &copied
+}
@@ -0,0 +1,182 @@
+package repotest
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"gorm.io/gorm"
+)
+
+// Transactions is an in-memory repository.TransactionRepository holding one row per charge id, and
+// their status history. Like Users, WithTx returns the same store. It implements the lookups and
+// writes of recording charges; the listings and reports (List, Stats, ...) are not implemented and
+// panic.
+type Transactions struct {
+	repository.TransactionRepository
+
+	mu     sync.Mutex
+	seq    uint
+	rows   []*models.Transaction
+	events []models.TransactionEvent
+}
+
+func NewTransactions(rows ...models.Transaction) *Transactions {
+	s := &Transactions{}
+	for i := range rows {
+		t := rows[i]
+		if err := s.UpsertByChargeID(&t); err != nil {
+			panic(err)
+		}
+	}
+	return s
+}
+
+// All returns a copy of every row, in insertion order.
+func (s *Transactions) All() []models.Transaction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]models.Transaction, len(s.rows))
+	for i, t := range s.rows {
+		out[i] = *t
+	}
+	return out
+}
+
+// Events returns the status history of transaction id, oldest first.
+func (s *Transactions) Events(id uint) []models.TransactionEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []models.TransactionEvent
+	for _, e := range s.events {
+		if e.TransactionID == id {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func (s *Transactions) Get(id uint) (*models.Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.rows {
+		if t.ID == id {
+			copied := *t
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (s *Transactions) Find(id string) (*models.Transaction, error) {
+	found, err := s.FindMany([]string{id})
+	if err != nil || len(found) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	return &found[0], nil
+}
+
+func (s *Transactions) FindMany(ids []string) ([]models.Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []models.Transaction
+	for _, t := range s.rows {
+		for _, id := range ids {
+			if id == t.ChargeID || id == strconv.FormatUint(uint64(t.ID), 10) {
+				out = append(out, *t)
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+func (s *Transactions) LockByChargeID(chargeID string) (*models.Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t := s.byChargeID(chargeID); t != nil {
+		copied := *t
+		return &copied, nil
+	}
+	return nil, repository.ErrNotFound
+}
+
+// UpsertByChargeID inserts t or updates the columns the Postgres upsert updates on the row with its
+// charge id; like the Postgres one, it sets t.CreatedAt to the row's.
+func (s *Transactions) UpsertByChargeID(t *models.Transaction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if t.UpdatedAt.IsZero() {
+		t.UpdatedAt = now
+	}
+	prev := s.byChargeID(t.ChargeID)
+	if prev == nil {
+		s.seq++
+		t.ID = s.seq
+		if t.CreatedAt.IsZero() {
+			t.CreatedAt = now
+		}
+		copied := *t
+		s.rows = append(s.rows, &copied)
+		return nil
+	}
+	t.ID, t.CreatedAt = prev.ID, prev.CreatedAt
+	prev.Status, prev.Description, prev.FailureCode, prev.FailureMessage, prev.ExpiresAt = t.Status, t.Description, t.FailureCode, t.FailureMessage, t.ExpiresAt
+	prev.AmountSatang, prev.Currency, prev.Channel, prev.CardBrand, prev.CardLastDigits, prev.Bank = t.AmountSatang, t.Currency, t.Channel, t.CardBrand, t.CardLastDigits, t.Bank
+	prev.RawPayload, prev.Meta, prev.UpdatedAt, prev.UserID, prev.ActingUserID = t.RawPayload, t.Meta, t.UpdatedAt, t.UserID, t.ActingUserID
+	prev.OrderID, prev.PaymentLinkID, prev.CouponID, prev.DiscountSatang = t.OrderID, t.PaymentLinkID, t.CouponID, t.DiscountSatang
+	prev.RiskDecision, prev.RiskScore, prev.RiskReasons = t.RiskDecision, t.RiskScore, t.RiskReasons
+	return nil
+}
+
+func (s *Transactions) UpdateStatus(t *models.Transaction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, row := range s.rows {
+		if row.ID == t.ID {
+			row.Status, row.FailureCode, row.FailureMessage = t.Status, t.FailureCode, t.FailureMessage
+		}
+	}
+	return nil
+}
+
+func (s *Transactions) RecordEvent(e *models.TransactionEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.ID = uint(len(s.events) + 1)
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	s.events = append(s.events, *e)
+	return nil
+}
+
+// LatestEventSource returns the source of the event of id recorded last.
+func (s *Transactions) LatestEventSource(id uint) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.events) - 1; i >= 0; i-- {
+		if s.events[i].TransactionID == id {
+			return s.events[i].Source, nil
+		}
+	}
+	return "", repository.ErrNotFound
+}
+
+func (s *Transactions) WithTx(*gorm.DB) repository.TransactionRepository { return s }
+
+func (s *Transactions) WithContext(context.Context) repository.TransactionRepository { return s }
+
+// (helper) the row of chargeID, nil if none; s.mu is held.
+func (s *Transactions) byChargeID(chargeID string) *models.Transaction {
+	for _, t := range s.rows {
+		if t.ChargeID == chargeID {
+			return t
+		}
+	}
+	return nil
+}
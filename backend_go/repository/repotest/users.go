@@ -0,0 +1,133 @@
+package repotest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"gorm.io/gorm"
+)
+
+// Users is an in-memory repository.UserRepository. WithTx and WithContext return the same store, so
+// changes are not rolled back with the DB transaction; a test checks what was committed.
+type Users struct {
+	mu       sync.Mutex
+	users    map[uint]*models.User
+	credited map[string]bool // charge ids CreditCharge credited
+}
+
+var _ repository.UserRepository = (*Users)(nil)
+
+// NewUsers returns a store holding users.
+func NewUsers(users ...models.User) *Users {
+	s := &Users{users: map[uint]*models.User{}, credited: map[string]bool{}}
+	for i := range users {
+		s.Put(users[i])
+	}
+	return s
+}
+
+// Put stores u, replacing the user with its ID.
+func (s *Users) Put(u models.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[u.ID] = &u
+}
+
+func (s *Users) Get(id uint) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	copied := *u
+	return &copied, nil
+}
+
+func (s *Users) GetForUpdate(id uint) (*models.User, error) { return s.Get(id) }
+
+func (s *Users) Credit(id uint, thb float64) error {
+	return s.update(id, func(u *models.User) bool { u.Balance += thb; return true })
+}
+
+func (s *Users) CreditCharge(id uint, chargeID string, thb float64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.credited[chargeID] {
+		return false, nil
+	}
+	u, ok := s.users[id]
+	if !ok {
+		return false, repository.ErrNotFound
+	}
+	s.credited[chargeID] = true
+	u.Balance += thb
+	return true, nil
+}
+
+func (s *Users) Debit(id uint, thb float64) error {
+	return s.update(id, func(u *models.User) bool {
+		if u.Balance < thb {
+			return false
+		}
+		u.Balance -= thb
+		return true
+	})
+}
+
+func (s *Users) Hold(id uint, thb float64) error {
+	return s.update(id, func(u *models.User) bool {
+		if u.Balance < thb {
+			return false
+		}
+		u.Balance, u.HeldBalance = u.Balance-thb, u.HeldBalance+thb
+		return true
+	})
+}
+
+func (s *Users) ReleaseHold(id uint, thb float64) error {
+	return s.update(id, func(u *models.User) bool {
+		u.Balance, u.HeldBalance = u.Balance+thb, u.HeldBalance-thb
+		return true
+	})
+}
+
+func (s *Users) CaptureHold(id uint, thb float64) error {
+	return s.update(id, func(u *models.User) bool { u.HeldBalance -= thb; return true })
+}
+
+func (s *Users) Freeze(id uint, thb float64) error {
+	return s.update(id, func(u *models.User) bool {
+		u.Balance, u.FrozenBalance = u.Balance-thb, u.FrozenBalance+thb
+		return true
+	})
+}
+
+func (s *Users) Unfreeze(id uint, thb float64, restore bool) error {
+	return s.update(id, func(u *models.User) bool {
+		u.FrozenBalance -= thb
+		if restore {
+			u.Balance += thb
+		}
+		return true
+	})
+}
+
+func (s *Users) WithTx(*gorm.DB) repository.UserRepository             { return s }
+func (s *Users) WithContext(context.Context) repository.UserRepository { return s }
+
+// (helper) apply change to user id; change returns false to refuse it as ErrInsufficientBalance.
+func (s *Users) update(id uint, change func(u *models.User) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if !change(u) {
+		return repository.ErrInsufficientBalance
+	}
+	return nil
+}
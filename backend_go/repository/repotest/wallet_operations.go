@@ -0,0 +1,76 @@
+package repotest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"gorm.io/gorm"
+)
+
+// WalletOperations is an in-memory repository.WalletOperationRepository. Like Users, WithTx returns
+// the same store.
+type WalletOperations struct {
+	mu  sync.Mutex
+	seq uint
+	ops map[string]*models.WalletOperation
+}
+
+var _ repository.WalletOperationRepository = (*WalletOperations)(nil)
+
+func NewWalletOperations() *WalletOperations {
+	return &WalletOperations{ops: map[string]*models.WalletOperation{}}
+}
+
+func (s *WalletOperations) Claim(op *models.WalletOperation) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, claimed := s.ops[op.OperationID]; claimed {
+		return false, nil
+	}
+	s.seq++
+	op.ID = s.seq
+	stored := *op
+	s.ops[op.OperationID] = &stored
+	return true, nil
+}
+
+func (s *WalletOperations) Get(operationID string) (*models.WalletOperation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	op, ok := s.ops[operationID]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	copied := *op
+	return &copied, nil
+}
+
+func (s *WalletOperations) LockHold(operationID string) (*models.WalletOperation, error) {
+	op, err := s.Get(operationID)
+	if err != nil {
+		return nil, err
+	}
+	if op.Kind != models.WalletOpHold {
+		return nil, repository.ErrNotFound
+	}
+	return op, nil
+}
+
+func (s *WalletOperations) Save(op *models.WalletOperation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.ops[op.OperationID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	stored.HoldStatus, stored.Balance, stored.HeldBalance = op.HoldStatus, op.Balance, op.HeldBalance
+	return nil
+}
+
+func (s *WalletOperations) WithTx(*gorm.DB) repository.WalletOperationRepository { return s }
+
+func (s *WalletOperations) WithContext(context.Context) repository.WalletOperationRepository {
+	return s
+}
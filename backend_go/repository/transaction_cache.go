@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/cache"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+)
+
+// transactionCacheKey prefixes every key TransactionCache writes.
+const transactionCacheKey = "payments:txn:"
+
+// transactionCacheTimeout bounds invalidation, which must not be skipped because its caller's
+// context has ended.
+const transactionCacheTimeout = time.Second
+
+// transactionCacheMetrics is published at /debug/vars as "transaction_cache": hits, misses and errors
+// (cache failures, served from the database).
+var transactionCacheMetrics = expvar.NewMap("transaction_cache")
+
+// TransactionCache caches single-transaction lookups and List totals (the COUNT(*) over the filter)
+// for the dashboards that poll them. Cached transactions carry no RawPayload, so use them to serve the
+// API only, never to decide on money. Invalidate drops a transaction and every cached total; anything
+// written without it is stale for at most TTL. Cache failures are logged and read from the database.
+// A nil *TransactionCache caches nothing.
+type TransactionCache struct {
+	Store cache.Store
+	TTL   time.Duration
+}
+
+// Find is repo.Find through the cache, keyed by id as given (internal or charge id).
+func (c *TransactionCache) Find(ctx context.Context, repo TransactionRepository, id string) (*models.Transaction, error) {
+	repo = repo.WithContext(ctx)
+	if c == nil {
+		return repo.Find(id)
+	}
+	key := transactionCacheKey + id
+	if raw, err := c.Store.Get(ctx, key); err == nil {
+		var t models.Transaction
+		if err := json.Unmarshal(raw, &t); err == nil {
+			transactionCacheMetrics.Add("hits", 1)
+			return &t, nil
+		}
+	} else if !errors.Is(err, cache.ErrMiss) {
+		c.failed("get", key, err)
+	}
+	transactionCacheMetrics.Add("misses", 1)
+
+	t, err := repo.Find(id)
+	if err != nil {
+		return nil, err
+	}
+	if raw, err := json.Marshal(t); err == nil {
+		if err := c.Store.Set(ctx, key, raw, c.TTL); err != nil {
+			c.failed("set", key, err)
+		}
+	}
+	return t, nil
+}
+
+// List is repo.List with the total read through the cache; the page itself is always read.
+func (c *TransactionCache) List(ctx context.Context, repo TransactionRepository, f TransactionFilter, limit, offset int) ([]models.Transaction, int64, error) {
+	repo = repo.WithContext(ctx)
+	if c == nil {
+		return repo.List(f, limit, offset)
+	}
+	total, err := c.count(ctx, repo, f)
+	if err != nil {
+		return nil, 0, err
+	}
+	page, err := repo.Page(f, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return page, total, nil
+}
+
+// ListAfter is List by keyset (see PageAfter); the total is still the whole filter's.
+func (c *TransactionCache) ListAfter(ctx context.Context, repo TransactionRepository, f TransactionFilter, after *TransactionCursor, limit int) ([]models.Transaction, int64, error) {
+	repo = repo.WithContext(ctx)
+	var total int64
+	var err error
+	if c == nil {
+		total, err = repo.Count(f)
+	} else {
+		total, err = c.count(ctx, repo, f)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	page, err := repo.PageAfter(f, after, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	return page, total, nil
+}
+
+// Invalidate drops the cached copies of t and every cached total. Call it after the commit that
+// changed t: invalidating inside the DB transaction lets a concurrent read cache the old row again.
+func (c *TransactionCache) Invalidate(ctx context.Context, t models.Transaction) {
+	if c == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), transactionCacheTimeout)
+	defer cancel()
+	keys := []string{transactionCacheKey + t.ChargeID}
+	if t.ID != 0 {
+		keys = append(keys, transactionCacheKey+strconv.FormatUint(uint64(t.ID), 10))
+	}
+	if err := c.Store.Delete(ctx, keys...); err != nil {
+		c.failed("delete", keys[0], err)
+	}
+	c.invalidateTotals(ctx)
+}
+
+// InvalidateTotals drops every cached total, for commits that only inserted transactions (which
+// Find never cached, since misses are not stored).
+func (c *TransactionCache) InvalidateTotals(ctx context.Context) {
+	if c == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), transactionCacheTimeout)
+	defer cancel()
+	c.invalidateTotals(ctx)
+}
+
+// (helper for Invalidate) totals are keyed by generation, so bumping it retires them all at once.
+func (c *TransactionCache) invalidateTotals(ctx context.Context) {
+	if _, err := c.Store.Incr(ctx, transactionCacheKey+"count:gen"); err != nil {
+		c.failed("incr", transactionCacheKey+"count:gen", err)
+	}
+}
+
+// (helper for List) the total for f, from the cache when the current generation has it.
+func (c *TransactionCache) count(ctx context.Context, repo TransactionRepository, f TransactionFilter) (int64, error) {
+	gen := "0"
+	if raw, err := c.Store.Get(ctx, transactionCacheKey+"count:gen"); err == nil {
+		gen = string(raw)
+	} else if !errors.Is(err, cache.ErrMiss) {
+		c.failed("get", transactionCacheKey+"count:gen", err)
+		return repo.Count(f)
+	}
+	key := transactionCacheKey + "count:" + gen + ":" + url.Values{
+		"merchant": {f.MerchantID}, "user": {f.UserID}, "acting": {f.ActingUserID}, "status": {f.Status}, "channel": {f.Channel},
+		"not_status": {f.NotStatus}, "not_channel": {f.NotChannel}, "has_user": {cacheBool(f.HasUser)},
+		"q": {f.Search}, "min_amount": {cacheInt(f.MinAmount)}, "max_amount": {cacheInt(f.MaxAmount)},
+		"from": {cacheTime(f.From)}, "to": {cacheTime(f.To)},
+	}.Encode()
+	if raw, err := c.Store.Get(ctx, key); err == nil {
+		if n, err := strconv.ParseInt(string(raw), 10, 64); err == nil {
+			transactionCacheMetrics.Add("hits", 1)
+			return n, nil
+		}
+	} else if !errors.Is(err, cache.ErrMiss) {
+		c.failed("get", key, err)
+	}
+	transactionCacheMetrics.Add("misses", 1)
+
+	n, err := repo.Count(f)
+	if err != nil {
+		return 0, err
+	}
+	if err := c.Store.Set(ctx, key, []byte(strconv.FormatInt(n, 10)), c.TTL); err != nil {
+		c.failed("set", key, err)
+	}
+	return n, nil
+}
+
+// (helper for TransactionCache) count and log a cache failure.
+func (c *TransactionCache) failed(op, key string, err error) {
+	transactionCacheMetrics.Add("errors", 1)
+	log.Printf("transaction cache: %s %s failed: %v", op, key, err)
+}
+
+// (helper for count) t as a cache key part; the zero time (no bound) is empty.
+func cacheTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// (helper for count) b as a cache key part; nil (not filtered) is empty.
+func cacheBool(b *bool) string {
+	if b == nil {
+		return ""
+	}
+	return strconv.FormatBool(*b)
+}
+
+// (helper for count) n as a cache key part; nil (not filtered) is empty.
+func cacheInt(n *int64) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.FormatInt(*n, 10)
+}
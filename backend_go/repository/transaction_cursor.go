@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+)
+
+// ErrInvalidCursor is returned by ParseTransactionCursor for a cursor it did not encode.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// TransactionCursor is a position in the (created_at DESC, id DESC) order of List; PageAfter returns
+// the rows after it. Unlike an offset it stays on the same row while new transactions are inserted,
+// and the keyset lookup costs the same at any depth.
+type TransactionCursor struct {
+	CreatedAt time.Time
+	ID        uint
+}
+
+// CursorAfter is the cursor of the page that follows t.
+func CursorAfter(t models.Transaction) TransactionCursor {
+	return TransactionCursor{CreatedAt: t.CreatedAt, ID: t.ID}
+}
+
+// String encodes the cursor for clients, who should treat it as opaque.
+func (c TransactionCursor) String() string {
+	raw := strconv.FormatInt(c.CreatedAt.UnixNano(), 10) + "." + strconv.FormatUint(uint64(c.ID), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseTransactionCursor decodes a TransactionCursor.String.
+func ParseTransactionCursor(s string) (TransactionCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return TransactionCursor{}, ErrInvalidCursor
+	}
+	ts, id, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return TransactionCursor{}, ErrInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return TransactionCursor{}, ErrInvalidCursor
+	}
+	n, err := strconv.ParseUint(id, 10, 0)
+	if err != nil {
+		return TransactionCursor{}, ErrInvalidCursor
+	}
+	return TransactionCursor{CreatedAt: time.Unix(0, nanos), ID: uint(n)}, nil
+}
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/gorm"
+)
+
+// rollupZone is where the days of the rollups (and of Stats periods) start.
+var rollupZone = time.FixedZone("ICT", 7*60*60)
+
+// Intervals and groupings of Stats.
+var (
+	StatsIntervals = []string{"day", "week", "month"}
+	StatsGroupings = []string{"channel", "status"}
+)
+
+// TransactionStats is one bucket of Stats: the transactions of a currency in the period starting at
+// Period (Bangkok day, ISO week or month; nil when not grouped by interval) with the grouping
+// column's value Key (empty when not grouped).
+type TransactionStats struct {
+	Period                 *time.Time `json:"period,omitempty"`
+	Key                    string     `json:"key,omitempty"`
+	Currency               string     `json:"currency"`
+	Count                  int64      `json:"count"`
+	AmountSatang           int64      `json:"amount_satang"`
+	Successful             int64      `json:"successful"`
+	SuccessfulAmountSatang int64      `json:"successful_amount_satang"`
+	SuccessRate            float64    `json:"success_rate"` // Successful / Count
+}
+
+// Stats aggregates one row per day, merchant, channel, status and currency: read from
+// transaction_daily_rollups for the whole days of [From, To) that are rolled up, and grouped from
+// transactions for the rest (the edges of the range, today, days not rolled up yet). Only filters on
+// the rollup columns can use the rollups; any other filter aggregates every row live.
+func (r *pgTransactions) Stats(f TransactionFilter, interval, groupBy string) ([]TransactionStats, error) {
+	// interval and groupBy are whitelisted, so they can be spliced into the SQL.
+	cols, group := []string{"currency"}, []string{"currency"}
+	if interval != "" {
+		if !slices.Contains(StatsIntervals, interval) {
+			return nil, fmt.Errorf("unknown stats interval %q", interval)
+		}
+		period := "date_trunc('" + interval + "', day::timestamp) AT TIME ZONE 'Asia/Bangkok'"
+		cols, group = append(cols, period+" AS period"), append(group, period)
+	}
+	if groupBy != "" {
+		if !slices.Contains(StatsGroupings, groupBy) {
+			return nil, fmt.Errorf("unknown stats grouping %q", groupBy)
+		}
+		cols, group = append(cols, groupBy+" AS key"), append(group, groupBy)
+	}
+	cols = append(cols,
+		"SUM(n)::bigint AS count",
+		"SUM(amount_satang)::bigint AS amount_satang",
+		"COALESCE(SUM(n) FILTER (WHERE status = 'successful'), 0)::bigint AS successful",
+		"COALESCE(SUM(amount_satang) FILTER (WHERE status = 'successful'), 0)::bigint AS successful_amount_satang",
+		"ROUND(COALESCE(SUM(n) FILTER (WHERE status = 'successful'), 0) / SUM(n), 4) AS success_rate",
+	)
+	f.Order = TransactionOrder{}
+
+	var out []TransactionStats
+	err := dbutil.Retry("transaction_stats", func() error {
+		days, err := r.rolledUpDays(f)
+		if err != nil {
+			return err
+		}
+		out = nil
+		return dbutil.Replica(r.db).Table("(?) AS s", r.statsRows(f, days)).
+			Select(strings.Join(cols, ", ")).Group(strings.Join(group, ", ")).
+			Order(strings.Join(slices.Concat(group[1:], group[:1]), ", ")).
+			Scan(&out).Error
+	})
+	return out, err
+}
+
+// (helper for Stats) the days of [f.From, f.To) to read from the rollups: whole Bangkok days before
+// today that are rolled up. None when f filters on anything the rollups do not keep.
+func (r *pgTransactions) rolledUpDays(f TransactionFilter) ([]time.Time, error) {
+	if f.From.IsZero() || f.To.IsZero() || f != rollupFilter(f) {
+		return nil, nil
+	}
+	first := startOfDay(f.From)
+	if first.Before(f.From) {
+		first = first.AddDate(0, 0, 1)
+	}
+	end := startOfDay(f.To)
+	if today := startOfDay(time.Now()); today.Before(end) {
+		end = today
+	}
+	if !first.Before(end) {
+		return nil, nil
+	}
+	var marked []string
+	if err := dbutil.Replica(r.db).Model(&models.TransactionRollupDay{}).
+		Where("day >= ? AND day < ?", first.Format(time.DateOnly), end.Format(time.DateOnly)).
+		Order("day").Pluck("day", &marked).Error; err != nil {
+		return nil, err
+	}
+	days := make([]time.Time, 0, len(marked))
+	for _, d := range marked {
+		if day, err := time.ParseInLocation(time.DateOnly, d, rollupZone); err == nil {
+			days = append(days, day)
+		}
+	}
+	return days, nil
+}
+
+// (helper for Stats) the rows Stats aggregates: day, channel, status, currency, n (transactions) and
+// amount_satang, from the rollups for days (sorted) and from transactions for the rest of the range.
+func (r *pgTransactions) statsRows(f TransactionFilter, days []time.Time) *gorm.DB {
+	const liveRows = "(created_at AT TIME ZONE 'Asia/Bangkok')::date AS day, channel, status, LOWER(currency) AS currency, 1 AS n, amount_satang"
+	if len(days) == 0 {
+		return r.db.Model(&models.Transaction{}).Scopes(filterTransactions(f)).Select(liveRows)
+	}
+
+	// The range minus the rolled-up days, as created_at intervals merged where they touch.
+	var gaps []string
+	var args []interface{}
+	from := f.From
+	for _, day := range append(days, f.To) {
+		if from.Before(day) {
+			gaps, args = append(gaps, "(created_at >= ? AND created_at < ?)"), append(args, from, day)
+		}
+		from = day.AddDate(0, 0, 1)
+	}
+	f.From, f.To = time.Time{}, time.Time{}
+	live := r.db.Model(&models.Transaction{}).Scopes(filterTransactions(f)).Select(liveRows)
+	if len(gaps) > 0 {
+		live = live.Where(strings.Join(gaps, " OR "), args...)
+	} else {
+		live = live.Where("false")
+	}
+
+	names := make([]string, len(days))
+	for i, d := range days {
+		names[i] = d.Format(time.DateOnly)
+	}
+	rolled := r.db.Model(&models.TransactionDailyRollup{}).Scopes(filterTransactions(f)).Where("day IN ?", names).
+		Select("day::date AS day, channel, status, currency, count AS n, amount_satang")
+	return r.db.Raw("(?) UNION ALL (?)", rolled, live)
+}
+
+// rollupFilter keeps the parts of f that the rollups can answer: merchant, status and channel, and
+// the range.
+func rollupFilter(f TransactionFilter) TransactionFilter {
+	return TransactionFilter{
+		MerchantID: f.MerchantID, Status: f.Status, Channel: f.Channel, NotStatus: f.NotStatus, NotChannel: f.NotChannel,
+		From: f.From, To: f.To,
+	}
+}
+
+// (helper for rolledUpDays) the start of t's Bangkok day.
+func startOfDay(t time.Time) time.Time {
+	t = t.In(rollupZone)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, rollupZone)
+}
+
+func (r *pgTransactions) RefreshDailyRollup(day time.Time) (int, error) {
+	day = startOfDay(day)
+	name := day.Format(time.DateOnly)
+	var groups int
+	err := dbutil.Retry("refresh_transaction_rollup", func() error {
+		return r.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("day = ?", name).Delete(&models.TransactionDailyRollup{}).Error; err != nil {
+				return err
+			}
+			res := tx.Exec(`
+				INSERT INTO transaction_daily_rollups (day, merchant_id, channel, status, currency, count, amount_satang)
+				SELECT ?, merchant_id, COALESCE(channel, ''), COALESCE(status, ''), LOWER(COALESCE(currency, '')), COUNT(*), COALESCE(SUM(amount_satang), 0)
+				FROM transactions
+				WHERE created_at >= ? AND created_at < ? AND deleted_at IS NULL
+				GROUP BY 2, 3, 4, 5`, name, day, day.AddDate(0, 0, 1))
+			if res.Error != nil {
+				return res.Error
+			}
+			groups = int(res.RowsAffected)
+			return tx.Save(&models.TransactionRollupDay{Day: name, RefreshedAt: time.Now(), Groups: groups}).Error
+		})
+	})
+	return groups, err
+}
@@ -0,0 +1,428 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TransactionFilter narrows List; empty fields are not filtered.
+type TransactionFilter struct {
+	MerchantID   string
+	UserID       string
+	ActingUserID string
+	// Status and Channel match any of a comma-separated list ("successful,failed"); NotStatus and
+	// NotChannel exclude every value of theirs.
+	Status     string
+	Channel    string
+	NotStatus  string
+	NotChannel string
+	// RiskDecision matches the risk assessment of the charge (risk.Allow, Flag or Deny), e.g. "flag" for
+	// the charges awaiting review.
+	RiskDecision string
+	// HasUser keeps only transactions with (true) or without (false) a user_id, e.g. orphaned charges.
+	HasUser *bool
+	// Search is full-text search (Postgres websearch syntax) over the description, failure message and
+	// metadata values, e.g. an order reference or a student's email.
+	Search string
+	// MinAmount and MaxAmount bound amount_satang, both inclusive.
+	MinAmount *int64
+	MaxAmount *int64
+	// From and To bound created_at to [From, To).
+	From time.Time
+	To   time.Time
+	// Order sorts List and Page; it does not narrow them. PageAfter always pages newest first.
+	Order TransactionOrder
+}
+
+// TransactionOrder is a sort of List: Column descending unless Asc, then id in the same direction.
+// The zero value is newest first.
+type TransactionOrder struct {
+	Column string // one of TransactionSortColumns; empty is created_at
+	Asc    bool
+}
+
+// TransactionSortColumns are the columns List can sort by.
+var TransactionSortColumns = []string{"created_at", "amount_satang", "status"}
+
+// ParseTransactionOrder builds the order of ?sort=&order=; empty values are the default. The error
+// names the accepted values.
+func ParseTransactionOrder(sort, order string) (TransactionOrder, error) {
+	o := TransactionOrder{Column: sort}
+	if sort != "" && !slices.Contains(TransactionSortColumns, sort) {
+		return o, fmt.Errorf("sort must be one of %s", strings.Join(TransactionSortColumns, ", "))
+	}
+	switch order {
+	case "", "desc":
+	case "asc":
+		o.Asc = true
+	default:
+		return o, errors.New("order must be asc or desc")
+	}
+	return o, nil
+}
+
+// IsDefault reports whether o is newest first, the order PageAfter pages in.
+func (o TransactionOrder) IsDefault() bool {
+	return (o.Column == "" || o.Column == "created_at") && !o.Asc
+}
+
+// (helper for Page) the ORDER BY of o; Column is whitelisted by ParseTransactionOrder, and anything
+// else falls back to created_at.
+func (o TransactionOrder) clause() string {
+	col := "created_at"
+	if slices.Contains(TransactionSortColumns, o.Column) {
+		col = o.Column
+	}
+	dir := " DESC"
+	if o.Asc {
+		dir = " ASC"
+	}
+	return col + dir + ", id" + dir
+}
+
+// TransactionRepository reads and writes payment transactions.
+type TransactionRepository interface {
+	// List returns one page of transactions, newest first, and the total matching f.
+	List(f TransactionFilter, limit, offset int) ([]models.Transaction, int64, error)
+	// List, Page and Count read from the replica when one is configured (see dbutil.Replica).
+	// Page is List without the total.
+	Page(f TransactionFilter, limit, offset int) ([]models.Transaction, error)
+	// PageAfter is Page by keyset: the rows after the cursor (from the start when after is nil).
+	PageAfter(f TransactionFilter, after *TransactionCursor, limit int) ([]models.Transaction, error)
+	// Count is List's total alone.
+	Count(f TransactionFilter) (int64, error)
+	// Find looks up by internal id if id is numeric, else (or if not found) by charge id.
+	Find(id string) (*models.Transaction, error)
+	// FindMany is Find for several ids at once: the transactions whose internal id or charge id is
+	// among ids, in no particular order; ids that match nothing are left out.
+	FindMany(ids []string) ([]models.Transaction, error)
+	// Get looks up by internal id.
+	Get(id uint) (*models.Transaction, error)
+	// LockByChargeID locks chargeID for the rest of the DB transaction and reads its row FOR UPDATE;
+	// ErrNotFound when there is none yet. The lock (dbutil.LockKey) is taken either way, so concurrent
+	// writers of a charge that has no row yet queue rather than both inserting and crediting it.
+	// Outside WithTx it fails with dbutil.ErrNotInTransaction.
+	LockByChargeID(chargeID string) (*models.Transaction, error)
+	// UpsertByChargeID inserts t or updates the row with the same charge id; t.ID is set either way.
+	// The table is partitioned by created_at, so the row keeps the created_at its charge id was first
+	// recorded with (models.TransactionChargeID), whatever t.CreatedAt is now; t.CreatedAt is set to
+	// it. Call it within WithTx so the charge id is not registered without its row.
+	UpsertByChargeID(t *models.Transaction) error
+	// UpdateStatus saves t's status, failure code and failure message.
+	UpdateStatus(t *models.Transaction) error
+	// RecordEvent appends e to its transaction's status history; e.ID is set.
+	RecordEvent(e *models.TransactionEvent) error
+	// LatestEventSource returns the source of the latest entry in the status history of transaction
+	// id; ErrNotFound when it has none.
+	LatestEventSource(id uint) (string, error)
+	// Stats aggregates the transactions matching f (Order is ignored), see TransactionStats. Reads
+	// from the replica, and from the daily rollups for the days they cover when f allows.
+	Stats(f TransactionFilter, interval, groupBy string) ([]TransactionStats, error)
+	// RefreshDailyRollup recomputes the rollups of the Bangkok day starting at day and marks it as
+	// rolled up; the number of rollup rows.
+	RefreshDailyRollup(day time.Time) (int, error)
+	// CountAutoReloadsSince counts auto-reload charges created for the user since the given time.
+	CountAutoReloadsSince(userID uint, since time.Time) (int64, error)
+	// LatestChargeLike returns the user's newest pending or successful charge of amount and currency
+	// (lower case, as Omise sends it) created since since; ErrNotFound when there is none.
+	LatestChargeLike(userID uint, amount int64, currency string, since time.Time) (*models.Transaction, error)
+	// ChargeVelocity sums the user's charges created since since, per currency (see ChargeVolume).
+	ChargeVelocity(userID uint, since, recent time.Time) ([]ChargeVolume, error)
+
+	// WithTx returns a repository bound to the DB transaction tx.
+	WithTx(tx *gorm.DB) TransactionRepository
+	// WithContext returns a repository whose queries are cancelled with ctx.
+	WithContext(ctx context.Context) TransactionRepository
+}
+
+// NewTransactionRepository returns the Postgres TransactionRepository.
+func NewTransactionRepository(db *gorm.DB) TransactionRepository {
+	return &pgTransactions{db: db}
+}
+
+type pgTransactions struct {
+	db *gorm.DB
+}
+
+func (r *pgTransactions) WithTx(tx *gorm.DB) TransactionRepository {
+	return &pgTransactions{db: tx}
+}
+
+func (r *pgTransactions) WithContext(ctx context.Context) TransactionRepository {
+	return &pgTransactions{db: r.db.WithContext(ctx)}
+}
+
+func (r *pgTransactions) List(f TransactionFilter, limit, offset int) ([]models.Transaction, int64, error) {
+	total, err := r.Count(f)
+	if err != nil {
+		return nil, 0, err
+	}
+	out, err := r.Page(f, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return out, total, nil
+}
+
+func (r *pgTransactions) Count(f TransactionFilter) (int64, error) {
+	var total int64
+	err := dbutil.Retry("count_transactions", func() error {
+		return dbutil.Replica(r.db).Model(&models.Transaction{}).Scopes(filterTransactions(f)).Count(&total).Error
+	})
+	return total, err
+}
+
+func (r *pgTransactions) Page(f TransactionFilter, limit, offset int) ([]models.Transaction, error) {
+	// User is not serialized (json:"-"), so no Preload.
+	var out []models.Transaction
+	err := dbutil.Retry("list_transactions", func() error {
+		return dbutil.Replica(r.db).Model(&models.Transaction{}).Scopes(filterTransactions(f), OmitPayload).
+			Order(f.Order.clause()).
+			Limit(limit).Offset(offset).
+			Find(&out).Error
+	})
+	return out, err
+}
+
+func (r *pgTransactions) PageAfter(f TransactionFilter, after *TransactionCursor, limit int) ([]models.Transaction, error) {
+	var out []models.Transaction
+	err := dbutil.Retry("list_transactions", func() error {
+		q := dbutil.Replica(r.db).Model(&models.Transaction{}).Scopes(filterTransactions(f), OmitPayload)
+		if after != nil {
+			q = q.Where("(created_at, id) < (?, ?)", after.CreatedAt, after.ID)
+		}
+		return q.Order("created_at DESC, id DESC").Limit(limit).Find(&out).Error
+	})
+	return out, err
+}
+
+// OmitPayload is a GORM scope selecting every transaction column but raw_payload, which can be
+// megabytes a row and is only served by the admin raw payload endpoint (it is json:"-"). Listings and
+// batch reads use it; Find, Get and LockByChargeID still load the payload.
+func OmitPayload(db *gorm.DB) *gorm.DB {
+	return db.Omit("raw_payload")
+}
+
+// (helper for List) GORM scope for the optional filters.
+func filterTransactions(f TransactionFilter) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if f.MerchantID != "" {
+			db = db.Where("merchant_id = ?", f.MerchantID)
+		}
+		if f.UserID != "" {
+			db = db.Where("user_id = ?", f.UserID)
+		}
+		if f.ActingUserID != "" {
+			db = db.Where("acting_user_id = ?", f.ActingUserID)
+		}
+		if f.Status != "" {
+			db = db.Where("status IN ?", splitValues(f.Status))
+		}
+		if f.Channel != "" {
+			db = db.Where("channel IN ?", splitValues(f.Channel))
+		}
+		if f.NotStatus != "" {
+			db = db.Where("status NOT IN ?", splitValues(f.NotStatus))
+		}
+		if f.NotChannel != "" {
+			db = db.Where("channel NOT IN ?", splitValues(f.NotChannel))
+		}
+		if f.RiskDecision != "" {
+			db = db.Where("risk_decision = ?", f.RiskDecision)
+		}
+		if f.HasUser != nil {
+			if *f.HasUser {
+				db = db.Where("user_id IS NOT NULL")
+			} else {
+				db = db.Where("user_id IS NULL")
+			}
+		}
+		if f.Search != "" {
+			db = db.Where("search @@ websearch_to_tsquery('simple', ?)", f.Search)
+		}
+		if f.MinAmount != nil {
+			db = db.Where("amount_satang >= ?", *f.MinAmount)
+		}
+		if f.MaxAmount != nil {
+			db = db.Where("amount_satang <= ?", *f.MaxAmount)
+		}
+		if !f.From.IsZero() {
+			db = db.Where("created_at >= ?", f.From)
+		}
+		if !f.To.IsZero() {
+			db = db.Where("created_at < ?", f.To)
+		}
+		return db
+	}
+}
+
+// (helper for filterTransactions) the values of a comma-separated filter, without blanks.
+func splitValues(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func (r *pgTransactions) Find(id string) (*models.Transaction, error) {
+	var found *models.Transaction
+	err := dbutil.Retry("find_transaction", func() (err error) {
+		found, err = r.lookup(id)
+		return err
+	})
+	return found, err
+}
+
+func (r *pgTransactions) FindMany(ids []string) ([]models.Transaction, error) {
+	var internal []uint64
+	for _, id := range ids {
+		if n, err := strconv.ParseUint(id, 10, 64); err == nil {
+			internal = append(internal, n)
+		}
+	}
+	var out []models.Transaction
+	err := dbutil.Retry("find_transactions", func() error {
+		out = nil
+		q := r.db.Session(&gorm.Session{}).Scopes(OmitPayload).Where("charge_id IN ?", ids)
+		if len(internal) > 0 {
+			q = q.Or("id IN ?", internal)
+		}
+		return q.Find(&out).Error
+	})
+	return out, err
+}
+
+func (r *pgTransactions) lookup(id string) (*models.Transaction, error) {
+	var t models.Transaction
+	if n, err := strconv.ParseUint(id, 10, 64); err == nil {
+		err = r.db.Session(&gorm.Session{}).First(&t, uint(n)).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if err == nil {
+			return &t, nil
+		}
+	}
+
+	// Fallback to ChargeID lookup
+	if err := r.db.Session(&gorm.Session{}).Where("charge_id = ?", id).First(&t).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *pgTransactions) Get(id uint) (*models.Transaction, error) {
+	var t models.Transaction
+	if err := dbutil.Retry("get_transaction", func() error {
+		return r.db.First(&t, id).Error
+	}); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *pgTransactions) LockByChargeID(chargeID string) (*models.Transaction, error) {
+	if err := dbutil.LockKey(r.db, "charge:"+chargeID); err != nil {
+		return nil, err
+	}
+	var t models.Transaction
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("charge_id = ?", chargeID).Take(&t).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *pgTransactions) UpsertByChargeID(t *models.Transaction) error {
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = time.Now()
+	}
+	// The no-op update locks the charge id's row and returns its created_at when it already exists.
+	err := r.db.Raw(`INSERT INTO transaction_charge_ids (charge_id, created_at) VALUES (?, ?)
+		ON CONFLICT (charge_id) DO UPDATE SET charge_id = EXCLUDED.charge_id RETURNING created_at`,
+		t.ChargeID, t.CreatedAt).Row().Scan(&t.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "charge_id"}, {Name: "created_at"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"status", "description", "failure_code", "failure_message", "expires_at",
+			"amount_satang", "currency", "channel", "card_brand", "card_last_digits", "bank",
+			"raw_payload", "meta", "updated_at", "user_id", "acting_user_id", "order_id", "payment_link_id",
+			"coupon_id", "discount_satang", "risk_decision", "risk_score", "risk_reasons",
+		}),
+	}).Create(t).Error
+}
+
+func (r *pgTransactions) UpdateStatus(t *models.Transaction) error {
+	return r.db.Model(t).Updates(map[string]interface{}{
+		"status": t.Status, "failure_code": t.FailureCode, "failure_message": t.FailureMessage,
+	}).Error
+}
+
+func (r *pgTransactions) RecordEvent(e *models.TransactionEvent) error {
+	return r.db.Create(e).Error
+}
+
+func (r *pgTransactions) LatestEventSource(id uint) (string, error) {
+	var e models.TransactionEvent
+	if err := r.db.Select("source").Where("transaction_id = ?", id).
+		Order("created_at DESC, id DESC").Take(&e).Error; err != nil {
+		return "", err
+	}
+	return e.Source, nil
+}
+
+func (r *pgTransactions) LatestChargeLike(userID uint, amount int64, currency string, since time.Time) (*models.Transaction, error) {
+	var t models.Transaction
+	err := r.db.Scopes(OmitPayload).Where("user_id = ? AND amount_satang = ? AND currency = ? AND status IN ? AND created_at >= ?",
+		userID, amount, currency, []string{"pending", "successful"}, since).
+		Order("created_at DESC, id DESC").Take(&t).Error
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ChargeVolume is ChargeVelocity's sum of a user's charges in one currency: every charge created
+// since since, whatever became of it; those created since recent; the failed ones; and the amount of
+// the ones pending or successful.
+type ChargeVolume struct {
+	Currency     string
+	Charges      int64
+	Recent       int64
+	Failed       int64
+	AmountSatang int64
+}
+
+func (r *pgTransactions) ChargeVelocity(userID uint, since, recent time.Time) ([]ChargeVolume, error) {
+	var out []ChargeVolume
+	err := r.db.Model(&models.Transaction{}).
+		Select("LOWER(currency) AS currency, COUNT(*) AS charges, COUNT(*) FILTER (WHERE created_at >= ?) AS recent, "+
+			"COUNT(*) FILTER (WHERE status = 'failed') AS failed, "+
+			"COALESCE(SUM(amount_satang) FILTER (WHERE status IN ?), 0) AS amount_satang", recent, []string{"pending", "successful"}).
+		Where("user_id = ? AND created_at >= ?", userID, since).
+		Group("1").Scan(&out).Error
+	return out, err
+}
+
+func (r *pgTransactions) CountAutoReloadsSince(userID uint, since time.Time) (int64, error) {
+	var n int64
+	err := r.db.Model(&models.Transaction{}).
+		Where("user_id = ? AND meta->>'auto_reload' = ? AND created_at >= ?", userID, "true", since).
+		Count(&n).Error
+	return n, err
+}
@@ -0,0 +1,95 @@
+package repository_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/migrations"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// (helper) the Postgres database at TEST_DATABASE_URL, migrated up; skips the test without one. Tests
+// share it, so each uses charge ids of its own (testChargeID).
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("set TEST_DATABASE_URL to a scratch Postgres database to run the schema tests")
+	}
+	if err := migrations.Up(dsn); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func testChargeID(t *testing.T) string {
+	return fmt.Sprintf("chrg_test_%s_%d", t.Name(), time.Now().UnixNano())
+}
+
+func TestUpsertByChargeIDKeepsOneRowAcrossPartitions(t *testing.T) {
+	db := testDB(t)
+	repo := repository.NewTransactionRepository(db)
+	chargeID := testChargeID(t)
+	january := time.Date(2026, time.January, 10, 12, 0, 0, 0, time.UTC)
+	march := time.Date(2026, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+	upsert := func(status string, createdAt time.Time) models.Transaction {
+		t.Helper()
+		row := models.Transaction{ChargeID: chargeID, Status: status, AmountSatang: 5000, Currency: "thb", CreatedAt: createdAt}
+		err := dbutil.Transaction(db, "test_upsert", func(tx *gorm.DB) error {
+			return repo.WithTx(tx).UpsertByChargeID(&row)
+		})
+		if err != nil {
+			t.Fatalf("UpsertByChargeID(%s, %s): %v", status, createdAt, err)
+		}
+		return row
+	}
+	first := upsert("pending", january)
+	second := upsert("successful", march)
+	if second.ID != first.ID || !second.CreatedAt.Equal(january) {
+		t.Errorf("second upsert = id %d created %s, want the first row (id %d, created %s)", second.ID, second.CreatedAt, first.ID, january)
+	}
+
+	var rows []models.Transaction
+	if err := db.Where("charge_id = ?", chargeID).Find(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Status != "successful" || !rows[0].CreatedAt.Equal(january) {
+		t.Fatalf("rows of %s = %+v, want one successful row created in January", chargeID, rows)
+	}
+}
+
+func TestDisputeCasesReferenceTheirTransaction(t *testing.T) {
+	db := testDB(t)
+	row := models.Transaction{ChargeID: testChargeID(t), Status: "successful", AmountSatang: 5000, Currency: "thb"}
+	err := dbutil.Transaction(db, "test_upsert", func(tx *gorm.DB) error {
+		return repository.NewTransactionRepository(tx).UpsertByChargeID(&row)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dispute := func(createdAt time.Time) error {
+		return db.Create(&models.DisputeCase{
+			TransactionID: row.ID, TransactionCreatedAt: createdAt, UserID: 1,
+			Reason: "not_received", Status: models.DisputeCaseOpen,
+		}).Error
+	}
+	if err := dispute(row.CreatedAt.Add(-time.Hour)); err == nil {
+		t.Error("dispute of a transaction id with another created_at was accepted")
+	}
+	if err := dispute(row.CreatedAt); err != nil {
+		t.Errorf("dispute of the transaction: %v", err)
+	}
+}
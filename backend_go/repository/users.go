@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UserRepository reads users and moves money between a user's balance columns. Amounts are THB
+// (major units), matching the numeric columns. Guarded changes never make a column negative.
+type UserRepository interface {
+	// Get returns the user with its balance columns.
+	Get(id uint) (*models.User, error)
+	// GetForUpdate is Get with a row lock; only meaningful inside WithTx.
+	GetForUpdate(id uint) (*models.User, error)
+
+	// Credit adds to the balance.
+	Credit(id uint, thb float64) error
+	// CreditCharge is Credit for the successful charge chargeID, recorded as its models.ChargeCredit:
+	// false, crediting nothing, when the charge was credited before. Use it within WithTx so the
+	// record and the credit are one change. ErrNotFound, recording nothing, when the user does not
+	// exist.
+	CreditCharge(id uint, chargeID string, thb float64) (bool, error)
+	// Debit subtracts from the balance; ErrInsufficientBalance if it is short.
+	Debit(id uint, thb float64) error
+	// Hold moves balance into held_balance; ErrInsufficientBalance if the balance is short.
+	Hold(id uint, thb float64) error
+	// ReleaseHold moves held_balance back to the balance.
+	ReleaseHold(id uint, thb float64) error
+	// CaptureHold spends held_balance.
+	CaptureHold(id uint, thb float64) error
+	// Freeze moves balance into frozen_balance while a dispute is open.
+	Freeze(id uint, thb float64) error
+	// Unfreeze takes thb out of frozen_balance, back into the balance when restore is true.
+	Unfreeze(id uint, thb float64, restore bool) error
+
+	// WithTx returns a repository bound to the DB transaction tx.
+	WithTx(tx *gorm.DB) UserRepository
+	// WithContext returns a repository whose queries are cancelled with ctx.
+	WithContext(ctx context.Context) UserRepository
+}
+
+// NewUserRepository returns the Postgres UserRepository.
+func NewUserRepository(db *gorm.DB) UserRepository {
+	return &pgUsers{db: db}
+}
+
+type pgUsers struct {
+	db *gorm.DB
+}
+
+func (r *pgUsers) WithTx(tx *gorm.DB) UserRepository {
+	return &pgUsers{db: tx}
+}
+
+func (r *pgUsers) WithContext(ctx context.Context) UserRepository {
+	return &pgUsers{db: r.db.WithContext(ctx)}
+}
+
+func (r *pgUsers) Get(id uint) (*models.User, error) {
+	var u models.User
+	if err := r.db.Select("id", "balance", "frozen_balance", "held_balance").First(&u, id).Error; err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r *pgUsers) GetForUpdate(id uint) (*models.User, error) {
+	var u models.User
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&u, id).Error; err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r *pgUsers) Credit(id uint, thb float64) error {
+	return r.update(id, "", 0, map[string]interface{}{"balance": gorm.Expr("balance + ?", thb)})
+}
+
+func (r *pgUsers) CreditCharge(id uint, chargeID string, thb float64) (bool, error) {
+	res := r.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.ChargeCredit{ChargeID: chargeID, UserID: id, CreditedTHB: thb})
+	if res.Error != nil || res.RowsAffected == 0 {
+		return false, res.Error
+	}
+	if err := r.Credit(id, thb); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			if err := r.db.Delete(&models.ChargeCredit{}, "charge_id = ?", chargeID).Error; err != nil {
+				return false, err
+			}
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *pgUsers) Debit(id uint, thb float64) error {
+	return r.update(id, "balance", thb, map[string]interface{}{"balance": gorm.Expr("balance - ?", thb)})
+}
+
+func (r *pgUsers) Hold(id uint, thb float64) error {
+	return r.update(id, "balance", thb, map[string]interface{}{
+		"balance":      gorm.Expr("balance - ?", thb),
+		"held_balance": gorm.Expr("held_balance + ?", thb),
+	})
+}
+
+func (r *pgUsers) ReleaseHold(id uint, thb float64) error {
+	return r.update(id, "", 0, map[string]interface{}{
+		"balance":      gorm.Expr("balance + ?", thb),
+		"held_balance": gorm.Expr("held_balance - ?", thb),
+	})
+}
+
+func (r *pgUsers) CaptureHold(id uint, thb float64) error {
+	return r.update(id, "", 0, map[string]interface{}{"held_balance": gorm.Expr("held_balance - ?", thb)})
+}
+
+func (r *pgUsers) Freeze(id uint, thb float64) error {
+	return r.update(id, "", 0, map[string]interface{}{
+		"balance":        gorm.Expr("balance - ?", thb),
+		"frozen_balance": gorm.Expr("frozen_balance + ?", thb),
+	})
+}
+
+func (r *pgUsers) Unfreeze(id uint, thb float64, restore bool) error {
+	updates := map[string]interface{}{"frozen_balance": gorm.Expr("frozen_balance - ?", thb)}
+	if restore {
+		updates["balance"] = gorm.Expr("balance + ?", thb)
+	}
+	return r.update(id, "", 0, updates)
+}
+
+// (helper) apply updates to one user; with guard set, only when guard >= atLeast. No matching row is
+// ErrInsufficientBalance when the user exists and ErrNotFound otherwise.
+func (r *pgUsers) update(id uint, guard string, atLeast float64, updates map[string]interface{}) error {
+	q := r.db.Model(&models.User{}).Where("id = ?", id)
+	if guard != "" {
+		q = q.Where(guard+" >= ?", atLeast)
+	}
+	res := q.Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+		return nil
+	}
+	if guard != "" {
+		var u models.User
+		if err := r.db.Select("id").First(&u, id).Error; err != nil {
+			return err
+		}
+		return ErrInsufficientBalance
+	}
+	return ErrNotFound
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WalletOperationRepository stores the applied wallet operations, keyed by their client-supplied
+// operation ids (see models.WalletOperation).
+type WalletOperationRepository interface {
+	// Claim inserts op, claiming its operation id; false, without inserting, when the id is already
+	// claimed. Inside WithTx a concurrent claim of the same id waits until this transaction ends.
+	Claim(op *models.WalletOperation) (bool, error)
+	// Get returns the operation of operationID.
+	Get(operationID string) (*models.WalletOperation, error)
+	// LockHold reads the hold of operationID FOR UPDATE; only meaningful inside WithTx.
+	LockHold(operationID string) (*models.WalletOperation, error)
+	// Save updates op's hold status and balances.
+	Save(op *models.WalletOperation) error
+
+	// WithTx returns a repository bound to the DB transaction tx.
+	WithTx(tx *gorm.DB) WalletOperationRepository
+	// WithContext returns a repository whose queries are cancelled with ctx.
+	WithContext(ctx context.Context) WalletOperationRepository
+}
+
+// NewWalletOperationRepository returns the Postgres WalletOperationRepository.
+func NewWalletOperationRepository(db *gorm.DB) WalletOperationRepository {
+	return &pgWalletOperations{db: db}
+}
+
+type pgWalletOperations struct {
+	db *gorm.DB
+}
+
+func (r *pgWalletOperations) WithTx(tx *gorm.DB) WalletOperationRepository {
+	return &pgWalletOperations{db: tx}
+}
+
+func (r *pgWalletOperations) WithContext(ctx context.Context) WalletOperationRepository {
+	return &pgWalletOperations{db: r.db.WithContext(ctx)}
+}
+
+func (r *pgWalletOperations) Claim(op *models.WalletOperation) (bool, error) {
+	if err := r.db.Create(op).Error; err != nil {
+		if dbutil.IsUniqueViolation(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *pgWalletOperations) Get(operationID string) (*models.WalletOperation, error) {
+	var op models.WalletOperation
+	if err := r.db.Where("operation_id = ?", operationID).First(&op).Error; err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
+
+func (r *pgWalletOperations) LockHold(operationID string) (*models.WalletOperation, error) {
+	var op models.WalletOperation
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("operation_id = ? AND kind = ?", operationID, models.WalletOpHold).First(&op).Error; err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
+
+func (r *pgWalletOperations) Save(op *models.WalletOperation) error {
+	return r.db.Model(op).Select("hold_status", "balance", "held_balance", "updated_at").Updates(op).Error
+}
@@ -0,0 +1,120 @@
+// Package risk scores a charge before it is created, so a payment that looks like card testing or a
+// stolen card can be sent through 3-D Secure or refused. The service calls an Evaluator with what it
+// knows of the payer (service.CreateCharge) and records the assessment on the transaction.
+package risk
+
+import (
+	"context"
+	"fmt"
+)
+
+// Decisions.
+const (
+	Allow = "allow" // charge as usual
+	Flag  = "flag"  // charge, but cards must pass 3-D Secure; kept for review
+	Deny  = "deny"  // refuse the charge
+)
+
+// Input is a charge about to be created and its payer's recent history.
+type Input struct {
+	UserID      *uint  // nil for a charge without a user
+	Amount      int64  // minor units of Currency
+	Currency    string // lowercase ISO 4217
+	AmountTHB   int64  // Amount in THB satang; Amount when there is no rate to THB
+	PaymentType string // "credit_card", "promptpay" or "internet_banking"
+	IP          string // the payer's address; empty when unknown (e.g. service calls)
+
+	// The user's charges, whatever became of them, and declined ones; zero without a user.
+	ChargesLastHour int64
+	ChargesLastDay  int64
+	FailuresLastDay int64
+}
+
+// Assessment is an Evaluator's verdict on a charge.
+type Assessment struct {
+	Decision  string   `json:"decision"`
+	Score     int      `json:"score"`             // 0-100, higher is riskier
+	Reasons   []string `json:"reasons,omitempty"` // what raised the score, e.g. "recent_failures"
+	Evaluator string   `json:"evaluator"`
+}
+
+// Evaluator assesses charges before they are created. An error lets the charge through unassessed,
+// so a broken evaluator never stops payments.
+type Evaluator interface {
+	Name() string
+	Evaluate(ctx context.Context, in Input) (Assessment, error)
+}
+
+// New returns the Evaluator for a name (RISK_EVALUATOR): "rules" scores charges with DefaultRules.
+// An empty name disables risk scoring.
+func New(name string) (Evaluator, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case "rules":
+		return DefaultRules, nil
+	default:
+		return nil, fmt.Errorf("unknown risk evaluator %q", name)
+	}
+}
+
+// Rules scores a charge by adding fixed points for each sign of abuse; a score of FlagAt or more
+// flags the charge and DenyAt or more denies it.
+type Rules struct {
+	FlagAt, DenyAt int
+
+	// FailurePoints per declined charge in the last day, up to MaxFailurePoints.
+	FailurePoints, MaxFailurePoints int
+	// BurstPoints once a user has HourlyCharges charges in the last hour.
+	HourlyCharges int64
+	BurstPoints   int
+	// LargeAmountPoints for a charge of LargeAmountTHB satang or more.
+	LargeAmountTHB    int64
+	LargeAmountPoints int
+	// NoUserPoints for a charge no user is known for.
+	NoUserPoints int
+}
+
+// DefaultRules flags a large charge from a user declined today, or a burst of charges after a couple
+// of declines, and denies what looks like card testing: many declines in quick succession.
+var DefaultRules = Rules{
+	FlagAt: 40, DenyAt: 80,
+	FailurePoints: 15, MaxFailurePoints: 60,
+	HourlyCharges: 3, BurstPoints: 25,
+	LargeAmountTHB: 1000000, LargeAmountPoints: 30, // 10,000 THB
+	NoUserPoints: 10,
+}
+
+func (Rules) Name() string { return "rules" }
+
+func (r Rules) Evaluate(_ context.Context, in Input) (Assessment, error) {
+	a := Assessment{Evaluator: r.Name()}
+	add := func(points int, reason string) {
+		if points > 0 {
+			a.Score += points
+			a.Reasons = append(a.Reasons, reason)
+		}
+	}
+	if in.FailuresLastDay > 0 {
+		add(min(int(in.FailuresLastDay)*r.FailurePoints, r.MaxFailurePoints), "recent_failures")
+	}
+	if r.HourlyCharges > 0 && in.ChargesLastHour >= r.HourlyCharges {
+		add(r.BurstPoints, "charge_burst")
+	}
+	if r.LargeAmountTHB > 0 && in.AmountTHB >= r.LargeAmountTHB {
+		add(r.LargeAmountPoints, "large_amount")
+	}
+	if in.UserID == nil {
+		add(r.NoUserPoints, "no_user")
+	}
+	a.Score = min(a.Score, 100)
+	switch {
+	case a.Score >= r.DenyAt:
+		a.Decision = Deny
+	case a.Score >= r.FlagAt:
+		a.Decision = Flag
+	default:
+		a.Decision = Allow
+	}
+	return a, nil
+}
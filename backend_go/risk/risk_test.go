@@ -0,0 +1,48 @@
+package risk
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestDefaultRules(t *testing.T) {
+	uid := uint(7)
+	cases := []struct {
+		name    string
+		in      Input
+		want    string
+		score   int
+		reasons []string
+	}{
+		{"first charge", Input{UserID: &uid, AmountTHB: 50000}, Allow, 0, nil},
+		{"a decline", Input{UserID: &uid, AmountTHB: 50000, FailuresLastDay: 1}, Allow, 15, []string{"recent_failures"}},
+		{"large after declines", Input{UserID: &uid, AmountTHB: 1500000, FailuresLastDay: 1}, Flag, 45, []string{"recent_failures", "large_amount"}},
+		{"burst after declines", Input{UserID: &uid, AmountTHB: 2000, ChargesLastHour: 3, FailuresLastDay: 2}, Flag, 55, []string{"recent_failures", "charge_burst"}},
+		{"card testing", Input{UserID: &uid, AmountTHB: 2000, ChargesLastHour: 8, FailuresLastDay: 8}, Deny, 85, []string{"recent_failures", "charge_burst"}},
+		{"large without a user", Input{AmountTHB: 1000000}, Flag, 40, []string{"large_amount", "no_user"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			a, err := DefaultRules.Evaluate(context.Background(), tc.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a.Decision != tc.want || a.Score != tc.score || !slices.Equal(a.Reasons, tc.reasons) {
+				t.Errorf("assessment = %+v, want %s %d %v", a, tc.want, tc.score, tc.reasons)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	if e, err := New(""); e != nil || err != nil {
+		t.Errorf(`New("") = %v, %v; want disabled`, e, err)
+	}
+	if e, err := New("rules"); err != nil || e.Name() != "rules" {
+		t.Errorf(`New("rules") = %v, %v`, e, err)
+	}
+	if _, err := New("ml"); err == nil {
+		t.Error(`New("ml") succeeded, want an error`)
+	}
+}
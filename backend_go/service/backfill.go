@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/gateway"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	omise "github.com/omise/omise-go"
+	"github.com/omise/omise-go/operations"
+)
+
+// Results of one charge in a ChargeBackfill.
+const (
+	BackfillCreated   = "created"   // no transaction had it; recorded (or would be, on a dry run)
+	BackfillUpdated   = "updated"   // its transaction had another status; recorded
+	BackfillUnchanged = "unchanged" // its transaction already matches
+	BackfillFailed    = "failed"    // recording it failed; see Error
+)
+
+// backfillItemsCap caps the charges listed in a ChargeBackfill; the counts are always complete.
+const backfillItemsCap = 500
+
+// BackfilledCharge is one charge a backfill created or updated a transaction for.
+type BackfilledCharge struct {
+	MerchantID uint   `json:"merchant_id"`
+	ChargeID   string `json:"charge_id"`
+	Status     string `json:"status"`
+	Result     string `json:"result"`
+	UserID     *uint  `json:"user_id,omitempty"`
+	Credited   bool   `json:"credited,omitempty"` // the charge credits UserID's wallet
+	Error      string `json:"error,omitempty"`
+}
+
+// ChargeBackfill is the result of BackfillCharges. Charges lists the created, updated and failed ones,
+// up to backfillItemsCap.
+type ChargeBackfill struct {
+	DryRun       bool               `json:"dry_run"`
+	OmiseCharges int                `json:"omise_charges"`
+	Created      int                `json:"created"`
+	Updated      int                `json:"updated"`
+	Unchanged    int                `json:"unchanged"`
+	Failed       int                `json:"failed"`
+	Credited     int                `json:"credited"`
+	Charges      []BackfilledCharge `json:"charges"`
+}
+
+// BackfillCharges pages through every charge Omise created in [from, to) and records the ones the
+// transactions table is missing or has with another status, through RecordCharge: successful charges
+// carrying a user_id credit that user's wallet as their webhook would have. It populates the table
+// with charges made before this service existed. Recorded charges are sourced as an import and send
+// no receipts. With dryRun nothing is written and the result reports what would be. Each merchant's
+// account (Merchants) is backfilled in turn; a failed charge is reported and the rest carry on.
+func (s *PaymentService) BackfillCharges(ctx context.Context, from, to time.Time, dryRun bool) (*ChargeBackfill, error) {
+	merchants := s.Merchants
+	if len(merchants) == 0 {
+		merchants = []uint{models.DefaultMerchantID}
+	}
+	ctx = WithEventSource(ctx, models.TransactionEventImport)
+	result := &ChargeBackfill{DryRun: dryRun, Charges: []BackfilledCharge{}}
+	for _, id := range merchants {
+		if err := s.backfillMerchant(gateway.WithMerchant(ctx, id), id, from, to, dryRun, result); err != nil {
+			return result, fmt.Errorf("merchant %d: %w", id, err)
+		}
+	}
+	return result, nil
+}
+
+// (helper for BackfillCharges) backfill one merchant's account, a page of charges at a time.
+func (s *PaymentService) backfillMerchant(ctx context.Context, merchant uint, from, to time.Time, dryRun bool, result *ChargeBackfill) error {
+	for offset := 0; ; offset += omiseListPage {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		page, err := s.Omise.ListCharges(ctx, &operations.ListCharges{List: operations.List{
+			Offset: offset, Limit: omiseListPage, From: from, To: to, Order: omise.Chronological,
+		}})
+		if err != nil {
+			return fmt.Errorf("list charges at offset %d: %w", offset, err)
+		}
+		var charges []*omise.Charge
+		ids := make([]string, 0, len(page.Data))
+		for _, ch := range page.Data {
+			if ch.CreatedAt.Before(from) || !ch.CreatedAt.Before(to) { // Omise's "to" is inclusive
+				continue
+			}
+			charges = append(charges, ch)
+			ids = append(ids, ch.ID)
+		}
+		var found []models.Transaction
+		if len(ids) > 0 {
+			if err := s.DB.WithContext(ctx).Scopes(repository.OmitPayload).Select("id", "charge_id", "status", "amount_satang", "currency").
+				Where("charge_id IN ?", ids).Find(&found).Error; err != nil {
+				return err
+			}
+		}
+		existing := make(map[string]*models.Transaction, len(found))
+		for i := range found {
+			existing[found[i].ChargeID] = &found[i]
+		}
+
+		for _, ch := range charges {
+			result.OmiseCharges++
+			item := BackfilledCharge{MerchantID: merchant, ChargeID: ch.ID, Status: string(ch.Status), UserID: extractUserIDFromCharge(ch, nil)}
+			item.Result, item.Credited = s.backfillOutcome(existing[ch.ID], ch)
+			if item.Result == BackfillUnchanged {
+				result.Unchanged++
+				continue
+			}
+			if !dryRun {
+				if err := s.RecordCharge(ctx, ch, nil); err != nil {
+					item.Result, item.Credited, item.Error = BackfillFailed, false, err.Error()
+				}
+			}
+			switch item.Result {
+			case BackfillCreated:
+				result.Created++
+			case BackfillUpdated:
+				result.Updated++
+			case BackfillFailed:
+				result.Failed++
+			}
+			if item.Credited {
+				result.Credited++
+			}
+			if len(result.Charges) < backfillItemsCap {
+				result.Charges = append(result.Charges, item)
+			}
+		}
+		if len(page.Data) == 0 || offset+len(page.Data) >= page.Total {
+			return nil
+		}
+	}
+}
+
+// (helper for BackfillCharges) what recording ch over its transaction t (nil if none) does, and
+// whether it credits a wallet: RecordCharge credits on the move into successful. Amount mismatches
+// are left to ReconcileCharges, which reports them.
+func (s *PaymentService) backfillOutcome(t *models.Transaction, ch *omise.Charge) (string, bool) {
+	result := BackfillCreated
+	if t != nil {
+		if compareCharge(*t, ch) != DiscrepancyStatus {
+			return BackfillUnchanged, false
+		}
+		result = BackfillUpdated
+	}
+	credits := false
+	if ch.Status == omise.ChargeSuccessful && extractUserIDFromCharge(ch, nil) != nil {
+		_, credits = s.walletTHB(ch.Amount, ch.Currency)
+	}
+	return result, credits
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	omise "github.com/omise/omise-go"
+)
+
+func TestBackfillOutcome(t *testing.T) {
+	s := NewPaymentService(nil, nil)
+	charge := func(status omise.ChargeStatus, meta map[string]interface{}) *omise.Charge {
+		return &omise.Charge{Base: omise.Base{ID: "chrg_test_1"}, Status: status, Amount: 10000, Currency: "thb", Metadata: meta}
+	}
+	owned := map[string]interface{}{"user_id": float64(7)}
+	tx := func(status string) *models.Transaction {
+		return &models.Transaction{ChargeID: "chrg_test_1", Status: status, AmountSatang: 10000, Currency: "thb"}
+	}
+	cases := []struct {
+		name        string
+		t           *models.Transaction
+		ch          *omise.Charge
+		wantResult  string
+		wantCredits bool
+	}{
+		{"missing, successful with user", nil, charge(omise.ChargeSuccessful, owned), BackfillCreated, true},
+		{"missing, successful without user", nil, charge(omise.ChargeSuccessful, nil), BackfillCreated, false},
+		{"missing, failed", nil, charge(omise.ChargeFailed, owned), BackfillCreated, false},
+		{"stale pending", tx("pending"), charge(omise.ChargeSuccessful, owned), BackfillUpdated, true},
+		{"already recorded", tx("successful"), charge(omise.ChargeSuccessful, owned), BackfillUnchanged, false},
+		{"expired locally, pending at Omise", tx(StatusExpired), charge(omise.ChargePending, owned), BackfillUnchanged, false},
+	}
+	for _, c := range cases {
+		result, credits := s.backfillOutcome(c.t, c.ch)
+		if result != c.wantResult || credits != c.wantCredits {
+			t.Errorf("%s: got (%s, %t), want (%s, %t)", c.name, result, credits, c.wantResult, c.wantCredits)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrPayerBlocked is returned by CreateCharge and CheckBlocklist when the payer is on the blocklist
+// (models.BlocklistEntry). It does not say which entry matched.
+var ErrPayerBlocked = errors.New("payer is blocked")
+
+// Payer is who a charge would come from, as checked against the blocklist; empty fields are not
+// checked. The emails of a user also include the notify_email of their auto-reload setting.
+type Payer struct {
+	UserID    *uint
+	Email     string
+	CardToken string // its card's fingerprint is checked
+}
+
+// CheckBlocklist returns ErrPayerBlocked when p's user, email or card is blocked. The card token is
+// only read from Omise while some card fingerprint is blocked, so the common case costs one query.
+// A service without a database (tests of the Omise path) has no blocklist.
+func (s *PaymentService) CheckBlocklist(ctx context.Context, p Payer) error {
+	if s.DB == nil {
+		return nil
+	}
+	blocklist := s.Blocklist.WithContext(ctx)
+	if err := blocklistErr(blocklist.BlocksPayer(p.UserID, NormalizeEmail(p.Email))); err != nil {
+		return err
+	}
+	if p.CardToken == "" {
+		return nil
+	}
+	if cards, err := blocklist.BlocksCard(""); err != nil || !cards {
+		return blocklistErr(false, err)
+	}
+	token, err := s.Omise.RetrieveToken(ctx, p.CardToken)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve token: %w", err)
+	}
+	if token.Card == nil || token.Card.Fingerprint == "" {
+		return nil
+	}
+	return blocklistErr(blocklist.BlocksCard(token.Card.Fingerprint))
+}
+
+// NormalizeEmail is an email address as blocklist entries store it: trimmed and lower case.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// (helper for CheckBlocklist) ErrPayerBlocked for a match.
+func blocklistErr(blocked bool, err error) error {
+	if err != nil {
+		return fmt.Errorf("blocklist: %w", err)
+	}
+	if blocked {
+		return ErrPayerBlocked
+	}
+	return nil
+}
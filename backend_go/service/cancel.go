@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/a2n2k3p4/tutorium-backend/gateway"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	omise "github.com/omise/omise-go"
+)
+
+// CancelCharge cancels the pending charge of t for a payer who changed their mind: Omise expires it, so
+// its PromptPay QR or bank page can no longer be paid, and t becomes canceled. A charge Omise cannot
+// expire (e.g. a card charge awaiting 3-D Secure) is canceled here only; should it still succeed,
+// RecordCharge credits it as usual. It returns t as saved.
+//
+// Errors: *InputError charge_not_pending when t is not pending, also when Omise settled the charge
+// meanwhile (which is then recorded); *omise.Error 5xx and transport errors from Omise.
+func (s *PaymentService) CancelCharge(ctx context.Context, t *models.Transaction) (*models.Transaction, error) {
+	if t.Status != string(omise.ChargePending) {
+		return nil, invalidInput("charge_not_pending", "only pending charges can be canceled, charge %s is %s", t.ChargeID, t.Status)
+	}
+	mctx := gateway.WithMerchant(ctx, t.MerchantID)
+	ch, err := s.Omise.ExpireCharge(mctx, t.ChargeID)
+	var oerr *omise.Error
+	switch {
+	case errors.As(err, &oerr) && oerr.StatusCode < 500:
+		log.Printf("cancel: charge=%s not expired at Omise, canceled locally only: %v", t.ChargeID, err)
+		if ch, err = s.Omise.RetrieveCharge(mctx, t.ChargeID); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	}
+	if ch.Status != omise.ChargePending && string(ch.Status) != StatusExpired {
+		// Paid (or failed) before the cancel reached Omise.
+		if err := s.RecordCharge(mctx, ch, nil); err != nil {
+			return nil, err
+		}
+		return nil, invalidInput("charge_not_pending", "charge %s is already %s", t.ChargeID, ch.Status)
+	}
+	canceled, err := s.closePending(ctx, t.ChargeID, StatusCanceled, FailureCodeCanceled, "payment was canceled by the payer")
+	if err != nil {
+		return nil, err
+	}
+	saved, err := s.Transactions.WithContext(ctx).Find(t.ChargeID)
+	if err != nil {
+		return nil, err
+	}
+	if !canceled {
+		// A webhook settled it between Omise's answer and here.
+		return nil, invalidInput("charge_not_pending", "charge %s is already %s", t.ChargeID, saved.Status)
+	}
+	return saved, nil
+}
@@ -0,0 +1,313 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/gateway"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/money"
+	"github.com/a2n2k3p4/tutorium-backend/provider"
+	omise "github.com/omise/omise-go"
+	"github.com/omise/omise-go/operations"
+)
+
+// recordTimeout bounds recording a charge Omise has already created, after the request's own deadline.
+const recordTimeout = 10 * time.Second
+
+// reservedMetadataKeys are the charge metadata only the service sets: RecordCharge links the
+// transaction to what it pays by them. CreateCharge drops them from the client's metadata, as
+// assessRisk drops riskMetadataKeys.
+var reservedMetadataKeys = []string{"order_id", "payment_link_id", "payment_intent_id", "coupon_id", "discount_satang", "acting_user_id"}
+
+// CreateCharge creates the charge on Omise and records it locally. req must already satisfy its
+// validate tags; userID is the owner resolved by the caller (body, header, CLI flag) and is stored on
+// the transaction, while req.UserID is what gets attached to the Omise charge metadata.
+//
+// Errors: *InputError for requests Omise never saw, ErrCountryNotAllowed for a payer in a country
+// Countries refuses, ErrPayerBlocked for a payer on the blocklist (see CheckBlocklist), *LimitError
+// for a charge over the Velocity limits, *DuplicateChargeError for a repeat of a recent charge without
+// req.Force, ErrRiskDenied for a charge the Risk evaluator denies, *omise.Error for Omise rejections,
+// anything else is a transport failure. A failure to record the charge locally is logged, not
+// returned: the charge exists on Omise and the webhook will record it.
+func (s *PaymentService) CreateCharge(ctx context.Context, req models.PaymentRequest, userID *uint) (*omise.Charge, error) {
+	for _, key := range reservedMetadataKeys {
+		delete(req.Metadata, key)
+	}
+	if req.Card != nil && !s.AllowRawCard {
+		return nil, invalidInput("raw_card_disabled", "raw card data is not accepted; tokenize the card on the client (Omise.js / mobile SDK) and send token")
+	}
+	if req.CouponCode != "" && req.OrderID == nil {
+		return nil, invalidInput("coupon_requires_order", "coupon_code applies to an order; send order_id")
+	}
+	if req.OrderID != nil && req.PaymentLinkID != nil {
+		return nil, invalidInput("invalid_charge_request", "a charge pays an order or a payment link, not both")
+	}
+	if req.OrderID != nil {
+		if err := s.applyOrder(ctx, &req, userID); err != nil {
+			return nil, err
+		}
+	}
+	if req.PaymentLinkID != nil {
+		if err := s.applyPaymentLink(ctx, &req, userID); err != nil {
+			return nil, err
+		}
+	}
+	if (req.PaymentType == "promptpay" || req.PaymentType == "internet_banking") && !strings.EqualFold(req.Currency, money.THB) {
+		return nil, invalidInput("currency_not_supported", "%s takes THB only; pay %s by card", req.PaymentType, strings.ToUpper(req.Currency))
+	}
+	if _, ok := s.walletTHB(0, req.Currency); !ok && userID != nil {
+		return nil, invalidInput("currency_not_supported", "%s charges cannot be credited to a wallet: no exchange rate to THB is configured", strings.ToUpper(req.Currency))
+	}
+	if req.ReturnURI != "" {
+		if err := s.validateReturnURI(req.ReturnURI); err != nil {
+			return nil, invalidInput("return_uri_not_allowed", "%s", err.Error())
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := s.checkCountry(&req); err != nil {
+		return nil, err
+	}
+	// Raw cards (sandbox only) have no token yet, so only tokenized cards are checked.
+	payer := Payer{UserID: userID, CardToken: req.Token}
+	if payer.UserID == nil {
+		payer.UserID = req.UserID
+	}
+	payer.Email, _ = req.Metadata["email"].(string) // where the receipt goes
+	if err := s.CheckBlocklist(ctx, payer); err != nil {
+		return nil, err
+	}
+	if err := s.checkVelocity(ctx, payer.UserID, req.Amount, req.Currency); err != nil {
+		return nil, err
+	}
+	if !req.Force {
+		if err := s.checkDuplicate(ctx, payer.UserID, req.Amount, req.Currency); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.assessRisk(ctx, &req, payer.UserID); err != nil {
+		return nil, err
+	}
+	if err := s.checkSecureCard(req); err != nil {
+		return nil, err
+	}
+
+	var (
+		charge *omise.Charge
+		err    error
+	)
+	switch req.PaymentType {
+	case "credit_card":
+		charge, err = s.processCreditCard(ctx, req)
+	case "promptpay":
+		charge, err = s.processPromptPay(ctx, req)
+	case "internet_banking":
+		charge, err = s.processInternetBanking(ctx, req)
+	default:
+		return nil, invalidInput("unsupported_payment_type", "unsupported paymentType: %s", req.PaymentType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Persist/Upsert a local transaction row (idempotent on charge_id). The charge exists on Omise
+	// now, so the caller's deadline no longer applies; recordTimeout bounds the write instead.
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordTimeout)
+	defer cancel()
+	if err := s.RecordCharge(recordCtx, charge, userID); err != nil {
+		log.Printf("Failed to save transaction: %v", err) // do not fail outward
+	}
+	return charge, nil
+}
+
+// RefundInput is a refund of AmountSatang of an Omise charge; Metadata is attached to the refund.
+// MerchantID is the account the charge is on (Transaction.MerchantID); 0 uses ctx's merchant.
+type RefundInput struct {
+	MerchantID   uint
+	ChargeID     string
+	AmountSatang int64
+	Metadata     map[string]interface{}
+}
+
+// Refund refunds (part of) a charge with the Provider. Recording it (balances, audit) is up to the caller,
+// which usually does so in the same DB transaction as the change that motivated the refund.
+func (s *PaymentService) Refund(ctx context.Context, in RefundInput) (*provider.Refund, error) {
+	if in.ChargeID == "" {
+		return nil, invalidInput("invalid_refund_request", "charge id is required")
+	}
+	if in.AmountSatang <= 0 {
+		return nil, invalidInput("invalid_refund_request", "refund amount must be positive, got %d", in.AmountSatang)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if in.MerchantID != 0 {
+		ctx = gateway.WithMerchant(ctx, in.MerchantID)
+	}
+	return s.Provider.Refund(ctx, provider.RefundRequest{
+		ChargeID:     in.ChargeID,
+		AmountSatang: in.AmountSatang,
+		Metadata:     in.Metadata,
+	})
+}
+
+// ---------------------- processors ----------------------
+
+func badChargeRequest(format string, args ...interface{}) error {
+	return invalidInput("invalid_charge_request", format, args...)
+}
+
+// (helper for processors) req.Metadata plus user_id, which the webhook uses to credit the right wallet,
+// order_id, payment_link_id and payment_intent_id, which link the recorded transaction to what it
+// pays, and acting_user_id.
+func chargeMetadata(req models.PaymentRequest) map[string]interface{} {
+	metadata := req.Metadata
+	ids := map[string]*uint{"user_id": req.UserID, "order_id": req.OrderID, "payment_link_id": req.PaymentLinkID, "payment_intent_id": req.PaymentIntentID, "acting_user_id": req.ActingUserID}
+	for key, id := range ids {
+		if id == nil {
+			continue
+		}
+		if metadata == nil {
+			metadata = make(map[string]interface{})
+		}
+		metadata[key] = fmt.Sprintf("%d", *id)
+	}
+	return metadata
+}
+
+func (s *PaymentService) processCreditCard(ctx context.Context, req models.PaymentRequest) (*omise.Charge, error) {
+	metadata := chargeMetadata(req)
+
+	// Preferred flow: card token already created by frontend (Omise.js / mobile SDK).
+	if req.Token != "" {
+		return s.createCharge(ctx, req, provider.ChargeRequest{Method: provider.MethodCard, CardToken: req.Token, Metadata: metadata, Require3DS: s.requires3DS(req)})
+	}
+
+	// Server-side tokenization (testing only, gated by ALLOW_RAW_CARD); Omise only
+	if req.Card == nil {
+		return nil, badChargeRequest("missing token; either provide token or card for tokenization")
+	}
+	if !s.AllowRawCard {
+		return nil, badChargeRequest("raw card tokenization is disabled (set ALLOW_RAW_CARD=true in sandbox only)")
+	}
+	name, _ := req.Card["name"].(string)
+	number, _ := req.Card["number"].(string)
+
+	var expMonth, expYear int
+	var securityCode string
+
+	switch v := req.Card["expiration_month"].(type) {
+	case float64:
+		expMonth = int(v)
+	case string:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, badChargeRequest("invalid expiration_month: %v", v)
+		}
+		expMonth = n
+	default:
+		return nil, badChargeRequest("unexpected type for expiration_month: %T", v)
+	}
+	switch v := req.Card["expiration_year"].(type) {
+	case float64:
+		expYear = int(v)
+	case string:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, badChargeRequest("invalid expiration_year: %v", v)
+		}
+		expYear = n
+	default:
+		return nil, badChargeRequest("unexpected type for expiration_year: %T", v)
+	}
+	switch v := req.Card["security_code"].(type) {
+	case string:
+		securityCode = v
+	case float64:
+		securityCode = strconv.Itoa(int(v))
+	default:
+		return nil, badChargeRequest("unexpected type for security_code: %T", v)
+	}
+
+	token, err := s.Omise.CreateToken(ctx, &operations.CreateToken{
+		Name:            name,
+		Number:          number,
+		ExpirationMonth: time.Month(expMonth),
+		ExpirationYear:  expYear,
+		SecurityCode:    securityCode,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token: %w", err)
+	}
+
+	return s.createCharge(ctx, req, provider.ChargeRequest{Method: provider.MethodCard, CardToken: token.ID, Metadata: metadata, Require3DS: s.requires3DS(req)})
+}
+
+func (s *PaymentService) processPromptPay(ctx context.Context, req models.PaymentRequest) (*omise.Charge, error) {
+	req.ReturnURI = "" // the payer scans the QR; there is no redirect
+	return s.createCharge(ctx, req, provider.ChargeRequest{Method: provider.MethodPromptPay, Metadata: chargeMetadata(req)})
+}
+
+func (s *PaymentService) processInternetBanking(ctx context.Context, req models.PaymentRequest) (*omise.Charge, error) {
+	// Internet banking requires a source like "internet_banking_bbl", "internet_banking_scb", etc.
+	if req.Bank == "" {
+		return nil, badChargeRequest(`bank is required for internet_banking (e.g. "bay", "bbl", "scb")`)
+	}
+	if req.ReturnURI == "" {
+		return nil, badChargeRequest("return_uri is required for internet_banking")
+	}
+
+	return s.createCharge(ctx, req, provider.ChargeRequest{Method: provider.MethodInternetBanking, Bank: req.Bank, Metadata: chargeMetadata(req)})
+}
+
+// (helper for processors) create the charge with the Provider; amount, currency, return URI and
+// description come from req.
+func (s *PaymentService) createCharge(ctx context.Context, req models.PaymentRequest, cr provider.ChargeRequest) (*omise.Charge, error) {
+	cr.AmountSatang, cr.Currency, cr.ReturnURI, cr.Description = req.Amount, req.Currency, req.ReturnURI, req.Description
+	cr.IP = req.ClientIP
+	charge, err := s.Provider.CreateCharge(ctx, cr)
+	if err != nil {
+		return nil, err
+	}
+	return provider.OmiseCharge(charge)
+}
+
+// validateReturnURI checks a redirect target (3DS / internet banking / mobile banking) against
+// ReturnURIAllowlist so authorized users cannot be sent to arbitrary (phishing) sites.
+// Entries match the host exactly; "*.example.com" also matches any subdomain.
+func (s *PaymentService) validateReturnURI(raw string) error {
+	if len(s.ReturnURIAllowlist) == 0 {
+		return nil // allowlist not configured (development)
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("return_uri must be an absolute URL")
+	}
+	host := strings.ToLower(u.Hostname())
+	if u.Scheme != "https" && !(u.Scheme == "http" && (host == "localhost" || host == "127.0.0.1")) {
+		return fmt.Errorf("return_uri must use https")
+	}
+	if u.User != nil {
+		return fmt.Errorf("return_uri must not contain credentials")
+	}
+	for _, allowed := range s.ReturnURIAllowlist {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if suffix, ok := strings.CutPrefix(allowed, "*."); ok {
+			if host == suffix || strings.HasSuffix(host, "."+suffix) {
+				return nil
+			}
+			continue
+		}
+		if host == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("return_uri host %q is not allowed", host)
+}
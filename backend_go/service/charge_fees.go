@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/gateway"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+)
+
+// SyncChargeFees reads from Omise the fee, fee VAT, net amount and transferable time of every
+// successful transaction that does not have them yet, oldest first, a batch at a time. A charge Omise
+// cannot be asked about now is logged and left for the next run. It returns how many transactions got
+// their fees.
+func (s *PaymentService) SyncChargeFees(ctx context.Context) (int, error) {
+	synced := 0
+	var after *models.Transaction // last row of the previous batch
+	for {
+		q := s.DB.WithContext(ctx).Select("id", "merchant_id", "charge_id", "created_at").
+			Where("status = ? AND fees_synced_at IS NULL", "successful")
+		if after != nil {
+			q = q.Where("(created_at, id) > (?, ?)", after.CreatedAt, after.ID)
+		}
+		var unsynced []models.Transaction
+		if err := q.Order("created_at, id").Limit(maintenanceBatch).Find(&unsynced).Error; err != nil {
+			return synced, err
+		}
+		for i := range unsynced {
+			t := &unsynced[i]
+			if ctx.Err() != nil {
+				return synced, ctx.Err()
+			}
+			mctx := gateway.WithMerchant(ctx, t.MerchantID)
+			fees, err := s.Omise.RetrieveChargeFees(mctx, t.ChargeID)
+			if err != nil {
+				log.Printf("charge fees: retrieve charge=%s failed err=%v", t.ChargeID, err)
+				continue
+			}
+			updates := map[string]interface{}{"fee_satang": fees.Fee, "fee_vat_satang": fees.FeeVAT,
+				"net_satang": fees.Net, "fees_synced_at": time.Now()}
+			if fees.Transaction != "" {
+				// The balance transaction says when the net can be transferred (see MatchTransfers).
+				trx, err := s.Omise.RetrieveTransaction(mctx, fees.Transaction)
+				if err != nil {
+					log.Printf("charge fees: retrieve transaction=%s charge=%s failed err=%v", fees.Transaction, t.ChargeID, err)
+					continue
+				}
+				updates["transferable_at"] = trx.Transferable
+			}
+			if err := s.DB.WithContext(ctx).Model(&models.Transaction{}).Where("id = ?", t.ID).Updates(updates).Error; err != nil {
+				return synced, err
+			}
+			synced++
+		}
+		if len(unsynced) < maintenanceBatch {
+			return synced, nil
+		}
+		after = &unsynced[len(unsynced)-1]
+	}
+}
@@ -0,0 +1,502 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/gateway"
+	"github.com/a2n2k3p4/tutorium-backend/gateway/gatewaytest"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"github.com/a2n2k3p4/tutorium-backend/risk"
+	omise "github.com/omise/omise-go"
+	"github.com/omise/omise-go/operations"
+)
+
+func TestProcessPromptPay(t *testing.T) {
+	for name, gw := range map[string]func(t *testing.T) gateway.OmiseGateway{
+		"fake": func(t *testing.T) gateway.OmiseGateway { return gatewaytest.NewFake() },
+		"stub": func(t *testing.T) gateway.OmiseGateway { return gatewaytest.NewServer(t).Gateway(t) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := NewPaymentService(nil, gw(t))
+			uid := uint(7)
+			ch, err := s.processPromptPay(context.Background(), models.PaymentRequest{Amount: 50000, Currency: "thb", PaymentType: "promptpay", UserID: &uid})
+			if err != nil {
+				t.Fatalf("processPromptPay: %v", err)
+			}
+			if ch.Status != omise.ChargePending || ch.Source == nil || ch.Source.Type != "promptpay" {
+				t.Errorf("charge = status %s source %+v, want pending promptpay", ch.Status, ch.Source)
+			}
+			if ch.Source.ScannableCode == nil || ch.Source.ScannableCode.Image == nil {
+				t.Errorf("promptpay charge has no QR image")
+			}
+			if ch.Metadata["user_id"] != "7" {
+				t.Errorf("metadata user_id = %v, want 7", ch.Metadata["user_id"])
+			}
+		})
+	}
+}
+
+func TestCreateChargeRejectsInputBeforeCallingOmise(t *testing.T) {
+	cases := []struct {
+		name     string
+		req      models.PaymentRequest
+		wantCode string
+	}{
+		{"raw card", models.PaymentRequest{Amount: 50000, Currency: "thb", PaymentType: "credit_card", Card: map[string]interface{}{"number": "4242424242424242"}}, "raw_card_disabled"},
+		{"return uri", models.PaymentRequest{Amount: 50000, Currency: "thb", PaymentType: "credit_card", Token: "tokn_test_1", ReturnURI: "https://evil.example/cb"}, "return_uri_not_allowed"},
+		{"payment type", models.PaymentRequest{Amount: 50000, Currency: "thb", PaymentType: "cash"}, "unsupported_payment_type"},
+		{"missing bank", models.PaymentRequest{Amount: 50000, Currency: "thb", PaymentType: "internet_banking", ReturnURI: "https://app.tutorium.io/cb"}, "invalid_charge_request"},
+		{"coupon without order", models.PaymentRequest{Amount: 50000, Currency: "thb", PaymentType: "promptpay", CouponCode: "TERM1"}, "coupon_requires_order"},
+		{"order and payment link", models.PaymentRequest{PaymentType: "promptpay", OrderID: new(uint), PaymentLinkID: new(uint)}, "invalid_charge_request"},
+		{"promptpay in usd", models.PaymentRequest{Amount: 1000, Currency: "usd", PaymentType: "promptpay"}, "currency_not_supported"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := gatewaytest.NewFake()
+			s := NewPaymentService(nil, fake)
+			s.ReturnURIAllowlist = []string{"app.tutorium.io"}
+			_, err := s.CreateCharge(context.Background(), tc.req, nil)
+			var inErr *InputError
+			if !errors.As(err, &inErr) || inErr.Code != tc.wantCode {
+				t.Fatalf("err = %v, want InputError %s", err, tc.wantCode)
+			}
+			if calls := fake.Calls(); len(calls) != 0 {
+				t.Errorf("Omise called for rejected input: %v", calls)
+			}
+		})
+	}
+}
+
+func TestWalletTHB(t *testing.T) {
+	s := NewPaymentService(nil, gatewaytest.NewFake())
+	s.WalletFXRates = map[string]float64{"usd": 36.5, "jpy": 0.245}
+	cases := []struct {
+		amount   int64
+		currency string
+		want     float64
+		ok       bool
+	}{
+		{50000, "THB", 500, true},
+		{1999, "usd", 729.64, true}, // 19.99 USD, rounded to the satang
+		{1000, "jpy", 245, true},    // 1,000 yen: no minor unit
+		{1000, "sgd", 0, false},
+	}
+	for _, tc := range cases {
+		if got, ok := s.walletTHB(tc.amount, tc.currency); got != tc.want || ok != tc.ok {
+			t.Errorf("walletTHB(%d %s) = %v, %v; want %v, %v", tc.amount, tc.currency, got, ok, tc.want, tc.ok)
+		}
+	}
+
+	uid := uint(7)
+	fake := gatewaytest.NewFake()
+	s.Omise = fake
+	_, err := s.CreateCharge(context.Background(), models.PaymentRequest{Amount: 1000, Currency: "sgd", PaymentType: "credit_card", Token: "tokn_test_1"}, &uid)
+	var inErr *InputError
+	if !errors.As(err, &inErr) || inErr.Code != "currency_not_supported" {
+		t.Fatalf("SGD charge for a wallet without a rate: err = %v, want InputError currency_not_supported", err)
+	}
+	if calls := fake.Calls(); len(calls) != 0 {
+		t.Errorf("Omise called for a charge the wallet cannot take: %v", calls)
+	}
+}
+
+func TestChargeMetadataCarriesOrderID(t *testing.T) {
+	uid, oid := uint(7), uint(42)
+	md := chargeMetadata(models.PaymentRequest{UserID: &uid, OrderID: &oid, Metadata: map[string]interface{}{"note": "x"}})
+	if md["user_id"] != "7" || md["order_id"] != "42" || md["note"] != "x" {
+		t.Errorf("metadata = %v, want user_id 7, order_id 42 and the caller's note", md)
+	}
+	ch := &omise.Charge{Metadata: md}
+	if id := metadataID(ch, "order_id"); id == nil || *id != 42 {
+		t.Errorf("metadataID(order_id) = %v, want 42", id)
+	}
+
+	lid := uint(9)
+	md = chargeMetadata(models.PaymentRequest{UserID: &uid, PaymentLinkID: &lid})
+	if id := metadataID(&omise.Charge{Metadata: md}, "payment_link_id"); id == nil || *id != 9 {
+		t.Errorf("metadataID(payment_link_id) = %v, want 9", id)
+	}
+
+	iid, actor := uint(5), uint(8)
+	md = chargeMetadata(models.PaymentRequest{UserID: &uid, PaymentIntentID: &iid, ActingUserID: &actor})
+	if id := metadataID(&omise.Charge{Metadata: md}, "payment_intent_id"); id == nil || *id != 5 {
+		t.Errorf("metadataID(payment_intent_id) = %v, want 5", id)
+	}
+	if id := metadataID(&omise.Charge{Metadata: md}, "acting_user_id"); id == nil || *id != 8 {
+		t.Errorf("metadataID(acting_user_id) = %v, want 8", id)
+	}
+}
+
+func TestCouponDiscount(t *testing.T) {
+	cases := []struct {
+		coupon models.Coupon
+		total  int64
+		want   int64
+	}{
+		{models.Coupon{Kind: models.CouponPercent, PercentOff: 10}, 150000, 15000},
+		{models.Coupon{Kind: models.CouponPercent, PercentOff: 15}, 3333, 499}, // rounded down
+		{models.Coupon{Kind: models.CouponFixed, AmountOffSatang: 5000}, 150000, 5000},
+		{models.Coupon{Kind: models.CouponFixed, AmountOffSatang: 5000}, 3000, 3000}, // never more than the total
+	}
+	for _, tc := range cases {
+		if got := tc.coupon.Discount(tc.total); got != tc.want {
+			t.Errorf("%s coupon (%d%%, %d) on %d = %d, want %d", tc.coupon.Kind, tc.coupon.PercentOff, tc.coupon.AmountOffSatang, tc.total, got, tc.want)
+		}
+	}
+}
+
+func TestPaymentDetails(t *testing.T) {
+	cases := []struct {
+		name                    string
+		charge                  *omise.Charge
+		brand, last, bank, want string
+	}{
+		{"card", &omise.Charge{Card: &omise.Card{Brand: "Visa", LastDigits: "4242", Bank: "Bangkok Bank"}}, "Visa", "4242", "Bangkok Bank", "VISA •••• 4242"},
+		{"internet banking", &omise.Charge{Source: &omise.Source{Type: "internet_banking_bbl"}}, "", "", "bbl", "internet_banking_bbl"},
+		{"promptpay", &omise.Charge{Source: &omise.Source{Type: "promptpay"}}, "", "", "", "promptpay"},
+	}
+	for _, tc := range cases {
+		brand, last, bank := paymentDetails(tc.charge)
+		if brand != tc.brand || last != tc.last || bank != tc.bank {
+			t.Errorf("%s: paymentDetails = %q, %q, %q; want %q, %q, %q", tc.name, brand, last, bank, tc.brand, tc.last, tc.bank)
+		}
+		tx := models.Transaction{Channel: determineChannel(tc.charge), CardBrand: brand, CardLastDigits: last, Bank: bank}
+		if got := tx.PaymentMethod(); got != tc.want {
+			t.Errorf("%s: PaymentMethod = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRetrieveChargeFees(t *testing.T) {
+	for name, gw := range map[string]func(t *testing.T) gateway.OmiseGateway{
+		"fake": func(t *testing.T) gateway.OmiseGateway { return gatewaytest.NewFake() },
+		"stub": func(t *testing.T) gateway.OmiseGateway { return gatewaytest.NewServer(t).Gateway(t) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := gw(t)
+			s := NewPaymentService(nil, g)
+			ch, err := s.processCreditCard(context.Background(), models.PaymentRequest{Amount: 100000, Currency: "thb", PaymentType: "credit_card", Token: "tokn_test_1"})
+			if err != nil {
+				t.Fatalf("processCreditCard: %v", err)
+			}
+			fees, err := g.RetrieveChargeFees(context.Background(), ch.ID)
+			if err != nil {
+				t.Fatalf("RetrieveChargeFees: %v", err)
+			}
+			if fees.Fee != 3650 || fees.FeeVAT != 255 || fees.Net != 96095 {
+				t.Errorf("fees = %+v, want 3650 + 255 VAT, 96095 net", *fees)
+			}
+			trx, err := g.RetrieveTransaction(context.Background(), fees.Transaction)
+			if err != nil {
+				t.Fatalf("RetrieveTransaction(%q): %v", fees.Transaction, err)
+			}
+			if trx.Source != ch.ID || trx.Amount != fees.Net || trx.Transferable.IsZero() {
+				t.Errorf("transaction = %+v, want a credit of the net of %s with a transferable time", trx, ch.ID)
+			}
+		})
+	}
+}
+
+func TestListTransfers(t *testing.T) {
+	for name, gw := range map[string]func(t *testing.T) (gateway.OmiseGateway, *gatewaytest.Fake){
+		"fake": func(t *testing.T) (gateway.OmiseGateway, *gatewaytest.Fake) {
+			f := gatewaytest.NewFake()
+			return f, f
+		},
+		"stub": func(t *testing.T) (gateway.OmiseGateway, *gatewaytest.Fake) {
+			srv := gatewaytest.NewServer(t)
+			return srv.Gateway(t), srv.Fake
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g, fake := gw(t)
+			first, _ := fake.Transfer(96095, 3000, "thb")
+			second, _ := fake.Transfer(48047, 3000, "thb")
+			list, err := g.ListTransfers(context.Background(), &operations.ListTransfers{List: operations.List{Order: omise.ReverseChronological}})
+			if err != nil {
+				t.Fatalf("ListTransfers: %v", err)
+			}
+			if list.Total != 2 || len(list.Data) != 2 || list.Data[0].ID != second.ID || list.Data[1].ID != first.ID {
+				t.Fatalf("transfers = %+v, want %s then %s", list.Data, second.ID, first.ID)
+			}
+			if tr := list.Data[1]; tr.Amount != 96095 || tr.Fee != 3000 || !tr.Sent || !tr.Paid {
+				t.Errorf("transfer = %+v, want 96095 sent and paid with a 3000 fee", tr)
+			}
+		})
+	}
+}
+
+func TestExpireCharge(t *testing.T) {
+	for name, gw := range map[string]func(t *testing.T) gateway.OmiseGateway{
+		"fake": func(t *testing.T) gateway.OmiseGateway { return gatewaytest.NewFake() },
+		"stub": func(t *testing.T) gateway.OmiseGateway { return gatewaytest.NewServer(t).Gateway(t) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := gw(t)
+			s := NewPaymentService(nil, g)
+			qr, err := s.processPromptPay(context.Background(), models.PaymentRequest{Amount: 50000, Currency: "thb", PaymentType: "promptpay"})
+			if err != nil {
+				t.Fatalf("processPromptPay: %v", err)
+			}
+			ch, err := g.ExpireCharge(context.Background(), qr.ID)
+			if err != nil {
+				t.Fatalf("ExpireCharge: %v", err)
+			}
+			if string(ch.Status) != StatusExpired {
+				t.Errorf("status = %s, want expired", ch.Status)
+			}
+
+			card, err := s.processCreditCard(context.Background(), models.PaymentRequest{Amount: 50000, Currency: "thb", PaymentType: "credit_card", Token: "tokn_test_1"})
+			if err != nil {
+				t.Fatalf("processCreditCard: %v", err)
+			}
+			var oerr *omise.Error
+			if _, err := g.ExpireCharge(context.Background(), card.ID); !errors.As(err, &oerr) || oerr.StatusCode != 400 {
+				t.Errorf("expiring a successful card charge: err = %v, want a 400", err)
+			}
+		})
+	}
+}
+
+func TestCancelChargeOnlyPending(t *testing.T) {
+	fake := gatewaytest.NewFake()
+	s := NewPaymentService(nil, fake)
+	_, err := s.CancelCharge(context.Background(), &models.Transaction{ChargeID: "chrg_test_1", Status: "successful"})
+	var inErr *InputError
+	if !errors.As(err, &inErr) || inErr.Code != "charge_not_pending" {
+		t.Fatalf("err = %v, want InputError charge_not_pending", err)
+	}
+	if calls := fake.Calls(); len(calls) != 0 {
+		t.Errorf("Omise called for a settled charge: %v", calls)
+	}
+}
+
+func TestRetrieveTokenFingerprint(t *testing.T) {
+	srv := gatewaytest.NewServer(t)
+	gw := srv.Gateway(t)
+	ctx := context.Background()
+	var fingerprints []string
+	for _, number := range []string{"4242424242424242", "4242424242424242", "4111111111111111"} {
+		tok, err := gw.CreateToken(ctx, &operations.CreateToken{Name: "A", Number: number, ExpirationMonth: 12, ExpirationYear: 2030, SecurityCode: "123"})
+		if err != nil {
+			t.Fatalf("CreateToken: %v", err)
+		}
+		got, err := gw.RetrieveToken(ctx, tok.ID)
+		if err != nil {
+			t.Fatalf("RetrieveToken: %v", err)
+		}
+		fingerprints = append(fingerprints, got.Card.Fingerprint)
+	}
+	if fingerprints[0] == "" || fingerprints[0] != fingerprints[1] || fingerprints[0] == fingerprints[2] {
+		t.Errorf("fingerprints = %q, want the same for one card and another for the other", fingerprints)
+	}
+}
+
+// velocityTransactions is a TransactionRepository whose only query is ChargeVelocity.
+type velocityTransactions struct {
+	repository.TransactionRepository
+	volumes []repository.ChargeVolume
+}
+
+func (v velocityTransactions) WithContext(context.Context) repository.TransactionRepository { return v }
+
+func (v velocityTransactions) ChargeVelocity(uint, time.Time, time.Time) ([]repository.ChargeVolume, error) {
+	return v.volumes, nil
+}
+
+func TestCheckVelocity(t *testing.T) {
+	s := NewPaymentService(nil, gatewaytest.NewFake())
+	s.WalletFXRates = map[string]float64{"usd": 36.5}
+	s.Velocity = VelocityLimits{MaxChargeSatang: 2000000, MaxPerHour: 3, MaxPerDay: 10, MaxDailyTopUpSatang: 5000000}
+	uid := uint(7)
+	cases := []struct {
+		name     string
+		userID   *uint
+		amount   int64
+		currency string
+		volumes  []repository.ChargeVolume
+		wantCode string
+	}{
+		{"within limits", &uid, 100000, "thb", []repository.ChargeVolume{{Currency: "thb", Charges: 2, Recent: 1, AmountSatang: 200000}}, ""},
+		{"one charge too large", nil, 2000100, "thb", nil, "charge_amount_limit"},
+		{"too large in usd", &uid, 60000, "usd", nil, "charge_amount_limit"}, // 21,900 THB
+		{"an hour of card testing", &uid, 2000, "thb", []repository.ChargeVolume{{Currency: "thb", Charges: 3, Recent: 3}}, "charge_rate_limit"},
+		{"a day of charges", &uid, 2000, "thb", []repository.ChargeVolume{{Currency: "thb", Charges: 6, Recent: 1}, {Currency: "usd", Charges: 4}}, "charge_rate_limit"},
+		{"daily top-up", &uid, 1500000, "thb", []repository.ChargeVolume{{Currency: "thb", Charges: 2, AmountSatang: 3000000}, {Currency: "usd", Charges: 1, AmountSatang: 20000}}, "daily_top_up_limit"},
+		{"no user, no counts", nil, 100000, "thb", []repository.ChargeVolume{{Currency: "thb", Charges: 50, Recent: 50}}, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s.Transactions = velocityTransactions{volumes: tc.volumes}
+			err := s.checkVelocity(context.Background(), tc.userID, tc.amount, tc.currency)
+			var limErr *LimitError
+			switch {
+			case tc.wantCode == "" && err != nil:
+				t.Errorf("err = %v, want none", err)
+			case tc.wantCode != "" && (!errors.As(err, &limErr) || limErr.Code != tc.wantCode):
+				t.Errorf("err = %v, want LimitError %s", err, tc.wantCode)
+			}
+		})
+	}
+}
+
+// duplicateTransactions is a TransactionRepository whose only query is LatestChargeLike.
+type duplicateTransactions struct {
+	repository.TransactionRepository
+	prev *models.Transaction
+}
+
+func (d duplicateTransactions) WithContext(context.Context) repository.TransactionRepository {
+	return d
+}
+
+func (d duplicateTransactions) LatestChargeLike(_ uint, amount int64, currency string, _ time.Time) (*models.Transaction, error) {
+	if d.prev == nil || d.prev.AmountSatang != amount || d.prev.Currency != currency {
+		return nil, repository.ErrNotFound
+	}
+	return d.prev, nil
+}
+
+func TestDuplicateChargeNeedsForce(t *testing.T) {
+	fake := gatewaytest.NewFake()
+	s := NewPaymentService(nil, fake)
+	s.DuplicateWindow = 2 * time.Minute
+	s.Transactions = duplicateTransactions{prev: &models.Transaction{ChargeID: "chrg_test_1", AmountSatang: 50000, Currency: "thb", CreatedAt: time.Now().Add(-20 * time.Second)}}
+	uid := uint(7)
+
+	_, err := s.CreateCharge(context.Background(), models.PaymentRequest{Amount: 50000, Currency: "THB", PaymentType: "promptpay"}, &uid)
+	var dupErr *DuplicateChargeError
+	if !errors.As(err, &dupErr) || dupErr.ChargeID != "chrg_test_1" {
+		t.Fatalf("second 500 THB charge: err = %v, want DuplicateChargeError for chrg_test_1", err)
+	}
+	if calls := fake.Calls(); len(calls) != 0 {
+		t.Errorf("Omise called for a duplicate: %v", calls)
+	}
+	if err := s.checkDuplicate(context.Background(), &uid, 60000, "thb"); err != nil {
+		t.Errorf("another amount: err = %v, want none", err)
+	}
+	if err := s.checkDuplicate(context.Background(), nil, 50000, "thb"); err != nil {
+		t.Errorf("no user: err = %v, want none", err)
+	}
+}
+
+func TestRiskFlaggedCardNeeds3DS(t *testing.T) {
+	fake := gatewaytest.NewFake()
+	s := NewPaymentService(nil, fake)
+	s.Risk = risk.DefaultRules
+	s.Transactions = velocityTransactions{volumes: []repository.ChargeVolume{{Currency: "thb", Charges: 3, Failed: 3}}}
+	uid := uint(7)
+	card := models.PaymentRequest{Amount: 50000, Currency: "thb", PaymentType: "credit_card", Token: "tokn_test_1"}
+
+	_, err := s.CreateCharge(context.Background(), card, &uid)
+	var inErr *InputError
+	if !errors.As(err, &inErr) || inErr.Code != "authentication_required" {
+		t.Fatalf("flagged card without return_uri: err = %v, want authentication_required", err)
+	}
+
+	card.ReturnURI = "https://app.tutorium.io/payments/done"
+	card.Metadata = map[string]interface{}{"risk_decision": risk.Allow}
+	if err := s.assessRisk(context.Background(), &card, &uid); err != nil {
+		t.Fatal(err)
+	}
+	ch, err := s.processCreditCard(context.Background(), card)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ch.Status != omise.ChargePending || ch.AuthorizeURI == "" {
+		t.Errorf("flagged card charge = %s (authorize_uri %q), want pending 3-D Secure", ch.Status, ch.AuthorizeURI)
+	}
+	if ch.Metadata["risk_decision"] != risk.Flag || ch.Metadata["risk_score"] != "45" {
+		t.Errorf("metadata = %v, want the assessment (flag, 45) over the client's", ch.Metadata)
+	}
+	if score := metadataInt(ch, "risk_score"); score != 45 {
+		t.Errorf("recorded risk score = %d, want 45", score)
+	}
+
+	s.Transactions = velocityTransactions{volumes: []repository.ChargeVolume{{Currency: "thb", Charges: 6, Recent: 4, Failed: 6}}}
+	if _, err := s.CreateCharge(context.Background(), card, &uid); !errors.Is(err, ErrRiskDenied) {
+		t.Errorf("card testing: err = %v, want ErrRiskDenied", err)
+	}
+}
+
+func TestSecureCardPolicy(t *testing.T) {
+	fake := gatewaytest.NewFake()
+	s := NewPaymentService(nil, fake)
+	s.WalletFXRates = map[string]float64{"usd": 36.5}
+	s.SecureCards.MinAmountSatang = 1000000 // 10,000 THB
+	cases := []struct {
+		name string
+		req  models.PaymentRequest
+		want bool
+	}{
+		{"small card", models.PaymentRequest{Amount: 50000, Currency: "thb", PaymentType: "credit_card"}, false},
+		{"large card", models.PaymentRequest{Amount: 1000000, Currency: "thb", PaymentType: "credit_card"}, true},
+		{"large in usd", models.PaymentRequest{Amount: 30000, Currency: "usd", PaymentType: "credit_card"}, true}, // 10,950 THB
+		{"large promptpay", models.PaymentRequest{Amount: 1000000, Currency: "thb", PaymentType: "promptpay"}, false},
+		{"flagged", models.PaymentRequest{Amount: 50000, Currency: "thb", PaymentType: "credit_card", Metadata: map[string]interface{}{"risk_decision": risk.Flag}}, true},
+	}
+	for _, tc := range cases {
+		if got := s.requires3DS(tc.req); got != tc.want {
+			t.Errorf("%s: requires3DS = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	large := models.PaymentRequest{Amount: 1500000, Currency: "thb", PaymentType: "credit_card", Token: "tokn_test_1"}
+	_, err := s.CreateCharge(context.Background(), large, nil)
+	var inErr *InputError
+	if !errors.As(err, &inErr) || inErr.Code != "authentication_required" {
+		t.Fatalf("large card without return_uri: err = %v, want authentication_required", err)
+	}
+	large.ReturnURI = "https://app.tutorium.io/payments/done"
+	ch, err := s.processCreditCard(context.Background(), large)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ch.Status != omise.ChargePending || ch.AuthorizeURI == "" {
+		t.Errorf("large card charge = %s (authorize_uri %q), want pending 3-D Secure", ch.Status, ch.AuthorizeURI)
+	}
+}
+
+func TestCountryPolicy(t *testing.T) {
+	for in, want := range map[string]string{"th": "TH", " LA ": "LA", "XX": "", "T1": "T1", "THA": "", "1A": ""} {
+		if got := NormalizeCountry(in); got != want {
+			t.Errorf("NormalizeCountry(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	fake := gatewaytest.NewFake()
+	s := NewPaymentService(nil, fake)
+	s.Countries = CountryPolicy{Allowed: []string{"TH", "LA"}, Denied: []string{"LA"}}
+	promptpay := models.PaymentRequest{Amount: 50000, Currency: "thb", PaymentType: "promptpay", ClientIP: "203.0.113.7"}
+	for country, allowed := range map[string]bool{"th": true, "LA": false, "US": false, "T1": false, "": true} {
+		req := promptpay
+		req.ClientCountry = country
+		req.Metadata = map[string]interface{}{"ip_country": "TH"}
+		err := s.checkCountry(&req)
+		if allowed != (err == nil) {
+			t.Errorf("country %q: err = %v, want allowed %v", country, err, allowed)
+		}
+		if got, _ := req.Metadata["ip_country"].(string); err == nil && got != NormalizeCountry(country) {
+			t.Errorf("country %q: metadata = %v, want the payer's country only", country, req.Metadata)
+		}
+	}
+
+	promptpay.ClientCountry = "US"
+	if _, err := s.CreateCharge(context.Background(), promptpay, nil); !errors.Is(err, ErrCountryNotAllowed) {
+		t.Errorf("charge from US: err = %v, want ErrCountryNotAllowed", err)
+	}
+	if calls := fake.Calls(); len(calls) != 0 {
+		t.Errorf("Omise called for a refused country: %v", calls)
+	}
+	ch, err := s.processPromptPay(context.Background(), promptpay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chargeIP(ch) != "203.0.113.7" {
+		t.Errorf("charge ip = %q, want the payer's", chargeIP(ch))
+	}
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/money"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// (helper for applyOrder) the coupon with code, if userID may apply it to order now, and its discount
+// on the order total. Limits are checked against successful redemptions, so charges still pending
+// with the coupon may take it past MaxRedemptions.
+func (s *PaymentService) couponFor(ctx context.Context, code string, order models.Order, userID *uint) (*models.Coupon, int64, error) {
+	db := s.DB.WithContext(ctx)
+	var c models.Coupon
+	if err := db.Where("code = ?", strings.ToUpper(strings.TrimSpace(code))).Take(&c).Error; err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, 0, invalidInput("coupon_not_found", "coupon %q does not exist", code)
+		}
+		return nil, 0, fmt.Errorf("load coupon: %w", err)
+	}
+	switch {
+	case !c.Active:
+		return nil, 0, invalidInput("coupon_inactive", "coupon %s is no longer active", c.Code)
+	case c.ExpiresAt != nil && !time.Now().Before(*c.ExpiresAt):
+		return nil, 0, invalidInput("coupon_expired", "coupon %s expired at %s", c.Code, c.ExpiresAt.Format(time.RFC3339))
+	case c.Kind == models.CouponFixed && !strings.EqualFold(c.Currency, order.Currency):
+		return nil, 0, invalidInput("coupon_currency_mismatch", "coupon %s is for %s orders", c.Code, strings.ToUpper(c.Currency))
+	case c.MaxRedemptions > 0 && c.Redemptions >= c.MaxRedemptions:
+		return nil, 0, invalidInput("coupon_limit_reached", "coupon %s has been used up", c.Code)
+	}
+	if c.MaxPerUser > 0 && userID != nil {
+		var used int64
+		if err := db.Model(&models.CouponRedemption{}).Where("coupon_id = ? AND user_id = ?", c.ID, *userID).Count(&used).Error; err != nil {
+			return nil, 0, fmt.Errorf("count coupon redemptions: %w", err)
+		}
+		if used >= int64(c.MaxPerUser) {
+			return nil, 0, invalidInput("coupon_limit_reached", "coupon %s has already been used %d time(s) by this user", c.Code, used)
+		}
+	}
+	// A coupon may not discount an order below the smallest charge in its currency.
+	discount := c.Discount(order.TotalSatang)
+	if limits, _ := money.ChargeLimits(order.Currency); order.TotalSatang-discount < limits.Min {
+		return nil, 0, invalidInput("coupon_discount_too_large", "coupon %s would bring order %d below the minimum charge of %s",
+			c.Code, order.ID, money.New(limits.Min, order.Currency))
+	}
+	return &c, discount, nil
+}
+
+// (helper for RecordCharge) count t's coupon as redeemed by t, which just became successful. Limits
+// are not enforced here: the payment has been made.
+func redeemCoupon(tx *gorm.DB, t models.Transaction) error {
+	res := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "transaction_id"}}, DoNothing: true}).
+		Create(&models.CouponRedemption{CouponID: *t.CouponID, UserID: t.UserID, OrderID: t.OrderID, TransactionID: t.ID, DiscountSatang: t.DiscountSatang})
+	if res.Error != nil || res.RowsAffected == 0 {
+		return res.Error
+	}
+	return tx.Model(&models.Coupon{}).Where("id = ?", *t.CouponID).
+		UpdateColumn("redemptions", gorm.Expr("redemptions + 1")).Error
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/money"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+)
+
+// DuplicateChargeError is a charge like one the user made within DuplicateWindow, most likely a double
+// tap on "Pay"; resending it with PaymentRequest.Force charges again. Surfaces map it to "conflict"
+// (HTTP 409).
+type DuplicateChargeError struct {
+	ChargeID  string
+	Amount    money.Money
+	CreatedAt time.Time
+}
+
+func (e *DuplicateChargeError) Error() string {
+	return fmt.Sprintf("a charge of %s (%s) was made %s ago; send force=true to charge again",
+		e.Amount, e.ChargeID, time.Since(e.CreatedAt).Round(time.Second))
+}
+
+// (helper for CreateCharge) a *DuplicateChargeError when userID has a pending or successful charge of
+// amount and currency created within s.DuplicateWindow.
+func (s *PaymentService) checkDuplicate(ctx context.Context, userID *uint, amount int64, currency string) error {
+	if s.DuplicateWindow <= 0 || userID == nil {
+		return nil
+	}
+	currency = strings.ToLower(currency)
+	prev, err := s.Transactions.WithContext(ctx).LatestChargeLike(*userID, amount, currency, time.Now().Add(-s.DuplicateWindow))
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return nil
+	case err != nil:
+		return fmt.Errorf("duplicate charge check: %w", err)
+	}
+	log.Printf("duplicate charge: refused user=%d amount=%d %s, like %s", *userID, amount, currency, prev.ChargeID)
+	return &DuplicateChargeError{ChargeID: prev.ChargeID, Amount: money.New(amount, currency), CreatedAt: prev.CreatedAt}
+}
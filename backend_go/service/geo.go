@@ -0,0 +1,74 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	omise "github.com/omise/omise-go"
+)
+
+// ErrCountryNotAllowed is returned by CreateCharge for a payer in a country Countries does not sell to.
+var ErrCountryNotAllowed = errors.New("payments are not accepted from this country")
+
+// CountryPolicy limits charges by the country of the payer's IP address (CHARGE_COUNTRIES_*), as
+// ISO 3166-1 alpha-2 codes in upper case. Charges from an unknown country (no country header, service
+// calls) are not limited.
+type CountryPolicy struct {
+	Allowed []string // only these countries; empty allows all
+	Denied  []string // never these
+}
+
+// Permits reports whether p lets a payer from country charge; "" is an unknown country.
+func (p CountryPolicy) Permits(country string) bool {
+	if country == "" {
+		return true
+	}
+	if slices.Contains(p.Denied, country) {
+		return false
+	}
+	return len(p.Allowed) == 0 || slices.Contains(p.Allowed, country)
+}
+
+// NormalizeCountry is a country code as CountryPolicy and transactions store it: an upper-case
+// two-letter code, or "" for a value that is not one. Of Cloudflare's pseudo-codes, "XX" (unknown) is
+// "" and "T1" (Tor) is kept, so an allowlist refuses Tor.
+func NormalizeCountry(country string) string {
+	c := strings.ToUpper(strings.TrimSpace(country))
+	if c == "T1" {
+		return c
+	}
+	if len(c) != 2 || c == "XX" || strings.Trim(c, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return ""
+	}
+	return c
+}
+
+// (helper for CreateCharge) refuse a payer from a country s.Countries does not permit, and note the
+// payer's country in req's metadata (ip_country), which RecordCharge copies to the transaction;
+// clients cannot set it themselves.
+func (s *PaymentService) checkCountry(req *models.PaymentRequest) error {
+	delete(req.Metadata, "ip_country")
+	country := NormalizeCountry(req.ClientCountry)
+	if !s.Countries.Permits(country) {
+		log.Printf("geo: charge refused country=%s ip=%s", country, req.ClientIP)
+		return ErrCountryNotAllowed
+	}
+	if country != "" {
+		if req.Metadata == nil {
+			req.Metadata = make(map[string]interface{})
+		}
+		req.Metadata["ip_country"] = country
+	}
+	return nil
+}
+
+// (helper for RecordCharge) the payer's address Omise has for charge; "" if none.
+func chargeIP(charge *omise.Charge) string {
+	if charge == nil || charge.IP == nil {
+		return ""
+	}
+	return *charge.IP
+}
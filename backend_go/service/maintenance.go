@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/gateway"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	omise "github.com/omise/omise-go"
+	"gorm.io/gorm"
+)
+
+// Local status and failure code of a pending charge expired by ExpireStalePending.
+const (
+	StatusExpired      = "expired"
+	FailureCodeExpired = "payment_expired"
+)
+
+// Local status and failure code of a pending charge its payer canceled (CancelCharge).
+const (
+	StatusCanceled      = "canceled"
+	FailureCodeCanceled = "payment_canceled"
+)
+
+// maintenanceBatch bounds how many rows one maintenance pass touches, so a backlog is worked off over
+// several runs instead of one long one.
+const maintenanceBatch = 200
+
+// ReconcilePending re-fetches charges still pending locally that were created before createdBefore
+// (their webhook may have been lost) and records whatever Omise reports now. It works newest first
+// through every such charge, a batch at a time, so charges Omise still reports pending (the payer
+// has not scanned the QR yet) do not keep older ones from being checked. It returns how many
+// transactions left pending.
+func (s *PaymentService) ReconcilePending(ctx context.Context, createdBefore time.Time) (int, error) {
+	settled := 0
+	var after *models.Transaction // last row of the previous batch
+	for {
+		q := s.DB.WithContext(ctx).Select("id", "merchant_id", "charge_id", "created_at").
+			Where("status = ? AND created_at < ?", string(omise.ChargePending), createdBefore)
+		if after != nil {
+			q = q.Where("(created_at, id) < (?, ?)", after.CreatedAt, after.ID)
+		}
+		var pending []models.Transaction
+		if err := q.Order("created_at DESC, id DESC").Limit(maintenanceBatch).Find(&pending).Error; err != nil {
+			return settled, err
+		}
+		for i := range pending {
+			t := &pending[i]
+			if ctx.Err() != nil {
+				return settled, ctx.Err()
+			}
+			mctx := gateway.WithMerchant(ctx, t.MerchantID)
+			ch, err := s.Omise.RetrieveCharge(mctx, t.ChargeID)
+			if err != nil {
+				log.Printf("reconcile: retrieve charge=%s failed err=%v", t.ChargeID, err)
+				continue
+			}
+			if ch.Status == omise.ChargePending {
+				continue
+			}
+			if err := s.RecordCharge(mctx, ch, nil); err != nil {
+				return settled, fmt.Errorf("record charge %s: %w", t.ChargeID, err)
+			}
+			log.Printf("reconcile: charge=%s pending -> %s", t.ChargeID, ch.Status)
+			settled++
+		}
+		if len(pending) < maintenanceBatch {
+			return settled, nil
+		}
+		after = &pending[len(pending)-1]
+	}
+}
+
+// expiryGrace is how long after a charge's expires_at ExpireStalePending leaves it to Omise's own
+// charge.expire webhook.
+const expiryGrace = 5 * time.Minute
+
+// ExpireStalePending closes out charges still pending locally at now past their deadline: their
+// expires_at (plus expiryGrace) for charges that expire, such as PromptPay QRs and bank redirects, and
+// ttl after creation for the others. Ones Omise has settled in the meantime are recorded, ones it still
+// reports pending are marked expired here (the payer abandoned the QR or bank page). A late successful
+// webhook still credits an expired transaction, as RecordCharge credits any transition into
+// successful. It returns how many were expired.
+func (s *PaymentService) ExpireStalePending(ctx context.Context, now time.Time, ttl time.Duration) (int, error) {
+	pending, err := s.stalePending(ctx, now, ttl)
+	if err != nil {
+		return 0, err
+	}
+	expired := 0
+	for _, t := range pending {
+		if ctx.Err() != nil {
+			return expired, ctx.Err()
+		}
+		mctx := gateway.WithMerchant(ctx, t.MerchantID)
+		ch, err := s.Omise.RetrieveCharge(mctx, t.ChargeID)
+		if err != nil {
+			log.Printf("expire: retrieve charge=%s failed err=%v", t.ChargeID, err)
+			continue
+		}
+		if ch.Status != omise.ChargePending {
+			if err := s.RecordCharge(mctx, ch, nil); err != nil {
+				return expired, fmt.Errorf("record charge %s: %w", t.ChargeID, err)
+			}
+			continue
+		}
+		ok, err := s.expirePending(ctx, t.ChargeID)
+		if err != nil {
+			return expired, fmt.Errorf("expire charge %s: %w", t.ChargeID, err)
+		}
+		if ok {
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+// (helper for ExpireStalePending) the oldest batch of pending transactions past their deadline at now.
+func (s *PaymentService) stalePending(ctx context.Context, now time.Time, ttl time.Duration) ([]models.Transaction, error) {
+	var pending []models.Transaction
+	err := s.DB.WithContext(ctx).Select("id", "merchant_id", "charge_id", "created_at").
+		Where("status = ? AND (expires_at < ? OR (expires_at IS NULL AND created_at < ?))",
+			string(omise.ChargePending), now.Add(-expiryGrace), now.Add(-ttl)).
+		Order("created_at").Limit(maintenanceBatch).Find(&pending).Error
+	return pending, err
+}
+
+// (helper for ExpireStalePending) mark the transaction expired unless a webhook settled it first.
+func (s *PaymentService) expirePending(ctx context.Context, chargeID string) (bool, error) {
+	return s.closePending(ctx, chargeID, StatusExpired, FailureCodeExpired, "payment was not completed in time")
+}
+
+// (helper for expirePending and CancelCharge) move a pending transaction to status with the failure
+// code and message, unless a webhook settled it first; it reports whether it did.
+func (s *PaymentService) closePending(ctx context.Context, chargeID, status, code, msg string) (bool, error) {
+	var saved *models.Transaction
+	err := dbutil.Transaction(s.DB.WithContext(ctx), "close_pending_transaction", func(tx *gorm.DB) error {
+		saved = nil
+		transactions := s.Transactions.WithTx(tx)
+		t, err := transactions.LockByChargeID(chargeID)
+		if err != nil {
+			return err
+		}
+		if t.Status != string(omise.ChargePending) {
+			return nil
+		}
+		t.Status, t.FailureCode, t.FailureMessage = status, &code, &msg
+		if err := transactions.UpdateStatus(t); err != nil {
+			return err
+		}
+		saved = t
+		if err := RecordStatusEvent(transactions, *t, string(omise.ChargePending), EventSource(ctx)); err != nil {
+			return err
+		}
+		return tx.Create(systemAudit(models.AuditStatusChange, "transaction", fmt.Sprintf("%d", t.ID),
+			map[string]interface{}{"status": string(omise.ChargePending)},
+			map[string]interface{}{"status": status, "charge_id": chargeID, "failure_code": code})).Error
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if saved == nil {
+		return false, nil
+	}
+	s.TransactionCache.Invalidate(ctx, *saved)
+	s.Updates.Publish(*saved)
+	return true, nil
+}
+
+// PruneRawPayloads clears the stored Omise payload of settled transactions last updated before cutoff,
+// in batches until none are left or ctx ends; the structured columns stay. With a PayloadArchive each
+// payload is first moved there, as stored (sealed by PayloadCipher), and stays readable through
+// RawPayload. It returns how many were cleared.
+func (s *PaymentService) PruneRawPayloads(ctx context.Context, cutoff time.Time) (int64, error) {
+	if s.PayloadArchive != nil {
+		return s.archiveRawPayloads(ctx, cutoff)
+	}
+	var total int64
+	for ctx.Err() == nil {
+		res := s.DB.WithContext(ctx).Model(&models.Transaction{}).
+			Where("id IN (?)", s.DB.Model(&models.Transaction{}).Select("id").
+				Where("raw_payload IS NOT NULL AND status <> ? AND updated_at < ?", string(omise.ChargePending), cutoff).
+				Limit(maintenanceBatch)).
+			UpdateColumn("raw_payload", nil)
+		if res.Error != nil {
+			return total, res.Error
+		}
+		total += res.RowsAffected
+		if res.RowsAffected < maintenanceBatch {
+			return total, nil
+		}
+	}
+	return total, ctx.Err()
+}
+
+// (helper for PruneRawPayloads) move payloads to s.PayloadArchive, oldest first, one object per charge
+// under PayloadArchivePrefix/<yyyy>/<mm>/. A payload is only cleared once its object is written; an
+// upload failure stops the run, leaving the rest for the next one.
+func (s *PaymentService) archiveRawPayloads(ctx context.Context, cutoff time.Time) (int64, error) {
+	var total int64
+	for ctx.Err() == nil {
+		var batch []models.Transaction
+		if err := s.DB.WithContext(ctx).Select("id", "charge_id", "created_at", "raw_payload").
+			Where("raw_payload IS NOT NULL AND status <> ? AND updated_at < ?", string(omise.ChargePending), cutoff).
+			Order("id").Limit(maintenanceBatch).Find(&batch).Error; err != nil {
+			return total, err
+		}
+		for _, t := range batch {
+			key := s.PayloadArchivePrefix + t.CreatedAt.UTC().Format("2006/01/") + t.ChargeID + ".bin"
+			if err := s.PayloadArchive.Put(ctx, key, t.RawPayload, "application/octet-stream"); err != nil {
+				return total, err
+			}
+			res := s.DB.WithContext(ctx).Model(&models.Transaction{}).Where("id = ?", t.ID).
+				UpdateColumns(map[string]interface{}{"raw_payload": nil, "raw_payload_object": key})
+			if res.Error != nil {
+				return total, res.Error
+			}
+			total += res.RowsAffected
+		}
+		if len(batch) < maintenanceBatch {
+			return total, nil
+		}
+	}
+	return total, ctx.Err()
+}
+
+// RawPayload is t's stored Omise payload, as sealed by PayloadCipher: from the row, or from
+// PayloadArchive once pruned there. nil when neither has it (pruned without an archive).
+func (s *PaymentService) RawPayload(ctx context.Context, t *models.Transaction) ([]byte, error) {
+	if len(t.RawPayload) > 0 || t.RawPayloadObject == "" {
+		return t.RawPayload, nil
+	}
+	if s.PayloadArchive == nil {
+		return nil, fmt.Errorf("raw payload archived to %s, but no archive is configured", t.RawPayloadObject)
+	}
+	return s.PayloadArchive.Read(ctx, t.RawPayloadObject)
+}
+
+// EnsureTransactionPartitions creates the monthly partitions of transactions (migration 000029) from
+// this month through monthsAhead months from now that do not exist yet, so new charges never fall
+// into transactions_default. It returns how many it created.
+func (s *PaymentService) EnsureTransactionPartitions(ctx context.Context, monthsAhead int) (int, error) {
+	now := time.Now()
+	var created int
+	err := s.DB.WithContext(ctx).Raw("SELECT create_transaction_partitions(?, ?)", now, now.AddDate(0, monthsAhead, 0)).
+		Scan(&created).Error
+	return created, err
+}
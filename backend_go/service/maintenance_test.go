@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/a2n2k3p4/tutorium-backend/gateway/gatewaytest"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/objectstore"
+)
+
+func TestRawPayloadFromArchive(t *testing.T) {
+	s := NewPaymentService(nil, gatewaytest.NewFake())
+	stored := &models.Transaction{RawPayload: []byte(`{"id":"chrg_test_1"}`)}
+	archived := &models.Transaction{RawPayloadObject: "raw-payloads/2026/01/chrg_test_2.bin"}
+
+	if b, err := s.RawPayload(context.Background(), stored); err != nil || string(b) != `{"id":"chrg_test_1"}` {
+		t.Errorf("stored payload = %q, %v", b, err)
+	}
+	if _, err := s.RawPayload(context.Background(), archived); err == nil {
+		t.Error("archived payload without an archive: want an error")
+	}
+
+	store := objectstore.NewMemory()
+	s.PayloadArchive = store
+	if _, err := s.RawPayload(context.Background(), archived); !errors.Is(err, objectstore.ErrNotFound) {
+		t.Errorf("missing object: err = %v, want objectstore.ErrNotFound", err)
+	}
+	store.Put(context.Background(), archived.RawPayloadObject, []byte(`{"id":"chrg_test_2"}`), "application/octet-stream")
+	if b, err := s.RawPayload(context.Background(), archived); err != nil || string(b) != `{"id":"chrg_test_2"}` {
+		t.Errorf("archived payload = %q, %v", b, err)
+	}
+	if b, err := s.RawPayload(context.Background(), &models.Transaction{}); err != nil || b != nil {
+		t.Errorf("pruned without archive = %q, %v; want none", b, err)
+	}
+}
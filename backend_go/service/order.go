@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"gorm.io/gorm"
+)
+
+// (helper for CreateCharge) check that req may pay for its order, which must be pending and the
+// payer's, and take the amount (the order total less req's coupon) and currency from it when req
+// leaves them out.
+func (s *PaymentService) applyOrder(ctx context.Context, req *models.PaymentRequest, userID *uint) error {
+	order, err := s.Orders.WithContext(ctx).Get(*req.OrderID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return invalidInput("order_not_found", "order %d does not exist", *req.OrderID)
+		}
+		return fmt.Errorf("load order %d: %w", *req.OrderID, err)
+	}
+	if order.Status != models.OrderPending {
+		return invalidInput("order_not_payable", "order %d is %s", order.ID, order.Status)
+	}
+	if order.UserID != nil && userID != nil && *order.UserID != *userID {
+		return invalidInput("order_user_mismatch", "order %d belongs to another user", order.ID)
+	}
+	amount := order.TotalSatang
+	if req.CouponCode != "" {
+		coupon, discount, err := s.couponFor(ctx, req.CouponCode, *order, userID)
+		if err != nil {
+			return err
+		}
+		amount -= discount
+		// Recorded on the transaction from the charge metadata (see RecordCharge). CreateCharge has
+		// dropped any the client sent (reservedMetadataKeys), so only these values are trusted.
+		if req.Metadata == nil {
+			req.Metadata = make(map[string]interface{})
+		}
+		req.Metadata["coupon_id"] = fmt.Sprintf("%d", coupon.ID)
+		req.Metadata["discount_satang"] = fmt.Sprintf("%d", discount)
+	}
+	if req.Amount == 0 {
+		req.Amount = amount
+	}
+	if req.Currency == "" {
+		req.Currency = order.Currency
+	}
+	if req.Amount != amount || !strings.EqualFold(req.Currency, order.Currency) {
+		return invalidInput("order_amount_mismatch", "order %d is charged %d %s, not %d %s",
+			order.ID, amount, order.Currency, req.Amount, req.Currency)
+	}
+	return nil
+}
+
+// (helper for RecordCharge) mark t's order paid by t, which just became successful. An order that is
+// no longer pending (paid by another charge, or cancelled), or whose total t (with its discount) does
+// not pay, is left alone and logged: the payment stands and needs a refund or a manual match.
+func (s *PaymentService) markOrderPaid(tx *gorm.DB, t models.Transaction) error {
+	orders := s.Orders.WithTx(tx)
+	order, err := orders.Get(*t.OrderID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			log.Printf("orders: charge=%s succeeded for order=%d, which does not exist; not marked paid", t.ChargeID, *t.OrderID)
+			return nil
+		}
+		return err
+	}
+	if t.AmountSatang+t.DiscountSatang != order.TotalSatang || !strings.EqualFold(t.Currency, order.Currency) {
+		log.Printf("orders: charge=%s of %d %s (discount %d) does not pay order=%d of %d %s; not marked paid",
+			t.ChargeID, t.AmountSatang, t.Currency, t.DiscountSatang, order.ID, order.TotalSatang, order.Currency)
+		return nil
+	}
+	paid, err := orders.MarkPaid(*t.OrderID, t.ID, time.Now())
+	if err != nil {
+		return err
+	}
+	if !paid {
+		log.Printf("orders: charge=%s succeeded for order=%d, which is not pending; not marked paid", t.ChargeID, *t.OrderID)
+		return nil
+	}
+	return tx.Create(systemAudit(models.AuditOrderPaid, "order", fmt.Sprintf("%d", *t.OrderID), nil,
+		map[string]interface{}{"status": models.OrderPaid, "transaction_id": t.ID, "charge_id": t.ChargeID})).Error
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/repository/repotest"
+	omise "github.com/omise/omise-go"
+	"gorm.io/gorm"
+)
+
+func TestRecordChargeMarksOrderPaidOnce(t *testing.T) {
+	l := newLedgerTest(t, models.User{Model: gorm.Model{ID: 7}})
+	uid := uint(7)
+	order := models.Order{UserID: &uid, Status: models.OrderPending, Currency: "THB", TotalSatang: 150000}
+	if err := l.orders.Create(&order); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	md := map[string]interface{}{"user_id": "7", "order_id": "1"}
+
+	if err := l.RecordCharge(ctx, testCharge("chrg_test_order_1", omise.ChargePending, 150000, md), nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := l.orders.Get(1); got.Status != models.OrderPending {
+		t.Fatalf("order after a pending charge = %s, want pending", got.Status)
+	}
+	paid := testCharge("chrg_test_order_1", omise.ChargeSuccessful, 150000, md)
+	if err := l.RecordCharge(ctx, paid, nil); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := l.orders.Get(1)
+	if got.Status != models.OrderPaid || got.PaidTransactionID == nil || *got.PaidTransactionID != 1 || got.PaidAt == nil {
+		t.Fatalf("order after the charge succeeded = %+v, want paid by transaction 1", got)
+	}
+	paidAt := *got.PaidAt
+
+	// A webhook retry, then another charge for the same order, do not pay it again.
+	if err := l.RecordCharge(ctx, paid, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.RecordCharge(ctx, testCharge("chrg_test_order_2", omise.ChargeSuccessful, 150000, md), nil); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = l.orders.Get(1)
+	if got.Status != models.OrderPaid || *got.PaidTransactionID != 1 || !got.PaidAt.Equal(paidAt) {
+		t.Errorf("order after more successes = %+v, want still paid by transaction 1 at %v", got, paidAt)
+	}
+	if u, _ := l.users.Get(7); u.Balance != 3000 {
+		t.Errorf("balance = %.2f, want 3000.00: each successful charge credited once", u.Balance)
+	}
+}
+
+func TestClientMetadataCannotPayAnOrder(t *testing.T) {
+	l := newLedgerTest(t, models.User{Model: gorm.Model{ID: 7}})
+	l.Blocklist = repotest.NewBlocklist()
+	uid := uint(7)
+	order := models.Order{UserID: &uid, Status: models.OrderPending, Currency: "THB", TotalSatang: 150000}
+	if err := l.orders.Create(&order); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	metadata := map[string]interface{}{"note": "lunch"}
+	for _, key := range reservedMetadataKeys {
+		metadata[key] = "1"
+	}
+	req := models.PaymentRequest{Amount: 100, Currency: "thb", PaymentType: "promptpay", Metadata: metadata}
+	ch, err := l.CreateCharge(ctx, req, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range reservedMetadataKeys {
+		if _, ok := ch.Metadata[key]; ok {
+			t.Errorf("charge metadata = %v, want no client-set %s", ch.Metadata, key)
+		}
+	}
+	if ch.Metadata["note"] != "lunch" {
+		t.Errorf("charge metadata = %v, want the client's note", ch.Metadata)
+	}
+
+	// A charge that names the order but does not pay its total leaves it pending.
+	short := testCharge("chrg_test_order_short", omise.ChargeSuccessful, 100, map[string]interface{}{"user_id": "7", "order_id": "1"})
+	if err := l.RecordCharge(ctx, short, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := l.orders.Get(1); got.Status != models.OrderPending {
+		t.Errorf("order after a 1 THB charge = %s, want pending", got.Status)
+	}
+}
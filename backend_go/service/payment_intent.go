@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/gateway"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	omise "github.com/omise/omise-go"
+	"gorm.io/gorm"
+)
+
+const (
+	// intentTTL is how long a payment intent can be confirmed.
+	intentTTL = 24 * time.Hour
+	// intentProcessingTimeout is after how long a confirm that never finished (e.g. Omise timed out)
+	// lets the intent be confirmed again. A charge Omise did create meanwhile confirms the intent
+	// through its webhook first (see confirmIntentByCharge).
+	intentProcessingTimeout = 10 * time.Minute
+)
+
+// ErrIntentProcessing is returned by ConfirmIntent while another confirm of the intent is creating its
+// charge.
+var ErrIntentProcessing = errors.New("payment intent is being confirmed")
+
+// CreateIntent fixes the amount and currency of a future charge: the order's total less its coupon
+// when req has an order (as CreateCharge checks it), else req's own. Only the amount, currency, order,
+// coupon, description and metadata of req are used; userID is the payer.
+func (s *PaymentService) CreateIntent(ctx context.Context, req models.PaymentRequest, userID *uint) (*models.PaymentIntent, error) {
+	if req.CouponCode != "" && req.OrderID == nil {
+		return nil, invalidInput("coupon_requires_order", "coupon_code applies to an order; send order_id")
+	}
+	metadata := maps.Clone(req.Metadata) // before applyOrder adds the coupon's
+	if req.OrderID != nil {
+		if err := s.applyOrder(ctx, &req, userID); err != nil {
+			return nil, err
+		}
+	}
+	intent := models.PaymentIntent{
+		MerchantID:   merchantID(ctx),
+		UserID:       userID,
+		OrderID:      req.OrderID,
+		AmountSatang: req.Amount,
+		Currency:     strings.ToUpper(req.Currency),
+		Description:  req.Description,
+		CouponCode:   req.CouponCode,
+		Metadata:     metadata,
+		Status:       models.IntentRequiresConfirmation,
+		ExpiresAt:    time.Now().Add(intentTTL),
+	}
+	if err := s.DB.WithContext(ctx).Create(&intent).Error; err != nil {
+		return nil, fmt.Errorf("create payment intent: %w", err)
+	}
+	return &intent, nil
+}
+
+// ConfirmIntent creates the charge of intent id with pay's payment method (PaymentType, Token,
+// ReturnURI, Bank); the amount and everything else come from the intent. It reports whether the charge
+// is a replay: an intent already confirmed returns its charge, re-read from Omise, without charging
+// again. A declined or rejected attempt leaves the intent confirmable with another payment method.
+//
+// Errors are CreateCharge's, plus *InputError for intents that cannot be confirmed and
+// ErrIntentProcessing for a confirm racing another.
+func (s *PaymentService) ConfirmIntent(ctx context.Context, id uint, pay models.PaymentRequest) (*omise.Charge, bool, error) {
+	intent, err := s.loadIntent(ctx, id)
+	if err != nil {
+		return nil, false, err
+	}
+	ctx = gateway.WithMerchant(ctx, intent.MerchantID)
+	if intent.Status == models.IntentConfirmed {
+		return s.replayIntent(ctx, intent)
+	}
+	now := time.Now()
+	if !now.Before(intent.ExpiresAt) {
+		return nil, false, invalidInput("intent_expired", "payment intent %d expired at %s", intent.ID, intent.ExpiresAt.Format(time.RFC3339))
+	}
+
+	// Claim the intent: of concurrent confirms, only one creates a charge.
+	res := s.DB.WithContext(ctx).Model(&models.PaymentIntent{}).
+		Where("id = ? AND (status = ? OR (status = ? AND updated_at < ?))", intent.ID,
+			models.IntentRequiresConfirmation, models.IntentProcessing, now.Add(-intentProcessingTimeout)).
+		Updates(map[string]interface{}{"status": models.IntentProcessing, "updated_at": now})
+	if res.Error != nil {
+		return nil, false, fmt.Errorf("claim payment intent %d: %w", intent.ID, res.Error)
+	}
+	if res.RowsAffected == 0 {
+		if intent, err = s.loadIntent(ctx, id); err != nil {
+			return nil, false, err
+		}
+		if intent.Status == models.IntentConfirmed {
+			return s.replayIntent(ctx, intent)
+		}
+		return nil, false, ErrIntentProcessing
+	}
+
+	req := models.PaymentRequest{
+		Amount:        intent.AmountSatang,
+		Currency:      intent.Currency,
+		PaymentType:   pay.PaymentType,
+		Token:         pay.Token,
+		ReturnURI:     pay.ReturnURI,
+		Bank:          pay.Bank,
+		Card:          pay.Card,
+		ClientIP:      pay.ClientIP,
+		ClientCountry: pay.ClientCountry,
+		Description:   intent.Description,
+		Metadata:      maps.Clone(intent.Metadata),
+		UserID:        intent.UserID,
+		OrderID:       intent.OrderID,
+		CouponCode:    intent.CouponCode,
+		Force:         true, // the intent is what makes confirming it twice charge once
+	}
+	req.PaymentIntentID = &intent.ID
+
+	charge, err := s.CreateCharge(ctx, req, intent.UserID)
+	// The charge exists on Omise (or surely does not) from here on, so the caller's deadline no longer
+	// applies to recording the outcome on the intent.
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordTimeout)
+	defer cancel()
+	if err != nil {
+		var inErr *InputError
+		var oerr *omise.Error
+		if errors.As(err, &inErr) || (errors.As(err, &oerr) && oerr.StatusCode < 500) {
+			s.releaseIntent(recordCtx, intent.ID, nil, err.Error())
+		}
+		// Otherwise Omise may or may not have created the charge: the intent stays processing until
+		// the charge's webhook confirms it, or intentProcessingTimeout lets it be confirmed again.
+		return nil, false, err
+	}
+	if charge.Status == omise.ChargeFailed {
+		reason := "charge " + charge.ID + " failed"
+		if charge.FailureCode != nil {
+			reason += ": " + *charge.FailureCode
+		}
+		s.releaseIntent(recordCtx, intent.ID, &charge.ID, reason)
+		return charge, false, nil
+	}
+	if err := confirmIntentByCharge(s.DB.WithContext(recordCtx), intent.ID, charge, intent.UserID); err != nil {
+		log.Printf("payment intents: intent=%d charge=%s: failed to mark confirmed: %v", intent.ID, charge.ID, err) // the webhook retries it
+	}
+	return charge, false, nil
+}
+
+// (helper for ConfirmIntent) the intent, or *InputError if there is none.
+func (s *PaymentService) loadIntent(ctx context.Context, id uint) (*models.PaymentIntent, error) {
+	var intent models.PaymentIntent
+	if err := s.DB.WithContext(ctx).Take(&intent, id).Error; err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, invalidInput("intent_not_found", "payment intent %d does not exist", id)
+		}
+		return nil, fmt.Errorf("load payment intent %d: %w", id, err)
+	}
+	return &intent, nil
+}
+
+// (helper for ConfirmIntent) the charge of a confirmed intent (which always has ChargeID), as a replay.
+func (s *PaymentService) replayIntent(ctx context.Context, intent *models.PaymentIntent) (*omise.Charge, bool, error) {
+	charge, err := s.Omise.RetrieveCharge(ctx, *intent.ChargeID)
+	if err != nil {
+		return nil, false, err
+	}
+	return charge, true, nil
+}
+
+// (helper for ConfirmIntent) make a claimed intent confirmable again after an attempt that created no
+// usable charge; chargeID is the declined charge, if there is one.
+func (s *PaymentService) releaseIntent(ctx context.Context, id uint, chargeID *string, reason string) {
+	updates := map[string]interface{}{"status": models.IntentRequiresConfirmation, "last_error": truncate(reason, 255), "updated_at": time.Now()}
+	if chargeID != nil {
+		updates["charge_id"] = *chargeID
+	}
+	if err := s.DB.WithContext(ctx).Model(&models.PaymentIntent{}).
+		Where("id = ? AND status = ?", id, models.IntentProcessing).Updates(updates).Error; err != nil {
+		log.Printf("payment intents: intent=%d: failed to release: %v", id, err)
+	}
+}
+
+// confirmIntentByCharge marks intent id confirmed by charge of userID, if a confirm is still
+// processing it and the charge is the intent's: the same user, amount and currency. Both ConfirmIntent
+// and RecordCharge (for charges carrying metadata.payment_intent_id) call it, so an intent whose
+// confirm lost track of the charge is confirmed by the charge's webhook.
+func confirmIntentByCharge(tx *gorm.DB, id uint, charge *omise.Charge, userID *uint) error {
+	return tx.Model(&models.PaymentIntent{}).
+		Where("id = ? AND status = ? AND user_id IS NOT DISTINCT FROM ? AND amount_satang = ? AND LOWER(currency) = LOWER(?)",
+			id, models.IntentProcessing, userID, charge.Amount, charge.Currency).
+		Updates(map[string]interface{}{"status": models.IntentConfirmed, "charge_id": charge.ID, "last_error": "", "updated_at": time.Now()}).Error
+}
+
+// (helper for releaseIntent) s cut to at most n bytes.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
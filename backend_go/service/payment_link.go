@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"gorm.io/gorm"
+)
+
+// (helper for CreateCharge) check that req may pay its payment link, which must be active, unexpired
+// and, if it names a payer, the payer's; the amount, currency and description are the link's.
+func (s *PaymentService) applyPaymentLink(ctx context.Context, req *models.PaymentRequest, userID *uint) error {
+	var link models.PaymentLink
+	if err := s.DB.WithContext(ctx).Take(&link, *req.PaymentLinkID).Error; err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return invalidInput("payment_link_not_found", "payment link %d does not exist", *req.PaymentLinkID)
+		}
+		return fmt.Errorf("load payment link %d: %w", *req.PaymentLinkID, err)
+	}
+	if !link.Payable(time.Now()) {
+		status := link.Status
+		if status == models.PaymentLinkActive {
+			status = "expired"
+		}
+		return invalidInput("payment_link_not_payable", "payment link is %s", status)
+	}
+	if link.PayerUserID != nil && (userID == nil || *link.PayerUserID != *userID) {
+		return invalidInput("payment_link_user_mismatch", "payment link is for another user")
+	}
+	req.Amount, req.Currency, req.Description = link.AmountSatang, link.Currency, link.Description
+	return nil
+}
+
+// (helper for RecordCharge) mark t's payment link paid by t, which just became successful. A link that
+// is no longer active (paid by another charge, or cancelled), or whose amount t does not pay, is left
+// alone and logged, as for orders.
+func markPaymentLinkPaid(tx *gorm.DB, t models.Transaction) error {
+	now := time.Now()
+	res := tx.Model(&models.PaymentLink{}).
+		Where("id = ? AND status = ? AND amount_satang = ? AND LOWER(currency) = LOWER(?)",
+			*t.PaymentLinkID, models.PaymentLinkActive, t.AmountSatang, t.Currency).
+		Updates(map[string]interface{}{"status": models.PaymentLinkPaid, "paid_at": now, "paid_transaction_id": t.ID, "updated_at": now})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		log.Printf("payment links: charge=%s of %d %s succeeded for link=%d, which is not active for that amount; not marked paid",
+			t.ChargeID, t.AmountSatang, t.Currency, *t.PaymentLinkID)
+		return nil
+	}
+	return tx.Create(systemAudit(models.AuditPaymentLinkPaid, "payment_link", fmt.Sprintf("%d", *t.PaymentLinkID), nil,
+		map[string]interface{}{"status": models.PaymentLinkPaid, "transaction_id": t.ID, "charge_id": t.ChargeID})).Error
+}
@@ -0,0 +1,504 @@
+// Package service holds the payment business logic (charge creation, recording charges, crediting
+// balances, refunds) behind context-aware methods with typed inputs. It knows nothing about HTTP: the
+// Fiber handlers are thin adapters over it, and the same service can back gRPC or CLI surfaces.
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/gateway"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/money"
+	"github.com/a2n2k3p4/tutorium-backend/objectstore"
+	"github.com/a2n2k3p4/tutorium-backend/provider"
+	"github.com/a2n2k3p4/tutorium-backend/rawpayload"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	"github.com/a2n2k3p4/tutorium-backend/risk"
+	"github.com/a2n2k3p4/tutorium-backend/tax"
+	omise "github.com/omise/omise-go"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// PaymentService creates charges on Omise and keeps the local ledger (transactions, user balances,
+// audit log) in step with them.
+type PaymentService struct {
+	DB *gorm.DB
+
+	// Transactions, Users, Orders and Blocklist are the data access for payment transactions, user
+	// balances, the orders charges pay for and the payer blocklist; NewPaymentService binds the
+	// Postgres implementations to DB.
+	Transactions repository.TransactionRepository
+	Users        repository.UserRepository
+	Orders       repository.OrderRepository
+	Blocklist    repository.BlocklistRepository
+
+	// Omise is the payment provider API; tests inject gatewaytest.Fake or a gatewaytest.Server client.
+	Omise gateway.OmiseGateway
+
+	// Provider creates charges and refunds and parses webhooks; NewPaymentService wraps Omise in
+	// provider.Omise. Recording and reconciliation still read Omise directly.
+	Provider provider.PaymentProvider
+
+	// AllowRawCard enables server-side tokenization of raw card data (PAN/CVV in the request's Card).
+	// Keep false outside sandbox: accepting raw card data puts this service in PCI scope.
+	AllowRawCard bool
+
+	// PayloadCipher encrypts Transaction.RawPayload at rest; nil stores masked plaintext.
+	PayloadCipher *rawpayload.Cipher
+	// PayloadArchive receives raw payloads pruned by PruneRawPayloads, under PayloadArchivePrefix;
+	// nil discards them.
+	PayloadArchive       objectstore.Store
+	PayloadArchivePrefix string
+
+	// VATRateBps is the VAT included in charge amounts, in basis points (VAT_RATE_PCT); successful
+	// charges get their VAT and a tax invoice number at this rate. 0 disables both.
+	VATRateBps int64
+
+	// WalletFXRates is THB per major unit of each other currency (lowercase ISO 4217) whose charges may
+	// credit a wallet, which holds THB (WALLET_FX_RATES). Charges for a user in a currency without a
+	// rate are refused.
+	WalletFXRates map[string]float64
+
+	// Velocity caps each charge and each user's charges per hour and day; the zero value is unlimited.
+	Velocity VelocityLimits
+
+	// DuplicateWindow refuses a user's charge of the same amount and currency as one of theirs pending
+	// or successful created this recently, unless the request is forced (PaymentRequest.Force); 0
+	// disables the check.
+	DuplicateWindow time.Duration
+
+	// Risk assesses charges before they are created (RISK_EVALUATOR): denied ones are refused, flagged
+	// ones are kept for review. nil disables risk scoring.
+	Risk risk.Evaluator
+
+	// Countries limits charges by the payer's country; the zero value allows all.
+	Countries CountryPolicy
+
+	// SecureCards sends large and risk-flagged card charges through 3-D Secure.
+	SecureCards SecureCardPolicy
+
+	// ReturnURIAllowlist lists hosts allowed as return_uri for redirect-based charges; empty disables the check.
+	ReturnURIAllowlist []string
+
+	// OnChargeSucceeded is called after commit when a transaction first becomes successful (e.g. to
+	// issue its e-Tax invoice), except for imported history (models.TransactionEventImport). It must
+	// not block; nil disables it.
+	OnChargeSucceeded func(transactionID uint)
+	// OnChargeFailed is OnChargeSucceeded for a transaction first becoming failed (declined).
+	OnChargeFailed func(transactionID uint)
+
+	// Updates receives every transaction RecordCharge saves, after commit.
+	Updates TransactionFeed
+
+	// Merchants lists the merchant ids with an Omise account behind Omise (see gateway.Merchants);
+	// ReconcileCharges reconciles each. Empty means only models.DefaultMerchantID.
+	Merchants []uint
+
+	// TransactionCache serves the polled transaction reads; the service invalidates it after every
+	// commit that changes a transaction. nil disables caching.
+	TransactionCache *repository.TransactionCache
+
+	// PageSize bounds the listings of every surface (HTTP and gRPC); zero is repository.DefaultPageSize.
+	PageSize repository.PageSize
+}
+
+func NewPaymentService(db *gorm.DB, gw gateway.OmiseGateway) *PaymentService {
+	return &PaymentService{
+		DB:           db,
+		Omise:        gw,
+		Provider:     provider.NewOmise(gw),
+		Transactions: repository.NewTransactionRepository(db),
+		Users:        repository.NewUserRepository(db),
+		Orders:       repository.NewOrderRepository(db),
+		Blocklist:    repository.NewBlocklistRepository(db),
+		VATRateBps:   tax.VATRateBps,
+		SecureCards:  SecureCardPolicy{RiskFlagged: true},
+	}
+}
+
+// InputError is a request the service refuses before calling Omise. Code is a stable machine-readable
+// reason; surfaces map InputError to their "bad request" (HTTP 400).
+type InputError struct {
+	Code    string
+	Message string
+}
+
+func (e *InputError) Error() string { return e.Message }
+
+func invalidInput(code, format string, args ...interface{}) error {
+	return &InputError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// RecordCharge updates or creates the local transaction for charge and adjusts the user's balance,
+// only on status transitions across the "successful" boundary. userID overrides metadata.user_id.
+// A new transaction belongs to ctx's merchant (gateway.WithMerchant), the account charge came from.
+// It is idempotent, so webhooks and retries may record the same charge any number of times. Status
+// changes are appended to the status history with ctx's EventSource.
+func (s *PaymentService) RecordCharge(ctx context.Context, charge *omise.Charge, userID *uint) error {
+	if charge == nil {
+		return fmt.Errorf("nil charge")
+	}
+	userID = extractUserIDFromCharge(charge, userID)
+	channel := determineChannel(charge)
+	brand, lastDigits, bank := paymentDetails(charge)
+	rawPayload, err := s.SealRawPayload(charge)
+	if err != nil {
+		return err
+	}
+
+	var meta datatypes.JSONMap
+	if charge.Metadata != nil {
+		meta = datatypes.JSONMap(charge.Metadata)
+	}
+
+	// Retried as a whole on transient DB errors; safe because the balance credit is keyed on the
+	// previous status, read under the charge's lock (LockByChargeID), which also serializes webhooks
+	// and reconciliation on other replicas recording the same charge.
+	var (
+		saved            models.Transaction
+		becameSuccessful bool
+		becameFailed     bool
+	)
+	err = dbutil.Transaction(s.DB.WithContext(ctx), "upsert_transaction", func(tx *gorm.DB) error {
+		prev, err := s.Transactions.WithTx(tx).LockByChargeID(charge.ID)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+		prevWasSuccessful := prev != nil && prev.Status == "successful"
+		becameSuccessful = !prevWasSuccessful && string(charge.Status) == "successful"
+		becameFailed = (prev == nil || prev.Status != string(omise.ChargeFailed)) && charge.Status == omise.ChargeFailed
+
+		newTx := models.Transaction{
+			CreatedAt:      chargeCreatedAt(charge, prev),
+			UserID:         userID,
+			ActingUserID:   metadataID(charge, "acting_user_id"),
+			MerchantID:     merchantID(ctx),
+			Provider:       provider.NameOmise,
+			ChargeID:       charge.ID,
+			AmountSatang:   charge.Amount,
+			Currency:       charge.Currency,
+			Channel:        channel,
+			CardBrand:      brand,
+			CardLastDigits: lastDigits,
+			Bank:           bank,
+			Status:         string(charge.Status),
+			Description:    charge.Description,
+			FailureCode:    charge.FailureCode,
+			FailureMessage: charge.FailureMessage,
+			ExpiresAt:      chargeExpiry(charge),
+			OrderID:        metadataID(charge, "order_id"),
+			PaymentLinkID:  metadataID(charge, "payment_link_id"),
+			CouponID:       metadataID(charge, "coupon_id"),
+			DiscountSatang: metadataSatang(charge, "discount_satang"),
+			ClientIP:       chargeIP(charge),
+			IPCountry:      metadataString(charge, "ip_country"),
+			RiskDecision:   metadataString(charge, "risk_decision"),
+			RiskScore:      metadataInt(charge, "risk_score"),
+			RiskReasons:    metadataString(charge, "risk_reasons"),
+			RawPayload:     rawPayload,
+			Meta:           meta,
+		}
+		if prev != nil && prev.Status == StatusCanceled && (charge.Status == omise.ChargePending || string(charge.Status) == StatusExpired) {
+			// The payer canceled it (CancelCharge); Omise expiring it, or not supporting that, changes nothing.
+			newTx.Status, newTx.FailureCode, newTx.FailureMessage = prev.Status, prev.FailureCode, prev.FailureMessage
+		}
+		if prev != nil && prev.Status != newTx.Status {
+			overridden, err := statusOverridden(s.Transactions.WithTx(tx), prev.ID)
+			if err != nil {
+				return err
+			}
+			if overridden {
+				// An admin set the status (OverrideStatus); it stands, and so does the wallet credit it made or not.
+				log.Printf("record: charge=%s reported %s, keeping the overridden status %s", charge.ID, charge.Status, prev.Status)
+				newTx.Status, newTx.FailureCode, newTx.FailureMessage = prev.Status, prev.FailureCode, prev.FailureMessage
+				becameSuccessful, becameFailed = false, false
+			}
+		}
+		if err := s.Transactions.WithTx(tx).UpsertByChargeID(&newTx); err != nil {
+			return err
+		}
+		// The VAT and tax invoice number are fixed once issued (the upsert leaves them alone); a charge
+		// that succeeds again after a reversal keeps them.
+		if prev != nil && prev.TaxInvoiceNumber != nil {
+			newTx.VATRateBps, newTx.VATSatang, newTx.TaxInvoiceNumber = prev.VATRateBps, prev.VATSatang, prev.TaxInvoiceNumber
+		} else if becameSuccessful && s.VATRateBps > 0 {
+			if err := issueTaxInvoice(tx, &newTx, s.VATRateBps, time.Now()); err != nil {
+				return err
+			}
+		}
+		saved = newTx
+		if prev != nil {
+			saved.CreatedAt = prev.CreatedAt // the upsert does not read the row back
+		}
+
+		// Status history: one entry per status the transaction passes through (see GetTransactionAsOf
+		// and GetTransactionHistory).
+		if prev == nil || prev.Status != newTx.Status {
+			var before interface{}
+			oldStatus := ""
+			if prev != nil {
+				before = map[string]interface{}{"status": prev.Status}
+				oldStatus = prev.Status
+			}
+			if err := RecordStatusEvent(s.Transactions.WithTx(tx), newTx, oldStatus, EventSource(ctx)); err != nil {
+				return err
+			}
+			if err := tx.Create(systemAudit(models.AuditStatusChange, "transaction", fmt.Sprintf("%d", newTx.ID), before,
+				map[string]interface{}{"status": newTx.Status, "charge_id": newTx.ChargeID, "failure_code": newTx.FailureCode})).Error; err != nil {
+				return err
+			}
+		}
+
+		if becameSuccessful && newTx.OrderID != nil {
+			if err := s.markOrderPaid(tx, newTx); err != nil {
+				return err
+			}
+		}
+		if becameSuccessful && newTx.PaymentLinkID != nil {
+			if err := markPaymentLinkPaid(tx, newTx); err != nil {
+				return err
+			}
+		}
+		if id := metadataID(charge, "payment_intent_id"); id != nil && charge.Status != omise.ChargeFailed {
+			if err := confirmIntentByCharge(tx, *id, charge, userID); err != nil {
+				return err
+			}
+		}
+		if becameSuccessful && newTx.CouponID != nil {
+			if err := redeemCoupon(tx, newTx); err != nil {
+				return err
+			}
+		}
+		if userID != nil && newTx.Status == string(charge.Status) {
+			return s.adjustUserBalanceOnStatusTransition(tx, charge, userID, prevWasSuccessful)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.TransactionCache.Invalidate(ctx, saved)
+	s.Updates.Publish(saved)
+	if EventSource(ctx) == models.TransactionEventImport {
+		return nil // history being imported: no receipts or confirmations for it
+	}
+	if becameSuccessful && saved.ID != 0 && s.OnChargeSucceeded != nil {
+		s.OnChargeSucceeded(saved.ID)
+	}
+	if becameFailed && saved.ID != 0 && s.OnChargeFailed != nil {
+		s.OnChargeFailed(saved.ID)
+	}
+	return nil
+}
+
+// chargeExpiry is when charge can no longer be paid; nil for charges that do not expire.
+func chargeExpiry(charge *omise.Charge) *time.Time {
+	if charge.ExpiresAt.IsZero() {
+		return nil
+	}
+	t := charge.ExpiresAt
+	return &t
+}
+
+// merchantID is the merchant ctx's Omise calls go to: the one set by gateway.WithMerchant, or the
+// default account.
+func merchantID(ctx context.Context) uint {
+	if id := gateway.MerchantFrom(ctx); id != 0 {
+		return id
+	}
+	return models.DefaultMerchantID
+}
+
+// SealRawPayload serializes the charge, masks cardholder PII, and encrypts it when a key is configured.
+func (s *PaymentService) SealRawPayload(charge *omise.Charge) ([]byte, error) {
+	// Mask on the struct so the charge is encoded once (no JSON round trip through a map).
+	masked, err := json.Marshal(rawpayload.MaskCharge(charge))
+	if err != nil {
+		return nil, err
+	}
+	return s.PayloadCipher.Encrypt(masked)
+}
+
+// adjustUserBalanceOnStatusTransition handles user balance adjustment logic for status transitions.
+func (s *PaymentService) adjustUserBalanceOnStatusTransition(tx *gorm.DB, charge *omise.Charge, userID *uint, prevWasSuccessful bool) error {
+	nowSuccessful := string(charge.Status) == "successful"
+	switch {
+	case !prevWasSuccessful && nowSuccessful:
+		return s.creditCharge(tx, charge.ID, charge.Amount, charge.Currency, *userID)
+	case prevWasSuccessful && !nowSuccessful:
+		// optional: debit if a previously successful charge became non-successful (reversal/refund)
+		// uncomment if your product requires it; consider partial refunds.
+		/*
+			amountTHB := money.New(charge.Amount, charge.Currency).Major()
+			if err := s.Users.WithTx(tx).Debit(*userID, amountTHB); err != nil {
+				log.Printf("Failed to debit user balance: %v", err)
+				return err
+			}
+		*/
+	}
+	return nil
+}
+
+// creditCharge credits userID's wallet for the successful charge chargeID of amount minor units of
+// currency, and records the credit in the audit log, in tx. A charge is credited once: one that was
+// successful before keeps its credit when it leaves successful, and is not credited on coming back.
+func (s *PaymentService) creditCharge(tx *gorm.DB, chargeID string, amount int64, currency string, userID uint) error {
+	amountTHB, ok := s.walletTHB(amount, currency)
+	if !ok {
+		// CreateCharge refuses these; a rate removed since is the only way here. Keep the transaction.
+		log.Printf("credit: charge=%s in %s not credited to user=%d: no wallet exchange rate", chargeID, currency, userID)
+		return nil
+	}
+	credited, err := s.Users.WithTx(tx).CreditCharge(userID, chargeID, amountTHB)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		// Charges may carry a user id that has no wallet here; keep the transaction regardless.
+		log.Printf("credit: user=%d not found for charge=%s", userID, chargeID)
+		return nil
+	case err != nil:
+		log.Printf("Failed to credit user balance: %v", err)
+		return err
+	case !credited:
+		log.Printf("credit: charge=%s was credited before", chargeID)
+		return nil
+	}
+	return tx.Create(systemAudit(models.AuditBalanceCredit, "user", fmt.Sprintf("%d", userID), nil,
+		map[string]interface{}{"charge_id": chargeID, "credited_thb": amountTHB, "currency": currency, "status": "successful"})).Error
+}
+
+// walletTHB converts minor units of currency to the THB a wallet is credited or debited for them, at
+// WalletFXRates and rounded to the satang; false if currency has no rate.
+func (s *PaymentService) walletTHB(amount int64, currency string) (float64, bool) {
+	m := money.New(amount, currency)
+	if m.Currency == money.THB {
+		return m.Major(), true
+	}
+	rate, ok := s.WalletFXRates[m.Currency]
+	if !ok || rate <= 0 {
+		return 0, false
+	}
+	return money.FromMajor(m.Major()*rate, money.THB).Major(), true
+}
+
+// (helper for RecordCharge) audit entry for a change made by the service itself; nil before/after is omitted.
+func systemAudit(action, entityType, entityID string, before, after interface{}) *models.AuditLog {
+	entry := &models.AuditLog{
+		Actor:      "system:payments",
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+	}
+	if before != nil {
+		if raw, err := json.Marshal(before); err == nil {
+			entry.Before = datatypes.JSON(raw)
+		}
+	}
+	if raw, err := json.Marshal(after); err == nil {
+		entry.After = datatypes.JSON(raw)
+	}
+	return entry
+}
+
+func determineChannel(charge *omise.Charge) string {
+	if charge == nil {
+		return "card"
+	}
+	if charge.Source != nil && charge.Source.Type != "" {
+		return charge.Source.Type
+	}
+	return "card"
+}
+
+// (helper for RecordCharge) the card brand, last digits and issuing bank of a card charge, or the bank
+// code of a banking source (internet_banking_bbl -> "bbl").
+func paymentDetails(charge *omise.Charge) (brand, lastDigits, bank string) {
+	if charge.Card != nil {
+		return charge.Card.Brand, charge.Card.LastDigits, charge.Card.Bank
+	}
+	if charge.Source != nil {
+		for _, prefix := range []string{"internet_banking_", "mobile_banking_"} {
+			if code, ok := strings.CutPrefix(charge.Source.Type, prefix); ok {
+				return "", "", code
+			}
+		}
+	}
+	return "", "", ""
+}
+
+// (helper for RecordCharge) the explicit user id, else metadata.user_id set at charge creation.
+func extractUserIDFromCharge(charge *omise.Charge, userID *uint) *uint {
+	if userID != nil {
+		return userID
+	}
+	return metadataID(charge, "user_id")
+}
+
+// (helper for RecordCharge) the created_at of the charge's row: the recorded one when there is a row,
+// since the table is partitioned by it, otherwise when the provider created the charge.
+func chargeCreatedAt(charge *omise.Charge, prev *models.Transaction) time.Time {
+	if prev != nil {
+		return prev.CreatedAt
+	}
+	if !charge.CreatedAt.IsZero() {
+		return charge.CreatedAt
+	}
+	return time.Now()
+}
+
+// (helper for RecordCharge) an id (user, order) stored under key in the charge metadata, nil if absent.
+func metadataID(charge *omise.Charge, key string) *uint {
+	if charge == nil || charge.Metadata == nil {
+		return nil
+	}
+	switch v := charge.Metadata[key].(type) {
+	case string:
+		if n, err := strconv.ParseUint(v, 10, 32); err == nil {
+			u := uint(n)
+			return &u
+		}
+	case float64:
+		u := uint(v)
+		return &u
+	}
+	return nil
+}
+
+// (helper for RecordCharge) an amount in satang stored under key in the charge metadata, 0 if absent.
+func metadataSatang(charge *omise.Charge, key string) int64 {
+	if charge == nil || charge.Metadata == nil {
+		return 0
+	}
+	switch v := charge.Metadata[key].(type) {
+	case string:
+		n, _ := strconv.ParseInt(v, 10, 64)
+		return n
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
+
+// (helper for RecordCharge) a count or score stored under key in the charge metadata, 0 if absent.
+func metadataInt(charge *omise.Charge, key string) int {
+	if charge == nil || charge.Metadata == nil {
+		return 0
+	}
+	switch v := charge.Metadata[key].(type) {
+	case string:
+		n, _ := strconv.Atoi(v)
+		return n
+	case float64:
+		return int(v)
+	}
+	return 0
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/a2n2k3p4/tutorium-backend/gateway/gatewaytest"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/repository/repotest"
+	omise "github.com/omise/omise-go"
+)
+
+// ledgerTest is a PaymentService recording charges into in-memory repositories.
+type ledgerTest struct {
+	*PaymentService
+	transactions *repotest.Transactions
+	users        *repotest.Users
+	orders       *repotest.Orders
+}
+
+func newLedgerTest(t *testing.T, users ...models.User) ledgerTest {
+	t.Helper()
+	s := NewPaymentService(repotest.NewDB(), gatewaytest.NewFake())
+	l := ledgerTest{PaymentService: s, transactions: repotest.NewTransactions(), users: repotest.NewUsers(users...), orders: repotest.NewOrders()}
+	s.Transactions, s.Users, s.Orders = l.transactions, l.users, l.orders
+	s.VATRateBps = 0 // tax invoices number from a Postgres sequence
+	return l
+}
+
+// (helper for ledger tests) a charge as Omise reports it.
+func testCharge(id string, status omise.ChargeStatus, amount int64, metadata map[string]interface{}) *omise.Charge {
+	return &omise.Charge{Base: omise.Base{Object: "charge", ID: id}, Status: status, Amount: amount, Currency: "thb", Metadata: metadata}
+}
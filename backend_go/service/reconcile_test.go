@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/gateway/gatewaytest"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	omise "github.com/omise/omise-go"
+	"github.com/omise/omise-go/operations"
+)
+
+func TestCompareCharge(t *testing.T) {
+	ch := &omise.Charge{Status: omise.ChargePending, Amount: 10000, Currency: "thb"}
+	cases := []struct {
+		name string
+		t    models.Transaction
+		want string
+	}{
+		{"agree", models.Transaction{Status: "pending", AmountSatang: 10000, Currency: "THB"}, ""},
+		{"expired locally", models.Transaction{Status: StatusExpired, AmountSatang: 10000, Currency: "thb"}, ""},
+		{"stale status", models.Transaction{Status: "failed", AmountSatang: 10000, Currency: "thb"}, DiscrepancyStatus},
+		{"amount", models.Transaction{Status: "failed", AmountSatang: 9000, Currency: "thb"}, DiscrepancyAmount},
+		{"currency", models.Transaction{Status: "pending", AmountSatang: 10000, Currency: "usd"}, DiscrepancyAmount},
+	}
+	for _, tc := range cases {
+		if got := compareCharge(tc.t, ch); got != tc.want {
+			t.Errorf("%s: compareCharge = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestListChargesThroughStub(t *testing.T) {
+	srv := gatewaytest.NewServer(t)
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	now := day.Add(-time.Hour)
+	srv.Fake.Now = func() time.Time { return now }
+	var ids []string
+	for i := 0; i < 4; i++ {
+		ch, err := srv.Fake.CreateCharge(context.Background(), &operations.CreateCharge{Amount: 10000, Currency: "thb", Card: "tokn_test"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, ch.ID)
+		now = now.Add(time.Hour)
+	}
+
+	gw := srv.Gateway(t)
+	var got []string
+	for offset := 0; ; offset += 2 {
+		page, err := gw.ListCharges(context.Background(), &operations.ListCharges{List: operations.List{Offset: offset, Limit: 2, From: day, Order: omise.Chronological}})
+		if err != nil {
+			t.Fatalf("ListCharges: %v", err)
+		}
+		for _, ch := range page.Data {
+			got = append(got, ch.ID)
+		}
+		if offset+len(page.Data) >= page.Total {
+			break
+		}
+	}
+	want := ids[1:] // the first was created before day
+	if len(got) != len(want) {
+		t.Fatalf("listed %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("listed %v, want %v", got, want)
+			break
+		}
+	}
+}
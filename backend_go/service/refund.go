@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/provider"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	omise "github.com/omise/omise-go"
+	"gorm.io/gorm"
+)
+
+// RefundChargeInput refunds a recorded charge. TransactionID is the internal id or the charge id;
+// AmountSatang 0 refunds whatever has not been refunded yet. Actor and Reason go to the audit log.
+type RefundChargeInput struct {
+	TransactionID string
+	AmountSatang  int64
+	Reason        string
+	Actor         string // e.g. "service:booking"; empty records "system:payments"
+}
+
+// RefundChargeResult is the refund the provider created and the charge's refund total including it.
+type RefundChargeResult struct {
+	Refund         *provider.Refund
+	AmountSatang   int64
+	RefundedSatang int64
+	Transaction    *models.Transaction
+}
+
+// RefundCharge refunds (part of) a successful charge and records it: the amount is taken out of the
+// owner's balance and an AuditRefund entry is written. The amount is held before calling Omise and
+// released if the refund fails, so a wallet that was already spent cannot be refunded.
+//
+// Errors: repository.ErrNotFound for an unknown transaction, *InputError for refunds refused before
+// calling Omise, *omise.Error for Omise rejections, anything else is a DB or transport failure.
+func (s *PaymentService) RefundCharge(ctx context.Context, in RefundChargeInput) (*RefundChargeResult, error) {
+	txn, err := s.Transactions.WithContext(ctx).Find(in.TransactionID)
+	if err != nil {
+		return nil, err
+	}
+	if txn.Status != string(omise.ChargeSuccessful) {
+		return nil, invalidInput("charge_not_refundable", "only successful charges can be refunded, charge %s is %s", txn.ChargeID, txn.Status)
+	}
+	refunded, err := s.refundedSatang(ctx, txn.ID)
+	if err != nil {
+		return nil, err
+	}
+	remaining := txn.AmountSatang - refunded
+	amount := in.AmountSatang
+	if amount == 0 {
+		amount = remaining
+	}
+	switch {
+	case amount < 0:
+		return nil, invalidInput("invalid_refund_request", "refund amount must be positive, got %d", amount)
+	case remaining <= 0:
+		return nil, invalidInput("refund_exceeds_charge", "charge %s is already fully refunded", txn.ChargeID)
+	case amount > remaining:
+		return nil, invalidInput("refund_exceeds_charge", "refund of %d exceeds the %d satang not yet refunded", amount, remaining)
+	}
+
+	// The refund leaves the owner's wallet; an unknown owner (no wallet here) is refunded regardless.
+	// Charges in other currencies are debited at today's rate, as they were credited at their day's.
+	thb, ok := s.walletTHB(amount, txn.Currency)
+	if !ok && txn.UserID != nil {
+		return nil, invalidInput("currency_not_supported", "no wallet exchange rate for %s to debit user %d", strings.ToUpper(txn.Currency), *txn.UserID)
+	}
+	held := false
+	if txn.UserID != nil {
+		err := s.Users.WithContext(ctx).Hold(*txn.UserID, thb)
+		switch {
+		case errors.Is(err, repository.ErrInsufficientBalance):
+			return nil, invalidInput("insufficient_balance", "user %d no longer has %.2f THB to refund", *txn.UserID, thb)
+		case errors.Is(err, repository.ErrNotFound):
+		case err != nil:
+			return nil, err
+		default:
+			held = true
+		}
+	}
+
+	refund, err := s.Refund(ctx, RefundInput{
+		MerchantID:   txn.MerchantID,
+		ChargeID:     txn.ChargeID,
+		AmountSatang: amount,
+		Metadata:     map[string]interface{}{"transaction_id": fmt.Sprintf("%d", txn.ID)},
+	})
+	if err != nil {
+		if held {
+			// The caller's deadline may be what failed the refund; the hold must be released anyway.
+			releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordTimeout)
+			defer cancel()
+			if uerr := s.Users.WithContext(releaseCtx).ReleaseHold(*txn.UserID, thb); uerr != nil {
+				log.Printf("refund: failed to release the %.2f THB held from user %d: %v", thb, *txn.UserID, uerr)
+			}
+		}
+		return nil, err
+	}
+
+	// The money has left on Omise, so the record is written even if the caller's deadline has passed.
+	entityID := fmt.Sprintf("%d", txn.ID)
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordTimeout)
+	defer cancel()
+	err = dbutil.Transaction(s.DB.WithContext(recordCtx), "record_refund", func(tx *gorm.DB) error {
+		if held {
+			if err := s.Users.WithTx(tx).CaptureHold(*txn.UserID, thb); err != nil {
+				return err
+			}
+			debit := systemAudit(models.AuditBalanceDebit, "user", fmt.Sprintf("%d", *txn.UserID), nil,
+				map[string]interface{}{"refund_id": refund.ID, "debited_thb": thb, "transaction_id": txn.ID})
+			debit.Actor, debit.Reason = refundActor(in.Actor), in.Reason
+			if err := tx.Create(debit).Error; err != nil {
+				return err
+			}
+		}
+		entry := systemAudit(models.AuditRefund, "transaction", entityID, nil,
+			map[string]interface{}{"refund_id": refund.ID, "amount_satang": amount})
+		entry.Actor, entry.Reason = refundActor(in.Actor), in.Reason
+		return tx.Create(entry).Error
+	})
+	if err != nil {
+		// The money already left on Omise; the reconciliation checks will flag the missing record.
+		log.Printf("refund: %s refunded on Omise (%s) but not recorded: %v", txn.ChargeID, refund.ID, err)
+		return nil, err
+	}
+	return &RefundChargeResult{Refund: refund, AmountSatang: amount, RefundedSatang: refunded + amount, Transaction: txn}, nil
+}
+
+// (helper for RefundCharge) total refunded on a transaction so far; every refund writes an AuditRefund
+// entry with after.amount_satang.
+func (s *PaymentService) refundedSatang(ctx context.Context, transactionID uint) (int64, error) {
+	var refunded int64
+	err := s.DB.WithContext(ctx).Model(&models.AuditLog{}).
+		Select("COALESCE(SUM((after->>'amount_satang')::bigint), 0)").
+		Where("action = ? AND entity_type = ? AND entity_id = ?", models.AuditRefund, "transaction", fmt.Sprintf("%d", transactionID)).
+		Scan(&refunded).Error
+	return refunded, err
+}
+
+// (helper for RefundCharge) the audit actor for a refund requested by actor.
+func refundActor(actor string) string {
+	if actor == "" {
+		return "system:payments"
+	}
+	return actor
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/money"
+	"github.com/a2n2k3p4/tutorium-backend/risk"
+	omise "github.com/omise/omise-go"
+)
+
+// ErrRiskDenied is returned by CreateCharge when the Risk evaluator denies the charge.
+var ErrRiskDenied = errors.New("charge denied by risk assessment")
+
+// riskMetadataKeys carry a charge's risk.Assessment in its Omise metadata, to the transaction.
+var riskMetadataKeys = []string{"risk_decision", "risk_score", "risk_reasons"}
+
+// (helper for CreateCharge) assess req with s.Risk and note the assessment in req's metadata, which
+// RecordCharge copies to the transaction. A denied charge is audited and refused with ErrRiskDenied;
+// flagged ones may have to pass 3-D Secure (SecureCards). Without an evaluator, or when it fails, the charge goes ahead unassessed. Clients cannot set the assessment
+// themselves: its metadata keys are dropped from req first.
+func (s *PaymentService) assessRisk(ctx context.Context, req *models.PaymentRequest, userID *uint) error {
+	for _, key := range riskMetadataKeys {
+		delete(req.Metadata, key)
+	}
+	if s.Risk == nil {
+		return nil
+	}
+	in := risk.Input{
+		UserID:      userID,
+		Amount:      req.Amount,
+		Currency:    strings.ToLower(req.Currency),
+		AmountTHB:   req.Amount,
+		PaymentType: req.PaymentType,
+		IP:          req.ClientIP,
+	}
+	if thb, ok := s.walletTHB(req.Amount, req.Currency); ok {
+		in.AmountTHB = money.FromMajor(thb, money.THB).Amount
+	}
+	if userID != nil {
+		now := time.Now()
+		volumes, err := s.Transactions.WithContext(ctx).ChargeVelocity(*userID, now.Add(-24*time.Hour), now.Add(-time.Hour))
+		if err != nil {
+			return fmt.Errorf("risk history: %w", err)
+		}
+		for _, v := range volumes {
+			in.ChargesLastHour += v.Recent
+			in.ChargesLastDay += v.Charges
+			in.FailuresLastDay += v.Failed
+		}
+	}
+	a, err := s.Risk.Evaluate(ctx, in)
+	if err != nil {
+		log.Printf("risk: %s failed, charging unassessed: %v", s.Risk.Name(), err)
+		return nil
+	}
+
+	if a.Decision == risk.Deny {
+		entityID := ""
+		if userID != nil {
+			entityID = fmt.Sprintf("%d", *userID)
+		}
+		log.Printf("risk: charge denied user=%s score=%d reasons=%v", entityID, a.Score, a.Reasons)
+		if s.DB == nil {
+			return ErrRiskDenied
+		}
+		if err := s.DB.WithContext(ctx).Create(systemAudit(models.AuditRiskDeny, "user", entityID, nil,
+			map[string]interface{}{"assessment": a, "amount": money.New(req.Amount, req.Currency), "ip": req.ClientIP})).Error; err != nil {
+			log.Printf("risk: audit failed: %v", err)
+		}
+		return ErrRiskDenied
+	}
+	if req.Metadata == nil {
+		req.Metadata = make(map[string]interface{})
+	}
+	req.Metadata["risk_decision"] = a.Decision
+	req.Metadata["risk_score"] = strconv.Itoa(a.Score)
+	if len(a.Reasons) > 0 {
+		req.Metadata["risk_reasons"] = strings.Join(a.Reasons, ",")
+	}
+	return nil
+}
+
+// (helper for requires3DS) whether req was flagged by assessRisk.
+func riskFlagged(req models.PaymentRequest) bool {
+	return req.Metadata["risk_decision"] == risk.Flag
+}
+
+// (helper for RecordCharge) a string metadata value of charge; "" if missing.
+func metadataString(charge *omise.Charge, key string) string {
+	if charge == nil || charge.Metadata == nil {
+		return ""
+	}
+	v, _ := charge.Metadata[key].(string)
+	return v
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/money"
+)
+
+// SecureCardPolicy decides which card charges must be authenticated with 3-D Secure (CARD_3DS_*),
+// which moves the fraud liability of a charge to the card issuer. Such charges need a return_uri and
+// are pending until the payer comes back from their bank.
+type SecureCardPolicy struct {
+	// MinAmountSatang, THB satang (other currencies converted at WalletFXRates); 0 disables.
+	MinAmountSatang int64
+	// RiskFlagged secures the charges the Risk evaluator flags.
+	RiskFlagged bool
+}
+
+// requires3DS reports whether req, assessed by assessRisk, is a card charge that s.SecureCards sends
+// through 3-D Secure.
+func (s *PaymentService) requires3DS(req models.PaymentRequest) bool {
+	if req.PaymentType != "credit_card" {
+		return false
+	}
+	p := s.SecureCards
+	if p.RiskFlagged && riskFlagged(req) {
+		return true
+	}
+	if p.MinAmountSatang <= 0 {
+		return false
+	}
+	thb, ok := s.walletTHB(req.Amount, req.Currency)
+	return ok && money.FromMajor(thb, money.THB).Amount >= p.MinAmountSatang
+}
+
+// (helper for CreateCharge) an InputError when req must go through 3-D Secure but cannot come back.
+func (s *PaymentService) checkSecureCard(req models.PaymentRequest) error {
+	if req.ReturnURI == "" && s.requires3DS(req) {
+		return invalidInput("authentication_required", "this payment must be verified with 3-D Secure; send return_uri")
+	}
+	return nil
+}
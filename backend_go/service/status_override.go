@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+	omise "github.com/omise/omise-go"
+	"gorm.io/gorm"
+)
+
+// FailureCodeOverride is the failure code of a transaction an admin set to a non-successful status.
+const FailureCodeOverride = "status_override"
+
+// OverridableStatuses are the statuses OverrideStatus sets.
+var OverridableStatuses = []string{
+	string(omise.ChargePending), string(omise.ChargeSuccessful), string(omise.ChargeFailed),
+	StatusExpired, StatusCanceled, string(omise.ChargeReversed),
+}
+
+// OverrideStatus sets the status of the transaction of chargeID by hand, for what Omise cannot report,
+// e.g. a bank transfer confirmed offline. It is recorded like a status Omise reported: a status-history
+// event (models.TransactionEventManual) and status_change audit entry, and on becoming successful the
+// tax invoice, order, payment link, coupon and wallet credit of RecordCharge, then OnChargeSucceeded.
+// Like RecordCharge it never debits: a transaction leaving successful keeps its credit, and is not
+// credited again should it be set successful once more. From then on RecordCharge keeps the
+// overridden status whatever Omise reports, until the next override.
+//
+// by is the admin's audit entry (Actor, IP and Reason); it is written with the change as
+// models.AuditStatusOverride. It returns the transaction as saved.
+//
+// Errors: *InputError invalid_status for a status not in OverridableStatuses, status_unchanged when the
+// transaction already has it; repository.ErrNotFound.
+func (s *PaymentService) OverrideStatus(ctx context.Context, chargeID, status string, by models.AuditLog) (*models.Transaction, error) {
+	if !overridable(status) {
+		return nil, invalidInput("invalid_status", "status must be one of %v", OverridableStatuses)
+	}
+	var (
+		saved            models.Transaction
+		becameSuccessful bool
+		becameFailed     bool
+	)
+	err := dbutil.Transaction(s.DB.WithContext(ctx), "override_transaction_status", func(tx *gorm.DB) error {
+		t, err := s.Transactions.WithTx(tx).LockByChargeID(chargeID)
+		if err != nil {
+			return err
+		}
+		if t.Status == status {
+			return invalidInput("status_unchanged", "transaction %d is already %s", t.ID, status)
+		}
+		oldStatus := t.Status
+		becameSuccessful = status == string(omise.ChargeSuccessful)
+		becameFailed = status == string(omise.ChargeFailed)
+
+		t.Status, t.FailureCode, t.FailureMessage = status, nil, nil
+		if !becameSuccessful && status != string(omise.ChargePending) {
+			code, msg := FailureCodeOverride, "status set by an administrator"
+			t.FailureCode, t.FailureMessage = &code, &msg
+		}
+		transactions := s.Transactions.WithTx(tx)
+		if err := transactions.UpdateStatus(t); err != nil {
+			return err
+		}
+		if err := RecordStatusEvent(transactions, *t, oldStatus, models.TransactionEventManual); err != nil {
+			return err
+		}
+		before := map[string]interface{}{"status": oldStatus}
+		after := map[string]interface{}{"status": t.Status, "charge_id": t.ChargeID, "failure_code": t.FailureCode}
+		if err := tx.Create(systemAudit(models.AuditStatusChange, "transaction", fmt.Sprintf("%d", t.ID), before, after)).Error; err != nil {
+			return err
+		}
+		entry := systemAudit(models.AuditStatusOverride, "transaction", fmt.Sprintf("%d", t.ID), before, after)
+		entry.Actor, entry.IP, entry.Reason = by.Actor, by.IP, by.Reason
+		if err := tx.Create(entry).Error; err != nil {
+			return err
+		}
+
+		if becameSuccessful {
+			if err := s.applySuccess(tx, t); err != nil {
+				return err
+			}
+		}
+		saved = *t
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.TransactionCache.Invalidate(ctx, saved)
+	s.Updates.Publish(saved)
+	if becameSuccessful && s.OnChargeSucceeded != nil {
+		s.OnChargeSucceeded(saved.ID)
+	}
+	if becameFailed && s.OnChargeFailed != nil {
+		s.OnChargeFailed(saved.ID)
+	}
+	return &saved, nil
+}
+
+// (helper for OverrideStatus) what RecordCharge does for a transaction becoming successful.
+func (s *PaymentService) applySuccess(tx *gorm.DB, t *models.Transaction) error {
+	if t.TaxInvoiceNumber == nil && s.VATRateBps > 0 {
+		if err := issueTaxInvoice(tx, t, s.VATRateBps, time.Now()); err != nil {
+			return err
+		}
+	}
+	if t.OrderID != nil {
+		if err := s.markOrderPaid(tx, *t); err != nil {
+			return err
+		}
+	}
+	if t.PaymentLinkID != nil {
+		if err := markPaymentLinkPaid(tx, *t); err != nil {
+			return err
+		}
+	}
+	if t.CouponID != nil {
+		if err := redeemCoupon(tx, *t); err != nil {
+			return err
+		}
+	}
+	if t.UserID == nil {
+		return nil
+	}
+	return s.creditCharge(tx, t.ChargeID, t.AmountSatang, t.Currency, *t.UserID)
+}
+
+// statusOverridden reports whether the latest status of transaction id was set by OverrideStatus.
+func statusOverridden(transactions repository.TransactionRepository, id uint) (bool, error) {
+	source, err := transactions.LatestEventSource(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	return source == models.TransactionEventManual, err
+}
+
+func overridable(status string) bool {
+	for _, s := range OverridableStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/a2n2k3p4/tutorium-backend/gateway/gatewaytest"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/repository/repotest"
+	omise "github.com/omise/omise-go"
+	"gorm.io/gorm"
+)
+
+func TestOverrideStatusRejectsUnknownStatus(t *testing.T) {
+	s := NewPaymentService(nil, gatewaytest.NewFake())
+	_, err := s.OverrideStatus(context.Background(), "chrg_test_1", "paid", models.AuditLog{Actor: "admin:ops"})
+	var inErr *InputError
+	if !errors.As(err, &inErr) || inErr.Code != "invalid_status" {
+		t.Fatalf("err = %v, want InputError invalid_status", err)
+	}
+	for _, status := range OverridableStatuses {
+		if !overridable(status) {
+			t.Errorf("%s is not overridable", status)
+		}
+	}
+}
+
+func TestOverrideStatusCreditsOnce(t *testing.T) {
+	l := newLedgerTest(t, models.User{Model: gorm.Model{ID: 7}})
+	ctx := context.Background()
+	admin := models.AuditLog{Actor: "admin:ops", Reason: "transfer confirmed by the bank"}
+	md := map[string]interface{}{"user_id": "7"}
+	if err := l.RecordCharge(ctx, testCharge("chrg_test_override_1", omise.ChargePending, 50000, md), nil); err != nil {
+		t.Fatal(err)
+	}
+	balance := func() float64 {
+		u, _ := l.users.Get(7)
+		return u.Balance
+	}
+
+	saved, err := l.OverrideStatus(ctx, "chrg_test_override_1", string(omise.ChargeSuccessful), admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Status != string(omise.ChargeSuccessful) || balance() != 500 {
+		t.Fatalf("after the override: status %s, balance %.2f; want successful and 500.00", saved.Status, balance())
+	}
+
+	// Out of successful and back: the credit stays, and is not made again.
+	for _, status := range []string{string(omise.ChargeFailed), string(omise.ChargeSuccessful)} {
+		if _, err := l.OverrideStatus(ctx, "chrg_test_override_1", status, admin); err != nil {
+			t.Fatalf("override to %s: %v", status, err)
+		}
+	}
+	if balance() != 500 {
+		t.Errorf("balance after overriding to failed and back = %.2f, want 500.00", balance())
+	}
+
+	// Omise reporting the charge successful later does not credit it either.
+	if err := l.RecordCharge(ctx, testCharge("chrg_test_override_1", omise.ChargeSuccessful, 50000, md), nil); err != nil {
+		t.Fatal(err)
+	}
+	if balance() != 500 {
+		t.Errorf("balance after the webhook = %.2f, want 500.00", balance())
+	}
+	var inErr *InputError
+	if _, err := l.OverrideStatus(ctx, "chrg_test_override_1", string(omise.ChargeSuccessful), admin); !errors.As(err, &inErr) || inErr.Code != "status_unchanged" {
+		t.Errorf("override to the status it has: err = %v, want InputError status_unchanged", err)
+	}
+}
+
+func TestRecordChargeKeepsOverriddenStatus(t *testing.T) {
+	l := newLedgerTest(t, models.User{Model: gorm.Model{ID: 7}})
+	ctx := context.Background()
+	md := map[string]interface{}{"user_id": "7"}
+	if err := l.RecordCharge(ctx, testCharge("chrg_test_override_2", omise.ChargePending, 50000, md), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.OverrideStatus(ctx, "chrg_test_override_2", string(omise.ChargeFailed), models.AuditLog{Actor: "admin:ops", Reason: "payer disputed it"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.RecordCharge(ctx, testCharge("chrg_test_override_2", omise.ChargeSuccessful, 50000, md), nil); err != nil {
+		t.Fatal(err)
+	}
+	rows := l.transactions.All()
+	if len(rows) != 1 || rows[0].Status != string(omise.ChargeFailed) || rows[0].FailureCode == nil || *rows[0].FailureCode != FailureCodeOverride {
+		t.Fatalf("transaction after the webhook = %+v, want the overridden failed status", rows)
+	}
+	if u, _ := l.users.Get(7); u.Balance != 0 {
+		t.Errorf("balance = %.2f, want 0: the overridden charge is not credited", u.Balance)
+	}
+	events := l.transactions.Events(rows[0].ID)
+	if len(events) != 2 || events[1].Source != models.TransactionEventManual {
+		t.Errorf("status history = %+v, want the first status and the override only", events)
+	}
+}
+
+func TestRecordChargeForUnknownUserCreditsNothing(t *testing.T) {
+	l := newLedgerTest(t)
+	md := map[string]interface{}{"user_id": "99"}
+	if err := l.RecordCharge(context.Background(), testCharge("chrg_test_unknown_user", omise.ChargeSuccessful, 50000, md), nil); err != nil {
+		t.Fatal(err)
+	}
+	// The status change is audited; a credit that was not made is not.
+	audits := 0
+	for _, stmt := range repotest.Statements(l.DB) {
+		if strings.HasPrefix(stmt, `INSERT INTO "audit_logs"`) {
+			audits++
+		}
+	}
+	if audits != 1 {
+		t.Errorf("%d audit rows written, want 1 (the status change)", audits)
+	}
+	if saved, err := l.transactions.Find("chrg_test_unknown_user"); err != nil || saved.Status != string(omise.ChargeSuccessful) {
+		t.Errorf("transaction = %+v, %v; want it kept as successful", saved, err)
+	}
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/tax"
+	"gorm.io/gorm"
+)
+
+// taxZone is the time zone of tax invoice dates and monthly number series (Bangkok).
+var taxZone = time.FixedZone("ICT", 7*60*60)
+
+// (helper for RecordCharge) compute t's VAT at rateBps and give it the next tax invoice number of
+// the month, on t and on its row; t has just become successful and has no number yet. The number is
+// taken under the series' row lock in tx, so numbers are issued in commit order without gaps.
+func issueTaxInvoice(tx *gorm.DB, t *models.Transaction, rateBps int64, now time.Time) error {
+	issued := now.In(taxZone)
+	seq := models.TaxInvoiceSequence{Period: issued.Format("200601")}
+	if err := tx.Raw(`INSERT INTO tax_invoice_sequences (period, last_number, updated_at) VALUES (?, 1, ?)
+		ON CONFLICT (period) DO UPDATE SET last_number = tax_invoice_sequences.last_number + 1, updated_at = EXCLUDED.updated_at
+		RETURNING last_number`, seq.Period, now).Scan(&seq.LastNumber).Error; err != nil {
+		return err
+	}
+	_, vat := tax.SplitVAT(t.Amount(), rateBps)
+	number := tax.InvoiceNumber(issued, seq.LastNumber)
+	if err := tx.Model(&models.Transaction{}).Where("id = ?", t.ID).
+		Updates(map[string]interface{}{"vat_rate_bps": rateBps, "vat_satang": vat.Amount, "tax_invoice_number": number}).Error; err != nil {
+		return err
+	}
+	t.VATRateBps, t.VATSatang, t.TaxInvoiceNumber = rateBps, vat.Amount, &number
+	return nil
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/repository"
+)
+
+type eventSourceKey struct{}
+
+// WithEventSource returns a ctx whose status changes are recorded with source (one of the
+// models.TransactionEvent* sources) in the transaction's status history.
+func WithEventSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, eventSourceKey{}, source)
+}
+
+// EventSource returns the source WithEventSource set on ctx, models.TransactionEventSync if none.
+func EventSource(ctx context.Context) string {
+	if source, _ := ctx.Value(eventSourceKey{}).(string); source != "" {
+		return source
+	}
+	return models.TransactionEventSync
+}
+
+// RecordStatusEvent appends t's move from oldStatus to t.Status to its status history, through
+// transactions (bound to the DB transaction of the change with WithTx).
+func RecordStatusEvent(transactions repository.TransactionRepository, t models.Transaction, oldStatus, source string) error {
+	return transactions.RecordEvent(&models.TransactionEvent{
+		TransactionID: t.ID,
+		ChargeID:      t.ChargeID,
+		OldStatus:     oldStatus,
+		NewStatus:     t.Status,
+		Source:        source,
+		FailureCode:   t.FailureCode,
+	})
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"sync"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+)
+
+// transactionFeedBuffer is how many updates a slow subscriber may lag before updates are dropped for it.
+const transactionFeedBuffer = 64
+
+// TransactionFeed fans recorded transactions out to in-process subscribers (the gRPC
+// WatchTransactions stream); the zero value is ready to use. Updates are not stored or replayed.
+type TransactionFeed struct {
+	mu     sync.Mutex
+	subs   map[chan models.Transaction]struct{}
+	closed bool
+}
+
+// Publish hands t to every subscriber without blocking; a full subscriber misses it.
+func (f *TransactionFeed) Publish(t models.Transaction) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for ch := range f.subs {
+		select {
+		case ch <- t:
+		default:
+		}
+	}
+}
+
+// Subscribe registers a subscriber. The channel is closed by cancel or by Close; ok is false once the
+// feed has been closed for shutdown.
+func (f *TransactionFeed) Subscribe() (updates <-chan models.Transaction, cancel func(), ok bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil, nil, false
+	}
+	if f.subs == nil {
+		f.subs = make(map[chan models.Transaction]struct{})
+	}
+	ch := make(chan models.Transaction, transactionFeedBuffer)
+	f.subs[ch] = struct{}{}
+	return ch, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if _, ok := f.subs[ch]; ok {
+			delete(f.subs, ch)
+			close(ch)
+		}
+	}, true
+}
+
+// Close ends every subscription and refuses new subscribers.
+func (f *TransactionFeed) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	for ch := range f.subs {
+		delete(f.subs, ch)
+		close(ch)
+	}
+}
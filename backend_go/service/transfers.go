@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/dbutil"
+	"github.com/a2n2k3p4/tutorium-backend/gateway"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	omise "github.com/omise/omise-go"
+	"github.com/omise/omise-go/operations"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ImportTransfer records (or updates) an Omise transfer of the merchant in ctx, as its transfer webhooks
+// and SyncTransfers read it. Its charges are matched later, by MatchTransfers.
+func (s *PaymentService) ImportTransfer(ctx context.Context, tr *omise.Transfer) error {
+	row := models.OmiseTransfer{
+		MerchantID:    merchantID(ctx),
+		TransferID:    tr.ID,
+		AmountSatang:  tr.Amount,
+		FeeSatang:     tr.Fee,
+		Currency:      strings.ToUpper(tr.Currency),
+		Sent:          tr.Sent,
+		Paid:          tr.Paid,
+		FailureCode:   tr.FailureCode,
+		TransferredAt: tr.CreatedAt,
+	}
+	return dbutil.Retry("import_transfer", func() error {
+		return s.DB.WithContext(ctx).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "transfer_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"amount_satang", "fee_satang", "sent", "paid", "failure_code", "updated_at"}),
+		}).Create(&row).Error
+	})
+}
+
+// SyncTransfers imports every transfer each merchant's account (Merchants) made since since, newest
+// first, then matches their charges (MatchTransfers). It returns how many transfers were imported and
+// how many got their charges matched.
+func (s *PaymentService) SyncTransfers(ctx context.Context, since time.Time) (imported, matched int, err error) {
+	merchants := s.Merchants
+	if len(merchants) == 0 {
+		merchants = []uint{models.DefaultMerchantID}
+	}
+	for _, id := range merchants {
+		mctx := gateway.WithMerchant(ctx, id)
+	pages:
+		for offset := 0; ; offset += omiseListPage {
+			if ctx.Err() != nil {
+				return imported, 0, ctx.Err()
+			}
+			page, err := s.Omise.ListTransfers(mctx, &operations.ListTransfers{List: operations.List{
+				Offset: offset, Limit: omiseListPage, Order: omise.ReverseChronological,
+			}})
+			if err != nil {
+				return imported, 0, fmt.Errorf("merchant %d: list transfers at offset %d: %w", id, offset, err)
+			}
+			for _, tr := range page.Data {
+				if tr.CreatedAt.Before(since) {
+					break pages
+				}
+				if err := s.ImportTransfer(mctx, tr); err != nil {
+					return imported, 0, fmt.Errorf("merchant %d: import transfer %s: %w", id, tr.ID, err)
+				}
+				imported++
+			}
+			if len(page.Data) == 0 || offset+len(page.Data) >= page.Total {
+				break
+			}
+		}
+	}
+	matched, err = s.MatchTransfers(ctx)
+	return imported, matched, err
+}
+
+// MatchTransfers assigns charges to the sent transfers not matched yet, oldest first: a transfer pays
+// out every successful charge of its account and currency that became transferable before it was made
+// and is not in an earlier transfer. A transfer waits while a charge of its account created before it
+// has no fees synced (so no transferable time), and so do the account's later transfers. It returns how
+// many transfers were matched.
+func (s *PaymentService) MatchTransfers(ctx context.Context) (int, error) {
+	var pending []models.OmiseTransfer
+	if err := s.DB.WithContext(ctx).Where("matched_at IS NULL AND sent AND failure_code IS NULL").
+		Order("transferred_at, id").Find(&pending).Error; err != nil {
+		return 0, err
+	}
+	matched := 0
+	waiting := map[uint]bool{} // merchants with a transfer that cannot be matched yet
+	for i := range pending {
+		tr := &pending[i]
+		if ctx.Err() != nil {
+			return matched, ctx.Err()
+		}
+		if waiting[tr.MerchantID] {
+			continue
+		}
+		var unsynced int64
+		if err := s.DB.WithContext(ctx).Model(&models.Transaction{}).
+			Where("merchant_id = ? AND status = ? AND fees_synced_at IS NULL AND created_at < ?", tr.MerchantID, "successful", tr.TransferredAt).
+			Count(&unsynced).Error; err != nil {
+			return matched, err
+		}
+		if unsynced > 0 {
+			log.Printf("transfers: transfer=%s waits for the fees of %d charges", tr.TransferID, unsynced)
+			waiting[tr.MerchantID] = true
+			continue
+		}
+		if err := dbutil.Transaction(s.DB.WithContext(ctx), "match_transfer", func(tx *gorm.DB) error {
+			return matchTransfer(tx, tr)
+		}); err != nil {
+			return matched, fmt.Errorf("match transfer %s: %w", tr.TransferID, err)
+		}
+		matched++
+	}
+	return matched, nil
+}
+
+// (helper for MatchTransfers) assign tr's charges and record their count and net on it.
+func matchTransfer(tx *gorm.DB, tr *models.OmiseTransfer) error {
+	if err := tx.Model(&models.Transaction{}).
+		Where("merchant_id = ? AND status = ? AND omise_transfer_id IS NULL AND transferable_at <= ? AND UPPER(currency) = ?",
+			tr.MerchantID, "successful", tr.TransferredAt, tr.Currency).
+		Update("omise_transfer_id", tr.ID).Error; err != nil {
+		return err
+	}
+	var sums struct {
+		Charges int64
+		Net     int64
+	}
+	if err := tx.Model(&models.Transaction{}).Select("COUNT(*) AS charges, COALESCE(SUM(net_satang), 0) AS net").
+		Where("omise_transfer_id = ?", tr.ID).Scan(&sums).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.OmiseTransfer{}).Where("id = ?", tr.ID).Updates(map[string]interface{}{
+		"matched_at": time.Now(), "matched_charges": sums.Charges, "matched_net_satang": sums.Net,
+	}).Error
+}
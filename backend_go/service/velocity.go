@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/money"
+)
+
+// VelocityLimits caps what a payer may charge, against card testing and stolen cards (CHARGE_LIMIT_*).
+// Amounts are THB satang, other currencies converted at WalletFXRates; zero fields are unlimited.
+type VelocityLimits struct {
+	MaxChargeSatang     int64 // one charge
+	MaxPerHour          int   // charges a user created in the last hour, whatever became of them
+	MaxPerDay           int   // the same over the last 24 hours
+	MaxDailyTopUpSatang int64 // a user's pending and successful charges of the last 24 hours, this one included
+}
+
+// LimitError is a charge over one of the VelocityLimits. Code is a stable machine-readable reason;
+// surfaces map LimitError to "too many requests" (HTTP 429).
+type LimitError struct {
+	Code    string
+	Message string
+}
+
+func (e *LimitError) Error() string { return e.Message }
+
+// checkVelocity returns a *LimitError when charging amount of currency to userID (nil for a charge
+// without a user, which only MaxChargeSatang applies to) would exceed s.Velocity. Violations are
+// logged.
+func (s *PaymentService) checkVelocity(ctx context.Context, userID *uint, amount int64, currency string) error {
+	lim := s.Velocity
+	thb, converted := s.walletTHB(amount, currency)
+	satang := money.FromMajor(thb, money.THB).Amount
+	if lim.MaxChargeSatang > 0 && converted && satang > lim.MaxChargeSatang {
+		return velocityViolation(userID, "charge_amount_limit", "a single charge is limited to %s; this one is %s",
+			money.New(lim.MaxChargeSatang, money.THB), money.New(amount, currency))
+	}
+	if userID == nil || (lim.MaxPerHour <= 0 && lim.MaxPerDay <= 0 && lim.MaxDailyTopUpSatang <= 0) {
+		return nil
+	}
+
+	now := time.Now()
+	volumes, err := s.Transactions.WithContext(ctx).ChargeVelocity(*userID, now.Add(-24*time.Hour), now.Add(-time.Hour))
+	if err != nil {
+		return fmt.Errorf("charge velocity: %w", err)
+	}
+	var hour, day int64
+	topUps := satang
+	for _, v := range volumes {
+		hour += v.Recent
+		day += v.Charges
+		if t, ok := s.walletTHB(v.AmountSatang, v.Currency); ok {
+			topUps += money.FromMajor(t, money.THB).Amount
+		}
+	}
+	switch {
+	case lim.MaxPerHour > 0 && hour >= int64(lim.MaxPerHour):
+		return velocityViolation(userID, "charge_rate_limit", "at most %d charges an hour; try again later", lim.MaxPerHour)
+	case lim.MaxPerDay > 0 && day >= int64(lim.MaxPerDay):
+		return velocityViolation(userID, "charge_rate_limit", "at most %d charges a day; try again tomorrow", lim.MaxPerDay)
+	case lim.MaxDailyTopUpSatang > 0 && converted && topUps > lim.MaxDailyTopUpSatang:
+		return velocityViolation(userID, "daily_top_up_limit", "top-ups are limited to %s a day; %s is left",
+			money.New(lim.MaxDailyTopUpSatang, money.THB), money.New(max(lim.MaxDailyTopUpSatang-(topUps-satang), 0), money.THB))
+	}
+	return nil
+}
+
+// (helper for checkVelocity) log the violation and return it as a *LimitError.
+func velocityViolation(userID *uint, code, format string, args ...interface{}) error {
+	err := &LimitError{Code: code, Message: fmt.Sprintf(format, args...)}
+	user := "none"
+	if userID != nil {
+		user = fmt.Sprintf("%d", *userID)
+	}
+	log.Printf("velocity: charge refused user=%s code=%s: %s", user, code, err.Message)
+	return err
+}
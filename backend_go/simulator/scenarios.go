@@ -0,0 +1,133 @@
+package simulator
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	omise "github.com/omise/omise-go"
+	"github.com/omise/omise-go/operations"
+)
+
+// chargeExpired is Omise's status for a source charge the payer never completed (omise-go has no constant).
+const chargeExpired omise.ChargeStatus = "expired"
+
+// ScenarioMetadataKey selects a Scenario by name from the charge metadata, whatever the amount.
+const ScenarioMetadataKey = "sandbox_scenario"
+
+// Scenario is a deterministic failure (or webhook quirk) a sandbox charge can be steered into with a
+// magic amount or the sandbox_scenario metadata flag, so every failure state of the UI can be built
+// and tested. Card charges get the outcome at creation and a "charge.create" webhook; PromptPay and
+// internet banking charges are created pending and settled after ScenarioDelay.
+type Scenario struct {
+	Name        string `json:"name"`
+	Amount      int64  `json:"amount"` // satang, e.g. 40901 = 409.01 THB
+	Description string `json:"description"`
+
+	// Error makes CreateCharge itself fail; nothing is created and no webhook is sent.
+	Error *omise.Error `json:"-"`
+	// Status is the charge's final status; FailureCode/FailureMessage come with failed and expired.
+	Status         omise.ChargeStatus `json:"status,omitempty"`
+	FailureCode    string             `json:"failure_code,omitempty"`
+	FailureMessage string             `json:"-"`
+	// Deliveries is how often the resulting webhook is delivered (Omise may deliver an event more than once).
+	Deliveries int `json:"webhook_deliveries,omitempty"`
+}
+
+// Scenarios are the magic amounts. Failure codes and messages are Omise's.
+var Scenarios = []Scenario{
+	{Name: "insufficient_fund", Amount: 40901, Description: "card declined: insufficient funds",
+		Status: omise.ChargeFailed, FailureCode: "insufficient_fund", FailureMessage: "insufficient funds in the account or the card has reached the credit limit"},
+	{Name: "stolen_or_lost_card", Amount: 40902, Description: "card declined: reported stolen or lost",
+		Status: omise.ChargeFailed, FailureCode: "stolen_or_lost_card", FailureMessage: "card was stolen or lost"},
+	{Name: "failed_processing", Amount: 40903, Description: "generic processing failure at the issuer",
+		Status: omise.ChargeFailed, FailureCode: "failed_processing", FailureMessage: "the payment failed to process"},
+	{Name: "payment_rejected", Amount: 40904, Description: "payment rejected by the issuer or bank",
+		Status: omise.ChargeFailed, FailureCode: "payment_rejected", FailureMessage: "the payment was rejected by the issuer or the acquirer"},
+	{Name: "invalid_security_code", Amount: 40905, Description: "card declined: wrong CVV",
+		Status: omise.ChargeFailed, FailureCode: "invalid_security_code", FailureMessage: "the security code was invalid or the card didn't pass preauth"},
+	{Name: "failed_fraud_check", Amount: 40906, Description: "card flagged by the fraud check",
+		Status: omise.ChargeFailed, FailureCode: "failed_fraud_check", FailureMessage: "card was marked as fraudulent"},
+	{Name: "payment_expired", Amount: 40907, Description: "payer never completed the payment (charge.expire)",
+		Status: chargeExpired, FailureCode: "payment_expired", FailureMessage: "payment expired"},
+	{Name: "duplicate_webhook", Amount: 40910, Description: "successful, but the webhook is delivered twice",
+		Status: omise.ChargeSuccessful, Deliveries: 2},
+	{Name: "invalid_card", Amount: 40950, Description: "charge request rejected by Omise (HTTP 400)",
+		Error: &omise.Error{StatusCode: http.StatusBadRequest, Code: "invalid_card", Message: "card was rejected: invalid_card"}},
+	{Name: "provider_unavailable", Amount: 40951, Description: "Omise is down (HTTP 503)",
+		Error: &omise.Error{StatusCode: http.StatusServiceUnavailable, Code: "service_unavailable", Message: "service is temporarily unavailable"}},
+}
+
+// (helper for CreateCharge) the scenario op asks for: the metadata flag first, then the amount.
+func scenarioFor(op *operations.CreateCharge) (Scenario, bool) {
+	name, _ := op.Metadata[ScenarioMetadataKey].(string)
+	for _, sc := range Scenarios {
+		if (name != "" && sc.Name == name) || (name == "" && sc.Amount == op.Amount) {
+			return sc, true
+		}
+	}
+	return Scenario{}, false
+}
+
+// CreateCharge is Fake.CreateCharge steered by Scenarios.
+func (s *Simulator) CreateCharge(ctx context.Context, op *operations.CreateCharge) (*omise.Charge, error) {
+	sc, ok := scenarioFor(op)
+	if !ok {
+		return s.Fake.CreateCharge(ctx, op)
+	}
+	if sc.Error != nil {
+		s.Fake.FailNext("CreateCharge", sc.Error)
+		return s.Fake.CreateCharge(ctx, op)
+	}
+	ch, err := s.Fake.CreateCharge(ctx, op)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("sandbox: charge=%s follows scenario %s", ch.ID, sc.Name)
+
+	key := "charge.complete"
+	switch {
+	case ch.Status != omise.ChargePending:
+		// Card: the outcome is in the create response, as with Omise's test cards.
+		eventID, err := s.Settle(ch.ID, "charge.create", sc.Status, sc.FailureCode, sc.FailureMessage)
+		if err != nil {
+			return nil, err
+		}
+		time.AfterFunc(s.ScenarioDelay, func() { s.deliverScenario(sc, ch.ID, eventID) })
+		return s.Charge(ch.ID), nil
+	case sc.Status == chargeExpired:
+		key = "charge.expire"
+	}
+	// Source: the frontend sees the pending state first, then the webhook settles the charge.
+	time.AfterFunc(s.ScenarioDelay, func() {
+		eventID, err := s.Settle(ch.ID, key, sc.Status, sc.FailureCode, sc.FailureMessage)
+		if err != nil {
+			log.Printf("sandbox: charge=%s scenario %s: %v", ch.ID, sc.Name, err)
+			return
+		}
+		s.deliverScenario(sc, ch.ID, eventID)
+	})
+	return ch, nil
+}
+
+// (helper for CreateCharge) deliver a scenario's event as many times as it asks for.
+func (s *Simulator) deliverScenario(sc Scenario, chargeID, eventID string) {
+	for i := 0; i < max(sc.Deliveries, 1); i++ {
+		if err := s.deliver(eventID); err != nil {
+			log.Printf("sandbox: charge=%s scenario %s event=%s: %v", chargeID, sc.Name, eventID, err)
+		}
+	}
+}
+
+// scenarios lists the magic amounts for frontend developers.
+//
+//	GET /sandbox/scenarios
+func (s *Simulator) scenarios(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"metadata_key":  ScenarioMetadataKey,
+		"delay_seconds": s.ScenarioDelay.Seconds(),
+		"scenarios":     Scenarios,
+	})
+}
@@ -0,0 +1,89 @@
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	omise "github.com/omise/omise-go"
+	"github.com/omise/omise-go/operations"
+)
+
+func TestScenarios(t *testing.T) {
+	delivered := make(chan string, 10)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var env struct{ ID string }
+		_ = json.NewDecoder(r.Body).Decode(&env)
+		delivered <- env.ID
+	}))
+	defer hook.Close()
+
+	sim := New("http://sandbox.test")
+	sim.WebhookURL = hook.URL
+	sim.ScenarioDelay = 0
+	next := func() *omise.Event {
+		t.Helper()
+		select {
+		case id := <-delivered:
+			ev, err := sim.RetrieveEvent(context.Background(), id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return ev
+		case <-time.After(5 * time.Second):
+			t.Fatal("no webhook delivered")
+			return nil
+		}
+	}
+
+	// Magic amount on a card charge: failed in the create response, then charge.create.
+	tok, _ := sim.CreateToken(context.Background(), &operations.CreateToken{Name: "Test", Number: "4242424242424242"})
+	ch, err := sim.CreateCharge(context.Background(), &operations.CreateCharge{Amount: 40901, Currency: "thb", Card: tok.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ch.Status != omise.ChargeFailed || ch.FailureCode == nil || *ch.FailureCode != "insufficient_fund" {
+		t.Fatalf("card charge = %s %v, want failed insufficient_fund", ch.Status, ch.FailureCode)
+	}
+	if ev := next(); ev.Key != "charge.create" || ev.Data.(*omise.Charge).ID != ch.ID {
+		t.Errorf("card scenario event = %s, want charge.create for %s", ev.Key, ch.ID)
+	}
+
+	// Metadata flag on a PromptPay charge: pending at first, then expired by charge.expire.
+	src, _ := sim.CreateSource(context.Background(), &operations.CreateSource{Type: "promptpay", Amount: 50000, Currency: "thb"})
+	ch, err = sim.CreateCharge(context.Background(), &operations.CreateCharge{Amount: 50000, Currency: "thb", Source: src.ID,
+		Metadata: map[string]interface{}{ScenarioMetadataKey: "payment_expired"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ch.Status != omise.ChargePending {
+		t.Fatalf("promptpay charge = %s, want pending until the webhook", ch.Status)
+	}
+	if ev := next(); ev.Key != "charge.expire" || ev.Data.(*omise.Charge).Status != chargeExpired {
+		t.Errorf("promptpay scenario event = %s %s, want charge.expire expired", ev.Key, ev.Data.(*omise.Charge).Status)
+	}
+
+	// Duplicate delivery: the same event twice.
+	if _, err := sim.CreateCharge(context.Background(), &operations.CreateCharge{Amount: 40910, Currency: "thb", Card: tok.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if a, b := next(), next(); a.ID != b.ID {
+		t.Errorf("duplicate_webhook delivered %s and %s, want the same event twice", a.ID, b.ID)
+	}
+
+	// Provider errors fail the create call itself.
+	_, err = sim.CreateCharge(context.Background(), &operations.CreateCharge{Amount: 40951, Currency: "thb", Card: tok.ID})
+	var oerr *omise.Error
+	if !errors.As(err, &oerr) || oerr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("provider_unavailable err = %v, want Omise 503", err)
+	}
+
+	// Other amounts are unaffected.
+	if ch, _ := sim.CreateCharge(context.Background(), &operations.CreateCharge{Amount: 40999, Currency: "thb", Card: tok.ID}); ch.Status != omise.ChargeSuccessful {
+		t.Errorf("ordinary amount status = %s, want successful", ch.Status)
+	}
+}
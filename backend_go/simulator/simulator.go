@@ -0,0 +1,253 @@
+// Package simulator is the MOCK_OMISE=true sandbox: an in-process Omise (gatewaytest.Fake) that serves
+// its own PromptPay QR codes and offsite payment pages and delivers charge.complete webhooks to our own
+// endpoint, so the frontend and CI can run the full payment flow without Omise keys.
+//
+// Ids are sequential (chrg_test_1, src_test_2, ...) and every outcome is chosen explicitly, so a run
+// against a fresh process is deterministic:
+//   - card charges succeed immediately;
+//   - PromptPay and internet banking charges stay pending until completed through
+//     POST /sandbox/charges/:id/complete or the offsite page at AuthorizeURI;
+//   - magic amounts (40901 = insufficient_fund, ...) or the sandbox_scenario metadata flag produce a
+//     specific failure code and webhook sequence instead (see Scenarios, GET /sandbox/scenarios).
+package simulator
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/apperrors"
+	"github.com/a2n2k3p4/tutorium-backend/gateway/gatewaytest"
+	"github.com/a2n2k3p4/tutorium-backend/money"
+	"github.com/gofiber/fiber/v2"
+	omise "github.com/omise/omise-go"
+)
+
+// Simulator is a gateway.OmiseGateway plus the /sandbox routes that stand in for Omise's hosted pages.
+type Simulator struct {
+	*gatewaytest.Fake
+
+	// BaseURL is how clients reach this server, e.g. "http://localhost:8080"; QR and payment page
+	// links point at it.
+	BaseURL string
+	// WebhookURL receives {"id": "<event id>"} after every completion; defaults to BaseURL + "/api/v1/webhooks/omise".
+	WebhookURL string
+	Client     *http.Client
+	// ScenarioDelay is how long after creation a Scenario's webhook is delivered (and its pending
+	// charge settled).
+	ScenarioDelay time.Duration
+}
+
+// New returns a Simulator whose links point at baseURL.
+func New(baseURL string) *Simulator {
+	baseURL = strings.TrimRight(baseURL, "/")
+	s := &Simulator{
+		Fake:       gatewaytest.NewFake(),
+		BaseURL:    baseURL,
+		WebhookURL: baseURL + "/api/v1/webhooks/omise",
+		Client:     &http.Client{Timeout: 10 * time.Second},
+
+		ScenarioDelay: 3 * time.Second,
+	}
+	s.Fake.QRCodeURL = func(sourceID string) string { return s.BaseURL + "/sandbox/sources/" + sourceID + "/qrcode.svg" }
+	s.Fake.AuthorizeURL = func(chargeID string) string { return s.BaseURL + "/sandbox/offsites/" + chargeID }
+	return s
+}
+
+// Register mounts the sandbox routes. Call it before handlers.RegisterRoutes, whose catch-all must be last.
+func (s *Simulator) Register(app fiber.Router) {
+	sb := app.Group("/sandbox")
+	sb.Get("/scenarios", s.scenarios)
+	sb.Get("/sources/:id/qrcode.svg", s.qrCode)
+	sb.Get("/charges/:id", s.getCharge)
+	sb.Post("/charges/:id/complete", s.complete)
+	sb.Get("/offsites/:id", s.offsitePage)
+	sb.Post("/offsites/:id", s.offsiteSubmit)
+}
+
+// CompleteCharge settles a pending charge and delivers its charge.complete event to WebhookURL. It
+// returns the event id; a delivery failure is returned after the charge has already been settled.
+func (s *Simulator) CompleteCharge(chargeID string, successful bool) (string, error) {
+	ch := s.Charge(chargeID)
+	if ch == nil {
+		return "", apperrors.ErrNotFound.WithMessagef("charge %s not found", chargeID)
+	}
+	if ch.Status != omise.ChargePending {
+		return "", apperrors.ErrConflict.WithCode("charge_not_pending").WithMessagef("charge is already %s", ch.Status)
+	}
+	eventID, err := s.Complete(chargeID, successful)
+	if err != nil {
+		return "", err
+	}
+	return eventID, s.deliver(eventID)
+}
+
+// (helper for CompleteCharge) POST the event envelope the way Omise does; our handler re-reads it from the gateway.
+func (s *Simulator) deliver(eventID string) error {
+	body, _ := json.Marshal(map[string]string{"id": eventID})
+	resp, err := s.Client.Post(s.WebhookURL, fiber.MIMEApplicationJSON, bytes.NewReader(body))
+	if err != nil {
+		return apperrors.ErrUnavailable.WithMessage("webhook delivery failed").Wrap(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return apperrors.ErrUnavailable.WithMessagef("webhook delivery failed: %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *Simulator) getCharge(c *fiber.Ctx) error {
+	ch := s.Charge(c.Params("id"))
+	if ch == nil {
+		return apperrors.ErrNotFound.WithMessage("charge not found")
+	}
+	return c.JSON(ch)
+}
+
+// complete is the CI/scripting hook standing in for a customer paying (or the bank rejecting) a charge.
+//
+//	POST /sandbox/charges/:id/complete {"status": "successful" | "failed"}
+func (s *Simulator) complete(c *fiber.Ctx) error {
+	var req struct {
+		Status string `json:"status"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return apperrors.ErrBadRequest.WithMessage("invalid JSON body")
+		}
+	}
+	successful, err := parseOutcome(req.Status)
+	if err != nil {
+		return err
+	}
+	eventID, err := s.CompleteCharge(c.Params("id"), successful)
+	if eventID == "" && err != nil {
+		return err
+	}
+	out := fiber.Map{"event_id": eventID, "charge": s.Charge(c.Params("id")), "webhook_delivered": err == nil}
+	if err != nil {
+		out["webhook_error"] = err.Error()
+	}
+	return c.JSON(out)
+}
+
+// (helper for complete) "" and "successful" pay the charge; "failed" rejects it.
+func parseOutcome(status string) (bool, error) {
+	switch status {
+	case "", string(omise.ChargeSuccessful):
+		return true, nil
+	case string(omise.ChargeFailed):
+		return false, nil
+	}
+	return false, apperrors.ErrValidation.WithMessagef("status must be %q or %q", omise.ChargeSuccessful, omise.ChargeFailed)
+}
+
+var offsiteTemplate = template.Must(template.New("offsite").Parse(`<!doctype html>
+<html><head><meta charset="utf-8"><title>Sandbox bank</title></head>
+<body style="font-family:sans-serif;max-width:28em;margin:3em auto">
+<h1>Sandbox bank</h1>
+<p>Charge <code>{{.ID}}</code>: <strong>{{.Amount}}</strong> ({{.Status}})</p>
+{{if .Pending}}<form method="post">
+<button name="status" value="successful">Authorize payment</button>
+<button name="status" value="failed">Reject payment</button>
+</form>{{else}}<p>This charge is no longer pending.</p>{{end}}
+<p><small>MOCK_OMISE sandbox: no money moves.</small></p>
+</body></html>`))
+
+// offsitePage stands in for the bank's authorization page behind a charge's authorize_uri.
+func (s *Simulator) offsitePage(c *fiber.Ctx) error {
+	ch := s.Charge(c.Params("id"))
+	if ch == nil {
+		return apperrors.ErrNotFound.WithMessage("charge not found")
+	}
+	var buf bytes.Buffer
+	if err := offsiteTemplate.Execute(&buf, map[string]interface{}{
+		"ID":      ch.ID,
+		"Amount":  money.New(ch.Amount, ch.Currency).String(),
+		"Status":  ch.Status,
+		"Pending": ch.Status == omise.ChargePending,
+	}); err != nil {
+		return apperrors.ErrInternal.Wrap(err)
+	}
+	c.Type("html")
+	return c.Send(buf.Bytes())
+}
+
+// offsiteSubmit completes the charge from the form and sends the customer back to return_uri, as Omise does.
+func (s *Simulator) offsiteSubmit(c *fiber.Ctx) error {
+	successful, err := parseOutcome(c.FormValue("status"))
+	if err != nil {
+		return err
+	}
+	eventID, err := s.CompleteCharge(c.Params("id"), successful)
+	if eventID == "" && err != nil {
+		return err
+	}
+	if err != nil {
+		log.Printf("sandbox: charge=%s event=%s %v", c.Params("id"), eventID, err)
+	}
+	if ch := s.Charge(c.Params("id")); ch != nil && ch.ReturnURI != "" {
+		return c.Redirect(ch.ReturnURI, fiber.StatusSeeOther)
+	}
+	return c.Redirect(s.BaseURL+"/sandbox/offsites/"+c.Params("id"), fiber.StatusSeeOther)
+}
+
+// qrCode serves a QR-shaped SVG for a PromptPay source. It is not scannable; pay it with
+// POST /sandbox/charges/:id/complete instead.
+func (s *Simulator) qrCode(c *fiber.Ctx) error {
+	if s.Source(c.Params("id")) == nil {
+		return apperrors.ErrNotFound.WithMessage("source not found")
+	}
+	c.Type("svg")
+	return c.SendString(QRCodeSVG(c.Params("id")))
+}
+
+// QRCodeSVG draws a deterministic 25x25 QR-like pattern (finder squares plus modules derived from seed).
+func QRCodeSVG(seed string) string {
+	const n, cell, quiet = 25, 8, 4
+	size := (n + 2*quiet) * cell
+
+	dark := make([][]bool, n)
+	for y := range dark {
+		dark[y] = make([]bool, n)
+	}
+	sum := sha256.Sum256([]byte(seed))
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			i := y*n + x
+			dark[y][x] = sum[(i/8)%len(sum)]>>(i%8)&1 == 1
+		}
+	}
+	for _, o := range [][2]int{{0, 0}, {n - 7, 0}, {0, n - 7}} {
+		for y := -1; y <= 7; y++ {
+			for x := -1; x <= 7; x++ {
+				px, py := o[0]+x, o[1]+y
+				if px < 0 || py < 0 || px >= n || py >= n {
+					continue
+				}
+				ring := x == 0 || x == 6 || y == 0 || y == 6
+				core := x >= 2 && x <= 4 && y >= 2 && y <= 4
+				dark[py][px] = (ring || core) && x >= 0 && x <= 6 && y >= 0 && y <= 6
+			}
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, size, size, size, size)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#ffffff"/>`, size, size)
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			if dark[y][x] {
+				fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="#000000"/>`, (x+quiet)*cell, (y+quiet)*cell, cell, cell)
+			}
+		}
+	}
+	b.WriteString(`</svg>`)
+	return b.String()
+}
@@ -0,0 +1,100 @@
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/a2n2k3p4/tutorium-backend/handlers"
+	"github.com/a2n2k3p4/tutorium-backend/qrimage"
+	"github.com/gofiber/fiber/v2"
+	omise "github.com/omise/omise-go"
+	"github.com/omise/omise-go/operations"
+)
+
+func TestPromptPayFlow(t *testing.T) {
+	var mu sync.Mutex
+	var delivered []string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var env struct{ ID string }
+		_ = json.NewDecoder(r.Body).Decode(&env)
+		mu.Lock()
+		delivered = append(delivered, env.ID)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+
+	sim := New("http://sandbox.test/")
+	sim.WebhookURL = hook.URL
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
+	sim.Register(app)
+
+	src, err := sim.CreateSource(context.Background(), &operations.CreateSource{Type: "promptpay", Amount: 10000, Currency: "thb"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch, err := sim.CreateCharge(context.Background(), &operations.CreateCharge{Amount: 10000, Currency: "thb", Source: src.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ch.Status != omise.ChargePending {
+		t.Fatalf("status = %s, want pending", ch.Status)
+	}
+
+	qrURL := ch.Source.ScannableCode.Image.DownloadURI
+	if !strings.HasPrefix(qrURL, "http://sandbox.test/sandbox/sources/") {
+		t.Fatalf("QR url = %q, want one served by the simulator", qrURL)
+	}
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, strings.TrimPrefix(qrURL, "http://sandbox.test"), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("QR status = %d", resp.StatusCode)
+	}
+	if _, err := qrimage.Decode(svg); err != nil {
+		t.Fatalf("QR svg does not decode: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/sandbox/charges/"+ch.ID+"/complete", strings.NewReader(`{"status":"successful"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("complete status = %d", resp.StatusCode)
+	}
+
+	if len(delivered) != 1 {
+		t.Fatalf("webhooks delivered = %v, want 1", delivered)
+	}
+	ev, err := sim.RetrieveEvent(context.Background(), delivered[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ev.Data.(*omise.Charge); got.ID != ch.ID || got.Status != omise.ChargeSuccessful {
+		t.Errorf("event charge = %s %s, want %s successful", got.ID, got.Status, ch.ID)
+	}
+
+	// A settled charge cannot be completed twice.
+	resp, _ = app.Test(httptest.NewRequest(http.MethodPost, "/sandbox/charges/"+ch.ID+"/complete", nil))
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("second complete status = %d, want 409", resp.StatusCode)
+	}
+}
+
+func TestQRCodeSVGIsDeterministic(t *testing.T) {
+	if QRCodeSVG("src_test_1") != QRCodeSVG("src_test_1") {
+		t.Error("same seed rendered differently")
+	}
+	if QRCodeSVG("src_test_1") == QRCodeSVG("src_test_2") {
+		t.Error("different seeds rendered the same")
+	}
+}
@@ -0,0 +1,99 @@
+// Package tax defines the integration point for an external tax / e-Tax invoice service
+// (Thai Revenue Department e-Tax invoice & e-Receipt). Successful charges are submitted as
+// invoices and the provider's document references are stored on our side.
+package tax
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/money"
+)
+
+// VATRateBps is the Thai standard VAT rate (7%) in basis points, the default of VAT_RATE_PCT. Charge
+// amounts are VAT-inclusive.
+const VATRateBps = 700
+
+// InvoiceNumber is the tax invoice number seq of the month of issuedAt (which should be Bangkok time):
+// TIV-YYYYMM-NNNNNN.
+func InvoiceNumber(issuedAt time.Time, seq int64) string {
+	return fmt.Sprintf("TIV-%s-%06d", issuedAt.Format("200601"), seq)
+}
+
+// SplitVAT splits a VAT-inclusive total at rateBps into net and VAT.
+func SplitVAT(total money.Money, rateBps int64) (net, vat money.Money) {
+	return total.ExtractInclusive(rateBps, money.RoundHalfUp)
+}
+
+// Invoice is the data submitted for one successful charge.
+type Invoice struct {
+	Reference     string // our idempotency key for the provider (the Omise charge id)
+	Number        string // our tax invoice number (Transaction.TaxInvoiceNumber), if issued
+	TransactionID uint
+	UserID        *uint
+	Total         money.Money // amount paid, VAT included
+	Net           money.Money // Total minus VAT
+	VAT           money.Money
+	VATRateBps    int64
+	Description   string
+	IssuedAt      time.Time
+}
+
+// NewInvoice splits a VAT-inclusive total into net and VAT at rateBps.
+func NewInvoice(reference string, transactionID uint, userID *uint, total money.Money, rateBps int64, description string, issuedAt time.Time) Invoice {
+	net, vat := SplitVAT(total, rateBps)
+	return Invoice{
+		Reference:     reference,
+		TransactionID: transactionID,
+		UserID:        userID,
+		Total:         total,
+		Net:           net,
+		VAT:           vat,
+		VATRateBps:    rateBps,
+		Description:   description,
+		IssuedAt:      issuedAt,
+	}
+}
+
+// Document is the provider's reference to an issued tax document.
+type Document struct {
+	DocumentID string // provider-side id
+	Number     string // human-readable tax invoice number
+	URL        string // where the PDF/XML can be fetched, if the provider hosts it
+}
+
+// Service submits invoices to an external tax provider. Submit must be idempotent on Invoice.Reference.
+type Service interface {
+	Name() string
+	Submit(ctx context.Context, inv Invoice) (*Document, error)
+}
+
+// New returns the Service for a provider name (TAX_PROVIDER). An empty name disables tax submission.
+func New(provider string) (Service, error) {
+	switch provider {
+	case "":
+		return nil, nil
+	case "stub":
+		return Stub{}, nil
+	default:
+		return nil, fmt.Errorf("unknown tax provider %q", provider)
+	}
+}
+
+// Stub issues deterministic local document references without calling anything; used until a real
+// RD-certified provider is integrated, and in development.
+type Stub struct{}
+
+func (Stub) Name() string { return "stub" }
+
+func (Stub) Submit(_ context.Context, inv Invoice) (*Document, error) {
+	if inv.Reference == "" {
+		return nil, fmt.Errorf("tax: invoice reference is required")
+	}
+	number := inv.Number
+	if number == "" {
+		number = InvoiceNumber(inv.IssuedAt, int64(inv.TransactionID))
+	}
+	return &Document{DocumentID: "stub_" + inv.Reference, Number: number}, nil
+}
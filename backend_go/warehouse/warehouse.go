@@ -0,0 +1,151 @@
+// Package warehouse encodes transactions for the data warehouse as CSV files, plain or gzip-compressed,
+// one flat Row per transaction. Raw payloads are left out; metadata is carried as a JSON string.
+package warehouse
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+)
+
+// Formats of Encode; the format is also the file extension.
+const (
+	FormatCSV     = "csv"
+	FormatCSVGzip = "csv.gz"
+)
+
+// ValidFormat reports whether Encode writes format.
+func ValidFormat(format string) bool {
+	return format == FormatCSV || format == FormatCSVGzip
+}
+
+// ContentType is the MIME type of a file in format.
+func ContentType(format string) string {
+	if format == FormatCSVGzip {
+		return "application/gzip"
+	}
+	return "text/csv"
+}
+
+// Row is one transaction as the warehouse loads it, in column order.
+type Row struct {
+	ID             int64
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	MerchantID     int64
+	UserID         *int64
+	ActingUserID   *int64
+	Provider       string
+	ChargeID       string
+	AmountSatang   int64
+	Currency       string
+	Channel        string
+	Status         string
+	FailureCode    *string
+	FailureMessage *string
+	Description    *string
+	Meta           string // JSON object, "{}" when empty
+	// Columns added after the first loads go last, so earlier files stay loadable.
+	VATSatang        int64
+	TaxInvoiceNumber *string
+}
+
+// RowOf flattens t.
+func RowOf(t models.Transaction) Row {
+	meta := []byte("{}")
+	if len(t.Meta) > 0 {
+		if raw, err := json.Marshal(t.Meta); err == nil {
+			meta = raw
+		}
+	}
+	return Row{
+		ID:             int64(t.ID),
+		CreatedAt:      t.CreatedAt,
+		UpdatedAt:      t.UpdatedAt,
+		MerchantID:     int64(t.MerchantID),
+		UserID:         optionalID(t.UserID),
+		ActingUserID:   optionalID(t.ActingUserID),
+		Provider:       t.Provider,
+		ChargeID:       t.ChargeID,
+		AmountSatang:   t.AmountSatang,
+		Currency:       t.Currency,
+		Channel:        t.Channel,
+		Status:         t.Status,
+		FailureCode:    t.FailureCode,
+		FailureMessage: t.FailureMessage,
+		Description:    t.Description,
+		Meta:           string(meta),
+
+		VATSatang:        t.VATSatang,
+		TaxInvoiceNumber: t.TaxInvoiceNumber,
+	}
+}
+
+// Header are the columns of a file, in Row order.
+var Header = []string{"id", "created_at", "updated_at", "merchant_id", "user_id", "acting_user_id", "provider", "charge_id",
+	"amount_satang", "currency", "channel", "status", "failure_code", "failure_message", "description", "meta",
+	"vat_satang", "tax_invoice_number"}
+
+// Encode writes rows to w in format: a Header line, then one line per row. Times are RFC3339 UTC;
+// missing optional values are empty.
+func Encode(w io.Writer, format string, rows []models.Transaction) error {
+	switch format {
+	case FormatCSV:
+		return encodeCSV(w, rows)
+	case FormatCSVGzip:
+		zw := gzip.NewWriter(w)
+		if err := encodeCSV(zw, rows); err != nil {
+			return err
+		}
+		return zw.Close()
+	default:
+		return fmt.Errorf("warehouse: unknown format %q", format)
+	}
+}
+
+// (helper for Encode)
+func encodeCSV(w io.Writer, rows []models.Transaction) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(Header); err != nil {
+		return err
+	}
+	ts := func(t time.Time) string { return t.UTC().Format(time.RFC3339Nano) }
+	id := func(n *int64) string {
+		if n == nil {
+			return ""
+		}
+		return strconv.FormatInt(*n, 10)
+	}
+	str := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	for _, t := range rows {
+		r := RowOf(t)
+		if err := cw.Write([]string{strconv.FormatInt(r.ID, 10), ts(r.CreatedAt), ts(r.UpdatedAt), strconv.FormatInt(r.MerchantID, 10),
+			id(r.UserID), id(r.ActingUserID), r.Provider, r.ChargeID, strconv.FormatInt(r.AmountSatang, 10), r.Currency, r.Channel,
+			r.Status, str(r.FailureCode), str(r.FailureMessage), str(r.Description), r.Meta,
+			strconv.FormatInt(r.VATSatang, 10), str(r.TaxInvoiceNumber)}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// (helper for RowOf) id as an optional column.
+func optionalID(id *uint) *int64 {
+	if id == nil {
+		return nil
+	}
+	n := int64(*id)
+	return &n
+}
@@ -0,0 +1,61 @@
+package warehouse
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/datatypes"
+)
+
+func TestEncode(t *testing.T) {
+	uid := uint(4)
+	code := "insufficient_fund"
+	invoice := "TIV-202510-000001"
+	at := time.Date(2025, 10, 1, 3, 0, 0, 0, time.FixedZone("ICT", 7*3600))
+	rows := []models.Transaction{
+		{ID: 2, CreatedAt: at, UpdatedAt: at, MerchantID: 1, UserID: &uid, Provider: "omise", ChargeID: "chrg_test_2", AmountSatang: 150000,
+			Currency: "thb", Channel: "promptpay", Status: "successful", Meta: datatypes.JSONMap{"order_ref": "ORD-1"},
+			VATSatang: 9813, TaxInvoiceNumber: &invoice},
+		{ID: 1, CreatedAt: at, UpdatedAt: at, MerchantID: 1, Provider: "omise", ChargeID: "chrg_test_1", AmountSatang: 5000,
+			Currency: "thb", Channel: "card", Status: "failed", FailureCode: &code},
+	}
+
+	for _, format := range []string{FormatCSV, FormatCSVGzip} {
+		var buf bytes.Buffer
+		if err := Encode(&buf, format, rows); err != nil {
+			t.Fatalf("%s: Encode: %v", format, err)
+		}
+		var r io.Reader = &buf
+		if format == FormatCSVGzip {
+			zr, err := gzip.NewReader(&buf)
+			if err != nil {
+				t.Fatalf("%s: not gzip: %v", format, err)
+			}
+			r = zr
+		}
+		lines, err := csv.NewReader(r).ReadAll()
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if len(lines) != 3 || strings.Join(lines[0], ",") != strings.Join(Header, ",") {
+			t.Fatalf("%s: %d lines, header %v", format, len(lines), lines[0])
+		}
+		if got := lines[1]; got[1] != "2025-09-30T20:00:00Z" || got[4] != "4" || got[15] != `{"order_ref":"ORD-1"}` ||
+			got[16] != "9813" || got[17] != invoice {
+			t.Errorf("%s: first row = %v", format, got)
+		}
+		if got := lines[2]; got[4] != "" || got[12] != code || got[15] != "{}" || got[17] != "" {
+			t.Errorf("%s: second row = %v, want no user, the failure code and empty meta", format, got)
+		}
+	}
+
+	if err := Encode(io.Discard, "parquet", rows); err == nil {
+		t.Error("Encode accepted an unknown format")
+	}
+}
@@ -56,14 +56,14 @@ class _PaymentScreenState extends State<PaymentScreen> {
       payload['user_id'] = widget.userId;
       
       final res = await http.post(
-        Uri.parse('$backendUrl/payments/charge'), // Updated endpoint
+        Uri.parse('$backendUrl/api/v1/payments/charge'),
         headers: {'Content-Type': 'application/json'},
         body: json.encode(payload),
       );
 
       final body = json.decode(res.body);
       if (res.statusCode != 200) {
-        await _setStatus('Payment failed: ${body['error'] ?? 'Server error'}');
+        await _setStatus('Payment failed: ${body['message'] ?? body['error'] ?? 'Server error'}');
         return;
       }
 